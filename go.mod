@@ -5,6 +5,7 @@ go 1.23.6
 require (
 	github.com/TuftsBCB/io v0.0.0-20140121014543-22b94e9b23f9
 	github.com/spf13/cobra v1.10.1
+	github.com/spf13/pflag v1.0.9
 )
 
 require (
@@ -12,5 +13,4 @@ require (
 	github.com/TuftsBCB/structure v0.0.0-20130712042756-270e11c872f1 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/skelterjohn/go.matrix v0.0.0-20130517144113-daa59528eefd // indirect
-	github.com/spf13/pflag v1.0.9 // indirect
 )
@@ -0,0 +1,286 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	addHydrogensOutput  string
+	addHydrogensReplace bool
+	addHydrogensBatch   batchOptions
+)
+
+// peptideBondCutoff is the longest C-N distance (in Å) treated as a peptide bond
+const peptideBondCutoff = 2.0
+
+var addHydrogensCmd = &cobra.Command{
+	Use:   "add-hydrogens [flags] [input_file...]",
+	Short: "Add hydrogens to standard amino acids and nucleotides",
+	Long: `Add hydrogen atoms to standard amino acids and nucleotides, placed as riding hydrogens from ideal geometry.
+
+Hydrogens are placed from the positions of the heavy atoms they are bonded to: tetrahedral for sp3 atoms,
+in plane for sp2 atoms and aromatic rings, staggered for methyl and NH3+ groups and anti for hydroxyls.
+Histidine is protonated on NE2 (the HIE tautomer), and ASP, GLU, LYS, ARG and the N-terminus are in their
+charged states. Hydrogens that already exist are kept, unless --replace is given. Hydrogens whose heavy
+atoms are missing are skipped with a warning, and other residues (ligands, waters) are left unchanged.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Add hydrogens to a PDB file
+  pdbtk add-hydrogens 1a02.pdb --output 1a02_h.pdb
+
+  # Rebuild all hydrogens from ideal geometry
+  pdbtk add-hydrogens --replace 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runAddHydrogens,
+}
+
+func init() {
+	addHydrogensCmd.Flags().StringVarP(&addHydrogensOutput, "output", "o", "", "Output file (default: stdout)")
+	addHydrogensCmd.Flags().BoolVar(&addHydrogensReplace, "replace", false, "Remove existing hydrogens and rebuild them all")
+	addBatchFlags(addHydrogensCmd, &addHydrogensBatch, "{name}")
+}
+
+func runAddHydrogens(cmd *cobra.Command, args []string) error {
+	return runBatch(cmd.Context(), args, addHydrogensOutput, addHydrogensBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+
+		atoms := file.Atoms
+		if addHydrogensReplace {
+			atoms = removeHydrogens(atoms)
+		}
+		atoms, added, skipped := addHydrogens(atoms)
+		if skipped > 0 {
+			if err := warn("%d hydrogens could not be placed because heavy atoms are missing", skipped); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Added %d hydrogen atoms\n", added)
+
+		return writePDBRecords(file.WithAtoms(atoms), writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// groupResidues splits atoms into residues, in file order
+func groupResidues(atoms []*AtomRecord) [][]*AtomRecord {
+	var residues [][]*AtomRecord
+	for i, atom := range atoms {
+		if i == 0 || atom.Residue() != atoms[i-1].Residue() {
+			residues = append(residues, nil)
+		}
+		residues[len(residues)-1] = append(residues[len(residues)-1], atom)
+	}
+	return residues
+}
+
+// hydrogenBondLength returns the ideal length of a bond between a hydrogen and an atom of the given element
+func hydrogenBondLength(element string) float64 {
+	switch element {
+	case "N":
+		return 1.01
+	case "O":
+		return 0.96
+	case "S":
+		return 1.34
+	}
+	return 1.09
+}
+
+// addHydrogens adds hydrogens to the standard residues in atoms. It returns the new atoms, the number of
+// hydrogens added and the number that could not be placed because heavy atoms were missing.
+func addHydrogens(atoms []*AtomRecord) ([]*AtomRecord, int, int) {
+	var result []*AtomRecord
+	added, skipped := 0, 0
+
+	residues := groupResidues(atoms)
+	for i, residue := range residues {
+		result = append(result, residue...)
+		sites, ok := hydrogenSites[residue[0].ResName]
+		if !ok {
+			continue
+		}
+
+		var previous []*AtomRecord
+		if i > 0 && residues[i-1][0].Model == residue[0].Model && residues[i-1][0].ChainID == residue[0].ChainID {
+			previous = residues[i-1]
+		}
+		sites = append(append([]hydrogenSite{}, sites...), backboneHydrogenSites(residue, previous)...)
+
+		hydrogens, missing := placeResidueHydrogens(residue, previous, sites)
+		result = append(result, hydrogens...)
+		added += len(hydrogens)
+		skipped += missing
+	}
+	return result, added, skipped
+}
+
+// backboneHydrogenSites returns the amide hydrogen site of an amino acid, or the NH3+ hydrogens when the
+// residue is the first of its chain
+func backboneHydrogenSites(residue, previous []*AtomRecord) []hydrogenSite {
+	if atomOrderIndex(residue[0].ResName, "CA") < 0 {
+		return nil
+	}
+	if previous == nil {
+		if residue[0].ResName == "PRO" {
+			return []hydrogenSite{methylene("N", "CA", "CD", "H2", "H3")}
+		}
+		return []hydrogenSite{methyl("N", "C", "CA", "H1", "H2", "H3")}
+	}
+	if residue[0].ResName == "PRO" {
+		return nil
+	}
+	// The bond to the C atom of the previous residue is resolved under the name "-C"
+	return []hydrogenSite{planar("N", "-C", "CA", "H")}
+}
+
+// placeResidueHydrogens places the hydrogens of the sites of one residue. Residues with alternate
+// locations get hydrogens for each conformer where the atoms they are placed from differ.
+func placeResidueHydrogens(residue, previous []*AtomRecord, sites []hydrogenSite) ([]*AtomRecord, int) {
+	existing := make(map[string]bool)
+	var altLocs []byte
+	for _, atom := range residue {
+		existing[atom.Name] = true
+		if atom.AltLoc != ' ' && !containsByte(altLocs, atom.AltLoc) {
+			altLocs = append(altLocs, atom.AltLoc)
+		}
+	}
+	sort.Slice(altLocs, func(i, j int) bool { return altLocs[i] < altLocs[j] })
+	if len(altLocs) == 0 {
+		altLocs = []byte{' '}
+	}
+
+	var previousC *AtomRecord
+	for _, atom := range previous {
+		if atom.Name == "C" && (previousC == nil || atom.AltLoc < previousC.AltLoc) {
+			previousC = atom
+		}
+	}
+
+	var hydrogens []*AtomRecord
+	missing := 0
+	placedShared := make(map[string]bool)
+	for conformer, altLoc := range altLocs {
+		lookup := func(name string) *AtomRecord {
+			if name == "-C" {
+				return previousC
+			}
+			var fallback *AtomRecord
+			for _, atom := range residue {
+				if atom.Name != name {
+					continue
+				}
+				if atom.AltLoc == altLoc {
+					return atom
+				}
+				if atom.AltLoc == ' ' || fallback == nil {
+					fallback = atom
+				}
+			}
+			return fallback
+		}
+
+		for _, site := range sites {
+			if existing[site.names[0]] {
+				continue
+			}
+
+			parent := lookup(site.parent)
+			refs := make([]*AtomRecord, len(site.refs))
+			complete := parent != nil
+			shared := parent != nil && parent.AltLoc == ' '
+			for i, name := range site.refs {
+				refs[i] = lookup(name)
+				if refs[i] == nil {
+					complete = false
+				} else if refs[i].AltLoc != ' ' && name != "-C" {
+					shared = false
+				}
+			}
+			if complete && site.refs[0] == "-C" && distance(refs[0].Coord(), parent.Coord()) > peptideBondCutoff {
+				// Chain break: there is no peptide bond to place the amide hydrogen from
+				complete = false
+			}
+			if !complete {
+				if conformer == 0 {
+					missing += len(site.names)
+				}
+				continue
+			}
+			if shared {
+				if placedShared[site.parent] {
+					continue
+				}
+				placedShared[site.parent] = true
+			}
+
+			for i, position := range siteHydrogenPositions(site, parent, refs) {
+				hydrogen := parent.Copy()
+				hydrogen.Serial = 0
+				hydrogen.Name = site.names[i]
+				hydrogen.Element = "H"
+				hydrogen.Charge = ""
+				if !shared {
+					hydrogen.AltLoc = altLoc
+				}
+				hydrogen.SetCoord(position)
+				hydrogens = append(hydrogens, hydrogen)
+			}
+		}
+	}
+	return hydrogens, missing
+}
+
+// siteHydrogenPositions computes the positions of the hydrogens of a site
+func siteHydrogenPositions(site hydrogenSite, parent *AtomRecord, refs []*AtomRecord) []vec3 {
+	p := parent.Coord()
+	element := parent.Element
+	if element == "" {
+		element = inferElement(parent)
+	}
+	bond := hydrogenBondLength(element)
+
+	directionAway := func() vec3 {
+		var sum vec3
+		for _, ref := range refs {
+			sum = sum.add(ref.Coord().sub(p).unit())
+		}
+		return sum.scale(-1).unit()
+	}
+
+	switch site.kind {
+	case hydrogenTetrahedral, hydrogenPlanar:
+		return []vec3{p.add(directionAway().scale(bond))}
+	case hydrogenMethylene:
+		// Both hydrogens lie in the plane bisecting the two bonds, at half the tetrahedral angle either side
+		bisector := directionAway()
+		normal := refs[0].Coord().sub(p).cross(refs[1].Coord().sub(p)).unit()
+		const halfAngle = 109.5 / 2 * math.Pi / 180
+		along := bisector.scale(bond * math.Cos(halfAngle))
+		across := normal.scale(bond * math.Sin(halfAngle))
+		return []vec3{p.add(along).add(across), p.add(along).sub(across)}
+	}
+
+	positions := make([]vec3, len(site.torsions))
+	for i, torsion := range site.torsions {
+		positions[i] = placeAtom(refs[0].Coord(), refs[1].Coord(), p, bond, site.angle, torsion)
+	}
+	return positions
+}
+
+// containsByte reports whether b is in list
+func containsByte(list []byte, b byte) bool {
+	for _, x := range list {
+		if x == b {
+			return true
+		}
+	}
+	return false
+}
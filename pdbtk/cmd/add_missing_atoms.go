@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	addMissingOutput string
+	addMissingChains string
+	addMissingBatch  batchOptions
+)
+
+var addMissingAtomsCmd = &cobra.Command{
+	Use:   "add-missing-atoms [flags] [input_file...]",
+	Short: "Rebuild missing heavy atoms of standard amino acids",
+	Long: `Find standard amino acids with missing heavy atoms (truncated side chains, missing CB or O) and rebuild
+them from ideal bond lengths and angles, so that structures are complete before simulation.
+
+Side chain atoms are built outward from the atoms that are present, which are not moved. Chi angles
+that can be measured from the existing atoms are kept; the others are taken from the most frequent
+rotamer of the penultimate rotamer library whose new atoms do not clash with the rest of the structure,
+as in mutate. A missing O is placed anti to the N of the next residue, or to N of the residue itself at
+the end of a chain. Residues without N, CA or C are skipped with a warning, and OXT, hydrogens (see
+add-hydrogens), nucleotides and ligands are not added.
+
+Each residue that was completed is reported on stderr with the atoms that were added, and the clashes
+of the new side chain atoms are reported as a warning. --chains restricts rebuilding to chains,
+optionally with residue ranges (A:10-120).
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Rebuild truncated side chains before a simulation
+  pdbtk add-missing-atoms 1a02.pdb --output 1a02_complete.pdb
+
+  # Rebuild the missing atoms of chain A only
+  pdbtk add-missing-atoms --chains A 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runAddMissingAtoms,
+}
+
+func init() {
+	addMissingAtomsCmd.Flags().StringVarP(&addMissingOutput, "output", "o", "", "Output file (default: stdout)")
+	addMissingAtomsCmd.Flags().StringVarP(&addMissingChains, "chains", "c", "", "Comma-separated list of chain IDs to rebuild, optionally with residue ranges (default: all chains)")
+	addBatchFlags(addMissingAtomsCmd, &addMissingBatch, "{name}")
+}
+
+func runAddMissingAtoms(cmd *cobra.Command, args []string) error {
+	var selections chainSelections
+	if addMissingChains != "" {
+		var err error
+		if selections, err = parseChainSelections(addMissingChains); err != nil {
+			return err
+		}
+	}
+
+	return runBatch(cmd.Context(), args, addMissingOutput, addMissingBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		residues := groupResidues(file.Atoms)
+		var atoms []*AtomRecord
+		added, completed := 0, 0
+		for i, residue := range residues {
+			key := residue[0].Residue()
+			if _, ok := sidechainAtoms[key.ResName]; !ok || (selections != nil && !selections.Contains(key.ChainID, key.ResSeq, key.ICode)) {
+				atoms = append(atoms, residue...)
+				continue
+			}
+			var next []*AtomRecord
+			if i+1 < len(residues) && residues[i+1][0].Model == key.Model && residues[i+1][0].ChainID == key.ChainID {
+				next = residues[i+1]
+			}
+			rebuilt, names, clashes, err := completeResidue(residue, next, file.Atoms)
+			if err != nil {
+				if err := warn("%s", err); err != nil {
+					return err
+				}
+			}
+			atoms = append(atoms, rebuilt...)
+			if len(names) == 0 {
+				continue
+			}
+			added += len(names)
+			completed++
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s %s: added %s\n", residueLabel(key), key.ResName, strings.Join(names, ", "))
+			if len(clashes) > 0 {
+				if err := warn("%s %s clashes: %s", residueLabel(key), key.ResName, strings.Join(clashes, ", ")); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Added %d atoms to %d residues\n", added, completed)
+
+		return writePDBRecords(file.WithAtoms(atoms), writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// completeResidue returns the atoms of a standard amino acid with its missing heavy atoms rebuilt, the
+// names of the atoms that were added and the clashes of the new side chain atoms with the rest of the
+// structure. next is the following residue of the chain, if any. Residues without N, CA or C are
+// returned unchanged with an error.
+func completeResidue(residue, next []*AtomRecord, all []*AtomRecord) ([]*AtomRecord, []string, []string, error) {
+	key := residue[0].Residue()
+	positions := make(map[string]vec3)
+	var ca *AtomRecord
+	for _, atom := range filterAltLocRecords(residue, "first") {
+		positions[atom.Name] = atom.Coord()
+		if atom.Name == "CA" {
+			ca = atom
+		}
+	}
+	for _, name := range []string{"N", "CA", "C"} {
+		if _, ok := positions[name]; !ok {
+			return residue, nil, nil, fmt.Errorf("residue %s %s has no %s atom; its missing atoms were not rebuilt", residueLabel(key), key.ResName, name)
+		}
+	}
+
+	var names []string
+	var placed []vec3
+	if _, ok := positions["O"]; !ok {
+		anti := positions["N"]
+		for _, atom := range next {
+			if atom.Name == "N" && distance(atom.Coord(), positions["C"]) <= peptideBondCutoff {
+				anti = atom.Coord()
+			}
+		}
+		names, placed = append(names, "O"), append(placed, placeAtom(anti, positions["CA"], positions["C"], 1.23, 120.5, 180))
+	}
+	var clashes []string
+	if key.ResName != "GLY" {
+		chi := measuredChi(key.ResName, positions)
+		if chiCount(key.ResName) > 0 {
+			chi, _ = chooseRotamer(key.ResName, key, positions, chi, all)
+		}
+		sidechainNames, sidechain := buildSidechain(key.ResName, positions, chi)
+		clashes, _ = sidechainClashes(sidechainNames, sidechain, positions, clashCandidates(key, positions, all))
+		names, placed = append(names, sidechainNames...), append(placed, sidechain...)
+	}
+
+	atoms := residue
+	var added []string
+	for i, name := range names {
+		if _, ok := positions[name]; ok {
+			continue
+		}
+		atom := *ca
+		atom.Serial, atom.Name, atom.Element, atom.Charge, atom.Occupancy, atom.AltLoc = 0, name, name[:1], "", 1, ' '
+		atom.SetCoord(placed[i])
+		atoms = insertStandardAtom(atoms, &atom)
+		added = append(added, name)
+	}
+	return atoms, added, clashes, nil
+}
+
+// insertStandardAtom inserts a heavy atom into the atoms of a residue before the first atom that
+// follows it in the standard order, or before the hydrogens and non-standard atoms
+func insertStandardAtom(atoms []*AtomRecord, atom *AtomRecord) []*AtomRecord {
+	index := atomOrderIndex(atom.ResName, atom.Name)
+	at := len(atoms)
+	for i, other := range atoms {
+		if j := atomOrderIndex(other.ResName, other.Name); j < 0 || j > index {
+			at = i
+			break
+		}
+	}
+	result := make([]*AtomRecord, 0, len(atoms)+1)
+	result = append(result, atoms[:at]...)
+	result = append(result, atom)
+	return append(result, atoms[at:]...)
+}
@@ -0,0 +1,128 @@
+package cmd
+
+// Scores used by alignSequences
+const (
+	alignMatch     = 5
+	alignMismatch  = -4
+	alignGapOpen   = -10
+	alignGapExtend = -1
+)
+
+// alignedPair is one column of a pairwise alignment. A or B is -1 where that sequence has a gap.
+type alignedPair struct {
+	A, B int
+}
+
+// alignSequences aligns two sequences with affine gap penalties (Gotoh). Gaps at either end of
+// either sequence are not penalised, so a fragment aligns to the matching part of a longer sequence.
+func alignSequences(a, b string) []alignedPair {
+	n, m := len(a), len(b)
+	const negInf = -1 << 30
+
+	// match[i][j]: a[i-1] aligned with b[j-1]; gapA[i][j]: b[j-1] against a gap; gapB[i][j]: a[i-1] against a gap
+	match := newScoreMatrix(n+1, m+1, negInf)
+	gapA := newScoreMatrix(n+1, m+1, negInf)
+	gapB := newScoreMatrix(n+1, m+1, negInf)
+	match[0][0] = 0
+	for i := 1; i <= n; i++ {
+		gapB[i][0] = 0
+	}
+	for j := 1; j <= m; j++ {
+		gapA[0][j] = 0
+	}
+
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			score := alignMismatch
+			if a[i-1] == b[j-1] {
+				score = alignMatch
+			}
+			match[i][j] = max3(match[i-1][j-1], gapA[i-1][j-1], gapB[i-1][j-1]) + score
+
+			// End gaps are free: no penalty for gaps in the last row or column
+			openA, extendA := alignGapOpen, alignGapExtend
+			if i == n {
+				openA, extendA = 0, 0
+			}
+			gapA[i][j] = max3(match[i][j-1]+openA, gapA[i][j-1]+extendA, gapB[i][j-1]+openA)
+
+			openB, extendB := alignGapOpen, alignGapExtend
+			if j == m {
+				openB, extendB = 0, 0
+			}
+			gapB[i][j] = max3(match[i-1][j]+openB, gapB[i-1][j]+extendB, gapA[i-1][j]+openB)
+		}
+	}
+
+	// Trace back from the best of the three matrices in the last cell
+	matrices := [][][]int{match, gapA, gapB}
+	state := 0
+	for s := 1; s < 3; s++ {
+		if matrices[s][n][m] > matrices[state][n][m] {
+			state = s
+		}
+	}
+	var pairs []alignedPair
+	i, j := n, m
+	for i > 0 || j > 0 {
+		switch {
+		case i == 0:
+			state = 1
+		case j == 0:
+			state = 2
+		}
+		switch state {
+		case 0:
+			pairs = append(pairs, alignedPair{A: i - 1, B: j - 1})
+			state = bestPrevious(matrices, i-1, j-1, [3]int{0, 0, 0})
+			i, j = i-1, j-1
+		case 1:
+			pairs = append(pairs, alignedPair{A: -1, B: j - 1})
+			open, extend := alignGapOpen, alignGapExtend
+			if i == n {
+				open, extend = 0, 0
+			}
+			state = bestPrevious(matrices, i, j-1, [3]int{open, extend, open})
+			j--
+		default:
+			pairs = append(pairs, alignedPair{A: i - 1, B: -1})
+			open, extend := alignGapOpen, alignGapExtend
+			if j == m {
+				open, extend = 0, 0
+			}
+			state = bestPrevious(matrices, i-1, j, [3]int{open, open, extend})
+			i--
+		}
+	}
+
+	for left, right := 0, len(pairs)-1; left < right; left, right = left+1, right-1 {
+		pairs[left], pairs[right] = pairs[right], pairs[left]
+	}
+	return pairs
+}
+
+// bestPrevious returns the matrix with the best score at (i, j) once the transition penalties are added
+func bestPrevious(matrices [][][]int, i, j int, penalties [3]int) int {
+	best := 0
+	for s := 1; s < 3; s++ {
+		if matrices[s][i][j]+penalties[s] > matrices[best][i][j]+penalties[best] {
+			best = s
+		}
+	}
+	return best
+}
+
+func newScoreMatrix(rows, cols, fill int) [][]int {
+	matrix := make([][]int, rows)
+	for i := range matrix {
+		matrix[i] = make([]int, cols)
+		for j := range matrix[i] {
+			matrix[i][j] = fill
+		}
+	}
+	return matrix
+}
+
+func max3(a, b, c int) int {
+	return max(a, max(b, c))
+}
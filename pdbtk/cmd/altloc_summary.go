@@ -0,0 +1,249 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	altLocSummaryFormat string
+	altLocSummaryOutput string
+)
+
+var altLocSummaryCmd = &cobra.Command{
+	Use:   "altloc-summary [flags] [input_file]",
+	Short: "Summarize the alternate locations of a structure",
+	Long: `Summarize the alternate conformations (ALTLOC) of a structure, to help choose an --altloc policy
+before extracting or collapsing them.
+
+The report gives the number of residues and atoms with alternate locations, the ALTLOC identifiers
+used with their atom and residue counts, the distribution of the occupancies of alternate atoms, the
+chains affected, the residues whose alternate occupancies do not add up to 1, and every residue with
+alternate locations and its identifiers. Residues whose alternate locations have different residue
+names (microheterogeneity) are listed with all their names, e.g. A:45 SER/THR.
+Only the first model is analysed.
+The output is a text report, or JSON with --format json.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Summarize the alternate locations
+  pdbtk altloc-summary 1a02.pdb
+
+  # Report as JSON
+  pdbtk altloc-summary --format json 1a02.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runAltLocSummary,
+}
+
+func init() {
+	altLocSummaryCmd.Flags().StringVarP(&altLocSummaryFormat, "format", "f", "text", "Output format: text or json")
+	altLocSummaryCmd.Flags().StringVarP(&altLocSummaryOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// altLocIdentifier is an ALTLOC identifier and how many atoms and residues use it
+type altLocIdentifier struct {
+	ID       string `json:"id"`
+	Atoms    int    `json:"atoms"`
+	Residues int    `json:"residues"`
+}
+
+// occupancyBin is the number of alternate atoms with an occupancy in a range
+type occupancyBin struct {
+	Range string `json:"range"`
+	Atoms int    `json:"atoms"`
+}
+
+// chainAltLocs is the number of residues of a chain, and of those with alternate locations
+type chainAltLocs struct {
+	Chain          string `json:"chain"`
+	Residues       int    `json:"residues"`
+	AltLocResidues int    `json:"altloc_residues"`
+}
+
+// altLocResidue is a residue with alternate locations
+type altLocResidue struct {
+	Residue string   `json:"residue"`
+	AltLocs []string `json:"altlocs"`
+}
+
+// altLocSummary is the report written by altloc-summary
+type altLocSummary struct {
+	Residues        int                `json:"residues"`
+	AltLocResidues  int                `json:"altloc_residues"`
+	AltLocAtoms     int                `json:"altloc_atoms"`
+	Identifiers     []altLocIdentifier `json:"identifiers"`
+	Occupancy       []occupancyBin     `json:"occupancy"`
+	Chains          []chainAltLocs     `json:"chains"`
+	OccupancyIssues []string           `json:"occupancy_not_one"`
+	ResidueList     []altLocResidue    `json:"altloc_residue_list"`
+}
+
+func runAltLocSummary(cmd *cobra.Command, args []string) error {
+	if altLocSummaryFormat != "text" && altLocSummaryFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", altLocSummaryFormat))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	models := file.Models()
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if len(models) > 0 && atom.Model == models[0] {
+			atoms = append(atoms, atom)
+		}
+	}
+	summary := summarizeAltLocs(atoms)
+
+	return writeOutput(cmd.Context(), altLocSummaryOutput, func(w io.Writer) error {
+		if altLocSummaryFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(summary)
+		}
+		writeAltLocSummary(w, summary)
+		return nil
+	})
+}
+
+// summarizeAltLocs summarizes the alternate locations of the atoms of a model
+func summarizeAltLocs(atoms []*AtomRecord) *altLocSummary {
+	// Alternate locations of a residue may have different residue names, so residues are identified
+	// by their position only
+	type position struct {
+		chainID byte
+		resSeq  int
+		iCode   byte
+	}
+	var order []position
+	names := make(map[position][]string)
+	altLocs := make(map[position][]string)
+	identifierAtoms := make(map[string]int)
+	identifierResidues := make(map[string]map[position]bool)
+	atomOccupancy := make(map[position]map[string]float64)
+	bins := []occupancyBin{{Range: "0.0-0.2"}, {Range: "0.2-0.4"}, {Range: "0.4-0.6"}, {Range: "0.6-0.8"}, {Range: "0.8-1.0"}}
+
+	summary := &altLocSummary{Identifiers: []altLocIdentifier{}, Chains: []chainAltLocs{}, OccupancyIssues: []string{}, ResidueList: []altLocResidue{}}
+	for _, atom := range atoms {
+		p := position{atom.ChainID, atom.ResSeq, atom.ICode}
+		if _, seen := names[p]; !seen {
+			order = append(order, p)
+		}
+		if !containsString(names[p], atom.ResName) {
+			names[p] = append(names[p], atom.ResName)
+		}
+		if atom.AltLoc == ' ' || atom.AltLoc == 0 {
+			continue
+		}
+		id := string(atom.AltLoc)
+		summary.AltLocAtoms++
+		identifierAtoms[id]++
+		if identifierResidues[id] == nil {
+			identifierResidues[id] = make(map[position]bool)
+		}
+		identifierResidues[id][p] = true
+		if !containsString(altLocs[p], id) {
+			altLocs[p] = append(altLocs[p], id)
+		}
+		if atomOccupancy[p] == nil {
+			atomOccupancy[p] = make(map[string]float64)
+		}
+		atomOccupancy[p][atom.Name] += atom.Occupancy
+		// Bins are closed at the bottom, e.g. 0.60 falls in 0.6-0.8, except that 1.00 falls in 0.8-1.0
+		bin := int(math.Floor(atom.Occupancy*5 + 1e-6))
+		bins[min(max(bin, 0), len(bins)-1)].Atoms++
+	}
+	summary.Occupancy = bins
+
+	var ids []string
+	for id := range identifierAtoms {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		summary.Identifiers = append(summary.Identifiers, altLocIdentifier{ID: id, Atoms: identifierAtoms[id], Residues: len(identifierResidues[id])})
+	}
+
+	chainIndex := make(map[byte]int)
+	for _, p := range order {
+		i, seen := chainIndex[p.chainID]
+		if !seen {
+			i = len(summary.Chains)
+			chainIndex[p.chainID] = i
+			summary.Chains = append(summary.Chains, chainAltLocs{Chain: string(p.chainID)})
+		}
+		summary.Chains[i].Residues++
+		summary.Residues++
+		if len(altLocs[p]) == 0 {
+			continue
+		}
+		summary.Chains[i].AltLocResidues++
+		summary.AltLocResidues++
+
+		label := ResidueKey{ChainID: p.chainID, ResSeq: p.resSeq, ICode: p.iCode, ResName: strings.Join(names[p], "/")}.String()
+		sort.Strings(altLocs[p])
+		summary.ResidueList = append(summary.ResidueList, altLocResidue{Residue: label, AltLocs: altLocs[p]})
+		for _, total := range atomOccupancy[p] {
+			if math.Abs(total-1) > 0.01 {
+				summary.OccupancyIssues = append(summary.OccupancyIssues, label)
+				break
+			}
+		}
+	}
+	return summary
+}
+
+// writeAltLocSummary writes the text report of altloc-summary
+func writeAltLocSummary(w io.Writer, s *altLocSummary) {
+	percent := 0.0
+	if s.Residues > 0 {
+		percent = 100 * float64(s.AltLocResidues) / float64(s.Residues)
+	}
+	fmt.Fprintf(w, "Residues with alternate locations: %d of %d (%.1f%%)\n", s.AltLocResidues, s.Residues, percent)
+	fmt.Fprintf(w, "Atoms with alternate locations: %d\n", s.AltLocAtoms)
+	if s.AltLocAtoms == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "\nALTLOC identifiers:\n")
+	for _, id := range s.Identifiers {
+		fmt.Fprintf(w, "  %s  %d atoms, %d residues\n", id.ID, id.Atoms, id.Residues)
+	}
+	fmt.Fprintf(w, "\nOccupancy of alternate atoms:\n")
+	for _, bin := range s.Occupancy {
+		fmt.Fprintf(w, "  %s  %d\n", bin.Range, bin.Atoms)
+	}
+	fmt.Fprintf(w, "\nChains:\n")
+	for _, chain := range s.Chains {
+		if chain.AltLocResidues > 0 {
+			fmt.Fprintf(w, "  %s  %d of %d residues\n", chain.Chain, chain.AltLocResidues, chain.Residues)
+		}
+	}
+	if len(s.OccupancyIssues) > 0 {
+		fmt.Fprintf(w, "\nAlternate occupancies not adding up to 1:\n")
+		for _, residue := range s.OccupancyIssues {
+			fmt.Fprintf(w, "  %s\n", residue)
+		}
+	}
+	fmt.Fprintf(w, "\nResidues with alternate locations:\n")
+	for _, residue := range s.ResidueList {
+		fmt.Fprintf(w, "  %-14s %s\n", residue.Residue, strings.Join(residue.AltLocs, ","))
+	}
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Structures can be read directly out of .tar, .tar.gz (.tgz) and .zip archives. An archive given as
+// an input stands for every PDB file it contains, and a single member is named as archive:member,
+// e.g. casp15.tar.gz:T1104/model1.pdb. PDB bundles are tar archives too, but are read as one structure.
+
+// archiveExtensions are the extensions of the archives whose members can be read as inputs
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// errStopArchive stops walkArchive early
+var errStopArchive = errors.New("stop")
+
+// archiveExtension returns the archive extension of name, or "" if it is not an archive
+func archiveExtension(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return ext
+		}
+	}
+	return ""
+}
+
+// isArchive reports whether name is an archive of input files (and not a PDB bundle)
+func isArchive(name string) bool {
+	return archiveExtension(name) != "" && !isPDBBundle(name)
+}
+
+// splitArchiveMember splits an input named as archive:member into the archive and the member name
+func splitArchiveMember(inputFile string) (string, string, bool) {
+	lower := strings.ToLower(inputFile)
+	for _, ext := range archiveExtensions {
+		if i := strings.Index(lower, ext+":"); i >= 0 {
+			end := i + len(ext)
+			if archive := inputFile[:end]; !isPDBBundle(archive) {
+				return archive, inputFile[end+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// isArchiveInput reports whether an input is read out of an archive: an archive member or an archive
+func isArchiveInput(inputFile string) bool {
+	_, _, ok := splitArchiveMember(inputFile)
+	return ok || isArchive(inputFile)
+}
+
+// inputBaseName returns the base name of an input file, or of the member for an archive member
+func inputBaseName(inputFile string) string {
+	if _, member, ok := splitArchiveMember(inputFile); ok {
+		return path.Base(member)
+	}
+	return filepath.Base(inputFile)
+}
+
+// archiveFile is a regular file of an archive
+type archiveFile struct {
+	name    string
+	content []byte
+}
+
+// archiveCache holds the files of the archive read last, decompressed, so that reading its members
+// one by one decompresses the archive only once
+var archiveCache struct {
+	name  string
+	files []archiveFile
+}
+
+// readArchive returns the regular files of a local or object storage archive
+func readArchive(ctx context.Context, archive string) ([]archiveFile, error) {
+	if archiveCache.name == archive {
+		return archiveCache.files, nil
+	}
+	var content []byte
+	var err error
+	if isObjectURI(archive) {
+		content, err = readObject(ctx, archive)
+	} else if content, err = os.ReadFile(archive); err != nil {
+		err = withCode(ErrCodeIO, fmt.Errorf("failed to read archive: %v", err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	files, err := extractArchive(content, archiveExtension(archive) == ".zip")
+	if err != nil {
+		return nil, withCode(ErrCodeParse, fmt.Errorf("failed to read archive %s: %v", archive, err))
+	}
+	archiveCache.name, archiveCache.files = archive, files
+	return files, nil
+}
+
+// extractArchive returns the regular files of a zip or (possibly gzipped) tar archive
+func extractArchive(content []byte, isZip bool) ([]archiveFile, error) {
+	var files []archiveFile
+	if isZip {
+		zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+			files = append(files, archiveFile{f.Name, data})
+		}
+		return files, nil
+	}
+
+	var reader io.Reader = bytes.NewReader(content)
+	if len(content) > 2 && content[0] == 0x1f && content[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", header.Name, err)
+		}
+		files = append(files, archiveFile{strings.TrimPrefix(header.Name, "./"), data})
+	}
+}
+
+// walkArchive calls fn with the name and content of every regular file of an archive, until fn
+// returns errStopArchive or another error
+func walkArchive(ctx context.Context, archive string, fn func(name string, r io.Reader) error) error {
+	files, err := readArchive(ctx, archive)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := fn(f.name, bytes.NewReader(f.content)); err == errStopArchive {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveMemberDir returns the directory of an archive member named as archive:member, which batch runs
+// mirror in --outdir, or "" for a member at the top of the archive. References to parent directories
+// are dropped, so the directory stays inside --outdir.
+func archiveMemberDir(inputFile string) string {
+	_, member, ok := splitArchiveMember(inputFile)
+	if !ok {
+		return ""
+	}
+	dir := strings.TrimPrefix(path.Clean("/"+path.Dir(member)), "/")
+	if dir == "" || dir == "." {
+		return ""
+	}
+	return dir
+}
+
+// listArchivePDBFiles returns the inputs (archive:member) of the PDB files in an archive
+func listArchivePDBFiles(ctx context.Context, archive string) ([]string, error) {
+	var inputs []string
+	err := walkArchive(ctx, archive, func(name string, r io.Reader) error {
+		if checkPDBExtension(name) == nil {
+			inputs = append(inputs, archive+":"+name)
+		}
+		return nil
+	})
+	return inputs, err
+}
+
+// readArchiveInput reads an archive member named as archive:member, or the only PDB file of an archive
+func readArchiveInput(ctx context.Context, inputFile string) ([]byte, error) {
+	archive, member, ok := splitArchiveMember(inputFile)
+	if !ok {
+		members, err := listArchivePDBFiles(ctx, inputFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(members) != 1 {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("%s contains %d PDB files: name one as %s:member, or process them all with --outdir",
+				inputFile, len(members), inputFile))
+		}
+		archive, member, _ = splitArchiveMember(members[0])
+	}
+
+	var content []byte
+	found := false
+	err := walkArchive(ctx, archive, func(name string, r io.Reader) error {
+		if name != member {
+			return nil
+		}
+		var err error
+		if content, err = io.ReadAll(r); err != nil {
+			return withCode(ErrCodeParse, fmt.Errorf("failed to read %s: %v", inputFile, err))
+		}
+		found = true
+		return errStopArchive
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, withCode(ErrCodeInputNotFound, fmt.Errorf("%s not found in %s", member, archive))
+	}
+	return content, nil
+}
@@ -0,0 +1,324 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// symmetryOperator is a rotation matrix followed by a translation, applied as R·x + t
+type symmetryOperator struct {
+	Rotation    [3][3]float64
+	Translation [3]float64
+}
+
+// apply transforms a point by the operator
+func (o symmetryOperator) apply(p vec3) vec3 {
+	var out vec3
+	for i := 0; i < 3; i++ {
+		out[i] = o.Rotation[i][0]*p[0] + o.Rotation[i][1]*p[1] + o.Rotation[i][2]*p[2] + o.Translation[i]
+	}
+	return out
+}
+
+// then returns the operator that applies o and then next
+func (o symmetryOperator) then(next symmetryOperator) symmetryOperator {
+	var combined symmetryOperator
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				combined.Rotation[i][j] += next.Rotation[i][k] * o.Rotation[k][j]
+			}
+		}
+	}
+	combined.Translation = next.apply(vec3(o.Translation))
+	return combined
+}
+
+// parseOperatorList reads the operators of _pdbx_struct_oper_list by ID
+func parseOperatorList(block *CIFBlock) (map[string]symmetryOperator, error) {
+	operators := make(map[string]symmetryOperator)
+	for _, row := range block.Category("_pdbx_struct_oper_list") {
+		var op symmetryOperator
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				item := fmt.Sprintf("matrix[%d][%d]", i+1, j+1)
+				value, err := strconv.ParseFloat(row[item], 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid _pdbx_struct_oper_list.%s of operator %s: %s", item, row["id"], row[item])
+				}
+				op.Rotation[i][j] = value
+			}
+			item := fmt.Sprintf("vector[%d]", i+1)
+			value, err := strconv.ParseFloat(row[item], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid _pdbx_struct_oper_list.%s of operator %s: %s", item, row["id"], row[item])
+			}
+			op.Translation[i] = value
+		}
+		operators[row["id"]] = op
+	}
+	return operators, nil
+}
+
+// expandOperatorExpression expands an _pdbx_struct_assembly_gen.oper_expression such as "1", "1,2,5",
+// "(1-60)" or "(1-60)(61)" into sequences of operator IDs. Parenthesized groups are combined as a
+// Cartesian product, and the operators of a sequence are applied from right to left.
+func expandOperatorExpression(expression string) ([][]string, error) {
+	expression = strings.ReplaceAll(expression, " ", "")
+	var groups []string
+	if strings.HasPrefix(expression, "(") {
+		for _, part := range strings.Split(strings.TrimPrefix(expression, "("), "(") {
+			if !strings.HasSuffix(part, ")") {
+				return nil, fmt.Errorf("invalid operator expression: %s", expression)
+			}
+			groups = append(groups, strings.TrimSuffix(part, ")"))
+		}
+	} else {
+		groups = []string{expression}
+	}
+
+	sequences := [][]string{nil}
+	for _, group := range groups {
+		var ids []string
+		for _, item := range strings.Split(group, ",") {
+			low, high, isRange := strings.Cut(item, "-")
+			if !isRange {
+				if item == "" {
+					return nil, fmt.Errorf("invalid operator expression: %s", expression)
+				}
+				ids = append(ids, item)
+				continue
+			}
+			first, err1 := strconv.Atoi(low)
+			last, err2 := strconv.Atoi(high)
+			if err1 != nil || err2 != nil || first > last {
+				return nil, fmt.Errorf("invalid operator range %s in %s", item, expression)
+			}
+			for n := first; n <= last; n++ {
+				ids = append(ids, strconv.Itoa(n))
+			}
+		}
+		var expanded [][]string
+		for _, sequence := range sequences {
+			for _, id := range ids {
+				expanded = append(expanded, append(append([]string{}, sequence...), id))
+			}
+		}
+		sequences = expanded
+	}
+	return sequences, nil
+}
+
+// cifHeader returns the HEADER record of a PDB file converted from an mmCIF data block, and the crystal
+// records of its unit cell
+func cifHeader(block *CIFBlock) []string {
+	header := []string{fmt.Sprintf("%-62s%s", "HEADER", strings.ToUpper(block.Name))}
+	if cell := cifCell(block); cell != nil && cell.valid() {
+		header = append(header, cell.crystalHeader()...)
+	}
+	return header
+}
+
+// cifAtomRecord converts a row of _atom_site to an atom record, without its chain ID
+func cifAtomRecord(row map[string]string) (*AtomRecord, error) {
+	atom := &AtomRecord{
+		Het:       strings.EqualFold(row["group_PDB"], "HETATM"),
+		Name:      cifRowValue(row, "auth_atom_id"),
+		AltLoc:    ' ',
+		ResName:   cifRowValue(row, "auth_comp_id"),
+		ICode:     ' ',
+		Occupancy: 1.0,
+		Element:   strings.ToUpper(cifRowValue(row, "type_symbol")),
+		Model:     1,
+	}
+	if model, err := strconv.Atoi(cifRowValue(row, "pdbx_PDB_model_num")); err == nil {
+		atom.Model = model
+	}
+	if atom.Name == "" {
+		atom.Name = cifRowValue(row, "label_atom_id")
+	}
+	if atom.ResName == "" {
+		atom.ResName = cifRowValue(row, "label_comp_id")
+	}
+	if altLoc := cifRowValue(row, "label_alt_id"); altLoc != "" {
+		atom.AltLoc = altLoc[0]
+	}
+	if iCode := cifRowValue(row, "pdbx_PDB_ins_code"); iCode != "" {
+		atom.ICode = iCode[0]
+	}
+	if charge, err := strconv.Atoi(cifRowValue(row, "pdbx_formal_charge")); err == nil && charge != 0 {
+		sign := "+"
+		if charge < 0 {
+			sign, charge = "-", -charge
+		}
+		atom.Charge = strconv.Itoa(charge) + sign
+	}
+
+	var err error
+	seq := cifRowValue(row, "auth_seq_id")
+	if seq == "" {
+		seq = cifRowValue(row, "label_seq_id")
+	}
+	if atom.ResSeq, err = strconv.Atoi(seq); err != nil {
+		return nil, fmt.Errorf("invalid residue number %q of atom %s", seq, row["id"])
+	}
+	atom.Serial, _ = strconv.Atoi(row["id"])
+	for _, field := range []struct {
+		item     string
+		value    *float64
+		optional bool
+	}{{"Cartn_x", &atom.X, false}, {"Cartn_y", &atom.Y, false}, {"Cartn_z", &atom.Z, false}, {"occupancy", &atom.Occupancy, true}, {"B_iso_or_equiv", &atom.TempFactor, true}} {
+		value := cifRowValue(row, field.item)
+		if value == "" && field.optional {
+			continue
+		}
+		if *field.value, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid %s %q of atom %s", field.item, value, row["id"])
+		}
+	}
+	return atom, nil
+}
+
+// biologicalAssembly builds the atoms of an assembly of an mmCIF file by applying the operators of
+// _pdbx_struct_assembly_gen to the listed chains of the first model. The copy of each author chain made
+// by the first operator keeps its chain ID, and the other copies are given unused chain IDs. It also
+// returns a description of each chain of the assembly, e.g. "B: chain A, operator 2".
+func biologicalAssembly(block *CIFBlock, assemblyID string) (*PDBFile, []string, error) {
+	operators, err := parseOperatorList(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ids []string
+	type generator struct {
+		sequences [][]string
+		asymIDs   []string
+	}
+	var generators []generator
+	for _, row := range block.Category("_pdbx_struct_assembly_gen") {
+		if !containsString(ids, row["assembly_id"]) {
+			ids = append(ids, row["assembly_id"])
+		}
+		if row["assembly_id"] != assemblyID {
+			continue
+		}
+		sequences, err := expandOperatorExpression(row["oper_expression"])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, sequence := range sequences {
+			for _, id := range sequence {
+				if _, ok := operators[id]; !ok {
+					return nil, nil, fmt.Errorf("operator %s of assembly %s is not in _pdbx_struct_oper_list", id, assemblyID)
+				}
+			}
+		}
+		generators = append(generators, generator{sequences, strings.Split(strings.ReplaceAll(row["asym_id_list"], " ", ""), ",")})
+	}
+	if len(ids) == 0 {
+		return nil, nil, withCode(ErrCodeNoMatch, fmt.Errorf("no assemblies defined (_pdbx_struct_assembly_gen)"))
+	}
+	if len(generators) == 0 {
+		return nil, nil, withCode(ErrCodeNoMatch, fmt.Errorf("assembly %s not found (available: %s)", assemblyID, strings.Join(ids, ",")))
+	}
+
+	rows := block.Category("_atom_site")
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("no _atom_site records found")
+	}
+	firstModel := cifRowValue(rows[0], "pdbx_PDB_model_num")
+
+	// Each copy of an author chain by an operator sequence becomes a chain of the assembly
+	type copyKey struct {
+		operator string
+		chain    string
+	}
+	var copies []copyKey
+	copyAtoms := make(map[copyKey][]*AtomRecord)
+	for _, gen := range generators {
+		for _, sequence := range gen.sequences {
+			op := operators[sequence[len(sequence)-1]]
+			for i := len(sequence) - 2; i >= 0; i-- {
+				op = op.then(operators[sequence[i]])
+			}
+			name := strings.Join(sequence, "x")
+			for _, row := range rows {
+				if cifRowValue(row, "pdbx_PDB_model_num") != firstModel || !containsString(gen.asymIDs, row["label_asym_id"]) {
+					continue
+				}
+				atom, err := cifAtomRecord(row)
+				if err != nil {
+					return nil, nil, err
+				}
+				atom.SetCoord(op.apply(atom.Coord()))
+				chain := cifRowValue(row, "auth_asym_id")
+				if chain == "" {
+					chain = row["label_asym_id"]
+				}
+				key := copyKey{name, chain}
+				if _, seen := copyAtoms[key]; !seen {
+					copies = append(copies, key)
+				}
+				copyAtoms[key] = append(copyAtoms[key], atom)
+			}
+		}
+	}
+	if len(copies) == 0 {
+		return nil, nil, withCode(ErrCodeNoMatch, fmt.Errorf("assembly %s has no atoms", assemblyID))
+	}
+	if len(copies) > len(chainIDAlphabet) {
+		return nil, nil, fmt.Errorf("assembly %s has %d chains, more than fit in a PDB file (%d)", assemblyID, len(copies), len(chainIDAlphabet))
+	}
+
+	// The first copy of each chain keeps its ID when it is a single character
+	used := make(map[byte]bool)
+	chainIDs := make(map[copyKey]byte)
+	for _, key := range copies {
+		if len(key.chain) == 1 && strings.IndexByte(chainIDAlphabet, key.chain[0]) >= 0 && !used[key.chain[0]] {
+			chainIDs[key] = key.chain[0]
+			used[key.chain[0]] = true
+		}
+	}
+	file := &PDBFile{Header: cifHeader(block)}
+	var chains []string
+	next := 0
+	for _, key := range copies {
+		chainID, ok := chainIDs[key]
+		if !ok {
+			for used[chainIDAlphabet[next]] {
+				next++
+			}
+			chainID = chainIDAlphabet[next]
+			used[chainID] = true
+		}
+		for _, atom := range copyAtoms[key] {
+			atom.ChainID = chainID
+			file.Atoms = append(file.Atoms, atom)
+		}
+		chains = append(chains, fmt.Sprintf("%c: chain %s, operator %s", chainID, key.chain, key.operator))
+	}
+	return file, chains, nil
+}
+
+// readAssembly reads an mmCIF input and builds one of its biological assemblies, reporting the origin
+// of each chain on stderr
+func readAssembly(cmd *cobra.Command, inputFile, assemblyID string) (*PDBFile, error) {
+	block, err := readCIFInput(cmd.Context(), inputFile, "--assembly")
+	if err != nil {
+		return nil, err
+	}
+	file, chains, err := biologicalAssembly(block, assemblyID)
+	if err != nil {
+		if errorCode(err) == ErrCodeGeneric {
+			err = withCode(ErrCodeParse, err)
+		}
+		return nil, err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Assembly %s: %d chains\n", assemblyID, len(chains))
+	for _, chain := range chains {
+		fmt.Fprintf(cmd.ErrOrStderr(), "  %s\n", chain)
+	}
+	return file, nil
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// writeFileAtomic writes to a temporary file next to filename and renames it into place only
+// once write has succeeded, so an interrupted or failed run never leaves a truncated output file.
+// Object store URIs are written by buffering the output and uploading it in a single request.
+func writeFileAtomic(ctx context.Context, filename string, write func(io.Writer) error) error {
+	if isObjectURI(filename) {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			return err
+		}
+		defer beginPhase(phaseWrite)()
+		return writeObject(ctx, filename, buf.Bytes())
+	}
+
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+
+	tmpfile, err := createTempOutput(dir, base)
+	if err != nil {
+		return withCode(ErrCodeIO, fmt.Errorf("failed to create output file: %v", err))
+	}
+	tmpName := tmpfile.Name()
+
+	if err := write(timedOutput(tmpfile)); err != nil {
+		tmpfile.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	defer beginPhase(phaseWrite)()
+	// Flush the output to disk before it replaces the old file, so a crash cannot leave an empty file
+	if err := tmpfile.Sync(); err != nil {
+		tmpfile.Close()
+		os.Remove(tmpName)
+		return withCode(ErrCodeIO, fmt.Errorf("failed to write output file: %v", err))
+	}
+	if err := tmpfile.Close(); err != nil {
+		os.Remove(tmpName)
+		return withCode(ErrCodeIO, fmt.Errorf("failed to write output file: %v", err))
+	}
+	// A file that is replaced keeps its permissions
+	if existing, err := os.Stat(filename); err == nil {
+		if err := os.Chmod(tmpName, existing.Mode().Perm()); err != nil {
+			os.Remove(tmpName)
+			return withCode(ErrCodeIO, fmt.Errorf("failed to set output file permissions: %v", err))
+		}
+	}
+	// The output of a cancelled run may be incomplete
+	if ctx.Err() != nil {
+		os.Remove(tmpName)
+		return contextError(ctx.Err())
+	}
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return withCode(ErrCodeIO, fmt.Errorf("failed to move output file into place: %v", err))
+	}
+	return nil
+}
+
+// createTempOutput creates a new temporary file in dir for the output file base. Unlike os.CreateTemp,
+// which uses mode 0600, it is created with mode 0666 less the umask, as os.Create would create the output.
+func createTempOutput(dir, base string) (*os.File, error) {
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, "."+base+".tmp"+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if os.IsExist(err) && i < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
@@ -0,0 +1,258 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	averageOutput string
+	averageFit    string
+	averageRMSF   bool
+	averageBatch  batchOptions
+)
+
+// backboneAtoms are the atoms used by --fit backbone, for proteins and nucleic acids
+var backboneAtoms = map[string]bool{
+	"N": true, "CA": true, "C": true, "O": true,
+	"P": true, "O5'": true, "C5'": true, "C4'": true, "C3'": true, "O3'": true,
+}
+
+var averageCmd = &cobra.Command{
+	Use:   "average [flags] [input_file...]",
+	Short: "Compute the coordinate-averaged model of an ensemble",
+	Long: `Compute the coordinate-averaged model of a multi-model file (such as an NMR ensemble) and write it
+as a single-model PDB file.
+
+The models are superposed before averaging: each model is fitted onto the first model, then
+iteratively onto the current average until it no longer changes. The atoms used for fitting are
+selected with --fit: ca (CA atoms, the default), backbone (N, CA, C, O and nucleic acid backbone
+atoms) or all. Atoms are matched between models by chain, residue, atom name and alternate location;
+atoms that are not present in every model are left out.
+With --rmsf-bfactor, the root-mean-square fluctuation of each atom about the average (Å) is
+written to the B-factor column.
+Note that averaged coordinates can have distorted geometry where the models differ.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Average an NMR ensemble
+  pdbtk average 2k39.pdb --output 2k39_average.pdb
+
+  # Average over the backbone fit and store the RMSF in the B-factors
+  pdbtk average --fit backbone --rmsf-bfactor 2k39.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runAverage,
+}
+
+func init() {
+	averageCmd.Flags().StringVarP(&averageOutput, "output", "o", "", "Output file (default: stdout)")
+	averageCmd.Flags().StringVar(&averageFit, "fit", "ca", "Atoms used to superpose the models: ca, backbone or all")
+	averageCmd.Flags().BoolVar(&averageRMSF, "rmsf-bfactor", false, "Write the RMSF of each atom to the B-factor column")
+	addBatchFlags(averageCmd, &averageBatch, "{name}")
+}
+
+func runAverage(cmd *cobra.Command, args []string) error {
+	switch averageFit {
+	case "ca", "backbone", "all":
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --fit: %s (must be ca, backbone or all)", averageFit))
+	}
+
+	return runBatch(cmd.Context(), args, averageOutput, averageBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		ensemble, err := newEnsemble(file, averageFit)
+		if err != nil {
+			return err
+		}
+		if dropped := len(ensemble.atomsOf(0)) - len(ensemble.keys); dropped > 0 {
+			if err := warn("%d atoms are not present in every model and were left out", dropped); err != nil {
+				return err
+			}
+		}
+
+		mean := ensemble.superpose()
+		rmsf := ensemble.rmsf(mean)
+
+		atoms := make([]*AtomRecord, len(ensemble.keys))
+		for k, template := range ensemble.template {
+			atom := template.Copy()
+			atom.SetCoord(mean[k])
+			if averageRMSF {
+				atom.TempFactor = rmsf[k]
+			}
+			atoms[k] = atom
+		}
+
+		meanRMSD := 0.0
+		for m := range ensemble.coords {
+			meanRMSD += rmsd(ensemble.coords[m], mean)
+		}
+		meanRMSD /= float64(len(ensemble.coords))
+		fmt.Fprintf(cmd.ErrOrStderr(), "Averaged %d models over %d atoms (%d fitted); mean RMSD to the average %.3f Å\n",
+			len(ensemble.models), len(atoms), len(ensemble.fit), meanRMSD)
+		return writePDBRecords(file.WithAtoms(atoms), writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// ensembleAtomKey identifies an atom across the models of an ensemble
+type ensembleAtomKey struct {
+	ChainID byte
+	ResSeq  int
+	ICode   byte
+	ResName string
+	Name    string
+	AltLoc  byte
+}
+
+func ensembleKey(atom *AtomRecord) ensembleAtomKey {
+	return ensembleAtomKey{atom.ChainID, atom.ResSeq, atom.ICode, atom.ResName, atom.Name, atom.AltLoc}
+}
+
+// ensemble holds the coordinates of the atoms present in every model of a multi-model file
+type ensemble struct {
+	file     *PDBFile
+	models   []int
+	keys     []ensembleAtomKey
+	template []*AtomRecord // the atoms of the first model
+	coords   [][]vec3      // coords[model][atom]
+	fit      []int         // indices of the atoms used for superposition
+}
+
+// newEnsemble collects the atoms present in every model of file. fit selects the atoms used for
+// superposition: ca, backbone or all.
+func newEnsemble(file *PDBFile, fit string) (*ensemble, error) {
+	models := file.Models()
+	if len(models) < 2 {
+		return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("the file has %d model(s); an ensemble needs at least two", len(models)))
+	}
+	e := &ensemble{file: file, models: models}
+
+	modelIndex := make(map[int]int)
+	for i, model := range models {
+		modelIndex[model] = i
+	}
+	positions := make([]map[ensembleAtomKey]vec3, len(models))
+	for i := range positions {
+		positions[i] = make(map[ensembleAtomKey]vec3)
+	}
+	for _, atom := range file.Atoms {
+		key := ensembleKey(atom)
+		if _, seen := positions[modelIndex[atom.Model]][key]; !seen {
+			positions[modelIndex[atom.Model]][key] = atom.Coord()
+		}
+	}
+
+	seen := make(map[ensembleAtomKey]bool)
+	for _, atom := range e.atomsOf(0) {
+		key := ensembleKey(atom)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		inAll := true
+		for _, p := range positions[1:] {
+			if _, ok := p[key]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			e.keys = append(e.keys, key)
+			e.template = append(e.template, atom)
+		}
+	}
+	if len(e.keys) == 0 {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("no atoms are present in every model"))
+	}
+
+	e.coords = make([][]vec3, len(models))
+	for m := range models {
+		e.coords[m] = make([]vec3, len(e.keys))
+		for k, key := range e.keys {
+			e.coords[m][k] = positions[m][key]
+		}
+	}
+
+	for k, key := range e.keys {
+		switch {
+		case fit == "all",
+			fit == "ca" && key.Name == "CA" && !e.template[k].Het,
+			fit == "backbone" && backboneAtoms[key.Name] && !e.template[k].Het:
+			e.fit = append(e.fit, k)
+		}
+	}
+	if len(e.fit) < 3 {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("too few atoms to superpose the models with --fit %s (%d)", fit, len(e.fit)))
+	}
+	return e, nil
+}
+
+// atomsOf returns the atoms of the i-th model
+func (e *ensemble) atomsOf(i int) []*AtomRecord {
+	var atoms []*AtomRecord
+	for _, atom := range e.file.Atoms {
+		if atom.Model == e.models[i] {
+			atoms = append(atoms, atom)
+		}
+	}
+	return atoms
+}
+
+// fitCoords returns the coordinates of the fitted atoms in coords
+func (e *ensemble) fitCoords(coords []vec3) []vec3 {
+	points := make([]vec3, len(e.fit))
+	for i, k := range e.fit {
+		points[i] = coords[k]
+	}
+	return points
+}
+
+// superpose fits every model onto the first one and then iteratively onto the average, transforming the
+// coordinates of the ensemble in place, and returns the average coordinates
+func (e *ensemble) superpose() []vec3 {
+	reference := e.fitCoords(e.coords[0])
+	var mean []vec3
+	for iteration := 0; iteration < 20; iteration++ {
+		for m := range e.coords {
+			transform := superposition(e.fitCoords(e.coords[m]), reference)
+			for k := range e.coords[m] {
+				e.coords[m][k] = transform.apply(e.coords[m][k])
+			}
+		}
+
+		previous := mean
+		mean = make([]vec3, len(e.keys))
+		for k := range mean {
+			var sum vec3
+			for m := range e.coords {
+				sum = sum.add(e.coords[m][k])
+			}
+			mean[k] = sum.scale(1 / float64(len(e.coords)))
+		}
+		if previous != nil && rmsd(previous, mean) < 1e-4 {
+			break
+		}
+		reference = e.fitCoords(mean)
+	}
+	return mean
+}
+
+// rmsf returns the root-mean-square fluctuation of each atom about mean
+func (e *ensemble) rmsf(mean []vec3) []float64 {
+	values := make([]float64, len(mean))
+	for k := range mean {
+		sum := 0.0
+		for m := range e.coords {
+			d := e.coords[m][k].sub(mean[k])
+			sum += d.dot(d)
+		}
+		values[k] = math.Sqrt(sum / float64(len(e.coords)))
+	}
+	return values
+}
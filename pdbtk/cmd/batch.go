@@ -0,0 +1,454 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// batchOptions holds the flags shared by commands that can process several input files in one run
+type batchOptions struct {
+	outdir       string
+	nameTemplate string
+	// combine writes the results of several inputs to a single output when --outdir is not given
+	combine bool
+	// mmCIF accepts mmCIF inputs as well as PDB files
+	mmCIF bool
+	// recursive processes the PDB files under directory inputs, mirroring their directories in --outdir
+	recursive bool
+	// resume skips the inputs whose outputs are up to date (for objects, recorded as successful in the manifest)
+	resume bool
+}
+
+// processFunc processes a single input and writes the result to writer.
+// inputFile is empty when the input is read from stdin.
+type processFunc func(inputFile string, writer io.Writer) error
+
+// addBatchFlags registers --outdir and --name-template on a command
+func addBatchFlags(cmd *cobra.Command, opts *batchOptions, defaultTemplate string) {
+	cmd.Flags().StringVar(&opts.outdir, "outdir", "", "Output directory for batch mode (one output file per input)")
+	cmd.Flags().StringVar(&opts.nameTemplate, "name-template", defaultTemplate, "Output filename template used with --outdir ({name}, {stem}, {ext})")
+	cmd.Flags().BoolVarP(&opts.recursive, "recursive", "r", false, "Process the files under directory inputs, mirroring their subdirectories in --outdir")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "With --outdir, skip inputs whose outputs are newer than them (for objects, recorded as successful in the --manifest)")
+}
+
+// expandInputs expands any glob patterns in args and checks that every input file exists
+func expandInputs(ctx context.Context, args []string) ([]string, error) {
+	var inputs []string
+	for _, arg := range args {
+		if strings.ContainsAny(arg, "*?[") && !isObjectURI(arg) {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid glob pattern %s: %v", arg, err))
+			}
+			if len(matches) == 0 {
+				return nil, withCode(ErrCodeInputNotFound, fmt.Errorf("no files match: %s", arg))
+			}
+			for _, match := range matches {
+				if inputs, err = appendInput(ctx, inputs, match); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := CheckFileExistsContext(ctx, arg); err != nil {
+			return nil, withCode(ErrCodeInputNotFound, err)
+		}
+		var err error
+		if inputs, err = appendInput(ctx, inputs, arg); err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+// appendInput appends an input file to inputs, or every PDB file in it for an archive
+func appendInput(ctx context.Context, inputs []string, inputFile string) ([]string, error) {
+	if !isArchive(inputFile) {
+		return append(inputs, inputFile), nil
+	}
+	members, err := listArchivePDBFiles(ctx, inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, withCode(ErrCodeInputNotFound, fmt.Errorf("no PDB files found in archive %s", inputFile))
+	}
+	return append(inputs, members...), nil
+}
+
+// expandBatchInputs expands the inputs of a batch run like expandInputs. With --recursive, directories
+// are expanded to the files under them, and the directory of each file relative to its input directory
+// (in slash form) is returned in subdirs, as is the directory of each archive member in its archive.
+func expandBatchInputs(ctx context.Context, args []string, opts batchOptions) ([]string, map[string]string, error) {
+	var inputs []string
+	subdirs := make(map[string]string)
+	for _, arg := range args {
+		if !opts.recursive || !isLocalDir(arg) {
+			expanded, err := expandInputs(ctx, []string{arg})
+			if err != nil {
+				return nil, nil, err
+			}
+			// Archive members in different directories often share names (T1104/model1.pdb, T1106/model1.pdb)
+			for _, input := range expanded {
+				if dir := archiveMemberDir(input); dir != "" {
+					subdirs[input] = dir
+				}
+			}
+			inputs = append(inputs, expanded...)
+			continue
+		}
+		files, err := listInputDir(arg, true, opts.mmCIF)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(files) == 0 {
+			return nil, nil, withCode(ErrCodeInputNotFound, fmt.Errorf("no PDB files found under %s", arg))
+		}
+		for _, file := range files {
+			rel, err := filepath.Rel(arg, filepath.Dir(file))
+			if err != nil {
+				return nil, nil, withCode(ErrCodeIO, err)
+			}
+			if rel != "." {
+				subdirs[file] = filepath.ToSlash(rel)
+			}
+			inputs = append(inputs, file)
+		}
+	}
+	return inputs, subdirs, nil
+}
+
+// isLocalDir reports whether name is a local directory
+func isLocalDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
+
+// listInputDir returns the PDB files (and mmCIF files with mmCIF) in dir, in lexical order, and with
+// recursive those in its subdirectories as well
+func listInputDir(dir string, recursive, mmCIF bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if name != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if checkPDBExtension(name) == nil || (mmCIF && isCIFFilename(name)) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, withCode(ErrCodeIO, fmt.Errorf("failed to read directory %s: %v", dir, err))
+	}
+	return files, nil
+}
+
+// checkPDBExtension returns an error if the input file does not have a .pdb extension and is not a PDB bundle
+func checkPDBExtension(inputFile string) error {
+	inputExt := strings.ToLower(filepath.Ext(inputFile))
+	if inputExt != ".pdb" && !isPDBBundle(inputFile) {
+		return withCode(ErrCodeUnsupportedFormat, fmt.Errorf("only PDB files are supported, got: %s", inputExt))
+	}
+	return nil
+}
+
+// checkStdinAvailable returns an error if stdin is a terminal rather than a pipe or file
+func checkStdinAvailable() error {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return withCode(ErrCodeIO, fmt.Errorf("failed to check stdin: %v", err))
+	}
+	if (stat.Mode() & os.ModeCharDevice) != 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("no input file specified and stdin is not available"))
+	}
+	return nil
+}
+
+// renderNameTemplate fills in the placeholders of an output filename template for the given input file.
+// {name} is the input base name (of the member for an archive member), {stem} the base name without extension and {ext} the extension (including the dot).
+// A PDB bundle archive (1vy4-pdb-bundle.tar.gz) is named as the PDB file it is read as (1vy4-pdb-bundle.pdb).
+func renderNameTemplate(template, inputFile string) string {
+	name := inputBaseName(inputFile)
+	if isPDBBundle(name) {
+		name = name[:strings.LastIndex(strings.ToLower(name), ".tar")] + ".pdb"
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	replacer := strings.NewReplacer(
+		"{name}", name,
+		"{stem}", stem,
+		"{ext}", ext,
+	)
+	return replacer.Replace(template)
+}
+
+// writeOutput calls write with the writer for outputFile, which is stdout when outputFile is empty or "-"
+func writeOutput(ctx context.Context, outputFile string, write func(io.Writer) error) error {
+	if outputFile == "" || outputFile == "-" {
+		return write(timedOutput(os.Stdout))
+	}
+	return writeFileAtomic(ctx, outputFile, write)
+}
+
+// runBatch resolves the inputs named in args and runs process for each of them.
+// With no args the input is read from stdin. Without --outdir a single input is written to
+// output (or stdout); with --outdir every input gets its own output file named by the template.
+// No further inputs are processed once ctx is cancelled.
+func runBatch(ctx context.Context, args []string, output string, opts batchOptions, process processFunc) error {
+	if opts.resume && opts.outdir == "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--resume requires --outdir"))
+	}
+	if len(args) == 0 {
+		if opts.outdir != "" {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("--outdir requires at least one input file"))
+		}
+		if err := checkStdinAvailable(); err != nil {
+			return err
+		}
+		beginInput()
+		var stats *outputStats
+		err := writeOutput(ctx, output, func(w io.Writer) error {
+			stats = newOutputStats(w)
+			return process("", stats)
+		})
+		recordOutputResult("", output, stats, err)
+		return err
+	}
+
+	inputs, subdirs, err := expandBatchInputs(ctx, args, opts)
+	if err != nil {
+		return err
+	}
+	for _, inputFile := range inputs {
+		if opts.mmCIF && isCIFFilename(inputFile) {
+			continue
+		}
+		if isLocalDir(inputFile) {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("%s is a directory (use --recursive)", inputFile))
+		}
+		if err := checkPDBExtension(inputFile); err != nil {
+			return withCode(errorCode(err), fmt.Errorf("%s: %v", inputFile, err))
+		}
+	}
+
+	if opts.outdir == "" {
+		if len(inputs) > 1 && opts.combine {
+			return runCombined(ctx, inputs, output, process)
+		}
+		if len(inputs) > 1 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("multiple input files require --outdir"))
+		}
+		beginInput()
+		var stats *outputStats
+		err := writeOutput(ctx, output, func(w io.Writer) error {
+			stats = newOutputStats(w)
+			return process(inputs[0], stats)
+		})
+		recordOutputResult(inputs[0], output, stats, err)
+		return err
+	}
+
+	if output != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--output cannot be combined with --outdir"))
+	}
+	if err := makeOutputDir(opts.outdir); err != nil {
+		return err
+	}
+
+	outputFiles, err := batchOutputFiles(inputs, subdirs, opts)
+	if err != nil {
+		return err
+	}
+
+	var failures batchFailures
+	skipped := 0
+	progress := newProgress("Processing", int64(len(inputs)), false)
+	for i, inputFile := range inputs {
+		// Inputs already written are kept when the run is cancelled, so --resume can pick up from here
+		if ctx.Err() != nil {
+			progress.Finish()
+			return contextError(ctx.Err())
+		}
+		outputFile := outputFiles[i]
+		if opts.resume && outputUpToDate(inputFile, outputFile) {
+			recordSkipped(inputFile, outputFile)
+			skipped++
+			progress.Add(1)
+			continue
+		}
+		if subdirs[inputFile] != "" && !isObjectURI(outputFile) {
+			if err := makeOutputDir(filepath.Dir(outputFile)); err != nil {
+				return err
+			}
+		}
+		beginInput()
+		var stats *outputStats
+		err := writeOutput(ctx, outputFile, func(w io.Writer) error {
+			stats = newOutputStats(w)
+			return process(inputFile, stats)
+		})
+		recordOutputResult(inputFile, outputFile, stats, err)
+		failures.add(inputFile, err)
+		progress.Add(1)
+	}
+	progress.Finish()
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Skipped %d of %d inputs that were already processed\n", skipped, len(inputs))
+	}
+
+	return failures.err(len(inputs))
+}
+
+// batchOutputFiles returns the output file of each input of a batch run, failing if two inputs would
+// be written to the same file
+func batchOutputFiles(inputs []string, subdirs map[string]string, opts batchOptions) ([]string, error) {
+	outputFiles := make([]string, len(inputs))
+	writtenBy := make(map[string]string)
+	for i, inputFile := range inputs {
+		outputFile := joinOutputPath(opts.outdir, path.Join(subdirs[inputFile], renderNameTemplate(opts.nameTemplate, inputFile)))
+		if other, ok := writtenBy[outputFile]; ok {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("%s and %s would both be written to %s; use a --name-template that tells them apart",
+				other, inputFile, outputFile))
+		}
+		writtenBy[outputFile] = inputFile
+		outputFiles[i] = outputFile
+	}
+	return outputFiles, nil
+}
+
+// outputUpToDate reports whether the output of an input can be kept by --resume: it exists and is no
+// older than the input. Objects have no local modification times, so for them the output must be
+// recorded as written by a successful run in the manifest instead.
+func outputUpToDate(inputFile, outputFile string) bool {
+	written, ok := manifestDone[inputFile]
+	recorded := ok && written == outputFile
+	if archive, _, ok := splitArchiveMember(inputFile); ok {
+		inputFile = archive
+	}
+	if isObjectURI(inputFile) || isObjectURI(outputFile) {
+		return recorded
+	}
+	input, err := os.Stat(inputFile)
+	if err != nil {
+		return false
+	}
+	output, err := os.Stat(outputFile)
+	return err == nil && !output.ModTime().Before(input.ModTime())
+}
+
+// runCombined processes several inputs into a single output. Each input is processed into a buffer
+// first, so an input that fails contributes nothing and the remaining inputs are still written.
+func runCombined(ctx context.Context, inputs []string, output string, process processFunc) error {
+	var failures batchFailures
+	progress := newProgress("Processing", int64(len(inputs)), false)
+	err := writeOutput(ctx, output, func(w io.Writer) error {
+		for _, inputFile := range inputs {
+			if ctx.Err() != nil {
+				return contextError(ctx.Err())
+			}
+			var buf bytes.Buffer
+			beginInput()
+			stats := newOutputStats(&buf)
+			err := process(inputFile, stats)
+			recordOutputResult(inputFile, output, stats, err)
+			failures.add(inputFile, err)
+			if err == nil {
+				if _, err := w.Write(buf.Bytes()); err != nil {
+					return withCode(ErrCodeIO, err)
+				}
+			}
+			progress.Add(1)
+		}
+		return nil
+	})
+	progress.Finish()
+	if err != nil {
+		return err
+	}
+	return failures.err(len(inputs))
+}
+
+// batchFailures counts the inputs of a batch run that failed
+type batchFailures struct {
+	failed int
+	code   string
+	// noun names what failed in the error for the run, "inputs" if empty
+	noun string
+}
+
+// add prints and counts err, if it is not nil
+func (f *batchFailures) add(inputFile string, err error) {
+	if err == nil {
+		return
+	}
+	printError(inputFile, err)
+	if f.failed == 0 {
+		f.code = errorCode(err)
+	} else if f.code != errorCode(err) {
+		f.code = ErrCodeGeneric
+	}
+	f.failed++
+}
+
+// err returns the error for the whole run, or nil if no input failed
+func (f *batchFailures) err(total int) error {
+	if f.failed == 0 {
+		return nil
+	}
+	noun := f.noun
+	if noun == "" {
+		noun = "inputs"
+	}
+	// Exit with the specific failure code when every failed input failed the same way
+	return withCode(f.code, fmt.Errorf("%d of %d %s failed", f.failed, total, noun))
+}
+
+// recordCommandLine builds the command line recorded in the REMARK 1 block of an output file: the
+// command, the flags that were set, the given positional arguments and inputFile (omitted for stdin)
+func recordCommandLine(cmd *cobra.Command, positional []string, inputFile string) string {
+	parts := []string{"pdbtk", cmd.Name()}
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		switch flag.Name {
+		case "outdir", "name-template":
+			return
+		}
+		if flag.Value.Type() == "bool" {
+			if flag.Value.String() == "false" {
+				parts = append(parts, "--"+flag.Name+"=false")
+			} else {
+				parts = append(parts, "--"+flag.Name)
+			}
+			return
+		}
+		// List flags are repeated for each value, as they may have been given
+		if values, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range values.GetSlice() {
+				parts = append(parts, "--"+flag.Name, value)
+			}
+			return
+		}
+		parts = append(parts, "--"+flag.Name, flag.Value.String())
+	})
+	parts = append(parts, positional...)
+	if inputFile != "" {
+		parts = append(parts, inputFile)
+	}
+	return strings.Join(parts, " ")
+}
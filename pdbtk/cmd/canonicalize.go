@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	canonicalOutput   string
+	canonicalChecksum bool
+	canonicalBatch    batchOptions
+)
+
+var canonicalizeCmd = &cobra.Command{
+	Use:   "canonicalize [flags] [input_file...]",
+	Short: "Write a canonical form of a PDB file or its checksum",
+	Long: `Write a normalized canonical form of a PDB file, or with --checksum a SHA-256 hash of it, so
+structurally identical files can be deduplicated and changes detected across pipeline runs.
+
+The canonical form keeps only the coordinate records, CONECT records and CRYST1: HEADER, REMARK and
+all other header records are removed. Atoms are sorted by model, chain, residue number, insertion
+code and atom name (standard residues in their standard atom order), element symbols are filled in
+and upper-cased, atom serials are renumbered and every record is written with fixed formatting.
+Files that differ only in atom order, whitespace, serial numbers or header records therefore have
+the same canonical form and checksum.
+
+With --checksum, one line per input is written in the format of sha256sum: the hash and the file name.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Write the canonical form of a PDB file
+  pdbtk canonicalize 1a02.pdb --output 1a02_canonical.pdb
+
+  # Print checksums of all structures, e.g. to find duplicates
+  pdbtk canonicalize --checksum structures/*.pdb | sort | uniq -w 64 -D`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runCanonicalize,
+}
+
+func init() {
+	canonicalizeCmd.Flags().StringVarP(&canonicalOutput, "output", "o", "", "Output file (default: stdout)")
+	canonicalizeCmd.Flags().BoolVar(&canonicalChecksum, "checksum", false, "Write the SHA-256 checksum of the canonical form instead of the canonical form")
+	addBatchFlags(canonicalizeCmd, &canonicalBatch, "{name}")
+}
+
+func runCanonicalize(cmd *cobra.Command, args []string) error {
+	if !canonicalChecksum {
+		return runBatch(cmd.Context(), args, canonicalOutput, canonicalBatch, func(inputFile string, writer io.Writer) error {
+			return writeCanonical(cmd.Context(), inputFile, writer)
+		})
+	}
+
+	if canonicalBatch.outdir != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--checksum cannot be combined with --outdir"))
+	}
+	// Checksums of several inputs are written to a single output, one line each
+	opts := canonicalBatch
+	opts.combine = true
+	return runBatch(cmd.Context(), args, canonicalOutput, opts, func(inputFile string, writer io.Writer) error {
+		var buf bytes.Buffer
+		if err := writeCanonical(cmd.Context(), inputFile, &buf); err != nil {
+			return err
+		}
+		name := inputFile
+		if name == "" {
+			name = "-"
+		}
+		sum := sha256.Sum256(buf.Bytes())
+		_, err := fmt.Fprintf(writer, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+		return err
+	})
+}
+
+// writeCanonical writes the canonical form of a single input to writer
+func writeCanonical(ctx context.Context, inputFile string, writer io.Writer) error {
+	content, err := readInputContent(ctx, inputFile)
+	if err != nil {
+		return err
+	}
+	file, err := ParsePDBRecordsContext(ctx, bytes.NewReader(normalizePDBLines(content)))
+	if err != nil {
+		return parseError(ctx, err)
+	}
+	if len(file.Atoms) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no coordinate records found"))
+	}
+
+	canonical := canonicalizeRecords(file)
+	out, finish := formatCheckedWriter(writer)
+	for _, line := range canonical.Header {
+		fmt.Fprintf(out, "%-*s\n", pdbLineWidth, line)
+	}
+	var buf bytes.Buffer
+	writeCoordinateRecords(&buf, canonical)
+	if err := writePaddedLines(buf.Bytes(), out); err != nil {
+		return err
+	}
+	return finish()
+}
+
+// canonicalizeRecords returns a copy of file in canonical form: CRYST1 as the only header record,
+// atoms in canonical order and element symbols filled in
+func canonicalizeRecords(file *PDBFile) *PDBFile {
+	var header []string
+	for _, line := range file.Header {
+		if strings.HasPrefix(line, "CRYST1") {
+			header = append(header, strings.TrimRight(line, " "))
+		}
+	}
+
+	atoms := make([]*AtomRecord, len(file.Atoms))
+	for i, atom := range file.Atoms {
+		atoms[i] = atom.Copy()
+	}
+	inferred := inferElements(atoms)
+	for i, atom := range atoms {
+		if atom.Element == "" || !isElementSymbol(atom.Element) {
+			atom.Element = inferred[i]
+		}
+		atom.Element = strings.ToUpper(atom.Element)
+	}
+
+	// Ordering by name first makes the order of non-standard atoms independent of the input order
+	sort.SliceStable(atoms, func(i, j int) bool {
+		if atoms[i].Name != atoms[j].Name {
+			return atoms[i].Name < atoms[j].Name
+		}
+		return atoms[i].AltLoc < atoms[j].AltLoc
+	})
+	sortAtoms(atoms, false)
+
+	return &PDBFile{Header: header, Atoms: atoms, Conect: file.Conect}
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	capOutput string
+	capChains string
+	capBreaks bool
+	capNaming string
+	capBatch  batchOptions
+)
+
+// capPhi, capProPhi and capPsi are the backbone torsions (in degrees) given to the capped residues when they set
+// the position of a cap: those of an extended strand, or of proline for phi
+const (
+	capPhi    = -120.0
+	capProPhi = -65.0
+	capPsi    = 120.0
+)
+
+var capTerminiCmd = &cobra.Command{
+	Use:   "cap-termini [flags] [input_file...]",
+	Short: "Cap protein chain termini with ACE and NME groups",
+	Long: `Add an acetyl (ACE) group before the first amino acid and an N-methylamide (NME) group after the last
+amino acid of each protein chain, the neutral caps used to prepare fragments for molecular dynamics.
+
+The caps are built from ideal geometry with trans peptide bonds: the NME nitrogen is placed anti to the
+O of the last residue, and the ACE carbonyl at phi -120° (-65° for proline) of the first residue. ACE
+has the atoms C, O and CH3; NME has N and C, or N and CH3 with --naming amber. The OXT of the last
+residue and the H1/H2/H3 hydrogens of the first residue are removed (run add-hydrogens to add the
+amide hydrogens). ACE is numbered one before the first residue and NME one after the last, with an
+insertion code when that number is taken. Caps are written as ATOM records so that they stay inside
+the chain's TER record. Termini that are already capped are left unchanged.
+
+--breaks also caps both sides of each chain break (a C-N distance longer than 2.0 Å between consecutive
+amino acids). --chains restricts capping to chains.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Cap the termini of every protein chain
+  pdbtk cap-termini 1a02.pdb --output 1a02_capped.pdb
+
+  # Also cap chain breaks, with Amber atom names
+  pdbtk cap-termini --breaks --naming amber 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runCapTermini,
+}
+
+func init() {
+	capTerminiCmd.Flags().StringVarP(&capOutput, "output", "o", "", "Output file (default: stdout)")
+	capTerminiCmd.Flags().StringVarP(&capChains, "chains", "c", "", "Comma-separated list of chain IDs to cap (default: all chains)")
+	capTerminiCmd.Flags().BoolVar(&capBreaks, "breaks", false, "Also cap both sides of chain breaks")
+	capTerminiCmd.Flags().StringVar(&capNaming, "naming", "pdb", "Atom names of the caps: pdb (wwPDB, NME carbon C) or amber (NME carbon CH3)")
+	addBatchFlags(capTerminiCmd, &capBatch, "{name}")
+}
+
+func runCapTermini(cmd *cobra.Command, args []string) error {
+	methyl := "C"
+	switch capNaming {
+	case "pdb":
+	case "amber":
+		methyl = "CH3"
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --naming: %s (must be pdb or amber)", capNaming))
+	}
+	var chains []string
+	if capChains != "" {
+		chains = splitChainList(capChains)
+	}
+
+	return runBatch(cmd.Context(), args, capOutput, capBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		residues := groupResidues(file.Atoms)
+		taken := make(map[ResidueKey]bool)
+		for _, residue := range residues {
+			key := residue[0].Residue()
+			key.ResName = ""
+			taken[key] = true
+		}
+
+		// The amino acids of each chain, by index in residues, and the caps to add before and after them
+		chainResidues := make(map[[2]int][]int)
+		var order [][2]int
+		for i, residue := range residues {
+			key := residue[0].Residue()
+			if !cappable(residue) || (chains != nil && !containsString(chains, string(key.ChainID))) {
+				continue
+			}
+			chain := [2]int{key.Model, int(key.ChainID)}
+			if _, ok := chainResidues[chain]; !ok {
+				order = append(order, chain)
+			}
+			chainResidues[chain] = append(chainResidues[chain], i)
+		}
+		ace, nme := make(map[int]bool), make(map[int]bool)
+		for _, chain := range order {
+			indices := chainResidues[chain]
+			first, last := indices[0], indices[len(indices)-1]
+			if first == 0 || !isCap(residues[first-1]) {
+				ace[first] = true
+			}
+			if last == len(residues)-1 || !isCap(residues[last+1]) {
+				nme[last] = true
+			}
+			if !capBreaks {
+				continue
+			}
+			for k := 1; k < len(indices); k++ {
+				before, after := residues[indices[k-1]], residues[indices[k]]
+				if distance(atomPosition(before, "C"), atomPosition(after, "N")) > peptideBondCutoff {
+					nme[indices[k-1]], ace[indices[k]] = true, true
+				}
+			}
+		}
+
+		firstModel := file.Models()[0]
+		aceCount, nmeCount := 0, 0
+		var atoms []*AtomRecord
+		for i, residue := range residues {
+			key := residue[0].Residue()
+			if ace[i] {
+				atoms = append(atoms, aceCap(residue, taken)...)
+				if key.Model == firstModel {
+					aceCount++
+				}
+			}
+			for _, atom := range residue {
+				if (ace[i] && (atom.Name == "H1" || atom.Name == "H2" || atom.Name == "H3")) || (nme[i] && atom.Name == "OXT") {
+					continue
+				}
+				atoms = append(atoms, atom)
+			}
+			if nme[i] {
+				atoms = append(atoms, nmeCap(residue, methyl, taken)...)
+				if key.Model == firstModel {
+					nmeCount++
+				}
+			}
+		}
+		if aceCount+nmeCount == 0 {
+			if err := warn("no protein termini to cap"); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Added %d ACE and %d NME caps\n", aceCount, nmeCount)
+
+		return writePDBRecords(file.WithAtoms(atoms), writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// cappable reports whether a residue is an amino acid with the backbone atoms needed to place caps
+func cappable(residue []*AtomRecord) bool {
+	if isCap(residue) || !isAminoAcid(residue) {
+		return false
+	}
+	names := make(map[string]bool)
+	for _, atom := range residue {
+		names[atom.Name] = true
+	}
+	return names["N"] && names["CA"] && names["C"]
+}
+
+// isCap reports whether a residue is a terminal capping group
+func isCap(residue []*AtomRecord) bool {
+	switch residue[0].ResName {
+	case "ACE", "NME", "NMA", "NH2", "FOR":
+		return true
+	}
+	return false
+}
+
+// atomPosition returns the position of the first atom of a residue with the given name
+func atomPosition(residue []*AtomRecord, name string) vec3 {
+	for _, atom := range residue {
+		if atom.Name == name {
+			return atom.Coord()
+		}
+	}
+	return vec3{}
+}
+
+// capAtom returns a new atom of a cap of residue, named and placed
+func capAtom(residue []*AtomRecord, key ResidueKey, name string, p vec3) *AtomRecord {
+	atom := *residue[0]
+	atom.Het, atom.Serial, atom.Name, atom.AltLoc, atom.Element, atom.Charge, atom.Occupancy = false, 0, name, ' ', name[:1], "", 1
+	atom.ResName, atom.ResSeq, atom.ICode = key.ResName, key.ResSeq, key.ICode
+	atom.SetCoord(p)
+	return &atom
+}
+
+// capKey returns the residue key of a cap numbered resSeq in the chain of residue, with the first free
+// insertion code when that number is taken
+func capKey(residue []*AtomRecord, resName string, resSeq int, taken map[ResidueKey]bool) ResidueKey {
+	key := ResidueKey{Model: residue[0].Model, ChainID: residue[0].ChainID, ResSeq: resSeq, ICode: ' '}
+	for code := byte('A'); taken[key] && code <= 'Z'; code++ {
+		key.ICode = code
+	}
+	taken[key] = true
+	key.ResName = resName
+	return key
+}
+
+// aceCap returns the atoms of an ACE group bonded to the N of residue
+func aceCap(residue []*AtomRecord, taken map[ResidueKey]bool) []*AtomRecord {
+	n, ca, c := atomPosition(residue, "N"), atomPosition(residue, "CA"), atomPosition(residue, "C")
+	phi := capPhi
+	if residue[0].ResName == "PRO" {
+		phi = capProPhi
+	}
+	carbon := placeAtom(c, ca, n, 1.33, 121.7, phi)
+	key := capKey(residue, "ACE", residue[0].ResSeq-1, taken)
+	return []*AtomRecord{
+		capAtom(residue, key, "C", carbon),
+		capAtom(residue, key, "O", placeAtom(ca, n, carbon, 1.23, 123.0, 0)),
+		capAtom(residue, key, "CH3", placeAtom(ca, n, carbon, 1.52, 116.2, 180)),
+	}
+}
+
+// nmeCap returns the atoms of an NME group bonded to the C of residue, with the methyl carbon named
+// methyl
+func nmeCap(residue []*AtomRecord, methyl string, taken map[ResidueKey]bool) []*AtomRecord {
+	n, ca, c := atomPosition(residue, "N"), atomPosition(residue, "CA"), atomPosition(residue, "C")
+	var nitrogen vec3
+	hasO := false
+	for _, atom := range residue {
+		if atom.Name == "O" {
+			hasO = true
+		}
+	}
+	if hasO {
+		nitrogen = placeAtom(atomPosition(residue, "O"), ca, c, 1.33, 116.2, 180)
+	} else {
+		nitrogen = placeAtom(n, ca, c, 1.33, 116.2, capPsi)
+	}
+	key := capKey(residue, "NME", residue[len(residue)-1].ResSeq+1, taken)
+	return []*AtomRecord{
+		capAtom(residue, key, "N", nitrogen),
+		capAtom(residue, key, methyl, placeAtom(ca, c, nitrogen, 1.46, 121.7, 180)),
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	centerChains string
+	centerAtoms  string
+	centerFormat string
+	centerOutput string
+)
+
+var centerCmd = &cobra.Command{
+	Use:   "center [flags] [input_file]",
+	Short: "Report the centre of mass and geometric centre of a structure",
+	Long: `Report the centre of mass (atoms weighted by the standard atomic weight of their element) and the
+geometric centre (unweighted mean position) of a structure or a selection of it, for example to place
+a docking box on a binding site or to pick an alignment target.
+
+The atoms are selected with --chains (chain IDs, optionally with residue ranges such as A:10-120) and
+--atoms: all (the default), heavy (no hydrogens), backbone (N, CA, C, O and nucleic acid backbone
+atoms) or ca. Waters are left out, and only the first ALTLOC of atoms with alternate locations is used.
+
+The centres are reported for every model, as a tab-separated table (model, atoms, com_x, com_y, com_z,
+center_x, center_y, center_z), JSON with --format json, or with --format xyz only the x, y and z of the
+centre of mass separated by spaces, one line per model, for use in shell scripts.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Report the centre of mass and geometric centre
+  pdbtk center 1a02.pdb
+
+  # Centre a docking box on residues 40-60 of chain A
+  read x y z < <(pdbtk center --chains A:40-60 --format xyz 1a02.pdb)`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCenter,
+}
+
+func init() {
+	centerCmd.Flags().StringVarP(&centerChains, "chains", "c", "", "Comma-separated list of chain IDs, optionally with residue ranges (default: all chains)")
+	centerCmd.Flags().StringVar(&centerAtoms, "atoms", "all", "Atoms to include: all, heavy, backbone or ca")
+	centerCmd.Flags().StringVarP(&centerFormat, "format", "f", "tsv", "Output format: tsv, json or xyz")
+	centerCmd.Flags().StringVarP(&centerOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// modelCenter is the centre of mass and geometric centre of the selected atoms of a model
+type modelCenter struct {
+	Model           int        `json:"model"`
+	Atoms           int        `json:"atoms"`
+	CenterOfMass    [3]float64 `json:"center_of_mass"`
+	GeometricCenter [3]float64 `json:"geometric_center"`
+}
+
+func runCenter(cmd *cobra.Command, args []string) error {
+	if err := checkAtomSet(centerAtoms); err != nil {
+		return err
+	}
+	switch centerFormat {
+	case "tsv", "json", "xyz":
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv, json or xyz)", centerFormat))
+	}
+	var selections chainSelections
+	if centerChains != "" {
+		var err error
+		if selections, err = parseChainSelections(centerChains); err != nil {
+			return err
+		}
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	byModel := selectModelAtoms(file, selections, centerAtoms)
+	results := make([]modelCenter, 0, len(byModel))
+	for _, model := range file.Models() {
+		atoms := byModel[model]
+		if len(atoms) == 0 {
+			continue
+		}
+		masses, err := atomWeights(atoms, true)
+		if err != nil {
+			return err
+		}
+		ones, _ := atomWeights(atoms, false)
+		result := modelCenter{Model: model, Atoms: len(atoms)}
+		com, centre := weightedCentre(atoms, masses), weightedCentre(atoms, ones)
+		for i := range com {
+			result.CenterOfMass[i] = roundTo(com[i], 3)
+			result.GeometricCenter[i] = roundTo(centre[i], 3)
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no atoms match the selection"))
+	}
+
+	return writeOutput(cmd.Context(), centerOutput, func(w io.Writer) error {
+		switch centerFormat {
+		case "json":
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(results)
+		case "xyz":
+			for _, r := range results {
+				fmt.Fprintf(w, "%.3f %.3f %.3f\n", r.CenterOfMass[0], r.CenterOfMass[1], r.CenterOfMass[2])
+			}
+			return nil
+		}
+		fmt.Fprintln(w, "model\tatoms\tcom_x\tcom_y\tcom_z\tcenter_x\tcenter_y\tcenter_z")
+		for _, r := range results {
+			fmt.Fprintf(w, "%d\t%d\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\t%.3f\n", r.Model, r.Atoms,
+				r.CenterOfMass[0], r.CenterOfMass[1], r.CenterOfMass[2],
+				r.GeometricCenter[0], r.GeometricCenter[1], r.GeometricCenter[2])
+		}
+		return nil
+	})
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// residueRangePattern matches a residue number or range with optional insertion codes, e.g. 10, 10-120, 52A-60 or -5-10
+var residueRangePattern = regexp.MustCompile(`^(-?\d+)([A-Za-z]?)(?:-(-?\d+)([A-Za-z]?))?$`)
+
+// residuePosition is a residue number and insertion code (' ' when absent)
+type residuePosition struct {
+	ResSeq int
+	ICode  byte
+}
+
+// before reports whether p comes before q in residue numbering order (10 < 10A < 11)
+func (p residuePosition) before(q residuePosition) bool {
+	if p.ResSeq != q.ResSeq {
+		return p.ResSeq < q.ResSeq
+	}
+	return p.ICode < q.ICode
+}
+
+// residueRange is an inclusive range of residue positions
+type residueRange struct {
+	Start, End residuePosition
+}
+
+// chainSelection selects a whole chain, or only the given residue ranges of it
+type chainSelection struct {
+	ChainID byte
+	Ranges  []residueRange // empty for the whole chain
+}
+
+// chainSelections is a parsed --chains list
+type chainSelections []chainSelection
+
+// parseChainSelections parses a --chains list of chain IDs, each optionally followed by residue ranges:
+// "A,B", "A:10-120" or "A:10-120,B:5-40,200-250". Residue ranges that follow a chain with ranges
+// belong to that chain, so in "B:5-40,200-250" both ranges select residues of chain B.
+func parseChainSelections(spec string) (chainSelections, error) {
+	var selections chainSelections
+	index := make(map[byte]int)
+	for _, token := range strings.Split(spec, ",") {
+		token = strings.TrimSpace(token)
+		chainPart, rangePart, hasRange := strings.Cut(token, ":")
+		last := len(selections) - 1
+		if !hasRange && last >= 0 && len(selections[last].Ranges) > 0 && residueRangePattern.MatchString(token) {
+			// A range continuing the previous chain
+			chainPart, rangePart, hasRange = string(selections[last].ChainID), token, true
+		}
+		if len(chainPart) != 1 {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid chain ID: %s (must be single character, optionally followed by residue ranges such as A:10-120)", token))
+		}
+
+		var ranges []residueRange
+		if hasRange {
+			r, err := parseResidueRange(rangePart)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, r)
+		}
+
+		chainID := chainPart[0]
+		i, seen := index[chainID]
+		if !seen {
+			index[chainID] = len(selections)
+			selections = append(selections, chainSelection{ChainID: chainID, Ranges: ranges})
+			continue
+		}
+		// A chain listed more than once: the whole chain wins over ranges
+		if len(ranges) == 0 || len(selections[i].Ranges) == 0 {
+			selections[i].Ranges = nil
+		} else {
+			selections[i].Ranges = append(selections[i].Ranges, ranges...)
+		}
+	}
+	return selections, nil
+}
+
+// parseResidueRange parses a residue number or range such as 10, 10-120 or 52A-60
+func parseResidueRange(s string) (residueRange, error) {
+	match := residueRangePattern.FindStringSubmatch(s)
+	if match == nil {
+		return residueRange{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid residue range: %s (expected e.g. 10-120)", s))
+	}
+	position := func(number, iCode string) residuePosition {
+		n, _ := strconv.Atoi(number)
+		p := residuePosition{ResSeq: n, ICode: ' '}
+		if iCode != "" {
+			p.ICode = iCode[0]
+		}
+		return p
+	}
+	r := residueRange{Start: position(match[1], match[2])}
+	r.End = r.Start
+	if match[3] != "" {
+		r.End = position(match[3], match[4])
+	}
+	if r.End.before(r.Start) {
+		return residueRange{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid residue range: %s (end before start)", s))
+	}
+	return r, nil
+}
+
+// ChainIDs returns the selected chain IDs, in the order they were given
+func (s chainSelections) ChainIDs() []string {
+	ids := make([]string, len(s))
+	for i, selection := range s {
+		ids[i] = string(selection.ChainID)
+	}
+	return ids
+}
+
+// HasRanges reports whether any chain is restricted to residue ranges
+func (s chainSelections) HasRanges() bool {
+	for _, selection := range s {
+		if len(selection.Ranges) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains reports whether a residue is selected. An insertion code of 0 is treated as blank.
+func (s chainSelections) Contains(chainID byte, resSeq int, iCode byte) bool {
+	if iCode == 0 {
+		iCode = ' '
+	}
+	p := residuePosition{ResSeq: resSeq, ICode: iCode}
+	for _, selection := range s {
+		if selection.ChainID != chainID {
+			continue
+		}
+		if len(selection.Ranges) == 0 {
+			return true
+		}
+		for _, r := range selection.Ranges {
+			if !p.before(r.Start) && !r.End.before(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// String formats a chain selection as it is given on the command line
+func (c chainSelection) String() string {
+	if len(c.Ranges) == 0 {
+		return string(c.ChainID)
+	}
+	format := func(p residuePosition) string {
+		return strconv.Itoa(p.ResSeq) + strings.TrimSpace(string(p.ICode))
+	}
+	var ranges []string
+	for _, r := range c.Ranges {
+		if r.Start == r.End {
+			ranges = append(ranges, format(r.Start))
+		} else {
+			ranges = append(ranges, format(r.Start)+"-"+format(r.End))
+		}
+	}
+	return string(c.ChainID) + ":" + strings.Join(ranges, ",")
+}
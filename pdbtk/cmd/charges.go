@@ -0,0 +1,436 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	chargesFormat string
+	chargesOutput string
+)
+
+var chargesCmd = &cobra.Command{
+	Use:   "charges [flags] [input_file]",
+	Short: "Assign Gasteiger partial charges to atoms",
+	Long: `Assign Gasteiger-Marsili partial charges to the atoms of a structure, for docking receptor
+preparation and electrostatics checks.
+
+Bonds are found from the distances between atoms (covalent radii plus 0.45 Å) and the hybridization
+of C, N, O, S and P atoms from the number of bonded atoms and the bond angles or, for atoms with a
+single bond, the bond length. Carbon and nitrogen atoms in planar 5- and 6-membered rings are aromatic.
+Charges are then equalized along the bonds for 6 iterations of the Gasteiger-Marsili method, starting
+from the formal charges of the input (columns 79-80). Atoms without Gasteiger parameters, such as
+metal ions, keep their formal charge (a warning).
+
+Most crystal structures have no hydrogens, which leaves heavy atoms with the charges of their
+united-atom groups rather than the all-atom charges docking programs expect, so run add-hydrogens
+first. Only the first model and the first ALTLOC of atoms with alternate locations are used.
+
+The output is a tab-separated table (serial, chain, resnum, resname, atom, element, type, charge)
+with the SYBYL atom type used for the parameters, JSON with --format json, or a rigid (receptor)
+PDBQT file with the charges and AutoDock atom types with --format pdbqt.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Assign charges to the atoms of a structure
+  pdbtk charges 1a02.pdb
+
+  # Prepare a receptor PDBQT file for docking
+  pdbtk add-hydrogens 1a02.pdb | pdbtk charges --format pdbqt --output receptor.pdbqt`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runCharges,
+}
+
+func init() {
+	chargesCmd.Flags().StringVarP(&chargesFormat, "format", "f", "tsv", "Output format: tsv, json or pdbqt")
+	chargesCmd.Flags().StringVarP(&chargesOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// gasteigerParameters are the a, b and c coefficients of the electronegativity a + b·q + c·q² of the
+// Gasteiger-Marsili method, by SYBYL atom type (aromatic atoms use the sp2 parameters)
+var gasteigerParameters = map[string][3]float64{
+	"H":   {7.17, 6.24, -0.56},
+	"C.3": {7.98, 9.18, 1.88}, "C.2": {8.79, 9.32, 1.51}, "C.1": {10.39, 9.45, 0.73},
+	"N.3": {11.54, 10.82, 1.36}, "N.2": {12.87, 11.15, 0.85}, "N.1": {15.68, 11.70, -0.27},
+	"O.3": {14.18, 12.92, 1.39}, "O.2": {17.07, 13.79, 0.47},
+	"S.3": {10.14, 9.13, 1.38}, "P.3": {8.90, 8.24, 0.96},
+	"F": {14.66, 13.85, 2.31}, "CL": {11.00, 9.69, 1.35}, "BR": {10.08, 8.47, 1.16}, "I": {9.90, 7.96, 0.96},
+}
+
+// hydrogenCationElectronegativity is the electronegativity of the hydrogen cation, used in place of
+// a + b + c for hydrogen
+const hydrogenCationElectronegativity = 20.02
+
+// gasteigerIterations is the number of charge equalization steps
+const gasteigerIterations = 6
+
+// bondTolerance is added to the sum of the covalent radii of two atoms to decide that they are bonded
+const bondTolerance = 0.45
+
+// atomCharge is the partial charge of an atom as reported by charges
+type atomCharge struct {
+	Serial  int     `json:"serial"`
+	Chain   string  `json:"chain"`
+	ResNum  string  `json:"resnum"`
+	ResName string  `json:"resname"`
+	Atom    string  `json:"atom"`
+	Element string  `json:"element"`
+	Type    string  `json:"type"`
+	Charge  float64 `json:"charge"`
+}
+
+func runCharges(cmd *cobra.Command, args []string) error {
+	if chargesFormat != "tsv" && chargesFormat != "json" && chargesFormat != "pdbqt" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv, json or pdbqt)", chargesFormat))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	models := file.Models()
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if atom.Model == models[0] {
+			atoms = append(atoms, atom)
+		}
+	}
+	atoms = filterAltLocRecords(atoms, "first")
+	if len(atoms) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no atoms found"))
+	}
+
+	typed := typeAtoms(atoms)
+	charges := gasteigerCharges(typed)
+	var unparameterized []string
+	for _, a := range typed {
+		if _, ok := gasteigerParameters[a.parameterKey()]; !ok && !containsString(unparameterized, a.element) {
+			unparameterized = append(unparameterized, a.element)
+		}
+	}
+	if len(unparameterized) > 0 {
+		if err := warn("no Gasteiger parameters for %s: these atoms keep their formal charge", strings.Join(unparameterized, ", ")); err != nil {
+			return err
+		}
+	}
+
+	var results []atomCharge
+	for i, atom := range atoms {
+		resNum := strconv.Itoa(atom.ResSeq)
+		if atom.ICode != ' ' {
+			resNum += string(atom.ICode)
+		}
+		results = append(results, atomCharge{
+			Serial:  atom.Serial,
+			Chain:   string(atom.ChainID),
+			ResNum:  resNum,
+			ResName: atom.ResName,
+			Atom:    atom.Name,
+			Element: typed[i].element,
+			Type:    typed[i].sybylType(),
+			Charge:  roundTo(charges[i], 4),
+		})
+	}
+
+	return writeOutput(cmd.Context(), chargesOutput, func(w io.Writer) error {
+		switch chargesFormat {
+		case "json":
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(results)
+		case "pdbqt":
+			for i, atom := range atoms {
+				fmt.Fprintf(w, "%s    %6.3f %-2s\n", formatAtomRecord(atom, i+1)[:66], charges[i], typed[i].autodockType(typed))
+			}
+			fmt.Fprintln(w, "END")
+			return nil
+		}
+		fmt.Fprintln(w, "serial\tchain\tresnum\tresname\tatom\telement\ttype\tcharge")
+		for _, r := range results {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%.4f\n", r.Serial, r.Chain, r.ResNum, r.ResName, r.Atom, r.Element, r.Type, r.Charge)
+		}
+		return nil
+	})
+}
+
+// typedAtom is an atom with its bonds, hybridization (1 to 3 for sp to sp3) and aromaticity
+type typedAtom struct {
+	element       string
+	bonds         []int // indexes of the bonded atoms
+	hybridization int
+	aromatic      bool
+	formalCharge  float64
+}
+
+// parameterKey returns the SYBYL-like type whose Gasteiger parameters apply to the atom
+func (a *typedAtom) parameterKey() string {
+	switch a.element {
+	case "C", "N", "O", "S", "P":
+		return a.element + "." + strconv.Itoa(a.hybridization)
+	}
+	return a.element
+}
+
+// sybylType returns the SYBYL atom type of the atom, e.g. C.3 or C.ar
+func (a *typedAtom) sybylType() string {
+	if a.aromatic {
+		return a.element + ".ar"
+	}
+	if _, ok := gasteigerParameters[a.parameterKey()]; !ok {
+		return a.element
+	}
+	return a.parameterKey()
+}
+
+// autodockType returns the AutoDock 4 atom type of the atom: aromatic carbons are A, hydrogen bond
+// acceptors NA, OA and SA, and hydrogens bonded to N, O or S are HD
+func (a *typedAtom) autodockType(atoms []*typedAtom) string {
+	switch a.element {
+	case "C":
+		if a.aromatic {
+			return "A"
+		}
+		return "C"
+	case "N":
+		// Nitrogens with a lone pair to spare: sp, or sp2 with two bonds and no hydrogen
+		if a.hybridization == 1 || (a.hybridization == 2 && len(a.bonds) == 2) {
+			return "NA"
+		}
+		return "N"
+	case "O", "S":
+		return a.element + "A"
+	case "H":
+		for _, j := range a.bonds {
+			if e := atoms[j].element; e == "N" || e == "O" || e == "S" {
+				return "HD"
+			}
+		}
+		return "H"
+	}
+	return a.element[:1] + strings.ToLower(a.element[1:])
+}
+
+// typeAtoms finds the bonds of the atoms and assigns their hybridization and aromaticity
+func typeAtoms(atoms []*AtomRecord) []*typedAtom {
+	inferred := inferElements(atoms)
+	typed := make([]*typedAtom, len(atoms))
+	for i, atom := range atoms {
+		typed[i] = &typedAtom{element: atomElement(atom, inferred[i]), hybridization: 3, formalCharge: formalCharge(atom.Charge)}
+	}
+
+	// Sweep along x so that only atoms within the largest possible bond length are compared
+	radius := func(i int) (float64, bool) {
+		if typed[i].element == "H" {
+			return 0.31, true
+		}
+		r, ok := covalentRadii[typed[i].element]
+		return r, ok
+	}
+	order := make([]int, len(atoms))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return atoms[order[i]].X < atoms[order[j]].X })
+	maxRadius := 0.0
+	for _, r := range covalentRadii {
+		maxRadius = math.Max(maxRadius, r)
+	}
+	for m, i := range order {
+		ri, ok := radius(i)
+		if !ok {
+			continue
+		}
+		for _, j := range order[m+1:] {
+			if atoms[j].X-atoms[i].X > 2*maxRadius+bondTolerance {
+				break
+			}
+			rj, ok := radius(j)
+			if !ok || (typed[i].element == "H" && typed[j].element == "H") {
+				continue
+			}
+			if distance(atoms[i].Coord(), atoms[j].Coord()) <= ri+rj+bondTolerance {
+				typed[i].bonds = append(typed[i].bonds, j)
+				typed[j].bonds = append(typed[j].bonds, i)
+			}
+		}
+	}
+
+	for i, a := range typed {
+		a.hybridization = hybridization(a.element, atoms[i], atoms, a.bonds)
+	}
+	for i, a := range typed {
+		if (a.element == "C" || a.element == "N") && a.hybridization == 2 {
+			a.aromatic = inPlanarRing(typed, i, 5) || inPlanarRing(typed, i, 6)
+		}
+	}
+	return typed
+}
+
+// hybridization returns the hybridization of an atom (1 to 3 for sp to sp3) from the number of
+// bonded atoms and the mean angle between the bonds or, with a single bond, the bond length
+func hybridization(element string, atom *AtomRecord, atoms []*AtomRecord, bonds []int) int {
+	if element != "C" && element != "N" && element != "O" {
+		return 3
+	}
+	switch len(bonds) {
+	case 0:
+		return 3
+	case 1:
+		length := distance(atom.Coord(), atoms[bonds[0]].Coord())
+		switch element {
+		case "C":
+			if length < 1.25 {
+				return 1
+			} else if length < 1.40 {
+				return 2
+			}
+		case "N":
+			if length < 1.20 {
+				return 1
+			} else if length < 1.40 {
+				return 2
+			}
+		case "O":
+			if length < 1.30 {
+				return 2
+			}
+		}
+		return 3
+	}
+	if element == "O" || len(bonds) > 3 {
+		return 3
+	}
+
+	sum, n := 0.0, 0
+	for x := 0; x < len(bonds); x++ {
+		for y := x + 1; y < len(bonds); y++ {
+			u := atoms[bonds[x]].Coord().sub(atom.Coord()).unit()
+			v := atoms[bonds[y]].Coord().sub(atom.Coord()).unit()
+			sum += math.Acos(math.Max(-1, math.Min(1, u.dot(v)))) * 180 / math.Pi
+			n++
+		}
+	}
+	mean := sum / float64(n)
+	switch {
+	case len(bonds) == 2 && mean >= 155:
+		return 1
+	case mean >= 115:
+		return 2
+	}
+	return 3
+}
+
+// inPlanarRing reports whether an atom is in a ring of the given size whose atoms are all sp2
+func inPlanarRing(atoms []*typedAtom, start, size int) bool {
+	path := []int{start}
+	var search func(i int) bool
+	search = func(i int) bool {
+		for _, j := range atoms[i].bonds {
+			if j == start && len(path) == size {
+				return true
+			}
+			if len(path) == size || atoms[j].hybridization != 2 || containsInt(path, j) {
+				continue
+			}
+			path = append(path, j)
+			if search(j) {
+				return true
+			}
+			path = path[:len(path)-1]
+		}
+		return false
+	}
+	return search(start)
+}
+
+// gasteigerCharges equalizes the electronegativity of bonded atoms by the Gasteiger-Marsili method,
+// starting from their formal charges. Atoms without parameters keep their formal charge.
+func gasteigerCharges(atoms []*typedAtom) []float64 {
+	charges := make([]float64, len(atoms))
+	params := make([]*[3]float64, len(atoms))
+	for i, a := range atoms {
+		charges[i] = a.formalCharge
+		if p, ok := gasteigerParameters[a.parameterKey()]; ok {
+			params[i] = &p
+		}
+	}
+
+	damping := 1.0
+	electronegativity := make([]float64, len(atoms))
+	for iteration := 0; iteration < gasteigerIterations; iteration++ {
+		damping *= 0.5
+		for i, p := range params {
+			if p != nil {
+				q := charges[i]
+				electronegativity[i] = p[0] + p[1]*q + p[2]*q*q
+			}
+		}
+		transfers := make([]float64, len(atoms))
+		for i, a := range atoms {
+			if params[i] == nil {
+				continue
+			}
+			for _, j := range a.bonds {
+				// Each bond is considered once, from the less electronegative atom that gives up charge
+				if params[j] == nil || electronegativity[j] <= electronegativity[i] {
+					continue
+				}
+				cation := params[i][0] + params[i][1] + params[i][2]
+				if a.element == "H" {
+					cation = hydrogenCationElectronegativity
+				}
+				q := damping * (electronegativity[j] - electronegativity[i]) / cation
+				transfers[i] += q
+				transfers[j] -= q
+			}
+		}
+		for i := range charges {
+			charges[i] += transfers[i]
+		}
+	}
+	return charges
+}
+
+// formalCharge parses the charge column of an atom record, e.g. 1+ or 2-
+func formalCharge(charge string) float64 {
+	charge = strings.TrimSpace(charge)
+	if len(charge) != 2 {
+		return 0
+	}
+	n, err := strconv.Atoi(charge[:1])
+	if err != nil {
+		return 0
+	}
+	if charge[1] == '-' {
+		n = -n
+	}
+	return float64(n)
+}
+
+// containsInt reports whether list contains n
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CIFLoop is a loop_ construct: a table with one column per tag
+type CIFLoop struct {
+	Tags []string
+	Rows [][]string
+}
+
+// CIFBlock is a data_ block of an mmCIF file
+type CIFBlock struct {
+	Name  string
+	Items map[string]string // single-valued items, keyed by full tag (e.g. "_entry.id")
+	Loops []*CIFLoop
+}
+
+// Column returns the index of tag in the loop, or -1 if it is not present
+func (l *CIFLoop) Column(tag string) int {
+	for i, t := range l.Tags {
+		if strings.EqualFold(t, tag) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Category returns the rows of a category (e.g. "_atom_site") as maps from item name (e.g. "Cartn_x")
+// to value, whether the category was written as a loop or as single-valued items
+func (b *CIFBlock) Category(category string) []map[string]string {
+	prefix := strings.ToLower(category) + "."
+	for _, loop := range b.Loops {
+		if len(loop.Tags) == 0 || !strings.HasPrefix(strings.ToLower(loop.Tags[0]), prefix) {
+			continue
+		}
+		rows := make([]map[string]string, len(loop.Rows))
+		for i, values := range loop.Rows {
+			row := make(map[string]string, len(loop.Tags))
+			for j, tag := range loop.Tags {
+				row[tag[len(prefix):]] = values[j]
+			}
+			rows[i] = row
+		}
+		return rows
+	}
+
+	row := make(map[string]string)
+	for tag, value := range b.Items {
+		if strings.HasPrefix(strings.ToLower(tag), prefix) {
+			row[tag[len(prefix):]] = value
+		}
+	}
+	if len(row) == 0 {
+		return nil
+	}
+	return []map[string]string{row}
+}
+
+// HasCategory reports whether the block contains any items of the category
+func (b *CIFBlock) HasCategory(category string) bool {
+	return len(b.Category(category)) > 0
+}
+
+// cifToken is a single token of a CIF file
+type cifToken struct {
+	value  string
+	quoted bool // quoted strings and text fields are never keywords
+	line   int
+}
+
+// tokenizeCIF splits CIF content into tokens, handling quoted strings, semicolon text fields and comments
+func tokenizeCIF(reader io.Reader) ([]cifToken, error) {
+	var tokens []cifToken
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	lineNum := 0
+	var textField *strings.Builder
+	textStart := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if textField != nil {
+			if strings.HasPrefix(line, ";") {
+				tokens = append(tokens, cifToken{value: strings.TrimSuffix(textField.String(), "\n"), quoted: true, line: textStart})
+				textField = nil
+				line = line[1:]
+			} else {
+				textField.WriteString(line)
+				textField.WriteString("\n")
+				continue
+			}
+		} else if strings.HasPrefix(line, ";") {
+			textField = &strings.Builder{}
+			textField.WriteString(line[1:])
+			if len(line) > 1 {
+				textField.WriteString("\n")
+			}
+			textStart = lineNum
+			continue
+		}
+
+		i := 0
+		for i < len(line) {
+			c := line[i]
+			switch {
+			case c == ' ' || c == '\t':
+				i++
+			case c == '#':
+				i = len(line)
+			case c == '\'' || c == '"':
+				// A quote only closes the string when followed by whitespace or the end of the line
+				end := i + 1
+				for end < len(line) && !(line[end] == c && (end+1 == len(line) || line[end+1] == ' ' || line[end+1] == '\t')) {
+					end++
+				}
+				if end >= len(line) {
+					return nil, fmt.Errorf("line %d: unterminated quoted string", lineNum)
+				}
+				tokens = append(tokens, cifToken{value: line[i+1 : end], quoted: true, line: lineNum})
+				i = end + 1
+			default:
+				end := i
+				for end < len(line) && line[end] != ' ' && line[end] != '\t' {
+					end++
+				}
+				tokens = append(tokens, cifToken{value: line[i:end], line: lineNum})
+				i = end
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if textField != nil {
+		return nil, fmt.Errorf("line %d: unterminated text field", textStart)
+	}
+	return tokens, nil
+}
+
+// ParseCIF reads the data blocks of an mmCIF file
+func ParseCIF(reader io.Reader) ([]*CIFBlock, error) {
+	defer beginPhase(phaseParse)()
+	tokens, err := tokenizeCIF(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*CIFBlock
+	var block *CIFBlock
+	isKeyword := func(t cifToken) bool {
+		if t.quoted {
+			return false
+		}
+		lower := strings.ToLower(t.value)
+		return strings.HasPrefix(t.value, "_") || strings.HasPrefix(lower, "data_") || lower == "loop_" ||
+			strings.HasPrefix(lower, "save_") || lower == "stop_" || lower == "global_"
+	}
+
+	for i := 0; i < len(tokens); {
+		token := tokens[i]
+		lower := strings.ToLower(token.value)
+		switch {
+		case !token.quoted && strings.HasPrefix(lower, "data_"):
+			block = &CIFBlock{Name: token.value[5:], Items: make(map[string]string)}
+			blocks = append(blocks, block)
+			i++
+		case block == nil:
+			return nil, fmt.Errorf("line %d: data found before the first data_ block", token.line)
+		case !token.quoted && lower == "loop_":
+			loop := &CIFLoop{}
+			i++
+			for i < len(tokens) && !tokens[i].quoted && strings.HasPrefix(tokens[i].value, "_") {
+				loop.Tags = append(loop.Tags, tokens[i].value)
+				i++
+			}
+			if len(loop.Tags) == 0 {
+				return nil, fmt.Errorf("line %d: loop_ without tags", token.line)
+			}
+			var values []string
+			for i < len(tokens) && !isKeyword(tokens[i]) {
+				values = append(values, tokens[i].value)
+				i++
+			}
+			if len(values)%len(loop.Tags) != 0 {
+				return nil, fmt.Errorf("line %d: loop has %d values, not a multiple of its %d tags", token.line, len(values), len(loop.Tags))
+			}
+			for start := 0; start < len(values); start += len(loop.Tags) {
+				loop.Rows = append(loop.Rows, values[start:start+len(loop.Tags)])
+			}
+			block.Loops = append(block.Loops, loop)
+		case !token.quoted && strings.HasPrefix(token.value, "_"):
+			if i+1 >= len(tokens) || isKeyword(tokens[i+1]) {
+				return nil, fmt.Errorf("line %d: item %s has no value", token.line, token.value)
+			}
+			block.Items[token.value] = tokens[i+1].value
+			i += 2
+		default:
+			// save frames and global blocks are not used by mmCIF coordinate files
+			i++
+		}
+	}
+
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no data_ block found")
+	}
+	return blocks, nil
+}
+
+// isCIFFilename reports whether a filename looks like an mmCIF file
+func isCIFFilename(filename string) bool {
+	lower := strings.ToLower(strings.TrimSuffix(filename, ".gz"))
+	return strings.HasSuffix(lower, ".cif") || strings.HasSuffix(lower, ".mmcif")
+}
+
+// looksLikeCIF reports whether content appears to be mmCIF rather than PDB format
+func looksLikeCIF(content []byte) bool {
+	for _, line := range strings.SplitN(string(content), "\n", 50) {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		return strings.HasPrefix(strings.ToLower(trimmed), "data_")
+	}
+	return false
+}
+
+// readCIFInput reads and parses an mmCIF input file, or stdin when inputFile is empty, and returns its
+// first data block. what names the feature that requires mmCIF in the error for other formats.
+func readCIFInput(ctx context.Context, inputFile, what string) (*CIFBlock, error) {
+	content, err := readInputContent(ctx, inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if !isCIFFilename(inputFile) && !looksLikeCIF(content) {
+		return nil, withCode(ErrCodeUnsupportedFormat, fmt.Errorf("%s requires an mmCIF input", what))
+	}
+	blocks, err := ParseCIF(strings.NewReader(string(content)))
+	if err != nil {
+		return nil, withCode(ErrCodeParse, fmt.Errorf("failed to parse mmCIF: %v", err))
+	}
+	return blocks[0], nil
+}
@@ -0,0 +1,442 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// metadataClient sends the requests to the metadata servers of cloud instances. They answer at once on
+// an instance and not at all elsewhere, so the wait is short, and they are never reached through a proxy.
+var metadataClient = &http.Client{Timeout: 2 * time.Second, Transport: &http.Transport{}}
+
+// readINIFile reads the sections of an INI file, such as the AWS credentials and config files, as maps
+// from key to value. It returns nil if the file cannot be read.
+func readINIFile(filename string) map[string]map[string]string {
+	if filename == "" {
+		return nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	sections := make(map[string]map[string]string)
+	var section map[string]string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			if sections[name] == nil {
+				sections[name] = make(map[string]string)
+			}
+			section = sections[name]
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if section == nil || !ok || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		section[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return sections
+}
+
+// awsConfigFile returns the path of the AWS config file
+func awsConfigFile() string {
+	if filename := os.Getenv("AWS_CONFIG_FILE"); filename != "" {
+		return filename
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".aws", "config")
+	}
+	return ""
+}
+
+// awsConfigProfileCredentials finds the credentials of a profile of the AWS config file: its access keys,
+// the output of its credential_process, or the role credentials of its IAM Identity Center (SSO) login.
+// It reports false if the profile has none of them.
+func awsConfigProfileCredentials(ctx context.Context, profile string) (awsCredentialSet, bool, error) {
+	sections := readINIFile(awsConfigFile())
+	name := "profile " + profile
+	if profile == "default" && sections[name] == nil {
+		name = "default"
+	}
+	section := sections[name]
+
+	switch {
+	case section["aws_access_key_id"] != "":
+		return awsCredentialSet{
+			AccessKeyID:     section["aws_access_key_id"],
+			SecretAccessKey: section["aws_secret_access_key"],
+			SessionToken:    section["aws_session_token"],
+		}, true, nil
+	case section["credential_process"] != "":
+		creds, err := awsProcessCredentials(ctx, section["credential_process"])
+		return creds, true, err
+	case section["sso_account_id"] != "" && section["sso_role_name"] != "":
+		creds, err := awsSSOCredentials(ctx, profile, section, sections["sso-session "+section["sso_session"]])
+		return creds, true, err
+	}
+	return awsCredentialSet{}, false, nil
+}
+
+// awsProcessCredentials runs the credential_process command of a profile, which writes the credentials
+// as JSON to stdout
+func awsProcessCredentials(ctx context.Context, command string) (awsCredentialSet, error) {
+	args := splitCommandLine(command)
+	if len(args) == 0 {
+		return awsCredentialSet{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("empty credential_process in the AWS config file"))
+	}
+	process := exec.CommandContext(ctx, args[0], args[1:]...)
+	process.Stderr = os.Stderr
+	output, err := process.Output()
+	if err != nil {
+		return awsCredentialSet{}, withCode(ErrCodeGeneric, fmt.Errorf("credential_process %s failed: %v", args[0], err))
+	}
+	var result struct {
+		Version         int
+		AccessKeyID     string `json:"AccessKeyId"`
+		SecretAccessKey string
+		SessionToken    string
+	}
+	if err := json.Unmarshal(output, &result); err != nil || result.Version != 1 || result.AccessKeyID == "" {
+		return awsCredentialSet{}, withCode(ErrCodeGeneric, fmt.Errorf("credential_process %s wrote no version 1 credentials", args[0]))
+	}
+	return awsCredentialSet{AccessKeyID: result.AccessKeyID, SecretAccessKey: result.SecretAccessKey, SessionToken: result.SessionToken}, nil
+}
+
+// splitCommandLine splits a command line into its arguments at whitespace outside single or double quotes
+func splitCommandLine(command string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// awsSSOCredentials gets the role credentials of an IAM Identity Center (SSO) profile with the access
+// token that "aws sso login" cached. session is the sso-session section the profile refers to, if any.
+func awsSSOCredentials(ctx context.Context, profile string, section, session map[string]string) (awsCredentialSet, error) {
+	// The cache is named after the session, or after the start URL of a profile without one
+	cacheKey, region := section["sso_start_url"], section["sso_region"]
+	if section["sso_session"] != "" {
+		cacheKey, region = section["sso_session"], session["sso_region"]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || cacheKey == "" || region == "" {
+		return awsCredentialSet{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("incomplete SSO configuration of AWS profile %s", profile))
+	}
+	sum := sha1.Sum([]byte(cacheKey))
+	content, err := os.ReadFile(filepath.Join(home, ".aws", "sso", "cache", hex.EncodeToString(sum[:])+".json"))
+	var token struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresAt   string `json:"expiresAt"`
+	}
+	if err == nil {
+		err = json.Unmarshal(content, &token)
+	}
+	expires, _ := time.Parse(time.RFC3339, token.ExpiresAt)
+	if err != nil || token.AccessToken == "" || time.Now().After(expires) {
+		return awsCredentialSet{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("no current SSO login for AWS profile %s (run \"aws sso login --profile %s\")", profile, profile))
+	}
+
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SSO")
+	if endpoint == "" {
+		endpoint = "https://portal.sso." + region + ".amazonaws.com"
+	}
+	target := strings.TrimSuffix(endpoint, "/") + "/federation/credentials?" + url.Values{
+		"account_id": {section["sso_account_id"]},
+		"role_name":  {section["sso_role_name"]},
+	}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return awsCredentialSet{}, withCode(ErrCodeNetwork, fmt.Errorf("invalid request: %v", err))
+	}
+	req.Header.Set("x-amz-sso_bearer_token", token.AccessToken)
+	var result struct {
+		RoleCredentials struct {
+			AccessKeyID     string `json:"accessKeyId"`
+			SecretAccessKey string `json:"secretAccessKey"`
+			SessionToken    string `json:"sessionToken"`
+		} `json:"roleCredentials"`
+	}
+	if err := requestJSON(req, &result); err != nil {
+		return awsCredentialSet{}, credentialsError(ctx, "SSO role credentials", err)
+	}
+	role := result.RoleCredentials
+	return awsCredentialSet{AccessKeyID: role.AccessKeyID, SecretAccessKey: role.SecretAccessKey, SessionToken: role.SessionToken}, nil
+}
+
+// awsContainerCredentials gets the credentials of an ECS task, AWS Batch job or EKS pod from the
+// container credentials endpoint, if the environment names one. It reports false otherwise.
+func awsContainerCredentials(ctx context.Context) (awsCredentialSet, bool, error) {
+	var creds awsCredentialSet
+	target := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		target = "http://169.254.170.2" + relative
+	}
+	if target == "" {
+		return creds, false, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return creds, true, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid container credentials URI %s: %v", target, err))
+	}
+	authorization := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return creds, true, withCode(ErrCodeIO, fmt.Errorf("failed to read container authorization token: %v", err))
+		}
+		authorization = strings.TrimSpace(string(content))
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	if err := requestJSON(req, &creds); err != nil {
+		return creds, true, credentialsError(ctx, "container credentials", err)
+	}
+	return creds, true, nil
+}
+
+// awsInstanceCredentials gets the credentials of the instance profile of an EC2 instance from the
+// instance metadata service (IMDSv2). Away from EC2 the metadata service does not answer, and no
+// credentials are returned.
+func awsInstanceCredentials() (awsCredentialSet, error) {
+	var creds awsCredentialSet
+	if strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return creds, nil
+	}
+	endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "http://169.254.169.254"
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	token, status, err := metadataRequest(http.MethodPut, endpoint+"/latest/api/token", "X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	if err != nil || status != http.StatusOK {
+		return creds, nil
+	}
+	roles, status, err := metadataRequest(http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/", "X-aws-ec2-metadata-token", token)
+	if err != nil || status != http.StatusOK || strings.TrimSpace(roles) == "" {
+		// An instance without an instance profile
+		return creds, nil
+	}
+	role := strings.TrimSpace(strings.SplitN(roles, "\n", 2)[0])
+	content, status, err := metadataRequest(http.MethodGet, endpoint+"/latest/meta-data/iam/security-credentials/"+role, "X-aws-ec2-metadata-token", token)
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("HTTP %d", status)
+	}
+	if err == nil {
+		err = json.Unmarshal([]byte(content), &creds)
+	}
+	if err != nil {
+		return creds, withCode(ErrCodeNetwork, fmt.Errorf("failed to get the credentials of instance profile %s: %v", role, err))
+	}
+	return creds, nil
+}
+
+// metadataRequest sends a request to an instance metadata server with one header, and returns the
+// response body and status
+func metadataRequest(method, target, header, value string) (string, int, error) {
+	req, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set(header, value)
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), resp.StatusCode, err
+}
+
+// requestJSON sends req and decodes the JSON response into v
+func requestJSON(req *http.Request, v interface{}) error {
+	body, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return withCode(ErrCodeNetwork, fmt.Errorf("invalid response from %s: %v", req.URL.Host, err))
+	}
+	return nil
+}
+
+// credentialsError returns the error of a failed lookup of credentials from source. Lookups have a
+// limit of their own rather than the --timeout of the command, which is reported instead.
+func credentialsError(ctx context.Context, source string, err error) error {
+	if ctx.Err() != nil {
+		err = fmt.Errorf("no response within %s", credentialsTimeout)
+	}
+	return withCode(ErrCodeNetwork, fmt.Errorf("failed to get %s: %v", source, err))
+}
+
+// gcsStorageScope is the OAuth 2.0 scope requested for service account credentials
+const gcsStorageScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// googleCredentialsFile is a credentials file of Google Cloud: a service account key or the
+// application default credentials of a user from "gcloud auth application-default login"
+type googleCredentialsFile struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleCredentialsFilename returns the Google Cloud credentials file of the environment: the
+// GOOGLE_APPLICATION_CREDENTIALS file, or the application default credentials of gcloud if they exist.
+// It returns "" if there is neither.
+func googleCredentialsFilename() string {
+	if filename := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); filename != "" {
+		return filename
+	}
+	dir := os.Getenv("CLOUDSDK_CONFIG")
+	if dir == "" {
+		if runtime.GOOS == "windows" {
+			dir = filepath.Join(os.Getenv("APPDATA"), "gcloud")
+		} else if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, ".config", "gcloud")
+		}
+	}
+	filename := filepath.Join(dir, "application_default_credentials.json")
+	if _, err := os.Stat(filename); err != nil {
+		return ""
+	}
+	return filename
+}
+
+// googleFileAccessToken exchanges the credentials of a Google Cloud credentials file for an access token
+func googleFileAccessToken(ctx context.Context, filename string) (string, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", withCode(ErrCodeIO, fmt.Errorf("failed to read Google Cloud credentials: %v", err))
+	}
+	var creds googleCredentialsFile
+	if err := json.Unmarshal(content, &creds); err != nil {
+		return "", withCode(ErrCodeParse, fmt.Errorf("invalid Google Cloud credentials file %s: %v", filename, err))
+	}
+	tokenURI := creds.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	form := url.Values{}
+	switch creds.Type {
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", creds.ClientID)
+		form.Set("client_secret", creds.ClientSecret)
+		form.Set("refresh_token", creds.RefreshToken)
+	case "service_account":
+		assertion, err := serviceAccountAssertion(creds, tokenURI, time.Now())
+		if err != nil {
+			return "", withCode(ErrCodeParse, fmt.Errorf("invalid service account key %s: %v", filename, err))
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	default:
+		return "", withCode(ErrCodeUnsupportedFormat, fmt.Errorf("unsupported Google Cloud credentials type %q in %s (supported: service_account, authorized_user)", creds.Type, filename))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", withCode(ErrCodeNetwork, fmt.Errorf("invalid request: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := requestJSON(req, &token); err != nil {
+		return "", credentialsError(ctx, "a Google Cloud access token", err)
+	}
+	if token.AccessToken == "" {
+		return "", withCode(ErrCodeNetwork, fmt.Errorf("failed to get a Google Cloud access token: none in the response"))
+	}
+	return token.AccessToken, nil
+}
+
+// serviceAccountAssertion returns the JWT, signed with the private key of a service account, that is
+// exchanged for an access token to Cloud Storage
+func serviceAccountAssertion(creds googleCredentialsFile, audience string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", errors.New("no PEM private key")
+	}
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return "", errors.New("private key is not an RSA key")
+		}
+	} else if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+		return "", err
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": gcsStorageScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	clusterOutput    string
+	clusterOutdir    string
+	clusterIdentity  float64
+	clusterMinLength int
+)
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster [flags] input_file...",
+	Short: "Cluster chains across PDB files by sequence identity",
+	Long: `Extract the chain sequences of a set of PDB files and cluster them by sequence identity, for
+building non-redundant structural datasets.
+
+Clustering is greedy, as in CD-HIT: chains are processed from the longest to the shortest sequence,
+and each chain joins the first cluster whose representative it matches with at least --identity
+sequence identity (relative to the shorter sequence), or else becomes the representative of a new
+cluster. Sequences are taken from the coordinate records of the first model, with modified residues
+written as their parent residue. Chains shorter than --min-length residues are left out.
+
+The output is a tab-separated table with one row per chain: the cluster number, the file, the chain,
+the sequence length, whether the chain is the cluster representative and its identity to the
+representative. With --outdir, the representative chains are also written as PDB files named
+{stem}_{chain}.pdb. Files that cannot be read are reported and skipped.
+
+Examples:
+  # Cluster all chains at 90% identity
+  pdbtk cluster structures/*.pdb > clusters.tsv
+
+  # Write a non-redundant set of chains at 30% identity
+  pdbtk cluster --identity 0.3 --min-length 30 --outdir nr30/ structures/*.pdb`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runCluster,
+}
+
+func init() {
+	clusterCmd.Flags().StringVarP(&clusterOutput, "output", "o", "", "Output file for the cluster table (default: stdout)")
+	clusterCmd.Flags().StringVar(&clusterOutdir, "outdir", "", "Write the representative chains as PDB files to this directory")
+	clusterCmd.Flags().Float64Var(&clusterIdentity, "identity", 0.9, "Minimum sequence identity (0-1) to the cluster representative")
+	clusterCmd.Flags().IntVar(&clusterMinLength, "min-length", 0, "Leave out chains with fewer residues than this")
+}
+
+// clusterMember is a chain assigned to a cluster
+type clusterMember struct {
+	File     string
+	ChainID  byte
+	Sequence string
+	Cluster  int
+	Identity float64
+}
+
+func runCluster(cmd *cobra.Command, args []string) error {
+	if clusterIdentity < 0 || clusterIdentity > 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --identity: %g (must be between 0 and 1)", clusterIdentity))
+	}
+	inputs, err := expandInputs(cmd.Context(), args)
+	if err != nil {
+		return err
+	}
+	for _, inputFile := range inputs {
+		if err := checkPDBExtension(inputFile); err != nil {
+			return withCode(errorCode(err), fmt.Errorf("%s: %v", inputFile, err))
+		}
+	}
+
+	var members []*clusterMember
+	files := make(map[string]*PDBFile)
+	var failures batchFailures
+	progress := newProgress("Reading", int64(len(inputs)), false)
+	for _, inputFile := range inputs {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		recordResult(inputFile, clusterOutput, err)
+		failures.add(inputFile, err)
+		progress.Add(1)
+		if err != nil {
+			continue
+		}
+		files[inputFile] = file
+		chainIDs, sequences := observedChainSequences(file)
+		for _, chainID := range chainIDs {
+			if len(sequences[chainID]) >= clusterMinLength {
+				members = append(members, &clusterMember{File: inputFile, ChainID: chainID, Sequence: sequences[chainID]})
+			}
+		}
+	}
+	progress.Finish()
+	if len(members) == 0 {
+		if err := failures.err(len(inputs)); err != nil {
+			return err
+		}
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no polymer chains found"))
+	}
+
+	representatives := clusterChains(members, clusterIdentity)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Clustered %d chains into %d clusters\n", len(members), len(representatives))
+
+	err = writeOutput(cmd.Context(), clusterOutput, func(w io.Writer) error {
+		fmt.Fprintln(w, "cluster\tfile\tchain\tlength\trepresentative\tidentity")
+		for _, member := range members {
+			representative := representatives[member.Cluster-1] == member
+			fmt.Fprintf(w, "%d\t%s\t%c\t%d\t%t\t%.3f\n", member.Cluster, member.File, member.ChainID,
+				len(member.Sequence), representative, member.Identity)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if clusterOutdir != "" {
+		if err := writeRepresentatives(cmd, representatives, files); err != nil {
+			return err
+		}
+	}
+	return failures.err(len(inputs))
+}
+
+// clusterChains assigns members to clusters greedily from the longest sequence to the shortest and
+// returns the cluster representatives. Members are left sorted by cluster, with the representative first.
+func clusterChains(members []*clusterMember, minIdentity float64) []*clusterMember {
+	sort.SliceStable(members, func(i, j int) bool {
+		return len(members[i].Sequence) > len(members[j].Sequence)
+	})
+
+	var representatives []*clusterMember
+	for _, member := range members {
+		for i, representative := range representatives {
+			if identity := sequenceIdentity(representative.Sequence, member.Sequence); identity >= minIdentity {
+				member.Cluster = i + 1
+				member.Identity = identity
+				break
+			}
+		}
+		if member.Cluster == 0 {
+			representatives = append(representatives, member)
+			member.Cluster = len(representatives)
+			member.Identity = 1
+		}
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Cluster < members[j].Cluster
+	})
+	return representatives
+}
+
+// writeRepresentatives writes each representative chain to its own PDB file in --outdir
+func writeRepresentatives(cmd *cobra.Command, representatives []*clusterMember, files map[string]*PDBFile) error {
+	if err := makeOutputDir(clusterOutdir); err != nil {
+		return err
+	}
+	for _, representative := range representatives {
+		file := files[representative.File]
+		var atoms []*AtomRecord
+		for _, atom := range file.Atoms {
+			if atom.ChainID == representative.ChainID {
+				atoms = append(atoms, atom)
+			}
+		}
+
+		name := inputBaseName(representative.File)
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		outputFile := joinOutputPath(clusterOutdir, fmt.Sprintf("%s_%c.pdb", stem, representative.ChainID))
+		err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+			return writePDBRecords(file.WithAtoms(atoms), w, recordCommandLine(cmd, nil, representative.File))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// identifierCollision is a chain or residue identifier that is used for more than one thing
+type identifierCollision struct {
+	Severity string
+	Message  string
+}
+
+// residueID identifies a residue by number and insertion code, without its name
+type residueID struct {
+	model   int
+	chainID byte
+	resSeq  int
+	iCode   byte
+}
+
+// findIdentifierCollisions reports chains whose polymer records appear in separate blocks of a model, and
+// residue numbers (with insertion code) used by more than one residue in a chain. Both silently break
+// commands that select or renumber by chain and residue number.
+func findIdentifierCollisions(atoms []*AtomRecord) []identifierCollision {
+	return findNamedIdentifierCollisions(atoms, nil)
+}
+
+// findNamedIdentifierCollisions is findIdentifierCollisions for atoms whose chain IDs stand for the longer
+// chain names in chainNames, e.g. the author chain IDs of an mmCIF file, which name them in the messages
+func findNamedIdentifierCollisions(atoms []*AtomRecord, chainNames map[byte]string) []identifierCollision {
+	var collisions []identifierCollision
+	chainLabel := func(chainID byte) string {
+		if name, ok := chainNames[chainID]; ok {
+			return name
+		}
+		return string(chainID)
+	}
+
+	// Polymer chains split into separate blocks. HETATM records are skipped, since ligands and waters are
+	// commonly listed after all polymer chains with the chain ID of the polymer they belong to.
+	type chainKey struct {
+		model   int
+		chainID byte
+	}
+	blocks := make(map[chainKey]int)
+	var blockOrder []chainKey
+	var last *AtomRecord
+	for _, atom := range atoms {
+		if atom.Het {
+			continue
+		}
+		if last == nil || last.Model != atom.Model || last.ChainID != atom.ChainID {
+			key := chainKey{atom.Model, atom.ChainID}
+			if blocks[key] == 0 {
+				blockOrder = append(blockOrder, key)
+			}
+			blocks[key]++
+		}
+		last = atom
+	}
+	for _, key := range blockOrder {
+		if blocks[key] > 1 {
+			collisions = append(collisions, identifierCollision{SeverityWarning,
+				fmt.Sprintf("chain %s appears in %d separate blocks in model %d", chainLabel(key.chainID), blocks[key], key.model)})
+		}
+	}
+
+	// Residue numbers reused by a later, non-adjacent run of records, or by a residue with a different
+	// name without alternate locations to explain it
+	type residueRun struct {
+		id      residueID
+		resName string
+		altLoc  bool
+	}
+	var runs []*residueRun
+	for i, atom := range atoms {
+		id := residueID{atom.Model, atom.ChainID, atom.ResSeq, atom.ICode}
+		if i == 0 || id != runs[len(runs)-1].id || atom.ResName != runs[len(runs)-1].resName {
+			runs = append(runs, &residueRun{id: id, resName: atom.ResName})
+		}
+		if atom.AltLoc != ' ' {
+			runs[len(runs)-1].altLoc = true
+		}
+	}
+
+	seen := make(map[residueID]*residueRun)
+	reported := make(map[residueID]bool)
+	var collided []residueID
+	for i, run := range runs {
+		if _, ok := seen[run.id]; !ok {
+			seen[run.id] = run
+			continue
+		}
+		adjacent := runs[i-1].id == run.id
+		if adjacent && run.altLoc && runs[i-1].altLoc {
+			// Microheterogeneity: alternate residue types at the same position
+			continue
+		}
+		if !reported[run.id] {
+			reported[run.id] = true
+			collided = append(collided, run.id)
+		}
+	}
+	for _, id := range collided {
+		key := ResidueKey{Model: id.model, ChainID: id.chainID, ResSeq: id.resSeq, ICode: id.iCode, ResName: seen[id].resName}
+		collisions = append(collisions, identifierCollision{SeverityError,
+			fmt.Sprintf("residue number %s:%s is used by more than one residue", chainLabel(id.chainID),
+				strings.TrimPrefix(residueLabel(key), string(id.chainID)+":"))})
+	}
+	return collisions
+}
+
+// residueLabel formats the chain, number and insertion code of a residue, e.g. "A:45B"
+func residueLabel(key ResidueKey) string {
+	icode := ""
+	if key.ICode != ' ' {
+		icode = string(key.ICode)
+	}
+	return fmt.Sprintf("%c:%d%s", key.ChainID, key.ResSeq, icode)
+}
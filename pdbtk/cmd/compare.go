@@ -0,0 +1,304 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	compareOutput      string
+	compareFormat      string
+	compareMinIdentity float64
+	compareCutoff      float64
+	compareAlignment   string
+	compareAlignFormat string
+	compareAlignCutoff float64
+)
+
+var compareCmd = &cobra.Command{
+	Use:   "compare [flags] structure_a.pdb structure_b.pdb",
+	Short: "Compare two structures after superposition",
+	Long: `Compare two structures and report how their chains correspond and how they differ.
+
+Chains of the first structure are mapped one-to-one to chains of the second by sequence identity
+(the best-matching pairs first, with at least --min-identity identity relative to the shorter
+sequence). The residues of mapped chains are paired by sequence alignment, and the structures are
+superposed on the CA atoms of all paired residues.
+
+The report lists the chain mapping with the sequence identity, number of paired residues and RMSD of
+each mapped chain, the overall CA RMSD, the paired residues that differ in identity (mutations) and
+the paired residues whose CA atoms are more than --cutoff apart after superposition (differences in
+conformation). Sequences and coordinates are taken from the first model of each file.
+
+--alignment writes the structure-based sequence alignment of each mapped chain pair, as FASTA or
+Stockholm (--alignment-format). Each chain pair is realigned on its superposed structures: residues
+are aligned where their CA atoms are within --alignment-cutoff, keeping the sequence order and
+preferring the closest pairs. Starting from the superposition of the comparison and from those of
+the best gapless alignments of the two chains, the superposition is refined on the aligned residues
+until the alignment no longer improves, and the best alignment is kept. For distant homologs this is
+usually more accurate than the sequence alignment; use a low --min-identity so that their chains
+are mapped.
+
+Examples:
+  # Compare a model with an experimental structure
+  pdbtk compare model.pdb 1a02.pdb
+
+  # Report as JSON, listing residues that moved by more than 1 Å
+  pdbtk compare --cutoff 1 --format json apo.pdb holo.pdb
+
+  # Write the structure-based alignment of two homologs
+  pdbtk compare --min-identity 0 --alignment aln.sto --alignment-format stockholm 1a02.pdb 2b3c.pdb`,
+	Args: cobra.ExactArgs(2),
+	RunE: runCompare,
+}
+
+func init() {
+	compareCmd.Flags().StringVarP(&compareOutput, "output", "o", "", "Output file (default: stdout)")
+	compareCmd.Flags().StringVarP(&compareFormat, "format", "f", "text", "Output format: text or json")
+	compareCmd.Flags().Float64Var(&compareMinIdentity, "min-identity", 0.3, "Minimum sequence identity (0-1) for two chains to be mapped")
+	compareCmd.Flags().Float64Var(&compareCutoff, "cutoff", 2.0, "Report paired residues whose CA atoms are further apart than this (Å)")
+	compareCmd.Flags().StringVar(&compareAlignment, "alignment", "", "Write the structure-based alignment of the mapped chains to this file")
+	compareCmd.Flags().StringVar(&compareAlignFormat, "alignment-format", "fasta", "Format of the --alignment file: fasta or stockholm")
+	compareCmd.Flags().Float64Var(&compareAlignCutoff, "alignment-cutoff", 4.0, "Align residues whose CA atoms are at most this far apart after superposition (Å)")
+}
+
+// chainMapping is a pair of corresponding chains of two structures
+type chainMapping struct {
+	ChainA   string  `json:"chain_a"`
+	ChainB   string  `json:"chain_b"`
+	Identity float64 `json:"identity"`
+	Paired   int     `json:"paired_residues"`
+	RMSD     float64 `json:"rmsd"`
+}
+
+// residueDifference is a pair of corresponding residues that differ
+type residueDifference struct {
+	ResidueA string  `json:"residue_a"`
+	ResidueB string  `json:"residue_b"`
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// comparisonReport is the report written by compare
+type comparisonReport struct {
+	FileA                   string              `json:"file_a"`
+	FileB                   string              `json:"file_b"`
+	Chains                  []chainMapping      `json:"chains"`
+	UnmappedA               []string            `json:"unmapped_chains_a"`
+	UnmappedB               []string            `json:"unmapped_chains_b"`
+	PairedResidues          int                 `json:"paired_residues"`
+	RMSD                    float64             `json:"rmsd"`
+	SequenceDifferences     []residueDifference `json:"sequence_differences"`
+	ConformationDifferences []residueDifference `json:"conformation_differences"`
+
+	transform rigidTransform // superposition of a onto b
+}
+
+// residuePair is a pair of corresponding residues with their CA positions
+type residuePair struct {
+	A, B       *ResidueKey
+	CodeA      byte
+	CodeB      byte
+	CAA, CAB   vec3
+	chainIndex int
+}
+
+func runCompare(cmd *cobra.Command, args []string) error {
+	if compareFormat != "text" && compareFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", compareFormat))
+	}
+	if compareMinIdentity < 0 || compareMinIdentity > 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --min-identity: %g (must be between 0 and 1)", compareMinIdentity))
+	}
+	if compareAlignFormat != "fasta" && compareAlignFormat != "stockholm" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --alignment-format: %s (must be fasta or stockholm)", compareAlignFormat))
+	}
+	if compareAlignCutoff <= 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --alignment-cutoff: %g (must be positive)", compareAlignCutoff))
+	}
+
+	var files [2]*PDBFile
+	for i, inputFile := range args {
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return withCode(errorCode(err), fmt.Errorf("%s: %v", inputFile, err))
+		}
+		files[i] = file
+	}
+
+	report, err := compareStructures(files[0], files[1], compareMinIdentity, compareCutoff)
+	if err != nil {
+		return err
+	}
+	report.FileA, report.FileB = args[0], args[1]
+
+	if compareAlignment != "" {
+		alignments := structuralAlignments(files[0], files[1], args[0], args[1], report, compareAlignCutoff)
+		err := writeOutput(cmd.Context(), compareAlignment, func(w io.Writer) error {
+			return writeStructuralAlignments(w, alignments, compareAlignFormat, compareAlignCutoff)
+		})
+		if err != nil {
+			return withCode(ErrCodeIO, fmt.Errorf("failed to write alignment: %v", err))
+		}
+	}
+
+	return writeOutput(cmd.Context(), compareOutput, func(w io.Writer) error {
+		if compareFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+		return writeComparisonText(report, w)
+	})
+}
+
+// caPositions returns the CA position of each residue of the first model
+func caPositions(file *PDBFile) map[ResidueKey]vec3 {
+	positions := make(map[ResidueKey]vec3)
+	models := file.Models()
+	for _, atom := range file.Atoms {
+		if atom.Model != models[0] || atom.Name != "CA" {
+			continue
+		}
+		if _, seen := positions[atom.Residue()]; !seen {
+			positions[atom.Residue()] = atom.Coord()
+		}
+	}
+	return positions
+}
+
+// compareStructures maps the chains of a to those of b, superposes the paired residues and lists their differences
+func compareStructures(a, b *PDBFile, minIdentity, cutoff float64) (*comparisonReport, error) {
+	chainsA, positionsA := polymerPositions(a)
+	chainsB, positionsB := polymerPositions(b)
+	caA, caB := caPositions(a), caPositions(b)
+
+	type candidate struct {
+		a, b     byte
+		identity float64
+	}
+	var candidates []candidate
+	for _, chainA := range chainsA {
+		for _, chainB := range chainsB {
+			identity := sequenceIdentity(positionsToString(positionsA[chainA]), positionsToString(positionsB[chainB]))
+			if identity >= minIdentity {
+				candidates = append(candidates, candidate{chainA, chainB, identity})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].identity > candidates[j].identity
+	})
+
+	report := &comparisonReport{
+		Chains:                  make([]chainMapping, 0),
+		UnmappedA:               make([]string, 0),
+		UnmappedB:               make([]string, 0),
+		SequenceDifferences:     make([]residueDifference, 0),
+		ConformationDifferences: make([]residueDifference, 0),
+	}
+	mappedA, mappedB := make(map[byte]bool), make(map[byte]bool)
+	var pairs []residuePair
+	for _, c := range candidates {
+		if mappedA[c.a] || mappedB[c.b] {
+			continue
+		}
+		mappedA[c.a], mappedB[c.b] = true, true
+
+		seqA, seqB := positionsA[c.a], positionsB[c.b]
+		paired := 0
+		for _, pair := range alignSequences(positionsToString(seqA), positionsToString(seqB)) {
+			if pair.A < 0 || pair.B < 0 {
+				continue
+			}
+			p := residuePair{A: seqA[pair.A].Residue, B: seqB[pair.B].Residue, CodeA: seqA[pair.A].Code,
+				CodeB: seqB[pair.B].Code, chainIndex: len(report.Chains)}
+			posA, okA := caA[*p.A]
+			posB, okB := caB[*p.B]
+			if !okA || !okB {
+				continue
+			}
+			p.CAA, p.CAB = posA, posB
+			pairs = append(pairs, p)
+			paired++
+		}
+		report.Chains = append(report.Chains, chainMapping{ChainA: string(c.a), ChainB: string(c.b),
+			Identity: roundTo(c.identity, 3), Paired: paired})
+	}
+	for _, chainID := range chainsA {
+		if !mappedA[chainID] {
+			report.UnmappedA = append(report.UnmappedA, string(chainID))
+		}
+	}
+	for _, chainID := range chainsB {
+		if !mappedB[chainID] {
+			report.UnmappedB = append(report.UnmappedB, string(chainID))
+		}
+	}
+	if len(pairs) < 3 {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("too few corresponding residues to superpose the structures (%d)", len(pairs)))
+	}
+
+	mobile, target := make([]vec3, len(pairs)), make([]vec3, len(pairs))
+	for i, p := range pairs {
+		mobile[i], target[i] = p.CAA, p.CAB
+	}
+	transform := superposition(mobile, target)
+	report.transform = transform
+	for i := range mobile {
+		mobile[i] = transform.apply(mobile[i])
+	}
+	report.PairedResidues = len(pairs)
+	report.RMSD = roundTo(rmsd(mobile, target), 3)
+
+	chainSums := make([]float64, len(report.Chains))
+	for i, p := range pairs {
+		d := distance(mobile[i], target[i])
+		chainSums[p.chainIndex] += d * d
+		if p.A.ResName != p.B.ResName {
+			report.SequenceDifferences = append(report.SequenceDifferences, residueDifference{ResidueA: p.A.String(), ResidueB: p.B.String()})
+		}
+		if d > cutoff {
+			report.ConformationDifferences = append(report.ConformationDifferences,
+				residueDifference{ResidueA: p.A.String(), ResidueB: p.B.String(), Distance: roundTo(d, 3)})
+		}
+	}
+	for i := range report.Chains {
+		if report.Chains[i].Paired > 0 {
+			report.Chains[i].RMSD = roundTo(math.Sqrt(chainSums[i]/float64(report.Chains[i].Paired)), 3)
+		}
+	}
+	return report, nil
+}
+
+func writeComparisonText(report *comparisonReport, w io.Writer) error {
+	fmt.Fprintf(w, "%s vs %s: %d paired residues, CA RMSD %.3f Å\n", report.FileA, report.FileB, report.PairedResidues, report.RMSD)
+	fmt.Fprintf(w, "\nChain mapping:\n")
+	for _, c := range report.Chains {
+		fmt.Fprintf(w, "  %s -> %s: identity %.1f%%, %d paired residues, RMSD %.3f Å\n", c.ChainA, c.ChainB, c.Identity*100, c.Paired, c.RMSD)
+	}
+	if len(report.UnmappedA) > 0 {
+		fmt.Fprintf(w, "  unmapped in %s: %s\n", report.FileA, strings.Join(report.UnmappedA, ","))
+	}
+	if len(report.UnmappedB) > 0 {
+		fmt.Fprintf(w, "  unmapped in %s: %s\n", report.FileB, strings.Join(report.UnmappedB, ","))
+	}
+
+	fmt.Fprintf(w, "\nResidues differing in identity: %d\n", len(report.SequenceDifferences))
+	for _, d := range report.SequenceDifferences {
+		fmt.Fprintf(w, "  %s -> %s\n", d.ResidueA, d.ResidueB)
+	}
+	fmt.Fprintf(w, "\nResidues differing in conformation (CA > %.2f Å): %d\n", compareCutoff, len(report.ConformationDifferences))
+	for _, d := range report.ConformationDifferences {
+		fmt.Fprintf(w, "  %s -> %s: %.3f Å\n", d.ResidueA, d.ResidueB, d.Distance)
+	}
+	return nil
+}
@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// structuralRefinements is the largest number of times the superposition is refined on the
+// structure-based alignment
+const structuralRefinements = 5
+
+// gaplessSeeds is the number of the best gapless alignments of two chains that are refined, in
+// addition to the superposition of the comparison
+const gaplessSeeds = 3
+
+// chainAlignment is the structure-based alignment of a pair of mapped chains
+type chainAlignment struct {
+	IDA, IDB string
+	A, B     string // aligned sequences, with '-' for gaps
+	Aligned  int
+	RMSD     float64
+}
+
+// structuralAlignments aligns the residues of each pair of mapped chains of a and b by the distances
+// of their CA atoms after superposition
+func structuralAlignments(a, b *PDBFile, fileA, fileB string, report *comparisonReport, cutoff float64) []chainAlignment {
+	_, positionsA := polymerPositions(a)
+	_, positionsB := polymerPositions(b)
+	caA, caB := caPositions(a), caPositions(b)
+
+	ids := make(fastaIDs)
+	var results []chainAlignment
+	for _, c := range report.Chains {
+		seqA, seqB := positionsA[c.ChainA[0]], positionsB[c.ChainB[0]]
+		pairs, transform := alignChainStructures(seqA, seqB, caA, caB, report.transform, cutoff)
+		result := chainAlignment{
+			IDA: ids.unique(renderIDTemplate("{file}_{chain}", fileA, "", c.ChainA[0], nil, 0)),
+			IDB: ids.unique(renderIDTemplate("{file}_{chain}", fileB, "", c.ChainB[0], nil, 0)),
+		}
+		var lineA, lineB strings.Builder
+		var mobile, target []vec3
+		for _, p := range pairs {
+			codeA, codeB := byte('-'), byte('-')
+			if p.A >= 0 {
+				codeA = seqA[p.A].Code
+			}
+			if p.B >= 0 {
+				codeB = seqB[p.B].Code
+			}
+			if p.A >= 0 && p.B >= 0 {
+				mobile = append(mobile, transform.apply(caA[*seqA[p.A].Residue]))
+				target = append(target, caB[*seqB[p.B].Residue])
+			}
+			lineA.WriteByte(codeA)
+			lineB.WriteByte(codeB)
+		}
+		result.A, result.B = lineA.String(), lineB.String()
+		result.Aligned = len(mobile)
+		result.RMSD = roundTo(rmsd(mobile, target), 3)
+		results = append(results, result)
+	}
+	return results
+}
+
+// alignChainStructures finds the structure-based alignment of two chains and the superposition it
+// implies. The superposition of the comparison and those of the best gapless alignments of the chains
+// are each refined on the aligned residues until the alignment no longer changes, and the alignment
+// with the highest score is kept.
+func alignChainStructures(seqA, seqB []sequencePosition, caA, caB map[ResidueKey]vec3, initial rigidTransform, cutoff float64) ([]alignedPair, rigidTransform) {
+	seeds := append([]rigidTransform{initial}, gaplessSuperpositions(seqA, seqB, caA, caB, cutoff)...)
+	var best []alignedPair
+	var bestTransform rigidTransform
+	bestScore := -1.0
+	for _, transform := range seeds {
+		pairs, score := alignByDistance(seqA, seqB, caA, caB, transform, cutoff)
+		for refinement := 0; refinement < structuralRefinements; refinement++ {
+			var mobile, target []vec3
+			for _, p := range pairs {
+				if p.A >= 0 && p.B >= 0 {
+					mobile = append(mobile, caA[*seqA[p.A].Residue])
+					target = append(target, caB[*seqB[p.B].Residue])
+				}
+			}
+			if len(mobile) < 3 {
+				break
+			}
+			refined := superposition(mobile, target)
+			refinedPairs, refinedScore := alignByDistance(seqA, seqB, caA, caB, refined, cutoff)
+			if refinedScore <= score {
+				break
+			}
+			pairs, score, transform = refinedPairs, refinedScore, refined
+		}
+		if score > bestScore {
+			best, bestTransform, bestScore = pairs, transform, score
+		}
+	}
+	return best, bestTransform
+}
+
+// gaplessSuperpositions returns the superpositions of the best-scoring gapless alignments of two chains
+// (one chain shifted along the other), which overlap by at least half of the shorter chain
+func gaplessSuperpositions(seqA, seqB []sequencePosition, caA, caB map[ResidueKey]vec3, cutoff float64) []rigidTransform {
+	type seed struct {
+		transform rigidTransform
+		score     float64
+	}
+	minOverlap := max(3, min(len(seqA), len(seqB))/2)
+	var seeds []seed
+	for offset := -len(seqA) + 1; offset < len(seqB); offset++ {
+		var mobile, target []vec3
+		for i := range seqA {
+			j := i + offset
+			if j < 0 || j >= len(seqB) {
+				continue
+			}
+			p, okA := caA[*seqA[i].Residue]
+			q, okB := caB[*seqB[j].Residue]
+			if okA && okB {
+				mobile, target = append(mobile, p), append(target, q)
+			}
+		}
+		if len(mobile) < minOverlap {
+			continue
+		}
+		transform := superposition(mobile, target)
+		score := 0.0
+		for k := range mobile {
+			score += distanceScore(distance(transform.apply(mobile[k]), target[k]), cutoff)
+		}
+		seeds = append(seeds, seed{transform, score})
+	}
+	sort.SliceStable(seeds, func(i, j int) bool { return seeds[i].score > seeds[j].score })
+	var transforms []rigidTransform
+	for i := 0; i < len(seeds) && i < gaplessSeeds; i++ {
+		transforms = append(transforms, seeds[i].transform)
+	}
+	return transforms
+}
+
+// distanceScore is the score of a pair of residues whose CA atoms are d apart: 1/(1+(d/d0)²), with d0
+// half the alignment cutoff
+func distanceScore(d, cutoff float64) float64 {
+	r := d / (cutoff / 2)
+	return 1 / (1 + r*r)
+}
+
+// alignByDistance aligns two chains by the distances of their CA atoms after transforming those of a:
+// the order-preserving set of residue pairs within cutoff with the highest total distanceScore, which
+// it also returns. Gaps are not penalised, and residues without a CA atom are not aligned.
+func alignByDistance(seqA, seqB []sequencePosition, caA, caB map[ResidueKey]vec3, transform rigidTransform, cutoff float64) ([]alignedPair, float64) {
+	n, m := len(seqA), len(seqB)
+	pointsA := make([]*vec3, n)
+	for i, position := range seqA {
+		if p, ok := caA[*position.Residue]; ok {
+			moved := transform.apply(p)
+			pointsA[i] = &moved
+		}
+	}
+	pointsB := make([]*vec3, m)
+	for j, position := range seqB {
+		if p, ok := caB[*position.Residue]; ok {
+			pointsB[j] = &p
+		}
+	}
+	pair := func(i, j int) (float64, bool) {
+		if pointsA[i] == nil || pointsB[j] == nil {
+			return 0, false
+		}
+		d := distance(*pointsA[i], *pointsB[j])
+		if d > cutoff {
+			return 0, false
+		}
+		return distanceScore(d, cutoff), true
+	}
+
+	score := make([][]float64, n+1)
+	for i := range score {
+		score[i] = make([]float64, m+1)
+	}
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			score[i][j] = max(score[i-1][j], score[i][j-1])
+			if s, ok := pair(i-1, j-1); ok && score[i-1][j-1]+s > score[i][j] {
+				score[i][j] = score[i-1][j-1] + s
+			}
+		}
+	}
+
+	var reversed []alignedPair
+	i, j := n, m
+	for i > 0 || j > 0 {
+		if i > 0 && j > 0 {
+			if s, ok := pair(i-1, j-1); ok && score[i][j] == score[i-1][j-1]+s {
+				reversed = append(reversed, alignedPair{A: i - 1, B: j - 1})
+				i, j = i-1, j-1
+				continue
+			}
+		}
+		if i > 0 && (j == 0 || score[i][j] == score[i-1][j]) {
+			reversed = append(reversed, alignedPair{A: i - 1, B: -1})
+			i--
+		} else {
+			reversed = append(reversed, alignedPair{A: -1, B: j - 1})
+			j--
+		}
+	}
+	pairs := make([]alignedPair, len(reversed))
+	for k, p := range reversed {
+		pairs[len(reversed)-1-k] = p
+	}
+	return pairs, score[n][m]
+}
+
+// writeStructuralAlignments writes the alignments of the mapped chains as FASTA or Stockholm
+func writeStructuralAlignments(w io.Writer, alignments []chainAlignment, format string, cutoff float64) error {
+	if format == "fasta" {
+		for _, a := range alignments {
+			fmt.Fprintf(w, ">%s\n%s\n>%s\n%s\n", a.IDA, a.A, a.IDB, a.B)
+		}
+		return nil
+	}
+	for _, a := range alignments {
+		width := len(a.IDA)
+		if len(a.IDB) > width {
+			width = len(a.IDB)
+		}
+		fmt.Fprintln(w, "# STOCKHOLM 1.0")
+		fmt.Fprintf(w, "#=GF DE Structure-based alignment of %s and %s: %d residues with CA atoms within %.1f Å, RMSD %.3f Å\n",
+			a.IDA, a.IDB, a.Aligned, cutoff, a.RMSD)
+		fmt.Fprintf(w, "%-*s  %s\n%-*s  %s\n//\n", width, a.IDA, a.A, width, a.IDB, a.B)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// flagCompletion completes the values of a flag from an input file
+type flagCompletion struct {
+	values func(file *PDBFile) []string
+	list   bool // the flag takes a comma-separated list, so no space is added after a value
+}
+
+// completionFlags are the flags whose values are completed from the input file on the command line:
+// chain IDs, or the residue names of het components
+var completionFlags = map[string]flagCompletion{
+	"chains": {chainCompletions, true},
+	"chain":  {chainCompletions, false},
+	"ligand": {hetCompletions, true},
+	"het":    {hetCompletions, false},
+}
+
+// registerCompletions registers the dynamic completions of completionFlags on every command that has
+// them. Shell scripts that use them are generated by the completion command.
+func registerCompletions(cmd *cobra.Command) {
+	for name, completion := range completionFlags {
+		if cmd.Flags().Lookup(name) == nil {
+			continue
+		}
+		completion := completion
+		_ = cmd.RegisterFlagCompletionFunc(name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return completeFromInput(cmd.Context(), args, toComplete, completion)
+		})
+	}
+	for _, sub := range cmd.Commands() {
+		registerCompletions(sub)
+	}
+}
+
+// completeFromInput completes the last entry of a comma-separated flag value with the values found in
+// the first local file among args (those before the cursor). Without a readable input file nothing is
+// suggested, so that completion never waits on stdin or the network.
+func completeFromInput(ctx context.Context, args []string, toComplete string, completion flagCompletion) ([]string, cobra.ShellCompDirective) {
+	var file *PDBFile
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if parsed, err := readInputRecords(ctx, arg); err == nil {
+			file = parsed
+			break
+		}
+	}
+	if file == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	prefix, last := "", toComplete
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, last = toComplete[:i+1], toComplete[i+1:]
+	}
+	given := strings.Split(prefix, ",")
+	var completions []string
+	for _, value := range completion.values(file) {
+		name, _, _ := strings.Cut(value, "\t")
+		if strings.HasPrefix(name, last) && !containsString(given, name) {
+			completions = append(completions, prefix+value)
+		}
+	}
+	if completion.list {
+		return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// chainCompletions returns the chain IDs of the first model of a file, in file order, described by
+// their number of residues
+func chainCompletions(file *PDBFile) []string {
+	counts := make(map[byte]int)
+	var order []byte
+	firstModel := file.Models()[0]
+	for _, residue := range groupResidues(file.Atoms) {
+		key := residue[0].Residue()
+		if key.Model != firstModel || waterResidues[key.ResName] {
+			continue
+		}
+		if _, ok := counts[key.ChainID]; !ok {
+			order = append(order, key.ChainID)
+		}
+		counts[key.ChainID]++
+	}
+	var completions []string
+	for _, chainID := range order {
+		description := fmt.Sprintf("%d residues", counts[chainID])
+		if counts[chainID] == 1 {
+			description = "1 residue"
+		}
+		if chainID != ' ' {
+			completions = append(completions, string(chainID)+"\t"+description)
+		}
+	}
+	return completions
+}
+
+// hetCompletions returns the residue names of the het components of the first model of a file that
+// are not waters or part of a polymer chain, described by their number of copies
+func hetCompletions(file *PDBFile) []string {
+	counts := make(map[string]int)
+	var order []string
+	firstModel := file.Models()[0]
+	for key := range ligandResidues(file.Atoms) {
+		if key.Model != firstModel {
+			continue
+		}
+		if _, ok := counts[key.ResName]; !ok {
+			order = append(order, key.ResName)
+		}
+		counts[key.ResName]++
+	}
+	sort.Strings(order)
+	var completions []string
+	for _, resName := range order {
+		description := fmt.Sprintf("%d copies", counts[resName])
+		if counts[resName] == 1 {
+			description = "1 copy"
+		}
+		completions = append(completions, resName+"\t"+description)
+	}
+	return completions
+}
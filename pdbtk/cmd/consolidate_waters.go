@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	consolidateOutput   string
+	consolidateChain    string
+	consolidatePerChain bool
+	consolidateStart    int
+	consolidateBatch    batchOptions
+)
+
+var consolidateWatersCmd = &cobra.Command{
+	Use:   "consolidate-waters [flags] [input_file...]",
+	Short: "Move waters into solvent chains and renumber them sequentially",
+	Long: `Move all water molecules (HOH, WAT, DOD, H2O and SOL residues) into one solvent chain, or into the chain
+of their nearest polymer chain, and renumber them sequentially. This fixes the scattered and duplicated
+water numbering of many deposited entries.
+
+By default the waters are moved to chain W (--chain). With --per-chain, each water takes the chain ID of
+the polymer chain with the atom nearest to its first atom, as in wwPDB entries. Waters are numbered in
+file order from --start, which defaults to one after the last residue number of the other residues of
+their chain (or 1). Waters that share a residue number are told apart by their atoms: a repeated atom
+name starts a new water. The waters are written after the other atoms of each model, and LINK records
+of waters are updated to the new numbering (a duplicated number refers to the first of its waters).
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Move all waters to chain W, numbered from 1
+  pdbtk consolidate-waters 1a02.pdb --output 1a02_waters.pdb
+
+  # Give each water the chain ID of its nearest protein chain
+  pdbtk consolidate-waters --per-chain 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runConsolidateWaters,
+}
+
+func init() {
+	consolidateWatersCmd.Flags().StringVarP(&consolidateOutput, "output", "o", "", "Output file (default: stdout)")
+	consolidateWatersCmd.Flags().StringVarP(&consolidateChain, "chain", "c", "W", "Chain ID to move the waters to")
+	consolidateWatersCmd.Flags().BoolVar(&consolidatePerChain, "per-chain", false, "Move each water to the chain of its nearest polymer chain")
+	consolidateWatersCmd.Flags().IntVarP(&consolidateStart, "start", "s", 0, "First water residue number (default: after the last residue of the chain)")
+	addBatchFlags(consolidateWatersCmd, &consolidateBatch, "{name}")
+}
+
+func runConsolidateWaters(cmd *cobra.Command, args []string) error {
+	if len(consolidateChain) != 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --chain: %q (must be a single character)", consolidateChain))
+	}
+	if consolidatePerChain && cmd.Flags().Changed("chain") {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--chain and --per-chain cannot be combined"))
+	}
+	autoStart := !cmd.Flags().Changed("start")
+
+	return runBatch(cmd.Context(), args, consolidateOutput, consolidateBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+
+		firstModel := file.Models()[0]
+		renumbered := make(map[ResidueKey]ResidueKey)
+		var atoms []*AtomRecord
+		moved := 0
+		var chains []string
+		for _, model := range file.Models() {
+			var others []*AtomRecord
+			var waters [][]*AtomRecord
+			for _, atom := range file.Atoms {
+				if atom.Model != model {
+					continue
+				}
+				if !waterResidues[atom.ResName] {
+					others = append(others, atom)
+				} else if n := len(waters); n == 0 || newWater(waters[n-1], atom) {
+					waters = append(waters, []*AtomRecord{atom})
+				} else {
+					waters[n-1] = append(waters[n-1], atom)
+				}
+			}
+
+			// The chain of each water
+			targets := make([]byte, len(waters))
+			var polymer []*AtomRecord
+			if consolidatePerChain {
+				for _, residue := range groupResidues(others) {
+					if _, standard := oneLetterCode(residue[0].ResName); standard || isPolymerResidue(residue) {
+						polymer = append(polymer, residue...)
+					}
+				}
+				if len(polymer) == 0 && len(waters) > 0 {
+					return withCode(ErrCodeNoMatch, fmt.Errorf("no polymer chains to assign the waters to"))
+				}
+			}
+			for i, water := range waters {
+				targets[i] = consolidateChain[0]
+				if consolidatePerChain {
+					targets[i] = nearestChain(water[0].Coord(), polymer)
+				}
+			}
+			// The next number in each chain; solvent chains follow the order of the chains in the file
+			var order []byte
+			for _, atom := range others {
+				if !strings.ContainsRune(string(order), rune(atom.ChainID)) {
+					order = append(order, atom.ChainID)
+				}
+			}
+			next := make(map[byte]int)
+			for _, chainID := range targets {
+				if _, ok := next[chainID]; ok {
+					continue
+				}
+				if !strings.ContainsRune(string(order), rune(chainID)) {
+					order = append(order, chainID)
+				}
+				next[chainID] = consolidateStart
+				if autoStart {
+					next[chainID] = 1
+					for _, atom := range others {
+						if atom.ChainID == chainID && atom.ResSeq >= next[chainID] {
+							next[chainID] = atom.ResSeq + 1
+						}
+					}
+				}
+			}
+
+			atoms = append(atoms, others...)
+			for _, chainID := range order {
+				if _, ok := next[chainID]; !ok {
+					continue
+				}
+				for i, water := range waters {
+					if targets[i] != chainID {
+						continue
+					}
+					old := water[0].Residue()
+					for _, atom := range water {
+						copied := *atom
+						copied.ChainID, copied.ResSeq, copied.ICode = chainID, next[chainID], ' '
+						atoms = append(atoms, &copied)
+					}
+					if model == firstModel {
+						if _, ok := renumbered[old]; !ok {
+							renumbered[old] = atoms[len(atoms)-1].Residue()
+						}
+						moved++
+					}
+					next[chainID]++
+				}
+				if model == firstModel {
+					chains = append(chains, string(chainID))
+				}
+			}
+		}
+		if moved == 0 {
+			if err := warn("no waters found"); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Moved %d waters to chains %s\n", moved, strings.Join(chains, ","))
+
+		header := renumberWaterLinks(file.Header, renumbered)
+		return writePDBRecords(&PDBFile{Header: header, Atoms: atoms, Conect: file.Conect}, writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// newWater reports whether a water atom starts a new water molecule after the atoms of water: when its
+// residue differs, or when it repeats an atom name (other than as an alternate location)
+func newWater(water []*AtomRecord, atom *AtomRecord) bool {
+	if atom.Residue() != water[0].Residue() {
+		return true
+	}
+	for _, other := range water {
+		if other.Name == atom.Name && (other.AltLoc == atom.AltLoc || other.AltLoc == ' ' || atom.AltLoc == ' ') {
+			return true
+		}
+	}
+	return false
+}
+
+// nearestChain returns the chain ID of the atom nearest to p
+func nearestChain(p vec3, atoms []*AtomRecord) byte {
+	best, chainID := math.Inf(1), byte(' ')
+	for _, atom := range atoms {
+		if d := distance(p, atom.Coord()); d < best {
+			best, chainID = d, atom.ChainID
+		}
+	}
+	return chainID
+}
+
+// renumberWaterLinks updates the water residues of LINK records to their new chains and numbers
+func renumberWaterLinks(header []string, renumbered map[ResidueKey]ResidueKey) []string {
+	result := make([]string, len(header))
+	for i, line := range header {
+		result[i] = line
+		if !strings.HasPrefix(line, "LINK  ") || len(line) < 57 {
+			continue
+		}
+		for _, at := range []int{17, 47} {
+			resName := strings.TrimSpace(line[at : at+3])
+			if !waterResidues[resName] {
+				continue
+			}
+			position := recordPosition(line[at+5:at+9], line[at+9])
+			for old, key := range renumbered {
+				if old.ChainID == line[at+4] && old.ResSeq == position.ResSeq && old.ICode == position.ICode && old.ResName == resName {
+					line = line[:at+4] + fmt.Sprintf("%c%4d%c", key.ChainID, key.ResSeq, key.ICode) + line[at+10:]
+					break
+				}
+			}
+		}
+		result[i] = line
+	}
+	return result
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// unitCell is the unit cell and space group of a crystal structure, as in a CRYST1 record
+type unitCell struct {
+	A          float64 `json:"a"` // edge lengths (Å)
+	B          float64 `json:"b"`
+	C          float64 `json:"c"`
+	Alpha      float64 `json:"alpha"` // angles (degrees)
+	Beta       float64 `json:"beta"`
+	Gamma      float64 `json:"gamma"`
+	SpaceGroup string  `json:"space_group"` // Hermann-Mauguin symbol, e.g. "P 21 21 21"
+	Z          int     `json:"z"`           // polymeric chains per unit cell
+}
+
+// crystalRecordNames are the header records that describe the crystal, kept through every command
+var crystalRecordNames = []string{"CRYST1", "ORIGX1", "ORIGX2", "ORIGX3", "SCALE1", "SCALE2", "SCALE3"}
+
+// isCrystalRecord reports whether a header record is a CRYST1, ORIGXn or SCALEn record
+func isCrystalRecord(line string) bool {
+	for _, name := range crystalRecordNames {
+		if strings.HasPrefix(line, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// crystalRecords returns the CRYST1, ORIGXn and SCALEn records of header records
+func crystalRecords(header []string) []string {
+	var records []string
+	for _, line := range header {
+		if isCrystalRecord(line) {
+			records = append(records, line)
+		}
+	}
+	return records
+}
+
+// parseCryst1 parses a CRYST1 record
+func parseCryst1(line string) (*unitCell, error) {
+	var values [6]float64
+	columns := [6][2]int{{6, 15}, {15, 24}, {24, 33}, {33, 40}, {40, 47}, {47, 54}}
+	for i, c := range columns {
+		v, err := strconv.ParseFloat(strings.TrimSpace(safeColumns(line, c[0], c[1])), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CRYST1 record: %q", line)
+		}
+		values[i] = v
+	}
+	cell := &unitCell{A: values[0], B: values[1], C: values[2], Alpha: values[3], Beta: values[4], Gamma: values[5],
+		SpaceGroup: strings.TrimSpace(safeColumns(line, 55, 66))}
+	if z, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 66, 70))); err == nil {
+		cell.Z = z
+	}
+	return cell, nil
+}
+
+// headerCell returns the unit cell of the CRYST1 record of header records, or nil if there is none
+func headerCell(header []string) (*unitCell, error) {
+	for _, line := range header {
+		if strings.HasPrefix(line, "CRYST1") {
+			return parseCryst1(line)
+		}
+	}
+	return nil, nil
+}
+
+// cifCell returns the unit cell of the _cell and _symmetry categories of an mmCIF data block, or nil if
+// it has none
+func cifCell(block *CIFBlock) *unitCell {
+	rows := block.Category("_cell")
+	if len(rows) == 0 {
+		return nil
+	}
+	var values [6]float64
+	for i, item := range []string{"length_a", "length_b", "length_c", "angle_alpha", "angle_beta", "angle_gamma"} {
+		v, err := strconv.ParseFloat(rows[0][item], 64)
+		if err != nil {
+			return nil
+		}
+		values[i] = v
+	}
+	cell := &unitCell{A: values[0], B: values[1], C: values[2], Alpha: values[3], Beta: values[4], Gamma: values[5], SpaceGroup: "P 1"}
+	if symmetry := block.Category("_symmetry"); len(symmetry) > 0 && cifRowValue(symmetry[0], "space_group_name_H-M") != "" {
+		cell.SpaceGroup = cifRowValue(symmetry[0], "space_group_name_H-M")
+	}
+	if z, err := strconv.Atoi(rows[0]["Z_PDB"]); err == nil {
+		cell.Z = z
+	}
+	return cell
+}
+
+// cryst1 formats the cell as a CRYST1 record
+func (c *unitCell) cryst1() string {
+	return fmt.Sprintf("CRYST1%9.3f%9.3f%9.3f%7.2f%7.2f%7.2f %-11s%4d", c.A, c.B, c.C, c.Alpha, c.Beta, c.Gamma, c.SpaceGroup, c.Z)
+}
+
+// orthogonalization returns the matrix that converts fractional coordinates to Cartesian coordinates,
+// with a along x and b in the xy plane (the PDB convention)
+func (c *unitCell) orthogonalization() [3][3]float64 {
+	rad := math.Pi / 180
+	cosA, cosB, cosG := math.Cos(c.Alpha*rad), math.Cos(c.Beta*rad), math.Cos(c.Gamma*rad)
+	sinG := math.Sin(c.Gamma * rad)
+	volume := c.A * c.B * c.C * math.Sqrt(1-cosA*cosA-cosB*cosB-cosG*cosG+2*cosA*cosB*cosG)
+	return [3][3]float64{
+		{c.A, c.B * cosG, c.C * cosB},
+		{0, c.B * sinG, c.C * (cosA - cosB*cosG) / sinG},
+		{0, 0, volume / (c.A * c.B * sinG)},
+	}
+}
+
+// fractionalization returns the matrix that converts Cartesian coordinates to fractional coordinates,
+// the inverse of the (upper triangular) orthogonalization matrix
+func (c *unitCell) fractionalization() [3][3]float64 {
+	m := c.orthogonalization()
+	return [3][3]float64{
+		{1 / m[0][0], -m[0][1] / (m[0][0] * m[1][1]), (m[0][1]*m[1][2] - m[0][2]*m[1][1]) / (m[0][0] * m[1][1] * m[2][2])},
+		{0, 1 / m[1][1], -m[1][2] / (m[1][1] * m[2][2])},
+		{0, 0, 1 / m[2][2]},
+	}
+}
+
+// valid reports whether the cell describes a unit cell with a volume
+func (c *unitCell) valid() bool {
+	if c.A <= 0 || c.B <= 0 || c.C <= 0 || c.Alpha <= 0 || c.Beta <= 0 || c.Gamma <= 0 ||
+		c.Alpha >= 180 || c.Beta >= 180 || c.Gamma >= 180 {
+		return false
+	}
+	m := c.orthogonalization()
+	return m[2][2] > 0 && !math.IsNaN(m[2][2])
+}
+
+// crystalHeader returns the CRYST1, ORIGXn (identity) and SCALEn records of the cell
+func (c *unitCell) crystalHeader() []string {
+	records := []string{c.cryst1()}
+	for i := 0; i < 3; i++ {
+		var row [3]float64
+		row[i] = 1
+		records = append(records, fmt.Sprintf("ORIGX%d    %10.6f%10.6f%10.6f     %10.5f", i+1, row[0], row[1], row[2], 0.0))
+	}
+	scale := c.fractionalization()
+	for i, row := range scale {
+		for j := range row {
+			row[j] = dropRoundingError(row[j], 5e-7)
+		}
+		records = append(records, fmt.Sprintf("SCALE%d    %10.6f%10.6f%10.6f     %10.5f", i+1, row[0], row[1], row[2], 0.0))
+	}
+	return records
+}
+
+// dropRoundingError returns 0 for values smaller than tolerance, such as the rounding errors of the
+// cosines of right angles, which would otherwise be written as -0.000000
+func dropRoundingError(v, tolerance float64) float64 {
+	if math.Abs(v) < tolerance {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	dedupeOutput    string
+	dedupeTolerance float64
+	dedupeRemove    bool
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe [flags] directory_or_file...",
+	Short: "Find and remove duplicate structures across PDB files",
+	Long: `Find PDB files that contain the same structure, e.g. after aggregating models from several sources.
+Directories are scanned for .pdb files (not recursively); files and glob patterns can be given as well.
+
+Each file is reduced to its canonical form, as written by canonicalize: the header is ignored and
+atoms are put in a standard order, so files that differ only in atom order, serial numbers, formatting
+or header records can match. The hash of a file covers its sequence and atoms (models, chains, residues
+and atom names). Files with the same hash are duplicates when no atom is more than --tolerance Å
+(0.01 by default) from the same atom of the other file; coordinates are compared in place, without
+superposition.
+
+The output is a tab-separated table with one row per file: the duplicate group, the file, its hash,
+the earlier file it duplicates (- for the first file of each group) and the largest atom deviation
+from that file. With --remove, the duplicates are deleted and the first file of each group is kept.
+Files that cannot be read are reported and skipped.
+
+Examples:
+  # Report duplicate structures in a directory
+  pdbtk dedupe models/ > duplicates.tsv
+
+  # Delete duplicates from several directories, allowing 0.05 Å of rounding differences
+  pdbtk dedupe --remove --tolerance 0.05 source1/ source2/`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runDedupe,
+}
+
+func init() {
+	dedupeCmd.Flags().StringVarP(&dedupeOutput, "output", "o", "", "Output file for the duplicate table (default: stdout)")
+	dedupeCmd.Flags().Float64Var(&dedupeTolerance, "tolerance", 0.01, "Largest coordinate deviation (Å) of any atom between duplicates")
+	dedupeCmd.Flags().BoolVar(&dedupeRemove, "remove", false, "Delete the duplicate files, keeping the first file of each group")
+}
+
+// dedupeEntry is a file compared for duplicates
+type dedupeEntry struct {
+	File        string
+	Hash        string
+	Group       int
+	DuplicateOf *dedupeEntry
+	Deviation   float64
+	atoms       []*AtomRecord
+}
+
+func runDedupe(cmd *cobra.Command, args []string) error {
+	if dedupeTolerance < 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --tolerance: %g (must not be negative)", dedupeTolerance))
+	}
+	inputs, err := expandDirectoryInputs(cmd.Context(), args)
+	if err != nil {
+		return err
+	}
+	for _, inputFile := range inputs {
+		if err := checkPDBExtension(inputFile); err != nil {
+			return withCode(errorCode(err), fmt.Errorf("%s: %v", inputFile, err))
+		}
+	}
+
+	var entries []*dedupeEntry
+	byHash := make(map[string][]*dedupeEntry)
+	groups := 0
+	var failures batchFailures
+	progress := newProgress("Comparing", int64(len(inputs)), false)
+	for _, inputFile := range inputs {
+		entry, err := readDedupeEntry(cmd.Context(), inputFile)
+		recordResult(inputFile, dedupeOutput, err)
+		failures.add(inputFile, err)
+		progress.Add(1)
+		if err != nil {
+			continue
+		}
+		// Compare with the first file of each group of the same hash
+		for _, first := range byHash[entry.Hash] {
+			if deviation := maxAtomDeviation(first.atoms, entry.atoms); deviation <= dedupeTolerance {
+				entry.Group, entry.DuplicateOf, entry.Deviation = first.Group, first, deviation
+				break
+			}
+		}
+		if entry.DuplicateOf == nil {
+			groups++
+			entry.Group = groups
+			byHash[entry.Hash] = append(byHash[entry.Hash], entry)
+		}
+		entries = append(entries, entry)
+	}
+	progress.Finish()
+	if len(entries) == 0 {
+		return failures.err(len(inputs))
+	}
+
+	duplicates := len(entries) - groups
+	fmt.Fprintf(cmd.ErrOrStderr(), "Found %d duplicates in %d files (%d unique structures)\n", duplicates, len(entries), groups)
+
+	err = writeOutput(cmd.Context(), dedupeOutput, func(w io.Writer) error {
+		fmt.Fprintln(w, "group\tfile\thash\tduplicate_of\tmax_deviation")
+		for _, entry := range entries {
+			duplicateOf := "-"
+			if entry.DuplicateOf != nil {
+				duplicateOf = entry.DuplicateOf.File
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.3f\n", entry.Group, entry.File, entry.Hash, duplicateOf, entry.Deviation)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if dedupeRemove {
+		for _, entry := range entries {
+			if entry.DuplicateOf == nil {
+				continue
+			}
+			if err := os.Remove(entry.File); err != nil {
+				return withCode(ErrCodeIO, fmt.Errorf("failed to remove %s: %v", entry.File, err))
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Removed %s (duplicate of %s)\n", entry.File, entry.DuplicateOf.File)
+		}
+	}
+	return failures.err(len(inputs))
+}
+
+// expandDirectoryInputs expands the directories in args to the PDB files they contain, and any glob
+// patterns to the files they match
+func expandDirectoryInputs(ctx context.Context, args []string) ([]string, error) {
+	var inputs []string
+	var files []string
+	for _, arg := range args {
+		if !isLocalDir(arg) {
+			files = append(files, arg)
+			continue
+		}
+		dirFiles, err := listInputDir(arg, false, false)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, dirFiles...)
+	}
+	expanded, err := expandInputs(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+	inputs = append(inputs, expanded...)
+	if len(inputs) == 0 {
+		return nil, withCode(ErrCodeInputNotFound, fmt.Errorf("no PDB files found in %s", strings.Join(args, ", ")))
+	}
+	return inputs, nil
+}
+
+// readDedupeEntry reads the canonical atoms of a file and hashes their sequence and atom names
+func readDedupeEntry(ctx context.Context, inputFile string) (*dedupeEntry, error) {
+	file, err := readInputRecords(ctx, inputFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Atoms) == 0 {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("no coordinate records found"))
+	}
+	atoms := canonicalizeRecords(file).Atoms
+
+	hash := sha256.New()
+	for _, atom := range atoms {
+		fmt.Fprintf(hash, "%d %s %c %s\n", atom.Model, atom.Residue(), atom.AltLoc, atom.Name)
+	}
+	return &dedupeEntry{File: inputFile, Hash: hex.EncodeToString(hash.Sum(nil))[:16], atoms: atoms}, nil
+}
+
+// maxAtomDeviation returns the largest distance between the atoms at the same position of a and b,
+// which have the same atoms in the same order
+func maxAtomDeviation(a, b []*AtomRecord) float64 {
+	deviation := 0.0
+	for i := range a {
+		dx, dy, dz := a[i].X-b[i].X, a[i].Y-b[i].Y, a[i].Z-b[i].Z
+		deviation = max(deviation, math.Sqrt(dx*dx+dy*dy+dz*dz))
+	}
+	return deviation
+}
@@ -0,0 +1,245 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	linksTolerance float64
+	linksOutput    string
+	linksBatch     batchOptions
+)
+
+// covalentRadii are the single-bond covalent radii (Å) of the elements considered by detect-links
+var covalentRadii = map[string]float64{
+	"B": 0.84, "C": 0.76, "N": 0.71, "O": 0.66, "F": 0.57, "SI": 1.11, "P": 1.07, "S": 1.05,
+	"CL": 1.02, "SE": 1.20, "BR": 1.20, "I": 1.39,
+}
+
+var detectLinksCmd = &cobra.Command{
+	Use:   "detect-links [flags] [input_file...]",
+	Short: "Detect covalent bonds between residues and write LINK records",
+	Long: `Detect covalent bonds between residues, such as covalently bound inhibitors, glycosylation and
+crosslinks, from unusually short heavy-atom distances, and add the corresponding LINK records (and
+SSBOND records for disulfide bonds) to the output.
+
+Two atoms of different residues are considered bonded if their distance is at most the sum of their
+covalent radii plus --tolerance (0.4 Å by default). Metals are not considered, and neither are the
+peptide (C-N) and phosphodiester (O3'-P) bonds between residues of the same chain. Bonds that already
+have a LINK or SSBOND record are kept as they are. Only the first model is analysed.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Add LINK records for covalent modifications
+  pdbtk detect-links 6lu7.pdb --output 6lu7_links.pdb
+
+  # Use a stricter distance criterion
+  pdbtk detect-links --tolerance 0.2 6lu7.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runDetectLinks,
+}
+
+func init() {
+	detectLinksCmd.Flags().Float64Var(&linksTolerance, "tolerance", 0.4, "Distance (Å) allowed beyond the sum of the covalent radii")
+	detectLinksCmd.Flags().StringVarP(&linksOutput, "output", "o", "", "Output file (default: stdout)")
+	addBatchFlags(detectLinksCmd, &linksBatch, "{name}")
+}
+
+// covalentLink is a covalent bond between atoms of two residues
+type covalentLink struct {
+	a, b     *AtomRecord
+	distance float64
+}
+
+func runDetectLinks(cmd *cobra.Command, args []string) error {
+	if linksTolerance < 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --tolerance: %g (must not be negative)", linksTolerance))
+	}
+
+	return runBatch(cmd.Context(), args, linksOutput, linksBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+
+		existing := existingLinks(file.Header)
+		var ssbonds, links []string
+		serial := existing.ssbonds
+		for _, link := range findCovalentLinks(file, linksTolerance) {
+			if existing.has(link.a, link.b) {
+				continue
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s %s - %s %s: %.2f Å\n", link.a.Residue(), link.a.Name, link.b.Residue(), link.b.Name, link.distance)
+			if isDisulfide(link.a, link.b) {
+				serial++
+				ssbonds = append(ssbonds, formatSSBondRecord(serial, link))
+			} else {
+				links = append(links, formatLinkRecord(link))
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Detected %d new covalent links and %d new disulfide bonds\n", len(links), len(ssbonds))
+
+		header := insertHeaderRecords(file.Header, ssbonds, "LINK", "CISPEP", "SITE", "CRYST1", "ORIGX", "SCALE", "MTRIX")
+		header = insertHeaderRecords(header, links, "CISPEP", "SITE", "CRYST1", "ORIGX", "SCALE", "MTRIX")
+		return writePDBRecords(&PDBFile{Header: header, Atoms: file.Atoms, Conect: file.Conect}, writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// findCovalentLinks returns the covalent bonds between residues of the first model, in atom order
+func findCovalentLinks(file *PDBFile, tolerance float64) []covalentLink {
+	models := file.Models()
+	var atoms []*AtomRecord
+	var radii []float64
+	var candidates []*AtomRecord
+	for _, atom := range file.Atoms {
+		if atom.Model == models[0] {
+			candidates = append(candidates, atom)
+		}
+	}
+	inferred := inferElements(candidates)
+	maxRadius := 0.0
+	for i, atom := range candidates {
+		radius, ok := covalentRadii[atomElement(atom, inferred[i])]
+		if !ok || waterResidues[atom.ResName] {
+			continue
+		}
+		atoms = append(atoms, atom)
+		radii = append(radii, radius)
+		if radius > maxRadius {
+			maxRadius = radius
+		}
+	}
+
+	// Sweep along x so that only atoms within the largest possible bond length are compared
+	order := make([]int, len(atoms))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool { return atoms[order[i]].X < atoms[order[j]].X })
+	var links []covalentLink
+	for m, i := range order {
+		for _, j := range order[m+1:] {
+			if atoms[j].X-atoms[i].X > 2*maxRadius+tolerance {
+				break
+			}
+			a, b := atoms[i], atoms[j]
+			if a.Residue() == b.Residue() || !altLocsCompatible(a, b) || isPolymerBond(a, b) {
+				continue
+			}
+			if d := distance(a.Coord(), b.Coord()); d <= radii[i]+radii[j]+tolerance {
+				if a.Serial > b.Serial {
+					a, b = b, a
+				}
+				links = append(links, covalentLink{a: a, b: b, distance: d})
+			}
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].a.Serial != links[j].a.Serial {
+			return links[i].a.Serial < links[j].a.Serial
+		}
+		return links[i].b.Serial < links[j].b.Serial
+	})
+	return links
+}
+
+// isPolymerBond reports whether two atoms form a peptide or phosphodiester bond within a chain
+func isPolymerBond(a, b *AtomRecord) bool {
+	if a.ChainID != b.ChainID {
+		return false
+	}
+	names := a.Name + "-" + b.Name
+	return names == "C-N" || names == "N-C" || names == "O3'-P" || names == "P-O3'"
+}
+
+// isDisulfide reports whether a link is a disulfide bond between two cysteines
+func isDisulfide(a, b *AtomRecord) bool {
+	return a.ResName == "CYS" && b.ResName == "CYS" && a.Name == "SG" && b.Name == "SG"
+}
+
+// linkSet holds the atom pairs of the LINK and SSBOND records of a header
+type linkSet struct {
+	pairs   map[string]bool
+	ssbonds int
+}
+
+// linkEnd identifies an atom in a LINK or SSBOND record, e.g. "A:145 :SG" for a blank insertion code
+func linkEnd(chainID byte, resSeq int, iCode byte, name string) string {
+	return fmt.Sprintf("%c:%d%c:%s", chainID, resSeq, iCode, strings.TrimSpace(name))
+}
+
+// has reports whether the set contains a bond between two atoms
+func (s linkSet) has(a, b *AtomRecord) bool {
+	endA := linkEnd(a.ChainID, a.ResSeq, a.ICode, a.Name)
+	endB := linkEnd(b.ChainID, b.ResSeq, b.ICode, b.Name)
+	return s.pairs[endA+"|"+endB] || s.pairs[endB+"|"+endA]
+}
+
+// existingLinks returns the bonds described by the LINK and SSBOND records of a header
+func existingLinks(header []string) linkSet {
+	set := linkSet{pairs: make(map[string]bool)}
+	field := func(line string, start, end int) string {
+		if len(line) < end {
+			line += strings.Repeat(" ", end-len(line))
+		}
+		return line[start:end]
+	}
+	end := func(line string, chain, resSeq, iCode int, name string) string {
+		seq, _ := strconv.Atoi(strings.TrimSpace(field(line, resSeq, resSeq+4)))
+		return linkEnd(field(line, chain, chain+1)[0], seq, field(line, iCode, iCode+1)[0], name)
+	}
+	for _, line := range header {
+		switch {
+		case strings.HasPrefix(line, "LINK  "):
+			set.pairs[end(line, 21, 22, 26, field(line, 12, 16))+"|"+end(line, 51, 52, 56, field(line, 42, 46))] = true
+		case strings.HasPrefix(line, "SSBOND"):
+			set.pairs[end(line, 15, 17, 21, "SG")+"|"+end(line, 29, 31, 35, "SG")] = true
+			set.ssbonds++
+		}
+	}
+	return set
+}
+
+// formatLinkRecord formats a LINK record for a bond in the asymmetric unit
+func formatLinkRecord(link covalentLink) string {
+	a, b := link.a, link.b
+	return fmt.Sprintf("LINK        %s%c%3s %c%4d%c               %s%c%3s %c%4d%c  %6s %6s %5.2f",
+		formatAtomNameWithElement(a.Name, a.Element), a.AltLoc, a.ResName, a.ChainID, a.ResSeq, a.ICode,
+		formatAtomNameWithElement(b.Name, b.Element), b.AltLoc, b.ResName, b.ChainID, b.ResSeq, b.ICode,
+		"1555", "1555", link.distance)
+}
+
+// formatSSBondRecord formats an SSBOND record for a disulfide bond in the asymmetric unit
+func formatSSBondRecord(serial int, link covalentLink) string {
+	a, b := link.a, link.b
+	return fmt.Sprintf("SSBOND %3d CYS %c %4d%c   CYS %c %4d%c                       %6s %6s %5.2f",
+		serial, a.ChainID, a.ResSeq, a.ICode, b.ChainID, b.ResSeq, b.ICode, "1555", "1555", link.distance)
+}
+
+// insertHeaderRecords inserts records into a header before the first record starting with one of the
+// given record names, or at the end of the header if there is none
+func insertHeaderRecords(header, records []string, before ...string) []string {
+	if len(records) == 0 {
+		return header
+	}
+	at := len(header)
+search:
+	for i, line := range header {
+		for _, name := range before {
+			if strings.HasPrefix(line, name) {
+				at = i
+				break search
+			}
+		}
+	}
+	result := make([]string, 0, len(header)+len(records))
+	result = append(result, header[:at]...)
+	result = append(result, records...)
+	return append(result, header[at:]...)
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffOutput         string
+	diffFormat         string
+	diffTolerance      float64
+	diffValueTolerance float64
+)
+
+// ErrCodeDifferent is reported when diff finds differences between two files
+const ErrCodeDifferent = "differences_found"
+
+// Kinds of differences reported by diff
+const (
+	diffResidueAdded     = "residue_added"
+	diffResidueRemoved   = "residue_removed"
+	diffResidueRenamed   = "residue_renamed"
+	diffAtomAdded        = "atom_added"
+	diffAtomRemoved      = "atom_removed"
+	diffAtomMoved        = "atom_moved"
+	diffBFactorChanged   = "bfactor_changed"
+	diffOccupancyChanged = "occupancy_changed"
+)
+
+// diffKinds lists the kinds of differences in the order they are summarised
+var diffKinds = []string{diffResidueAdded, diffResidueRemoved, diffResidueRenamed, diffAtomAdded, diffAtomRemoved,
+	diffAtomMoved, diffBFactorChanged, diffOccupancyChanged}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [flags] file_a.pdb file_b.pdb",
+	Short: "Compare two PDB files at the residue and atom level",
+	Long: `Compare two PDB files and report their differences at the chain, residue and atom level, rather
+than as a textual diff of fixed-width lines.
+
+Residues are matched by model, chain, residue number and insertion code, and atoms within a residue by
+atom name and alternate location. Reported differences are residues added, removed or renamed (e.g.
+mutations), atoms added or removed, atoms that moved by more than --tolerance, and B-factor or
+occupancy changes larger than --value-tolerance. A summary with the number of differences of each kind
+and the RMSD of the matched atoms is printed at the end.
+
+Like diff(1), the command exits with status 0 if the files are the same and 1 if they differ.
+
+Examples:
+  # Compare a structure before and after refinement
+  pdbtk diff before.pdb after.pdb
+
+  # Ignore coordinate changes below 0.1 Å and report as JSON
+  pdbtk diff --tolerance 0.1 --format json a.pdb b.pdb`,
+	Args: cobra.ExactArgs(2),
+	RunE: runDiff,
+}
+
+func init() {
+	diffCmd.Flags().StringVarP(&diffOutput, "output", "o", "", "Output file (default: stdout)")
+	diffCmd.Flags().StringVarP(&diffFormat, "format", "f", "text", "Output format: text or json")
+	diffCmd.Flags().Float64Var(&diffTolerance, "tolerance", 0.001, "Report atoms that moved by more than this distance (Å)")
+	diffCmd.Flags().Float64Var(&diffValueTolerance, "value-tolerance", 0.005, "Report B-factor and occupancy changes larger than this")
+}
+
+// structureDifference is a single difference found by diff
+type structureDifference struct {
+	Kind     string  `json:"kind"`
+	Residue  string  `json:"residue"`
+	Atom     string  `json:"atom,omitempty"`
+	Before   string  `json:"before,omitempty"`
+	After    string  `json:"after,omitempty"`
+	Distance float64 `json:"distance,omitempty"`
+}
+
+// diffResult holds the differences between two files
+type diffResult struct {
+	FileA        string                `json:"file_a"`
+	FileB        string                `json:"file_b"`
+	Counts       map[string]int        `json:"counts"`
+	MatchedAtoms int                   `json:"matched_atoms"`
+	RMSD         float64               `json:"rmsd"`
+	Differences  []structureDifference `json:"differences"`
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	if diffFormat != "text" && diffFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", diffFormat))
+	}
+	if diffTolerance < 0 || diffValueTolerance < 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("tolerances must not be negative"))
+	}
+
+	var files [2]*PDBFile
+	for i, inputFile := range args {
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return withCode(errorCode(err), fmt.Errorf("%s: %v", inputFile, err))
+		}
+		files[i] = file
+	}
+
+	result := diffStructures(files[0], files[1])
+	result.FileA, result.FileB = args[0], args[1]
+
+	err := writeOutput(cmd.Context(), diffOutput, func(w io.Writer) error {
+		if diffFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}
+		return writeDiffText(result, w)
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(result.Differences) > 0 {
+		return withCode(ErrCodeDifferent, fmt.Errorf("files differ (%d differences)", len(result.Differences)))
+	}
+	return nil
+}
+
+// diffResidueKey identifies a residue independently of its name, so renamed residues are matched
+type diffResidueKey struct {
+	Model   int
+	ChainID byte
+	ResSeq  int
+	ICode   byte
+}
+
+// diffAtomKey identifies an atom within a residue
+type diffAtomKey struct {
+	Name   string
+	AltLoc byte
+}
+
+// diffResidue is a residue with its atoms indexed by name and alternate location
+type diffResidue struct {
+	Key   ResidueKey
+	Order []diffAtomKey
+	Atoms map[diffAtomKey]*AtomRecord
+}
+
+// indexResidues groups atoms by residue, in the order the residues first appear
+func indexResidues(atoms []*AtomRecord) ([]diffResidueKey, map[diffResidueKey]*diffResidue) {
+	var order []diffResidueKey
+	residues := make(map[diffResidueKey]*diffResidue)
+	for _, atom := range atoms {
+		key := diffResidueKey{Model: atom.Model, ChainID: atom.ChainID, ResSeq: atom.ResSeq, ICode: atom.ICode}
+		residue, ok := residues[key]
+		if !ok {
+			residue = &diffResidue{Key: atom.Residue(), Atoms: make(map[diffAtomKey]*AtomRecord)}
+			residues[key] = residue
+			order = append(order, key)
+		}
+		atomKey := diffAtomKey{Name: atom.Name, AltLoc: atom.AltLoc}
+		if _, ok := residue.Atoms[atomKey]; !ok {
+			residue.Order = append(residue.Order, atomKey)
+			residue.Atoms[atomKey] = atom
+		}
+	}
+	return order, residues
+}
+
+// diffStructures compares the residues and atoms of two files
+func diffStructures(a, b *PDBFile) *diffResult {
+	result := &diffResult{Counts: make(map[string]int), Differences: make([]structureDifference, 0)}
+	add := func(d structureDifference) {
+		result.Differences = append(result.Differences, d)
+		result.Counts[d.Kind]++
+	}
+	multiModel := len(a.Models()) > 1 || len(b.Models()) > 1
+	label := func(key ResidueKey) string {
+		if multiModel {
+			return fmt.Sprintf("%d/%s", key.Model, key)
+		}
+		return key.String()
+	}
+
+	orderA, residuesA := indexResidues(a.Atoms)
+	orderB, residuesB := indexResidues(b.Atoms)
+
+	var sumSquares float64
+	for _, key := range orderA {
+		residueA := residuesA[key]
+		residueB, ok := residuesB[key]
+		if !ok {
+			add(structureDifference{Kind: diffResidueRemoved, Residue: label(residueA.Key)})
+			continue
+		}
+		if residueA.Key.ResName != residueB.Key.ResName {
+			add(structureDifference{Kind: diffResidueRenamed, Residue: label(residueA.Key),
+				Before: residueA.Key.ResName, After: residueB.Key.ResName})
+			continue
+		}
+
+		for _, atomKey := range residueA.Order {
+			atomA := residueA.Atoms[atomKey]
+			atomB, ok := residueB.Atoms[atomKey]
+			if !ok {
+				add(structureDifference{Kind: diffAtomRemoved, Residue: label(residueA.Key), Atom: diffAtomLabel(atomKey)})
+				continue
+			}
+			d := distance(atomA.Coord(), atomB.Coord())
+			result.MatchedAtoms++
+			sumSquares += d * d
+			if d > diffTolerance {
+				add(structureDifference{Kind: diffAtomMoved, Residue: label(residueA.Key), Atom: diffAtomLabel(atomKey),
+					Distance: roundTo(d, 3)})
+			}
+			if math.Abs(atomA.TempFactor-atomB.TempFactor) > diffValueTolerance {
+				add(structureDifference{Kind: diffBFactorChanged, Residue: label(residueA.Key), Atom: diffAtomLabel(atomKey),
+					Before: fmt.Sprintf("%.2f", atomA.TempFactor), After: fmt.Sprintf("%.2f", atomB.TempFactor)})
+			}
+			if math.Abs(atomA.Occupancy-atomB.Occupancy) > diffValueTolerance {
+				add(structureDifference{Kind: diffOccupancyChanged, Residue: label(residueA.Key), Atom: diffAtomLabel(atomKey),
+					Before: fmt.Sprintf("%.2f", atomA.Occupancy), After: fmt.Sprintf("%.2f", atomB.Occupancy)})
+			}
+		}
+		for _, atomKey := range residueB.Order {
+			if _, ok := residueA.Atoms[atomKey]; !ok {
+				add(structureDifference{Kind: diffAtomAdded, Residue: label(residueB.Key), Atom: diffAtomLabel(atomKey)})
+			}
+		}
+	}
+	for _, key := range orderB {
+		if _, ok := residuesA[key]; !ok {
+			add(structureDifference{Kind: diffResidueAdded, Residue: label(residuesB[key].Key)})
+		}
+	}
+
+	if result.MatchedAtoms > 0 {
+		result.RMSD = roundTo(math.Sqrt(sumSquares/float64(result.MatchedAtoms)), 3)
+	}
+	return result
+}
+
+// diffAtomLabel formats an atom name with its alternate location, e.g. "CA" or "CA (altloc B)"
+func diffAtomLabel(key diffAtomKey) string {
+	if key.AltLoc == ' ' {
+		return key.Name
+	}
+	return fmt.Sprintf("%s (altloc %c)", key.Name, key.AltLoc)
+}
+
+func writeDiffText(result *diffResult, w io.Writer) error {
+	for _, d := range result.Differences {
+		switch d.Kind {
+		case diffResidueAdded, diffResidueRemoved:
+			fmt.Fprintf(w, "%s: %s\n", d.Kind, d.Residue)
+		case diffResidueRenamed:
+			fmt.Fprintf(w, "%s: %s: %s -> %s\n", d.Kind, d.Residue, d.Before, d.After)
+		case diffAtomAdded, diffAtomRemoved:
+			fmt.Fprintf(w, "%s: %s %s\n", d.Kind, d.Residue, d.Atom)
+		case diffAtomMoved:
+			fmt.Fprintf(w, "%s: %s %s: %.3f Å\n", d.Kind, d.Residue, d.Atom, d.Distance)
+		default:
+			fmt.Fprintf(w, "%s: %s %s: %s -> %s\n", d.Kind, d.Residue, d.Atom, d.Before, d.After)
+		}
+	}
+
+	fmt.Fprintf(w, "%s vs %s:", result.FileA, result.FileB)
+	for i, kind := range diffKinds {
+		separator := ","
+		if i == 0 {
+			separator = ""
+		}
+		fmt.Fprintf(w, "%s %d %s", separator, result.Counts[kind], kind)
+	}
+	fmt.Fprintf(w, "; %d matched atoms, RMSD %.3f Å\n", result.MatchedAtoms, result.RMSD)
+	return nil
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errCorruptDownload marks a download that was cut off or does not match its published size or checksum,
+// which is worth retrying
+var errCorruptDownload = errors.New("corrupt download")
+
+// publishedDigests returns the checksums a server publishes for a response, by algorithm (md5 or sha-256),
+// from the Content-MD5, Digest and Repr-Digest headers
+func publishedDigests(header http.Header) map[string][]byte {
+	digests := make(map[string][]byte)
+	if value := header.Get("Content-MD5"); value != "" {
+		if sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value)); err == nil {
+			digests["md5"] = sum
+		}
+	}
+	// Digest: sha-256=<base64> (RFC 3230) and Repr-Digest: sha-256=:<base64>: (RFC 9530)
+	for _, name := range []string{"Digest", "Repr-Digest"} {
+		for _, item := range strings.Split(header.Get(name), ",") {
+			algorithm, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+			if !ok {
+				continue
+			}
+			algorithm = strings.ToLower(algorithm)
+			if algorithm != "md5" && algorithm != "sha-256" {
+				continue
+			}
+			if sum, err := base64.StdEncoding.DecodeString(strings.Trim(value, ":")); err == nil {
+				digests[algorithm] = sum
+			}
+		}
+	}
+	return digests
+}
+
+// copyVerified copies a download to w and checks that it is complete: that it has the size and
+// checksums published with it and, when gzipped, that it decompresses. Failed checks are errCorruptDownload.
+func copyVerified(w io.Writer, body io.Reader, resp *http.Response, gzipped bool) error {
+	hashes := map[string]hash.Hash{"md5": md5.New(), "sha-256": sha256.New()}
+	writers := []io.Writer{w, hashes["md5"], hashes["sha-256"]}
+
+	// The gzip stream is checked as it arrives
+	var gzipDone chan error
+	var gzipWriter *io.PipeWriter
+	if gzipped {
+		var reader *io.PipeReader
+		reader, gzipWriter = io.Pipe()
+		gzipDone = make(chan error, 1)
+		go func() {
+			gz, err := gzip.NewReader(reader)
+			if err == nil {
+				_, err = io.Copy(io.Discard, gz)
+			}
+			// Keep reading so the download is not blocked by a broken stream
+			io.Copy(io.Discard, reader)
+			gzipDone <- err
+		}()
+		writers = append(writers, gzipWriter)
+	}
+
+	size, err := io.Copy(io.MultiWriter(writers...), body)
+	if gzipWriter != nil {
+		gzipWriter.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: transfer interrupted: %v", errCorruptDownload, err)
+	}
+	if resp.ContentLength >= 0 && size != resp.ContentLength {
+		return fmt.Errorf("%w: got %d of %d bytes", errCorruptDownload, size, resp.ContentLength)
+	}
+	for algorithm, expected := range publishedDigests(resp.Header) {
+		if sum := hashes[algorithm].Sum(nil); !bytes.Equal(sum, expected) {
+			return fmt.Errorf("%w: %s checksum mismatch", errCorruptDownload, algorithm)
+		}
+	}
+	if gzipDone != nil {
+		if err := <-gzipDone; err != nil {
+			return fmt.Errorf("%w: invalid gzip data: %v", errCorruptDownload, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import "strings"
+
+// elementMasses maps element symbols (upper case, as used in the PDB element column) to standard atomic weights
+var elementMasses = map[string]float64{
+	"H": 1.008, "HE": 4.0026, "LI": 6.94, "BE": 9.0122, "B": 10.81, "C": 12.011,
+	"N": 14.007, "O": 15.999, "F": 18.998, "NE": 20.180, "NA": 22.990, "MG": 24.305,
+	"AL": 26.982, "SI": 28.085, "P": 30.974, "S": 32.06, "CL": 35.45, "AR": 39.948,
+	"K": 39.098, "CA": 40.078, "SC": 44.956, "TI": 47.867, "V": 50.942, "CR": 51.996,
+	"MN": 54.938, "FE": 55.845, "CO": 58.933, "NI": 58.693, "CU": 63.546, "ZN": 65.38,
+	"GA": 69.723, "GE": 72.630, "AS": 74.922, "SE": 78.971, "BR": 79.904, "KR": 83.798,
+	"RB": 85.468, "SR": 87.62, "Y": 88.906, "ZR": 91.224, "NB": 92.906, "MO": 95.95,
+	"TC": 98, "RU": 101.07, "RH": 102.91, "PD": 106.42, "AG": 107.87, "CD": 112.41,
+	"IN": 114.82, "SN": 118.71, "SB": 121.76, "TE": 127.60, "I": 126.90, "XE": 131.29,
+	"CS": 132.91, "BA": 137.33, "LA": 138.91, "CE": 140.12, "PR": 140.91, "ND": 144.24,
+	"PM": 145, "SM": 150.36, "EU": 151.96, "GD": 157.25, "TB": 158.93, "DY": 162.50,
+	"HO": 164.93, "ER": 167.26, "TM": 168.93, "YB": 173.05, "LU": 174.97, "HF": 178.49,
+	"TA": 180.95, "W": 183.84, "RE": 186.21, "OS": 190.23, "IR": 192.22, "PT": 195.08,
+	"AU": 196.97, "HG": 200.59, "TL": 204.38, "PB": 207.2, "BI": 208.98, "PO": 209,
+	"AT": 210, "RN": 222, "FR": 223, "RA": 226, "AC": 227, "TH": 232.04,
+	"PA": 231.04, "U": 238.03, "NP": 237, "PU": 244, "AM": 243, "CM": 247,
+	"BK": 247, "CF": 251, "ES": 252, "FM": 257, "MD": 258, "NO": 259,
+	"LR": 262,
+	// Deuterium is written as D in the element column of neutron structures
+	"D": 2.014,
+}
+
+// isElementSymbol reports whether symbol is a known element symbol (case-insensitive)
+func isElementSymbol(symbol string) bool {
+	_, ok := elementMasses[strings.ToUpper(strings.TrimSpace(symbol))]
+	return ok
+}
+
+// ionResidues are residue names of single-atom ions whose atom name is the element symbol
+var ionResidues = map[string]string{
+	"NA": "NA", "K": "K", "MG": "MG", "CA": "CA", "MN": "MN", "FE": "FE", "FE2": "FE", "CO": "CO",
+	"NI": "NI", "CU": "CU", "CU1": "CU", "ZN": "ZN", "CD": "CD", "HG": "HG", "CL": "CL", "BR": "BR",
+	"IOD": "I", "LI": "LI", "RB": "RB", "CS": "CS", "SR": "SR", "BA": "BA", "AL": "AL", "PT": "PT",
+	"AU": "AU", "AG": "AG", "PB": "PB", "F": "F", "YB": "YB", "SM": "SM", "GD": "GD", "TB": "TB",
+}
+
+// hetTwoLetterElements are two-letter element symbols that are recognised from the start of a HETATM
+// atom name. Symbols that clash with common atom names of organic groups (CA, CD, CE, HG, NE, ...) are
+// left out, since e.g. "CD1" is a carbon far more often than a cadmium.
+var hetTwoLetterElements = map[string]bool{
+	"CL": true, "BR": true, "FE": true, "ZN": true, "MG": true, "SE": true, "MN": true, "CU": true,
+	"NI": true, "PT": true, "AU": true, "AG": true, "MO": true, "RU": true, "RH": true, "IR": true,
+	"SI": true, "AS": true, "SB": true, "GA": true, "GE": true, "TE": true, "RE": true, "OS": true,
+	"PD": true, "HF": true, "LI": true, "AL": true, "BE": true, "SN": true, "PB": true, "TL": true,
+}
+
+// inferElement guesses the element symbol of an atom from its name and residue name
+func inferElement(atom *AtomRecord) string {
+	name := strings.ToUpper(strings.TrimSpace(atom.Name))
+	resName := strings.ToUpper(strings.TrimSpace(atom.ResName))
+
+	// Single-atom ions, where e.g. "CA" in residue CA is calcium rather than an alpha carbon
+	if element, ok := ionResidues[resName]; ok && strings.TrimRight(name, "0123456789+-") == element {
+		return element
+	}
+
+	// Drop leading digits of hydrogen names such as "1HB" or "2HG1"
+	letters := strings.TrimLeft(name, "0123456789")
+	if letters == "" {
+		return ""
+	}
+
+	if atom.Het && len(letters) >= 2 && hetTwoLetterElements[letters[:2]] {
+		return letters[:2]
+	}
+
+	// Deuterium in neutron structures is named like hydrogen with a leading D
+	switch letters[0] {
+	case 'C', 'N', 'O', 'S', 'H', 'P', 'D':
+		return letters[:1]
+	}
+
+	if len(letters) >= 2 && isElementSymbol(letters[:2]) {
+		return letters[:2]
+	}
+	if isElementSymbol(letters[:1]) {
+		return letters[:1]
+	}
+	return ""
+}
+
+// standardResidues are the residue names of standard amino acids and nucleotides, whose atom names
+// always start with a one-letter element symbol
+var standardResidues = map[string]bool{
+	"ALA": true, "ARG": true, "ASN": true, "ASP": true, "CYS": true, "GLN": true, "GLU": true,
+	"GLY": true, "HIS": true, "ILE": true, "LEU": true, "LYS": true, "MET": true, "PHE": true,
+	"PRO": true, "SER": true, "THR": true, "TRP": true, "TYR": true, "VAL": true,
+	"A": true, "C": true, "G": true, "U": true, "I": true, "DA": true, "DC": true, "DG": true, "DT": true, "DI": true,
+}
+
+// inferElements guesses the element symbols of atoms from their names and the residues they belong to.
+// Unlike inferElement it also recognises single-atom residues named after an element (e.g. a sodium
+// ion in a residue named NA1) as ions.
+func inferElements(atoms []*AtomRecord) []string {
+	residueSizes := make(map[ResidueKey]int)
+	for _, atom := range atoms {
+		residueSizes[atom.Residue()]++
+	}
+
+	elements := make([]string, len(atoms))
+	for i, atom := range atoms {
+		name := strings.TrimRight(strings.ToUpper(strings.TrimSpace(atom.Name)), "0123456789+-")
+		resName := strings.ToUpper(atom.ResName)
+		switch {
+		case standardResidues[resName]:
+			letters := strings.TrimLeft(strings.ToUpper(strings.TrimSpace(atom.Name)), "0123456789'*")
+			if letters != "" {
+				elements[i] = letters[:1]
+			}
+		case atom.Het && residueSizes[atom.Residue()] == 1 && isElementSymbol(name) && name != "D":
+			elements[i] = name
+		default:
+			elements[i] = inferElement(atom)
+		}
+	}
+	return elements
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ensembleReorder bool
+	ensembleOutput  string
+)
+
+var ensembleCmd = &cobra.Command{
+	Use:   "ensemble [flags] input_file input_file...",
+	Short: "Stack single-model PDB files into a multi-model ensemble",
+	Long: `Stack several PDB files (or glob patterns) of the same molecule into one multi-model PDB file, one
+MODEL per input in the order given, e.g. to view models or MD snapshots as a trajectory or to analyse
+them with ensemble-stats and average.
+
+Every input must have the same atoms (matched by chain, residue number, insertion code, residue name,
+atom name and ALTLOC) in the same order as the first input. With --reorder, the atoms of each input are
+put in the order of the first input instead, which still requires the same atoms. Mismatches are
+reported with the first atoms missing from or extra in an input.
+
+Only the first model of each input is used. The HEADER and CONECT records of the first input are kept.
+
+Examples:
+  # Stack models into an ensemble
+  pdbtk ensemble model_*.pdb > ensemble.pdb
+
+  # Stack MD snapshots whose atoms are written in different orders
+  pdbtk ensemble --reorder --output trajectory.pdb frame1.pdb frame2.pdb frame3.pdb`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runEnsemble,
+}
+
+func init() {
+	ensembleCmd.Flags().BoolVar(&ensembleReorder, "reorder", false, "Reorder the atoms of each input to match the first input")
+	ensembleCmd.Flags().StringVarP(&ensembleOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+func runEnsemble(cmd *cobra.Command, args []string) error {
+	inputs, err := expandInputs(cmd.Context(), args)
+	if err != nil {
+		return err
+	}
+	if len(inputs) < 2 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("ensemble requires at least two input files"))
+	}
+
+	var stacked *PDBFile
+	var template []ensembleAtomKey
+	reordered := 0
+	for i, inputFile := range inputs {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return withCode(errorCode(err), fmt.Errorf("%s: %v", inputFile, err))
+		}
+		models := file.Models()
+		if len(models) == 0 {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("%s: no atoms found", inputFile))
+		}
+		if len(models) > 1 {
+			if err := warn("%s has %d models; only model %d is used", inputFile, len(models), models[0]); err != nil {
+				return err
+			}
+		}
+		var atoms []*AtomRecord
+		for _, atom := range file.Atoms {
+			if atom.Model == models[0] {
+				atom.Model = i + 1
+				atoms = append(atoms, atom)
+			}
+		}
+
+		if i == 0 {
+			var header []string
+			for _, line := range file.Header {
+				if strings.HasPrefix(line, "HEADER") || isCrystalRecord(line) {
+					header = append(header, line)
+				}
+			}
+			stacked = &PDBFile{Header: header, Atoms: atoms, Conect: file.Conect}
+			for _, atom := range atoms {
+				template = append(template, ensembleKey(atom))
+			}
+			continue
+		}
+
+		matched, inOrder, err := matchEnsembleAtoms(template, atoms)
+		if err != nil {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("%s: %v", inputFile, err))
+		}
+		if !inOrder {
+			if !ensembleReorder {
+				return withCode(ErrCodeInvalidArgument, fmt.Errorf("%s: atoms are in a different order than in %s (use --reorder)", inputFile, inputs[0]))
+			}
+			reordered++
+		}
+		stacked.Atoms = append(stacked.Atoms, matched...)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Stacked %d models of %d atoms (%d reordered)\n", len(inputs), len(template), reordered)
+
+	return writeOutput(cmd.Context(), ensembleOutput, func(w io.Writer) error {
+		return writePDBRecords(stacked, w, recordCommandLine(cmd, inputs, ""))
+	})
+}
+
+// matchEnsembleAtoms returns the atoms in the order of template, and whether they were already in that
+// order. It fails if the atoms are not the same as those of the template.
+func matchEnsembleAtoms(template []ensembleAtomKey, atoms []*AtomRecord) ([]*AtomRecord, bool, error) {
+	byKey := make(map[ensembleAtomKey]*AtomRecord, len(atoms))
+	for _, atom := range atoms {
+		key := ensembleKey(atom)
+		if _, seen := byKey[key]; seen {
+			return nil, false, fmt.Errorf("duplicate atom %s %s", atom.Residue(), atom.Name)
+		}
+		byKey[key] = atom
+	}
+
+	describe := func(keys []ensembleAtomKey) string {
+		var names []string
+		for _, key := range keys[:min(len(keys), 3)] {
+			names = append(names, fmt.Sprintf("%s %s", ResidueKey{ChainID: key.ChainID, ResSeq: key.ResSeq, ICode: key.ICode, ResName: key.ResName}, key.Name))
+		}
+		if len(keys) > 3 {
+			names = append(names, "...")
+		}
+		return strings.Join(names, ", ")
+	}
+	var missing []ensembleAtomKey
+	inTemplate := make(map[ensembleAtomKey]bool, len(template))
+	for _, key := range template {
+		inTemplate[key] = true
+		if byKey[key] == nil {
+			missing = append(missing, key)
+		}
+	}
+	var extra []ensembleAtomKey
+	for _, atom := range atoms {
+		if key := ensembleKey(atom); !inTemplate[key] {
+			extra = append(extra, key)
+		}
+	}
+	if len(missing) > 0 || len(extra) > 0 {
+		var problems []string
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%d atoms of the first input are missing (%s)", len(missing), describe(missing)))
+		}
+		if len(extra) > 0 {
+			problems = append(problems, fmt.Sprintf("%d atoms are not in the first input (%s)", len(extra), describe(extra)))
+		}
+		return nil, false, fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	matched := make([]*AtomRecord, len(template))
+	inOrder := true
+	for i, key := range template {
+		matched[i] = byKey[key]
+		inOrder = inOrder && matched[i] == atoms[i]
+	}
+	return matched, inOrder, nil
+}
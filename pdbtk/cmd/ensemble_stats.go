@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ensembleStatsOutput string
+	ensembleStatsFormat string
+	ensembleStatsFit    string
+)
+
+var ensembleStatsCmd = &cobra.Command{
+	Use:   "ensemble-stats [flags] [input_file]",
+	Short: "Summarize the models of a multi-model file",
+	Long: `Summarize a multi-model file (such as an NMR ensemble) to help pick a single model before analysis.
+
+The report lists the number of models, the pairwise RMSD matrix of the models after superposition,
+the most representative (medoid) model, which has the lowest mean RMSD to the other models, and the
+variability of each residue: the mean RMSF of its atoms about the average structure.
+The atoms used for superposition and RMSD are selected with --fit: ca (the default), backbone or all.
+Atoms that are not present in every model are left out.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Summarize an NMR ensemble
+  pdbtk ensemble-stats 2k39.pdb
+
+  # Report as JSON, superposing on all atoms
+  pdbtk ensemble-stats --fit all --format json 2k39.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEnsembleStats,
+}
+
+func init() {
+	ensembleStatsCmd.Flags().StringVarP(&ensembleStatsOutput, "output", "o", "", "Output file (default: stdout)")
+	ensembleStatsCmd.Flags().StringVarP(&ensembleStatsFormat, "format", "f", "text", "Output format: text or json")
+	ensembleStatsCmd.Flags().StringVar(&ensembleStatsFit, "fit", "ca", "Atoms used for superposition and RMSD: ca, backbone or all")
+}
+
+// residueVariability is the mean RMSF of the atoms of a residue
+type residueVariability struct {
+	Residue string  `json:"residue"`
+	RMSF    float64 `json:"rmsf"`
+}
+
+// ensembleStats is the report written by ensemble-stats
+type ensembleStats struct {
+	Models      []int                `json:"models"`
+	Atoms       int                  `json:"atoms"`
+	FittedAtoms int                  `json:"fitted_atoms"`
+	RMSD        [][]float64          `json:"rmsd"`
+	Medoid      int                  `json:"medoid"`
+	MedoidRMSD  float64              `json:"medoid_mean_rmsd"`
+	Residues    []residueVariability `json:"residues"`
+}
+
+func runEnsembleStats(cmd *cobra.Command, args []string) error {
+	if ensembleStatsFormat != "text" && ensembleStatsFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", ensembleStatsFormat))
+	}
+	switch ensembleStatsFit {
+	case "ca", "backbone", "all":
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --fit: %s (must be ca, backbone or all)", ensembleStatsFit))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	e, err := newEnsemble(file, ensembleStatsFit)
+	if err != nil {
+		return err
+	}
+	stats := computeEnsembleStats(e)
+
+	return writeOutput(cmd.Context(), ensembleStatsOutput, func(w io.Writer) error {
+		if ensembleStatsFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(stats)
+		}
+		return writeEnsembleStatsText(stats, w)
+	})
+}
+
+// computeEnsembleStats computes the pairwise RMSD matrix, medoid and per-residue RMSF of an ensemble
+func computeEnsembleStats(e *ensemble) *ensembleStats {
+	n := len(e.models)
+	stats := &ensembleStats{Models: e.models, Atoms: len(e.keys), FittedAtoms: len(e.fit), RMSD: make([][]float64, n)}
+	for i := range stats.RMSD {
+		stats.RMSD[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			mobile, target := e.fitCoords(e.coords[j]), e.fitCoords(e.coords[i])
+			transform := superposition(mobile, target)
+			for k := range mobile {
+				mobile[k] = transform.apply(mobile[k])
+			}
+			value := roundTo(rmsd(mobile, target), 3)
+			stats.RMSD[i][j], stats.RMSD[j][i] = value, value
+		}
+	}
+
+	best := math.Inf(1)
+	for i := 0; i < n; i++ {
+		sum := 0.0
+		for j := 0; j < n; j++ {
+			sum += stats.RMSD[i][j]
+		}
+		if mean := sum / float64(n-1); mean < best {
+			best = mean
+			stats.Medoid = e.models[i]
+		}
+	}
+	stats.MedoidRMSD = roundTo(best, 3)
+
+	rmsf := e.rmsf(e.superpose())
+	var order []ResidueKey
+	sums := make(map[ResidueKey]float64)
+	counts := make(map[ResidueKey]int)
+	for k, atom := range e.template {
+		key := atom.Residue()
+		if counts[key] == 0 {
+			order = append(order, key)
+		}
+		sums[key] += rmsf[k]
+		counts[key]++
+	}
+	for _, key := range order {
+		stats.Residues = append(stats.Residues, residueVariability{Residue: key.String(), RMSF: roundTo(sums[key]/float64(counts[key]), 3)})
+	}
+	return stats
+}
+
+// roundTo rounds x to the given number of decimal places
+func roundTo(x float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(x*scale) / scale
+}
+
+func writeEnsembleStatsText(stats *ensembleStats, w io.Writer) error {
+	fmt.Fprintf(w, "Models: %d (%d atoms in every model, %d fitted)\n", len(stats.Models), stats.Atoms, stats.FittedAtoms)
+	fmt.Fprintf(w, "Medoid model: %d (mean RMSD to the other models %.3f Å)\n", stats.Medoid, stats.MedoidRMSD)
+
+	fmt.Fprintf(w, "\nPairwise RMSD (Å):\n%6s", "")
+	for _, model := range stats.Models {
+		fmt.Fprintf(w, " %7d", model)
+	}
+	fmt.Fprintln(w)
+	for i, row := range stats.RMSD {
+		fmt.Fprintf(w, "%6d", stats.Models[i])
+		for _, value := range row {
+			fmt.Fprintf(w, " %7.3f", value)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintf(w, "\nPer-residue RMSF (Å):\n")
+	for _, residue := range stats.Residues {
+		fmt.Fprintf(w, "%-12s %7.3f\n", residue.Residue, residue.RMSF)
+	}
+	return nil
+}
@@ -1,10 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/TuftsBCB/io/pdb"
@@ -12,17 +13,69 @@ import (
 )
 
 var (
-	chains string
-	output string
-	altloc string
+	chains          string
+	output          string
+	altloc          string
+	extractLigands  string
+	extractBox      string
+	boxResidues     bool
+	extractSS       string
+	ssElements      string
+	extractPolymer  string
+	extractAssembly string
+	extractModels   string
+	dropModels      string
+	keepRemarks     string
+	extractBatch    batchOptions
 )
 
 var extractCmd = &cobra.Command{
-	Use:   "extract [flags] [input_file]",
+	Use:   "extract [flags] [input_file...]",
 	Short: "Extract chains from a PDB file",
 	Long: `Extract specific chains from a PDB structure file.
 The output can be written to a file or stdout (if no output file is specified).
 If no input file is specified, reads from stdin.
+Multiple input files (or glob patterns) can be processed in one run with --outdir.
+
+A chain ID can be followed by residue ranges to extract only part of the chain, e.g. A:10-120 or
+B:5-40,200-250 (ranges after a chain with ranges belong to that chain). Ranges are inclusive and
+may use insertion codes (52A-60).
+
+--ligand keeps the listed het groups (ligands, cofactors, ions) of the selected chains, or of every
+chain when --chains is not given, so they can be extracted with their chains or on their own. Other
+het groups and waters are left out.
+
+--box crops the structure (or the selected chains and ligands) to the atoms inside a Cartesian box,
+given as xmin:xmax,ymin:ymax,zmin:zmax in Ångström, e.g. to carve out the region covered by a density
+map or docking grid. With --box-residues, residues with at least one atom inside the box are kept whole.
+
+--ss keeps the residues in helices (H), strands (E) or coil (C), and --ss-element individual helices and
+strands numbered per chain in the order of the HELIX and SHEET records (A:H3 is the third helix of chain A,
+B:E1 the first strand of chain B). Both use the HELIX and SHEET records of the input.
+
+--polymer keeps the residues of the given molecule types: protein, dna, rna, ligand or water (e.g.
+--polymer protein to drop nucleic acids, ligands and waters without listing chain IDs). Polymer residues,
+including modified ones, take the type of most residues of their chain.
+
+--assembly builds biological assembly N of an mmCIF input from its _pdbx_struct_assembly_gen and
+_pdbx_struct_oper_list records: the chains of the assembly are copied by each of its operators (with
+the transforms applied), and the other chains are left out. The copies made by the first operator keep
+their chain IDs and the others are given unused ones; the origin of each chain is printed to stderr.
+The output is a PDB file, and the other selections apply to the chains of the assembly.
+
+--models keeps the listed models of a multi-model file (e.g. an NMR ensemble or MD trajectory), given
+as model numbers and inclusive ranges such as 1,3,5-10, and --drop-models removes them instead, e.g. to
+thin an ensemble or remove corrupted frames. The kept models keep their MODEL numbers; use
+renumber-models to number them sequentially.
+
+--keep-remarks copies the listed REMARK classes of the input to the output, e.g. 2 (resolution),
+3 (refinement), 350 (biological assemblies) and 465 (missing residues), or all of them with "all"; the
+other REMARKs are left out. The rows of REMARK 465 and 470 (missing atoms) and the REMARK 350 operators
+of chains that were not extracted are dropped. Without it, extracting chains writes no REMARKs of the
+input, and the other selections keep all of them.
+
+With --ligand, --box, --ss, --ss-element, --polymer, --assembly, --models or --drop-models, every field of the coordinate records (residue names, occupancies, B-factors)
+is kept as it is in the input.
 
 Examples:
   # Extract chains A, B, and C to a file
@@ -34,98 +87,505 @@ Examples:
   # Extract from stdin
   cat 1a02.pdb | pdbtk extract --chains A,B,C
 
+  # Extract a domain: residues 10-120 of chain A and two segments of chain B
+  pdbtk extract --chains A:10-120,B:5-40,200-250 1a02.pdb
+
+  # Extract chain A with its heme and NAD
+  pdbtk extract --chains A --ligand HEM,NAD 4hhb.pdb
+
+  # Extract all heme groups on their own
+  pdbtk extract --ligand HEM 4hhb.pdb
+
+  # Crop to the atoms inside a box
+  pdbtk extract --box 10:30,-5:15,0:20 1a02.pdb
+
+  # Keep the whole residues of chain A that reach into the box
+  pdbtk extract --chains A --box 10:30,-5:15,0:20 --box-residues 1a02.pdb
+
+  # Extract the helices of chain A
+  pdbtk extract --chains A --ss H 1a02.pdb
+
+  # Extract the third helix of chain A
+  pdbtk extract --ss-element A:H3 1a02.pdb
+
+  # Extract the protein chains of a protein-DNA complex
+  pdbtk extract --polymer protein 1a02.pdb
+
+  # Extract the first biological assembly of an mmCIF file
+  pdbtk extract --assembly 1 --output 1a02_assembly1.pdb 1a02.cif
+
+  # Keep every other model of the first ten of an NMR ensemble
+  pdbtk extract --models 1,3,5,7,9 2k39.pdb > thinned.pdb
+
+  # Remove corrupted frames 12 to 15 from a trajectory
+  pdbtk extract --drop-models 12-15 trajectory.pdb > cleaned.pdb
+
+  # Extract chain A with its resolution, assembly and missing residue annotations
+  pdbtk extract --chains A --keep-remarks 2,3,350,465 1a02.pdb
+
   # Extract only ALTLOC A atoms
   pdbtk extract --chains A --altloc A 1a02.pdb
 
   # Extract first ALTLOC when duplicates exist
-  pdbtk extract --chains A --altloc first 1a02.pdb`,
-	Args: cobra.MaximumNArgs(1),
+  pdbtk extract --chains A --altloc first 1a02.pdb
+
+  # Extract chain A from every PDB file in the current directory
+  pdbtk extract --chains A --outdir out/ --name-template {stem}_chainA.pdb *.pdb`,
+	Args: cobra.ArbitraryArgs,
 	RunE: runExtract,
 }
 
 func init() {
-	extractCmd.Flags().StringVarP(&chains, "chains", "c", "", "Comma-separated list of chain IDs to extract")
+	extractCmd.Flags().StringVarP(&chains, "chains", "c", "", "Comma-separated list of chain IDs to extract, optionally with residue ranges (A:10-120,B:5-40,200-250)")
 	extractCmd.Flags().StringVar(&chains, "chain", "", "Alias for --chains")
 	extractCmd.Flags().StringVarP(&output, "output", "o", "", "Output file (default: stdout)")
+	extractCmd.Flags().StringVar(&extractLigands, "ligand", "", "Comma-separated het residue names to keep with the selected chains (e.g. HEM,NAD)")
+	extractCmd.Flags().StringVar(&extractBox, "box", "", "Keep only atoms inside the box xmin:xmax,ymin:ymax,zmin:zmax (Å)")
+	extractCmd.Flags().BoolVar(&boxResidues, "box-residues", false, "With --box, keep whole residues that have any atom inside the box")
+	extractCmd.Flags().StringVar(&extractSS, "ss", "", "Keep residues by secondary structure from HELIX/SHEET records: H, E, C (coil) or a comma-separated combination")
+	extractCmd.Flags().StringVar(&ssElements, "ss-element", "", "Keep numbered helices and strands, e.g. A:H3,B:E1 (third helix of chain A, first strand of chain B)")
+	extractCmd.Flags().StringVar(&extractPolymer, "polymer", "", "Keep residues of these molecule types: protein, dna, rna, ligand, water (comma-separated)")
+	extractCmd.Flags().StringVar(&extractAssembly, "assembly", "", "Build this biological assembly of an mmCIF input, applying its symmetry operators")
+	extractCmd.Flags().StringVar(&extractModels, "models", "", "Keep these models of a multi-model file: numbers and ranges (e.g. 1,3,5-10)")
+	extractCmd.Flags().StringVar(&dropModels, "drop-models", "", "Remove these models of a multi-model file: numbers and ranges (e.g. 2,12-15)")
+	extractCmd.Flags().StringVar(&keepRemarks, "keep-remarks", "", "Copy these REMARK classes of the input to the output (e.g. 2,3,350,465, or all)")
 	extractCmd.Flags().StringVar(&altloc, "altloc", "", "Filter by ALTLOC identifier (e.g., A, B) or 'first' to take first ALTLOC when duplicates exist")
+	addBatchFlags(extractCmd, &extractBatch, "{name}")
 }
 
 func runExtract(cmd *cobra.Command, args []string) error {
-	var inputFile string
-	var isStdin bool
-
-	if len(args) > 0 {
-		inputFile = args[0]
-		isStdin = false
-		// Check if input file exists
-		if err := CheckFileExists(inputFile); err != nil {
+	// Validate that at least one selection is specified
+	if chains == "" && altloc == "" && extractLigands == "" && extractBox == "" && extractSS == "" && ssElements == "" && extractPolymer == "" && extractAssembly == "" && extractModels == "" && dropModels == "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("at least one of --chains, --altloc, --ligand, --box, --ss, --ss-element, --polymer, --assembly, --models or --drop-models must be specified"))
+	}
+	if extractModels != "" && dropModels != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--models and --drop-models cannot be combined"))
+	}
+	if boxResidues && extractBox == "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--box-residues requires --box"))
+	}
+	selection := recordSelection{assembly: extractAssembly}
+	if keepRemarks != "" {
+		var err error
+		if selection.remarks, err = parseRemarkClasses(keepRemarks); err != nil {
 			return err
 		}
-		// Check if it's a PDB file
-		inputExt := strings.ToLower(filepath.Ext(inputFile))
-		if inputExt != ".pdb" {
-			return fmt.Errorf("only PDB files are supported, got: %s", inputExt)
+	}
+	extractBatch.mmCIF = extractAssembly != ""
+	if extractBox != "" {
+		var err error
+		if selection.box, err = parseCoordinateBox(extractBox); err != nil {
+			return err
+		}
+	}
+	if extractModels != "" || dropModels != "" {
+		var err error
+		if selection.models, err = parseModelSelection(extractModels, dropModels); err != nil {
+			return err
 		}
+	}
+	if extractPolymer != "" {
+		var err error
+		if selection.types, err = parseMoleculeTypes(extractPolymer); err != nil {
+			return err
+		}
+	}
+	if extractSS != "" || ssElements != "" {
+		var err error
+		if selection.ss, err = parseSSSelection(extractSS, ssElements); err != nil {
+			return err
+		}
+	}
+	if extractLigands != "" {
+		for _, name := range strings.Split(extractLigands, ",") {
+			name = strings.ToUpper(strings.TrimSpace(name))
+			if name == "" || len(name) > 3 {
+				return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --ligand: %q (must be residue names of 1-3 characters)", name))
+			}
+			selection.ligands = append(selection.ligands, name)
+		}
+	}
+
+	// Parse chain IDs and residue ranges
+	if chains != "" {
+		var err error
+		if selection.chains, err = parseChainSelections(chains); err != nil {
+			return err
+		}
+	}
+
+	return runBatch(cmd.Context(), args, output, extractBatch, func(inputFile string, writer io.Writer) error {
+		if len(selection.ligands) > 0 || selection.box != nil || selection.ss != nil || selection.types != nil || selection.assembly != "" || selection.models != nil {
+			return extractRecords(cmd, args, inputFile, selection, writer)
+		}
+		return extractFile(cmd, args, inputFile, selection.chains, selection.remarks, writer)
+	})
+}
+
+// recordSelection is what extractRecords keeps of an input
+type recordSelection struct {
+	chains   chainSelections
+	ligands  []string
+	box      *coordinateBox
+	ss       *ssSelection
+	types    map[moleculeType]bool
+	assembly string
+	models   *modelSelection
+	remarks  *remarkClasses
+}
+
+// extractRecords extracts the requested assembly, models, chains, residue ranges, ligands, box, secondary structure
+// and ALTLOCs from a single input at the record level, keeping the residue names of het groups, and writes
+// them to writer
+func extractRecords(cmd *cobra.Command, args []string, inputFile string, selection recordSelection, writer io.Writer) error {
+	selections, ligands := selection.chains, selection.ligands
+	var file *PDBFile
+	var err error
+	if selection.assembly != "" {
+		file, err = readAssembly(cmd, inputFile, selection.assembly)
 	} else {
-		// Check if stdin is available
-		stat, err := os.Stdin.Stat()
-		if err != nil {
-			return fmt.Errorf("failed to check stdin: %v", err)
+		file, err = readInputRecords(cmd.Context(), inputFile)
+	}
+	if err != nil {
+		return err
+	}
+	if selection.models != nil {
+		if file, err = selection.models.filter(file); err != nil {
+			return err
 		}
-		if (stat.Mode() & os.ModeCharDevice) != 0 {
-			return fmt.Errorf("no input file specified and stdin is not available")
+	}
+
+	selectedChains := make(map[byte]bool)
+	for _, chain := range selections {
+		selectedChains[chain.ChainID] = true
+	}
+	if len(selections) > 0 {
+		present := make(map[byte]bool)
+		for _, chainID := range file.ChainIDs() {
+			present[chainID] = true
+		}
+		var missing []string
+		for _, chain := range selections {
+			if !present[chain.ChainID] {
+				missing = append(missing, string(chain.ChainID))
+			}
+		}
+		if len(missing) == len(selections) {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("no matching chains found: %s", strings.Join(missing, ",")))
+		}
+		if len(missing) > 0 {
+			if err := warn("chains not found: %s", strings.Join(missing, ",")); err != nil {
+				return err
+			}
 		}
-		inputFile = ""
-		isStdin = true
 	}
 
-	// Validate that at least one of --chains or --altloc is specified
-	if chains == "" && altloc == "" {
-		return fmt.Errorf("at least one of --chains or --altloc must be specified")
+	wanted := make(map[string]bool)
+	for _, name := range ligands {
+		wanted[name] = true
+	}
+	hetGroups := ligandResidues(file.Atoms)
+	found := make(map[string]bool)
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		key := atom.Residue()
+		switch {
+		case len(selections) == 0 && len(ligands) == 0:
+			// Only cropping by box or secondary structure
+		case len(ligands) == 0:
+			if !selections.Contains(key.ChainID, key.ResSeq, key.ICode) {
+				continue
+			}
+		case hetGroups[key]:
+			if !wanted[key.ResName] || (len(selections) > 0 && !selectedChains[key.ChainID]) {
+				continue
+			}
+			found[key.ResName] = true
+		case waterResidues[key.ResName]:
+			continue
+		case len(selections) == 0 && selection.types == nil,
+			len(selections) > 0 && !selections.Contains(key.ChainID, key.ResSeq, key.ICode):
+			continue
+		}
+		atoms = append(atoms, atom)
 	}
 
-	// Parse chain IDs
-	var chainList []string
-	if chains != "" {
-		chainList = strings.Split(chains, ",")
-		for i, chain := range chainList {
-			chainList[i] = strings.TrimSpace(chain)
-			if len(chainList[i]) != 1 {
-				return fmt.Errorf("invalid chain ID: %s (must be single character)", chainList[i])
+	var missing []string
+	for _, name := range ligands {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 && len(missing) == len(ligands) && len(selections) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no matching ligands found: %s", strings.Join(missing, ",")))
+	}
+	if len(missing) > 0 {
+		if err := warn("ligands not found: %s", strings.Join(missing, ",")); err != nil {
+			return err
+		}
+	}
+
+	if selection.types != nil {
+		types := classifyResidues(file.Atoms)
+		var kept []*AtomRecord
+		for _, atom := range atoms {
+			key := atom.Residue()
+			if selection.types[types[key]] || (hetGroups[key] && wanted[key.ResName]) {
+				kept = append(kept, atom)
 			}
 		}
+		atoms = kept
+	}
+	if selection.ss != nil {
+		polymer := func(key ResidueKey) bool { return !hetGroups[key] && !waterResidues[key.ResName] }
+		ligand := func(key ResidueKey) bool { return hetGroups[key] && wanted[key.ResName] }
+		if atoms, err = selection.ss.filter(file.Header, atoms, polymer, ligand); err != nil {
+			return err
+		}
+	}
+	if selection.box != nil {
+		atoms = selection.box.filter(atoms, boxResidues)
 	}
+	if altloc != "" {
+		atoms = filterAltLocRecords(atoms, altloc)
+	}
+	if len(atoms) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no atoms match the selection"))
+	}
+	extracted := file.WithAtoms(atoms)
+	if selection.remarks != nil {
+		extracted.Header = keepSelectedRemarks(file.Header, selection.remarks, atomChains(atoms))
+	}
+	return writePDBRecords(extracted, writer, buildCommandLine(cmd, args, inputFile))
+}
 
-	// Read the PDB file with ALTLOC support
-	var entry *pdb.Entry
-	var altLocList []byte
-	var err error
-	if isStdin {
-		content, err := readAllFromStdin()
-		if err != nil {
-			return fmt.Errorf("failed to read from stdin: %v", err)
+// keepSelectedRemarks returns the header records with only the selected REMARKs of the given chains,
+// in place of the REMARKs of the input
+func keepSelectedRemarks(header []string, remarks *remarkClasses, chains map[byte]bool) []string {
+	var kept []string
+	inserted := false
+	provenance, rest := splitProvenance(header)
+	kept = append(kept, provenance...)
+	for _, line := range rest {
+		if _, ok := remarkClass(line); !ok {
+			kept = append(kept, line)
+		} else if !inserted {
+			kept = append(kept, remarks.selectRemarks(rest, chains)...)
+			inserted = true
 		}
-		extendedEntry, err := ReadPDBWithAltLocFromContent(content, "")
+	}
+	return kept
+}
+
+// atomChains returns the chain IDs of atoms
+func atomChains(atoms []*AtomRecord) map[byte]bool {
+	chains := make(map[byte]bool)
+	for _, atom := range atoms {
+		chains[atom.ChainID] = true
+	}
+	return chains
+}
+
+// modelSelection is a set of model numbers and ranges to keep, or to drop
+type modelSelection struct {
+	ranges [][2]int
+	parts  []string
+	drop   bool
+}
+
+// parseModelSelection parses the model numbers and inclusive ranges of --models or --drop-models,
+// such as 1,3,5-10
+func parseModelSelection(keep, drop string) (*modelSelection, error) {
+	flag, spec := "--models", keep
+	if drop != "" {
+		flag, spec = "--drop-models", drop
+	}
+	selection := &modelSelection{drop: drop != ""}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		low, high, isRange := strings.Cut(part, "-")
+		first, err := strconv.Atoi(strings.TrimSpace(low))
 		if err != nil {
-			return fmt.Errorf("failed to read PDB file: %v", err)
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid %s: %q (expected model numbers and ranges such as 1,3,5-10)", flag, part))
 		}
-		entry = extendedEntry.Entry
-		altLocList = extendedEntry.AltLocList
-	} else {
-		extendedEntry, err := ReadPDBWithAltLoc(inputFile)
-		if err != nil {
-			return fmt.Errorf("failed to read PDB file: %v", err)
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(strings.TrimSpace(high)); err != nil || last < first {
+				return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid %s range: %q", flag, part))
+			}
+		}
+		selection.ranges = append(selection.ranges, [2]int{first, last})
+		selection.parts = append(selection.parts, part)
+	}
+	return selection, nil
+}
+
+// contains reports whether a model number is in one of the ranges, and which
+func (s *modelSelection) contains(model int) (int, bool) {
+	for i, r := range s.ranges {
+		if model >= r[0] && model <= r[1] {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// filter keeps the atoms of the selected models, or removes them when dropping
+func (s *modelSelection) filter(file *PDBFile) (*PDBFile, error) {
+	matched := make(map[int]bool)
+	for _, model := range file.Models() {
+		if i, ok := s.contains(model); ok {
+			matched[i] = true
+		}
+	}
+	var missing []string
+	for i, part := range s.parts {
+		if !matched[i] {
+			missing = append(missing, part)
+		}
+	}
+	if !s.drop && len(missing) == len(s.parts) {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("no matching models found: %s", strings.Join(missing, ",")))
+	}
+	if len(missing) > 0 {
+		if err := warn("models not found: %s", strings.Join(missing, ",")); err != nil {
+			return nil, err
+		}
+	}
+
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if _, ok := s.contains(atom.Model); ok != s.drop {
+			atoms = append(atoms, atom)
 		}
-		entry = extendedEntry.Entry
-		altLocList = extendedEntry.AltLocList
 	}
+	if len(atoms) == 0 {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("no models left after --drop-models"))
+	}
+	return file.WithAtoms(atoms), nil
+}
+
+// coordinateBox is an axis-aligned box of Cartesian coordinates
+type coordinateBox struct {
+	Min, Max [3]float64
+}
+
+// parseCoordinateBox parses a box given as xmin:xmax,ymin:ymax,zmin:zmax
+func parseCoordinateBox(spec string) (*coordinateBox, error) {
+	invalid := withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --box: %s (expected xmin:xmax,ymin:ymax,zmin:zmax)", spec))
+	ranges := strings.Split(spec, ",")
+	if len(ranges) != 3 {
+		return nil, invalid
+	}
+	box := &coordinateBox{}
+	for i, r := range ranges {
+		low, high, ok := strings.Cut(strings.TrimSpace(r), ":")
+		if !ok {
+			return nil, invalid
+		}
+		var err error
+		if box.Min[i], err = strconv.ParseFloat(strings.TrimSpace(low), 64); err != nil {
+			return nil, invalid
+		}
+		if box.Max[i], err = strconv.ParseFloat(strings.TrimSpace(high), 64); err != nil {
+			return nil, invalid
+		}
+		if box.Min[i] > box.Max[i] {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --box: %s (minimum greater than maximum)", spec))
+		}
+	}
+	return box, nil
+}
+
+// contains reports whether an atom lies inside the box, boundaries included
+func (b *coordinateBox) contains(atom *AtomRecord) bool {
+	coords := [3]float64{atom.X, atom.Y, atom.Z}
+	for i, c := range coords {
+		if c < b.Min[i] || c > b.Max[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// filter keeps the atoms inside the box, or with wholeResidues every atom of the residues that have
+// an atom inside it
+func (b *coordinateBox) filter(atoms []*AtomRecord, wholeResidues bool) []*AtomRecord {
+	inside := make(map[ResidueKey]bool)
+	if wholeResidues {
+		for _, atom := range atoms {
+			if b.contains(atom) {
+				inside[atom.Residue()] = true
+			}
+		}
+	}
+	var filtered []*AtomRecord
+	for _, atom := range atoms {
+		if (wholeResidues && inside[atom.Residue()]) || (!wholeResidues && b.contains(atom)) {
+			filtered = append(filtered, atom)
+		}
+	}
+	return filtered
+}
+
+// filterAltLocRecords keeps the atoms without an ALTLOC and those with the given ALTLOC, or with
+// "first" the first ALTLOC of each atom that has several
+func filterAltLocRecords(atoms []*AtomRecord, altlocFilter string) []*AtomRecord {
+	type atomKey struct {
+		residue ResidueKey
+		name    string
+	}
+	chosen := make(map[atomKey]byte)
+	var filtered []*AtomRecord
+	for _, atom := range atoms {
+		if atom.AltLoc == ' ' {
+			filtered = append(filtered, atom)
+			continue
+		}
+		if altlocFilter != "first" {
+			if atom.AltLoc == altlocFilter[0] {
+				filtered = append(filtered, atom)
+			}
+			continue
+		}
+		key := atomKey{atom.Residue(), atom.Name}
+		if first, ok := chosen[key]; ok && first != atom.AltLoc {
+			continue
+		}
+		chosen[key] = atom.AltLoc
+		filtered = append(filtered, atom)
+	}
+	return filtered
+}
+
+// extractFile extracts the requested chains, residue ranges and ALTLOCs from a single input and writes them to writer
+func extractFile(cmd *cobra.Command, args []string, inputFile string, selections chainSelections, remarks *remarkClasses, writer io.Writer) error {
+	// Read the PDB file with ALTLOC support
+	extendedEntry, err := readInputEntryWithAltLoc(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	entry := extendedEntry.Entry
+	altLocList := extendedEntry.AltLocList
+	header := append([]string{}, extendedEntry.Provenance...)
 
 	// Extract the specified chains (if specified)
 	var extractedChains *pdb.Entry
-	if len(chainList) > 0 {
-		extractedChains, altLocList, err = ExtractChainsPDB(entry, chainList, altLocList)
+	if len(selections) > 0 {
+		chainList := selections.ChainIDs()
+		if err := checkChainsPresent(entry, chainList); err != nil {
+			return err
+		}
+		extractedChains, altLocList, err = ExtractChainsPDBContext(cmd.Context(), entry, chainList, altLocList)
 		if err != nil {
-			return fmt.Errorf("failed to extract chains: %v", err)
+			return contextError(err)
+		}
+		if selections.HasRanges() {
+			chainsOnly := extractedChains
+			extractedChains, altLocList = filterResidueRanges(chainsOnly, altLocList, selections)
+			if err := checkRangesPresent(chainsOnly, extractedChains, selections); err != nil {
+				return err
+			}
 		}
 	} else {
 		// No chain filtering, use all chains
@@ -136,50 +596,53 @@ func runExtract(cmd *cobra.Command, args []string) error {
 	if altloc != "" {
 		extractedChains, altLocList, err = filterByAltLoc(extractedChains, altLocList, altloc)
 		if err != nil {
-			return fmt.Errorf("failed to filter by ALTLOC: %v", err)
+			return fmt.Errorf("failed to filter by ALTLOC: %w", err)
+		}
+		if len(extractedChains.Chains) == 0 {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("no atoms match ALTLOC %s", altloc))
 		}
 	}
 
-	// Build the full command line
-	commandLine := buildCommandLine(cmd, args, inputFile)
-
-	// Write the output
-	if output == "" || output == "-" {
-		// Write to stdout
-		return writePDBToWriterWithAltLoc(extractedChains, altLocList, os.Stdout, commandLine)
-	} else {
-		// Write to file
-		file, err := os.Create(output)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %v", err)
+	if remarks != nil {
+		chains := make(map[byte]bool)
+		for _, chain := range extractedChains.Chains {
+			chains[chain.Ident] = true
 		}
-		defer file.Close()
-		return writePDBToWriterWithAltLoc(extractedChains, altLocList, file, commandLine)
+		header = append(header, remarks.selectRemarks(extendedEntry.Header, chains)...)
 	}
-}
+	header = append(header, crystalRecords(extendedEntry.Header)...)
+
+	// Build the full command line
+	commandLine := buildCommandLine(cmd, args, inputFile)
 
-func readPDB(filename string) (*pdb.Entry, error) {
-	return pdb.ReadPDB(filename)
+	return writePDBToWriterWithAltLoc(extractedChains, altLocList, header, writer, commandLine)
 }
 
-func readPDBFromContent(content []byte) (*pdb.Entry, error) {
-	// Create a temporary file to read from content
-	tmpfile, err := os.CreateTemp("", "pdbtk_*.pdb")
-	if err != nil {
-		return nil, err
+// checkChainsPresent returns a no_match error if none of the chains in chainList exist in entry,
+// and warns about any individual chains that are missing
+func checkChainsPresent(entry *pdb.Entry, chainList []string) error {
+	var missing []string
+	for _, chainID := range chainList {
+		if entry.Chain(chainID[0]) == nil {
+			missing = append(missing, chainID)
+		}
 	}
-	defer os.Remove(tmpfile.Name())
-
-	if _, err := tmpfile.Write(content); err != nil {
-		tmpfile.Close()
-		return nil, err
+	if len(missing) == 0 {
+		return nil
 	}
-	tmpfile.Close()
-
-	return pdb.ReadPDB(tmpfile.Name())
+	if len(missing) == len(chainList) {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no matching chains found: %s", strings.Join(missing, ",")))
+	}
+	return warn("chains not found: %s", strings.Join(missing, ","))
 }
 
+// ExtractChainsPDB returns the chains of entry listed in chainList, with their ALTLOC information
 func ExtractChainsPDB(entry *pdb.Entry, chainList []string, altLocList []byte) (*pdb.Entry, []byte, error) {
+	return ExtractChainsPDBContext(context.Background(), entry, chainList, altLocList)
+}
+
+// ExtractChainsPDBContext is ExtractChainsPDB with a context that stops the extraction, returning its error
+func ExtractChainsPDBContext(ctx context.Context, entry *pdb.Entry, chainList []string, altLocList []byte) (*pdb.Entry, []byte, error) {
 	// Create a new entry with only the specified chains
 	newEntry := &pdb.Entry{
 		Path:   entry.Path,
@@ -202,6 +665,9 @@ func ExtractChainsPDB(entry *pdb.Entry, chainList []string, altLocList []byte) (
 	atomIndex := 0
 
 	for _, chain := range entry.Chains {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
 		// Count atoms in this chain
 		atomCount := 0
 		for _, model := range chain.Models {
@@ -224,6 +690,74 @@ func ExtractChainsPDB(entry *pdb.Entry, chainList []string, altLocList []byte) (
 	return newEntry, newAltLocList, nil
 }
 
+// filterResidueRanges keeps the residues of entry selected by the residue ranges of selections,
+// together with their ALTLOC information
+func filterResidueRanges(entry *pdb.Entry, altLocList []byte, selections chainSelections) (*pdb.Entry, []byte) {
+	filteredEntry := &pdb.Entry{
+		Path:   entry.Path,
+		IdCode: entry.IdCode,
+		Chains: make([]*pdb.Chain, 0),
+		Scop:   entry.Scop,
+		Cath:   entry.Cath,
+	}
+
+	newAltLocList := make([]byte, 0)
+	atomIndex := 0
+	for _, chain := range entry.Chains {
+		newChain := &pdb.Chain{
+			Entry:    filteredEntry,
+			Ident:    chain.Ident,
+			SeqType:  chain.SeqType,
+			Sequence: chain.Sequence,
+			Models:   make([]*pdb.Model, 0),
+			Missing:  chain.Missing,
+		}
+		for _, model := range chain.Models {
+			newModel := &pdb.Model{
+				Entry:    filteredEntry,
+				Chain:    newChain,
+				Num:      model.Num,
+				Residues: make([]*pdb.Residue, 0),
+			}
+			for _, residue := range model.Residues {
+				selected := selections.Contains(chain.Ident, residue.SequenceNum, residue.InsertionCode)
+				for range residue.Atoms {
+					if selected && atomIndex < len(altLocList) {
+						newAltLocList = append(newAltLocList, altLocList[atomIndex])
+					}
+					atomIndex++
+				}
+				if selected {
+					newModel.Residues = append(newModel.Residues, residue)
+				}
+			}
+			if len(newModel.Residues) > 0 {
+				newChain.Models = append(newChain.Models, newModel)
+			}
+		}
+		if len(newChain.Models) > 0 {
+			filteredEntry.Chains = append(filteredEntry.Chains, newChain)
+		}
+	}
+	return filteredEntry, newAltLocList
+}
+
+// checkRangesPresent returns a no_match error if the residue ranges selected no residues from entry,
+// and warns about chains of entry none of whose residues are in their ranges
+func checkRangesPresent(entry, filtered *pdb.Entry, selections chainSelections) error {
+	if len(filtered.Chains) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no residues match the selection: %s", chains))
+	}
+	for _, selection := range selections {
+		if len(selection.Ranges) > 0 && entry.Chain(selection.ChainID) != nil && filtered.Chain(selection.ChainID) == nil {
+			if err := warn("no residues match %s", selection); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // filterByAltLoc filters atoms based on ALTLOC criteria
 func filterByAltLoc(entry *pdb.Entry, altLocList []byte, altlocFilter string) (*pdb.Entry, []byte, error) {
 	// Create a new entry with filtered atoms
@@ -271,13 +805,17 @@ func filterByAltLoc(entry *pdb.Entry, altLocList []byte, altlocFilter string) (*
 					altLoc byte
 				}
 				atomGroups := make(map[string][]atomWithIndex)
+				var atomNames []string
 
-				// Group atoms by name
+				// Group atoms by name, keeping the names in file order
 				for _, atom := range residue.Atoms {
 					var altLoc byte = ' '
 					if atomIndex < len(altLocList) {
 						altLoc = altLocList[atomIndex]
 					}
+					if _, seen := atomGroups[atom.Name]; !seen {
+						atomNames = append(atomNames, atom.Name)
+					}
 					atomGroups[atom.Name] = append(atomGroups[atom.Name], atomWithIndex{
 						atom:   atom,
 						index:  atomIndex,
@@ -287,7 +825,8 @@ func filterByAltLoc(entry *pdb.Entry, altLocList []byte, altlocFilter string) (*
 				}
 
 				// Apply ALTLOC filtering
-				for _, group := range atomGroups {
+				for _, name := range atomNames {
+					group := atomGroups[name]
 					if altlocFilter == "first" {
 						// Take the first ALTLOC when duplicates exist
 						if len(group) > 1 {
@@ -359,6 +898,36 @@ func buildCommandLine(cmd *cobra.Command, args []string, inputFile string) strin
 	if altloc != "" {
 		parts = append(parts, "--altloc", altloc)
 	}
+	if extractLigands != "" {
+		parts = append(parts, "--ligand", extractLigands)
+	}
+	if extractBox != "" {
+		parts = append(parts, "--box", extractBox)
+	}
+	if boxResidues {
+		parts = append(parts, "--box-residues")
+	}
+	if extractSS != "" {
+		parts = append(parts, "--ss", extractSS)
+	}
+	if ssElements != "" {
+		parts = append(parts, "--ss-element", ssElements)
+	}
+	if extractPolymer != "" {
+		parts = append(parts, "--polymer", extractPolymer)
+	}
+	if extractAssembly != "" {
+		parts = append(parts, "--assembly", extractAssembly)
+	}
+	if extractModels != "" {
+		parts = append(parts, "--models", extractModels)
+	}
+	if dropModels != "" {
+		parts = append(parts, "--drop-models", dropModels)
+	}
+	if keepRemarks != "" {
+		parts = append(parts, "--keep-remarks", keepRemarks)
+	}
 
 	// Add input file if not from stdin
 	if inputFile != "" {
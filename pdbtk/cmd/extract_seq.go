@@ -1,9 +1,10 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
@@ -12,19 +13,57 @@ import (
 )
 
 var (
-	seqChains string
-	seqOutput string
-	useSeqRes bool
+	seqChains  string
+	seqOutput  string
+	useSeqRes  bool
+	seqWrap    int
+	seqIDTmpl  string
+	seqNonStd  string
+	seqModels  bool
+	seqMapOut  string
+	seqPolymer string
+	seqAnnot   string
+	seqBatch   batchOptions
 )
 
+// seqAnnotations are the tracks that --annotate can add to each sequence
+var seqAnnotations = []string{"ss", "bfactor"}
+
+// seqIDPlaceholders are the placeholders supported by --id-template
+var seqIDPlaceholders = []string{"{file}", "{pdbid}", "{chain}", "{entity}", "{organism}", "{model}"}
+
 var extractSeqCmd = &cobra.Command{
-	Use:   "extract-seq [flags] [input_file]",
+	Use:   "extract-seq [flags] [input_file...]",
 	Short: "Extract sequences from chains in a PDB file",
 	Long: `Extract sequences from chains in a PDB structure file.
 The output is in FASTA format with sequence IDs in the format: >{pdbfilename_no_dotpdb}_{chain}
+Use --id-template to build the IDs from other fields: {file} (filename without extension), {pdbid}
+(ID code from the HEADER record), {chain}, {entity} (MOL_ID from COMPND), {organism}
+(ORGANISM_SCIENTIFIC from SOURCE, with spaces replaced by underscores) and {model} (model number).
 
-If no chains are specified, all chains will be extracted.
+Sequences are taken from the first model. With --per-model, a chain whose sequence differs between
+models is written once per model, with _model{N} appended to its ID (unless the template uses {model}).
+
+If no chains are specified, all chains will be extracted. A chain ID can be followed by residue
+ranges (A:10-120 or B:5-40,200-250) to extract only part of the chain's sequence.
+--polymer protein, dna or rna (or a comma-separated combination) only extracts chains of those types.
+Ligands and waters are not part of the polymer sequences. Modified residues (e.g. MSE, or residues
+listed in MODRES records) are written as their standard parent residue (MSE as M) by default; use
+--nonstandard x to write them as X, or --nonstandard skip to leave them out.
+Sequence lines are wrapped at 80 characters; use --wrap to change the width, or --wrap 0 to write
+each sequence on a single line.
 If no input file is specified, reads from stdin.
+With --map-output, a tab-separated table mapping each FASTA position to the chain, residue number,
+insertion code and residue name of the structure is written alongside the sequences, so alignment
+positions can be translated back onto the structure. Gap positions have no row.
+--annotate adds tracks parallel to each sequence, as records following it with the same length, in
+the A3M style of feature files: ss writes a {id}_ss record with the secondary structure of each
+residue from the HELIX and SHEET records (H helix, E strand, C coil), and bfactor a {id}_bfactor
+record with the mean B-factor of each residue's atoms divided by 10 as a digit from 0 to 9 (the
+pLDDT confidence of predicted models). Gap positions are '-' in every track.
+Multiple input files (or glob patterns) are written to a single combined FASTA file, or to one file
+per input with --outdir. Sequence IDs are made unique by appending _2, _3, ... to repeated IDs, and
+inputs that fail are reported and skipped.
 
 Examples:
   # Extract sequences from all chains
@@ -37,103 +76,273 @@ Examples:
   pdbtk extract-seq --output 1a02_all.fasta 1a02.pdb
 
   # Extract from stdin
-  cat 1a02.pdb | pdbtk extract-seq --chains B,C`,
-	Args: cobra.MaximumNArgs(1),
+  cat 1a02.pdb | pdbtk extract-seq --chains B,C
+
+  # Use the PDB ID, chain and source organism in the sequence IDs
+  pdbtk extract-seq --id-template "{pdbid}_{chain} {organism}" 1a02.pdb
+
+  # Write a sequence per model for NMR ensembles whose models differ
+  pdbtk extract-seq --per-model ensemble.pdb
+
+  # Write a table mapping sequence positions to residue numbers
+  pdbtk extract-seq --map-output 1a02_map.tsv 1a02.pdb > 1a02.fasta
+
+  # Add secondary structure and B-factor tracks to each sequence
+  pdbtk extract-seq --annotate ss,bfactor --wrap 0 model.pdb
+
+  # Write one FASTA file per PDB file into seqs/
+  pdbtk extract-seq --outdir seqs/ *.pdb`,
+	Args: cobra.ArbitraryArgs,
 	RunE: runExtractSeq,
 }
 
 func init() {
-	extractSeqCmd.Flags().StringVarP(&seqChains, "chains", "c", "", "Comma-separated list of chain IDs to extract (default: all chains)")
+	extractSeqCmd.Flags().StringVarP(&seqChains, "chains", "c", "", "Comma-separated list of chain IDs to extract, optionally with residue ranges (A:10-120) (default: all chains)")
 	extractSeqCmd.Flags().StringVar(&seqChains, "chain", "", "Alias for --chains")
 	extractSeqCmd.Flags().StringVarP(&seqOutput, "output", "o", "", "Output file (default: stdout)")
 	extractSeqCmd.Flags().BoolVar(&useSeqRes, "seqres", false, "Use SEQRES records instead of ATOM records")
+	extractSeqCmd.Flags().StringVar(&seqIDTmpl, "id-template", "{file}_{chain}", "Template for FASTA sequence IDs ({file}, {pdbid}, {chain}, {entity}, {organism})")
+	extractSeqCmd.Flags().StringVar(&seqNonStd, "nonstandard", "parent", "How to write modified residues: parent (map to the standard residue), x or skip")
+	extractSeqCmd.Flags().BoolVar(&seqModels, "per-model", false, "Write a sequence for every model when a chain's sequence differs between models")
+	extractSeqCmd.Flags().StringVar(&seqMapOut, "map-output", "", "Write a TSV mapping FASTA positions to chain, residue number, insertion code and residue name")
+	extractSeqCmd.Flags().StringVar(&seqPolymer, "polymer", "", "Only extract chains of these polymer types: protein, dna, rna (comma-separated)")
+	extractSeqCmd.Flags().StringVar(&seqAnnot, "annotate", "", "Add tracks parallel to each sequence: ss (secondary structure) and bfactor (comma-separated)")
+	extractSeqCmd.Flags().IntVar(&seqWrap, "wrap", 80, "Wrap sequence lines at this many characters (0: no wrapping)")
+	addBatchFlags(extractSeqCmd, &seqBatch, "{stem}.fasta")
+	seqBatch.combine = true
 }
 
 func runExtractSeq(cmd *cobra.Command, args []string) error {
-	var inputFile string
-	var isStdin bool
-
-	if len(args) > 0 {
-		inputFile = args[0]
-		isStdin = false
-		// Check if input file exists
-		if err := CheckFileExists(inputFile); err != nil {
-			return err
+	if seqWrap < 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --wrap: %d (must be 0 or more)", seqWrap))
+	}
+	if err := checkIDTemplate(seqIDTmpl); err != nil {
+		return err
+	}
+	switch seqNonStd {
+	case "parent", "x", "skip":
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --nonstandard: %s (must be parent, x or skip)", seqNonStd))
+	}
+	if seqMapOut != "" && useSeqRes {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--map-output cannot be combined with --seqres"))
+	}
+	var annotations []string
+	if seqAnnot != "" {
+		for _, track := range strings.Split(seqAnnot, ",") {
+			track = strings.TrimSpace(track)
+			if !containsString(seqAnnotations, track) {
+				return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --annotate: %s (must be ss or bfactor)", track))
+			}
+			if !containsString(annotations, track) {
+				annotations = append(annotations, track)
+			}
 		}
-		// Check if it's a PDB file
-		inputExt := strings.ToLower(filepath.Ext(inputFile))
-		if inputExt != ".pdb" {
-			return fmt.Errorf("only PDB files are supported, got: %s", inputExt)
+		if useSeqRes {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("--annotate cannot be combined with --seqres"))
 		}
-	} else {
-		// Check if stdin is available
-		stat, err := os.Stdin.Stat()
-		if err != nil {
-			return fmt.Errorf("failed to check stdin: %v", err)
+	}
+	if seqMapOut != "" && seqBatch.outdir != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--map-output cannot be combined with --outdir"))
+	}
+
+	var polymerTypes map[moleculeType]bool
+	if seqPolymer != "" {
+		var err error
+		if polymerTypes, err = parseMoleculeTypes(seqPolymer); err != nil {
+			return err
 		}
-		if (stat.Mode() & os.ModeCharDevice) != 0 {
-			return fmt.Errorf("no input file specified and stdin is not available")
+		if polymerTypes[moleculeLigand] || polymerTypes[moleculeWater] {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --polymer: %s (must be protein, dna or rna)", seqPolymer))
 		}
-		inputFile = ""
-		isStdin = true
 	}
 
-	// Parse chain IDs if specified
-	var chainList []string
+	// Parse chain IDs and residue ranges if specified
+	var selections chainSelections
 	if seqChains != "" {
-		chainList = strings.Split(seqChains, ",")
-		for i, chain := range chainList {
-			chainList[i] = strings.TrimSpace(chain)
-			if len(chainList[i]) != 1 {
-				return fmt.Errorf("invalid chain ID: %s (must be single character)", chainList[i])
-			}
+		var err error
+		if selections, err = parseChainSelections(seqChains); err != nil {
+			return err
+		}
+		if selections.HasRanges() && useSeqRes {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("residue ranges in --chains cannot be combined with --seqres"))
+		}
+	}
+
+	ids := make(fastaIDs)
+	var mapping bytes.Buffer
+	err := runBatch(cmd.Context(), args, seqOutput, seqBatch, func(inputFile string, writer io.Writer) error {
+		if seqBatch.outdir != "" {
+			// IDs only need to be unique within each output file
+			ids = make(fastaIDs)
 		}
+		return extractSeqFile(cmd.Context(), inputFile, selections, polymerTypes, annotations, ids, writer, &mapping)
+	})
+	if seqMapOut == "" || (err != nil && mapping.Len() == 0) {
+		return err
 	}
+	// The map is written even if some inputs failed, to match the sequences that were written
+	if mapErr := writeOutput(cmd.Context(), seqMapOut, func(w io.Writer) error {
+		return writePositionMap(w, mapping.Bytes())
+	}); mapErr != nil {
+		return withCode(ErrCodeIO, fmt.Errorf("failed to write --map-output: %v", mapErr))
+	}
+	return err
+}
 
+// extractSeqFile extracts the sequences of a single input, of all polymer types or only polymerTypes,
+// and writes them to writer as FASTA with the annotation tracks, and the position map rows of the
+// sequences to mapping
+func extractSeqFile(ctx context.Context, inputFile string, selections chainSelections, polymerTypes map[moleculeType]bool, annotations []string, ids fastaIDs, writer io.Writer, mapping io.Writer) error {
 	// Read the PDB file
-	var entry *pdb.Entry
-	var err error
-	if isStdin {
-		content, err := readAllFromStdin()
-		if err != nil {
-			return fmt.Errorf("failed to read from stdin: %v", err)
-		}
-		entry, err = readPDBFromContent(content)
-	} else {
-		entry, err = readPDB(inputFile)
+	content, err := readInputContent(ctx, inputFile)
+	if err != nil {
+		return err
 	}
+	extendedEntry, err := parseInputEntry(ctx, inputFile, content)
 	if err != nil {
-		return fmt.Errorf("failed to read PDB file: %v", err)
+		return err
+	}
+	entry := extendedEntry.Entry
+
+	chainList := selections.ChainIDs()
+	if err := checkChainsPresent(entry, chainList); err != nil {
+		return err
+	}
+
+	file, err := ParsePDBRecordsContext(ctx, strings.NewReader(string(content)))
+	if err != nil {
+		return parseError(ctx, err)
+	}
+	header := headerLines(content)
+	modres := parseModres(header)
+	models := file.Models()
+	atomSequences := selections.filterSequences(chainSequences(file, models[0], modres, seqNonStd))
+	if selections.HasRanges() && !anySequence(atomSequences, chainList) {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no residues match the selection: %s", seqChains))
 	}
 
 	// Extract sequences
-	sequences, err := extractSequencesPDB(entry, chainList, useSeqRes)
+	sequences, err := extractSequencesPDB(entry, atomSequences, chainList, useSeqRes)
 	if err != nil {
-		return fmt.Errorf("failed to extract sequences: %v", err)
+		return fmt.Errorf("failed to extract sequences: %w", err)
 	}
 
-	// Write the output
-	if seqOutput == "" || seqOutput == "-" {
-		// Write to stdout
-		return writeFASTAToWriter(sequences, os.Stdout, inputFile)
-	} else {
-		// Write to file
-		file, err := os.Create(seqOutput)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %v", err)
+	_, entities := parseEntities(header)
+	// The parser falls back to an ID code derived from the filename, which is meaningless for stdin
+	idCode := (&PDBFile{Header: header}).IdCode()
+	if idCode == "" && inputFile != "" {
+		idCode = entry.IdCode
+	}
+	// With --per-model, collect the sequences of the other models to compare with the first
+	var modelSequences []map[byte][]sequencePosition
+	if seqModels && !useSeqRes {
+		for _, model := range models {
+			modelSequences = append(modelSequences, selections.filterSequences(chainSequences(file, model, modres, seqNonStd)))
+		}
+	}
+
+	chainTypes := chainPolymerTypes(file)
+	var records []fastaRecord
+	for _, chain := range entry.Chains {
+		sequence, ok := sequences[string(chain.Ident)]
+		if !ok || (polymerTypes != nil && !polymerTypes[chainTypes[chain.Ident]]) {
+			continue
+		}
+		entity := entities[chain.Ident]
+
+		perModel := make([]string, len(modelSequences))
+		differs := false
+		for i, chainSeqs := range modelSequences {
+			perModel[i] = positionsToString(chainSeqs[chain.Ident])
+			differs = differs || perModel[i] != sequence
+		}
+		if !differs {
+			id := renderIDTemplate(seqIDTmpl, inputFile, idCode, chain.Ident, entity, models[0])
+			records = append(records, fastaRecord{ID: ids.unique(id), Sequence: sequence, Positions: atomSequences[chain.Ident]})
+			continue
+		}
+		for i, modelSequence := range perModel {
+			if modelSequence == "" {
+				continue
+			}
+			id := renderIDTemplate(seqIDTmpl, inputFile, idCode, chain.Ident, entity, models[i])
+			if !strings.Contains(seqIDTmpl, "{model}") {
+				id = fmt.Sprintf("%s_model%d", id, models[i])
+			}
+			records = append(records, fastaRecord{ID: ids.unique(id), Sequence: modelSequence, Positions: modelSequences[i][chain.Ident]})
 		}
-		defer file.Close()
-		return writeFASTAToWriter(sequences, file, inputFile)
 	}
+
+	writePositionMapRows(mapping, records)
+	if len(annotations) > 0 {
+		records = annotateSequences(records, file, header, annotations, ids)
+	}
+	return writeFASTAToWriter(records, writer)
 }
 
-func extractSequencesPDB(entry *pdb.Entry, chainList []string, useSeqRes bool) (map[string]string, error) {
+// annotateSequences adds the requested annotation tracks after each sequence record: the secondary
+// structure (ss) or the B-factor digit (bfactor) of each position, with '-' for gaps
+func annotateSequences(records []fastaRecord, file *PDBFile, header []string, annotations []string, ids fastaIDs) []fastaRecord {
+	elements := parseSecondaryStructure(header)
+	bfactors := make(map[ResidueKey][]float64)
+	for _, atom := range file.Atoms {
+		key := atom.Residue()
+		bfactors[key] = append(bfactors[key], atom.TempFactor)
+	}
+
+	track := func(positions []sequencePosition, annotate func(ResidueKey) byte) string {
+		var b strings.Builder
+		for _, position := range positions {
+			if position.Residue == nil {
+				b.WriteByte('-')
+			} else {
+				b.WriteByte(annotate(*position.Residue))
+			}
+		}
+		return b.String()
+	}
+	secondaryStructure := func(key ResidueKey) byte {
+		for _, element := range elements {
+			if element.contains(key) {
+				return element.Type
+			}
+		}
+		return 'C'
+	}
+	bfactorDigit := func(key ResidueKey) byte {
+		sum := 0.0
+		for _, b := range bfactors[key] {
+			sum += b
+		}
+		digit := int(sum / float64(len(bfactors[key])) / 10)
+		return byte('0' + max(0, min(9, digit)))
+	}
+
+	var annotated []fastaRecord
+	for _, record := range records {
+		annotated = append(annotated, record)
+		for _, name := range annotations {
+			annotate := secondaryStructure
+			if name == "bfactor" {
+				annotate = bfactorDigit
+			}
+			annotated = append(annotated, fastaRecord{ID: ids.unique(record.ID + "_" + name), Sequence: track(record.Positions, annotate)})
+		}
+	}
+	return annotated
+}
+
+func extractSequencesPDB(entry *pdb.Entry, atomSequences map[byte][]sequencePosition, chainList []string, useSeqRes bool) (map[string]string, error) {
 	sequences := make(map[string]string)
 
 	// If no chains specified, extract all chains
 	if len(chainList) == 0 {
 		for _, chain := range entry.Chains {
-			sequence := extractChainSequence(chain, useSeqRes)
+			sequence, err := extractChainSequence(chain, atomSequences[chain.Ident], useSeqRes)
+			if err != nil {
+				return nil, err
+			}
 			if sequence != "" {
 				sequences[string(chain.Ident)] = sequence
 			}
@@ -149,7 +358,10 @@ func extractSequencesPDB(entry *pdb.Entry, chainList []string, useSeqRes bool) (
 
 		for _, chain := range entry.Chains {
 			if validChains[chain.Ident] {
-				sequence := extractChainSequence(chain, useSeqRes)
+				sequence, err := extractChainSequence(chain, atomSequences[chain.Ident], useSeqRes)
+				if err != nil {
+					return nil, err
+				}
 				if sequence != "" {
 					sequences[string(chain.Ident)] = sequence
 				}
@@ -160,7 +372,7 @@ func extractSequencesPDB(entry *pdb.Entry, chainList []string, useSeqRes bool) (
 	return sequences, nil
 }
 
-func extractChainSequence(chain *pdb.Chain, useSeqRes bool) string {
+func extractChainSequence(chain *pdb.Chain, atomSequence []sequencePosition, useSeqRes bool) (string, error) {
 	// If --seqres flag is set, only use SEQRES records
 	if useSeqRes {
 		if len(chain.Sequence) > 0 {
@@ -168,70 +380,244 @@ func extractChainSequence(chain *pdb.Chain, useSeqRes bool) string {
 			for _, residue := range chain.Sequence {
 				sequence.WriteByte(byte(residue))
 			}
-			return sequence.String()
+			return sequence.String(), nil
 		}
 		// No SEQRES available - warn the user
-		fmt.Fprintf(os.Stderr, "Warning: --seqres flag specified but no SEQRES records found for chain %c\n", chain.Ident)
-		return ""
+		return "", warn("--seqres flag specified but no SEQRES records found for chain %c", chain.Ident)
 	}
 
-	// Default behavior: extract sequence from ATOM records with gap handling
-	if len(chain.Models) == 0 {
-		return ""
+	// Default behavior: the sequence from ATOM records with gap handling
+	return positionsToString(atomSequence), nil
+}
+
+// filterSequences restricts the sequences of chains with residue ranges to the residues in their ranges.
+// Gaps are kept where they fall between two residues in the same range.
+func (s chainSelections) filterSequences(sequences map[byte][]sequencePosition) map[byte][]sequencePosition {
+	if !s.HasRanges() {
+		return sequences
+	}
+	for chainID, positions := range sequences {
+		var filtered, gaps []sequencePosition
+		contiguous := false
+		for _, position := range positions {
+			if position.Residue == nil {
+				gaps = append(gaps, position)
+				continue
+			}
+			if s.Contains(chainID, position.Residue.ResSeq, position.Residue.ICode) {
+				if contiguous {
+					filtered = append(filtered, gaps...)
+				}
+				filtered = append(filtered, position)
+				contiguous = true
+			} else {
+				contiguous = false
+			}
+			gaps = nil
+		}
+		sequences[chainID] = filtered
 	}
+	return sequences
+}
 
-	// Use the first model
-	model := chain.Models[0]
-	if len(model.Residues) == 0 {
-		return ""
+// anySequence reports whether any of the chains has a non-empty sequence
+func anySequence(sequences map[byte][]sequencePosition, chainList []string) bool {
+	for _, chainID := range chainList {
+		if len(sequences[chainID[0]]) > 0 {
+			return true
+		}
 	}
+	return false
+}
 
+// positionsToString returns the one-letter codes of a sequence as a string
+func positionsToString(positions []sequencePosition) string {
 	var sequence strings.Builder
-	prevResNum := model.Residues[0].SequenceNum - 1
+	for _, position := range positions {
+		sequence.WriteByte(position.Code)
+	}
+	return sequence.String()
+}
+
+// sequencePosition is one position of a sequence extracted from coordinate records
+type sequencePosition struct {
+	Code    byte
+	Residue *ResidueKey // nil for gap positions
+}
+
+// chainSequences extracts the polymer sequence of each chain of a model. Gaps in the residue
+// numbering are filled with '-'. Waters and ligands are left out; modified residues are written as
+// their parent residue, as X or skipped, depending on mode (parent, x or skip).
+func chainSequences(file *PDBFile, model int, modres map[string]string, mode string) map[byte][]sequencePosition {
+	sequences := make(map[byte][]sequencePosition)
+	lastResSeq := make(map[byte]int)
 
-	for _, residue := range model.Residues {
-		// Add gap characters for missing residues
-		gap := residue.SequenceNum - prevResNum - 1
-		if gap > 0 {
-			for i := 0; i < gap; i++ {
-				sequence.WriteByte('-')
+	for _, residue := range groupResidues(file.Atoms) {
+		atom := residue[0]
+		if atom.Model != model || waterResidues[atom.ResName] {
+			continue
+		}
+
+		code, standard := oneLetterCode(atom.ResName)
+		if !standard {
+			if atom.Het && !isPolymerResidue(residue) {
+				if _, modified := modres[atom.ResName]; !modified {
+					// A ligand rather than a modified residue of the chain
+					continue
+				}
+			}
+			switch mode {
+			case "skip":
+				// Leave the residue out without marking its position as a gap
+				lastResSeq[atom.ChainID] = atom.ResSeq
+				continue
+			case "x":
+				code = 'X'
+			default:
+				code = parentOneLetterCode(atom.ResName, modres)
 			}
 		}
 
-		// residue.Name is already a single-letter code (seq.Residue type is a byte)
-		sequence.WriteByte(byte(residue.Name))
-		prevResNum = residue.SequenceNum
+		key := atom.Residue()
+		if last, ok := lastResSeq[atom.ChainID]; ok {
+			for gap := key.ResSeq - last - 1; gap > 0; gap-- {
+				sequences[atom.ChainID] = append(sequences[atom.ChainID], sequencePosition{Code: '-'})
+			}
+		}
+		lastResSeq[atom.ChainID] = key.ResSeq
+		sequences[atom.ChainID] = append(sequences[atom.ChainID], sequencePosition{Code: code, Residue: &key})
 	}
+	return sequences
+}
 
-	return sequence.String()
+// fastaRecord is a single sequence of a FASTA file
+type fastaRecord struct {
+	ID        string
+	Sequence  string
+	Positions []sequencePosition // the residues of the sequence; nil for SEQRES sequences
 }
 
-func writeFASTAToWriter(sequences map[string]string, writer io.Writer, inputFile string) error {
-	// Get base filename without extension
-	var baseName string
-	if inputFile == "" {
-		baseName = "stdin"
-	} else {
-		baseName = strings.TrimSuffix(filepath.Base(inputFile), filepath.Ext(inputFile))
-	}
+// positionMapHeader is the header row of the --map-output table
+const positionMapHeader = "sequence_id\tposition\tchain\tresnum\ticode\tresname"
 
-	// Write sequences in FASTA format
-	for chainID, sequence := range sequences {
-		fmt.Fprintf(writer, ">%s_%s\n", baseName, chainID)
-		// Write sequence in lines of 80 characters
-		for i := 0; i < len(sequence); i += 80 {
-			end := i + 80
-			if end > len(sequence) {
-				end = len(sequence)
+// writePositionMapRows writes one --map-output row per residue position of each record
+func writePositionMapRows(writer io.Writer, records []fastaRecord) {
+	for _, record := range records {
+		for i, position := range record.Positions {
+			if position.Residue == nil {
+				continue
 			}
-			fmt.Fprintf(writer, "%s\n", sequence[i:end])
+			icode := strings.TrimSpace(string(position.Residue.ICode))
+			fmt.Fprintf(writer, "%s\t%d\t%c\t%d\t%s\t%s\n", record.ID, i+1, position.Residue.ChainID,
+				position.Residue.ResSeq, icode, position.Residue.ResName)
 		}
 	}
+}
+
+// writePositionMap writes the --map-output table with the given rows
+func writePositionMap(writer io.Writer, rows []byte) error {
+	if _, err := fmt.Fprintln(writer, positionMapHeader); err != nil {
+		return err
+	}
+	_, err := writer.Write(rows)
+	return err
+}
+
+// fastaIDs tracks the sequence IDs written so far, to keep them unique
+type fastaIDs map[string]int
+
+// unique returns id, or id with a _2, _3, ... suffix if it has been used before
+func (ids fastaIDs) unique(id string) string {
+	ids[id]++
+	if ids[id] == 1 {
+		return id
+	}
+	for {
+		candidate := fmt.Sprintf("%s_%d", id, ids[id])
+		if ids[candidate] == 0 {
+			ids[candidate]++
+			return candidate
+		}
+		ids[id]++
+	}
+}
+
+func writeFASTAToWriter(records []fastaRecord, writer io.Writer) error {
+	// Write sequences in FASTA format
+	for _, record := range records {
+		fmt.Fprintf(writer, ">%s\n", record.ID)
+		writeWrappedSequence(writer, record.Sequence, seqWrap)
+	}
 
 	// Final newline
-	if len(sequences) > 0 {
+	if len(records) > 0 {
 		fmt.Fprintf(writer, "\n")
 	}
 
 	return nil
 }
+
+// writeWrappedSequence writes a sequence in lines of at most width characters, or on one line if width is 0
+func writeWrappedSequence(writer io.Writer, sequence string, width int) {
+	if width <= 0 {
+		fmt.Fprintf(writer, "%s\n", sequence)
+		return
+	}
+	for i := 0; i < len(sequence); i += width {
+		end := i + width
+		if end > len(sequence) {
+			end = len(sequence)
+		}
+		fmt.Fprintf(writer, "%s\n", sequence[i:end])
+	}
+}
+
+// checkIDTemplate returns an error if template contains an unknown placeholder
+func checkIDTemplate(template string) error {
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			return nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --id-template: unterminated placeholder in %q", template))
+		}
+		placeholder := rest[start : start+end+1]
+		known := false
+		for _, p := range seqIDPlaceholders {
+			if p == placeholder {
+				known = true
+			}
+		}
+		if !known {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --id-template: unknown placeholder %s (supported: %s)", placeholder, strings.Join(seqIDPlaceholders, ", ")))
+		}
+		rest = rest[start+end+1:]
+	}
+}
+
+// renderIDTemplate builds the FASTA ID of a chain from the --id-template placeholders
+func renderIDTemplate(template, inputFile, idCode string, chainID byte, entity *entityInfo, model int) string {
+	file := "stdin"
+	if inputFile != "" {
+		name := inputBaseName(inputFile)
+		file = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	entityID, organism := "", ""
+	if entity != nil {
+		entityID = entity.ID
+		organism = strings.ReplaceAll(entity.Organism, " ", "_")
+	}
+
+	replacer := strings.NewReplacer(
+		"{file}", file,
+		"{pdbid}", idCode,
+		"{chain}", string(chainID),
+		"{entity}", entityID,
+		"{organism}", organism,
+		"{model}", fmt.Sprint(model),
+	)
+	return replacer.Replace(template)
+}
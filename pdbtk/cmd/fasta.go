@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// parseFASTA parses FASTA content into records. Whitespace inside sequences is ignored and
+// residue letters are converted to upper case.
+func parseFASTA(content []byte) ([]fastaRecord, error) {
+	var records []fastaRecord
+	var sequence strings.Builder
+	flush := func() {
+		if len(records) > 0 {
+			records[len(records)-1].Sequence = sequence.String()
+		}
+		sequence.Reset()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, ">"):
+			flush()
+			id := ""
+			if fields := strings.Fields(line[1:]); len(fields) > 0 {
+				id = fields[0]
+			}
+			records = append(records, fastaRecord{ID: id})
+		default:
+			if len(records) == 0 {
+				return nil, fmt.Errorf("sequence data before the first '>' header line")
+			}
+			for _, field := range strings.Fields(line) {
+				sequence.WriteString(strings.ToUpper(field))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return records, nil
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// filesFrom is the value of the --files-from flag. The list is read by expandFilesFrom before the
+// command line is parsed, so that its paths reach every command as positional arguments.
+var filesFrom string
+
+// expandFilesFrom replaces a --files-from (-l) flag in args with the input files listed in the named
+// file, or on stdin for "-", appended as positional arguments
+func expandFilesFrom(args []string) ([]string, error) {
+	var rest []string
+	source := ""
+	found := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		switch {
+		case arg == "--files-from" || arg == "-l":
+			if i+1 == len(args) {
+				return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("flag needs an argument: %s", arg))
+			}
+			i++
+			source, found = args[i], true
+		case strings.HasPrefix(arg, "--files-from="):
+			source, found = strings.TrimPrefix(arg, "--files-from="), true
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if !found {
+		return args, nil
+	}
+
+	paths, err := readFileList(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("no input files listed in --files-from %s", source))
+	}
+	if !containsString(rest, "--") {
+		// Paths that start with a dash must not be parsed as flags
+		rest = append(rest, "--")
+	}
+	return append(rest, paths...), nil
+}
+
+// readFileList reads a list of paths, one per line or separated by NUL characters (as written by
+// find -print0), from a file or from stdin for "-". Blank lines and lines starting with # are skipped.
+func readFileList(source string) ([]string, error) {
+	var content []byte
+	var err error
+	if source == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(source)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, withCode(ErrCodeInputNotFound, fmt.Errorf("file does not exist: %s", source))
+		}
+		return nil, withCode(ErrCodeIO, fmt.Errorf("failed to read file list %s: %v", source, err))
+	}
+
+	separator := []byte("\n")
+	if bytes.IndexByte(content, 0) >= 0 {
+		separator = []byte{0}
+	}
+	var paths []string
+	for _, entry := range bytes.Split(content, separator) {
+		path := strings.TrimRight(string(entry), "\r\n")
+		if separator[0] == '\n' {
+			path = strings.TrimSpace(path)
+			if strings.HasPrefix(path, "#") {
+				continue
+			}
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
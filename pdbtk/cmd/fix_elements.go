@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	fixElementsOutput string
+	fixElementsBatch  batchOptions
+)
+
+var fixElementsCmd = &cobra.Command{
+	Use:   "fix-elements [flags] [input_file...]",
+	Short: "Recompute the element column of every atom",
+	Long: `Recompute the element symbol (columns 77-78) of every atom from its atom name and residue,
+fixing files whose element columns are blank or wrong.
+
+Atoms of standard amino acids and nucleotides take the first letter of their name. Single-atom
+HETATM residues whose atom name is an element symbol are treated as ions (e.g. CA in residue CA
+is calcium, not an alpha carbon), and two-letter elements such as CL, BR, FE and SE are recognised
+in ligand atom names. The number of changed element symbols is reported on stderr.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Fix the element columns of a PDB file
+  pdbtk fix-elements 1a02.pdb --output 1a02_fixed.pdb
+
+  # Fix every PDB file in a directory
+  pdbtk fix-elements --outdir fixed/ structures/*.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runFixElements,
+}
+
+func init() {
+	fixElementsCmd.Flags().StringVarP(&fixElementsOutput, "output", "o", "", "Output file (default: stdout)")
+	addBatchFlags(fixElementsCmd, &fixElementsBatch, "{name}")
+}
+
+func runFixElements(cmd *cobra.Command, args []string) error {
+	return runBatch(cmd.Context(), args, fixElementsOutput, fixElementsBatch, func(inputFile string, writer io.Writer) error {
+		return fixElementsFile(cmd, inputFile, writer)
+	})
+}
+
+// fixElementsFile recomputes the element symbols of a single input and writes the result to writer
+func fixElementsFile(cmd *cobra.Command, inputFile string, writer io.Writer) error {
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	changed := 0
+	unknown := 0
+	for i, element := range inferElements(file.Atoms) {
+		atom := file.Atoms[i]
+		if element == "" {
+			unknown++
+			continue
+		}
+		if !strings.EqualFold(atom.Element, element) {
+			changed++
+		}
+		atom.Element = element
+	}
+	if unknown > 0 {
+		if err := warn("could not infer the element of %d atoms, leaving them unchanged", unknown); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Changed %d element symbols\n", changed)
+
+	return writePDBRecords(file, writer, recordCommandLine(cmd, nil, inputFile))
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import "math"
+
+// vec3 is a point or direction in Cartesian space
+type vec3 [3]float64
+
+func (a vec3) add(b vec3) vec3 {
+	return vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func (a vec3) sub(b vec3) vec3 {
+	return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func (a vec3) scale(s float64) vec3 {
+	return vec3{a[0] * s, a[1] * s, a[2] * s}
+}
+
+func (a vec3) dot(b vec3) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func (a vec3) cross(b vec3) vec3 {
+	return vec3{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func (a vec3) length() float64 {
+	return math.Sqrt(a.dot(a))
+}
+
+// unit returns a scaled to length 1, or the zero vector if a has no length
+func (a vec3) unit() vec3 {
+	l := a.length()
+	if l == 0 {
+		return vec3{}
+	}
+	return a.scale(1 / l)
+}
+
+// distance returns the distance between two points
+func distance(a, b vec3) float64 {
+	return a.sub(b).length()
+}
+
+// Coord returns the position of the atom
+func (a *AtomRecord) Coord() vec3 {
+	return vec3{a.X, a.Y, a.Z}
+}
+
+// SetCoord moves the atom to p
+func (a *AtomRecord) SetCoord(p vec3) {
+	a.X, a.Y, a.Z = p[0], p[1], p[2]
+}
+
+// placeAtom returns the position of an atom d bonded to c, given the bond length c-d, the angle b-c-d
+// and the torsion a-b-c-d in degrees (the natural extension reference frame construction)
+func placeAtom(a, b, c vec3, bond, angle, torsion float64) vec3 {
+	theta := angle * math.Pi / 180
+	phi := torsion * math.Pi / 180
+
+	bc := c.sub(b).unit()
+	n := b.sub(a).cross(bc).unit()
+	m := n.cross(bc)
+
+	d := bc.scale(-bond * math.Cos(theta)).
+		add(m.scale(bond * math.Sin(theta) * math.Cos(phi))).
+		add(n.scale(bond * math.Sin(theta) * math.Sin(phi)))
+	return c.add(d)
+}
+
+// dihedral returns the torsion angle a-b-c-d in degrees, from -180 to 180
+func dihedral(a, b, c, d vec3) float64 {
+	b1, b2, b3 := b.sub(a), c.sub(b), d.sub(c)
+	n1, n2 := b1.cross(b2), b2.cross(b3)
+	return math.Atan2(b2.length()*b1.dot(n2), n1.dot(n2)) * 180 / math.Pi
+}
@@ -1,30 +1,67 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/spf13/cobra"
 )
 
 var (
-	getOutput string
-	getFormat string
+	getOutput        string
+	getFormat        string
+	getOutdir        string
+	getUniprot       bool
+	getRateLimit     float64
+	getMaxConcurrent int
+	getRetries       int
+	getWithMetadata  bool
 )
 
+// retryDelay is the wait before the first retry of a download, which grows with every retry
+const retryDelay = 500 * time.Millisecond
+
 var getCmd = &cobra.Command{
-	Use:   "get [flags] <pdb_code>",
+	Use:   "get [flags] <pdb_code|uniprot_accession> [...]",
 	Short: "Download a PDB file from the RCSB PDB database",
 	Long: `Download a PDB file from the RCSB PDB database using the PDB code.
-The file will be downloaded from https://files.rcsb.org/download/{pdb_code}.pdb
+The file will be downloaded from https://files.rcsb.org/download/{pdb_code}.pdb; set PDBTK_RCSB_FILES_URL
+to use a mirror.
 
 By default, the file is saved as {pdb_code}.pdb in the current directory.
 Use --output to specify a different filename or "-" to output to stdout.
 Use --format to specify the file format (pdb, pdb.gz).
+Several PDB codes can be downloaded in one run; use --outdir to choose the directory they are saved in.
+
+With --uniprot, the arguments are UniProt accessions and every model of each protein known to the
+3D-Beacons network (experimental structures from PDBe, AlphaFold DB, SWISS-MODEL and other providers)
+is downloaded in its original format, as {accession}_{provider}_{model_id}.{pdb|cif}. The 3D-Beacons
+summary of each model (provider, model category, creation date, sequence identity, coverage, UniProt
+range, ...) is saved with the local file name in {accession}_models.json as provenance metadata.
+Set PDBTK_3DBEACONS_URL to use another 3D-Beacons hub.
+
+For bulk downloads, --rate-limit caps the number of requests per second and --max-concurrent the number
+of downloads running at once (1 by default), to stay within the usage guidelines of RCSB and PDBe.
+Requests are sent with the User-Agent pdbtk/{version}; use --user-agent or PDBTK_USER_AGENT to identify
+your pipeline, e.g. with a contact address.
+
+Every download is verified before it is saved: its size must match the Content-Length of the response,
+gzipped files (pdb.gz) must decompress, and checksums published by the server in the Content-MD5,
+Digest or Repr-Digest headers must match. Downloads that fail verification or are cut off are retried
+up to --retries times, and are never saved incomplete.
+
+With --with-metadata, a JSON sidecar is written next to each downloaded file as {file}.json, recording
+where and when it was retrieved: the entry, source URL, retrieval time, format, and the title,
+experimental method and resolution of the entry from the RCSB Data API (or from 3D-Beacons with
+--uniprot). Entries whose metadata cannot be looked up get a sidecar without them and a warning.
 
 Examples:
   # Download 1A02 as PDB file
@@ -37,22 +74,53 @@ Examples:
   pdbtk get --output - 1A02
 
   # Download to specific file
-  pdbtk get --output my_structure.pdb 1A02`,
-	Args: cobra.ExactArgs(1),
+  pdbtk get --output my_structure.pdb 1A02
+
+  # Download several entries into a directory
+  pdbtk get --outdir structures/ 1A02 4HHB 1CRN
+
+  # Record the provenance of each download in structures/{pdb_code}.pdb.json
+  pdbtk get --with-metadata --outdir structures/ 1A02 4HHB
+
+  # Download all experimental and predicted models of a protein
+  pdbtk get --uniprot --outdir models/ P69905
+
+  # Download a list of entries politely, four at a time and at most five requests per second
+  pdbtk get --outdir structures/ --max-concurrent 4 --rate-limit 5 --user-agent "mylab-pipeline (me@example.org)" $(cat ids.txt)`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: runGet,
 }
 
 func init() {
 	getCmd.Flags().StringVarP(&getOutput, "output", "o", "", "Output file (default: {pdb_code}.pdb, use '-' for stdout)")
 	getCmd.Flags().StringVarP(&getFormat, "format", "f", "pdb", "File format: pdb, pdb.gz (default: pdb)")
+	getCmd.Flags().StringVar(&getOutdir, "outdir", "", "Output directory for downloaded files (default: current directory)")
+	getCmd.Flags().BoolVar(&getUniprot, "uniprot", false, "Treat the arguments as UniProt accessions and download all their models from 3D-Beacons")
+	getCmd.Flags().Float64Var(&getRateLimit, "rate-limit", 0, "Maximum number of requests per second (default: no limit)")
+	getCmd.Flags().IntVar(&getMaxConcurrent, "max-concurrent", 1, "Maximum number of downloads running at once")
+	getCmd.Flags().IntVar(&getRetries, "retries", 3, "Number of times a download that is cut off or fails verification is retried")
+	getCmd.Flags().BoolVar(&getWithMetadata, "with-metadata", false, "Write a JSON metadata sidecar ({file}.json) next to each downloaded file")
+	getCmd.Flags().StringVar(&userAgent, "user-agent", "", "User-Agent sent with requests (default: $PDBTK_USER_AGENT or pdbtk/{version})")
 }
 
 func runGet(cmd *cobra.Command, args []string) error {
-	pdbCode := strings.ToUpper(args[0])
-
-	// Validate PDB code format (4 characters, alphanumeric)
-	if len(pdbCode) != 4 {
-		return fmt.Errorf("PDB code must be exactly 4 characters, got: %s", pdbCode)
+	if getRateLimit < 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --rate-limit: %g (must not be negative)", getRateLimit))
+	}
+	if getRetries < 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --retries: %d (must not be negative)", getRetries))
+	}
+	if getMaxConcurrent < 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --max-concurrent: %d (must be at least 1)", getMaxConcurrent))
+	}
+	if getWithMetadata && getOutput == "-" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--with-metadata cannot be used with --output -"))
+	}
+	if getRateLimit > 0 {
+		requestLimiter = newRateLimiter(getRateLimit)
+	}
+	if getUniprot {
+		return runGetUniprot(cmd.Context(), args)
 	}
 
 	// Validate format
@@ -61,61 +129,205 @@ func runGet(cmd *cobra.Command, args []string) error {
 		"pdb.gz": true,
 	}
 	if !validFormats[getFormat] {
-		return fmt.Errorf("unsupported format: %s (supported: pdb, pdb.gz)", getFormat)
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("unsupported format: %s (supported: pdb, pdb.gz)", getFormat))
+	}
+
+	pdbCodes := make([]string, len(args))
+	for i, arg := range args {
+		pdbCodes[i] = strings.ToUpper(arg)
+		// Validate PDB code format (4 characters, alphanumeric)
+		if len(pdbCodes[i]) != 4 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("PDB code must be exactly 4 characters, got: %s", pdbCodes[i]))
+		}
+	}
+
+	if len(pdbCodes) > 1 && getOutput != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--output cannot be used with multiple PDB codes, use --outdir instead"))
+	}
+	if getOutdir != "" {
+		if getOutput != "" {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("--output cannot be combined with --outdir"))
+		}
+		if err := makeOutputDir(getOutdir); err != nil {
+			return err
+		}
+	}
+
+	var mu sync.Mutex
+	if len(pdbCodes) == 1 {
+		outputFile := getOutputFile(pdbCodes[0])
+		err := getEntry(cmd.Context(), pdbCodes[0], outputFile, &mu)
+		recordResult(pdbCodes[0], outputFile, err)
+		return err
+	}
+
+	// The run shows the number of entries downloaded, which the byte counts of each download would overwrite
+	fileDownloadProgress = false
+	progress := newProgress("Downloading", int64(len(pdbCodes)), false)
+	failures := batchFailures{noun: "downloads"}
+	forEachDownload(cmd.Context(), len(pdbCodes), func(i int) {
+		outputFile := getOutputFile(pdbCodes[i])
+		err := getEntry(cmd.Context(), pdbCodes[i], outputFile, &mu)
+		mu.Lock()
+		defer mu.Unlock()
+		recordResult(pdbCodes[i], outputFile, err)
+		failures.add(pdbCodes[i], err)
+		progress.Add(1)
+	})
+	progress.Finish()
+	if err := cmd.Context().Err(); err != nil {
+		return contextError(err)
+	}
+	return failures.err(len(pdbCodes))
+}
+
+// fileDownloadProgress shows the bytes received by each download, unless the run shows its own progress
+var fileDownloadProgress = true
+
+// forEachDownload calls download for every index below n, with up to --max-concurrent calls running at
+// once. No more downloads are started once ctx is cancelled.
+func forEachDownload(ctx context.Context, n int, download func(i int)) {
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(getMaxConcurrent, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				download(i)
+			}
+		}()
 	}
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(indexes)
+	wg.Wait()
+}
 
-	// Construct download URL
-	url := fmt.Sprintf("https://files.rcsb.org/download/%s.%s", pdbCode, getFormat)
+// getOutputFile determines the output filename for a PDB code, returning "" for stdout
+func getOutputFile(pdbCode string) string {
+	if getOutput == "-" {
+		return ""
+	}
+	if getOutput != "" {
+		return getOutput
+	}
+	return joinOutputPath(getOutdir, fmt.Sprintf("%s.%s", pdbCode, getFormat))
+}
 
-	// Determine output filename
-	var outputFile string
-	if getOutput == "" {
-		outputFile = fmt.Sprintf("%s.%s", pdbCode, getFormat)
-	} else if getOutput == "-" {
-		outputFile = ""
-	} else {
-		outputFile = getOutput
+// getEntry downloads an entry in the --format to outputFile, with its metadata sidecar if requested.
+// mu guards the warnings of concurrent downloads.
+func getEntry(ctx context.Context, pdbCode string, outputFile string, mu *sync.Mutex) error {
+	retrieved := time.Now()
+	if err := downloadEntry(ctx, pdbCode, getFormat, outputFile); err != nil || !getWithMetadata {
+		return err
+	}
+	metadata := newDownloadMetadata(pdbCode, entryURL(pdbCode, getFormat), outputFile, getFormat, retrieved)
+	if err := metadata.lookupEntry(ctx); err != nil {
+		mu.Lock()
+		err = warn("no metadata found for %s: %v", pdbCode, err)
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
 	}
+	return writeDownloadMetadata(ctx, outputFile, metadata)
+}
+
+// downloadEntry downloads a single entry from RCSB in the given format (pdb or pdb.gz) to outputFile,
+// or to stdout if outputFile is empty
+func downloadEntry(ctx context.Context, pdbCode string, format string, outputFile string) error {
+	return downloadFile(ctx, pdbCode, entryURL(pdbCode, format), outputFile)
+}
 
-	// Download the file
-	fmt.Fprintf(os.Stderr, "Downloading %s from %s...\n", pdbCode, url)
+// entryURL returns the URL an entry is downloaded from in the given format
+func entryURL(pdbCode string, format string) string {
+	return fmt.Sprintf("%s/download/%s.%s", rcsbFilesURL(), pdbCode, format)
+}
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// downloadFile downloads url to outputFile, or to stdout if outputFile is empty, labelling the
+// progress messages with label. Downloads that are cut off or fail verification are retried.
+func downloadFile(ctx context.Context, label string, url string, outputFile string) error {
+	defer beginPhase(phaseDownload)()
+	var err error
+	for attempt := 0; attempt <= getRetries; attempt++ {
+		if attempt > 0 {
+			fmt.Fprintf(os.Stderr, "Retrying %s (%d of %d): %v\n", label, attempt, getRetries, err)
+			select {
+			case <-time.After(time.Duration(attempt) * retryDelay):
+			case <-ctx.Done():
+				return contextError(ctx.Err())
+			}
+		}
+		err = downloadOnce(ctx, label, url, outputFile)
+		if err != nil && ctx.Err() != nil {
+			// A download cut off by cancellation is not retried
+			return contextError(ctx.Err())
+		}
+		if !errors.Is(err, errCorruptDownload) {
+			return err
+		}
 	}
+	return withCode(ErrCodeNetwork, fmt.Errorf("failed to download %s: %v", label, err))
+}
+
+// downloadOnce downloads url to outputFile, or to stdout if outputFile is empty, verifying the download
+// before it is written
+func downloadOnce(ctx context.Context, label string, url string, outputFile string) error {
+	fmt.Fprintf(os.Stderr, "Downloading %s from %s...\n", label, url)
 
-	resp, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return withCode(ErrCodeNetwork, fmt.Errorf("invalid request: %v", err))
+	}
+	resp, err := sendRequest(req)
 	if err != nil {
-		return fmt.Errorf("failed to download file: %v", err)
+		if ctx.Err() != nil {
+			return contextError(ctx.Err())
+		}
+		return withCode(ErrCodeNetwork, fmt.Errorf("failed to download file: %v", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download file: HTTP %d %s", resp.StatusCode, resp.Status)
+		return withCode(ErrCodeNetwork, fmt.Errorf("failed to download file: HTTP %d %s", resp.StatusCode, resp.Status))
+	}
+
+	// Progress bars of concurrent downloads would overwrite each other
+	var body io.Reader = resp.Body
+	if fileDownloadProgress && getMaxConcurrent <= 1 {
+		progress := newProgress(label, resp.ContentLength, true)
+		defer progress.Finish()
+		body = progress.Reader(resp.Body)
 	}
+	gzipped := strings.HasSuffix(strings.ToLower(req.URL.Path), ".gz")
 
 	// Write to output
 	if outputFile == "" {
-		// Write to stdout
-		_, err = io.Copy(os.Stdout, resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to write to stdout: %v", err)
+		// Written to stdout only once verified, as it cannot be taken back
+		var buf bytes.Buffer
+		if err := copyVerified(&buf, body, resp, gzipped); err != nil {
+			return err
 		}
-	} else {
-		// Write to file
-		file, err := os.Create(outputFile)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %v", err)
-		}
-		defer file.Close()
-
-		_, err = io.Copy(file, resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to write to file: %v", err)
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return withCode(ErrCodeIO, fmt.Errorf("failed to write to stdout: %v", err))
 		}
-
-		fmt.Fprintf(os.Stderr, "Downloaded %s to %s\n", pdbCode, outputFile)
+		return nil
 	}
 
+	// An incomplete download is not written, as the temporary file is removed on error
+	err = writeFileAtomic(ctx, outputFile, func(w io.Writer) error {
+		return copyVerified(w, body, resp, gzipped)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Downloaded %s to %s\n", label, outputFile)
 	return nil
 }
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// beaconsURLEnv overrides the base URL of the 3D-Beacons hub API
+const beaconsURLEnv = "PDBTK_3DBEACONS_URL"
+
+// uniprotAccessionPattern matches UniProt accessions, e.g. P69905 or A0A023GPI8
+var uniprotAccessionPattern = regexp.MustCompile(`^([OPQ][0-9][A-Z0-9]{3}[0-9]|[A-NR-Z][0-9]([A-Z][A-Z0-9]{2}[0-9]){1,2})$`)
+
+// beaconsModelFormats maps 3D-Beacons model formats to file extensions
+var beaconsModelFormats = map[string]string{"PDB": "pdb", "MMCIF": "cif", "BCIF": "bcif"}
+
+// beaconsURL returns the base URL of the 3D-Beacons hub API
+func beaconsURL() string {
+	if url := os.Getenv(beaconsURLEnv); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://www.ebi.ac.uk/pdbe/pdbe-kb/3dbeacons/api"
+}
+
+// beaconsSummary is the part of the 3D-Beacons UniProt summary response used by get --uniprot. The
+// summary of each model is kept as is for the provenance metadata.
+type beaconsSummary struct {
+	Structures []struct {
+		Summary json.RawMessage `json:"summary"`
+	} `json:"structures"`
+}
+
+// beaconsModel holds the fields of a 3D-Beacons model summary needed to download it and describe it
+// in its metadata sidecar
+type beaconsModel struct {
+	ModelIdentifier    string   `json:"model_identifier"`
+	Provider           string   `json:"provider"`
+	ModelURL           string   `json:"model_url"`
+	ModelFormat        string   `json:"model_format"`
+	ExperimentalMethod string   `json:"experimental_method"`
+	Resolution         *float64 `json:"resolution"`
+}
+
+// beaconsManifestEntry is an entry of the {accession}_models.json provenance file
+type beaconsManifestEntry struct {
+	File    string          `json:"file"`
+	Summary json.RawMessage `json:"summary"`
+}
+
+// unsafeFilenameChars matches the characters replaced when building file names from provider names and
+// model identifiers
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// runGetUniprot downloads the models of UniProt accessions from 3D-Beacons
+func runGetUniprot(ctx context.Context, args []string) error {
+	if getOutput != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--output cannot be used with --uniprot, use --outdir instead"))
+	}
+	accessions := make([]string, len(args))
+	for i, arg := range args {
+		accessions[i] = strings.ToUpper(arg)
+		if !uniprotAccessionPattern.MatchString(accessions[i]) {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid UniProt accession: %s", arg))
+		}
+	}
+	if getOutdir != "" {
+		if err := makeOutputDir(getOutdir); err != nil {
+			return err
+		}
+	}
+
+	failures := batchFailures{noun: "downloads"}
+	total := 0
+	for _, accession := range accessions {
+		var summary beaconsSummary
+		err := fetchJSON(ctx, beaconsURL()+"/uniprot/summary/"+accession+".json", &summary)
+		if errors.Is(err, errNotFound) || (err == nil && len(summary.Structures) == 0) {
+			err = withCode(ErrCodeNoMatch, fmt.Errorf("no models found for %s", accession))
+		}
+		if err != nil {
+			if len(accessions) == 1 {
+				return err
+			}
+			failures.add(accession, err)
+			total++
+			continue
+		}
+
+		// Entries are collected by model so the metadata keeps the order of the summary
+		entries := make([]*beaconsManifestEntry, len(summary.Structures))
+		total += len(summary.Structures)
+		var mu sync.Mutex
+		forEachDownload(ctx, len(summary.Structures), func(i int) {
+			structure := summary.Structures[i]
+			var model beaconsModel
+			if err := json.Unmarshal(structure.Summary, &model); err != nil || model.ModelURL == "" {
+				mu.Lock()
+				defer mu.Unlock()
+				failures.add(accession, withCode(ErrCodeNetwork, fmt.Errorf("invalid model summary from 3D-Beacons")))
+				return
+			}
+			outputFile := joinOutputPath(getOutdir, beaconsModelFilename(accession, model))
+			retrieved := time.Now()
+			err := downloadFile(ctx, model.ModelIdentifier, model.ModelURL, outputFile)
+			if err == nil && getWithMetadata {
+				metadata := newDownloadMetadata(model.ModelIdentifier, model.ModelURL, outputFile, strings.TrimPrefix(filepath.Ext(outputFile), "."), retrieved)
+				metadata.Provider, metadata.Method, metadata.Resolution = model.Provider, model.ExperimentalMethod, model.Resolution
+				err = writeDownloadMetadata(ctx, outputFile, metadata)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			recordResult(accession, outputFile, err)
+			if err != nil {
+				failures.add(model.ModelIdentifier, err)
+				return
+			}
+			entries[i] = &beaconsManifestEntry{File: filepath.Base(outputFile), Summary: structure.Summary}
+		})
+		manifest := make([]beaconsManifestEntry, 0, len(entries))
+		for _, entry := range entries {
+			if entry != nil {
+				manifest = append(manifest, *entry)
+			}
+		}
+
+		manifestFile := joinOutputPath(getOutdir, accession+"_models.json")
+		err = writeFileAtomic(ctx, manifestFile, func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(manifest)
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote metadata for %d models of %s to %s\n", len(manifest), accession, manifestFile)
+	}
+	return failures.err(total)
+}
+
+// beaconsModelFilename returns the file name a model is saved as, e.g. P69905_AlphaFold_DB_AF-P69905-F1.cif
+func beaconsModelFilename(accession string, model beaconsModel) string {
+	ext, ok := beaconsModelFormats[strings.ToUpper(model.ModelFormat)]
+	if !ok {
+		ext = strings.TrimPrefix(path.Ext(model.ModelURL), ".")
+	}
+	provider := unsafeFilenameChars.ReplaceAllString(model.Provider, "_")
+	identifier := unsafeFilenameChars.ReplaceAllString(model.ModelIdentifier, "_")
+	return fmt.Sprintf("%s_%s_%s.%s", accession, provider, identifier, ext)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+)
+
+// downloadMetadata is the JSON sidecar written by get --with-metadata for each downloaded file
+type downloadMetadata struct {
+	Entry      string   `json:"entry"`
+	File       string   `json:"file"`
+	SourceURL  string   `json:"source_url"`
+	Retrieved  string   `json:"retrieved"`
+	Tool       string   `json:"retrieved_with"`
+	Format     string   `json:"format"`
+	Provider   string   `json:"provider,omitempty"`
+	Title      string   `json:"title,omitempty"`
+	Method     string   `json:"experimental_method,omitempty"`
+	Resolution *float64 `json:"resolution,omitempty"`
+}
+
+// newDownloadMetadata returns the metadata of a download of entry from sourceURL to outputFile
+func newDownloadMetadata(entry, sourceURL, outputFile, format string, retrieved time.Time) downloadMetadata {
+	return downloadMetadata{
+		Entry:     entry,
+		File:      filepath.Base(outputFile),
+		SourceURL: sourceURL,
+		Retrieved: retrieved.UTC().Format(time.RFC3339),
+		Tool:      "pdbtk/" + Version,
+		Format:    format,
+	}
+}
+
+// lookupEntry adds the title, experimental method and resolution of a PDB entry from the RCSB Data API
+func (m *downloadMetadata) lookupEntry(ctx context.Context) error {
+	var entry struct {
+		Struct struct {
+			Title string `json:"title"`
+		} `json:"struct"`
+		Exptl []struct {
+			Method string `json:"method"`
+		} `json:"exptl"`
+		Info struct {
+			Resolution []float64 `json:"resolution_combined"`
+		} `json:"rcsb_entry_info"`
+	}
+	if err := fetchJSON(ctx, rcsbDataURL()+"/rest/v1/core/entry/"+m.Entry, &entry); err != nil {
+		return err
+	}
+	m.Title = entry.Struct.Title
+	if len(entry.Exptl) > 0 {
+		m.Method = entry.Exptl[0].Method
+	}
+	if len(entry.Info.Resolution) > 0 {
+		m.Resolution = &entry.Info.Resolution[0]
+	}
+	return nil
+}
+
+// writeDownloadMetadata writes the metadata sidecar of a downloaded file, {file}.json
+func writeDownloadMetadata(ctx context.Context, outputFile string, metadata downloadMetadata) error {
+	return writeFileAtomic(ctx, outputFile+".json", func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(metadata); err != nil {
+			return withCode(ErrCodeIO, fmt.Errorf("failed to write metadata: %v", err))
+		}
+		return nil
+	})
+}
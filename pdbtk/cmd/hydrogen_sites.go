@@ -0,0 +1,178 @@
+package cmd
+
+// hydrogenKind is the geometry used to place the hydrogens of a site
+type hydrogenKind int
+
+const (
+	hydrogenTetrahedral hydrogenKind = iota // one hydrogen on an sp3 atom with three heavy neighbours
+	hydrogenMethylene                       // two hydrogens on an sp3 atom with two heavy neighbours
+	hydrogenPlanar                          // one hydrogen on an sp2 atom with two heavy neighbours
+	hydrogenTorsion                         // hydrogens placed by angle and torsions from two reference atoms
+)
+
+// hydrogenSite describes the hydrogens bonded to one heavy atom of a residue
+type hydrogenSite struct {
+	kind     hydrogenKind
+	parent   string
+	refs     []string // bonded heavy atoms, or for hydrogenTorsion the atoms a and b of the torsion a-b-parent-H
+	names    []string
+	angle    float64 // angle b-parent-H, for hydrogenTorsion
+	torsions []float64
+}
+
+func tetrahedral(parent, n1, n2, n3, name string) hydrogenSite {
+	return hydrogenSite{kind: hydrogenTetrahedral, parent: parent, refs: []string{n1, n2, n3}, names: []string{name}}
+}
+
+func methylene(parent, n1, n2, name1, name2 string) hydrogenSite {
+	return hydrogenSite{kind: hydrogenMethylene, parent: parent, refs: []string{n1, n2}, names: []string{name1, name2}}
+}
+
+func planar(parent, n1, n2, name string) hydrogenSite {
+	return hydrogenSite{kind: hydrogenPlanar, parent: parent, refs: []string{n1, n2}, names: []string{name}}
+}
+
+// methyl places three staggered hydrogens on parent, e.g. also used for NH3+ groups
+func methyl(parent, a, b string, names ...string) hydrogenSite {
+	return hydrogenSite{kind: hydrogenTorsion, parent: parent, refs: []string{a, b}, names: names, angle: 109.5, torsions: []float64{60, 180, 300}}
+}
+
+// hydroxyl places a hydroxyl or thiol hydrogen anti to atom a
+func hydroxyl(parent, a, b, name string) hydrogenSite {
+	return hydrogenSite{kind: hydrogenTorsion, parent: parent, refs: []string{a, b}, names: []string{name}, angle: 109.5, torsions: []float64{180}}
+}
+
+// amide places the two hydrogens of a planar NH2 group, cis and trans to atom a
+func amide(parent, a, b, cisName, transName string) hydrogenSite {
+	return hydrogenSite{kind: hydrogenTorsion, parent: parent, refs: []string{a, b}, names: []string{cisName, transName}, angle: 120, torsions: []float64{0, 180}}
+}
+
+// hydrogenSites lists the hydrogens of standard residues, other than the backbone amide hydrogen and
+// terminal groups. Histidine is protonated on NE2 (the HIE tautomer); ASP, GLU, LYS and ARG are in their
+// charged states.
+var hydrogenSites = map[string][]hydrogenSite{
+	"ALA": {tetrahedral("CA", "N", "C", "CB", "HA"), methyl("CB", "N", "CA", "HB1", "HB2", "HB3")},
+	"ARG": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		methylene("CG", "CB", "CD", "HG2", "HG3"), methylene("CD", "CG", "NE", "HD2", "HD3"),
+		planar("NE", "CD", "CZ", "HE"), amide("NH1", "NE", "CZ", "HH11", "HH12"), amide("NH2", "NE", "CZ", "HH21", "HH22"),
+	},
+	"ASN": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		amide("ND2", "OD1", "CG", "HD21", "HD22"),
+	},
+	"ASP": {tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3")},
+	"CYS": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "SG", "HB2", "HB3"),
+		hydroxyl("SG", "CA", "CB", "HG"),
+	},
+	"GLN": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		methylene("CG", "CB", "CD", "HG2", "HG3"), amide("NE2", "OE1", "CD", "HE21", "HE22"),
+	},
+	"GLU": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		methylene("CG", "CB", "CD", "HG2", "HG3"),
+	},
+	"GLY": {methylene("CA", "N", "C", "HA2", "HA3")},
+	"HIS": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		planar("CD2", "CG", "NE2", "HD2"), planar("CE1", "ND1", "NE2", "HE1"), planar("NE2", "CD2", "CE1", "HE2"),
+	},
+	"ILE": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), tetrahedral("CB", "CA", "CG1", "CG2", "HB"),
+		methylene("CG1", "CB", "CD1", "HG12", "HG13"), methyl("CG2", "CA", "CB", "HG21", "HG22", "HG23"),
+		methyl("CD1", "CB", "CG1", "HD11", "HD12", "HD13"),
+	},
+	"LEU": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		tetrahedral("CG", "CB", "CD1", "CD2", "HG"), methyl("CD1", "CB", "CG", "HD11", "HD12", "HD13"),
+		methyl("CD2", "CB", "CG", "HD21", "HD22", "HD23"),
+	},
+	"LYS": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		methylene("CG", "CB", "CD", "HG2", "HG3"), methylene("CD", "CG", "CE", "HD2", "HD3"),
+		methylene("CE", "CD", "NZ", "HE2", "HE3"), methyl("NZ", "CD", "CE", "HZ1", "HZ2", "HZ3"),
+	},
+	"MET": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		methylene("CG", "CB", "SD", "HG2", "HG3"), methyl("CE", "CG", "SD", "HE1", "HE2", "HE3"),
+	},
+	"PHE": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		planar("CD1", "CG", "CE1", "HD1"), planar("CD2", "CG", "CE2", "HD2"), planar("CE1", "CD1", "CZ", "HE1"),
+		planar("CE2", "CD2", "CZ", "HE2"), planar("CZ", "CE1", "CE2", "HZ"),
+	},
+	"PRO": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		methylene("CG", "CB", "CD", "HG2", "HG3"), methylene("CD", "CG", "N", "HD2", "HD3"),
+	},
+	"SER": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "OG", "HB2", "HB3"),
+		hydroxyl("OG", "CA", "CB", "HG"),
+	},
+	"THR": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), tetrahedral("CB", "CA", "OG1", "CG2", "HB"),
+		hydroxyl("OG1", "CA", "CB", "HG1"), methyl("CG2", "CA", "CB", "HG21", "HG22", "HG23"),
+	},
+	"TRP": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		planar("CD1", "CG", "NE1", "HD1"), planar("NE1", "CD1", "CE2", "HE1"), planar("CE3", "CD2", "CZ3", "HE3"),
+		planar("CZ2", "CE2", "CH2", "HZ2"), planar("CZ3", "CE3", "CH2", "HZ3"), planar("CH2", "CZ2", "CZ3", "HH2"),
+	},
+	"TYR": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), methylene("CB", "CA", "CG", "HB2", "HB3"),
+		planar("CD1", "CG", "CE1", "HD1"), planar("CD2", "CG", "CE2", "HD2"), planar("CE1", "CD1", "CZ", "HE1"),
+		planar("CE2", "CD2", "CZ", "HE2"), hydroxyl("OH", "CE1", "CZ", "HH"),
+	},
+	"VAL": {
+		tetrahedral("CA", "N", "C", "CB", "HA"), tetrahedral("CB", "CA", "CG1", "CG2", "HB"),
+		methyl("CG1", "CA", "CB", "HG11", "HG12", "HG13"), methyl("CG2", "CA", "CB", "HG21", "HG22", "HG23"),
+	},
+}
+
+// riboseSites are the hydrogens of the sugar of RNA nucleotides
+var riboseSites = []hydrogenSite{
+	methylene("C5'", "O5'", "C4'", "H5'", "H5''"), tetrahedral("C4'", "C5'", "O4'", "C3'", "H4'"),
+	tetrahedral("C3'", "C4'", "O3'", "C2'", "H3'"), tetrahedral("C2'", "C3'", "O2'", "C1'", "H2'"),
+	hydroxyl("O2'", "C3'", "C2'", "HO2'"),
+}
+
+// deoxyriboseSites are the hydrogens of the sugar of DNA nucleotides
+var deoxyriboseSites = []hydrogenSite{
+	methylene("C5'", "O5'", "C4'", "H5'", "H5''"), tetrahedral("C4'", "C5'", "O4'", "C3'", "H4'"),
+	tetrahedral("C3'", "C4'", "O3'", "C2'", "H3'"), methylene("C2'", "C3'", "C1'", "H2'", "H2''"),
+}
+
+// baseSites are the hydrogens of the nucleotide bases, keyed by the one-letter base name
+var baseSites = map[string][]hydrogenSite{
+	"A": {
+		tetrahedral("C1'", "O4'", "C2'", "N9", "H1'"), planar("C8", "N7", "N9", "H8"),
+		planar("C2", "N1", "N3", "H2"), amide("N6", "N1", "C6", "H61", "H62"),
+	},
+	"G": {
+		tetrahedral("C1'", "O4'", "C2'", "N9", "H1'"), planar("C8", "N7", "N9", "H8"),
+		planar("N1", "C2", "C6", "H1"), amide("N2", "N1", "C2", "H21", "H22"),
+	},
+	"C": {
+		tetrahedral("C1'", "O4'", "C2'", "N1", "H1'"), planar("C5", "C4", "C6", "H5"),
+		planar("C6", "C5", "N1", "H6"), amide("N4", "N3", "C4", "H41", "H42"),
+	},
+	"U": {
+		tetrahedral("C1'", "O4'", "C2'", "N1", "H1'"), planar("C5", "C4", "C6", "H5"),
+		planar("C6", "C5", "N1", "H6"), planar("N3", "C2", "C4", "H3"),
+	},
+	"T": {
+		tetrahedral("C1'", "O4'", "C2'", "N1", "H1'"), planar("C6", "C5", "N1", "H6"),
+		planar("N3", "C2", "C4", "H3"), methyl("C7", "C4", "C5", "H71", "H72", "H73"),
+	},
+}
+
+func init() {
+	for _, base := range []string{"A", "G", "C", "U"} {
+		hydrogenSites[base] = append(append([]hydrogenSite{}, riboseSites...), baseSites[base]...)
+	}
+	for _, base := range []string{"A", "G", "C", "T"} {
+		hydrogenSites["D"+base] = append(append([]hydrogenSite{}, deoxyriboseSites...), baseSites[base]...)
+	}
+}
@@ -0,0 +1,360 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	importSSFile   string
+	importSSOutput string
+)
+
+var importSSCmd = &cobra.Command{
+	Use:   "import-ss --dssp FILE [flags] [input_file]",
+	Short: "Write HELIX/SHEET records from a DSSP file into a structure",
+	Long: `Read the secondary structure assigned by DSSP (or another program) and write it into the structure as
+HELIX and SHEET records, replacing those of the input, so that externally computed annotations are
+used by the other commands (e.g. extract --ss) and carried into their output.
+
+--dssp reads either classic DSSP output (.dssp) or an mmCIF file with _struct_conf and
+_struct_sheet_range categories (DSSP 4 mmCIF output, or any annotated mmCIF file), detected from the
+content. In DSSP output, runs of H (alpha), G (3-10) and I (pi) residues become helices of PDB
+classes 1, 5 and 3, and runs of E residues become strands, grouped into sheets by the DSSP sheet
+label. Isolated bridges (B), turns, bends and polyproline helices are not written. The sense of each
+strand but the first of a sheet is taken from its bridge partners in the previous strands (1 parallel,
+-1 antiparallel). Residues are matched to the structure by chain, residue number and insertion code;
+elements whose residues are not in the structure are reported and left out.
+
+The structure is written in PDB format, as pdbtk writes no mmCIF.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Add the DSSP secondary structure to a model
+  pdbtk import-ss --dssp model.dssp model.pdb --output model_ss.pdb
+
+  # Use the secondary structure of a DSSP mmCIF file
+  pdbtk import-ss --dssp model_dssp.cif model.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runImportSS,
+}
+
+func init() {
+	importSSCmd.Flags().StringVar(&importSSFile, "dssp", "", "DSSP output or annotated mmCIF file with the secondary structure (required)")
+	importSSCmd.Flags().StringVarP(&importSSOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// importedElement is a helix or strand read from a DSSP or mmCIF file
+type importedElement struct {
+	Type       byte // 'H' for helices, 'E' for strands
+	Class      int  // PDB helix class
+	Sheet      string
+	Sense      int // strand sense relative to a previous strand of the sheet
+	ChainID    byte
+	Start, End residuePosition
+}
+
+func runImportSS(cmd *cobra.Command, args []string) error {
+	if importSSFile == "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--dssp must be specified"))
+	}
+	if err := CheckFileExistsContext(cmd.Context(), importSSFile); err != nil {
+		return withCode(ErrCodeInputNotFound, err)
+	}
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	content, err := readInputContent(cmd.Context(), importSSFile)
+	if err != nil {
+		return err
+	}
+	var elements []importedElement
+	if isCIFFilename(importSSFile) || looksLikeCIF(content) {
+		blocks, err := ParseCIF(strings.NewReader(string(content)))
+		if err != nil {
+			return withCode(ErrCodeParse, fmt.Errorf("failed to parse mmCIF: %v", err))
+		}
+		elements, err = cifSecondaryStructure(blocks[0])
+		if err != nil {
+			return withCode(ErrCodeParse, err)
+		}
+	} else if elements, err = parseDSSP(strings.NewReader(string(content))); err != nil {
+		return withCode(ErrCodeParse, fmt.Errorf("%s: %v", importSSFile, err))
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	records, err := secondaryStructureRecords(file, elements)
+	if err != nil {
+		return err
+	}
+	var header []string
+	for _, line := range file.Header {
+		if !strings.HasPrefix(line, "HELIX ") && !strings.HasPrefix(line, "SHEET ") {
+			header = append(header, line)
+		}
+	}
+	header = insertHeaderRecords(header, records, "SSBOND", "LINK", "CISPEP", "SITE", "CRYST1", "ORIGX", "SCALE", "MTRIX")
+
+	helices, strands := 0, 0
+	for _, line := range records {
+		if strings.HasPrefix(line, "HELIX ") {
+			helices++
+		} else {
+			strands++
+		}
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Imported %d helices and %d strands\n", helices, strands)
+	return writeOutput(cmd.Context(), importSSOutput, func(w io.Writer) error {
+		return writePDBRecords(&PDBFile{Header: header, Atoms: file.Atoms, Conect: file.Conect}, w, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// dsspHelixClasses are the PDB helix classes of the DSSP helix codes
+var dsspHelixClasses = map[byte]int{'H': 1, 'G': 5, 'I': 3}
+
+// parseDSSP reads the helices and strands of classic DSSP output: runs of residues with the same helix
+// code, or strand residues with the same sheet label, not interrupted by a chain break
+func parseDSSP(reader io.Reader) ([]importedElement, error) {
+	type dsspResidue struct {
+		number   int
+		code     byte
+		sheet    byte
+		chainID  byte
+		position residuePosition
+		bridges  [][2]int // partner number and 1 (parallel) or -1 (antiparallel)
+	}
+	var residues []dsspResidue
+	scanner := bufio.NewScanner(reader)
+	started := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !started {
+			started = strings.HasPrefix(line, "  #  RESIDUE")
+			continue
+		}
+		if len(line) < 34 || line[13] == '!' {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSpace(line[0:5]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DSSP residue number: %q", line[0:5])
+		}
+		r := dsspResidue{number: number, code: line[16], sheet: line[33], chainID: line[11],
+			position: recordPosition(line[5:10], line[10])}
+		for i, label := range []byte{line[23], line[24]} {
+			partner, _ := strconv.Atoi(strings.TrimSpace(line[25+4*i : 29+4*i]))
+			if label == ' ' || partner == 0 {
+				continue
+			}
+			sense := -1
+			if label >= 'a' && label <= 'z' {
+				sense = 1
+			}
+			r.bridges = append(r.bridges, [2]int{partner, sense})
+		}
+		residues = append(residues, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !started {
+		return nil, fmt.Errorf("not a DSSP file (no residue table found)")
+	}
+
+	var elements []importedElement
+	strandOf := make(map[int]int) // DSSP residue number to index of its strand in elements
+	for i, r := range residues {
+		class, helix := dsspHelixClasses[r.code]
+		if !helix && r.code != 'E' {
+			continue
+		}
+		previous := i > 0 && residues[i-1].number == r.number-1 && residues[i-1].chainID == r.chainID &&
+			residues[i-1].code == r.code && residues[i-1].sheet == r.sheet
+		if previous && len(elements) > 0 {
+			elements[len(elements)-1].End = r.position
+		} else {
+			element := importedElement{Type: 'H', Class: class, ChainID: r.chainID, Start: r.position, End: r.position}
+			if !helix {
+				element.Type, element.Sheet = 'E', string(r.sheet)
+			}
+			elements = append(elements, element)
+		}
+		if !helix {
+			strandOf[r.number] = len(elements) - 1
+		}
+	}
+
+	// The sense of a strand is that of its bridges to an earlier strand of the sheet
+	for _, r := range residues {
+		index, ok := strandOf[r.number]
+		if !ok || elements[index].Sense != 0 {
+			continue
+		}
+		for _, bridge := range r.bridges {
+			if partner, ok := strandOf[bridge[0]]; ok && partner < index {
+				elements[index].Sense = bridge[1]
+				break
+			}
+		}
+	}
+	return elements, nil
+}
+
+// cifHelixClasses are the PDB helix classes of the _struct_conf.conf_type_id values of helices
+var cifHelixClasses = map[string]int{"HELX_P": 1, "HELX_RH_AL_P": 1, "HELX_RH_3T_P": 5, "HELX_RH_PI_P": 3}
+
+// cifSecondaryStructure reads the helices of _struct_conf and the strands of _struct_sheet_range, with
+// the sense of the strands from _struct_sheet_order
+func cifSecondaryStructure(block *CIFBlock) ([]importedElement, error) {
+	// position reads the chain and residue of the beginning (beg) or end of a range, by author numbering
+	// when it is given
+	position := func(row map[string]string, end string) (byte, residuePosition, error) {
+		chainID := cifRowValue(row, end+"_auth_asym_id")
+		if chainID == "" {
+			chainID = cifRowValue(row, end+"_label_asym_id")
+		}
+		resSeq := cifRowValue(row, end+"_auth_seq_id")
+		if resSeq == "" {
+			resSeq = cifRowValue(row, end+"_label_seq_id")
+		}
+		n, err := strconv.Atoi(resSeq)
+		if err != nil || len(chainID) != 1 {
+			return 0, residuePosition{}, fmt.Errorf("invalid residue range start or end: chain %q, residue %q", chainID, resSeq)
+		}
+		iCode := byte(' ')
+		if ins := cifRowValue(row, "pdbx_"+end+"_PDB_ins_code"); ins != "" {
+			iCode = ins[0]
+		}
+		return chainID[0], residuePosition{ResSeq: n, ICode: iCode}, nil
+	}
+	rangeOf := func(element *importedElement, row map[string]string) error {
+		chainID, start, err := position(row, "beg")
+		if err != nil {
+			return err
+		}
+		_, end, err := position(row, "end")
+		if err != nil {
+			return err
+		}
+		element.ChainID, element.Start, element.End = chainID, start, end
+		return nil
+	}
+
+	var elements []importedElement
+	for _, row := range block.Category("_struct_conf") {
+		class, ok := cifHelixClasses[strings.ToUpper(cifRowValue(row, "conf_type_id"))]
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(cifRowValue(row, "pdbx_PDB_helix_class")); err == nil {
+			class = n
+		}
+		element := importedElement{Type: 'H', Class: class}
+		if err := rangeOf(&element, row); err != nil {
+			return nil, fmt.Errorf("_struct_conf: %v", err)
+		}
+		elements = append(elements, element)
+	}
+
+	senses := make(map[[2]string]int)
+	for _, row := range block.Category("_struct_sheet_order") {
+		sense := -1
+		if strings.EqualFold(cifRowValue(row, "sense"), "parallel") {
+			sense = 1
+		}
+		senses[[2]string{cifRowValue(row, "sheet_id"), cifRowValue(row, "range_id_2")}] = sense
+	}
+	for _, row := range block.Category("_struct_sheet_range") {
+		sheet := cifRowValue(row, "sheet_id")
+		element := importedElement{Type: 'E', Sheet: sheet, Sense: senses[[2]string{sheet, cifRowValue(row, "id")}]}
+		if err := rangeOf(&element, row); err != nil {
+			return nil, fmt.Errorf("_struct_sheet_range: %v", err)
+		}
+		elements = append(elements, element)
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("no helices in _struct_conf or strands in _struct_sheet_range found")
+	}
+	return elements, nil
+}
+
+// secondaryStructureRecords formats elements as HELIX and SHEET records, with the residue names of the
+// structure. Elements with a residue that is not in the first model are left out with a warning.
+func secondaryStructureRecords(file *PDBFile, elements []importedElement) ([]string, error) {
+	type chainPosition struct {
+		chainID  byte
+		position residuePosition
+	}
+	models := file.Models()
+	names := make(map[chainPosition]string)
+	for _, atom := range file.Atoms {
+		if atom.Model == models[0] {
+			names[chainPosition{atom.ChainID, residuePosition{ResSeq: atom.ResSeq, ICode: atom.ICode}}] = atom.ResName
+		}
+	}
+
+	var helices []string
+	var sheets []string
+	strands := make(map[string][]importedElement)
+	for _, e := range elements {
+		startName, okStart := names[chainPosition{e.ChainID, e.Start}]
+		endName, okEnd := names[chainPosition{e.ChainID, e.End}]
+		if !okStart || !okEnd {
+			if err := warn("residues %c:%d%c-%d%c are not in the structure; element left out", e.ChainID,
+				e.Start.ResSeq, e.Start.ICode, e.End.ResSeq, e.End.ICode); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if e.Type == 'H' {
+			n := len(helices) + 1
+			length := 0
+			for _, atom := range file.Atoms {
+				p := residuePosition{ResSeq: atom.ResSeq, ICode: atom.ICode}
+				if atom.Model == models[0] && atom.ChainID == e.ChainID && atom.Name == "CA" && !p.before(e.Start) && !e.End.before(p) {
+					length++
+				}
+			}
+			helices = append(helices, fmt.Sprintf("HELIX  %3d %3d %3s %c %4d%c %3s %c %4d%c%2d%30s %5d",
+				n, n, startName, e.ChainID, e.Start.ResSeq, e.Start.ICode, endName, e.ChainID, e.End.ResSeq, e.End.ICode, e.Class, "", length))
+			continue
+		}
+		if _, seen := strands[e.Sheet]; !seen {
+			sheets = append(sheets, e.Sheet)
+		}
+		strands[e.Sheet] = append(strands[e.Sheet], e)
+	}
+
+	records := helices
+	for _, sheet := range sheets {
+		for i, e := range strands[sheet] {
+			sense := e.Sense
+			if i == 0 {
+				sense = 0
+			}
+			startName := names[chainPosition{e.ChainID, e.Start}]
+			endName := names[chainPosition{e.ChainID, e.End}]
+			records = append(records, fmt.Sprintf("SHEET  %3d %3s%2d %3s %c%4d%c %3s %c%4d%c%2d",
+				i+1, sheet, len(strands[sheet]), startName, e.ChainID, e.Start.ResSeq, e.Start.ICode, endName, e.ChainID, e.End.ResSeq, e.End.ICode, sense))
+		}
+	}
+	if len(records) == 0 {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("no helices or strands match the residues of the structure"))
+	}
+	return records, nil
+}
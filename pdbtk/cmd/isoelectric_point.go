@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	pIChains string
+	pIpH     float64
+	pISeqRes bool
+	pIFormat string
+	pIOutput string
+)
+
+// Side-chain and terminal pKa values used to estimate charges (EMBOSS iep)
+var (
+	positivePKa  = map[byte]float64{'K': 10.8, 'R': 12.5, 'H': 6.5}
+	negativePKa  = map[byte]float64{'D': 3.9, 'E': 4.1, 'C': 8.5, 'Y': 10.1}
+	nTerminalPKa = 8.6
+	cTerminalPKa = 3.6
+)
+
+var isoelectricPointCmd = &cobra.Command{
+	Use:   "isoelectric-point [flags] [input_file]",
+	Short: "Estimate the isoelectric point and net charge of each protein chain",
+	Long: `Estimate the isoelectric point (pI) and the net charge at --ph (7.0 by default) of each protein chain
+from its sequence, using the pKa values of EMBOSS iep for the termini and the Lys, Arg, His, Asp, Glu,
+Cys and Tyr side chains. Modified residues count as their parent residue.
+
+The sequence is taken from the residues in the coordinates of the first model, or from the SEQRES
+records with --seqres, which include residues missing from the model. Nucleic acid chains are left out.
+The output is a tab-separated table (chain, length, pi, ph, charge), or JSON with --format json.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Estimate the pI of each chain
+  pdbtk isoelectric-point 1a02.pdb
+
+  # Net charge of chain A at pH 5.5, from the SEQRES sequence
+  pdbtk isoelectric-point --chains A --ph 5.5 --seqres 1a02.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runIsoelectricPoint,
+}
+
+func init() {
+	isoelectricPointCmd.Flags().StringVarP(&pIChains, "chains", "c", "", "Comma-separated list of chain IDs (default: all protein chains)")
+	isoelectricPointCmd.Flags().Float64Var(&pIpH, "ph", 7.0, "pH at which to estimate the net charge")
+	isoelectricPointCmd.Flags().BoolVar(&pISeqRes, "seqres", false, "Use SEQRES records instead of ATOM records")
+	isoelectricPointCmd.Flags().StringVarP(&pIFormat, "format", "f", "tsv", "Output format: tsv or json")
+	isoelectricPointCmd.Flags().StringVarP(&pIOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// chainCharge is the estimated isoelectric point and net charge of a protein chain
+type chainCharge struct {
+	Chain  string  `json:"chain"`
+	Length int     `json:"length"`
+	PI     float64 `json:"pi"`
+	PH     float64 `json:"ph"`
+	Charge float64 `json:"charge"`
+}
+
+func runIsoelectricPoint(cmd *cobra.Command, args []string) error {
+	if pIpH < 0 || pIpH > 14 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --ph: %g (must be between 0 and 14)", pIpH))
+	}
+	if pIFormat != "tsv" && pIFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", pIFormat))
+	}
+	var selections chainSelections
+	if pIChains != "" {
+		var err error
+		if selections, err = parseChainSelections(pIChains); err != nil {
+			return err
+		}
+		if selections.HasRanges() {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("residue ranges are not supported by isoelectric-point"))
+		}
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	var chainIDs []byte
+	var sequences map[byte]string
+	if pISeqRes {
+		chainIDs, sequences = seqresProteinSequences(file.Header)
+		if len(chainIDs) == 0 {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("--seqres specified but no protein SEQRES records found"))
+		}
+	} else {
+		chainIDs, sequences = observedProteinSequences(file)
+	}
+
+	results := make([]chainCharge, 0, len(chainIDs))
+	for _, chainID := range chainIDs {
+		if len(selections) > 0 && !selections.Contains(chainID, 0, ' ') {
+			continue
+		}
+		sequence := sequences[chainID]
+		results = append(results, chainCharge{
+			Chain:  string(chainID),
+			Length: len(sequence),
+			PI:     roundTo(isoelectricPoint(sequence), 2),
+			PH:     pIpH,
+			Charge: roundTo(netCharge(sequence, pIpH), 2),
+		})
+	}
+	if len(results) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no protein chains found"))
+	}
+
+	return writeOutput(cmd.Context(), pIOutput, func(w io.Writer) error {
+		if pIFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(results)
+		}
+		fmt.Fprintln(w, "chain\tlength\tpi\tph\tcharge")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%d\t%.2f\t%.2f\t%.2f\n", r.Chain, r.Length, r.PI, r.PH, r.Charge)
+		}
+		return nil
+	})
+}
+
+// observedProteinSequences returns the protein chains of the first model and their sequences without
+// gaps, with modified residues written as their parent residue
+func observedProteinSequences(file *PDBFile) ([]byte, map[byte]string) {
+	models := file.Models()
+	if len(models) == 0 {
+		return nil, nil
+	}
+	positions := chainSequences(file, models[0], parseModres(file.Header), "parent")
+	var chainIDs []byte
+	sequences := make(map[byte]string)
+	for _, chainID := range file.ChainIDs() {
+		var names []string
+		var sequence strings.Builder
+		for _, position := range positions[chainID] {
+			if position.Residue != nil {
+				names = append(names, position.Residue.ResName)
+				sequence.WriteByte(position.Code)
+			}
+		}
+		if isProteinChain(names) {
+			chainIDs = append(chainIDs, chainID)
+			sequences[chainID] = sequence.String()
+		}
+	}
+	return chainIDs, sequences
+}
+
+// seqresProteinSequences returns the protein chains of the SEQRES records and their sequences, with
+// modified residues written as their parent residue
+func seqresProteinSequences(header []string) ([]byte, map[byte]string) {
+	modres := parseModres(header)
+	var order []byte
+	names := make(map[byte][]string)
+	for _, line := range header {
+		if !strings.HasPrefix(line, "SEQRES") || len(line) < 20 {
+			continue
+		}
+		chainID := line[11]
+		if _, seen := names[chainID]; !seen {
+			order = append(order, chainID)
+		}
+		names[chainID] = append(names[chainID], strings.Fields(line[19:])...)
+	}
+
+	var chainIDs []byte
+	sequences := make(map[byte]string)
+	for _, chainID := range order {
+		if !isProteinChain(names[chainID]) {
+			continue
+		}
+		var sequence strings.Builder
+		for _, name := range names[chainID] {
+			code, standard := oneLetterCode(name)
+			if !standard {
+				code = parentOneLetterCode(name, modres)
+			}
+			sequence.WriteByte(code)
+		}
+		chainIDs = append(chainIDs, chainID)
+		sequences[chainID] = sequence.String()
+	}
+	return chainIDs, sequences
+}
+
+// isProteinChain reports whether most residues of a chain are amino acids, as opposed to nucleotides
+func isProteinChain(resNames []string) bool {
+	aminoAcids := 0
+	for _, name := range resNames {
+		if len(name) == 3 {
+			aminoAcids++
+		}
+	}
+	return aminoAcids > 0 && aminoAcids*2 > len(resNames)
+}
+
+// netCharge estimates the net charge of a protein sequence at the given pH
+func netCharge(sequence string, pH float64) float64 {
+	positive := func(pKa float64) float64 { return 1 / (1 + math.Pow(10, pH-pKa)) }
+	negative := func(pKa float64) float64 { return -1 / (1 + math.Pow(10, pKa-pH)) }
+	charge := positive(nTerminalPKa) + negative(cTerminalPKa)
+	for i := 0; i < len(sequence); i++ {
+		if pKa, ok := positivePKa[sequence[i]]; ok {
+			charge += positive(pKa)
+		} else if pKa, ok := negativePKa[sequence[i]]; ok {
+			charge += negative(pKa)
+		}
+	}
+	return charge
+}
+
+// isoelectricPoint finds the pH at which the net charge of a protein sequence is zero by bisection
+func isoelectricPoint(sequence string) float64 {
+	low, high := 0.0, 14.0
+	for high-low > 0.0001 {
+		mid := (low + high) / 2
+		if netCharge(sequence, mid) > 0 {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return (low + high) / 2
+}
@@ -0,0 +1,227 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	contactsHet    string
+	contactsChain  string
+	contactsResSeq int
+	contactsCutoff float64
+	contactsFormat string
+	contactsOutput string
+)
+
+// Distance limits (Å) used to guess the interaction type of a contact
+const (
+	hbondDistance       = 3.5
+	ionicDistance       = 4.0
+	hydrophobicDistance = 4.0
+)
+
+// positiveAtoms and negativeAtoms are the charged side-chain atoms of standard residues at neutral pH
+var (
+	positiveAtoms = map[string]bool{"ARG NE": true, "ARG NH1": true, "ARG NH2": true, "LYS NZ": true, "HIS ND1": true, "HIS NE2": true}
+	negativeAtoms = map[string]bool{"ASP OD1": true, "ASP OD2": true, "GLU OE1": true, "GLU OE2": true}
+)
+
+var ligandContactsCmd = &cobra.Command{
+	Use:   "ligand-contacts [flags] --het NAME [input_file]",
+	Short: "List the protein atoms in contact with a ligand",
+	Long: `List every pair of ligand and polymer atoms within --cutoff (4.0 Å by default) of each other, with
+the distance and a guess of the interaction type, as a table suitable for binding-site fingerprinting.
+
+The interaction type is guessed from the elements, residue names and distance only:
+  ionic        a ligand O within 4.0 Å of an Arg, Lys or His side-chain N, or a ligand N or metal
+               within 4.0 Å of an Asp or Glu side-chain O
+  hbond        a ligand N or O within 3.5 Å of a polymer N or O
+  hydrophobic  a ligand C, S or halogen within 4.0 Å of a polymer C or S
+  contact      any other contact
+
+By default every instance of the component is analysed; use --chain and --resnum to select a
+specific instance. Only the first model is analysed.
+The output is a tab-separated table, or JSON with --format json.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # List the contacts of the heme groups
+  pdbtk ligand-contacts --het HEM 4hhb.pdb
+
+  # List the contacts of one instance within 5 Å as JSON
+  pdbtk ligand-contacts --het HEM --chain A --cutoff 5 --format json 4hhb.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLigandContacts,
+}
+
+func init() {
+	ligandContactsCmd.Flags().StringVar(&contactsHet, "het", "", "Residue name of the ligand (required)")
+	ligandContactsCmd.Flags().StringVarP(&contactsChain, "chain", "c", "", "Only analyse the ligand in this chain")
+	ligandContactsCmd.Flags().IntVar(&contactsResSeq, "resnum", 0, "Only analyse the ligand with this residue number")
+	ligandContactsCmd.Flags().Float64Var(&contactsCutoff, "cutoff", 4.0, "Maximum contact distance (Å)")
+	ligandContactsCmd.Flags().StringVarP(&contactsFormat, "format", "f", "tsv", "Output format: tsv or json")
+	ligandContactsCmd.Flags().StringVarP(&contactsOutput, "output", "o", "", "Output file (default: stdout)")
+	ligandContactsCmd.MarkFlagRequired("het")
+}
+
+// ligandContact is a ligand atom in contact with a polymer atom
+type ligandContact struct {
+	Ligand      string  `json:"ligand"`
+	LigandAtom  string  `json:"ligand_atom"`
+	Chain       string  `json:"chain"`
+	ResNum      string  `json:"resnum"`
+	ResName     string  `json:"resname"`
+	Atom        string  `json:"atom"`
+	Distance    float64 `json:"distance"`
+	Interaction string  `json:"interaction"`
+}
+
+func runLigandContacts(cmd *cobra.Command, args []string) error {
+	contactsHet = strings.ToUpper(strings.TrimSpace(contactsHet))
+	if contactsHet == "" || len(contactsHet) > 3 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --het: %q (must be a residue name of 1-3 characters)", contactsHet))
+	}
+	if contactsChain != "" && len(contactsChain) != 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("chain ID must be a single character, got: %s", contactsChain))
+	}
+	if contactsCutoff <= 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --cutoff: %g (must be positive)", contactsCutoff))
+	}
+	if contactsFormat != "tsv" && contactsFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", contactsFormat))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	models := file.Models()
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if atom.Model == models[0] {
+			atoms = append(atoms, atom)
+		}
+	}
+
+	selected := make(map[ResidueKey]bool)
+	for _, atom := range atoms {
+		if atom.ResName == contactsHet && (contactsChain == "" || atom.ChainID == contactsChain[0]) &&
+			(!cmd.Flags().Changed("resnum") || atom.ResSeq == contactsResSeq) {
+			selected[atom.Residue()] = true
+		}
+	}
+	if len(selected) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no %s residues found", contactsHet))
+	}
+
+	contacts := findLigandContacts(atoms, selected, contactsCutoff)
+	residues := make(map[string]bool)
+	for _, contact := range contacts {
+		residues[contact.Chain+":"+contact.ResNum] = true
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Found %d contacts with %d residues\n", len(contacts), len(residues))
+
+	return writeOutput(cmd.Context(), contactsOutput, func(w io.Writer) error {
+		if contactsFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(contacts)
+		}
+		fmt.Fprintln(w, "ligand\tligand_atom\tchain\tresnum\tresname\tatom\tdistance\tinteraction")
+		for _, c := range contacts {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%.2f\t%s\n", c.Ligand, c.LigandAtom, c.Chain, c.ResNum, c.ResName, c.Atom, c.Distance, c.Interaction)
+		}
+		return nil
+	})
+}
+
+// findLigandContacts returns the contacts between the atoms of the selected residues and polymer atoms
+// within cutoff, in the order of the polymer atoms
+func findLigandContacts(atoms []*AtomRecord, selected map[ResidueKey]bool, cutoff float64) []ligandContact {
+	inferred := inferElements(atoms)
+	elements := make(map[*AtomRecord]string, len(atoms))
+	for i, atom := range atoms {
+		elements[atom] = atomElement(atom, inferred[i])
+	}
+
+	var ligandAtoms []*AtomRecord
+	for _, atom := range atoms {
+		if selected[atom.Residue()] && elements[atom] != "H" && elements[atom] != "D" {
+			ligandAtoms = append(ligandAtoms, atom)
+		}
+	}
+
+	contacts := make([]ligandContact, 0)
+	for _, residue := range groupResidues(atoms) {
+		key := residue[0].Residue()
+		_, standard := oneLetterCode(key.ResName)
+		if selected[key] || !(standard || isPolymerResidue(residue)) {
+			continue
+		}
+		for _, atom := range residue {
+			if elements[atom] == "H" || elements[atom] == "D" {
+				continue
+			}
+			for _, ligandAtom := range ligandAtoms {
+				if !altLocsCompatible(atom, ligandAtom) {
+					continue
+				}
+				d := distance(atom.Coord(), ligandAtom.Coord())
+				if d > cutoff {
+					continue
+				}
+				contacts = append(contacts, ligandContact{
+					Ligand:      ligandAtom.Residue().String(),
+					LigandAtom:  ligandAtom.Name,
+					Chain:       string(key.ChainID),
+					ResNum:      strings.TrimSpace(fmt.Sprintf("%d%c", key.ResSeq, key.ICode)),
+					ResName:     key.ResName,
+					Atom:        atom.Name,
+					Distance:    roundTo(d, 2),
+					Interaction: guessInteraction(elements[ligandAtom], elements[atom], key.ResName+" "+atom.Name, d),
+				})
+			}
+		}
+	}
+	return contacts
+}
+
+// guessInteraction guesses the type of a contact at distance d between a ligand atom and a polymer atom
+// from their elements and the residue and name of the polymer atom (e.g. "LYS NZ")
+func guessInteraction(ligandElement, element, polymerAtom string, d float64) string {
+	switch {
+	case d <= ionicDistance && ligandElement == "O" && positiveAtoms[polymerAtom]:
+		return "ionic"
+	case d <= ionicDistance && (ligandElement == "N" || metalElements[ligandElement]) && negativeAtoms[polymerAtom]:
+		return "ionic"
+	case d <= hbondDistance && (ligandElement == "N" || ligandElement == "O") && (element == "N" || element == "O"):
+		return "hbond"
+	case d <= hydrophobicDistance && isApolarElement(ligandElement) && (element == "C" || element == "S"):
+		return "hydrophobic"
+	}
+	return "contact"
+}
+
+// isApolarElement reports whether a ligand atom of this element takes part in hydrophobic contacts
+func isApolarElement(element string) bool {
+	switch element {
+	case "C", "S", "F", "CL", "BR", "I":
+		return true
+	}
+	return false
+}
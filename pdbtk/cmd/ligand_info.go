@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ligandInfoOutput string
+	ligandInfoFormat string
+)
+
+var ligandInfoCmd = &cobra.Command{
+	Use:   "ligand-info [flags] [input_file]",
+	Short: "Look up SMILES and InChI for the het components of a structure",
+	Long: `Report the name, formula, SMILES and InChI of every het component (ligands, ions and modified
+residues; waters are left out) of a structure, so ligands can be cross-referenced against chemical
+databases.
+
+The descriptors are looked up in the Chemical Component Dictionary through the RCSB Data API
+(https://data.rcsb.org/rest/v1/core/chemcomp/{id}); set PDBTK_RCSB_DATA_URL to use a mirror.
+Components that are not in the dictionary are reported with empty fields and a warning.
+The output is a tab-separated table, or JSON with --format json.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Look up the ligands of a structure
+  pdbtk ligand-info 4hhb.pdb
+
+  # Report as JSON
+  pdbtk ligand-info --format json 4hhb.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runLigandInfo,
+}
+
+func init() {
+	ligandInfoCmd.Flags().StringVarP(&ligandInfoOutput, "output", "o", "", "Output file (default: stdout)")
+	ligandInfoCmd.Flags().StringVarP(&ligandInfoFormat, "format", "f", "tsv", "Output format: tsv or json")
+}
+
+// chemCompInfo holds the chemical descriptors of a het component
+type chemCompInfo struct {
+	ID           string `json:"id"`
+	Instances    int    `json:"instances"`
+	Name         string `json:"name"`
+	Formula      string `json:"formula"`
+	SMILES       string `json:"smiles"`
+	SMILESStereo string `json:"smiles_stereo"`
+	InChI        string `json:"inchi"`
+	InChIKey     string `json:"inchikey"`
+}
+
+// rcsbChemComp is the part of the RCSB Data API chemcomp response used by ligand-info and modified-residues
+type rcsbChemComp struct {
+	ChemComp struct {
+		Name          string   `json:"name"`
+		Formula       string   `json:"formula"`
+		ParentCompIDs []string `json:"mon_nstd_parent_comp_id"`
+		OneLetterCode string   `json:"one_letter_code"`
+	} `json:"chem_comp"`
+	Descriptor struct {
+		SMILES       string `json:"SMILES"`
+		SMILESStereo string `json:"SMILES_stereo"`
+		InChI        string `json:"InChI"`
+		InChIKey     string `json:"InChIKey"`
+	} `json:"rcsb_chem_comp_descriptor"`
+}
+
+func runLigandInfo(cmd *cobra.Command, args []string) error {
+	if ligandInfoFormat != "tsv" && ligandInfoFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", ligandInfoFormat))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	components := hetComponents(file)
+	if len(components) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no het components found"))
+	}
+
+	for _, component := range components {
+		if err := lookupChemComp(cmd.Context(), component); err != nil {
+			return err
+		}
+	}
+
+	return writeOutput(cmd.Context(), ligandInfoOutput, func(w io.Writer) error {
+		if ligandInfoFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(components)
+		}
+		fmt.Fprintln(w, "id\tinstances\tname\tformula\tsmiles\tsmiles_stereo\tinchi\tinchikey")
+		for _, c := range components {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, c.Instances, c.Name, c.Formula, c.SMILES, c.SMILESStereo, c.InChI, c.InChIKey)
+		}
+		return nil
+	})
+}
+
+// hetComponents returns the het components of the first model in order of appearance, with the number
+// of residues of each
+func hetComponents(file *PDBFile) []*chemCompInfo {
+	var components []*chemCompInfo
+	byName := make(map[string]*chemCompInfo)
+	models := file.Models()
+	for _, residue := range groupResidues(file.Atoms) {
+		atom := residue[0]
+		if atom.Model != models[0] || !atom.Het || waterResidues[atom.ResName] {
+			continue
+		}
+		component, ok := byName[atom.ResName]
+		if !ok {
+			component = &chemCompInfo{ID: atom.ResName}
+			byName[atom.ResName] = component
+			components = append(components, component)
+		}
+		component.Instances++
+	}
+	return components
+}
+
+// lookupChemComp fills in the descriptors of a component from the RCSB Data API
+func lookupChemComp(ctx context.Context, component *chemCompInfo) error {
+	var response rcsbChemComp
+	err := fetchJSON(ctx, rcsbDataURL()+"/rest/v1/core/chemcomp/"+url.PathEscape(component.ID), &response)
+	if errors.Is(err, errNotFound) {
+		return warn("component %s is not in the Chemical Component Dictionary", component.ID)
+	}
+	if err != nil {
+		return err
+	}
+	component.Name = response.ChemComp.Name
+	component.Formula = response.ChemComp.Formula
+	component.SMILES = response.Descriptor.SMILES
+	component.SMILESStereo = response.Descriptor.SMILESStereo
+	component.InChI = response.Descriptor.InChI
+	component.InChIKey = response.Descriptor.InChIKey
+	return nil
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var manifestFile string
+
+// manifest is the open --manifest file, nil when no manifest is written
+var manifest *os.File
+
+// manifestDone are the inputs recorded as processed in the manifest of an earlier run, with their
+// outputs, when resuming
+var manifestDone map[string]string
+
+// inputStart and inputWarnings mark the start of the current input: when it began and how many
+// warnings had been raised before it
+var (
+	inputStart    = time.Now()
+	inputWarnings int
+)
+
+// manifestEntry is the line of the --manifest file written for each input
+type manifestEntry struct {
+	Input    string       `json:"input"`
+	Output   string       `json:"output"`
+	Status   string       `json:"status"`
+	Chains   []string     `json:"chains,omitempty"`
+	Atoms    *int         `json:"atoms,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+	Seconds  float64      `json:"seconds"`
+	Error    *reportError `json:"error,omitempty"`
+}
+
+// openManifest creates the --manifest file, if one was requested. When resuming, the inputs processed
+// by earlier runs are read from it first and the new entries are appended.
+func openManifest(resume bool) error {
+	if manifestFile == "" || manifest != nil {
+		return nil
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	var previous []byte
+	if resume {
+		var err error
+		if previous, err = os.ReadFile(manifestFile); err != nil && !os.IsNotExist(err) {
+			return withCode(ErrCodeIO, fmt.Errorf("failed to read manifest: %v", err))
+		}
+		manifestDone = parseManifestDone(previous)
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(manifestFile, flags, 0644)
+	if err != nil {
+		return withCode(ErrCodeIO, fmt.Errorf("failed to create manifest: %v", err))
+	}
+	manifest = f
+	if len(previous) > 0 && previous[len(previous)-1] != '\n' {
+		// End the line cut off by an interrupted run, so the new entries start on a line of their own
+		if _, err := f.Write([]byte("\n")); err != nil {
+			return withCode(ErrCodeIO, fmt.Errorf("failed to write manifest: %v", err))
+		}
+	}
+	return nil
+}
+
+// parseManifestDone returns the inputs whose last entry in a manifest is successful (or skipped), with
+// their outputs. Lines that cannot be parsed, such as the last line of an interrupted run, are ignored.
+func parseManifestDone(content []byte) map[string]string {
+	done := make(map[string]string)
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		var entry manifestEntry
+		if json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		switch entry.Status {
+		case "ok", "skipped":
+			done[entry.Input] = entry.Output
+		case "error":
+			delete(done, entry.Input)
+		}
+	}
+	return done
+}
+
+// closeManifest closes the --manifest file
+func closeManifest() error {
+	if manifest == nil {
+		return nil
+	}
+	err := manifest.Close()
+	manifest = nil
+	if err != nil {
+		return withCode(ErrCodeIO, fmt.Errorf("failed to write manifest: %v", err))
+	}
+	return nil
+}
+
+// beginInput marks the start of processing an input, for the timing and warnings of its manifest entry
+func beginInput() {
+	inputStart = time.Now()
+	inputWarnings = len(currentReport.Warnings)
+}
+
+// writeManifestEntry writes the manifest line of a result. stats are the coordinate records written
+// for it, nil when the output was not counted.
+func writeManifestEntry(result inputResult, stats *outputStats) {
+	defer beginInput()
+	if manifest == nil {
+		return
+	}
+	entry := manifestEntry{
+		Input:   result.Input,
+		Output:  result.Output,
+		Status:  result.Status,
+		Seconds: time.Since(inputStart).Seconds(),
+		Error:   result.Error,
+	}
+	for _, warning := range currentReport.Warnings[inputWarnings:] {
+		entry.Warnings = append(entry.Warnings, warning.Message)
+	}
+	if stats != nil && result.Status == "ok" && stats.atoms > 0 {
+		entry.Chains = stats.chains
+		entry.Atoms = &stats.atoms
+	}
+	line, _ := json.Marshal(entry)
+	// Each line is written as soon as the input is done, so an interrupted run leaves a valid manifest
+	if _, err := manifest.Write(append(line, '\n')); err != nil {
+		printError(manifestFile, withCode(ErrCodeIO, fmt.Errorf("failed to write manifest: %v", err)))
+	}
+}
+
+// outputStats counts the atoms and chains of the coordinate records written through it
+type outputStats struct {
+	w       io.Writer
+	atoms   int
+	chains  []string
+	seen    map[byte]bool
+	partial []byte
+}
+
+func newOutputStats(w io.Writer) *outputStats {
+	return &outputStats{w: w, seen: make(map[byte]bool)}
+}
+
+func (s *outputStats) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	data := append(s.partial, p[:n]...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		s.countLine(data[:i])
+		data = data[i+1:]
+	}
+	s.partial = append([]byte(nil), data...)
+	return n, err
+}
+
+// countLine counts a line of output if it is an ATOM or HETATM record
+func (s *outputStats) countLine(line []byte) {
+	if !bytes.HasPrefix(line, []byte("ATOM  ")) && !bytes.HasPrefix(line, []byte("HETATM")) {
+		return
+	}
+	s.atoms++
+	if len(line) > 21 && line[21] != ' ' && !s.seen[line[21]] {
+		s.seen[line[21]] = true
+		s.chains = append(s.chains, string(line[21]))
+	}
+}
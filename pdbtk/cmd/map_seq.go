@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mapSeqFasta  string
+	mapSeqID     string
+	mapSeqChain  string
+	mapSeqOutput string
+)
+
+// Statuses of the positions reported by map-seq
+const (
+	mapStatusMatch         = "match"
+	mapStatusMismatch      = "mismatch"
+	mapStatusUnmodelled    = "unmodelled"
+	mapStatusNotInSequence = "not_in_sequence"
+)
+
+var mapSeqCmd = &cobra.Command{
+	Use:   "map-seq [flags] --fasta sequence.fasta [input_file]",
+	Short: "Map a FASTA sequence onto the residues of a chain",
+	Long: `Align a sequence from a FASTA file (for example a construct or UniProt sequence) to the observed
+residues of a chain and report the correspondence of every position.
+
+The output is a tab-separated table with one row per alignment column: the sequence position and
+residue, the chain, residue number, insertion code and residue name it corresponds to, and a status:
+match, mismatch, unmodelled (sequence positions with no residue in the structure) or
+not_in_sequence (residues of the structure that are not in the sequence).
+A summary of the mapping is written to stderr.
+
+The sequence is aligned to the one-letter sequence of the chain's first model, with modified residues
+written as their parent residue. Overhangs at either end of either sequence are not penalised.
+--chain is required when the structure has more than one chain. The first sequence of the FASTA
+file is used unless --seq-id is given.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Map a UniProt sequence onto chain A
+  pdbtk map-seq --fasta P01308.fasta --chain A 1a02.pdb
+
+  # Map one sequence of a multi-sequence FASTA file and save the table
+  pdbtk map-seq --fasta constructs.fasta --seq-id construct_2 --chain B 1a02.pdb --output map.tsv`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runMapSeq,
+}
+
+func init() {
+	mapSeqCmd.Flags().StringVar(&mapSeqFasta, "fasta", "", "FASTA file with the sequence to map (required)")
+	mapSeqCmd.Flags().StringVar(&mapSeqID, "seq-id", "", "ID of the sequence to use from the FASTA file (default: the first sequence)")
+	mapSeqCmd.Flags().StringVarP(&mapSeqChain, "chain", "c", "", "Chain to map the sequence onto (default: the only chain)")
+	mapSeqCmd.Flags().StringVarP(&mapSeqOutput, "output", "o", "", "Output file (default: stdout)")
+	mapSeqCmd.MarkFlagRequired("fasta")
+}
+
+// sequenceMapping is one column of the alignment of a sequence to a chain
+type sequenceMapping struct {
+	SeqPosition int // 1-based; 0 for residues that are not in the sequence
+	SeqResidue  byte
+	Residue     *ResidueKey // nil for unmodelled positions
+	Code        byte        // one-letter code of Residue
+	Status      string
+}
+
+func runMapSeq(cmd *cobra.Command, args []string) error {
+	if mapSeqChain != "" && len(mapSeqChain) != 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid chain ID: %s (must be single character)", mapSeqChain))
+	}
+
+	query, err := readMapSequence(cmd.Context(), mapSeqFasta, mapSeqID)
+	if err != nil {
+		return err
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	models := file.Models()
+	if len(models) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no coordinate records found"))
+	}
+	sequences := chainSequences(file, models[0], parseModres(file.Header), "parent")
+
+	chainID, err := selectMapChain(file, sequences, mapSeqChain)
+	if err != nil {
+		return err
+	}
+
+	var observed []sequencePosition
+	for _, position := range sequences[chainID] {
+		if position.Residue != nil {
+			observed = append(observed, position)
+		}
+	}
+	mapping := mapSequence(query.Sequence, observed)
+
+	err = writeOutput(cmd.Context(), mapSeqOutput, func(w io.Writer) error {
+		return writeSequenceMapping(w, mapping, chainID)
+	})
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, column := range mapping {
+		counts[column.Status]++
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Mapped %s onto chain %c: %d matches, %d mismatches, %d unmodelled, %d not in sequence\n",
+		query.ID, chainID, counts[mapStatusMatch], counts[mapStatusMismatch], counts[mapStatusUnmodelled], counts[mapStatusNotInSequence])
+	return nil
+}
+
+// readMapSequence reads the FASTA file and returns the sequence with the given ID, or the first one
+func readMapSequence(ctx context.Context, fastaFile, id string) (fastaRecord, error) {
+	var content []byte
+	var err error
+	if isObjectURI(fastaFile) {
+		content, err = readObject(ctx, fastaFile)
+	} else {
+		content, err = os.ReadFile(fastaFile)
+	}
+	if err != nil {
+		if os.IsNotExist(err) || errors.Is(err, errNotFound) {
+			return fastaRecord{}, withCode(ErrCodeInputNotFound, fmt.Errorf("FASTA file not found: %s", fastaFile))
+		}
+		return fastaRecord{}, withCode(ErrCodeIO, fmt.Errorf("failed to read FASTA file: %v", err))
+	}
+	records, err := parseFASTA(content)
+	if err != nil {
+		return fastaRecord{}, withCode(ErrCodeParse, fmt.Errorf("failed to read FASTA file: %v", err))
+	}
+	for _, record := range records {
+		if (id == "" || record.ID == id) && record.Sequence != "" {
+			return record, nil
+		}
+	}
+	if id != "" {
+		return fastaRecord{}, withCode(ErrCodeNoMatch, fmt.Errorf("sequence %s not found in %s", id, fastaFile))
+	}
+	return fastaRecord{}, withCode(ErrCodeParse, fmt.Errorf("no sequences found in %s", fastaFile))
+}
+
+// selectMapChain returns the chain named by chain, or the only chain with a polymer sequence
+func selectMapChain(file *PDBFile, sequences map[byte][]sequencePosition, chain string) (byte, error) {
+	if chain != "" {
+		if len(sequences[chain[0]]) == 0 {
+			return 0, withCode(ErrCodeNoMatch, fmt.Errorf("no polymer residues found in chain %s", chain))
+		}
+		return chain[0], nil
+	}
+
+	var chainIDs []string
+	for _, chainID := range file.ChainIDs() {
+		if len(sequences[chainID]) > 0 {
+			chainIDs = append(chainIDs, string(chainID))
+		}
+	}
+	switch len(chainIDs) {
+	case 0:
+		return 0, withCode(ErrCodeNoMatch, fmt.Errorf("no polymer residues found"))
+	case 1:
+		return chainIDs[0][0], nil
+	}
+	return 0, withCode(ErrCodeInvalidArgument, fmt.Errorf("the structure has %d chains (%s); select one with --chain", len(chainIDs), strings.Join(chainIDs, ",")))
+}
+
+// mapSequence aligns sequence to the observed residues of a chain and classifies every alignment column
+func mapSequence(sequence string, observed []sequencePosition) []sequenceMapping {
+	codes := make([]byte, len(observed))
+	for i, position := range observed {
+		codes[i] = position.Code
+	}
+
+	var mapping []sequenceMapping
+	for _, pair := range alignSequences(sequence, string(codes)) {
+		column := sequenceMapping{}
+		if pair.A >= 0 {
+			column.SeqPosition = pair.A + 1
+			column.SeqResidue = sequence[pair.A]
+		}
+		if pair.B >= 0 {
+			column.Residue = observed[pair.B].Residue
+			column.Code = observed[pair.B].Code
+		}
+		switch {
+		case pair.B < 0:
+			column.Status = mapStatusUnmodelled
+		case pair.A < 0:
+			column.Status = mapStatusNotInSequence
+		case column.SeqResidue == column.Code:
+			column.Status = mapStatusMatch
+		default:
+			column.Status = mapStatusMismatch
+		}
+		mapping = append(mapping, column)
+	}
+	return mapping
+}
+
+// writeSequenceMapping writes the mapping as a tab-separated table
+func writeSequenceMapping(writer io.Writer, mapping []sequenceMapping, chainID byte) error {
+	w := bufio.NewWriter(writer)
+	fmt.Fprintln(w, "seq_position\tseq_residue\tchain\tresnum\ticode\tresname\tstatus")
+	for _, column := range mapping {
+		seqPosition, seqResidue := "", ""
+		if column.SeqPosition > 0 {
+			seqPosition = fmt.Sprint(column.SeqPosition)
+			seqResidue = string(column.SeqResidue)
+		}
+		resnum, icode, resname := "", "", ""
+		if column.Residue != nil {
+			resnum = fmt.Sprint(column.Residue.ResSeq)
+			icode = strings.TrimSpace(string(column.Residue.ICode))
+			resname = column.Residue.ResName
+		}
+		fmt.Fprintf(w, "%s\t%s\t%c\t%s\t%s\t%s\t%s\n", seqPosition, seqResidue, chainID, resnum, icode, resname, column.Status)
+	}
+	return w.Flush()
+}
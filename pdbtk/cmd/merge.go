@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mergeNoRename bool
+	mergeOutput   string
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge [flags] input_file input_file...",
+	Short: "Merge several PDB files into one structure",
+	Long: `Merge the coordinates of several PDB files (or glob patterns) into a single structure, e.g. to
+combine a protein with a docked ligand or separately modelled domains.
+
+Chains keep their IDs unless an earlier input already has a chain with the same ID. Such chains are
+given the first chain ID that none of the inputs use (A-Z, a-z, 0-9), and every renamed chain is
+reported on stderr. Use --no-rename to fail on chain ID collisions instead.
+
+Only the first model of each input is used. Atom serial numbers and CONECT records are renumbered to
+follow on from the previous input, and the HEADER of the first input is kept.
+
+Examples:
+  # Merge a receptor and a docked ligand
+  pdbtk merge receptor.pdb ligand.pdb > complex.pdb
+
+  # Merge every model in a directory, failing if any chain IDs collide
+  pdbtk merge --no-rename --output merged.pdb models/*.pdb`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMerge,
+}
+
+func init() {
+	mergeCmd.Flags().BoolVar(&mergeNoRename, "no-rename", false, "Fail on chain ID collisions instead of renaming chains")
+	mergeCmd.Flags().StringVarP(&mergeOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// chainRename is a chain of a merged input that was given a new chain ID
+type chainRename struct {
+	File     string
+	Original byte
+	New      byte
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	inputs, err := expandInputs(cmd.Context(), args)
+	if err != nil {
+		return err
+	}
+	if len(inputs) < 2 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("merge requires at least two input files"))
+	}
+	files := make([]*PDBFile, len(inputs))
+	for i, inputFile := range inputs {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return withCode(errorCode(err), fmt.Errorf("%s: %v", inputFile, err))
+		}
+		if models := file.Models(); len(models) > 1 {
+			if err := warn("%s has %d models; only model %d is merged", inputFile, len(models), models[0]); err != nil {
+				return err
+			}
+		}
+		files[i] = file
+	}
+
+	merged, renames, err := mergeFiles(inputs, files, !mergeNoRename)
+	if err != nil {
+		return err
+	}
+	for _, rename := range renames {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s: chain %c renamed to %c\n", rename.File, rename.Original, rename.New)
+	}
+
+	return writeOutput(cmd.Context(), mergeOutput, func(w io.Writer) error {
+		return writePDBRecords(merged, w, recordCommandLine(cmd, inputs, ""))
+	})
+}
+
+// mergeFiles merges the first model of each file into a single model. Chains whose ID is used by an
+// earlier file are given a chain ID that no file uses when rename is set, and are an error otherwise.
+func mergeFiles(names []string, files []*PDBFile, rename bool) (*PDBFile, []chainRename, error) {
+	reserved := make(map[byte]bool)
+	for _, file := range files {
+		for _, chainID := range file.ChainIDs() {
+			reserved[chainID] = true
+		}
+	}
+
+	var header []string
+	for _, line := range files[0].Header {
+		if strings.HasPrefix(line, "HEADER") || isCrystalRecord(line) {
+			header = append(header, line)
+		}
+	}
+	merged := &PDBFile{Header: header}
+	var renames []chainRename
+	used := make(map[byte]bool)
+	next := 0
+	offset := 0
+	for i, file := range files {
+		models := file.Models()
+		if len(models) == 0 {
+			continue
+		}
+		chainIDs := make(map[byte]byte)
+		for _, chainID := range file.ChainIDs() {
+			if !used[chainID] {
+				chainIDs[chainID] = chainID
+				continue
+			}
+			if !rename {
+				return nil, nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("chain %c of %s is also in an earlier input (drop --no-rename, or use rename-chain first)", chainID, names[i]))
+			}
+			for next < len(chainIDAlphabet) && reserved[chainIDAlphabet[next]] {
+				next++
+			}
+			if next == len(chainIDAlphabet) {
+				return nil, nil, fmt.Errorf("no unused chain ID left for chain %c of %s", chainID, names[i])
+			}
+			chainIDs[chainID] = chainIDAlphabet[next]
+			reserved[chainIDAlphabet[next]] = true
+			renames = append(renames, chainRename{File: names[i], Original: chainID, New: chainIDAlphabet[next]})
+		}
+
+		serialMap := make(map[int]int)
+		maxSerial := 0
+		for _, atom := range file.Atoms {
+			if atom.Model != models[0] {
+				continue
+			}
+			maxSerial = max(maxSerial, atom.Serial)
+			serialMap[atom.Serial] = atom.Serial + offset
+			atom.Serial += offset
+			atom.ChainID = chainIDs[atom.ChainID]
+			atom.Model = 1
+			merged.Atoms = append(merged.Atoms, atom)
+		}
+		for _, chainID := range chainIDs {
+			used[chainID] = true
+		}
+		merged.Conect = append(merged.Conect, remapConect(file.Conect, serialMap)...)
+		offset += maxSerial
+	}
+	return merged, renames, nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var metadataOutput string
+
+// metadataQuery is the RCSB Data API GraphQL query used by the metadata command
+const metadataQuery = `query($ids: [String!]!) {
+  entries(entry_ids: $ids) {
+    rcsb_id
+    struct { title pdbx_descriptor }
+    struct_keywords { pdbx_keywords text }
+    exptl { method }
+    rcsb_entry_info {
+      resolution_combined
+      experimental_method
+      molecular_weight
+      deposited_atom_count
+      deposited_polymer_entity_instance_count
+      polymer_entity_count
+      nonpolymer_entity_count
+    }
+    rcsb_accession_info { deposit_date initial_release_date revision_date }
+    rcsb_primary_citation { title journal_abbrev year pdbx_database_id_DOI pdbx_database_id_PubMed rcsb_authors }
+    citation { id title journal_abbrev year pdbx_database_id_DOI pdbx_database_id_PubMed rcsb_authors }
+    polymer_entities {
+      rcsb_id
+      rcsb_polymer_entity { pdbx_description formula_weight }
+      entity_poly { type rcsb_entity_polymer_type pdbx_strand_id pdbx_seq_one_letter_code_can }
+      rcsb_entity_source_organism { scientific_name ncbi_taxonomy_id }
+      rcsb_polymer_entity_container_identifiers { uniprot_ids }
+    }
+    nonpolymer_entities {
+      rcsb_id
+      nonpolymer_comp { chem_comp { id name formula } }
+      rcsb_nonpolymer_entity_container_identifiers { auth_asym_ids }
+    }
+    assemblies {
+      rcsb_id
+      pdbx_struct_assembly { details oligomeric_details oligomeric_count }
+      rcsb_struct_symmetry { kind symbol type stoichiometry }
+    }
+  }
+}`
+
+var metadataCmd = &cobra.Command{
+	Use:   "metadata [flags] <pdb_code> [pdb_code...]",
+	Short: "Look up entry, entity and assembly metadata from the RCSB PDB",
+	Long: `Look up the metadata of PDB entries through the RCSB Data API (GraphQL) and write it as JSON:
+title, keywords, experimental method, resolution, dates and citations of the entry; description,
+type, chains, sequence, source organism and UniProt accessions of the polymer entities; the
+ligands; and the oligomeric state and symmetry of the assemblies.
+
+One entry is written as a JSON object, several entries as an array. Entries that are not found
+are reported as warnings. The query is sent to https://data.rcsb.org/graphql; set
+PDBTK_RCSB_DATA_URL to use a mirror.
+
+Examples:
+  # Look up the metadata of an entry
+  pdbtk metadata 4HHB
+
+  # Collect the metadata of several entries into one file
+  pdbtk metadata 1A02 4HHB 1CRN --output metadata.json`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMetadata,
+}
+
+func init() {
+	metadataCmd.Flags().StringVarP(&metadataOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// graphQLRequest is the body of a GraphQL request
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// graphQLError is an error reported in a GraphQL response
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+func runMetadata(cmd *cobra.Command, args []string) error {
+	ids := make([]string, len(args))
+	for i, arg := range args {
+		ids[i] = strings.ToUpper(arg)
+		if len(ids[i]) != 4 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("PDB code must be exactly 4 characters, got: %s", ids[i]))
+		}
+	}
+
+	var response struct {
+		Data struct {
+			Entries []json.RawMessage `json:"entries"`
+		} `json:"data"`
+		Errors []graphQLError `json:"errors"`
+	}
+	request := graphQLRequest{Query: metadataQuery, Variables: map[string]interface{}{"ids": ids}}
+	if err := postJSON(cmd.Context(), rcsbDataURL()+"/graphql", request, &response); err != nil {
+		return err
+	}
+	if len(response.Errors) > 0 && len(response.Data.Entries) == 0 {
+		return withCode(ErrCodeNetwork, fmt.Errorf("query failed: %s", response.Errors[0].Message))
+	}
+
+	found := make(map[string]bool)
+	var entries []json.RawMessage
+	for _, entry := range response.Data.Entries {
+		var id struct {
+			RcsbID string `json:"rcsb_id"`
+		}
+		if err := json.Unmarshal(entry, &id); err != nil || id.RcsbID == "" {
+			continue
+		}
+		found[strings.ToUpper(id.RcsbID)] = true
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("entries not found: %s", strings.Join(ids, ", ")))
+	}
+	for _, id := range ids {
+		if !found[id] {
+			if err := warn("entry not found: %s", id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return writeOutput(cmd.Context(), metadataOutput, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if len(ids) == 1 {
+			return encoder.Encode(entries[0])
+		}
+		return encoder.Encode(entries)
+	})
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	metalOutput string
+	metalFormat string
+	metalCutoff float64
+)
+
+// metalElements are the element symbols treated as metals by metal-sites
+var metalElements = map[string]bool{
+	"LI": true, "NA": true, "K": true, "RB": true, "CS": true, "BE": true, "MG": true, "CA": true,
+	"SR": true, "BA": true, "AL": true, "GA": true, "V": true, "CR": true, "MN": true, "FE": true,
+	"CO": true, "NI": true, "CU": true, "ZN": true, "MO": true, "W": true, "RU": true, "RH": true,
+	"PD": true, "AG": true, "CD": true, "PT": true, "AU": true, "HG": true, "PB": true, "TL": true,
+	"SN": true, "IR": true, "OS": true, "RE": true, "YB": true, "SM": true, "GD": true, "TB": true,
+	"EU": true, "LA": true, "CE": true, "LU": true, "U": true,
+}
+
+// coordinationGeometry is an ideal coordination geometry given by the directions of its ligands
+type coordinationGeometry struct {
+	name       string
+	directions []vec3
+}
+
+// coordinationGeometries are the ideal geometries that observed coordination spheres are compared with
+var coordinationGeometries = []coordinationGeometry{
+	{"linear", []vec3{{1, 0, 0}, {-1, 0, 0}}},
+	{"bent", []vec3{{1, 0, 0}, {-0.334, 0.943, 0}}},
+	{"trigonal planar", ringDirections(3, 0)},
+	{"trigonal pyramidal", []vec3{{1, -1, -1}, {-1, 1, -1}, {-1, -1, 1}}},
+	{"T-shaped", []vec3{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}}},
+	{"tetrahedral", []vec3{{1, 1, 1}, {1, -1, -1}, {-1, 1, -1}, {-1, -1, 1}}},
+	{"square planar", ringDirections(4, 0)},
+	{"trigonal bipyramidal", append(ringDirections(3, 0), vec3{0, 0, 1}, vec3{0, 0, -1})},
+	{"square pyramidal", append(ringDirections(4, 0), vec3{0, 0, 1})},
+	{"octahedral", append(ringDirections(4, 0), vec3{0, 0, 1}, vec3{0, 0, -1})},
+	{"trigonal prismatic", append(ringDirections(3, 0.8), ringDirections(3, -0.8)...)},
+	{"pentagonal bipyramidal", append(ringDirections(5, 0), vec3{0, 0, 1}, vec3{0, 0, -1})},
+	{"square antiprismatic", append(ringDirections(4, 0.7), rotatedRing(4, -0.7, math.Pi/4)...)},
+}
+
+// ringDirections returns n directions evenly spaced around the z axis at height z
+func ringDirections(n int, z float64) []vec3 {
+	return rotatedRing(n, z, 0)
+}
+
+// rotatedRing is like ringDirections with the ring rotated by offset radians
+func rotatedRing(n int, z, offset float64) []vec3 {
+	directions := make([]vec3, n)
+	for i := range directions {
+		angle := offset + 2*math.Pi*float64(i)/float64(n)
+		directions[i] = vec3{math.Cos(angle), math.Sin(angle), z}
+	}
+	return directions
+}
+
+var metalSitesCmd = &cobra.Command{
+	Use:   "metal-sites [flags] [input_file]",
+	Short: "Report metal ions and their coordination spheres",
+	Long: `Find the metal atoms of a structure (free ions as well as metals bound in cofactors such as heme)
+and report the atoms coordinating them with their distances, the coordination number and the
+coordination geometry.
+
+Coordinating atoms are the non-carbon, non-hydrogen atoms within --cutoff of the metal (3.0 Å by
+default), including water oxygens. The geometry is the ideal geometry for the coordination number
+(linear, trigonal planar, tetrahedral, square planar, trigonal bipyramidal, square pyramidal,
+octahedral, ...) whose ligand-metal-ligand angles best match the observed angles; the RMS deviation
+of the angles is reported so that distorted sites can be recognised.
+Only the first model is analysed. If no input file is specified, reads from stdin.
+
+Examples:
+  # Report the metal sites of a structure
+  pdbtk metal-sites 1ca2.pdb
+
+  # Use a longer cutoff and report as JSON
+  pdbtk metal-sites --cutoff 3.2 --format json 1ca2.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runMetalSites,
+}
+
+func init() {
+	metalSitesCmd.Flags().StringVarP(&metalOutput, "output", "o", "", "Output file (default: stdout)")
+	metalSitesCmd.Flags().StringVarP(&metalFormat, "format", "f", "text", "Output format: text or json")
+	metalSitesCmd.Flags().Float64Var(&metalCutoff, "cutoff", 3.0, "Maximum metal-ligand distance (Å)")
+}
+
+// coordinatingAtom is an atom coordinating a metal
+type coordinatingAtom struct {
+	Residue  string  `json:"residue"`
+	Atom     string  `json:"atom"`
+	Element  string  `json:"element"`
+	Distance float64 `json:"distance"`
+}
+
+// metalSite is a metal atom with its coordination sphere
+type metalSite struct {
+	Residue         string             `json:"residue"`
+	Atom            string             `json:"atom"`
+	Element         string             `json:"element"`
+	CoordinationNum int                `json:"coordination_number"`
+	Geometry        string             `json:"geometry"`
+	AngleDeviation  float64            `json:"angle_rms_deviation"`
+	Ligands         []coordinatingAtom `json:"ligands"`
+}
+
+func runMetalSites(cmd *cobra.Command, args []string) error {
+	if metalFormat != "text" && metalFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", metalFormat))
+	}
+	if metalCutoff <= 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --cutoff: %g (must be positive)", metalCutoff))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	sites := findMetalSites(file, metalCutoff)
+	if len(sites) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no metal atoms found"))
+	}
+
+	return writeOutput(cmd.Context(), metalOutput, func(w io.Writer) error {
+		if metalFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(sites)
+		}
+		for _, site := range sites {
+			fmt.Fprintf(w, "%s %s: coordination number %d, %s", site.Residue, site.Atom, site.CoordinationNum, site.Geometry)
+			if site.CoordinationNum > 1 {
+				fmt.Fprintf(w, " (RMS angle deviation %.1f°)", site.AngleDeviation)
+			}
+			fmt.Fprintln(w)
+			for _, ligand := range site.Ligands {
+				fmt.Fprintf(w, "  %-4s %-12s %.2f Å\n", ligand.Atom, ligand.Residue, ligand.Distance)
+			}
+		}
+		return nil
+	})
+}
+
+// atomElement returns the element of an atom, inferred from its name if the element column is blank
+func atomElement(atom *AtomRecord, inferred string) string {
+	if atom.Element != "" {
+		return strings.ToUpper(atom.Element)
+	}
+	return inferred
+}
+
+// findMetalSites finds the metal atoms of the first model and the atoms coordinating them
+func findMetalSites(file *PDBFile, cutoff float64) []*metalSite {
+	models := file.Models()
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if atom.Model == models[0] {
+			atoms = append(atoms, atom)
+		}
+	}
+	inferred := inferElements(atoms)
+	elements := make([]string, len(atoms))
+	for i, atom := range atoms {
+		elements[i] = atomElement(atom, inferred[i])
+	}
+
+	var sites []*metalSite
+	for i, metal := range atoms {
+		if !metal.Het || !metalElements[elements[i]] {
+			continue
+		}
+		site := &metalSite{Residue: metal.Residue().String(), Atom: metal.Name, Element: elements[i], Ligands: make([]coordinatingAtom, 0)}
+		var directions []vec3
+		for j, atom := range atoms {
+			switch elements[j] {
+			case "C", "H", "D":
+				continue
+			}
+			if i == j || metalElements[elements[j]] || !altLocsCompatible(metal, atom) {
+				continue
+			}
+			d := distance(metal.Coord(), atom.Coord())
+			if d > cutoff {
+				continue
+			}
+			site.Ligands = append(site.Ligands, coordinatingAtom{Residue: atom.Residue().String(), Atom: atom.Name,
+				Element: elements[j], Distance: roundTo(d, 2)})
+			directions = append(directions, atom.Coord().sub(metal.Coord()))
+		}
+		sort.SliceStable(site.Ligands, func(a, b int) bool {
+			return site.Ligands[a].Distance < site.Ligands[b].Distance
+		})
+		site.CoordinationNum = len(site.Ligands)
+		site.Geometry, site.AngleDeviation = classifyCoordination(directions)
+		sites = append(sites, site)
+	}
+	return sites
+}
+
+// altLocsCompatible reports whether two atoms can be present at the same time
+func altLocsCompatible(a, b *AtomRecord) bool {
+	return a.AltLoc == ' ' || b.AltLoc == ' ' || a.AltLoc == b.AltLoc
+}
+
+// classifyCoordination returns the ideal geometry with the same number of ligands whose sorted
+// ligand-metal-ligand angles best match those of directions, and the RMS deviation of the angles in degrees
+func classifyCoordination(directions []vec3) (string, float64) {
+	switch len(directions) {
+	case 0:
+		return "none", 0
+	case 1:
+		return "monodentate", 0
+	}
+	observed := sortedAngles(directions)
+	best, bestDeviation := "irregular", math.Inf(1)
+	for _, geometry := range coordinationGeometries {
+		if len(geometry.directions) != len(directions) {
+			continue
+		}
+		ideal := sortedAngles(geometry.directions)
+		sum := 0.0
+		for k := range ideal {
+			d := ideal[k] - observed[k]
+			sum += d * d
+		}
+		if deviation := math.Sqrt(sum / float64(len(ideal))); deviation < bestDeviation {
+			best, bestDeviation = geometry.name, deviation
+		}
+	}
+	if math.IsInf(bestDeviation, 1) {
+		return "irregular", 0
+	}
+	return best, roundTo(bestDeviation, 1)
+}
+
+// sortedAngles returns the angles in degrees between every pair of directions, in increasing order
+func sortedAngles(directions []vec3) []float64 {
+	var angles []float64
+	for i := range directions {
+		for j := i + 1; j < len(directions); j++ {
+			cos := directions[i].unit().dot(directions[j].unit())
+			angles = append(angles, math.Acos(math.Max(-1, math.Min(1, cos)))*180/math.Pi)
+		}
+	}
+	sort.Float64s(angles)
+	return angles
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	modifiedOutput string
+	modifiedFormat string
+	modifiedCCD    bool
+)
+
+var modifiedResiduesCmd = &cobra.Command{
+	Use:   "modified-residues [flags] [input_file]",
+	Short: "List the non-standard polymer residues with their parent residues",
+	Long: `List the non-standard residues of the polymer chains (such as MSE, SEP or PTR) with their standard
+parent residue and one-letter code, showing how extract-seq writes them in sequences and what
+tidy --standardize-residues would change.
+
+The parent residue is taken from the MODRES records of the file or the built-in table of common
+modifications (the source column says which). With --ccd, residues whose parent is not known from
+either are looked up in the Chemical Component Dictionary through the RCSB Data API
+(https://data.rcsb.org/rest/v1/core/chemcomp/{id}); set PDBTK_RCSB_DATA_URL to use a mirror.
+The standardize column reports whether tidy --standardize-residues converts the residue.
+Only the first model is analysed. The output is a tab-separated table, or JSON with --format json.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # List the modified residues of a structure
+  pdbtk modified-residues 1a02.pdb
+
+  # Look up unknown residues in the Chemical Component Dictionary
+  pdbtk modified-residues --ccd --format json 1a02.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runModifiedResidues,
+}
+
+func init() {
+	modifiedResiduesCmd.Flags().StringVarP(&modifiedOutput, "output", "o", "", "Output file (default: stdout)")
+	modifiedResiduesCmd.Flags().StringVarP(&modifiedFormat, "format", "f", "tsv", "Output format: tsv or json")
+	modifiedResiduesCmd.Flags().BoolVar(&modifiedCCD, "ccd", false, "Look up unknown parents in the Chemical Component Dictionary")
+}
+
+// modifiedResidue is a non-standard polymer residue
+type modifiedResidue struct {
+	Chain       string `json:"chain"`
+	ResNum      string `json:"resnum"`
+	ResName     string `json:"resname"`
+	Parent      string `json:"parent"`
+	OneLetter   string `json:"one_letter_code"`
+	Source      string `json:"source"`
+	Standardize bool   `json:"standardize"`
+}
+
+func runModifiedResidues(cmd *cobra.Command, args []string) error {
+	if modifiedFormat != "tsv" && modifiedFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", modifiedFormat))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	residues, err := findModifiedResidues(cmd.Context(), file, modifiedCCD)
+	if err != nil {
+		return err
+	}
+	if len(residues) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no modified residues found"))
+	}
+
+	return writeOutput(cmd.Context(), modifiedOutput, func(w io.Writer) error {
+		if modifiedFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(residues)
+		}
+		fmt.Fprintln(w, "chain\tresnum\tresname\tparent\tone_letter_code\tsource\tstandardize")
+		for _, r := range residues {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%t\n", r.Chain, r.ResNum, r.ResName, r.Parent, r.OneLetter, r.Source, r.Standardize)
+		}
+		return nil
+	})
+}
+
+// findModifiedResidues returns the non-standard polymer residues of the first model with their parents,
+// looking up unknown parents in the Chemical Component Dictionary if ccd is set
+func findModifiedResidues(ctx context.Context, file *PDBFile, ccd bool) ([]modifiedResidue, error) {
+	modres := parseModres(file.Header)
+	lookups := make(map[string]*modifiedResidue)
+	models := file.Models()
+	residues := make([]modifiedResidue, 0)
+	for _, residue := range groupResidues(file.Atoms) {
+		key := residue[0].Residue()
+		if key.Model != models[0] || waterResidues[key.ResName] {
+			continue
+		}
+		_, builtin := modifiedResidueParents[key.ResName]
+		_, standard := oneLetterCode(key.ResName)
+		_, modified := modres[key.ResName]
+		if (standard && !builtin) || !(builtin || modified || isPolymerResidue(residue)) {
+			continue
+		}
+
+		info := modifiedResidue{
+			Chain:       string(key.ChainID),
+			ResNum:      strings.TrimSpace(fmt.Sprintf("%d%c", key.ResSeq, key.ICode)),
+			ResName:     key.ResName,
+			Source:      "unknown",
+			Standardize: builtin,
+		}
+		switch {
+		case modified:
+			info.Parent, info.Source = modres[key.ResName], "modres"
+		case builtin:
+			info.Parent, info.Source = modifiedResidueParents[key.ResName], "builtin"
+		case ccd:
+			found, ok := lookups[key.ResName]
+			if !ok {
+				var err error
+				if found, err = lookupParent(ctx, key.ResName); err != nil {
+					return nil, err
+				}
+				lookups[key.ResName] = found
+			}
+			if found != nil {
+				info.Parent, info.OneLetter, info.Source = found.Parent, found.OneLetter, "ccd"
+			}
+		}
+		if info.OneLetter == "" {
+			info.OneLetter = string(parentOneLetterCode(key.ResName, map[string]string{key.ResName: info.Parent}))
+		}
+		residues = append(residues, info)
+	}
+	return residues, nil
+}
+
+// lookupParent looks up the parent residue and one-letter code of a component in the Chemical
+// Component Dictionary, returning nil if the component is not in the dictionary or has no parent
+func lookupParent(ctx context.Context, resName string) (*modifiedResidue, error) {
+	var response rcsbChemComp
+	err := fetchJSON(ctx, rcsbDataURL()+"/rest/v1/core/chemcomp/"+url.PathEscape(resName), &response)
+	if errors.Is(err, errNotFound) {
+		return nil, warn("component %s is not in the Chemical Component Dictionary", resName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(response.ChemComp.ParentCompIDs) == 0 {
+		return nil, nil
+	}
+	found := &modifiedResidue{Parent: strings.Join(response.ChemComp.ParentCompIDs, ",")}
+	if code := response.ChemComp.OneLetterCode; len(code) == 1 && code != "?" {
+		found.OneLetter = code
+	}
+	return found, nil
+}
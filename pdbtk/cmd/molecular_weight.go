@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	molecularWeightFormat string
+	molecularWeightOutput string
+)
+
+var molecularWeightCmd = &cobra.Command{
+	Use:   "molecular-weight [flags] [input_file]",
+	Short: "Report the molecular weight and chemical formula of each chain",
+	Long: `Compute the molecular weight (Da, from standard atomic weights) and chemical formula of each chain
+and of the whole structure, for comparison with stoichiometry or SEC-MALS measurements.
+
+Each chain and the whole structure (chain "all") are reported in parts: polymer (amino acids and
+nucleotides), ligands (other het groups), waters, and all of them together. Parts without atoms are
+left out. Formulas are in Hill order (C, H, then the other elements alphabetically).
+
+Only the atoms in the coordinates are counted: most crystal structures have no hydrogens, and
+missing residues and atoms are not added, so run add-hydrogens first to include hydrogens. Only the
+first model and the first ALTLOC of atoms with alternate locations are used.
+The output is a tab-separated table (chain, part, atoms, mass, formula), or JSON with --format json.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Report the molecular weight of each chain
+  pdbtk molecular-weight 1a02.pdb
+
+  # Include hydrogens
+  pdbtk add-hydrogens 1a02.pdb | pdbtk molecular-weight --format json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runMolecularWeight,
+}
+
+func init() {
+	molecularWeightCmd.Flags().StringVarP(&molecularWeightFormat, "format", "f", "tsv", "Output format: tsv or json")
+	molecularWeightCmd.Flags().StringVarP(&molecularWeightOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// molecularWeight is the mass and formula of a part of a chain or of the whole structure
+type molecularWeight struct {
+	Chain   string  `json:"chain"`
+	Part    string  `json:"part"`
+	Atoms   int     `json:"atoms"`
+	Mass    float64 `json:"mass"`
+	Formula string  `json:"formula"`
+}
+
+// molecularParts are the parts reported for each chain, in output order
+var molecularParts = []string{"polymer", "ligands", "waters", "all"}
+
+func runMolecularWeight(cmd *cobra.Command, args []string) error {
+	if molecularWeightFormat != "tsv" && molecularWeightFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", molecularWeightFormat))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	models := file.Models()
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if atom.Model == models[0] {
+			atoms = append(atoms, atom)
+		}
+	}
+	atoms = filterAltLocRecords(atoms, "first")
+	if len(atoms) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no atoms found"))
+	}
+	masses, err := atomWeights(atoms, true)
+	if err != nil {
+		return err
+	}
+
+	// Element counts of each part of each chain, and of the whole structure under chain "all"
+	inferred := inferElements(atoms)
+	ligands := ligandResidues(atoms)
+	counts := make(map[[2]string]map[string]int)
+	totals := make(map[[2]string]float64)
+	var chainIDs []string
+	for i, atom := range atoms {
+		chain := string(atom.ChainID)
+		if _, seen := counts[[2]string{chain, "all"}]; !seen {
+			chainIDs = append(chainIDs, chain)
+		}
+		part := "polymer"
+		switch {
+		case waterResidues[atom.ResName]:
+			part = "waters"
+		case ligands[atom.Residue()]:
+			part = "ligands"
+		}
+		element := atomElement(atom, inferred[i])
+		for _, key := range [][2]string{{chain, part}, {chain, "all"}, {"all", part}, {"all", "all"}} {
+			if counts[key] == nil {
+				counts[key] = make(map[string]int)
+			}
+			counts[key][element]++
+			totals[key] += masses[i]
+		}
+	}
+
+	var results []molecularWeight
+	for _, chain := range append(chainIDs, "all") {
+		for _, part := range molecularParts {
+			key := [2]string{chain, part}
+			if counts[key] == nil {
+				continue
+			}
+			n := 0
+			for _, count := range counts[key] {
+				n += count
+			}
+			results = append(results, molecularWeight{
+				Chain:   chain,
+				Part:    part,
+				Atoms:   n,
+				Mass:    roundTo(totals[key], 2),
+				Formula: hillFormula(counts[key]),
+			})
+		}
+	}
+
+	return writeOutput(cmd.Context(), molecularWeightOutput, func(w io.Writer) error {
+		if molecularWeightFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(results)
+		}
+		fmt.Fprintln(w, "chain\tpart\tatoms\tmass\tformula")
+		for _, r := range results {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.2f\t%s\n", r.Chain, r.Part, r.Atoms, r.Mass, r.Formula)
+		}
+		return nil
+	})
+}
+
+// hillFormula writes element counts as a chemical formula in Hill order: C and H first when there is
+// carbon, then the other elements alphabetically, e.g. C5H9NO2S
+func hillFormula(counts map[string]int) string {
+	var elements []string
+	for element := range counts {
+		elements = append(elements, element)
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		rank := func(element string) int {
+			if counts["C"] > 0 {
+				switch element {
+				case "C":
+					return 0
+				case "H":
+					return 1
+				}
+			}
+			return 2
+		}
+		if ri, rj := rank(elements[i]), rank(elements[j]); ri != rj {
+			return ri < rj
+		}
+		return elements[i] < elements[j]
+	})
+	var formula strings.Builder
+	for _, element := range elements {
+		// Element symbols are upper case in PDB files; formulas use e.g. Fe and Cl
+		formula.WriteString(element[:1] + strings.ToLower(element[1:]))
+		if counts[element] > 1 {
+			formula.WriteString(strconv.Itoa(counts[element]))
+		}
+	}
+	return formula.String()
+}
@@ -0,0 +1,336 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	mutateOutput string
+	mutateChi    string
+)
+
+// Heavy atoms of the new side chain closer than these distances (in Å) to other atoms clash; pairs of
+// nitrogen and oxygen atoms may be hydrogen bonded and are allowed closer
+const (
+	clashDistance      = 3.0
+	polarClashDistance = 2.5
+)
+
+var mutateCmd = &cobra.Command{
+	Use:   "mutate RESIDUE TARGET [flags] [input_file]",
+	Short: "Replace the side chain of a residue (point mutation)",
+	Long: `Replace a residue by another standard amino acid, for quick in-silico mutagenesis: pdbtk mutate A:45 TYR
+model.pdb. RESIDUE is the chain and residue number, with an optional insertion code (A:45B), and TARGET
+is the three- or one-letter code of the new residue.
+
+The backbone (N, CA, C, O, OXT) and CB are kept, and the new side chain is built from ideal bond lengths
+and angles. Its rotamer is the most frequent one of the penultimate rotamer library that does not clash
+with the rest of the structure, with a simple backbone dependence: rotamers with chi1 near +60° are
+rare in helices and are tried last there. If every rotamer clashes, the one with the smallest overlap is
+used. Heavy atoms closer than 3.0 Å (2.5 Å between nitrogen and oxygen atoms) clash; the clashes of
+the chosen rotamer are reported as a warning. --chi sets the chi angles instead.
+
+Hydrogens of the residue other than those on N are removed (run add-hydrogens to rebuild them), as are
+LINK and SSBOND records of the residue. Every model is mutated, and alternate locations of the residue
+are reduced to the first.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Mutate residue 45 of chain A to tyrosine
+  pdbtk mutate A:45 TYR 1a02.pdb --output 1a02_A45Y.pdb
+
+  # Mutate to alanine (one-letter code)
+  pdbtk mutate B:102 A 1a02.pdb
+
+  # Choose the chi angles of the new side chain
+  pdbtk mutate A:45 LEU --chi -65,175 1a02.pdb`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: runMutate,
+}
+
+func init() {
+	mutateCmd.Flags().StringVarP(&mutateOutput, "output", "o", "", "Output file (default: stdout)")
+	mutateCmd.Flags().StringVar(&mutateChi, "chi", "", "Chi angles of the new side chain in degrees (comma-separated) instead of choosing a rotamer")
+}
+
+func runMutate(cmd *cobra.Command, args []string) error {
+	target, err := parseMutationTarget(args[1])
+	if err != nil {
+		return err
+	}
+	chainID, position, err := parseResidueSpec(args[0])
+	if err != nil {
+		return err
+	}
+	var chi []float64
+	if mutateChi != "" {
+		for _, part := range strings.Split(mutateChi, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --chi: %q is not a number", part))
+			}
+			chi = append(chi, v)
+		}
+		if len(chi) != chiCount(target) {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --chi: %s has %d chi angles, got %d", target, chiCount(target), len(chi)))
+		}
+	}
+
+	var inputFile string
+	if len(args) == 3 {
+		inputFile = args[2]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	var atoms []*AtomRecord
+	var from string
+	found := false
+	for _, residue := range groupResidues(file.Atoms) {
+		key := residue[0].Residue()
+		if key.ChainID != chainID || key.ResSeq != position.ResSeq || key.ICode != position.ICode {
+			atoms = append(atoms, residue...)
+			continue
+		}
+		found, from = true, key.ResName
+		mutated, choice, clashes, err := mutateResidue(residue, target, chi, file.Atoms)
+		if err != nil {
+			return err
+		}
+		atoms = append(atoms, mutated...)
+		fmt.Fprintf(cmd.ErrOrStderr(), "Mutated %s %s to %s%s\n", residueLabel(key), from, target, choice)
+		if len(clashes) > 0 {
+			if err := warn("%s %s clashes: %s", residueLabel(key), target, strings.Join(clashes, ", ")); err != nil {
+				return err
+			}
+		}
+	}
+	if !found {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("residue %s not found", args[0]))
+	}
+
+	header := dropResidueLinks(file.Header, func(c byte, p residuePosition, name string) bool {
+		return c == chainID && p == position && !keptAtom(name, target)
+	})
+	return writeOutput(cmd.Context(), mutateOutput, func(w io.Writer) error {
+		return writePDBRecords(&PDBFile{Header: header, Atoms: atoms, Conect: file.Conect}, w, recordCommandLine(cmd, args[:2], inputFile))
+	})
+}
+
+// keptAtom reports whether an atom of a residue is kept when its side chain is replaced by that of
+// resName: the backbone, and CB unless resName is glycine
+func keptAtom(name, resName string) bool {
+	switch name {
+	case "N", "CA", "C", "O", "OXT":
+		return true
+	case "CB":
+		return resName != "GLY"
+	}
+	return false
+}
+
+// parseMutationTarget returns the residue name of a three- or one-letter amino acid code
+func parseMutationTarget(s string) (string, error) {
+	target := strings.ToUpper(s)
+	if len(target) == 1 {
+		target = singleLetterToResidue(target)
+	}
+	if _, ok := sidechainAtoms[target]; !ok {
+		return "", withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid target residue: %s (must be a standard amino acid)", s))
+	}
+	return target, nil
+}
+
+// parseResidueSpec parses a residue given as chain and residue number, e.g. A:45 or A:45B
+func parseResidueSpec(spec string) (byte, residuePosition, error) {
+	chainPart, numberPart, ok := strings.Cut(spec, ":")
+	if !ok || len(chainPart) != 1 {
+		return 0, residuePosition{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid residue: %s (expected chain:number, e.g. A:45)", spec))
+	}
+	r, err := parseResidueRange(numberPart)
+	if err != nil || r.Start != r.End {
+		return 0, residuePosition{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid residue: %s (expected chain:number, e.g. A:45)", spec))
+	}
+	return chainPart[0], r.Start, nil
+}
+
+// mutateResidue returns the atoms of a residue mutated to target, the rotamer that was chosen (for
+// reporting) and the clashes of the new side chain with all other atoms of the same model
+func mutateResidue(residue []*AtomRecord, target string, chi []float64, all []*AtomRecord) ([]*AtomRecord, string, []string, error) {
+	residue = filterAltLocRecords(residue, "first")
+	key := residue[0].Residue()
+	var backbone, hydrogens, oxt []*AtomRecord
+	positions := make(map[string]vec3)
+	var ca *AtomRecord
+	for _, atom := range residue {
+		copied := *atom
+		copied.ResName, copied.Het, copied.AltLoc = target, false, ' '
+		switch {
+		case keptAtom(atom.Name, target) && atom.Name != "OXT":
+			backbone = append(backbone, &copied)
+			positions[atom.Name] = atom.Coord()
+			if atom.Name == "CA" {
+				ca = &copied
+			}
+		case atom.Name == "OXT":
+			oxt = append(oxt, &copied)
+		case (atom.Name == "H" && target != "PRO") || atom.Name == "H1" || atom.Name == "H2" || atom.Name == "H3":
+			hydrogens = append(hydrogens, &copied)
+		}
+	}
+	for _, name := range []string{"N", "CA", "C"} {
+		if _, ok := positions[name]; !ok {
+			return nil, "", nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("residue %s has no %s atom", residueLabel(key), name))
+		}
+	}
+
+	choice := ""
+	if chi == nil && chiCount(target) > 0 {
+		var name string
+		chi, name = chooseRotamer(target, key, positions, nil, all)
+		choice = fmt.Sprintf(" (rotamer %s)", name)
+	}
+	names, placed := buildSidechain(target, positions, chi)
+	clashes, _ := sidechainClashes(names, placed, positions, clashCandidates(key, positions, all))
+
+	atoms := backbone
+	for i, name := range names {
+		if name == "CB" && positions["CB"] == placed[i] {
+			continue
+		}
+		atom := *ca
+		atom.Serial, atom.Name, atom.Element, atom.Charge, atom.Occupancy = 0, name, name[:1], "", 1
+		atom.SetCoord(placed[i])
+		atoms = append(atoms, &atom)
+	}
+	atoms = append(atoms, oxt...)
+	return append(atoms, hydrogens...), choice, clashes, nil
+}
+
+// clashCandidates returns the atoms that can clash with new side chain atoms of the residue key, whose
+// backbone atoms are in positions: the heavy atoms of the same model, except for the residue itself and
+// the backbone atoms of its neighbours that are within three bonds of it
+func clashCandidates(key ResidueKey, positions map[string]vec3, all []*AtomRecord) []*AtomRecord {
+	var others []*AtomRecord
+	for _, atom := range all {
+		if atom.Model != key.Model || atom.Residue() == key || atom.Element == "H" || atom.Element == "D" {
+			continue
+		}
+		if atom.ChainID == key.ChainID && ((atom.Name == "C" || atom.Name == "CA") && distance(atom.Coord(), positions["N"]) <= 2.5 ||
+			(atom.Name == "N" || atom.Name == "CA") && distance(atom.Coord(), positions["C"]) <= 2.5) {
+			continue
+		}
+		others = append(others, atom)
+	}
+	return others
+}
+
+// sidechainClashes returns the clashes with others of the placed side chain atoms that are not in
+// existing, and their total overlap
+func sidechainClashes(names []string, placed []vec3, existing map[string]vec3, others []*AtomRecord) ([]string, float64) {
+	var clashes []string
+	overlap := 0.0
+	for i, p := range placed {
+		if _, ok := existing[names[i]]; ok {
+			continue
+		}
+		for _, other := range others {
+			cutoff := clashDistance
+			if strings.ContainsAny(names[i][:1], "NO") && (other.Element == "N" || other.Element == "O") {
+				cutoff = polarClashDistance
+			}
+			if d := distance(p, other.Coord()); d < cutoff {
+				clashes = append(clashes, fmt.Sprintf("%s with %s %s %s (%.2f Å)", names[i], residueLabel(other.Residue()), other.ResName, other.Name, d))
+				overlap += cutoff - d
+			}
+		}
+	}
+	return clashes, overlap
+}
+
+// chooseRotamer returns the chi angles and name of the first rotamer of resName, in backboneRotamers
+// order, whose new side chain atoms do not clash with the rest of the structure, or of the one with the
+// smallest overlap. Chi angles in measured that are not NaN replace those of the rotamers.
+func chooseRotamer(resName string, key ResidueKey, positions map[string]vec3, measured []float64, all []*AtomRecord) ([]float64, string) {
+	others := clashCandidates(key, positions, all)
+	var chi []float64
+	choice := ""
+	bestOverlap := math.Inf(1)
+	for _, r := range backboneRotamers(resName, key, all) {
+		candidate := append([]float64(nil), r.chi...)
+		for i, v := range measured {
+			if !math.IsNaN(v) {
+				candidate[i] = v
+			}
+		}
+		names, placed := buildSidechain(resName, positions, candidate)
+		_, overlap := sidechainClashes(names, placed, positions, others)
+		if overlap < bestOverlap {
+			chi, choice, bestOverlap = candidate, r.name, overlap
+		}
+		if overlap == 0 {
+			break
+		}
+	}
+	return chi, choice
+}
+
+// backboneRotamers returns the rotamers of resName in the order they are tried for a residue: by
+// frequency, with those with chi1 near +60° last when the residue is in a helix (phi and psi in the
+// alpha region), where they clash with the preceding turn
+func backboneRotamers(resName string, key ResidueKey, all []*AtomRecord) []rotamer {
+	candidates := append([]rotamer(nil), rotamers[resName]...)
+	var chain []*AtomRecord
+	for _, atom := range all {
+		if atom.Model == key.Model && atom.ChainID == key.ChainID {
+			chain = append(chain, atom)
+		}
+	}
+	resNum := strings.TrimPrefix(residueLabel(key), string(key.ChainID)+":")
+	helical := false
+	for _, t := range backboneTorsions(filterAltLocRecords(chain, "first")) {
+		if t.ResNum == resNum && t.Phi != nil && t.Psi != nil {
+			helical = *t.Phi > -160 && *t.Phi < -20 && *t.Psi > -120 && *t.Psi < 50
+		}
+	}
+	if helical && resName != "SER" && resName != "THR" {
+		plus := func(r rotamer) bool { return r.chi[0] > 0 && r.chi[0] < 120 }
+		sort.SliceStable(candidates, func(i, j int) bool { return !plus(candidates[i]) && plus(candidates[j]) })
+	}
+	return candidates
+}
+
+// dropResidueLinks removes the LINK and SSBOND records with an atom for which removed returns true
+func dropResidueLinks(header []string, removed func(chainID byte, position residuePosition, name string) bool) []string {
+	mentions := func(line string, name string, chain, resSeq, iCode int) bool {
+		if len(line) <= iCode {
+			return false
+		}
+		return removed(line[chain], recordPosition(line[resSeq:resSeq+4], line[iCode]), strings.TrimSpace(name))
+	}
+	var kept []string
+	for _, line := range header {
+		switch {
+		case strings.HasPrefix(line, "LINK  ") && len(line) > 56 &&
+			(mentions(line, line[12:16], 21, 22, 26) || mentions(line, line[42:46], 51, 52, 56)):
+		case strings.HasPrefix(line, "SSBOND") && (mentions(line, "SG", 15, 17, 21) || mentions(line, "SG", 29, 31, 35)):
+		default:
+			kept = append(kept, line)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,425 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Object store URIs (s3://bucket/key and gs://bucket/object) can be used wherever pdbtk reads an input
+// file or writes an output file or directory. Requests are authenticated with the credentials of the
+// environment the way the AWS and Google Cloud tools find them, so no local staging is needed in cloud
+// batch pipelines.
+const (
+	// awsEndpointURLEnv overrides the S3 endpoint, e.g. for MinIO or another S3-compatible store.
+	// Buckets are then addressed path-style ({endpoint}/{bucket}/{key}).
+	awsEndpointURLEnv = "AWS_ENDPOINT_URL"
+	// gcsEmulatorHostEnv overrides the Google Cloud Storage endpoint, as for the Google Cloud client libraries
+	gcsEmulatorHostEnv = "STORAGE_EMULATOR_HOST"
+)
+
+// objectURI is a parsed s3:// or gs:// URI
+type objectURI struct {
+	Scheme string
+	Bucket string
+	Key    string
+}
+
+// isObjectURI reports whether name is an s3:// or gs:// URI rather than a local path
+func isObjectURI(name string) bool {
+	return strings.HasPrefix(name, "s3://") || strings.HasPrefix(name, "gs://")
+}
+
+// parseObjectURI splits an s3:// or gs:// URI into its bucket and key
+func parseObjectURI(name string) (objectURI, error) {
+	scheme, rest, _ := strings.Cut(name, "://")
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return objectURI{}, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid object URI (expected %s://bucket/key): %s", scheme, name))
+	}
+	return objectURI{Scheme: scheme, Bucket: bucket, Key: key}, nil
+}
+
+// String returns the URI in its s3:// or gs:// form
+func (o objectURI) String() string {
+	return o.Scheme + "://" + o.Bucket + "/" + o.Key
+}
+
+// joinOutputPath returns the path of the file name in the output directory dir, which may be an object URI
+func joinOutputPath(dir, name string) string {
+	if isObjectURI(dir) {
+		return strings.TrimSuffix(dir, "/") + "/" + path.Clean(name)
+	}
+	return filepath.Join(dir, name)
+}
+
+// makeOutputDir creates the output directory dir. Object stores have no directories, so nothing
+// is created for an object URI.
+func makeOutputDir(dir string) error {
+	if isObjectURI(dir) {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return withCode(ErrCodeIO, fmt.Errorf("failed to create output directory: %v", err))
+	}
+	return nil
+}
+
+// statObject returns errNotFound if the object named by uri does not exist
+func statObject(ctx context.Context, uri string) error {
+	o, err := parseObjectURI(uri)
+	if err != nil {
+		return err
+	}
+	req, err := o.request(ctx, http.MethodHead, nil)
+	if err != nil {
+		return err
+	}
+	body, err := doObjectRequest(req, o)
+	if err != nil {
+		return err
+	}
+	body.Close()
+	return nil
+}
+
+// readObject downloads the object named by uri
+func readObject(ctx context.Context, uri string) ([]byte, error) {
+	o, err := parseObjectURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	req, err := o.request(ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := doObjectRequest(req, o)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(body)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, contextError(ctx.Err())
+		}
+		return nil, withCode(ErrCodeNetwork, fmt.Errorf("failed to download %s: %v", uri, err))
+	}
+	return content, nil
+}
+
+// writeObject uploads content as the object named by uri, replacing any existing object.
+// Uploads are atomic: the object only becomes visible once it has been written completely.
+func writeObject(ctx context.Context, uri string, content []byte) error {
+	o, err := parseObjectURI(uri)
+	if err != nil {
+		return err
+	}
+	req, err := o.request(ctx, http.MethodPut, content)
+	if err != nil {
+		return err
+	}
+	body, err := doObjectRequest(req, o)
+	if err != nil {
+		return err
+	}
+	body.Close()
+	return nil
+}
+
+// doObjectRequest sends an object store request, reporting a missing object as errNotFound
+func doObjectRequest(req *http.Request, o objectURI) (io.ReadCloser, error) {
+	body, err := doRequest(req)
+	if errors.Is(err, errNotFound) {
+		return nil, fmt.Errorf("%w: %s", errNotFound, o)
+	}
+	return body, err
+}
+
+// request builds an authenticated request for the object. content is the body of an upload.
+func (o objectURI) request(ctx context.Context, method string, content []byte) (*http.Request, error) {
+	if o.Scheme == "gs" {
+		return o.gcsRequest(ctx, method, content)
+	}
+	return o.s3Request(ctx, method, content)
+}
+
+// s3Request builds an S3 request signed with AWS Signature Version 4
+func (o objectURI) s3Request(ctx context.Context, method string, content []byte) (*http.Request, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	u := &url.URL{Scheme: "https", Host: o.Bucket + ".s3." + region + ".amazonaws.com", Path: "/" + o.Key}
+	if endpoint := os.Getenv(awsEndpointURLEnv); endpoint != "" {
+		base, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+		if err != nil || base.Host == "" {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid %s: %s", awsEndpointURLEnv, endpoint))
+		}
+		u = &url.URL{Scheme: base.Scheme, Host: base.Host, Path: base.Path + "/" + o.Bucket + "/" + o.Key}
+	}
+	u.RawPath = awsURIEncode(u.Path)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, withCode(ErrCodeNetwork, fmt.Errorf("invalid request: %v", err))
+	}
+	creds, err := awsCredentials()
+	if err != nil {
+		return nil, err
+	}
+	if creds.AccessKeyID != "" {
+		signAWSRequest(req, content, creds, region, time.Now().UTC())
+	}
+	return req, nil
+}
+
+// gcsRequest builds a Google Cloud Storage JSON API request with an OAuth 2.0 access token
+func (o objectURI) gcsRequest(ctx context.Context, method string, content []byte) (*http.Request, error) {
+	base := "https://storage.googleapis.com"
+	if host := os.Getenv(gcsEmulatorHostEnv); host != "" {
+		base = strings.TrimSuffix(host, "/")
+		if !strings.Contains(base, "://") {
+			base = "http://" + base
+		}
+	}
+
+	var target string
+	switch method {
+	case http.MethodPut:
+		// Simple uploads are POSTed to the upload endpoint
+		method = http.MethodPost
+		target = fmt.Sprintf("%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s", base, url.PathEscape(o.Bucket), url.QueryEscape(o.Key))
+	case http.MethodHead:
+		// Object metadata
+		method = http.MethodGet
+		target = fmt.Sprintf("%s/storage/v1/b/%s/o/%s", base, url.PathEscape(o.Bucket), url.PathEscape(o.Key))
+	default:
+		target = fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media", base, url.PathEscape(o.Bucket), url.PathEscape(o.Key))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(content))
+	if err != nil {
+		return nil, withCode(ErrCodeNetwork, fmt.Errorf("invalid request: %v", err))
+	}
+	if content != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	token, err := gcsAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// awsCredentialSet is a set of AWS credentials. An empty access key means anonymous access.
+type awsCredentialSet struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"Token"`
+}
+
+// credentialsTimeout limits a lookup of credentials: a credential_process, or the requests to a
+// credentials or token endpoint
+const credentialsTimeout = 10 * time.Second
+
+var (
+	awsCredentialsOnce   sync.Once
+	awsCredentialsCached awsCredentialSet
+	awsCredentialsErr    error
+)
+
+// awsCredentials finds the AWS credentials of the environment, in the order of the AWS CLI: the
+// AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY/AWS_SESSION_TOKEN environment variables; the AWS_PROFILE
+// (or default) profile of the shared credentials file, then of the config file, with access keys, a
+// credential_process or an IAM Identity Center (SSO) login; the container credentials of ECS, AWS Batch
+// and EKS; then the instance profile of an EC2 instance. Without credentials, requests are sent
+// unsigned, which works for public buckets.
+func awsCredentials() (awsCredentialSet, error) {
+	awsCredentialsOnce.Do(func() {
+		awsCredentialsCached, awsCredentialsErr = findAWSCredentials()
+	})
+	return awsCredentialsCached, awsCredentialsErr
+}
+
+func findAWSCredentials() (awsCredentialSet, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return awsCredentialSet{
+			AccessKeyID:     id,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+
+	credentialsFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credentialsFile == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			credentialsFile = filepath.Join(home, ".aws", "credentials")
+		}
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	if creds, ok := readAWSCredentialsFile(credentialsFile, profile); ok {
+		return creds, nil
+	}
+
+	// Credentials are looked up once for the process, not for the command that first needs them, so
+	// the lookup has a limit of its own
+	ctx, cancel := context.WithTimeout(context.Background(), credentialsTimeout)
+	defer cancel()
+	if creds, ok, err := awsConfigProfileCredentials(ctx, profile); ok {
+		return creds, err
+	}
+	if creds, ok, err := awsContainerCredentials(ctx); ok {
+		return creds, err
+	}
+	return awsInstanceCredentials()
+}
+
+// readAWSCredentialsFile reads the credentials of a profile from an AWS shared credentials file
+func readAWSCredentialsFile(filename, profile string) (awsCredentialSet, bool) {
+	section := readINIFile(filename)[profile]
+	creds := awsCredentialSet{
+		AccessKeyID:     section["aws_access_key_id"],
+		SecretAccessKey: section["aws_secret_access_key"],
+		SessionToken:    section["aws_session_token"],
+	}
+	return creds, creds.AccessKeyID != ""
+}
+
+// signAWSRequest adds the AWS Signature Version 4 headers for an S3 request with the given payload
+func signAWSRequest(req *http.Request, payload []byte, creds awsCredentialSet, region string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	var names []string
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(req.Header.Get(name)))
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+	req.Header.Del("Host")
+}
+
+// awsURIEncode percent-encodes a path the way AWS Signature Version 4 expects: every byte except the
+// unreserved characters and "/"
+func awsURIEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+var (
+	gcsTokenOnce   sync.Once
+	gcsTokenCached string
+	gcsTokenErr    error
+)
+
+// gcsAccessToken finds an OAuth 2.0 access token for Google Cloud Storage, in the order of the Google
+// Cloud client libraries: the GOOGLE_OAUTH_ACCESS_TOKEN environment variable (e.g. from "gcloud auth
+// print-access-token"); the service account key or user credentials of the GOOGLE_APPLICATION_CREDENTIALS
+// file, or else of the application default credentials of gcloud; then the token of the service account
+// of the Compute Engine, Cloud Run or Cloud Batch instance. Without a token, requests are sent
+// unauthenticated, which works for public buckets.
+func gcsAccessToken() (string, error) {
+	gcsTokenOnce.Do(func() {
+		if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+			gcsTokenCached = token
+			return
+		}
+		if filename := googleCredentialsFilename(); filename != "" {
+			ctx, cancel := context.WithTimeout(context.Background(), credentialsTimeout)
+			defer cancel()
+			gcsTokenCached, gcsTokenErr = googleFileAccessToken(ctx, filename)
+			return
+		}
+		if os.Getenv(gcsEmulatorHostEnv) != "" {
+			return
+		}
+		token, status, err := metadataRequest(http.MethodGet, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", "Metadata-Flavor", "Google")
+		if err != nil || status != http.StatusOK {
+			return
+		}
+		var result struct {
+			AccessToken string `json:"access_token"`
+		}
+		if json.Unmarshal([]byte(token), &result) == nil {
+			gcsTokenCached = result.AccessToken
+		}
+	})
+	return gcsTokenCached, gcsTokenErr
+}
@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	packCellOutput    string
+	packCellSupercell string
+	packCellBatch     batchOptions
+)
+
+var packCellCmd = &cobra.Command{
+	Use:   "pack-cell [flags] [input_file...]",
+	Short: "Generate the symmetry copies of a crystal structure in its unit cell",
+	Long: `Generate the crystal packing of a structure: a copy of the asymmetric unit for each operator of the
+space group of its CRYST1 record, placed in the unit cell, for viewing crystal contacts and for contact
+analysis with the other commands.
+
+Each copy is moved by whole cell translations so that its centre lies inside the unit cell (fractional
+coordinates from 0 to 1). --supercell na,nb,nc repeats the packed cell along a, b and c, e.g. 3,3,3 to
+surround the central cell with its neighbours. The 65 space groups possible for chiral molecules are
+supported (see symmetry-ops).
+
+The copy made by the identity operator in the first cell keeps the chain IDs of the input, and the
+chains of the other copies are given unused chain IDs. Every atom is given the number of its copy as
+its segment ID (columns 73-76), which also tells the copies apart when there are more chains than
+chain IDs (a warning). The copies are listed on stderr. The CRYST1 record of the output describes
+the packed cell (or supercell) in space group P 1, and the REMARK 290 symmetry operators are removed.
+Only the first model of the input is packed.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Pack the unit cell of a crystal structure
+  pdbtk pack-cell 1a02.pdb --output 1a02_cell.pdb
+
+  # Pack a 3x3x3 supercell for contact analysis
+  pdbtk pack-cell --supercell 3,3,3 1a02.pdb --output 1a02_supercell.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runPackCell,
+}
+
+func init() {
+	packCellCmd.Flags().StringVarP(&packCellOutput, "output", "o", "", "Output file (default: stdout)")
+	packCellCmd.Flags().StringVar(&packCellSupercell, "supercell", "1,1,1", "Number of unit cells along a, b and c")
+	addBatchFlags(packCellCmd, &packCellBatch, "{stem}_cell.pdb")
+}
+
+func runPackCell(cmd *cobra.Command, args []string) error {
+	cells, err := parseSupercell(packCellSupercell)
+	if err != nil {
+		return err
+	}
+
+	return runBatch(cmd.Context(), args, packCellOutput, packCellBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		packed, copies, err := packCell(file, cells)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Packed %d copies\n", len(copies))
+		for _, c := range copies {
+			fmt.Fprintf(cmd.ErrOrStderr(), "  %s\n", c)
+		}
+		return writePDBRecords(packed, writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// parseSupercell parses a --supercell value: the number of cells along a, b and c
+func parseSupercell(spec string) ([3]int, error) {
+	var cells [3]int
+	parts := strings.Split(spec, ",")
+	if len(parts) != 3 {
+		return cells, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --supercell: %q (must be na,nb,nc)", spec))
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 {
+			return cells, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --supercell: %q (must be positive whole numbers)", spec))
+		}
+		cells[i] = n
+	}
+	return cells, nil
+}
+
+// packCell builds the copies of the first model of file for each space group operator and each cell
+// of the supercell. It also returns a description of each copy, e.g.
+// "2: operator 2 (-x+1/2,-y,z+1/2), cell 0,0,0, chains C,D".
+func packCell(file *PDBFile, cells [3]int) (*PDBFile, []string, error) {
+	cell, err := headerCell(file.Header)
+	if err != nil {
+		return nil, nil, withCode(ErrCodeParse, err)
+	}
+	if cell == nil {
+		return nil, nil, withCode(ErrCodeNoMatch, fmt.Errorf("no unit cell (CRYST1) found"))
+	}
+	if !cell.valid() {
+		return nil, nil, withCode(ErrCodeParse, fmt.Errorf("invalid unit cell: %s", cell.cryst1()))
+	}
+	group, ok := lookupSpaceGroup(cell.SpaceGroup)
+	if !ok {
+		return nil, nil, withCode(ErrCodeUnsupportedFormat, fmt.Errorf("unsupported space group: %s", cell.SpaceGroup))
+	}
+	if len(file.Atoms) == 0 {
+		return nil, nil, withCode(ErrCodeNoMatch, fmt.Errorf("no atoms found"))
+	}
+
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if atom.Model == file.Atoms[0].Model {
+			atoms = append(atoms, atom)
+		}
+	}
+	var chains []byte
+	for _, atom := range atoms {
+		if !containsByte(chains, atom.ChainID) {
+			chains = append(chains, atom.ChainID)
+		}
+	}
+	operators := group.operators()
+	copies := len(operators) * cells[0] * cells[1] * cells[2]
+	if copies > 9999 {
+		return nil, nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("%d copies are more than fit in the segment ID (9999)", copies))
+	}
+
+	// Chain IDs are only distinct when there are enough of them
+	newChainIDs := copies*len(chains) <= len(chainIDAlphabet)
+	if !newChainIDs {
+		if err := warn("%d chains are more than there are chain IDs (%d): the copies keep the chain IDs of the input and are told apart by segment ID",
+			copies*len(chains), len(chainIDAlphabet)); err != nil {
+			return nil, nil, err
+		}
+	}
+	used := make(map[byte]bool)
+	for _, chain := range chains {
+		used[chain] = true
+	}
+	next := 0
+
+	var center vec3
+	for _, atom := range atoms {
+		center = center.add(atom.Coord())
+	}
+	center = center.scale(1 / float64(len(atoms)))
+	toFractional := symmetryOperator{Rotation: cell.fractionalization()}
+
+	packed := &PDBFile{Header: packedHeader(file.Header, cell, cells), Conect: file.Conect}
+	var descriptions []string
+	n := 0
+	for i := 0; i < cells[0]; i++ {
+		for j := 0; j < cells[1]; j++ {
+			for k := 0; k < cells[2]; k++ {
+				for o, op := range operators {
+					n++
+					// Move the copy by whole cells so that its centre is in the cell, then into cell i,j,k
+					shift := op
+					f := op.apply(toFractional.apply(center))
+					for axis, offset := range [3]int{i, j, k} {
+						shift.Translation[axis] += float64(offset) - math.Floor(f[axis]+1e-6)
+					}
+					cartesian := cell.cartesian(shift)
+
+					chainIDs := make(map[byte]byte)
+					var names []string
+					for _, chain := range chains {
+						chainID := chain
+						if newChainIDs && n > 1 {
+							for used[chainIDAlphabet[next]] {
+								next++
+							}
+							chainID = chainIDAlphabet[next]
+							used[chainID] = true
+						}
+						chainIDs[chain] = chainID
+						names = append(names, string(chainID))
+					}
+					for _, atom := range atoms {
+						copied := atom.Copy()
+						copied.SetCoord(cartesian.apply(atom.Coord()))
+						copied.ChainID = chainIDs[atom.ChainID]
+						copied.SegID = strconv.Itoa(n)
+						packed.Atoms = append(packed.Atoms, copied)
+					}
+					descriptions = append(descriptions, fmt.Sprintf("%d: operator %d (%s), cell %d,%d,%d, chains %s",
+						n, o+1, formatXYZOperator(op), i, j, k, strings.Join(names, ",")))
+				}
+			}
+		}
+	}
+	return packed, descriptions, nil
+}
+
+// packedHeader returns the header of a packed cell: the crystal records describe the packed cell or
+// supercell in P 1, and the REMARK 290 symmetry operators of the space group are removed
+func packedHeader(header []string, cell *unitCell, cells [3]int) []string {
+	var rest []string
+	for _, line := range header {
+		if n, ok := remarkClass(line); (ok && n == 290) || isCrystalRecord(line) {
+			continue
+		}
+		rest = append(rest, line)
+	}
+	packed := *cell
+	packed.A *= float64(cells[0])
+	packed.B *= float64(cells[1])
+	packed.C *= float64(cells[2])
+	packed.SpaceGroup = "P 1"
+	packed.Z = cell.Z * cells[0] * cells[1] * cells[2]
+	return insertHeaderRecords(rest, packed.crystalHeader(), "MTRIX")
+}
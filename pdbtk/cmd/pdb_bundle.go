@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entries too large for the PDB format (more than 62 chains or 99999 atoms) are distributed as
+// {id}-pdb-bundle.tar.gz archives of PDB files ({id}-pdb-bundle1.pdb, {id}-pdb-bundle2.pdb, ...) that
+// reuse single-character chain IDs, with a {id}-chain-id-mapping.txt file giving the original chain ID
+// of every chain of every file.
+
+// chainIDAlphabet are the chain IDs assigned to the chains of a reassembled bundle
+const chainIDAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// bundleFilePattern matches the PDB files of a bundle, capturing their number
+var bundleFilePattern = regexp.MustCompile(`-pdb-bundle(\d+)\.pdb$`)
+
+// isPDBBundle reports whether name is a PDB bundle archive
+func isPDBBundle(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, "-pdb-bundle.tar.gz") || strings.HasSuffix(name, "-pdb-bundle.tar")
+}
+
+// bundleFile is a PDB file of a bundle
+type bundleFile struct {
+	name   string
+	number int
+	file   *PDBFile
+}
+
+// readPDBBundle reassembles the PDB files of a bundle archive into a single PDB file. Every chain
+// gets a unique chain ID, its original one when that is a single character, and keeps its original
+// chain ID in the segment ID column. When there are more chains than chain IDs, chains keep the
+// chain IDs of their bundle file.
+func readPDBBundle(content []byte) ([]byte, error) {
+	var reader io.Reader = bytes.NewReader(content)
+	if len(content) > 2 && content[0] == 0x1f && content[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, withCode(ErrCodeParse, fmt.Errorf("failed to read PDB bundle: %v", err))
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	var files []bundleFile
+	var mapping map[string]map[byte]string
+	archive := tar.NewReader(reader)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, withCode(ErrCodeParse, fmt.Errorf("failed to read PDB bundle: %v", err))
+		}
+		name := path.Base(header.Name)
+		switch {
+		case strings.HasSuffix(name, "-chain-id-mapping.txt"):
+			if mapping, err = parseChainIDMapping(archive); err != nil {
+				return nil, withCode(ErrCodeParse, fmt.Errorf("failed to read %s: %v", name, err))
+			}
+		case bundleFilePattern.MatchString(name):
+			number, _ := strconv.Atoi(bundleFilePattern.FindStringSubmatch(name)[1])
+			file, err := ParsePDBRecords(archive)
+			if err != nil {
+				return nil, withCode(ErrCodeParse, fmt.Errorf("failed to read %s: %v", name, err))
+			}
+			files = append(files, bundleFile{name: name, number: number, file: file})
+		}
+	}
+	if len(files) == 0 {
+		return nil, withCode(ErrCodeParse, fmt.Errorf("no PDB files found in PDB bundle"))
+	}
+	if mapping == nil {
+		return nil, withCode(ErrCodeParse, fmt.Errorf("no chain ID mapping file found in PDB bundle"))
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].number < files[j].number })
+
+	// Original chain IDs of the chains of every file, in file order
+	type bundleChain struct {
+		file    string
+		chainID byte
+	}
+	originals := make(map[bundleChain]string)
+	var order []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		for _, atom := range f.file.Atoms {
+			chain := bundleChain{f.name, atom.ChainID}
+			if _, ok := originals[chain]; ok {
+				continue
+			}
+			original, ok := mapping[f.name][atom.ChainID]
+			if !ok {
+				return nil, withCode(ErrCodeParse, fmt.Errorf("chain %c of %s is missing from the chain ID mapping", atom.ChainID, f.name))
+			}
+			originals[chain] = original
+			if !seen[original] {
+				seen[original] = true
+				order = append(order, original)
+			}
+		}
+	}
+	newIDs := assignBundleChainIDs(order)
+	if newIDs == nil {
+		if err := warn("PDB bundle has %d chains, more than there are chain IDs: chains keep the chain IDs of their bundle file, "+
+			"the original chain IDs are in the segment ID column", len(order)); err != nil {
+			return nil, err
+		}
+	}
+
+	merged := &PDBFile{Header: files[0].file.Header}
+	offset := 0
+	for _, f := range files {
+		serialMap := make(map[int]int)
+		maxSerial := 0
+		for _, atom := range f.file.Atoms {
+			original := originals[bundleChain{f.name, atom.ChainID}]
+			atom.SegID = original
+			if newIDs != nil {
+				atom.ChainID = newIDs[original]
+			}
+			if atom.Serial > maxSerial {
+				maxSerial = atom.Serial
+			}
+			serialMap[atom.Serial] = atom.Serial + offset
+			atom.Serial += offset
+			merged.Atoms = append(merged.Atoms, atom)
+		}
+		merged.Conect = append(merged.Conect, remapConect(f.file.Conect, serialMap)...)
+		offset += maxSerial
+	}
+
+	var buf bytes.Buffer
+	for _, line := range merged.Header {
+		fmt.Fprintln(&buf, line)
+	}
+	writeCoordinateRecords(&buf, merged)
+	return buf.Bytes(), nil
+}
+
+// assignBundleChainIDs assigns a single-character chain ID to each original chain ID, keeping original
+// IDs that are a single character. It returns nil if there are more chains than chain IDs.
+func assignBundleChainIDs(originals []string) map[string]byte {
+	if len(originals) > len(chainIDAlphabet) {
+		return nil
+	}
+	ids := make(map[string]byte)
+	used := make(map[byte]bool)
+	for _, original := range originals {
+		if len(original) == 1 && strings.IndexByte(chainIDAlphabet, original[0]) >= 0 {
+			ids[original] = original[0]
+			used[original[0]] = true
+		}
+	}
+	next := 0
+	for _, original := range originals {
+		if _, ok := ids[original]; ok {
+			continue
+		}
+		for used[chainIDAlphabet[next]] {
+			next++
+		}
+		ids[original] = chainIDAlphabet[next]
+		used[chainIDAlphabet[next]] = true
+	}
+	return ids
+}
+
+// parseChainIDMapping parses a bundle chain ID mapping file, which lists the chain IDs of each PDB file
+// of the bundle and their original chain IDs:
+//
+//	1vy4-pdb-bundle1.pdb:
+//	           A          AA
+//	           B          AB
+func parseChainIDMapping(reader io.Reader) (map[string]map[byte]string, error) {
+	mapping := make(map[string]map[byte]string)
+	var current string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, ".pdb:") {
+			current = strings.TrimSuffix(line, ":")
+			mapping[current] = make(map[byte]string)
+			continue
+		}
+		fields := strings.Fields(line)
+		if current == "" || len(fields) != 2 || len(fields[0]) != 1 {
+			continue
+		}
+		mapping[current][fields[0][0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(mapping) == 0 {
+		return nil, fmt.Errorf("no PDB files listed")
+	}
+	return mapping, nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import "strings"
+
+// entityInfo describes a molecule (entity) of a PDB entry, from its COMPND and SOURCE records
+type entityInfo struct {
+	ID       string
+	Molecule string
+	Organism string
+	TaxID    string
+	Chains   []byte
+}
+
+// specificationList joins the continuation lines of a COMPND or SOURCE record and splits the
+// specification list into "KEY: value" tokens
+func specificationList(header []string, record string) []string {
+	var text strings.Builder
+	for _, line := range header {
+		if strings.HasPrefix(line, record) {
+			text.WriteString(strings.TrimRight(safeColumns(line, 10, 80), " "))
+			text.WriteString(" ")
+		}
+	}
+	var tokens []string
+	for _, token := range strings.Split(text.String(), ";") {
+		if token = strings.TrimSpace(token); token != "" {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+// parseEntities reads the entities of a PDB entry from the COMPND and SOURCE records of its header.
+// It returns the entities in order and a map from chain ID to entity.
+func parseEntities(header []string) ([]*entityInfo, map[byte]*entityInfo) {
+	var entities []*entityInfo
+	byID := make(map[string]*entityInfo)
+
+	forEachSpec := func(record string, apply func(entity *entityInfo, key, value string)) {
+		var current *entityInfo
+		for _, token := range specificationList(header, record) {
+			key, value, ok := strings.Cut(token, ":")
+			if !ok {
+				continue
+			}
+			key = strings.TrimSpace(strings.ToUpper(key))
+			value = strings.TrimSpace(value)
+			if key == "MOL_ID" {
+				current = byID[value]
+				if current == nil {
+					current = &entityInfo{ID: value}
+					byID[value] = current
+					entities = append(entities, current)
+				}
+				continue
+			}
+			if current != nil {
+				apply(current, key, value)
+			}
+		}
+	}
+
+	forEachSpec("COMPND", func(entity *entityInfo, key, value string) {
+		switch key {
+		case "MOLECULE":
+			entity.Molecule = value
+		case "CHAIN":
+			for _, chainID := range strings.Split(value, ",") {
+				if chainID = strings.TrimSpace(chainID); len(chainID) == 1 {
+					entity.Chains = append(entity.Chains, chainID[0])
+				}
+			}
+		}
+	})
+	forEachSpec("SOURCE", func(entity *entityInfo, key, value string) {
+		switch key {
+		case "ORGANISM_SCIENTIFIC":
+			entity.Organism = value
+		case "ORGANISM_TAXID":
+			entity.TaxID = value
+		}
+	})
+
+	byChain := make(map[byte]*entityInfo)
+	for _, entity := range entities {
+		for _, chainID := range entity.Chains {
+			byChain[chainID] = entity
+		}
+	}
+	return entities, byChain
+}
+
+// headerLines returns the records of content before the first coordinate record
+func headerLines(content []byte) []string {
+	var header []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") || strings.HasPrefix(line, "MODEL") {
+			break
+		}
+		header = append(header, line)
+	}
+	return header
+}
+
+// parseModres reads the MODRES records of a header into a map from modified residue name to the name
+// of its standard parent residue
+func parseModres(header []string) map[string]string {
+	modres := make(map[string]string)
+	for _, line := range header {
+		if !strings.HasPrefix(line, "MODRES") {
+			continue
+		}
+		resName := strings.TrimSpace(safeColumns(line, 12, 15))
+		stdRes := strings.TrimSpace(safeColumns(line, 24, 27))
+		if resName != "" && stdRes != "" {
+			modres[resName] = stdRes
+		}
+	}
+	return modres
+}
@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/TuftsBCB/io/pdb"
@@ -11,11 +14,31 @@ import (
 // PDBEntryWithAltLoc extends the PDB entry with ALTLOC information
 type PDBEntryWithAltLoc struct {
 	*pdb.Entry
-	AltLocList []byte // ALTLOC values in the order they appear in the file
+	AltLocList []byte   // ALTLOC values in the order they appear in the file
+	Header     []string // records before the first coordinate record
+	Provenance []string // pdbtk REMARK blocks of earlier runs
 }
 
+// keptHeader returns the header records of the input that are written with the entry: the pdbtk
+// REMARK blocks and the crystal records
+func (e *PDBEntryWithAltLoc) keptHeader() []string {
+	return append(append([]string{}, e.Provenance...), crystalRecords(e.Header)...)
+}
+
+// contextCheckInterval is the number of lines the readers parse between checks of their context
+const contextCheckInterval = 4096
+
 // ReadPDBWithAltLoc reads a PDB file and preserves ALTLOC information
 func ReadPDBWithAltLoc(filename string) (*PDBEntryWithAltLoc, error) {
+	return ReadPDBWithAltLocContext(context.Background(), filename)
+}
+
+// ReadPDBWithAltLocContext is ReadPDBWithAltLoc with a context that stops the read, returning its error
+func ReadPDBWithAltLocContext(ctx context.Context, filename string) (*PDBEntryWithAltLoc, error) {
+	defer beginPhase(phaseParse)()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	// First, read the PDB file normally
 	entry, err := pdb.ReadPDB(filename)
 	if err != nil {
@@ -37,7 +60,10 @@ func ReadPDBWithAltLoc(filename string) (*PDBEntryWithAltLoc, error) {
 
 	scanner := bufio.NewScanner(file)
 
-	for scanner.Scan() {
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if lineNum%contextCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		line := scanner.Text()
 		if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
 			if len(line) >= 17 {
@@ -57,20 +83,29 @@ func ReadPDBWithAltLoc(filename string) (*PDBEntryWithAltLoc, error) {
 
 // ReadPDBWithAltLocFromContent reads PDB content and preserves ALTLOC information
 func ReadPDBWithAltLocFromContent(content []byte, filename string) (*PDBEntryWithAltLoc, error) {
-	// First, read the PDB content normally
-	tmpfile, err := os.CreateTemp("", "pdbtk_*.pdb")
+	return ReadPDBWithAltLocFromContentContext(context.Background(), content, filename)
+}
+
+// ReadPDBWithAltLocFromContentContext is ReadPDBWithAltLocFromContent with a context that stops the
+// read, returning its error
+func ReadPDBWithAltLocFromContentContext(ctx context.Context, content []byte, filename string) (*PDBEntryWithAltLoc, error) {
+	defer beginPhase(phaseParse)()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// First, read the PDB content normally. The pdb package takes the ID code of a file without a HEADER
+	// from its name, so the temporary file is given a name it takes none from.
+	dir, err := os.MkdirTemp("", "pdbtk-")
 	if err != nil {
 		return nil, err
 	}
-	defer os.Remove(tmpfile.Name())
-
-	if _, err := tmpfile.Write(content); err != nil {
-		tmpfile.Close()
+	defer os.RemoveAll(dir)
+	tmpName := filepath.Join(dir, "input")
+	if err := os.WriteFile(tmpName, content, 0600); err != nil {
 		return nil, err
 	}
-	tmpfile.Close()
 
-	entry, err := pdb.ReadPDB(tmpfile.Name())
+	entry, err := pdb.ReadPDB(tmpName)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +119,10 @@ func ReadPDBWithAltLocFromContent(content []byte, filename string) (*PDBEntryWit
 	// Now parse the content to extract ALTLOC information
 	lines := strings.Split(string(content), "\n")
 
-	for _, line := range lines {
+	for i, line := range lines {
+		if (i+1)%contextCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
 			if len(line) >= 17 {
 				// Extract ALTLOC (column 17)
@@ -96,3 +134,155 @@ func ReadPDBWithAltLocFromContent(content []byte, filename string) (*PDBEntryWit
 
 	return extendedEntry, nil
 }
+
+// readInputContent reads the content of inputFile, which may be an s3:// or gs:// URI, or of stdin
+// when inputFile is empty. PDB bundle archives are reassembled into a single PDB file, and with
+// --tolerant the malformed records of PDB content are repaired. Nothing is read once ctx is cancelled,
+// so commands that read many inputs stop at the next one.
+func readInputContent(ctx context.Context, inputFile string) ([]byte, error) {
+	content, err := readRawInputContent(ctx, inputFile)
+	if err != nil || !tolerantParsing || isCIFFilename(inputFile) || looksLikeCIF(content) {
+		return content, err
+	}
+	return repairPDBContent(inputFile, content)
+}
+
+// readRawInputContent is readInputContent without the repairs of --tolerant, for commands that check
+// the input as it is
+func readRawInputContent(ctx context.Context, inputFile string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, contextError(err)
+	}
+	defer beginPhase(phaseRead)()
+
+	if inputFile == "" {
+		content, err := readAllFromStdin()
+		if err != nil {
+			return nil, withCode(ErrCodeIO, fmt.Errorf("failed to read from stdin: %v", err))
+		}
+		return content, nil
+	}
+	if isArchiveInput(inputFile) {
+		return readArchiveInput(ctx, inputFile)
+	}
+	var content []byte
+	var err error
+	if isObjectURI(inputFile) {
+		content, err = readObject(ctx, inputFile)
+	} else if content, err = os.ReadFile(inputFile); err != nil {
+		err = withCode(ErrCodeIO, fmt.Errorf("failed to read input file: %v", err))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if isPDBBundle(inputFile) {
+		return readPDBBundle(content)
+	}
+	return content, nil
+}
+
+// readInputEntry reads a PDB entry from inputFile, or from stdin when inputFile is empty,
+// and reports problems found in its records as warnings
+func readInputEntry(ctx context.Context, inputFile string) (*pdb.Entry, error) {
+	extendedEntry, err := readInputEntryWithAltLoc(ctx, inputFile)
+	if err != nil {
+		return nil, err
+	}
+	return extendedEntry.Entry, nil
+}
+
+// readInputEntryWithAltLoc is like readInputEntry but also preserves ALTLOC information
+func readInputEntryWithAltLoc(ctx context.Context, inputFile string) (*PDBEntryWithAltLoc, error) {
+	content, err := readInputContent(ctx, inputFile)
+	if err != nil {
+		return nil, err
+	}
+	return parseInputEntry(ctx, inputFile, content)
+}
+
+// parseInputEntry parses the content read from inputFile (empty for stdin) as a PDB entry
+func parseInputEntry(ctx context.Context, inputFile string, content []byte) (*PDBEntryWithAltLoc, error) {
+	var extendedEntry *PDBEntryWithAltLoc
+	var err error
+	if inputFile == "" || isObjectURI(inputFile) || isPDBBundle(inputFile) || isArchiveInput(inputFile) || tolerantParsing {
+		extendedEntry, err = ReadPDBWithAltLocFromContentContext(ctx, content, inputFile)
+	} else {
+		// Read from the file itself so the entry path (used to infer the ID code) is the input filename
+		extendedEntry, err = ReadPDBWithAltLocContext(ctx, inputFile)
+	}
+	if err != nil {
+		return nil, parseError(ctx, err)
+	}
+
+	if err := checkInputRecords(ctx, content, extendedEntry.Entry); err != nil {
+		return nil, err
+	}
+	extendedEntry.Header = contentHeader(content)
+	extendedEntry.Provenance, _ = splitProvenance(extendedEntry.Header)
+	return extendedEntry, nil
+}
+
+// checkInputRecords warns about records that pdbtk cannot represent faithfully:
+// coordinate records without an element column, residues with unrecognised names and chain or residue
+// identifiers that are used more than once
+func checkInputRecords(ctx context.Context, content []byte, entry *pdb.Entry) error {
+	shortRecords := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+			if len(strings.TrimSpace(safeColumns(line, 76, 78))) == 0 {
+				shortRecords++
+			}
+		}
+	}
+	if shortRecords > 0 {
+		if err := warn("%d ATOM/HETATM records have no element symbol (columns 77-78)", shortRecords); err != nil {
+			return err
+		}
+	}
+
+	unknownResidues := 0
+	for _, chain := range entry.Chains {
+		for _, model := range chain.Models {
+			for _, residue := range model.Residues {
+				if residue.Name == 'X' {
+					unknownResidues++
+				}
+			}
+		}
+	}
+	if unknownResidues > 0 {
+		if err := warn("%d residues have unrecognised names (treated as UNK)", unknownResidues); err != nil {
+			return err
+		}
+	}
+
+	if file, err := ParsePDBRecordsContext(ctx, strings.NewReader(string(content))); err == nil {
+		for _, collision := range findIdentifierCollisions(file.Atoms) {
+			if err := warn("%s", collision.Message); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseError returns the error of a reader that failed to parse an input, or the error of ctx if it
+// stopped the reader
+func parseError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return contextError(ctx.Err())
+	}
+	return withCode(ErrCodeParse, fmt.Errorf("failed to read PDB file: %v", err))
+}
+
+// safeColumns returns line[start:end], clipped to the length of the line
+func safeColumns(line string, start, end int) string {
+	if start >= len(line) {
+		return ""
+	}
+	if end > len(line) {
+		end = len(line)
+	}
+	return line[start:end]
+}
@@ -0,0 +1,413 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AtomRecord holds every field of an ATOM or HETATM record
+type AtomRecord struct {
+	Het        bool
+	Serial     int
+	Name       string // atom name without surrounding spaces
+	AltLoc     byte   // ' ' when absent
+	ResName    string
+	ChainID    byte
+	ResSeq     int
+	ICode      byte // ' ' when absent
+	X, Y, Z    float64
+	Occupancy  float64
+	TempFactor float64
+	SegID      string
+	Element    string
+	Charge     string
+	Model      int
+}
+
+// PDBFile is a record-level view of a PDB file. Unlike pdb.Entry it keeps every field of the
+// coordinate records (including waters and HETATM residue names) and the non-coordinate records.
+type PDBFile struct {
+	Header []string      // records before the first coordinate record (HEADER, REMARK, SEQRES, CRYST1, ...)
+	Atoms  []*AtomRecord // ATOM/HETATM records of all models, in file order
+	Conect []string      // CONECT records
+}
+
+// ResidueKey identifies a residue within a model
+type ResidueKey struct {
+	Model   int
+	ChainID byte
+	ResSeq  int
+	ICode   byte
+	ResName string
+}
+
+// Residue returns the key of the residue this atom belongs to
+func (a *AtomRecord) Residue() ResidueKey {
+	return ResidueKey{Model: a.Model, ChainID: a.ChainID, ResSeq: a.ResSeq, ICode: a.ICode, ResName: a.ResName}
+}
+
+// String formats a residue key as e.g. "A:45B ALA"
+func (k ResidueKey) String() string {
+	icode := ""
+	if k.ICode != ' ' {
+		icode = string(k.ICode)
+	}
+	return fmt.Sprintf("%c:%d%s %s", k.ChainID, k.ResSeq, icode, k.ResName)
+}
+
+// Copy returns a copy of the atom record
+func (a *AtomRecord) Copy() *AtomRecord {
+	atom := *a
+	return &atom
+}
+
+// IdCode returns the ID code from the HEADER record, or an empty string if there is none
+func (f *PDBFile) IdCode() string {
+	for _, line := range f.Header {
+		if strings.HasPrefix(line, "HEADER") {
+			return strings.TrimSpace(safeColumns(line, 62, 66))
+		}
+	}
+	return ""
+}
+
+// Models returns the model numbers in the order they appear
+func (f *PDBFile) Models() []int {
+	var models []int
+	seen := make(map[int]bool)
+	for _, atom := range f.Atoms {
+		if !seen[atom.Model] {
+			seen[atom.Model] = true
+			models = append(models, atom.Model)
+		}
+	}
+	return models
+}
+
+// ChainIDs returns the chain IDs in the order they first appear
+func (f *PDBFile) ChainIDs() []byte {
+	var chainIDs []byte
+	seen := make(map[byte]bool)
+	for _, atom := range f.Atoms {
+		if !seen[atom.ChainID] {
+			seen[atom.ChainID] = true
+			chainIDs = append(chainIDs, atom.ChainID)
+		}
+	}
+	return chainIDs
+}
+
+// WithAtoms returns a shallow copy of the file with its atoms replaced
+func (f *PDBFile) WithAtoms(atoms []*AtomRecord) *PDBFile {
+	return &PDBFile{Header: f.Header, Atoms: atoms, Conect: f.Conect}
+}
+
+// ParsePDBRecords reads the records of a PDB file
+func ParsePDBRecords(reader io.Reader) (*PDBFile, error) {
+	return ParsePDBRecordsContext(context.Background(), reader)
+}
+
+// ParsePDBRecordsContext is ParsePDBRecords with a context that stops the parse, returning its error
+func ParsePDBRecordsContext(ctx context.Context, reader io.Reader) (*PDBFile, error) {
+	defer beginPhase(phaseParse)()
+	file := &PDBFile{}
+	model := 1
+	seenAtoms := false
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if lineNum%contextCheckInterval == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		line := strings.TrimRight(scanner.Text(), "\r")
+		recordName := strings.TrimSpace(safeColumns(line, 0, 6))
+
+		switch recordName {
+		case "ATOM", "HETATM":
+			atom, err := parseAtomRecord(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNum, err)
+			}
+			atom.Model = model
+			file.Atoms = append(file.Atoms, atom)
+			seenAtoms = true
+		case "MODEL":
+			n, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 10, 14)))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid MODEL serial number: %q", lineNum, line)
+			}
+			model = n
+		case "CONECT":
+			file.Conect = append(file.Conect, line)
+		case "TER", "ENDMDL", "END", "ANISOU", "MASTER", "SIGATM", "SIGUIJ":
+			// Regenerated (or dropped) on output
+		default:
+			if !seenAtoms && line != "" {
+				file.Header = append(file.Header, line)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(file.Atoms) == 0 {
+		return nil, fmt.Errorf("no ATOM or HETATM records found")
+	}
+	return file, nil
+}
+
+// readInputRecords reads the PDB records of inputFile, or of stdin when inputFile is empty
+func readInputRecords(ctx context.Context, inputFile string) (*PDBFile, error) {
+	content, err := readInputContent(ctx, inputFile)
+	if err != nil {
+		return nil, err
+	}
+	file, err := ParsePDBRecordsContext(ctx, strings.NewReader(string(content)))
+	if err != nil {
+		return nil, parseError(ctx, err)
+	}
+	return file, nil
+}
+
+func parseAtomRecord(line string) (*AtomRecord, error) {
+	if len(line) < 54 {
+		return nil, fmt.Errorf("coordinate record too short (%d columns, need at least 54)", len(line))
+	}
+
+	atom := &AtomRecord{
+		Het:       strings.HasPrefix(line, "HETATM"),
+		Name:      strings.TrimSpace(line[12:16]),
+		AltLoc:    line[16],
+		ResName:   strings.TrimSpace(line[17:20]),
+		ChainID:   line[21],
+		ICode:     line[26],
+		Occupancy: 1.0,
+		SegID:     strings.TrimSpace(safeColumns(line, 72, 76)),
+		Element:   strings.TrimSpace(safeColumns(line, 76, 78)),
+		Charge:    strings.TrimSpace(safeColumns(line, 78, 80)),
+	}
+
+	var err error
+	if serial := strings.TrimSpace(line[6:11]); serial != "" {
+		if atom.Serial, err = strconv.Atoi(serial); err != nil {
+			return nil, fmt.Errorf("invalid atom serial number %q", serial)
+		}
+	}
+	if atom.ResSeq, err = strconv.Atoi(strings.TrimSpace(line[22:26])); err != nil {
+		return nil, fmt.Errorf("invalid residue sequence number %q", line[22:26])
+	}
+	coords := []*float64{&atom.X, &atom.Y, &atom.Z}
+	for i, coord := range coords {
+		field := strings.TrimSpace(line[30+8*i : 38+8*i])
+		if *coord, err = strconv.ParseFloat(field, 64); err != nil {
+			return nil, fmt.Errorf("invalid coordinate %q", field)
+		}
+	}
+	if field := strings.TrimSpace(safeColumns(line, 54, 60)); field != "" {
+		if atom.Occupancy, err = strconv.ParseFloat(field, 64); err != nil {
+			return nil, fmt.Errorf("invalid occupancy %q", field)
+		}
+	}
+	if field := strings.TrimSpace(safeColumns(line, 60, 66)); field != "" {
+		if atom.TempFactor, err = strconv.ParseFloat(field, 64); err != nil {
+			return nil, fmt.Errorf("invalid temperature factor %q", field)
+		}
+	}
+	if atom.AltLoc == 0 {
+		atom.AltLoc = ' '
+	}
+	if atom.ICode == 0 {
+		atom.ICode = ' '
+	}
+	return atom, nil
+}
+
+// formatAtomRecord formats an atom as a fixed-column ATOM/HETATM record with the given serial number
+func formatAtomRecord(atom *AtomRecord, serial int) string {
+	recordType := "ATOM"
+	if atom.Het {
+		recordType = "HETATM"
+	}
+	return fmt.Sprintf("%-6s%5d %s%c%3s %c%4d%c   %8.3f%8.3f%8.3f%6.2f%6.2f      %-4s%2s%-2s",
+		recordType,
+		serial,
+		formatAtomNameWithElement(atom.Name, atom.Element),
+		atom.AltLoc,
+		atom.ResName,
+		atom.ChainID,
+		atom.ResSeq,
+		atom.ICode,
+		atom.X, atom.Y, atom.Z,
+		atom.Occupancy,
+		atom.TempFactor,
+		atom.SegID,
+		atom.Element,
+		atom.Charge,
+	)
+}
+
+// formatAtomNameWithElement formats an atom name for columns 13-16 using the element symbol to decide
+// the alignment, so that e.g. a calcium ion "CA" is not confused with an alpha carbon
+func formatAtomNameWithElement(name, element string) string {
+	if element == "" {
+		return formatAtomName(name)
+	}
+	if len(name) >= 4 || len(element) == 2 {
+		return fmt.Sprintf("%-4s", name)
+	}
+	return fmt.Sprintf(" %-3s", name)
+}
+
+// formatTerRecord formats a TER record following the given atom
+func formatTerRecord(atom *AtomRecord, serial int) string {
+	return fmt.Sprintf("TER   %5d      %3s %c%4d%c", serial, atom.ResName, atom.ChainID, atom.ResSeq, atom.ICode)
+}
+
+// writePDBRecords writes a record-level PDB file. Atom serial numbers are renumbered sequentially
+// (CONECT records are updated to match), TER records are written at the end of each polymer chain
+// and MODEL/ENDMDL records are written when there is more than one model.
+func writePDBRecords(file *PDBFile, writer io.Writer, commandLine string) error {
+	out, finish := formatCheckedWriter(writer)
+	w := bufio.NewWriter(out)
+
+	// The HEADER record comes first, and there is none to write for input without one
+	for _, line := range file.Header {
+		if strings.HasPrefix(line, "HEADER") {
+			fmt.Fprintln(w, line)
+			break
+		}
+	}
+	inherited, rest := splitProvenance(file.Header)
+	writeProvenance(w, inherited, commandLine)
+	for _, line := range rest {
+		if !strings.HasPrefix(line, "HEADER") {
+			fmt.Fprintln(w, line)
+		}
+	}
+
+	writeCoordinateRecords(w, file)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return finish()
+}
+
+// writeCoordinateRecords writes the MODEL, ATOM/HETATM, TER, ENDMDL, CONECT and END records of a file
+func writeCoordinateRecords(w io.Writer, file *PDBFile) {
+	models := file.Models()
+	multiModel := len(models) > 1
+
+	byModel := make(map[int][]*AtomRecord)
+	for _, atom := range file.Atoms {
+		byModel[atom.Model] = append(byModel[atom.Model], atom)
+	}
+
+	// Map original serial numbers of the first model to the new ones, for CONECT records
+	serialMap := make(map[int]int)
+	serial := 1
+	for i, model := range models {
+		if multiModel {
+			fmt.Fprintf(w, "MODEL     %4d\n", model)
+			serial = 1
+		}
+		atoms := byModel[model]
+		for j, atom := range atoms {
+			if i == 0 && atom.Serial != 0 {
+				if _, exists := serialMap[atom.Serial]; !exists {
+					serialMap[atom.Serial] = serial
+				}
+			}
+			fmt.Fprintln(w, formatAtomRecord(atom, serial))
+			serial++
+
+			// Terminate a polymer chain when the chain changes or HETATMs follow
+			if !atom.Het {
+				last := j == len(atoms)-1
+				if last || atoms[j+1].Het || atoms[j+1].ChainID != atom.ChainID {
+					fmt.Fprintln(w, formatTerRecord(atom, serial))
+					serial++
+				}
+			}
+		}
+		if multiModel {
+			fmt.Fprintf(w, "ENDMDL\n")
+		}
+	}
+
+	for _, line := range remapConect(file.Conect, serialMap) {
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "END\n")
+}
+
+// remapConect rewrites CONECT records to use new atom serial numbers, dropping bonds to atoms that were removed
+func remapConect(conect []string, serialMap map[int]int) []string {
+	bonds := make(map[int][]int)
+	var order []int
+	for _, line := range conect {
+		fields := parseConectSerials(line)
+		if len(fields) < 2 {
+			continue
+		}
+		from, ok := serialMap[fields[0]]
+		if !ok {
+			continue
+		}
+		for _, to := range fields[1:] {
+			if newTo, ok := serialMap[to]; ok {
+				if _, seen := bonds[from]; !seen {
+					order = append(order, from)
+				}
+				bonds[from] = append(bonds[from], newTo)
+			}
+		}
+	}
+
+	sort.Ints(order)
+	var lines []string
+	for _, from := range order {
+		partners := bonds[from]
+		sort.Ints(partners)
+		// At most four bonded atoms fit on a CONECT record
+		for i := 0; i < len(partners); i += 4 {
+			end := i + 4
+			if end > len(partners) {
+				end = len(partners)
+			}
+			var line strings.Builder
+			fmt.Fprintf(&line, "CONECT%5d", from)
+			for _, to := range partners[i:end] {
+				fmt.Fprintf(&line, "%5d", to)
+			}
+			lines = append(lines, line.String())
+		}
+	}
+	return lines
+}
+
+// parseConectSerials returns the atom serial numbers on a CONECT record
+func parseConectSerials(line string) []int {
+	var serials []int
+	for start := 6; start+5 <= len(line) && start < 31; start += 5 {
+		field := strings.TrimSpace(line[start : start+5])
+		if field == "" {
+			continue
+		}
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		serials = append(serials, n)
+	}
+	return serials
+}
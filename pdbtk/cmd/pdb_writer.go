@@ -8,18 +8,25 @@ import (
 	"github.com/TuftsBCB/io/pdb"
 )
 
-// writePDBToWriter writes a PDB entry to the given writer, preserving ALTLOC fields
-func writePDBToWriter(entry *pdb.Entry, writer io.Writer, commandLine string) error {
-	return writePDBToWriterWithAltLoc(entry, nil, writer, commandLine)
+// writePDBToWriter writes a PDB entry to the given writer, preserving ALTLOC fields. header are the
+// records of the input kept in the output, such as the pdbtk REMARK blocks of earlier runs.
+func writePDBToWriter(entry *pdb.Entry, header []string, writer io.Writer, commandLine string) error {
+	return writePDBToWriterWithAltLoc(entry, nil, header, writer, commandLine)
 }
 
 // writePDBToWriterWithAltLoc writes a PDB entry to the given writer, preserving ALTLOC fields
-func writePDBToWriterWithAltLoc(entry *pdb.Entry, altLocList []byte, writer io.Writer, commandLine string) error {
+func writePDBToWriterWithAltLoc(entry *pdb.Entry, altLocList []byte, header []string, writer io.Writer, commandLine string) error {
+	writer, finish := formatCheckedWriter(writer)
+
 	// Write header
-	fmt.Fprintf(writer, "HEADER    %s\n", entry.IdCode)
-	fmt.Fprintf(writer, "REMARK   1 GENERATED BY PDBTK\n")
-	fmt.Fprintf(writer, "REMARK   1 COMMAND: %s\n", commandLine)
-	fmt.Fprintf(writer, "REMARK   1\n")
+	if entry.IdCode != "" {
+		fmt.Fprintf(writer, "HEADER    %s\n", entry.IdCode)
+	}
+	inherited, rest := splitProvenance(header)
+	writeProvenance(writer, inherited, commandLine)
+	for _, line := range rest {
+		fmt.Fprintln(writer, line)
+	}
 
 	// Check if any chain has multiple models (ensemble) to determine if we need MODEL/ENDMDL records
 	hasMultipleModels := false
@@ -84,7 +91,7 @@ func writePDBToWriterWithAltLoc(entry *pdb.Entry, altLocList []byte, writer io.W
 	}
 
 	fmt.Fprintf(writer, "END\n")
-	return nil
+	return finish()
 }
 
 // ExtractAltLocFromAtomName extracts the ALTLOC field from an atom name
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// moleculeType is the kind of molecule a residue or chain belongs to
+type moleculeType string
+
+const (
+	moleculeProtein moleculeType = "protein"
+	moleculeDNA     moleculeType = "dna"
+	moleculeRNA     moleculeType = "rna"
+	moleculeLigand  moleculeType = "ligand"
+	moleculeWater   moleculeType = "water"
+)
+
+// moleculeTypes are the valid molecule types, in the order they are listed in messages
+var moleculeTypes = []moleculeType{moleculeProtein, moleculeDNA, moleculeRNA, moleculeLigand, moleculeWater}
+
+// parseMoleculeTypes parses a comma-separated list of molecule types such as "protein,ligand"
+func parseMoleculeTypes(spec string) (map[moleculeType]bool, error) {
+	types := make(map[moleculeType]bool)
+	for _, name := range strings.Split(spec, ",") {
+		t := moleculeType(strings.ToLower(strings.TrimSpace(name)))
+		valid := false
+		for _, known := range moleculeTypes {
+			valid = valid || t == known
+		}
+		if !valid {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --polymer: %s (must be protein, dna, rna, ligand or water)", name))
+		}
+		types[t] = true
+	}
+	return types, nil
+}
+
+// classifyResidues returns the molecule type of every residue: water, ligand, or the polymer type of
+// its chain. Polymer residues take the type of most polymer residues of their chain, so that modified
+// residues follow the chain they belong to.
+func classifyResidues(atoms []*AtomRecord) map[ResidueKey]moleculeType {
+	type chainKey struct {
+		model   int
+		chainID byte
+	}
+	ligands := ligandResidues(atoms)
+	types := make(map[ResidueKey]moleculeType)
+	votes := make(map[chainKey]map[moleculeType]int)
+	var polymer [][]*AtomRecord
+	for _, residue := range groupResidues(atoms) {
+		key := residue[0].Residue()
+		switch {
+		case waterResidues[key.ResName]:
+			types[key] = moleculeWater
+		case ligands[key]:
+			types[key] = moleculeLigand
+		default:
+			polymer = append(polymer, residue)
+			chain := chainKey{key.Model, key.ChainID}
+			if votes[chain] == nil {
+				votes[chain] = make(map[moleculeType]int)
+			}
+			if t := residuePolymerType(residue); t != "" {
+				votes[chain][t]++
+			}
+		}
+	}
+
+	for _, residue := range polymer {
+		key := residue[0].Residue()
+		chainType, most := moleculeProtein, 0
+		for _, t := range moleculeTypes {
+			if n := votes[chainKey{key.Model, key.ChainID}][t]; n > most {
+				chainType, most = t, n
+			}
+		}
+		types[key] = chainType
+	}
+	return types
+}
+
+// residuePolymerType guesses whether a polymer residue is an amino acid, a deoxyribonucleotide or a
+// ribonucleotide from its name, or from its atoms for modified residues. It returns "" if it cannot tell.
+func residuePolymerType(residue []*AtomRecord) moleculeType {
+	resName := residue[0].ResName
+	if _, standard := oneLetterCode(resName); standard {
+		switch {
+		case len(resName) == 3:
+			return moleculeProtein
+		case len(resName) == 2:
+			return moleculeDNA
+		default:
+			return moleculeRNA
+		}
+	}
+	names := make(map[string]bool)
+	for _, atom := range residue {
+		names[atom.Name] = true
+	}
+	switch {
+	case names["N"] && names["CA"] && names["C"]:
+		return moleculeProtein
+	case names["O2'"]:
+		return moleculeRNA
+	case names["C1'"] || names["O5'"]:
+		return moleculeDNA
+	}
+	return ""
+}
+
+// chainPolymerTypes returns the polymer type (protein, dna or rna) of each chain of the first model.
+// Chains without polymer residues are left out.
+func chainPolymerTypes(file *PDBFile) map[byte]moleculeType {
+	models := file.Models()
+	chains := make(map[byte]moleculeType)
+	if len(models) == 0 {
+		return chains
+	}
+	for key, t := range classifyResidues(file.Atoms) {
+		if key.Model == models[0] && t != moleculeWater && t != moleculeLigand {
+			chains[key.ChainID] = t
+		}
+	}
+	return chains
+}
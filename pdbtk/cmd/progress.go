@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var progressMode string
+
+const progressBarWidth = 30
+
+// progressReporter draws a progress bar (on a terminal) or periodic progress lines on stderr
+type progressReporter struct {
+	label    string
+	total    int64
+	current  int64
+	bytes    bool
+	start    time.Time
+	lastDraw time.Time
+	enabled  bool
+	tty      bool
+}
+
+// stderrIsTerminal reports whether stderr is attached to a terminal
+func stderrIsTerminal() bool {
+	stat, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return (stat.Mode() & os.ModeCharDevice) != 0
+}
+
+// validateProgressMode checks the value of the --progress flag
+func validateProgressMode() error {
+	switch progressMode {
+	case "auto", "always", "never":
+		return nil
+	}
+	return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --progress value: %s (must be auto, always or never)", progressMode))
+}
+
+// newProgress creates a progress reporter for total units of work. A total of -1 means unknown.
+// When bytes is true, amounts are formatted as byte sizes, otherwise as file counts.
+func newProgress(label string, total int64, bytes bool) *progressReporter {
+	tty := stderrIsTerminal()
+	enabled := progressMode == "always" || (progressMode == "auto" && tty)
+	return &progressReporter{
+		label:   label,
+		total:   total,
+		bytes:   bytes,
+		start:   time.Now(),
+		enabled: enabled,
+		tty:     tty,
+	}
+}
+
+// Add records n more units of completed work
+func (p *progressReporter) Add(n int64) {
+	p.current += n
+	if !p.enabled {
+		return
+	}
+	// Redraw a terminal bar often, but only emit a new line every few seconds otherwise
+	interval := 100 * time.Millisecond
+	if !p.tty {
+		interval = 2 * time.Second
+	}
+	if time.Since(p.lastDraw) >= interval {
+		p.draw()
+	}
+}
+
+// Write implements io.Writer so a progressReporter can count bytes passing through an io.TeeReader
+func (p *progressReporter) Write(b []byte) (int, error) {
+	p.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Reader wraps r so that bytes read from it are counted towards the progress
+func (p *progressReporter) Reader(r io.Reader) io.Reader {
+	return io.TeeReader(r, p)
+}
+
+// Finish draws the final state of the progress bar
+func (p *progressReporter) Finish() {
+	if !p.enabled {
+		return
+	}
+	p.draw()
+	if p.tty {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+func (p *progressReporter) draw() {
+	p.lastDraw = time.Now()
+
+	var line strings.Builder
+	line.WriteString(p.label)
+	if p.total > 0 {
+		fraction := float64(p.current) / float64(p.total)
+		if fraction > 1 {
+			fraction = 1
+		}
+		filled := int(fraction * progressBarWidth)
+		fmt.Fprintf(&line, " [%s%s] %3.0f%%", strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), fraction*100)
+		fmt.Fprintf(&line, " %s/%s", p.formatAmount(p.current), p.formatAmount(p.total))
+		if p.current > 0 && p.current < p.total {
+			elapsed := time.Since(p.start)
+			remaining := time.Duration(float64(elapsed) / fraction * (1 - fraction))
+			fmt.Fprintf(&line, " ETA %s", remaining.Round(time.Second))
+		}
+	} else {
+		fmt.Fprintf(&line, " %s", p.formatAmount(p.current))
+	}
+
+	if p.tty {
+		// Clear to end of line so shorter updates don't leave stale characters behind
+		fmt.Fprintf(os.Stderr, "\r%s\033[K", line.String())
+	} else {
+		fmt.Fprintln(os.Stderr, line.String())
+	}
+}
+
+func (p *progressReporter) formatAmount(n int64) string {
+	if !p.bytes {
+		return fmt.Sprintf("%d", n)
+	}
+	return formatByteSize(n)
+}
+
+// formatByteSize formats a number of bytes with a binary unit, e.g. 1.5MiB
+func formatByteSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
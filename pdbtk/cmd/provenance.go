@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Every PDB file written by pdbtk records how it was made in a REMARK 1 block, one per operation:
+//
+//	REMARK   1 GENERATED BY PDBTK 0.1.1
+//	REMARK   1 COMMAND: pdbtk extract --chain A input.pdb
+//	REMARK   1
+//
+// The blocks of earlier pdbtk runs in the input are kept ahead of the new one, so the output carries
+// the history of the operations that produced it.
+
+// noProvenance suppresses the pdbtk REMARK blocks, new and inherited
+var noProvenance bool
+
+// provenancePrefix starts the REMARK block written for each pdbtk operation
+const provenancePrefix = "REMARK   1 GENERATED BY PDBTK"
+
+// splitProvenance separates the pdbtk REMARK blocks of header records from the other records
+func splitProvenance(header []string) (provenance []string, rest []string) {
+	inBlock := false
+	for _, line := range header {
+		trimmed := strings.TrimRight(line, " ")
+		switch {
+		case strings.HasPrefix(trimmed, provenancePrefix):
+			inBlock = true
+			provenance = append(provenance, trimmed)
+		case inBlock && strings.HasPrefix(trimmed, "REMARK   1 COMMAND:"):
+			provenance = append(provenance, trimmed)
+		case inBlock && trimmed == "REMARK   1":
+			// The empty REMARK ends the block
+			provenance = append(provenance, trimmed)
+			inBlock = false
+		default:
+			inBlock = false
+			rest = append(rest, line)
+		}
+	}
+	return provenance, rest
+}
+
+// contentHeader returns the records of PDB content before the first coordinate record
+func contentHeader(content []byte) []string {
+	var header []string
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "ATOM  ") || strings.HasPrefix(line, "HETATM") || strings.HasPrefix(line, "MODEL ") {
+			break
+		}
+		header = append(header, line)
+	}
+	return header
+}
+
+// writeProvenance writes the pdbtk REMARK blocks of an output file: those inherited from the input,
+// followed by one for the current operation, unless --no-provenance is given
+func writeProvenance(w io.Writer, inherited []string, commandLine string) {
+	if noProvenance {
+		return
+	}
+	for _, line := range inherited {
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "%s %s\n", provenancePrefix, Version)
+	fmt.Fprintf(w, "REMARK   1 COMMAND: %s\n", commandLine)
+	fmt.Fprintf(w, "REMARK   1\n")
+}
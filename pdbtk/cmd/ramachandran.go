@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	ramachandranFormat string
+	ramachandranOutput string
+	ramachandranPlot   string
+)
+
+var ramachandranCmd = &cobra.Command{
+	Use:   "ramachandran [flags] [input_file]",
+	Short: "Report the backbone phi/psi torsion angles and plot them",
+	Long: `Compute the backbone torsion angles phi (C-N-CA-C) and psi (N-CA-C-N) of each amino acid residue, for
+Ramachandran analysis and model quality checks.
+
+Phi is undefined for the first residue of a chain and after a chain break (no C-N peptide bond to the
+previous residue, up to 2.0 Å), and psi for the last residue and before a break. Each residue is
+classified as glycine, proline, pre-proline (followed by a proline) or general, the classes whose
+torsion angle distributions differ.
+
+--plot writes a Ramachandran plot (phi against psi) for each chain, as SVG or PNG by the extension of
+the file name. {chain} in the name is replaced by the chain ID; without it, the chain ID is added
+before the extension when there is more than one chain (rama.svg becomes rama_A.svg, rama_B.svg).
+Residues are coloured by class: general blue, glycine orange, proline green, pre-proline purple. SVG
+plots have a title, axis labels, a legend and a tooltip for each residue; PNG plots have the grid
+and tick labels only. Only the first model and the first ALTLOC of atoms with alternate locations
+are used.
+
+The output is a tab-separated table (chain, resnum, resname, class, phi, psi), or JSON with --format
+json; undefined angles are empty (null in JSON).
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Report the phi/psi angles of each residue
+  pdbtk ramachandran 1a02.pdb
+
+  # Write a Ramachandran plot of each chain
+  pdbtk ramachandran 1a02.pdb --plot 1a02_rama.svg --output 1a02_rama.tsv`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRamachandran,
+}
+
+func init() {
+	ramachandranCmd.Flags().StringVarP(&ramachandranFormat, "format", "f", "tsv", "Output format: tsv or json")
+	ramachandranCmd.Flags().StringVarP(&ramachandranOutput, "output", "o", "", "Output file (default: stdout)")
+	ramachandranCmd.Flags().StringVar(&ramachandranPlot, "plot", "", "Write a Ramachandran plot of each chain to this SVG or PNG file ({chain} is replaced by the chain ID)")
+}
+
+// residueTorsions are the backbone torsion angles of a residue; phi and psi are nil when undefined
+type residueTorsions struct {
+	Chain   string   `json:"chain"`
+	ResNum  string   `json:"resnum"`
+	ResName string   `json:"resname"`
+	Class   string   `json:"class"`
+	Phi     *float64 `json:"phi"`
+	Psi     *float64 `json:"psi"`
+}
+
+func runRamachandran(cmd *cobra.Command, args []string) error {
+	if ramachandranFormat != "tsv" && ramachandranFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", ramachandranFormat))
+	}
+	if ramachandranPlot != "" {
+		if ext := strings.ToLower(filepath.Ext(ramachandranPlot)); ext != ".svg" && ext != ".png" {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --plot: %s (must end in .svg or .png)", ramachandranPlot))
+		}
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	models := file.Models()
+	var atoms []*AtomRecord
+	for _, atom := range file.Atoms {
+		if atom.Model == models[0] {
+			atoms = append(atoms, atom)
+		}
+	}
+	torsions := backboneTorsions(filterAltLocRecords(atoms, "first"))
+	if len(torsions) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no amino acid residues with N, CA and C atoms found"))
+	}
+
+	if ramachandranPlot != "" {
+		if err := writeRamachandranPlots(cmd, torsions, ramachandranPlot); err != nil {
+			return err
+		}
+	}
+
+	return writeOutput(cmd.Context(), ramachandranOutput, func(w io.Writer) error {
+		if ramachandranFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(torsions)
+		}
+		angle := func(v *float64) string {
+			if v == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*v, 'f', 1, 64)
+		}
+		fmt.Fprintln(w, "chain\tresnum\tresname\tclass\tphi\tpsi")
+		for _, r := range torsions {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Chain, r.ResNum, r.ResName, r.Class, angle(r.Phi), angle(r.Psi))
+		}
+		return nil
+	})
+}
+
+// backboneTorsions computes the phi and psi angles of the residues with N, CA and C atoms, in file order
+func backboneTorsions(atoms []*AtomRecord) []residueTorsions {
+	type backbone struct {
+		key      ResidueKey
+		n, ca, c *AtomRecord
+	}
+	var residues []backbone
+	for _, residue := range groupResidues(atoms) {
+		if waterResidues[residue[0].ResName] {
+			continue
+		}
+		b := backbone{key: residue[0].Residue()}
+		for _, atom := range residue {
+			switch atom.Name {
+			case "N":
+				b.n = atom
+			case "CA":
+				b.ca = atom
+			case "C":
+				b.c = atom
+			}
+		}
+		if b.n != nil && b.ca != nil && b.c != nil {
+			residues = append(residues, b)
+		}
+	}
+
+	// bonded reports whether residue i is joined to residue i+1 by a peptide bond
+	bonded := func(i int) bool {
+		return i >= 0 && i+1 < len(residues) && residues[i].key.ChainID == residues[i+1].key.ChainID &&
+			distance(residues[i].c.Coord(), residues[i+1].n.Coord()) <= peptideBondCutoff
+	}
+	var torsions []residueTorsions
+	for i, r := range residues {
+		resNum := strconv.Itoa(r.key.ResSeq)
+		if r.key.ICode != ' ' {
+			resNum += string(r.key.ICode)
+		}
+		t := residueTorsions{Chain: string(r.key.ChainID), ResNum: resNum, ResName: r.key.ResName, Class: "general"}
+		switch {
+		case r.key.ResName == "GLY":
+			t.Class = "glycine"
+		case r.key.ResName == "PRO":
+			t.Class = "proline"
+		case bonded(i) && residues[i+1].key.ResName == "PRO":
+			t.Class = "pre-proline"
+		}
+		if bonded(i - 1) {
+			phi := roundTo(dihedral(residues[i-1].c.Coord(), r.n.Coord(), r.ca.Coord(), r.c.Coord()), 1)
+			t.Phi = &phi
+		}
+		if bonded(i) {
+			psi := roundTo(dihedral(r.n.Coord(), r.ca.Coord(), r.c.Coord(), residues[i+1].n.Coord()), 1)
+			t.Psi = &psi
+		}
+		torsions = append(torsions, t)
+	}
+	return torsions
+}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Layout of a Ramachandran plot in pixels: the square plot area and the margins around it for the
+// title, tick labels, axis labels and legend
+const (
+	plotWidth  = 500
+	plotHeight = 520
+	plotLeft   = 70
+	plotTop    = 40
+	plotSize   = 400
+)
+
+// torsionClasses are the residue classes of a Ramachandran plot, in legend order, with their colours
+var torsionClasses = []struct {
+	name   string
+	colour color.RGBA
+}{
+	{"general", color.RGBA{0x1f, 0x77, 0xb4, 0xff}},
+	{"glycine", color.RGBA{0xff, 0x7f, 0x0e, 0xff}},
+	{"proline", color.RGBA{0x2c, 0xa0, 0x2c, 0xff}},
+	{"pre-proline", color.RGBA{0x94, 0x67, 0xbd, 0xff}},
+}
+
+// classColour returns the colour of the residues of a class
+func classColour(class string) color.RGBA {
+	for _, c := range torsionClasses {
+		if c.name == class {
+			return c.colour
+		}
+	}
+	return torsionClasses[0].colour
+}
+
+// plotPoint returns the position of a phi/psi pair in a plot
+func plotPoint(phi, psi float64) (float64, float64) {
+	return plotLeft + (phi+180)/360*plotSize, plotTop + (180-psi)/360*plotSize
+}
+
+// writeRamachandranPlots writes a plot of each chain with residues that have both phi and psi, to the file
+// named by template
+func writeRamachandranPlots(cmd *cobra.Command, torsions []residueTorsions, template string) error {
+	var chains []string
+	byChain := make(map[string][]residueTorsions)
+	for _, t := range torsions {
+		if t.Phi == nil || t.Psi == nil {
+			continue
+		}
+		if _, seen := byChain[t.Chain]; !seen {
+			chains = append(chains, t.Chain)
+		}
+		byChain[t.Chain] = append(byChain[t.Chain], t)
+	}
+	if len(chains) == 0 {
+		return warn("no residues with both phi and psi to plot")
+	}
+
+	for _, chain := range chains {
+		filename := plotFilename(template, chain, len(chains) > 1)
+		write := func(w io.Writer) error {
+			if strings.ToLower(filepath.Ext(filename)) == ".png" {
+				return writeRamachandranPNG(w, byChain[chain])
+			}
+			return writeRamachandranSVG(w, chain, byChain[chain])
+		}
+		if err := writeFileAtomic(cmd.Context(), filename, write); err != nil {
+			return withCode(ErrCodeIO, fmt.Errorf("failed to write plot: %v", err))
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %s (chain %s, %d residues)\n", filename, chain, len(byChain[chain]))
+	}
+	return nil
+}
+
+// plotFilename returns the plot file name of a chain: {chain} in template is replaced by the chain ID,
+// which is otherwise added before the extension when there are several chains
+func plotFilename(template, chain string, several bool) string {
+	if strings.Contains(template, "{chain}") {
+		return strings.ReplaceAll(template, "{chain}", chain)
+	}
+	if !several {
+		return template
+	}
+	ext := filepath.Ext(template)
+	return strings.TrimSuffix(template, ext) + "_" + chain + ext
+}
+
+// writeRamachandranSVG writes the Ramachandran plot of a chain as SVG
+func writeRamachandranSVG(w io.Writer, chain string, torsions []residueTorsions) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"sans-serif\">\n",
+		plotWidth, plotHeight, plotWidth, plotHeight)
+	fmt.Fprintf(&b, "<title>Ramachandran plot of chain %s</title>\n", html.EscapeString(chain))
+	fmt.Fprintf(&b, "<rect width=\"%d\" height=\"%d\" fill=\"white\"/>\n", plotWidth, plotHeight)
+	fmt.Fprintf(&b, "<text x=\"%d\" y=\"24\" text-anchor=\"middle\" font-size=\"16\">Chain %s (%d residues)</text>\n",
+		plotLeft+plotSize/2, html.EscapeString(chain), len(torsions))
+
+	// Grid every 60°, with the axes through 0 darker
+	for angle := -180; angle <= 180; angle += 60 {
+		x, y := plotPoint(float64(angle), float64(angle))
+		stroke := "#dddddd"
+		if angle == 0 {
+			stroke = "#999999"
+		}
+		fmt.Fprintf(&b, "<line x1=\"%.1f\" y1=\"%d\" x2=\"%.1f\" y2=\"%d\" stroke=\"%s\"/>\n", x, plotTop, x, plotTop+plotSize, stroke)
+		fmt.Fprintf(&b, "<line x1=\"%d\" y1=\"%.1f\" x2=\"%d\" y2=\"%.1f\" stroke=\"%s\"/>\n", plotLeft, y, plotLeft+plotSize, y, stroke)
+		fmt.Fprintf(&b, "<text x=\"%.1f\" y=\"%d\" text-anchor=\"middle\" font-size=\"12\">%d</text>\n", x, plotTop+plotSize+18, angle)
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%.1f\" text-anchor=\"end\" font-size=\"12\">%d</text>\n", plotLeft-6, y+4, angle)
+	}
+	fmt.Fprintf(&b, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"none\" stroke=\"black\"/>\n", plotLeft, plotTop, plotSize, plotSize)
+	fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" text-anchor=\"middle\" font-size=\"14\">φ (°)</text>\n", plotLeft+plotSize/2, plotTop+plotSize+40)
+	fmt.Fprintf(&b, "<text x=\"20\" y=\"%d\" text-anchor=\"middle\" font-size=\"14\" transform=\"rotate(-90 20 %d)\">ψ (°)</text>\n",
+		plotTop+plotSize/2, plotTop+plotSize/2)
+
+	for _, t := range torsions {
+		x, y := plotPoint(*t.Phi, *t.Psi)
+		c := classColour(t.Class)
+		fmt.Fprintf(&b, "<circle cx=\"%.1f\" cy=\"%.1f\" r=\"3\" fill=\"#%02x%02x%02x\"><title>%s:%s %s (%.1f, %.1f)</title></circle>\n",
+			x, y, c.R, c.G, c.B, html.EscapeString(t.Chain), html.EscapeString(t.ResNum), html.EscapeString(t.ResName), *t.Phi, *t.Psi)
+	}
+
+	for i, class := range torsionClasses {
+		x := plotLeft + i*plotSize/len(torsionClasses)
+		c := class.colour
+		fmt.Fprintf(&b, "<circle cx=\"%d\" cy=\"%d\" r=\"4\" fill=\"#%02x%02x%02x\"/>\n", x+6, plotHeight-16, c.R, c.G, c.B)
+		fmt.Fprintf(&b, "<text x=\"%d\" y=\"%d\" font-size=\"12\">%s</text>\n", x+14, plotHeight-12, class.name)
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// plotDigits are 3×5 bitmaps of the characters of the tick labels of PNG plots, one string per row
+var plotDigits = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", "..#", "..#", "..#"},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	'-': {"...", "...", "###", "...", "..."},
+}
+
+// writeRamachandranPNG writes the Ramachandran plot of a chain as PNG, with the grid and tick labels
+func writeRamachandranPNG(w io.Writer, torsions []residueTorsions) error {
+	img := image.NewRGBA(image.Rect(0, 0, plotWidth, plotHeight))
+	fill := func(x0, y0, x1, y1 int, c color.RGBA) {
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+	}
+	// text draws a tick label at twice the size of the bitmaps, anchored at its right (align 1) or centre (align 0.5)
+	text := func(s string, x, y int, align float64) {
+		const scale, advance = 2, 8
+		x -= int(float64(len(s)*advance) * align)
+		for i, r := range s {
+			for row, bits := range plotDigits[r] {
+				for col, bit := range bits {
+					if bit == '#' {
+						px, py := x+i*advance+col*scale, y+row*scale
+						fill(px, py, px+scale, py+scale, color.RGBA{0, 0, 0, 0xff})
+					}
+				}
+			}
+		}
+	}
+
+	fill(0, 0, plotWidth, plotHeight, color.RGBA{0xff, 0xff, 0xff, 0xff})
+	for angle := -180; angle <= 180; angle += 60 {
+		fx, fy := plotPoint(float64(angle), float64(angle))
+		x, y := int(fx), int(fy)
+		grey := color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+		if angle == 0 {
+			grey = color.RGBA{0x99, 0x99, 0x99, 0xff}
+		}
+		fill(x, plotTop, x+1, plotTop+plotSize, grey)
+		fill(plotLeft, y, plotLeft+plotSize, y+1, grey)
+		label := strconv.Itoa(angle)
+		text(label, x, plotTop+plotSize+8, 0.5)
+		text(label, plotLeft-8, y-5, 1)
+	}
+	black := color.RGBA{0, 0, 0, 0xff}
+	fill(plotLeft, plotTop, plotLeft+plotSize+1, plotTop+1, black)
+	fill(plotLeft, plotTop+plotSize, plotLeft+plotSize+1, plotTop+plotSize+1, black)
+	fill(plotLeft, plotTop, plotLeft+1, plotTop+plotSize+1, black)
+	fill(plotLeft+plotSize, plotTop, plotLeft+plotSize+1, plotTop+plotSize+1, black)
+
+	for _, t := range torsions {
+		fx, fy := plotPoint(*t.Phi, *t.Psi)
+		c := classColour(t.Class)
+		for dy := -3; dy <= 3; dy++ {
+			for dx := -3; dx <= 3; dx++ {
+				if dx*dx+dy*dy <= 9 {
+					img.SetRGBA(int(fx)+dx, int(fy)+dy, c)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rcsbDataURLEnv overrides the base URL of the RCSB Data API, e.g. for a mirror
+const rcsbDataURLEnv = "PDBTK_RCSB_DATA_URL"
+
+// rcsbDataURL returns the base URL of the RCSB Data API
+func rcsbDataURL() string {
+	if url := os.Getenv(rcsbDataURLEnv); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://data.rcsb.org"
+}
+
+// rcsbFilesURLEnv overrides the base URL of the RCSB file download service, e.g. for a mirror
+const rcsbFilesURLEnv = "PDBTK_RCSB_FILES_URL"
+
+// rcsbFilesURL returns the base URL of the RCSB file download service
+func rcsbFilesURL() string {
+	if url := os.Getenv(rcsbFilesURLEnv); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://files.rcsb.org"
+}
+
+// rcsbSearchURLEnv overrides the base URL of the RCSB Search API, e.g. for a mirror
+const rcsbSearchURLEnv = "PDBTK_RCSB_SEARCH_URL"
+
+// rcsbSearchURL returns the base URL of the RCSB Search API
+func rcsbSearchURL() string {
+	if url := os.Getenv(rcsbSearchURLEnv); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://search.rcsb.org"
+}
+
+// userAgentEnv sets the User-Agent of the requests to structure databases, e.g. to include a contact address
+const userAgentEnv = "PDBTK_USER_AGENT"
+
+// userAgent is the User-Agent set by --user-agent, which overrides PDBTK_USER_AGENT
+var userAgent string
+
+// requestUserAgent returns the User-Agent sent with requests to structure databases
+func requestUserAgent() string {
+	if userAgent != "" {
+		return userAgent
+	}
+	if agent := os.Getenv(userAgentEnv); agent != "" {
+		return agent
+	}
+	return "pdbtk/" + Version
+}
+
+// rateLimiter spaces requests at least interval apart, across goroutines
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// requestLimiter limits the rate of requests to structure databases, nil for no limit
+var requestLimiter *rateLimiter
+
+// newRateLimiter returns a limiter for perSecond requests per second
+func newRateLimiter(perSecond float64) *rateLimiter {
+	return &rateLimiter{interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait blocks until the next request may be sent, or until ctx is cancelled
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(start))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// errNotFound is returned by fetchJSON and fetchBody when the server responds with HTTP 404
+var errNotFound = errors.New("not found")
+
+// fetchJSON downloads url and decodes the JSON response into v
+func fetchJSON(ctx context.Context, url string, v interface{}) error {
+	defer beginPhase(phaseDownload)()
+	body, err := fetchBody(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(v); err != nil {
+		return withCode(ErrCodeNetwork, fmt.Errorf("invalid response from %s: %v", url, err))
+	}
+	return nil
+}
+
+// fetchBody requests url and returns the response body, which the caller must close
+func fetchBody(ctx context.Context, url string) (io.ReadCloser, error) {
+	defer beginPhase(phaseDownload)()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, withCode(ErrCodeNetwork, fmt.Errorf("invalid request: %v", err))
+	}
+	return doRequest(req)
+}
+
+// postJSON posts request as JSON to url and decodes the JSON response into v. An empty response
+// (e.g. HTTP 204 No Content) leaves v unchanged.
+func postJSON(ctx context.Context, url string, request interface{}, v interface{}) error {
+	defer beginPhase(phaseDownload)()
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return withCode(ErrCodeNetwork, fmt.Errorf("invalid request: %v", err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	body, err := doRequest(req)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(v); err != nil && err != io.EOF {
+		return withCode(ErrCodeNetwork, fmt.Errorf("invalid response from %s: %v", url, err))
+	}
+	return nil
+}
+
+// doRequest sends req and returns the response body, which the caller must close
+func doRequest(req *http.Request) (io.ReadCloser, error) {
+	resp, err := sendRequest(req)
+	if err != nil {
+		return nil, requestError(req, err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errNotFound
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		resp.Body.Close()
+		return nil, withCode(ErrCodeNetwork, fmt.Errorf("request to %s failed: HTTP %s", req.URL, resp.Status))
+	}
+	return resp.Body, nil
+}
+
+// requestError returns the error of a failed request, which is a cancellation if the context of req
+// was cancelled
+func requestError(req *http.Request, err error) error {
+	if ctxErr := req.Context().Err(); ctxErr != nil {
+		return contextError(ctxErr)
+	}
+	return withCode(ErrCodeNetwork, fmt.Errorf("request failed: %v", err))
+}
+
+// httpClient sends the requests of pdbtk. Connecting, the TLS handshake and the wait for the response
+// headers are limited, so a server that stops responding fails the request, but there is no limit on
+// the whole request: a large download takes as long as it needs, within the context of the request.
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+// sendRequest sends req with the pdbtk User-Agent, once the rate limit allows it. The request runs
+// until the context of req is done, so that --timeout limits it and large downloads are not cut short.
+func sendRequest(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", requestUserAgent())
+	if err := requestLimiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return httpClient.Do(req)
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// remarkClasses are the REMARK classes copied from the input by --keep-remarks
+type remarkClasses struct {
+	all     bool
+	classes map[int]bool
+}
+
+// parseRemarkClasses parses a comma-separated list of REMARK classes, e.g. 2,3,350,465, or "all"
+func parseRemarkClasses(spec string) (*remarkClasses, error) {
+	if strings.EqualFold(strings.TrimSpace(spec), "all") {
+		return &remarkClasses{all: true}, nil
+	}
+	classes := &remarkClasses{classes: make(map[int]bool)}
+	for _, part := range strings.Split(spec, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 || n > 999 {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --keep-remarks: %q (must be REMARK numbers such as 2,3,350,465, or all)", part))
+		}
+		classes.classes[n] = true
+	}
+	return classes, nil
+}
+
+// remarkClass returns the number of a REMARK record
+func remarkClass(line string) (int, bool) {
+	if !strings.HasPrefix(line, "REMARK") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 6, 10)))
+	return n, err == nil
+}
+
+// selectRemarks returns the REMARK records of header in the selected classes, leaving out the parts of
+// REMARK 350, 465 and 470 that belong to chains other than the given ones. pdbtk provenance blocks
+// are not included.
+func (c *remarkClasses) selectRemarks(header []string, chains map[byte]bool) []string {
+	_, header = splitProvenance(header)
+	byClass := make(map[int][]string)
+	var order []int
+	for _, line := range header {
+		n, ok := remarkClass(line)
+		if !ok || !(c.all || c.classes[n]) {
+			continue
+		}
+		if _, seen := byClass[n]; !seen {
+			order = append(order, n)
+		}
+		byClass[n] = append(byClass[n], line)
+	}
+	sort.Ints(order)
+
+	var selected []string
+	for _, n := range order {
+		lines := byClass[n]
+		switch n {
+		case 350:
+			lines = filterAssemblyRemarks(lines, chains)
+		case 465, 470:
+			lines = filterResidueRemarks(lines, chains)
+		}
+		selected = append(selected, lines...)
+	}
+	return selected
+}
+
+// filterResidueRemarks keeps the rows of a REMARK 465 (missing residues) or 470 (missing atoms) table
+// that belong to the given chains, with the text that introduces the table
+func filterResidueRemarks(lines []string, chains map[byte]bool) []string {
+	var kept []string
+	for _, line := range lines {
+		// Table rows: model (12-14), residue name (16-18), chain (20) and residue number (22-26)
+		if _, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 21, 26))); err == nil && len(line) > 19 {
+			if !chains[line[19]] {
+				continue
+			}
+		}
+		kept = append(kept, line)
+	}
+	return kept
+}
+
+// filterAssemblyRemarks keeps the parts of the REMARK 350 biological assemblies that apply to the given
+// chains. The chain lists of the operators are reduced to those chains, operators that apply to none of
+// them are left out, and so are the assemblies left without operators.
+func filterAssemblyRemarks(lines []string, chains map[byte]bool) []string {
+	var kept, assembly, operators []string
+	inAssembly, keepOperators, assemblyUsed := false, false, false
+	flushOperators := func() {
+		if keepOperators {
+			assembly = append(assembly, operators...)
+			assemblyUsed = true
+		}
+		operators, keepOperators = nil, false
+	}
+	flushAssembly := func() {
+		flushOperators()
+		if assemblyUsed || !inAssembly {
+			kept = append(kept, assembly...)
+		}
+		assembly, assemblyUsed = nil, false
+	}
+
+	for _, line := range lines {
+		text := strings.TrimSpace(safeColumns(line, 10, len(line)))
+		switch {
+		case strings.HasPrefix(text, "BIOMOLECULE:"):
+			flushAssembly()
+			inAssembly = true
+			assembly = append(assembly, line)
+		case strings.HasPrefix(text, "APPLY THE FOLLOWING TO CHAINS:"), strings.HasPrefix(text, "AND CHAINS:"):
+			if strings.HasPrefix(text, "APPLY") {
+				flushOperators()
+			}
+			prefix, list, _ := strings.Cut(line, ":")
+			var ids []string
+			for _, id := range strings.Split(list, ",") {
+				id = strings.TrimSpace(id)
+				if len(id) == 1 && chains[id[0]] {
+					ids = append(ids, id)
+				}
+			}
+			if len(ids) > 0 {
+				keepOperators = true
+				operators = append(operators, prefix+": "+strings.Join(ids, ", "))
+			} else if strings.HasPrefix(text, "APPLY") {
+				// Keep the line that starts the operator block, in case its chains follow on AND CHAINS lines
+				operators = append(operators, prefix+":")
+			}
+		case len(operators) > 0:
+			operators = append(operators, line)
+		default:
+			assembly = append(assembly, line)
+		}
+	}
+	flushAssembly()
+	return kept
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	removeHydrogensOutput string
+	removeHydrogensBatch  batchOptions
+)
+
+var removeHydrogensCmd = &cobra.Command{
+	Use:   "remove-hydrogens [flags] [input_file...]",
+	Short: "Remove hydrogen and deuterium atoms",
+	Long: `Remove all hydrogen and deuterium atoms from a PDB file, producing heavy-atom-only output.
+
+Hydrogens are identified by the element column (H or D). Atoms with a blank element column are
+identified from their atom name and residue, as in fix-elements, so that e.g. mercury (HG) ions
+are not removed. CONECT records to removed atoms are dropped.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Remove hydrogens from a PDB file
+  pdbtk remove-hydrogens 1a02.pdb --output 1a02_noh.pdb
+
+  # Remove hydrogens from every PDB file in a directory
+  pdbtk remove-hydrogens --outdir noh/ structures/*.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runRemoveHydrogens,
+}
+
+func init() {
+	removeHydrogensCmd.Flags().StringVarP(&removeHydrogensOutput, "output", "o", "", "Output file (default: stdout)")
+	addBatchFlags(removeHydrogensCmd, &removeHydrogensBatch, "{name}")
+}
+
+func runRemoveHydrogens(cmd *cobra.Command, args []string) error {
+	return runBatch(cmd.Context(), args, removeHydrogensOutput, removeHydrogensBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+
+		atoms := removeHydrogens(file.Atoms)
+		if len(atoms) == 0 {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("no heavy atoms left after removing hydrogens"))
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Removed %d hydrogen atoms\n", len(file.Atoms)-len(atoms))
+		return writePDBRecords(file.WithAtoms(atoms), writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// isHydrogen reports whether an atom is a hydrogen or deuterium, given its inferred element for atoms
+// with a blank element column
+func isHydrogen(atom *AtomRecord, inferred string) bool {
+	element := atom.Element
+	if element == "" {
+		element = inferred
+	}
+	return element == "H" || element == "D"
+}
+
+// removeHydrogens returns the atoms that are not hydrogen or deuterium
+func removeHydrogens(atoms []*AtomRecord) []*AtomRecord {
+	inferred := inferElements(atoms)
+	var heavy []*AtomRecord
+	for i, atom := range atoms {
+		if !isHydrogen(atom, inferred[i]) {
+			heavy = append(heavy, atom)
+		}
+	}
+	return heavy
+}
@@ -2,8 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"strings"
 
 	"github.com/TuftsBCB/io/pdb"
@@ -13,15 +12,17 @@ import (
 var (
 	renameToChainID string
 	renameOutput    string
+	renameBatch     batchOptions
 )
 
 var renameChainCmd = &cobra.Command{
-	Use:   "rename-chain [flags] <chain_id> [input_file]",
+	Use:   "rename-chain [flags] <chain_id> [input_file...]",
 	Short: "Rename a chain in a PDB file",
 	Long: `Rename a chain in a PDB structure file.
 The chain ID must be a single character. The new chain ID must also be a single character.
 If the specified chain does not exist, the command will exit with an error.
 If the new chain ID already exists, a warning will be logged but the operation will continue.
+Multiple input files (or glob patterns) can be processed in one run with --outdir.
 
 Examples:
   # Rename chain A to B
@@ -31,14 +32,18 @@ Examples:
   pdbtk rename-chain A --to B --output 1a02_renamed.pdb 1a02.pdb
 
   # Rename chain A to B from stdin
-  cat 1a02.pdb | pdbtk rename-chain A --to B`,
-	Args: cobra.RangeArgs(1, 2),
+  cat 1a02.pdb | pdbtk rename-chain A --to B
+
+  # Rename chain A to B in every PDB file in the current directory
+  pdbtk rename-chain A --to B --outdir renamed/ *.pdb`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: runRenameChain,
 }
 
 func init() {
 	renameChainCmd.Flags().StringVarP(&renameToChainID, "to", "t", "", "New chain ID (required)")
 	renameChainCmd.Flags().StringVarP(&renameOutput, "output", "o", "", "Output file (default: stdout)")
+	addBatchFlags(renameChainCmd, &renameBatch, "{name}")
 
 	renameChainCmd.MarkFlagRequired("to")
 }
@@ -47,80 +52,38 @@ func runRenameChain(cmd *cobra.Command, args []string) error {
 	// Get the chain ID to rename
 	chainID := args[0]
 	if len(chainID) != 1 {
-		return fmt.Errorf("chain ID must be a single character, got: %s", chainID)
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("chain ID must be a single character, got: %s", chainID))
 	}
 
 	// Validate new chain ID
 	if len(renameToChainID) != 1 {
-		return fmt.Errorf("new chain ID must be a single character, got: %s", renameToChainID)
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("new chain ID must be a single character, got: %s", renameToChainID))
 	}
 
-	var inputFile string
-	var isStdin bool
-
-	if len(args) > 1 {
-		inputFile = args[1]
-		isStdin = false
-		// Check if input file exists
-		if err := CheckFileExists(inputFile); err != nil {
-			return err
-		}
-		// Check if it's a PDB file
-		inputExt := strings.ToLower(filepath.Ext(inputFile))
-		if inputExt != ".pdb" {
-			return fmt.Errorf("only PDB files are supported, got: %s", inputExt)
-		}
-	} else {
-		// Check if stdin is available
-		stat, err := os.Stdin.Stat()
-		if err != nil {
-			return fmt.Errorf("failed to check stdin: %v", err)
-		}
-		if (stat.Mode() & os.ModeCharDevice) != 0 {
-			return fmt.Errorf("no input file specified and stdin is not available")
-		}
-		inputFile = ""
-		isStdin = true
-	}
+	return runBatch(cmd.Context(), args[1:], renameOutput, renameBatch, func(inputFile string, writer io.Writer) error {
+		return renameChainFile(cmd, args, inputFile, chainID[0], writer)
+	})
+}
 
+// renameChainFile renames a chain in a single input and writes the result to writer
+func renameChainFile(cmd *cobra.Command, args []string, inputFile string, chainID byte, writer io.Writer) error {
 	// Read the PDB file
-	var entry *pdb.Entry
-	var err error
-	if isStdin {
-		content, err := readAllFromStdin()
-		if err != nil {
-			return fmt.Errorf("failed to read from stdin: %v", err)
-		}
-		entry, err = readPDBFromContent(content)
-	} else {
-		entry, err = readPDB(inputFile)
-	}
+	extendedEntry, err := readInputEntryWithAltLoc(cmd.Context(), inputFile)
 	if err != nil {
-		return fmt.Errorf("failed to read PDB file: %v", err)
+		return err
 	}
+	entry := extendedEntry.Entry
 
 	// Rename the chain
-	renamedEntry, err := renameChainPDB(entry, chainID[0], renameToChainID[0])
+	renamedEntry, err := renameChainPDB(entry, chainID, renameToChainID[0])
 	if err != nil {
-		return fmt.Errorf("failed to rename chain: %v", err)
+		return fmt.Errorf("failed to rename chain: %w", err)
 	}
 
 	// Build the full command line
 	commandLine := buildRenameChainCommandLine(cmd, args, inputFile)
 
-	// Write the output
-	if renameOutput == "" || renameOutput == "-" {
-		// Write to stdout
-		return writePDBToWriter(renamedEntry, os.Stdout, commandLine)
-	} else {
-		// Write to file
-		file, err := os.Create(renameOutput)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %v", err)
-		}
-		defer file.Close()
-		return writePDBToWriter(renamedEntry, file, commandLine)
-	}
+	return writePDBToWriter(renamedEntry, extendedEntry.keptHeader(), writer, commandLine)
 }
 
 func renameChainPDB(entry *pdb.Entry, oldChainID, newChainID byte) (*pdb.Entry, error) {
@@ -148,12 +111,14 @@ func renameChainPDB(entry *pdb.Entry, oldChainID, newChainID byte) (*pdb.Entry,
 
 	// Error if old chain doesn't exist
 	if !oldChainExists {
-		return nil, fmt.Errorf("chain %c does not exist", oldChainID)
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("chain %c does not exist", oldChainID))
 	}
 
 	// Warning if new chain already exists
 	if newChainExists {
-		fmt.Fprintf(os.Stderr, "Warning: chain %c already exists, continuing anyway\n", newChainID)
+		if err := warn("chain %c already exists, continuing anyway", newChainID); err != nil {
+			return nil, err
+		}
 	}
 
 	// Copy chains with renamed chain
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// renumberedResidue records the author numbering of a residue and the number it was given
+type renumberedResidue struct {
+	ChainID  byte
+	ResName  string
+	Original residueNumber
+	New      int
+}
+
+// runRemoveInsertionCodes renumbers the inputs into plain integers, without insertion codes, and records
+// the original numbering of every residue in REMARK 999 records and optionally in an --audit table
+func runRemoveInsertionCodes(cmd *cobra.Command, args []string) error {
+	if renumberAudit != "" && (len(args) > 1 || renumberBatch.outdir != "") {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--audit requires a single input; use the REMARK 999 records of each output in batch mode"))
+	}
+	var start *int
+	if cmd.Flags().Changed("start") {
+		start = &renumberStart
+	}
+
+	var audit []renumberedResidue
+	err := runBatch(cmd.Context(), args, renumberOutput, renumberBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		if renumberChain != "" && !containsByte(file.ChainIDs(), renumberChain[0]) {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("chain %s does not exist", renumberChain))
+		}
+
+		audit = removeInsertionCodes(file, renumberChain, start, renumberForceSequential, renumberExcludeZero)
+		inserted := 0
+		for _, residue := range audit {
+			if residue.Original.ICode != ' ' {
+				inserted++
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Renumbered %d residues (%d with insertion codes)\n", len(audit), inserted)
+
+		file.Header = append(file.Header, insertionCodeRemarks(audit)...)
+		for _, collision := range findIdentifierCollisions(file.Atoms) {
+			if collision.Severity == SeverityError {
+				if err := warn("after renumbering, %s", collision.Message); err != nil {
+					return err
+				}
+			}
+		}
+		return writePDBRecords(file, writer, recordCommandLine(cmd, nil, inputFile))
+	})
+	if err != nil || renumberAudit == "" {
+		return err
+	}
+
+	return writeOutput(cmd.Context(), renumberAudit, func(w io.Writer) error {
+		fmt.Fprintln(w, "chain\tresidue\toriginal\tnew")
+		for _, residue := range audit {
+			fmt.Fprintf(w, "%c\t%s\t%s\t%d\n", residue.ChainID, residue.ResName, residue.Original, residue.New)
+		}
+		return nil
+	})
+}
+
+// String returns the residue number as written by authors, e.g. 52 or 52A
+func (n residueNumber) String() string {
+	if n.ICode == ' ' || n.ICode == 0 {
+		return fmt.Sprint(n.ResSeq)
+	}
+	return fmt.Sprintf("%d%c", n.ResSeq, n.ICode)
+}
+
+// removeInsertionCodes renumbers the residues of every chain (or only chainID) into integers without
+// insertion codes, and returns the renumbering of the first model.
+//
+// Residues with an insertion code take the number after the preceding residue, and later residues are
+// shifted to make room, so 52, 52A, 52B, 53 become 52, 53, 54, 55. Gaps in the author numbering are
+// kept unless forceSequential is set. The first residue of each chain keeps its number, or is given
+// start when it is not nil.
+func removeInsertionCodes(file *PDBFile, chainID string, start *int, forceSequential, excludeZero bool) []renumberedResidue {
+	type chainKey struct {
+		model   int
+		chainID byte
+	}
+	models := file.Models()
+	numbers := make(map[chainKey]map[residueNumber]int)
+	last := make(map[chainKey]int)
+	offsets := make(map[chainKey]int)
+	var audit []renumberedResidue
+
+	for _, atom := range file.Atoms {
+		if chainID != "" && atom.ChainID != chainID[0] {
+			continue
+		}
+		chain := chainKey{atom.Model, atom.ChainID}
+		original := residueNumber{atom.ResSeq, atom.ICode}
+		if numbers[chain] == nil {
+			numbers[chain] = make(map[residueNumber]int)
+			if start != nil {
+				offsets[chain] = *start - atom.ResSeq
+			}
+		}
+		number, seen := numbers[chain][original]
+		if !seen {
+			number = atom.ResSeq + offsets[chain]
+			if previous, ok := last[chain]; ok && (forceSequential || number <= previous) {
+				number = previous + 1
+			}
+			if excludeZero && number == 0 {
+				number = 1
+			}
+			offsets[chain] = number - atom.ResSeq
+			numbers[chain][original] = number
+			last[chain] = number
+			if atom.Model == models[0] {
+				audit = append(audit, renumberedResidue{ChainID: atom.ChainID, ResName: atom.ResName, Original: original, New: number})
+			}
+		}
+		atom.ResSeq, atom.ICode = number, ' '
+	}
+	return audit
+}
+
+// insertionCodeRemarks writes the original numbering of renumbered residues as REMARK 999 records
+func insertionCodeRemarks(audit []renumberedResidue) []string {
+	remarks := []string{
+		"REMARK 999",
+		"REMARK 999 ORIGINAL AUTHOR RESIDUE NUMBERING (RENUMBERED WITHOUT INSERTION CODES)",
+		"REMARK 999 CHAIN RESIDUE ORIGINAL    NEW",
+	}
+	for _, residue := range audit {
+		remarks = append(remarks, fmt.Sprintf("REMARK 999     %c %-7s %8s %6d", residue.ChainID, residue.ResName, residue.Original, residue.New))
+	}
+	return remarks
+}
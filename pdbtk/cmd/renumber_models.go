@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	renumberModelsStart       int
+	renumberModelsOrderBy     string
+	renumberModelsScoreColumn int
+	renumberModelsDescending  bool
+	renumberModelsOutput      string
+)
+
+var renumberModelsCmd = &cobra.Command{
+	Use:   "renumber-models [flags] [input_file]",
+	Short: "Renumber and reorder the models of a multi-model PDB file",
+	Long: `Renumber the MODEL records of a multi-model PDB file sequentially from --start (1 by default), e.g. for
+concatenated ensembles with duplicate or non-sequential model numbers that confuse viewers. Models with
+the same number are kept apart as separate models.
+
+With --order-by, the models are first sorted by a score (e.g. an energy) read from a file of whitespace-
+or comma-separated columns: the model number in the first column and the score in --score-column (2 by
+default). Lines starting with # and lines whose score is not a number, such as a header, are skipped.
+When the input has duplicate model numbers, the first column is the position of the model in the file
+instead. Models are sorted from the lowest score, or the highest with --descending; models missing from
+the file are placed last in their original order.
+Every model whose number changes is reported on stderr.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Renumber models 1, 2, 3...
+  pdbtk renumber-models ensemble.pdb > renumbered.pdb
+
+  # Order models by energy, lowest first
+  pdbtk renumber-models --order-by energies.tsv ensemble.pdb > sorted.pdb
+
+  # Order models by the score in the third column, highest first
+  pdbtk renumber-models --order-by scores.csv --score-column 3 --descending ensemble.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRenumberModels,
+}
+
+func init() {
+	renumberModelsCmd.Flags().IntVarP(&renumberModelsStart, "start", "s", 1, "Number of the first model")
+	renumberModelsCmd.Flags().StringVar(&renumberModelsOrderBy, "order-by", "", "Sort models by the scores in this file (model and score columns)")
+	renumberModelsCmd.Flags().IntVar(&renumberModelsScoreColumn, "score-column", 2, "Column of the score in the --order-by file (1-based)")
+	renumberModelsCmd.Flags().BoolVar(&renumberModelsDescending, "descending", false, "Sort models from the highest score")
+	renumberModelsCmd.Flags().StringVarP(&renumberModelsOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+func runRenumberModels(cmd *cobra.Command, args []string) error {
+	if renumberModelsScoreColumn < 2 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --score-column: %d (must be 2 or more; column 1 is the model)", renumberModelsScoreColumn))
+	}
+	if renumberModelsOrderBy == "" && (cmd.Flags().Changed("score-column") || renumberModelsDescending) {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--score-column and --descending require --order-by"))
+	}
+	var scores map[int]float64
+	if renumberModelsOrderBy != "" {
+		if err := CheckFileExistsContext(cmd.Context(), renumberModelsOrderBy); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+		var err error
+		if scores, err = readModelScores(renumberModelsOrderBy, renumberModelsScoreColumn); err != nil {
+			return err
+		}
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, originals, err := readModelBlocks(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	// Models are identified by their position in the file from here on
+	order := make([]int, len(originals))
+	for i := range order {
+		order[i] = i + 1
+	}
+	if scores != nil {
+		keys := originals
+		if hasDuplicates(originals) {
+			keys = order
+		}
+		var missing []string
+		score := make(map[int]float64)
+		for i, key := range keys {
+			s, ok := scores[key]
+			if !ok {
+				missing = append(missing, strconv.Itoa(key))
+				continue
+			}
+			score[i+1] = s
+		}
+		if len(missing) == len(keys) {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("none of the models are in %s", renumberModelsOrderBy))
+		}
+		if len(missing) > 0 {
+			if err := warn("models without a score are placed last: %s", strings.Join(missing, ",")); err != nil {
+				return err
+			}
+		}
+		sort.SliceStable(order, func(i, j int) bool {
+			si, okI := score[order[i]]
+			sj, okJ := score[order[j]]
+			if okI != okJ {
+				return okI
+			}
+			if renumberModelsDescending {
+				return si > sj
+			}
+			return si < sj
+		})
+	}
+
+	byPosition := make(map[int][]*AtomRecord)
+	for _, atom := range file.Atoms {
+		byPosition[atom.Model] = append(byPosition[atom.Model], atom)
+	}
+	var atoms []*AtomRecord
+	for i, position := range order {
+		number := renumberModelsStart + i
+		if number != originals[position-1] {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Model %d (position %d) -> %d\n", originals[position-1], position, number)
+		}
+		for _, atom := range byPosition[position] {
+			atom.Model = number
+			atoms = append(atoms, atom)
+		}
+	}
+
+	return writeOutput(cmd.Context(), renumberModelsOutput, func(w io.Writer) error {
+		return writePDBRecords(file.WithAtoms(atoms), w, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// readModelBlocks reads a PDB file with the models numbered by their position in the file, so that
+// models with the same MODEL number are kept apart, and returns the original model numbers
+func readModelBlocks(ctx context.Context, inputFile string) (*PDBFile, []int, error) {
+	content, err := readInputContent(ctx, inputFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(string(content), "\n")
+	var originals []int
+	for i, line := range lines {
+		if strings.TrimSpace(safeColumns(line, 0, 6)) != "MODEL" {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 10, 14)))
+		if err != nil {
+			return nil, nil, withCode(ErrCodeParse, fmt.Errorf("line %d: invalid MODEL serial number: %q", i+1, line))
+		}
+		originals = append(originals, n)
+		lines[i] = fmt.Sprintf("MODEL     %4d", len(originals))
+	}
+	file, err := ParsePDBRecords(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return nil, nil, withCode(ErrCodeParse, fmt.Errorf("failed to read PDB file: %v", err))
+	}
+	if len(originals) == 0 {
+		originals = []int{1}
+	}
+	return file, originals, nil
+}
+
+// hasDuplicates reports whether any number appears more than once
+func hasDuplicates(numbers []int) bool {
+	seen := make(map[int]bool)
+	for _, n := range numbers {
+		if seen[n] {
+			return true
+		}
+		seen[n] = true
+	}
+	return false
+}
+
+// readModelScores reads the score of each model from a file with the model in the first column and
+// the score in the given column
+func readModelScores(path string, column int) (map[int]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, withCode(ErrCodeIO, fmt.Errorf("failed to open %s: %v", path, err))
+	}
+	defer f.Close()
+
+	scores := make(map[int]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' })
+		if len(fields) < column {
+			continue
+		}
+		model, err1 := strconv.Atoi(fields[0])
+		score, err2 := strconv.ParseFloat(fields[column-1], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		scores[model] = score
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, withCode(ErrCodeIO, fmt.Errorf("failed to read %s: %v", path, err))
+	}
+	if len(scores) == 0 {
+		return nil, withCode(ErrCodeParse, fmt.Errorf("no model scores found in %s", path))
+	}
+	return scores, nil
+}
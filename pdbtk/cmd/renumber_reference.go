@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// residueNumber is a residue number with its insertion code
+type residueNumber struct {
+	ResSeq int
+	ICode  byte
+}
+
+// runRenumberToReference renumbers the inputs to match the residue numbering of --reference
+func runRenumberToReference(cmd *cobra.Command, args []string) error {
+	for _, flag := range []string{"start", "force-sequential", "exclude-zero"} {
+		if cmd.Flags().Changed(flag) {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("--%s cannot be combined with --reference", flag))
+		}
+	}
+	if renumberReferenceChain != "" && len(renumberReferenceChain) != 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("chain ID must be a single character, got: %s", renumberReferenceChain))
+	}
+	if err := CheckFileExistsContext(cmd.Context(), renumberReference); err != nil {
+		return withCode(ErrCodeInputNotFound, err)
+	}
+	reference, err := readInputRecords(cmd.Context(), renumberReference)
+	if err != nil {
+		return withCode(errorCode(err), fmt.Errorf("%s: %v", renumberReference, err))
+	}
+	_, referenceSeqs := polymerPositions(reference)
+
+	return runBatch(cmd.Context(), args, renumberOutput, renumberBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		chainIDs, targetSeqs := polymerPositions(file)
+		if renumberChain != "" {
+			if len(targetSeqs[renumberChain[0]]) == 0 {
+				return withCode(ErrCodeNoMatch, fmt.Errorf("chain %s does not exist", renumberChain))
+			}
+			chainIDs = []byte{renumberChain[0]}
+		}
+
+		renumbered := 0
+		for _, chainID := range chainIDs {
+			referenceChain := chainID
+			if renumberReferenceChain != "" {
+				referenceChain = renumberReferenceChain[0]
+			}
+			if len(referenceSeqs[referenceChain]) == 0 {
+				if err := warn("chain %c not found in the reference; its numbering is unchanged", referenceChain); err != nil {
+					return err
+				}
+				continue
+			}
+
+			numbers, mismatches, unmatched := referenceNumbering(targetSeqs[chainID], referenceSeqs[referenceChain])
+			for _, atom := range file.Atoms {
+				if atom.ChainID != chainID {
+					continue
+				}
+				if number, ok := numbers[residueNumber{atom.ResSeq, atom.ICode}]; ok {
+					atom.ResSeq, atom.ICode = number.ResSeq, number.ICode
+				}
+			}
+			renumbered++
+			fmt.Fprintf(cmd.ErrOrStderr(), "Renumbered chain %c to match reference chain %c (%d residues, %d mismatches, %d not in the reference)\n",
+				chainID, referenceChain, len(numbers), mismatches, unmatched)
+		}
+		if renumbered == 0 {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("no chains found in the reference"))
+		}
+
+		for _, collision := range findIdentifierCollisions(file.Atoms) {
+			if collision.Severity == SeverityError {
+				if err := warn("after renumbering, %s", collision.Message); err != nil {
+					return err
+				}
+			}
+		}
+		return writePDBRecords(file, writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// polymerPositions returns the polymer chains of the first model and their observed residues
+func polymerPositions(file *PDBFile) ([]byte, map[byte][]sequencePosition) {
+	positions := make(map[byte][]sequencePosition)
+	models := file.Models()
+	if len(models) == 0 {
+		return nil, positions
+	}
+	var chainIDs []byte
+	for chainID, sequence := range chainSequences(file, models[0], parseModres(file.Header), "parent") {
+		for _, position := range sequence {
+			if position.Residue != nil {
+				positions[chainID] = append(positions[chainID], position)
+			}
+		}
+	}
+	for _, chainID := range file.ChainIDs() {
+		if len(positions[chainID]) > 0 {
+			chainIDs = append(chainIDs, chainID)
+		}
+	}
+	return chainIDs, positions
+}
+
+// referenceNumbering aligns the residues of a target chain to a reference chain and returns the new
+// number of every target residue, the number of aligned residues with different residue types and the
+// number of target residues with no counterpart in the reference. Those residues continue the numbering
+// of the preceding aligned residue where the reference numbering leaves room (or at the C-terminus), take
+// insertion codes after it otherwise, and count down from the first aligned residue at the N-terminus.
+func referenceNumbering(target, reference []sequencePosition) (map[residueNumber]residueNumber, int, int) {
+	targetCodes := make([]byte, len(target))
+	for i, position := range target {
+		targetCodes[i] = position.Code
+	}
+	referenceCodes := make([]byte, len(reference))
+	for i, position := range reference {
+		referenceCodes[i] = position.Code
+	}
+
+	assigned := make([]*residueNumber, len(target))
+	mismatches := 0
+	for _, pair := range alignSequences(string(targetCodes), string(referenceCodes)) {
+		if pair.A < 0 || pair.B < 0 {
+			continue
+		}
+		ref := reference[pair.B].Residue
+		assigned[pair.A] = &residueNumber{ref.ResSeq, ref.ICode}
+		if target[pair.A].Code != reference[pair.B].Code {
+			mismatches++
+		}
+	}
+
+	// Fill runs of unaligned residues after an aligned residue: with the free numbers up to the next
+	// aligned residue if there are enough (the reference has a gap there), otherwise with insertion codes
+	unmatched := 0
+	for i := 0; i < len(assigned); i++ {
+		if assigned[i] != nil {
+			continue
+		}
+		end := i
+		for end < len(assigned) && assigned[end] == nil {
+			end++
+		}
+		unmatched += end - i
+		if i == 0 {
+			i = end
+			continue
+		}
+		previous := *assigned[i-1]
+		free := previous.ICode == ' ' && end < len(assigned) && assigned[end].ResSeq-previous.ResSeq-1 >= end-i
+		for j := i; j < end; j++ {
+			if free || end == len(assigned) && previous.ICode == ' ' {
+				assigned[j] = &residueNumber{previous.ResSeq + j - i + 1, ' '}
+				continue
+			}
+			icode := byte('A')
+			if previous.ICode != ' ' {
+				icode = previous.ICode
+			}
+			assigned[j] = &residueNumber{previous.ResSeq, icode + byte(j-i)}
+			if previous.ICode != ' ' {
+				assigned[j].ICode++
+			}
+		}
+		i = end
+	}
+	// Residues before the first aligned residue count down from it
+	for i := len(assigned) - 1; i >= 0; i-- {
+		if assigned[i] == nil {
+			next := residueNumber{1, ' '}
+			if i+1 < len(assigned) {
+				next = *assigned[i+1]
+			}
+			assigned[i] = &residueNumber{next.ResSeq - 1, ' '}
+		}
+	}
+
+	numbers := make(map[residueNumber]residueNumber)
+	for i, position := range target {
+		numbers[residueNumber{position.Residue.ResSeq, position.Residue.ICode}] = *assigned[i]
+	}
+	return numbers, mismatches, unmatched
+}
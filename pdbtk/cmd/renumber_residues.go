@@ -2,8 +2,7 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"strconv"
 	"strings"
 
@@ -17,15 +16,30 @@ var (
 	renumberForceSequential bool
 	renumberExcludeZero     bool
 	renumberOutput          string
+	renumberReference       string
+	renumberReferenceChain  string
+	renumberRemoveICodes    bool
+	renumberAudit           string
+	renumberBatch           batchOptions
 )
 
 var renumberResiduesCmd = &cobra.Command{
-	Use:   "renumber-residues [flags] [input_file]",
+	Use:   "renumber-residues [flags] [input_file...]",
 	Short: "Renumber residues in a PDB file",
 	Long: `Renumber residues in a PDB structure file starting from a specified number.
 By default, this preserves gaps in the residue sequence but offsets the numbering.
 Use --force-sequential to make all residues sequential without gaps.
 Use --exclude-zero to skip residue number zero when using negative start values.
+With --reference, the sequence of each chain is aligned to the same chain of a reference structure
+(or to --reference-chain) and the reference residue numbering is copied, including its gaps, so that
+model and experimental structures share residue numbers. Residues with no counterpart in the reference
+get insertion codes after the preceding reference residue (or numbers before the first one).
+With --remove-icodes, residues with insertion codes (e.g. Kabat-numbered antibodies) are given plain
+integers: each takes the number after the preceding residue and later residues are shifted, so 52, 52A,
+52B, 53 become 52, 53, 54, 55; the first residue of each chain keeps its number unless --start is given.
+The original author numbering of every residue is recorded in REMARK 999 records, and in a
+tab-separated table with --audit.
+Multiple input files (or glob patterns) can be processed in one run with --outdir.
 
 Examples:
   # Renumber all residues starting from 1
@@ -43,9 +57,18 @@ Examples:
   # Renumber starting from -1, skipping zero (goes -1, 1, 2, 3...)
   pdbtk renumber-residues --start -1 --exclude-zero 1a02.pdb
 
+  # Renumber a model to match the numbering of chain A of an experimental structure
+  pdbtk renumber-residues --reference 1a02.pdb --chain A model.pdb
+
+  # Remove insertion codes, keeping a table of the original numbering
+  pdbtk renumber-residues --remove-icodes --audit 1a02_numbering.tsv 1a02.pdb
+
   # Renumber and output to a file
-  pdbtk renumber-residues --start 1 --output 1a02_renumbered.pdb 1a02.pdb`,
-	Args: cobra.MaximumNArgs(1),
+  pdbtk renumber-residues --start 1 --output 1a02_renumbered.pdb 1a02.pdb
+
+  # Renumber every PDB file in the current directory
+  pdbtk renumber-residues --start 1 --outdir renumbered/ *.pdb`,
+	Args: cobra.ArbitraryArgs,
 	RunE: runRenumberResidues,
 }
 
@@ -55,80 +78,63 @@ func init() {
 	renumberResiduesCmd.Flags().BoolVarP(&renumberForceSequential, "force-sequential", "f", false, "Force sequential numbering without gaps")
 	renumberResiduesCmd.Flags().BoolVarP(&renumberExcludeZero, "exclude-zero", "z", false, "Skip residue number zero when using negative start values")
 	renumberResiduesCmd.Flags().StringVarP(&renumberOutput, "output", "o", "", "Output file (default: stdout)")
+	renumberResiduesCmd.Flags().StringVar(&renumberReference, "reference", "", "Copy the residue numbering of this reference PDB file, by sequence alignment")
+	renumberResiduesCmd.Flags().StringVar(&renumberReferenceChain, "reference-chain", "", "Reference chain to align to (default: the chain with the same ID)")
+	renumberResiduesCmd.Flags().BoolVar(&renumberRemoveICodes, "remove-icodes", false, "Renumber into plain integers without insertion codes, recording the original numbering")
+	renumberResiduesCmd.Flags().StringVar(&renumberAudit, "audit", "", "Write the original and new number of every residue to this TSV file (with --remove-icodes)")
+	addBatchFlags(renumberResiduesCmd, &renumberBatch, "{name}")
 }
 
 func runRenumberResidues(cmd *cobra.Command, args []string) error {
-	var inputFile string
-	var isStdin bool
-
-	if len(args) > 0 {
-		inputFile = args[0]
-		isStdin = false
-		// Check if input file exists
-		if err := CheckFileExists(inputFile); err != nil {
-			return err
-		}
-		// Check if it's a PDB file
-		inputExt := strings.ToLower(filepath.Ext(inputFile))
-		if inputExt != ".pdb" {
-			return fmt.Errorf("only PDB files are supported, got: %s", inputExt)
-		}
-	} else {
-		// Check if stdin is available
-		stat, err := os.Stdin.Stat()
-		if err != nil {
-			return fmt.Errorf("failed to check stdin: %v", err)
-		}
-		if (stat.Mode() & os.ModeCharDevice) != 0 {
-			return fmt.Errorf("no input file specified and stdin is not available")
-		}
-		inputFile = ""
-		isStdin = true
-	}
-
 	// Validate chain ID if specified
 	if renumberChain != "" && len(renumberChain) != 1 {
-		return fmt.Errorf("chain ID must be a single character, got: %s", renumberChain)
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("chain ID must be a single character, got: %s", renumberChain))
 	}
 
-	// Read the PDB file
-	var entry *pdb.Entry
-	var err error
-	if isStdin {
-		content, err := readAllFromStdin()
-		if err != nil {
-			return fmt.Errorf("failed to read from stdin: %v", err)
+	if renumberAudit != "" && !renumberRemoveICodes {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--audit requires --remove-icodes"))
+	}
+	if renumberReference != "" {
+		if renumberRemoveICodes {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("--remove-icodes cannot be combined with --reference"))
 		}
-		entry, err = readPDBFromContent(content)
-	} else {
-		entry, err = readPDB(inputFile)
+		return runRenumberToReference(cmd, args)
+	}
+	if renumberReferenceChain != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--reference-chain requires --reference"))
+	}
+	if renumberRemoveICodes {
+		return runRemoveInsertionCodes(cmd, args)
 	}
+
+	return runBatch(cmd.Context(), args, renumberOutput, renumberBatch, func(inputFile string, writer io.Writer) error {
+		return renumberResiduesFile(cmd, args, inputFile, writer)
+	})
+}
+
+// renumberResiduesFile renumbers the residues of a single input and writes the result to writer
+func renumberResiduesFile(cmd *cobra.Command, args []string, inputFile string, writer io.Writer) error {
+	// Read the PDB file
+	extendedEntry, err := readInputEntryWithAltLoc(cmd.Context(), inputFile)
 	if err != nil {
-		return fmt.Errorf("failed to read PDB file: %v", err)
+		return err
+	}
+	entry := extendedEntry.Entry
+
+	if renumberChain != "" && entry.Chain(renumberChain[0]) == nil {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("chain %s does not exist", renumberChain))
 	}
 
 	// Renumber residues
 	renumberedEntry, err := renumberResiduesPDB(entry, renumberStart, renumberChain, renumberForceSequential, renumberExcludeZero)
 	if err != nil {
-		return fmt.Errorf("failed to renumber residues: %v", err)
+		return fmt.Errorf("failed to renumber residues: %w", err)
 	}
 
 	// Build the full command line
 	commandLine := buildRenumberResiduesCommandLine(cmd, args, inputFile)
 
-	// Write the output
-	if renumberOutput == "" || renumberOutput == "-" {
-		// Write to stdout
-		return writePDBToWriter(renumberedEntry, os.Stdout, commandLine)
-	} else {
-		// Write to file
-		file, err := os.Create(renumberOutput)
-		if err != nil {
-			return fmt.Errorf("failed to create output file: %v", err)
-		}
-		defer file.Close()
-		return writePDBToWriter(renumberedEntry, file, commandLine)
-	}
+	return writePDBToWriter(renumberedEntry, extendedEntry.keptHeader(), writer, commandLine)
 }
 
 func renumberResiduesPDB(entry *pdb.Entry, startNum int, chainID string, forceSequential bool, excludeZero bool) (*pdb.Entry, error) {
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Error codes used in machine-readable error output and run reports
+const (
+	ErrCodeGeneric           = "error"
+	ErrCodeInvalidArgument   = "invalid_argument"
+	ErrCodeInputNotFound     = "input_not_found"
+	ErrCodeUnsupportedFormat = "unsupported_format"
+	ErrCodeParse             = "parse_error"
+	ErrCodeIO                = "io_error"
+	ErrCodeNetwork           = "network_error"
+	ErrCodeNoMatch           = "no_match"
+	ErrCodeStrict            = "strict_warning"
+	ErrCodeWarning           = "warning"
+	ErrCodeCanceled          = "canceled"
+)
+
+// Process exit codes, so scripts can branch on the kind of failure
+const (
+	ExitOK       = 0
+	ExitError    = 1
+	ExitNoMatch  = 2
+	ExitParse    = 3
+	ExitNetwork  = 4
+	ExitWarnings = 5
+	ExitCanceled = 6
+)
+
+var (
+	reportFile string
+	jsonErrors bool
+	strictMode bool
+)
+
+// codedError attaches a machine-readable error code to an error
+type codedError struct {
+	code string
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// withCode tags err with a machine-readable error code
+func withCode(code string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// contextError returns the error of a cancelled context: the run was interrupted, or it ran past the
+// deadline of --timeout
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return withCode(ErrCodeCanceled, fmt.Errorf("timed out after %s", runTimeout))
+	}
+	return withCode(ErrCodeCanceled, fmt.Errorf("interrupted"))
+}
+
+// errorCode returns the code attached to err, or ErrCodeGeneric if it has none
+func errorCode(err error) string {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		return coded.code
+	}
+	return ErrCodeGeneric
+}
+
+// ExitCode returns the process exit code for the error returned by Execute
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	switch errorCode(err) {
+	case ErrCodeNoMatch:
+		return ExitNoMatch
+	case ErrCodeParse:
+		return ExitParse
+	case ErrCodeNetwork:
+		return ExitNetwork
+	case ErrCodeStrict:
+		return ExitWarnings
+	case ErrCodeCanceled:
+		return ExitCanceled
+	}
+	return ExitError
+}
+
+// warn prints a warning to stderr, or returns it as an error when --strict is set
+func warn(format string, args ...interface{}) error {
+	return warnLine("", 0, format, args...)
+}
+
+// warnLine is warn for a warning about a line of input, which is included in the warning when
+// input is not empty (stdin) and line is not zero
+func warnLine(input string, line int, format string, args ...interface{}) error {
+	warning := reportError{Code: ErrCodeWarning, Message: fmt.Sprintf(format, args...), Input: input, Line: line}
+	location := input
+	switch {
+	case line > 0 && input != "":
+		location = fmt.Sprintf("%s:%d", input, line)
+	case line > 0:
+		location = fmt.Sprintf("line %d", line)
+	}
+	msg := warning.Message
+	if location != "" {
+		msg = location + ": " + msg
+	}
+	if strictMode {
+		return withCode(ErrCodeStrict, fmt.Errorf("%s (warning treated as error in --strict mode)", msg))
+	}
+
+	currentReport.Warnings = append(currentReport.Warnings, warning)
+	if jsonErrors {
+		json.NewEncoder(os.Stderr).Encode(warning)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	return nil
+}
+
+// reportError is the JSON representation of an error
+type reportError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Input   string `json:"input,omitempty"`
+	Line    int    `json:"line,omitempty"`
+}
+
+// inputResult records the outcome of processing a single input
+type inputResult struct {
+	Input  string       `json:"input"`
+	Output string       `json:"output,omitempty"`
+	Status string       `json:"status"`
+	Error  *reportError `json:"error,omitempty"`
+}
+
+// runReport is written to the --report file at the end of a run
+type runReport struct {
+	Command  string         `json:"command"`
+	Version  string         `json:"version"`
+	Status   string         `json:"status"`
+	Results  []inputResult  `json:"results"`
+	Warnings []reportError  `json:"warnings,omitempty"`
+	Error    *reportError   `json:"error,omitempty"`
+	Timings  *timingSummary `json:"timings,omitempty"`
+}
+
+var currentReport = &runReport{Results: make([]inputResult, 0)}
+
+func newReportError(input string, err error) *reportError {
+	return &reportError{Code: errorCode(err), Message: err.Error(), Input: input}
+}
+
+// recordResult adds the outcome of processing input to the run report and the manifest
+func recordResult(input, output string, err error) {
+	recordOutputResult(input, output, nil, err)
+}
+
+// recordOutputResult is recordResult for an input whose coordinate records were counted in stats
+func recordOutputResult(input, output string, stats *outputStats, err error) {
+	result := inputResult{Input: input, Output: output, Status: "ok"}
+	if input == "" {
+		result.Input = "-"
+	}
+	if output == "" || output == "-" {
+		result.Output = "-"
+	}
+	if err != nil {
+		result.Status = "error"
+		result.Error = newReportError(input, err)
+	}
+	currentReport.Results = append(currentReport.Results, result)
+	writeManifestEntry(result, stats)
+}
+
+// recordSkipped adds an input skipped by --resume to the run report and the manifest
+func recordSkipped(input, output string) {
+	result := inputResult{Input: input, Output: output, Status: "skipped"}
+	currentReport.Results = append(currentReport.Results, result)
+	writeManifestEntry(result, nil)
+}
+
+// printError writes err to stderr, as a JSON object when --json-errors is set.
+// input names the file the error relates to and may be empty.
+func printError(input string, err error) {
+	if jsonErrors {
+		json.NewEncoder(os.Stderr).Encode(newReportError(input, err))
+		return
+	}
+	if input != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s: %v\n", input, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+}
+
+// writeReport writes the run report for the completed command to reportFile
+func writeReport(args []string, runErr error) error {
+	currentReport.Command = strings.Join(append([]string{"pdbtk"}, args...), " ")
+	currentReport.Version = Version
+	currentReport.Status = "ok"
+	if runErr != nil {
+		currentReport.Status = "error"
+		currentReport.Error = newReportError("", runErr)
+	}
+
+	// The report is written even when the run was interrupted
+	return writeFileAtomic(context.Background(), reportFile, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(currentReport)
+	})
+}
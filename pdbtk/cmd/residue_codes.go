@@ -0,0 +1,42 @@
+package cmd
+
+// oneLetterCodes maps the residue names of standard amino acids and nucleotides to one-letter codes
+var oneLetterCodes = map[string]byte{
+	"ALA": 'A', "ARG": 'R', "ASN": 'N', "ASP": 'D', "CYS": 'C', "GLN": 'Q', "GLU": 'E', "GLY": 'G',
+	"HIS": 'H', "ILE": 'I', "LEU": 'L', "LYS": 'K', "MET": 'M', "PHE": 'F', "PRO": 'P', "SER": 'S',
+	"THR": 'T', "TRP": 'W', "TYR": 'Y', "VAL": 'V', "SEC": 'U', "PYL": 'O', "UNK": 'X',
+	"A": 'A', "C": 'C', "G": 'G', "U": 'U', "I": 'I', "N": 'N',
+	"DA": 'A', "DC": 'C', "DG": 'G', "DT": 'T', "DI": 'I', "DN": 'N',
+}
+
+// waterResidues are the residue names used for water molecules
+var waterResidues = map[string]bool{"HOH": true, "WAT": true, "DOD": true, "H2O": true, "SOL": true}
+
+// isPolymerResidue reports whether the atoms of a residue that is not a standard residue look like part
+// of a polymer chain: an amino acid backbone (N, CA, C) or a nucleotide backbone (P, O5', C5')
+func isPolymerResidue(residue []*AtomRecord) bool {
+	names := make(map[string]bool)
+	for _, atom := range residue {
+		names[atom.Name] = true
+	}
+	return (names["N"] && names["CA"] && names["C"]) || (names["P"] && names["O5'"] && names["C5'"])
+}
+
+// oneLetterCode returns the one-letter code of a standard residue, and false for other residues
+func oneLetterCode(resName string) (byte, bool) {
+	code, ok := oneLetterCodes[resName]
+	return code, ok
+}
+
+// parentOneLetterCode returns the one-letter code of the standard parent of a modified residue, from the
+// MODRES records or the built-in table of common modifications, or X if the parent is not known
+func parentOneLetterCode(resName string, modres map[string]string) byte {
+	parent, ok := modres[resName]
+	if !ok {
+		parent = modifiedResidueParents[resName]
+	}
+	if code, ok := oneLetterCodes[parent]; ok {
+		return code
+	}
+	return 'X'
+}
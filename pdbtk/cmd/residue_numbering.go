@@ -0,0 +1,246 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	residueNumberingChains string
+	residueNumberingFormat string
+	residueNumberingOutput string
+)
+
+var residueNumberingCmd = &cobra.Command{
+	Use:   "residue-numbering [flags] [input_file]",
+	Short: "Export the mapping between author and label residue numbering of an mmCIF file",
+	Long: `Export the mapping between the author residue numbering (auth_asym_id, auth_seq_id and insertion
+code, as used in PDB files and most papers) and the label numbering (label_asym_id and label_seq_id,
+which count from 1 along the entity sequence) of the polymer residues of an mmCIF file.
+
+The mapping is read from _pdbx_poly_seq_scheme when present, which includes residues missing from the
+coordinates, and otherwise from the residues of the first model in _atom_site. Residues without
+coordinates have observed = false and no author number. The offset column is auth_seq_id minus
+label_seq_id; a summary of the offsets of each chain is printed to stderr.
+
+Use --chains to select chains by author or label chain ID.
+The output is a tab-separated table (entity, label_asym_id, label_seq_id, auth_asym_id, auth_seq_id,
+ins_code, residue, observed, offset), or JSON with --format json.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Export the numbering of every polymer residue
+  pdbtk residue-numbering 1a02.cif
+
+  # Export the numbering of chain A as JSON
+  pdbtk residue-numbering --chains A --format json 1a02.cif`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runResidueNumbering,
+}
+
+func init() {
+	residueNumberingCmd.Flags().StringVarP(&residueNumberingChains, "chains", "c", "", "Comma-separated list of author or label chain IDs (default: all chains)")
+	residueNumberingCmd.Flags().StringVarP(&residueNumberingFormat, "format", "f", "tsv", "Output format: tsv or json")
+	residueNumberingCmd.Flags().StringVarP(&residueNumberingOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// residueNumbering is the author and label numbering of a polymer residue
+type residueNumbering struct {
+	Entity    string `json:"entity"`
+	LabelAsym string `json:"label_asym_id"`
+	LabelSeq  int    `json:"label_seq_id"`
+	AuthAsym  string `json:"auth_asym_id"`
+	AuthSeq   *int   `json:"auth_seq_id"`
+	InsCode   string `json:"ins_code"`
+	Residue   string `json:"residue"`
+	Observed  bool   `json:"observed"`
+	Offset    *int   `json:"offset"`
+}
+
+func runResidueNumbering(cmd *cobra.Command, args []string) error {
+	if residueNumberingFormat != "tsv" && residueNumberingFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", residueNumberingFormat))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	// PDB files only have author numbering
+	block, err := readCIFInput(cmd.Context(), inputFile, "residue-numbering")
+	if err != nil {
+		return err
+	}
+
+	var residues []residueNumbering
+	if block.HasCategory("_pdbx_poly_seq_scheme") {
+		residues, err = polySeqSchemeNumbering(block)
+	} else {
+		residues, err = atomSiteNumbering(block)
+	}
+	if err != nil {
+		return withCode(ErrCodeParse, err)
+	}
+	if residueNumberingChains != "" {
+		chains := strings.Split(residueNumberingChains, ",")
+		var selected []residueNumbering
+		for _, r := range residues {
+			if containsString(chains, r.AuthAsym) || containsString(chains, r.LabelAsym) {
+				selected = append(selected, r)
+			}
+		}
+		residues = selected
+	}
+	if len(residues) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no polymer residues found"))
+	}
+	writeNumberingOffsets(cmd.ErrOrStderr(), residues)
+
+	return writeOutput(cmd.Context(), residueNumberingOutput, func(w io.Writer) error {
+		if residueNumberingFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(residues)
+		}
+		fmt.Fprintln(w, "entity\tlabel_asym_id\tlabel_seq_id\tauth_asym_id\tauth_seq_id\tins_code\tresidue\tobserved\toffset")
+		optional := func(n *int) string {
+			if n == nil {
+				return ""
+			}
+			return strconv.Itoa(*n)
+		}
+		for _, r := range residues {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%t\t%s\n", r.Entity, r.LabelAsym, r.LabelSeq, r.AuthAsym,
+				optional(r.AuthSeq), r.InsCode, r.Residue, r.Observed, optional(r.Offset))
+		}
+		return nil
+	})
+}
+
+// cifRowValue returns an mmCIF value, or "" for the null values ? and .
+func cifRowValue(row map[string]string, item string) string {
+	value := row[item]
+	if value == "?" || value == "." {
+		return ""
+	}
+	return value
+}
+
+// polySeqSchemeNumbering reads the numbering of every polymer residue from _pdbx_poly_seq_scheme
+func polySeqSchemeNumbering(block *CIFBlock) ([]residueNumbering, error) {
+	var residues []residueNumbering
+	for _, row := range block.Category("_pdbx_poly_seq_scheme") {
+		labelSeq, err := strconv.Atoi(row["seq_id"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid _pdbx_poly_seq_scheme.seq_id: %s", row["seq_id"])
+		}
+		r := residueNumbering{
+			Entity:    cifRowValue(row, "entity_id"),
+			LabelAsym: cifRowValue(row, "asym_id"),
+			LabelSeq:  labelSeq,
+			AuthAsym:  cifRowValue(row, "pdb_strand_id"),
+			InsCode:   cifRowValue(row, "pdb_ins_code"),
+			Residue:   cifRowValue(row, "mon_id"),
+		}
+		// auth_seq_num is null for residues without coordinates
+		if authSeq, err := strconv.Atoi(cifRowValue(row, "auth_seq_num")); err == nil {
+			r.AuthSeq = &authSeq
+			r.Observed = true
+			offset := authSeq - labelSeq
+			r.Offset = &offset
+		}
+		residues = append(residues, r)
+	}
+	return residues, nil
+}
+
+// atomSiteNumbering reads the numbering of the observed polymer residues of the first model from _atom_site
+func atomSiteNumbering(block *CIFBlock) ([]residueNumbering, error) {
+	rows := block.Category("_atom_site")
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no _atom_site or _pdbx_poly_seq_scheme records found")
+	}
+	var residues []residueNumbering
+	seen := make(map[[2]string]bool)
+	firstModel := cifRowValue(rows[0], "pdbx_PDB_model_num")
+	for _, row := range rows {
+		if cifRowValue(row, "pdbx_PDB_model_num") != firstModel {
+			continue
+		}
+		// Non-polymer residues have no label_seq_id
+		labelSeqValue := cifRowValue(row, "label_seq_id")
+		if labelSeqValue == "" {
+			continue
+		}
+		key := [2]string{cifRowValue(row, "label_asym_id"), labelSeqValue}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		labelSeq, err := strconv.Atoi(labelSeqValue)
+		if err != nil {
+			return nil, fmt.Errorf("invalid _atom_site.label_seq_id: %s", labelSeqValue)
+		}
+		r := residueNumbering{
+			Entity:    cifRowValue(row, "label_entity_id"),
+			LabelAsym: key[0],
+			LabelSeq:  labelSeq,
+			AuthAsym:  cifRowValue(row, "auth_asym_id"),
+			InsCode:   cifRowValue(row, "pdbx_PDB_ins_code"),
+			Residue:   cifRowValue(row, "label_comp_id"),
+			Observed:  true,
+		}
+		if r.AuthAsym == "" {
+			r.AuthAsym = r.LabelAsym
+		}
+		authSeq, err := strconv.Atoi(cifRowValue(row, "auth_seq_id"))
+		if err != nil {
+			authSeq = labelSeq
+		}
+		offset := authSeq - labelSeq
+		r.AuthSeq, r.Offset = &authSeq, &offset
+		residues = append(residues, r)
+	}
+	return residues, nil
+}
+
+// writeNumberingOffsets writes the offset between author and label numbers of each chain, or its
+// range when the offset changes along the chain
+func writeNumberingOffsets(w io.Writer, residues []residueNumbering) {
+	var order []string
+	low := make(map[string]int)
+	high := make(map[string]int)
+	for _, r := range residues {
+		if r.Offset == nil {
+			continue
+		}
+		if _, seen := low[r.LabelAsym]; !seen {
+			order = append(order, r.LabelAsym)
+			low[r.LabelAsym], high[r.LabelAsym] = *r.Offset, *r.Offset
+		}
+		low[r.LabelAsym] = min(low[r.LabelAsym], *r.Offset)
+		high[r.LabelAsym] = max(high[r.LabelAsym], *r.Offset)
+	}
+	authAsym := make(map[string]string)
+	for _, r := range residues {
+		authAsym[r.LabelAsym] = r.AuthAsym
+	}
+	for _, chain := range order {
+		if low[chain] == high[chain] {
+			fmt.Fprintf(w, "Chain %s (label %s): auth_seq_id = label_seq_id %+d\n", authAsym[chain], chain, low[chain])
+		} else {
+			fmt.Fprintf(w, "Chain %s (label %s): auth_seq_id - label_seq_id varies from %d to %d\n", authAsym[chain], chain, low[chain], high[chain])
+		}
+	}
+}
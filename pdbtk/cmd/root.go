@@ -1,14 +1,25 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 )
 
 const Version = "0.1.1"
 
+var (
+	runTimeout time.Duration
+	// cancelTimeout releases the deadline of --timeout once the command is done
+	cancelTimeout context.CancelFunc = func() {}
+)
+
 var rootCmd = &cobra.Command{
 	Use:   "pdbtk",
 	Short: "PDB structure file manipulation toolkit",
@@ -16,11 +27,70 @@ var rootCmd = &cobra.Command{
 It provides various operations for extracting, filtering, and transforming protein structure data.
 
 Version: %s`, Version),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Flags and arguments have been parsed by now, so later errors are not usage errors
+		cmd.SilenceUsage = true
+		if err := validateProgressMode(); err != nil {
+			return err
+		}
+		if runTimeout < 0 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --timeout: %s", runTimeout))
+		}
+		// serve applies the timeout to each request instead
+		if runTimeout > 0 && cmd != serveCmd {
+			var ctx context.Context
+			ctx, cancelTimeout = context.WithTimeout(cmd.Context(), runTimeout)
+			cmd.SetContext(ctx)
+		}
+		resume, _ := cmd.Flags().GetBool("resume")
+		return openManifest(resume)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
-	return rootCmd.Execute()
+	// Errors are printed by printError so they can be emitted as JSON
+	rootCmd.SilenceErrors = true
+
+	// Interrupting pdbtk cancels the running command, which stops downloads and batch runs. A second
+	// interrupt exits at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	defer func() { cancelTimeout() }()
+
+	args, err := expandFilesFrom(os.Args[1:])
+	if err == nil {
+		rootCmd.SetArgs(args)
+		err = rootCmd.ExecuteContext(ctx)
+		if closeErr := closeManifest(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		printError("", err)
+	}
+
+	var summary *timingSummary
+	if showTimings {
+		summary = summarizeTimings()
+		currentReport.Timings = summary
+	}
+	if reportFile != "" {
+		if reportErr := writeReport(os.Args[1:], err); reportErr != nil {
+			printError(reportFile, reportErr)
+			if err == nil {
+				err = reportErr
+			}
+		}
+	}
+	if summary != nil {
+		printTimings(summary)
+	}
+	return err
 }
 
 var versionCmd = &cobra.Command{
@@ -33,16 +103,101 @@ var versionCmd = &cobra.Command{
 }
 
 func init() {
+	rootCmd.PersistentFlags().StringVar(&reportFile, "report", "", "Write a JSON report of per-input results and errors to this file")
+	rootCmd.PersistentFlags().BoolVar(&jsonErrors, "json-errors", false, "Print errors to stderr as JSON objects with error codes")
+	rootCmd.PersistentFlags().BoolVar(&strictMode, "strict", false, "Treat warnings (unknown residues, missing columns, missing chains) as errors")
+	rootCmd.PersistentFlags().BoolVar(&tolerantParsing, "tolerant", false, "Repair malformed coordinate records (short records, bad numbers, nonstandard columns) with warnings instead of failing")
+	rootCmd.PersistentFlags().BoolVar(&strictFormat, "strict-format", false, "Check every PDB record written against the fixed-column format and fail instead of writing malformed output")
+	rootCmd.PersistentFlags().StringVarP(&filesFrom, "files-from", "l", "", "Read the input files from this file, one path per line (- for stdin)")
+	rootCmd.PersistentFlags().StringVar(&manifestFile, "manifest", "", "Write one JSON line per input (status, output, chains, atoms, warnings, timing) to this file")
+	rootCmd.PersistentFlags().BoolVar(&noProvenance, "no-provenance", false, "Do not write the REMARK 1 records of the pdbtk operations that produced an output file")
+	rootCmd.PersistentFlags().DurationVar(&runTimeout, "timeout", 0, "Stop the command after this long, e.g. 30s or 5m (for serve: each request; default: no limit)")
+	rootCmd.PersistentFlags().BoolVar(&showTimings, "timings", false, "Report the wall time and peak heap memory of each phase (download, read, parse, filter, write) on stderr at the end of the run")
+	rootCmd.PersistentFlags().StringVar(&progressMode, "progress", "auto", "Show progress on stderr: auto (only on a terminal), always or never")
+
+	rootCmd.AddCommand(addHydrogensCmd)
+	rootCmd.AddCommand(addMissingAtomsCmd)
+	rootCmd.AddCommand(altLocSummaryCmd)
+	rootCmd.AddCommand(averageCmd)
+	rootCmd.AddCommand(canonicalizeCmd)
+	rootCmd.AddCommand(capTerminiCmd)
+	rootCmd.AddCommand(centerCmd)
+	rootCmd.AddCommand(chargesCmd)
+	rootCmd.AddCommand(clusterCmd)
+	rootCmd.AddCommand(collapseAltLocCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(consolidateWatersCmd)
+	rootCmd.AddCommand(contactNumberCmd)
+	rootCmd.AddCommand(dedupeCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(densityMapCmd)
+	rootCmd.AddCommand(detectLinksCmd)
+	rootCmd.AddCommand(ensembleCmd)
+	rootCmd.AddCommand(ensembleStatsCmd)
 	rootCmd.AddCommand(extractCmd)
+	rootCmd.AddCommand(extractLigandCmd)
 	rootCmd.AddCommand(extractSeqCmd)
+	rootCmd.AddCommand(fixElementsCmd)
 	rootCmd.AddCommand(getCmd)
+	rootCmd.AddCommand(importSSCmd)
+	rootCmd.AddCommand(isoelectricPointCmd)
+	rootCmd.AddCommand(ligandContactsCmd)
+	rootCmd.AddCommand(ligandInfoCmd)
+	rootCmd.AddCommand(mapSeqCmd)
+	rootCmd.AddCommand(mergeCmd)
+	rootCmd.AddCommand(metadataCmd)
+	rootCmd.AddCommand(metalSitesCmd)
+	rootCmd.AddCommand(modifiedResiduesCmd)
+	rootCmd.AddCommand(molecularWeightCmd)
+	rootCmd.AddCommand(mutateCmd)
+	rootCmd.AddCommand(packCellCmd)
+	rootCmd.AddCommand(radiusOfGyrationCmd)
+	rootCmd.AddCommand(ramachandranCmd)
+	rootCmd.AddCommand(removeHydrogensCmd)
+	rootCmd.AddCommand(removeWatersCmd)
 	rootCmd.AddCommand(renameChainCmd)
+	rootCmd.AddCommand(renumberModelsCmd)
 	rootCmd.AddCommand(renumberResiduesCmd)
+	rootCmd.AddCommand(residueNumberingCmd)
+	rootCmd.AddCommand(runPluginCmd)
+	rootCmd.AddCommand(searchSeqCmd)
+	rootCmd.AddCommand(setCellCmd)
+	rootCmd.AddCommand(setProtonationCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(siftsCmd)
+	rootCmd.AddCommand(solventShellCmd)
+	rootCmd.AddCommand(sortCmd)
+	rootCmd.AddCommand(splitEntitiesCmd)
+	rootCmd.AddCommand(symmetryOpsCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(stoichiometryCmd)
+	rootCmd.AddCommand(tidyCmd)
+	rootCmd.AddCommand(trimSidechainsCmd)
+	rootCmd.AddCommand(uniprotFeaturesCmd)
+	rootCmd.AddCommand(validateCmd)
 	rootCmd.AddCommand(versionCmd)
+	registerCompletions(rootCmd)
 }
 
-// CheckFileExists checks if a file exists and returns an error if it doesn't
+// CheckFileExists checks if a file (or s3:// or gs:// object) exists and returns an error if it doesn't.
+// For an archive member (archive:member) only the archive is checked.
 func CheckFileExists(filename string) error {
+	return CheckFileExistsContext(context.Background(), filename)
+}
+
+// CheckFileExistsContext is CheckFileExists with a context that cancels the check of an object
+func CheckFileExistsContext(ctx context.Context, filename string) error {
+	if archive, _, ok := splitArchiveMember(filename); ok {
+		filename = archive
+	}
+	if isObjectURI(filename) {
+		if err := statObject(ctx, filename); errors.Is(err, errNotFound) {
+			return fmt.Errorf("file does not exist: %s", filename)
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		return fmt.Errorf("file does not exist: %s", filename)
 	}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// pluginPathEnv names the environment variable with the directories searched for plugins before PATH
+const pluginPathEnv = "PDBTK_PLUGIN_PATH"
+
+// pluginContextEnv names the environment variable that passes the JSON context to a plugin
+const pluginContextEnv = "PDBTK_CONTEXT"
+
+// pluginPrefix is the prefix of the executable names of plugins: plugin NAME is pdbtk-NAME
+const pluginPrefix = "pdbtk-"
+
+var (
+	pluginOutput string
+	pluginArgs   []string
+	pluginList   bool
+	pluginBatch  batchOptions
+)
+
+// pluginContext is the JSON context passed to a plugin with each input
+type pluginContext struct {
+	Step         string   `json:"step"`
+	Input        string   `json:"input"`
+	Args         []string `json:"args"`
+	Strict       bool     `json:"strict"`
+	PdbtkVersion string   `json:"pdbtk_version"`
+}
+
+var runPluginCmd = &cobra.Command{
+	Use:   "run-plugin NAME [flags] [input_file...]",
+	Short: "Run an external program as a processing step",
+	Long: `Run a site-specific program as a pdbtk processing step, so that it can be used in pdbtk pipelines and
+batch runs like the built-in commands.
+
+A plugin is an executable named pdbtk-NAME, found in the directories of the PDBTK_PLUGIN_PATH environment
+variable (separated like PATH) or else on the PATH; NAME may also be the path of an executable. The
+plugin reads a PDB file on stdin and writes the processed PDB file to stdout, with messages on stderr.
+Its context is passed as a JSON object in the PDBTK_CONTEXT environment variable:
+
+  {"step": "NAME", "input": "1a02.pdb", "args": [...], "strict": false, "pdbtk_version": "..."}
+
+where input is empty for stdin and args are the --arg values, which are also passed as the plugin's
+command-line arguments. A plugin that exits with a non-zero status fails the input, and its output must
+be a PDB file with coordinates. The output is written like that of other commands, with a REMARK 1
+provenance record, and batch flags (--outdir, --manifest, --report) work as usual.
+--list lists the plugins that can be found.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Run the plugin pdbtk-protonate on a file
+  pdbtk run-plugin protonate 1a02.pdb --output 1a02_protonated.pdb
+
+  # Pass arguments to the plugin and process a directory
+  pdbtk run-plugin minimize --arg --steps --arg 500 --outdir minimized/ structures/*.pdb
+
+  # List the available plugins
+  pdbtk run-plugin --list`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if !pluginList && len(args) == 0 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("a plugin name is required"))
+		}
+		return nil
+	},
+	RunE: runRunPlugin,
+}
+
+func init() {
+	runPluginCmd.Flags().StringVarP(&pluginOutput, "output", "o", "", "Output file (default: stdout)")
+	runPluginCmd.Flags().StringArrayVar(&pluginArgs, "arg", nil, "Argument to pass to the plugin (repeat for several)")
+	runPluginCmd.Flags().BoolVar(&pluginList, "list", false, "List the available plugins")
+	addBatchFlags(runPluginCmd, &pluginBatch, "{name}")
+}
+
+func runRunPlugin(cmd *cobra.Command, args []string) error {
+	if pluginList {
+		for _, plugin := range findPlugins() {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\n", plugin[0], plugin[1])
+		}
+		return nil
+	}
+	name := args[0]
+	executable, err := pluginExecutable(name)
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	return runBatch(ctx, args[1:], pluginOutput, pluginBatch, func(inputFile string, writer io.Writer) error {
+		content, err := readInputContent(ctx, inputFile)
+		if err != nil {
+			return err
+		}
+		context, err := json.Marshal(pluginContext{Step: name, Input: inputFile, Args: append([]string{}, pluginArgs...), Strict: strictMode, PdbtkVersion: Version})
+		if err != nil {
+			return err
+		}
+
+		var stdout bytes.Buffer
+		plugin := exec.CommandContext(ctx, executable, pluginArgs...)
+		plugin.Stdin = bytes.NewReader(content)
+		plugin.Stdout = &stdout
+		plugin.Stderr = cmd.ErrOrStderr()
+		plugin.Env = append(os.Environ(), pluginContextEnv+"="+string(context))
+		killPluginOnCancel(plugin)
+		// Processes started by the plugin may keep its output open after it is killed
+		plugin.WaitDelay = time.Second
+		if err := plugin.Run(); err != nil {
+			if ctx.Err() != nil {
+				return contextError(ctx.Err())
+			}
+			return withCode(ErrCodeGeneric, fmt.Errorf("plugin %s failed: %v", name, err))
+		}
+
+		file, err := ParsePDBRecordsContext(ctx, &stdout)
+		if ctx.Err() != nil {
+			return contextError(ctx.Err())
+		}
+		if err != nil {
+			return withCode(ErrCodeParse, fmt.Errorf("plugin %s wrote an invalid PDB file: %v", name, err))
+		}
+		if len(file.Atoms) == 0 {
+			return withCode(ErrCodeParse, fmt.Errorf("plugin %s wrote no coordinate records", name))
+		}
+		return writePDBRecords(file, writer, recordCommandLine(cmd, []string{name}, inputFile))
+	})
+}
+
+// pluginDirectories returns the directories searched for plugins, in order
+func pluginDirectories() []string {
+	var dirs []string
+	for _, list := range []string{os.Getenv(pluginPathEnv), os.Getenv("PATH")} {
+		for _, dir := range filepath.SplitList(list) {
+			if dir != "" {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// isExecutable reports whether path is a regular file that can be executed
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+// pluginExecutable returns the path of the executable of a plugin, given by name or path
+func pluginExecutable(name string) (string, error) {
+	if strings.ContainsRune(name, filepath.Separator) {
+		if !isExecutable(name) {
+			return "", withCode(ErrCodeInputNotFound, fmt.Errorf("plugin %s is not an executable file", name))
+		}
+		return name, nil
+	}
+	for _, dir := range pluginDirectories() {
+		if path := filepath.Join(dir, pluginPrefix+name); isExecutable(path) {
+			return path, nil
+		}
+	}
+	return "", withCode(ErrCodeInputNotFound, fmt.Errorf("plugin %s not found: no executable %s%s in %s or PATH", name, pluginPrefix, name, pluginPathEnv))
+}
+
+// findPlugins returns the name and path of each plugin that can be found, sorted by name. A plugin
+// found in more than one directory is run from the first.
+func findPlugins() [][2]string {
+	found := make(map[string]string)
+	for _, dir := range pluginDirectories() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := strings.TrimPrefix(entry.Name(), pluginPrefix)
+			path := filepath.Join(dir, entry.Name())
+			if _, ok := found[name]; ok || !strings.HasPrefix(entry.Name(), pluginPrefix) || name == "" || !isExecutable(path) {
+				continue
+			}
+			found[name] = path
+		}
+	}
+	var plugins [][2]string
+	for name, path := range found {
+		plugins = append(plugins, [2]string{name, path})
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i][0] < plugins[j][0] })
+	return plugins
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package cmd
+
+import "os/exec"
+
+// killPluginOnCancel kills a plugin when the run is cancelled. Processes it started are left running,
+// but no longer hold up pdbtk once their output is abandoned.
+func killPluginOnCancel(plugin *exec.Cmd) {
+	plugin.Cancel = func() error {
+		return plugin.Process.Kill()
+	}
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killPluginOnCancel runs a plugin in a process group of its own, which is killed as a whole when the
+// run is cancelled, so the processes the plugin started stop with it
+func killPluginOnCancel(plugin *exec.Cmd) {
+	plugin.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	plugin.Cancel = func() error {
+		return syscall.Kill(-plugin.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	searchChain    string
+	searchIdentity float64
+	searchEvalue   float64
+	searchMaxHits  int
+	searchFormat   string
+	searchOutput   string
+	searchFetch    bool
+	searchOutdir   string
+)
+
+// dnaResidues and rnaResidues are the residue names of standard DNA and RNA nucleotides
+var (
+	dnaResidues = map[string]bool{"DA": true, "DC": true, "DG": true, "DT": true, "DI": true, "DN": true}
+	rnaResidues = map[string]bool{"A": true, "C": true, "G": true, "U": true, "I": true, "N": true}
+)
+
+var searchSeqCmd = &cobra.Command{
+	Use:   "search-seq [flags] [input_file]",
+	Short: "Search the RCSB PDB for chains similar to a chain of a structure",
+	Long: `Extract the sequence of a chain of a local structure (the first chain by default) and search the
+RCSB PDB for similar polymer chains with the RCSB Search API sequence service (MMseqs2).
+
+The hits are reported with their sequence identity, e-value, bit score and the aligned ranges of
+the query and the hit, best hits first, as a tab-separated table or as JSON with --format json.
+With --fetch, the PDB entries of the hits are downloaded into --outdir as {pdb_code}.pdb.
+The search is sent to https://search.rcsb.org; set PDBTK_RCSB_SEARCH_URL to use a mirror.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Find chains at least 90% identical to chain A
+  pdbtk search-seq --chain A 1a02.pdb
+
+  # Find remote homologues and report them as JSON
+  pdbtk search-seq --chain A --identity 0.3 --evalue 1e-5 --format json 1a02.pdb
+
+  # Download the entries of close homologues
+  pdbtk search-seq --chain A --identity 0.95 --fetch --outdir homologues/ 1a02.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSearchSeq,
+}
+
+func init() {
+	searchSeqCmd.Flags().StringVarP(&searchChain, "chain", "c", "", "Chain whose sequence is searched (default: first polymer chain)")
+	searchSeqCmd.Flags().Float64Var(&searchIdentity, "identity", 0.9, "Minimum sequence identity of hits (0-1)")
+	searchSeqCmd.Flags().Float64Var(&searchEvalue, "evalue", 0.1, "Maximum e-value of hits")
+	searchSeqCmd.Flags().IntVar(&searchMaxHits, "max-hits", 100, "Maximum number of hits to report")
+	searchSeqCmd.Flags().StringVarP(&searchFormat, "format", "f", "tsv", "Output format: tsv or json")
+	searchSeqCmd.Flags().StringVarP(&searchOutput, "output", "o", "", "Output file (default: stdout)")
+	searchSeqCmd.Flags().BoolVar(&searchFetch, "fetch", false, "Download the PDB entries of the hits")
+	searchSeqCmd.Flags().StringVar(&searchOutdir, "outdir", "", "Output directory for --fetch (default: current directory)")
+}
+
+// sequenceHit is a polymer chain found by a sequence search
+type sequenceHit struct {
+	Identifier      string  `json:"identifier"`
+	Entry           string  `json:"entry"`
+	Chain           string  `json:"chain"`
+	Identity        float64 `json:"identity"`
+	Evalue          float64 `json:"evalue"`
+	Bitscore        float64 `json:"bitscore"`
+	AlignmentLength int     `json:"alignment_length"`
+	QueryStart      int     `json:"query_start"`
+	QueryEnd        int     `json:"query_end"`
+	SubjectStart    int     `json:"subject_start"`
+	SubjectEnd      int     `json:"subject_end"`
+}
+
+// rcsbSearchResponse is the part of the RCSB Search API response used by search-seq
+type rcsbSearchResponse struct {
+	TotalCount int `json:"total_count"`
+	ResultSet  []struct {
+		Identifier string `json:"identifier"`
+		Services   []struct {
+			Nodes []struct {
+				MatchContext []struct {
+					SequenceIdentity float64 `json:"sequence_identity"`
+					Evalue           float64 `json:"evalue"`
+					Bitscore         float64 `json:"bitscore"`
+					AlignmentLength  int     `json:"alignment_length"`
+					QueryBeg         int     `json:"query_beg"`
+					QueryEnd         int     `json:"query_end"`
+					SubjectBeg       int     `json:"subject_beg"`
+					SubjectEnd       int     `json:"subject_end"`
+				} `json:"match_context"`
+			} `json:"nodes"`
+		} `json:"services"`
+	} `json:"result_set"`
+}
+
+func runSearchSeq(cmd *cobra.Command, args []string) error {
+	if searchFormat != "tsv" && searchFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", searchFormat))
+	}
+	if searchChain != "" && len(searchChain) != 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("chain ID must be a single character, got: %s", searchChain))
+	}
+	if searchIdentity < 0 || searchIdentity > 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --identity: %g (must be between 0 and 1)", searchIdentity))
+	}
+	if searchEvalue <= 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --evalue: %g (must be positive)", searchEvalue))
+	}
+	if searchMaxHits < 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --max-hits: %d (must be at least 1)", searchMaxHits))
+	}
+	if searchOutdir != "" && !searchFetch {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--outdir can only be used with --fetch"))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	chainIDs, sequences := observedChainSequences(file)
+	if len(chainIDs) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no polymer chains found"))
+	}
+	chainID := chainIDs[0]
+	if searchChain != "" {
+		chainID = searchChain[0]
+		if sequences[chainID] == "" {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("chain not found: %s", searchChain))
+		}
+	}
+
+	sequenceType := chainSequenceType(file, chainID)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Searching %s sequence of chain %c (%d residues)\n", sequenceType, chainID, len(sequences[chainID]))
+	hits, total, err := searchSequence(cmd.Context(), sequences[chainID], sequenceType)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Found %d matching chains", total)
+	if total > len(hits) {
+		fmt.Fprintf(cmd.ErrOrStderr(), ", reporting the best %d", len(hits))
+	}
+	fmt.Fprintln(cmd.ErrOrStderr())
+
+	err = writeOutput(cmd.Context(), searchOutput, func(w io.Writer) error {
+		if searchFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(hits)
+		}
+		fmt.Fprintln(w, "identifier\tentry\tchain\tidentity\tevalue\tbitscore\talignment_length\tquery_start\tquery_end\tsubject_start\tsubject_end")
+		for _, h := range hits {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.3f\t%g\t%g\t%d\t%d\t%d\t%d\t%d\n", h.Identifier, h.Entry, h.Chain, h.Identity, h.Evalue,
+				h.Bitscore, h.AlignmentLength, h.QueryStart, h.QueryEnd, h.SubjectStart, h.SubjectEnd)
+		}
+		return nil
+	})
+	if err != nil || !searchFetch {
+		return err
+	}
+	return fetchHits(cmd.Context(), hits)
+}
+
+// chainSequenceType returns the RCSB sequence type (protein, dna or rna) of a chain of the first model,
+// from the residue names of most of its residues
+func chainSequenceType(file *PDBFile, chainID byte) string {
+	models := file.Models()
+	counts := make(map[string]int)
+	for _, residue := range groupResidues(file.Atoms) {
+		key := residue[0].Residue()
+		if key.Model != models[0] || key.ChainID != chainID {
+			continue
+		}
+		switch {
+		case dnaResidues[key.ResName]:
+			counts["dna"]++
+		case rnaResidues[key.ResName]:
+			counts["rna"]++
+		default:
+			counts["protein"]++
+		}
+	}
+	sequenceType := "protein"
+	for _, t := range []string{"dna", "rna"} {
+		if counts[t] > counts[sequenceType] {
+			sequenceType = t
+		}
+	}
+	return sequenceType
+}
+
+// searchSequence searches the RCSB PDB for polymer chains similar to sequence, returning the best hits
+// and the total number of matching chains
+func searchSequence(ctx context.Context, sequence, sequenceType string) ([]sequenceHit, int, error) {
+	request := map[string]interface{}{
+		"query": map[string]interface{}{
+			"type":    "terminal",
+			"service": "sequence",
+			"parameters": map[string]interface{}{
+				"evalue_cutoff":   searchEvalue,
+				"identity_cutoff": searchIdentity,
+				"sequence_type":   sequenceType,
+				"value":           sequence,
+			},
+		},
+		"request_options": map[string]interface{}{
+			"scoring_strategy": "sequence",
+			"paginate":         map[string]int{"start": 0, "rows": searchMaxHits},
+		},
+		"return_type": "polymer_instance",
+	}
+	var response rcsbSearchResponse
+	if err := postJSON(ctx, rcsbSearchURL()+"/rcsbsearch/v2/query", request, &response); err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]sequenceHit, 0, len(response.ResultSet))
+	for _, result := range response.ResultSet {
+		hit := sequenceHit{Identifier: result.Identifier, Entry: result.Identifier}
+		if entry, chain, ok := strings.Cut(result.Identifier, "."); ok {
+			hit.Entry, hit.Chain = entry, chain
+		}
+		if len(result.Services) > 0 && len(result.Services[0].Nodes) > 0 && len(result.Services[0].Nodes[0].MatchContext) > 0 {
+			match := result.Services[0].Nodes[0].MatchContext[0]
+			hit.Identity = match.SequenceIdentity
+			hit.Evalue = match.Evalue
+			hit.Bitscore = match.Bitscore
+			hit.AlignmentLength = match.AlignmentLength
+			hit.QueryStart, hit.QueryEnd = match.QueryBeg, match.QueryEnd
+			hit.SubjectStart, hit.SubjectEnd = match.SubjectBeg, match.SubjectEnd
+		}
+		hits = append(hits, hit)
+	}
+	return hits, response.TotalCount, nil
+}
+
+// fetchHits downloads the PDB entries of the hits into --outdir
+func fetchHits(ctx context.Context, hits []sequenceHit) error {
+	if searchOutdir != "" {
+		if err := makeOutputDir(searchOutdir); err != nil {
+			return err
+		}
+	}
+	seen := make(map[string]bool)
+	failures := batchFailures{noun: "downloads"}
+	total := 0
+	for _, hit := range hits {
+		if seen[hit.Entry] {
+			continue
+		}
+		seen[hit.Entry] = true
+		total++
+		outputFile := joinOutputPath(searchOutdir, hit.Entry+".pdb")
+		err := downloadEntry(ctx, hit.Entry, "pdb", outputFile)
+		recordResult(hit.Entry, outputFile, err)
+		failures.add(hit.Entry, err)
+	}
+	return failures.err(total)
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ssElement is a helix or strand given by the HELIX and SHEET records of a PDB file
+type ssElement struct {
+	Type       byte // 'H' for helices, 'E' for strands
+	Number     int  // position among the elements of the same type of the chain, from 1
+	ChainID    byte
+	Start, End residuePosition
+}
+
+// String formats an element the way it is selected with --ss-element, e.g. A:H3
+func (e ssElement) String() string {
+	return fmt.Sprintf("%c:%c%d", e.ChainID, e.Type, e.Number)
+}
+
+// parseSecondaryStructure returns the helices and strands of the HELIX and SHEET records, numbered per
+// chain in the order of the records. Strands listed in several sheets are counted once.
+func parseSecondaryStructure(header []string) []ssElement {
+	var elements []ssElement
+	counts := make(map[[2]byte]int)
+	seen := make(map[ssElement]bool)
+	for _, line := range header {
+		var element ssElement
+		switch {
+		case strings.HasPrefix(line, "HELIX ") && len(line) >= 38:
+			element = ssElement{Type: 'H', ChainID: line[19],
+				Start: recordPosition(line[21:25], line[25]), End: recordPosition(line[33:37], line[37])}
+		case strings.HasPrefix(line, "SHEET ") && len(line) >= 38:
+			element = ssElement{Type: 'E', ChainID: line[21],
+				Start: recordPosition(line[22:26], line[26]), End: recordPosition(line[33:37], line[37])}
+		default:
+			continue
+		}
+		if seen[element] {
+			continue
+		}
+		seen[element] = true
+		key := [2]byte{element.ChainID, element.Type}
+		counts[key]++
+		element.Number = counts[key]
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+// recordPosition parses the residue number and insertion code columns of a record
+func recordPosition(resSeq string, iCode byte) residuePosition {
+	n, _ := strconv.Atoi(strings.TrimSpace(resSeq))
+	return residuePosition{ResSeq: n, ICode: iCode}
+}
+
+// contains reports whether a residue lies within the element
+func (e ssElement) contains(key ResidueKey) bool {
+	iCode := key.ICode
+	if iCode == 0 {
+		iCode = ' '
+	}
+	p := residuePosition{ResSeq: key.ResSeq, ICode: iCode}
+	return key.ChainID == e.ChainID && !p.before(e.Start) && !e.End.before(p)
+}
+
+// ssElementPattern matches an element reference such as A:H3 or B:E1
+var ssElementPattern = regexp.MustCompile(`^(.):([HE])(\d+)$`)
+
+// ssSelection selects residues by secondary structure type (H, E or C for coil) or by numbered element
+type ssSelection struct {
+	Types    map[byte]bool
+	Elements []string // element references, e.g. A:H3
+}
+
+// parseSSSelection parses the --ss types (H,E,C) and --ss-element references (A:H3,B:E1)
+func parseSSSelection(types, elements string) (*ssSelection, error) {
+	selection := &ssSelection{Types: make(map[byte]bool)}
+	if types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.ToUpper(strings.TrimSpace(t))
+			if t != "H" && t != "E" && t != "C" {
+				return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --ss: %s (must be H, E or C)", t))
+			}
+			selection.Types[t[0]] = true
+		}
+	}
+	if elements != "" {
+		for _, ref := range strings.Split(elements, ",") {
+			ref = strings.TrimSpace(ref)
+			match := ssElementPattern.FindStringSubmatch(ref)
+			if match == nil || match[3] == "0" {
+				return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --ss-element: %s (expected a chain, H or E and an element number, e.g. A:H3)", ref))
+			}
+			selection.Elements = append(selection.Elements, ref)
+		}
+	}
+	return selection, nil
+}
+
+// filter keeps the atoms of the polymer residues that match the selection, using the HELIX and SHEET
+// records of header; residues for which keep returns true are kept whatever their secondary structure.
+// Polymer residues outside every helix and strand are coil.
+func (s *ssSelection) filter(header []string, atoms []*AtomRecord, polymer, keep func(ResidueKey) bool) ([]*AtomRecord, error) {
+	elements := parseSecondaryStructure(header)
+	if len(elements) == 0 {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("no HELIX or SHEET records found"))
+	}
+	byRef := make(map[string]ssElement)
+	for _, element := range elements {
+		byRef[element.String()] = element
+	}
+	var selected []ssElement
+	var missing []string
+	for _, ref := range s.Elements {
+		if element, ok := byRef[ref]; ok {
+			selected = append(selected, element)
+		} else {
+			missing = append(missing, ref)
+		}
+	}
+	if len(missing) > 0 {
+		if err := warn("secondary structure elements not found: %s", strings.Join(missing, ",")); err != nil {
+			return nil, err
+		}
+	}
+
+	matches := func(key ResidueKey) bool {
+		for _, element := range selected {
+			if element.contains(key) {
+				return true
+			}
+		}
+		ssType := byte('C')
+		for _, element := range elements {
+			if element.contains(key) {
+				ssType = element.Type
+				break
+			}
+		}
+		return s.Types[ssType]
+	}
+
+	var filtered []*AtomRecord
+	for _, atom := range atoms {
+		key := atom.Residue()
+		if keep(key) || (polymer(key) && matches(key)) {
+			filtered = append(filtered, atom)
+		}
+	}
+	return filtered, nil
+}
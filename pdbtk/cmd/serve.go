@@ -0,0 +1,270 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	serveAddr      string
+	serveMaxUpload int64
+)
+
+// servedCommands are the commands exposed as endpoints by serve
+var servedCommands = []*cobra.Command{extractCmd, extractSeqCmd}
+
+// serveDeniedFlags are the flags that cannot be set through serve, since they write to the server's file system
+var serveDeniedFlags = map[string]bool{"output": true, "outdir": true, "name-template": true, "map-output": true}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve [flags]",
+	Short: "Serve pdbtk operations over HTTP",
+	Long: `Run an HTTP server that exposes pdbtk commands as endpoints, so web applications and workflow
+engines can use pdbtk without spawning processes.
+
+Endpoints:
+  GET  /health            returns {"status": "ok", "version": ...}
+  POST /v1/extract        runs extract and returns the PDB file
+  POST /v1/extract-seq    runs extract-seq and returns the FASTA file
+
+Only extract and extract-seq are served: pdbtk has no info or convert commands to expose.
+
+The structure is sent as the request body, or as the "file" field of a multipart/form-data upload,
+or is downloaded from the RCSB PDB when the "pdb" query parameter gives a PDB code (e.g. ?pdb=1A02).
+The other query parameters are the command's flags, e.g. /v1/extract?chains=A,B&altloc=first;
+flags that write files on the server (--output, --outdir, --map-output) are not accepted.
+
+Errors are returned as JSON objects with the same codes as --json-errors, with status 400 for
+invalid arguments, 404 when nothing matched, 422 for unreadable structures, 502 for download
+failures and 503 for requests that ran past the global --timeout, which applies to each request.
+A request is abandoned when its client disconnects. Requests are processed one at a time, and
+interrupting the server lets the request in progress stop before it exits.
+
+Examples:
+  # Serve on the default address (127.0.0.1:8080)
+  pdbtk serve
+
+  # Extract chain A of an uploaded file
+  curl --data-binary @1a02.pdb 'http://127.0.0.1:8080/v1/extract?chains=A'
+
+  # Extract the sequences of an entry fetched from the RCSB PDB
+  curl -X POST 'http://127.0.0.1:8080/v1/extract-seq?pdb=1A02'`,
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8080", "Address to listen on")
+	serveCmd.Flags().Int64Var(&serveMaxUpload, "max-upload", 100, "Maximum size of an uploaded structure in MB")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	if serveMaxUpload <= 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --max-upload: %d (must be positive)", serveMaxUpload))
+	}
+
+	server := &commandServer{maxUpload: serveMaxUpload << 20}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok", "version": Version})
+	})
+	for _, c := range servedCommands {
+		mux.Handle("/v1/"+c.Name(), server.handler(c))
+	}
+
+	// Requests are cancelled along with the server, which shuts down once they have stopped
+	httpServer := &http.Server{
+		Addr:        serveAddr,
+		Handler:     mux,
+		BaseContext: func(net.Listener) context.Context { return cmd.Context() },
+	}
+	go func() {
+		<-cmd.Context().Done()
+		httpServer.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "Serving on http://%s\n", serveAddr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return withCode(ErrCodeNetwork, fmt.Errorf("server failed: %v", err))
+	}
+	return nil
+}
+
+// commandServer runs commands for HTTP requests. Commands keep their flag values in package
+// variables, so requests are processed one at a time.
+type commandServer struct {
+	mu        sync.Mutex
+	maxUpload int64
+}
+
+// handler returns the HTTP handler running command c
+func (s *commandServer) handler(c *cobra.Command) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeServeError(w, withCode(ErrCodeInvalidArgument, fmt.Errorf("method %s not allowed, use POST", r.Method)))
+			return
+		}
+		if runTimeout > 0 {
+			ctx, cancel := context.WithTimeout(r.Context(), runTimeout)
+			defer cancel()
+			r = r.WithContext(ctx)
+		}
+
+		dir, err := os.MkdirTemp("", "pdbtk-serve-")
+		if err != nil {
+			writeServeError(w, withCode(ErrCodeIO, err))
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+		inputFile, err := saveRequestInput(r, dir)
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+		flags, err := requestFlags(c, r)
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+
+		outputFile := filepath.Join(dir, "output")
+		s.mu.Lock()
+		err = runServedCommand(r.Context(), c, append(flags, "--output", outputFile, inputFile))
+		currentReport = &runReport{Results: make([]inputResult, 0)}
+		s.mu.Unlock()
+		if err != nil {
+			writeServeError(w, err)
+			return
+		}
+
+		content, err := os.ReadFile(outputFile)
+		if err != nil {
+			writeServeError(w, withCode(ErrCodeIO, fmt.Errorf("failed to read output: %v", err)))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(content)
+	})
+}
+
+// saveRequestInput writes the structure of a request to dir and returns its path: the request body, the
+// "file" field of a multipart upload, or the entry given by the "pdb" query parameter
+func saveRequestInput(r *http.Request, dir string) (string, error) {
+	if pdbCode := r.URL.Query().Get("pdb"); pdbCode != "" {
+		pdbCode = strings.ToUpper(pdbCode)
+		if len(pdbCode) != 4 {
+			return "", withCode(ErrCodeInvalidArgument, fmt.Errorf("PDB code must be exactly 4 characters, got: %s", pdbCode))
+		}
+		inputFile := filepath.Join(dir, pdbCode+".pdb")
+		return inputFile, downloadEntry(r.Context(), pdbCode, "pdb", inputFile)
+	}
+
+	name := "input.pdb"
+	body := io.Reader(r.Body)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return "", withCode(ErrCodeInvalidArgument, fmt.Errorf("missing \"file\" field in upload: %v", err))
+		}
+		defer file.Close()
+		if base := filepath.Base(header.Filename); base != "." && base != string(filepath.Separator) {
+			name = base
+		}
+		body = file
+	}
+
+	inputFile := filepath.Join(dir, name)
+	out, err := os.Create(inputFile)
+	if err != nil {
+		return "", withCode(ErrCodeIO, err)
+	}
+	defer out.Close()
+	n, err := io.Copy(out, body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "", withCode(ErrCodeInvalidArgument, fmt.Errorf("upload larger than %d MB", tooLarge.Limit>>20))
+	}
+	if err != nil {
+		return "", withCode(ErrCodeIO, fmt.Errorf("failed to read upload: %v", err))
+	}
+	if n == 0 {
+		return "", withCode(ErrCodeInvalidArgument, fmt.Errorf("no structure in request: send it as the body, as a \"file\" upload or with ?pdb="))
+	}
+	return inputFile, nil
+}
+
+// requestFlags converts the query parameters of a request (other than pdb) to command-line flags of c
+func requestFlags(c *cobra.Command, r *http.Request) ([]string, error) {
+	var flags []string
+	for name, values := range r.URL.Query() {
+		if name == "pdb" {
+			continue
+		}
+		if c.Flags().Lookup(name) == nil || serveDeniedFlags[name] {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("unsupported parameter for %s: %s", c.Name(), name))
+		}
+		for _, value := range values {
+			flags = append(flags, "--"+name+"="+value)
+		}
+	}
+	return flags, nil
+}
+
+// runServedCommand runs c in-process with args and the context of the request, after resetting its
+// flags to their defaults
+func runServedCommand(ctx context.Context, c *cobra.Command, args []string) error {
+	c.Flags().VisitAll(func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+	if err := c.ParseFlags(args); err != nil {
+		return withCode(ErrCodeInvalidArgument, err)
+	}
+	positional := c.Flags().Args()
+	if err := c.ValidateArgs(positional); err != nil {
+		return withCode(ErrCodeInvalidArgument, err)
+	}
+	c.SetContext(ctx)
+	return c.RunE(c, positional)
+}
+
+// writeServeError writes err as a JSON error response with a status code matching its error code
+func writeServeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch errorCode(err) {
+	case ErrCodeInvalidArgument, ErrCodeInputNotFound, ErrCodeUnsupportedFormat, ErrCodeStrict:
+		status = http.StatusBadRequest
+	case ErrCodeNoMatch:
+		status = http.StatusNotFound
+	case ErrCodeParse:
+		status = http.StatusUnprocessableEntity
+	case ErrCodeNetwork:
+		status = http.StatusBadGateway
+	case ErrCodeCanceled:
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(newReportError("", err))
+}
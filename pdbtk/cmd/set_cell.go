@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	setCellOutput     string
+	setCellCell       string
+	setCellSpaceGroup string
+	setCellZ          int
+	setCellRemove     bool
+	setCellBatch      batchOptions
+)
+
+var setCellCmd = &cobra.Command{
+	Use:   "set-cell [flags] [input_file...]",
+	Short: "Create or edit the unit cell and space group (CRYST1)",
+	Long: `Create or edit the CRYST1 record of a structure: the unit cell and space group that MD box setup
+and crystallographic tools read.
+
+--cell sets the edge lengths a, b and c (Å) and, optionally, the angles alpha, beta and gamma (degrees,
+90 by default), e.g. 60,60,60 for a cubic box or 40.1,75.3,80.2,90,105.5,90. When the cell is set, the
+SCALEn records are recalculated from it and ORIGXn records are written as the identity. --space-group
+(the Hermann-Mauguin symbol, e.g. "P 21 21 21") and --z (the number of polymeric chains per unit cell)
+change the CRYST1 record on their own; a new cell is P 1 with Z = 1 unless they are given.
+--remove deletes the CRYST1, ORIGXn and SCALEn records.
+
+The crystal records of the input are kept by every command that writes PDB files.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Set a 60 Å cubic box for an MD simulation
+  pdbtk set-cell --cell 60,60,60 protein.pdb --output boxed.pdb
+
+  # Set a monoclinic cell and its space group
+  pdbtk set-cell --cell 40.1,75.3,80.2,90,105.5,90 --space-group "P 1 21 1" --z 2 model.pdb
+
+  # Fix the space group of a structure
+  pdbtk set-cell --space-group "P 21 21 21" 1a02.pdb
+
+  # Remove the crystal records
+  pdbtk set-cell --remove 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runSetCell,
+}
+
+func init() {
+	setCellCmd.Flags().StringVarP(&setCellOutput, "output", "o", "", "Output file (default: stdout)")
+	setCellCmd.Flags().StringVar(&setCellCell, "cell", "", "Unit cell a,b,c[,alpha,beta,gamma] in Å and degrees (angles default to 90)")
+	setCellCmd.Flags().StringVar(&setCellSpaceGroup, "space-group", "", "Space group symbol, e.g. \"P 21 21 21\" (default for a new cell: P 1)")
+	setCellCmd.Flags().IntVar(&setCellZ, "z", 0, "Number of polymeric chains per unit cell (default for a new cell: 1)")
+	setCellCmd.Flags().BoolVar(&setCellRemove, "remove", false, "Remove the CRYST1, ORIGXn and SCALEn records")
+	addBatchFlags(setCellCmd, &setCellBatch, "{name}")
+}
+
+func runSetCell(cmd *cobra.Command, args []string) error {
+	if setCellRemove && (setCellCell != "" || setCellSpaceGroup != "" || setCellZ != 0) {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--remove cannot be combined with --cell, --space-group or --z"))
+	}
+	if !setCellRemove && setCellCell == "" && setCellSpaceGroup == "" && setCellZ == 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("at least one of --cell, --space-group, --z or --remove must be specified"))
+	}
+	if setCellZ < 0 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --z: %d (must be positive)", setCellZ))
+	}
+	if len(setCellSpaceGroup) > 11 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --space-group: %q (at most 11 characters)", setCellSpaceGroup))
+	}
+	var cell *unitCell
+	if setCellCell != "" {
+		var err error
+		if cell, err = parseCellSpec(setCellCell); err != nil {
+			return err
+		}
+	}
+
+	return runBatch(cmd.Context(), args, setCellOutput, setCellBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		header, err := setCellHeader(file.Header, cell)
+		if err != nil {
+			return err
+		}
+		return writePDBRecords(&PDBFile{Header: header, Atoms: file.Atoms, Conect: file.Conect}, writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// parseCellSpec parses a --cell value: a,b,c or a,b,c,alpha,beta,gamma
+func parseCellSpec(spec string) (*unitCell, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) != 3 && len(parts) != 6 {
+		return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --cell: %q (must be a,b,c or a,b,c,alpha,beta,gamma)", spec))
+	}
+	values := []float64{0, 0, 0, 90, 90, 90}
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --cell: %q is not a number", part))
+		}
+		values[i] = v
+	}
+	cell := &unitCell{A: values[0], B: values[1], C: values[2], Alpha: values[3], Beta: values[4], Gamma: values[5]}
+	if !cell.valid() {
+		return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --cell: %q does not describe a unit cell", spec))
+	}
+	return cell, nil
+}
+
+// setCellHeader returns the header records with the crystal records replaced as requested by the
+// set-cell flags. cell is the new cell from --cell, nil to keep the cell of the input.
+func setCellHeader(header []string, cell *unitCell) ([]string, error) {
+	var rest []string
+	for _, line := range header {
+		if !isCrystalRecord(line) {
+			rest = append(rest, line)
+		}
+	}
+	if setCellRemove {
+		return rest, nil
+	}
+
+	current, err := headerCell(header)
+	if err != nil {
+		return nil, withCode(ErrCodeParse, err)
+	}
+	var records []string
+	if cell == nil {
+		// Only the space group or Z change: the other crystal records are kept as they are
+		if current == nil {
+			return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("no CRYST1 record to edit (use --cell to create one)"))
+		}
+		cell = current
+		records = crystalRecords(header)
+	} else {
+		cell.SpaceGroup, cell.Z = "P 1", 1
+		if current != nil {
+			cell.SpaceGroup, cell.Z = current.SpaceGroup, current.Z
+		}
+	}
+	if setCellSpaceGroup != "" {
+		cell.SpaceGroup = setCellSpaceGroup
+	}
+	if setCellZ != 0 {
+		cell.Z = setCellZ
+	}
+	if records == nil {
+		records = cell.crystalHeader()
+	} else {
+		for i, line := range records {
+			if strings.HasPrefix(line, "CRYST1") {
+				records[i] = cell.cryst1()
+			}
+		}
+	}
+	return insertHeaderRecords(rest, records, "MTRIX"), nil
+}
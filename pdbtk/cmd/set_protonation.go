@@ -0,0 +1,317 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	protonationOutput     string
+	protonationForcefield string
+	protonationRules      []string
+	protonationBatch      batchOptions
+)
+
+// Distance limits (Å) used to guess the protonation of histidine nitrogens and to find disulfides
+const (
+	protonationHbondDistance = 3.2
+	protonationMetalDistance = 2.6
+	disulfideDistance        = 2.5
+)
+
+// protonationVariants gives the base residue of each protonation variant, by its Amber name
+var protonationVariants = map[string]string{
+	"HID": "HIS", "HIE": "HIS", "HIP": "HIS",
+	"ASP": "ASP", "ASH": "ASP",
+	"GLU": "GLU", "GLH": "GLU",
+	"LYS": "LYS", "LYN": "LYS",
+	"CYS": "CYS", "CYX": "CYS",
+}
+
+// charmmVariantNames gives the CHARMM residue names of the Amber variants that differ. Neutral ASP and
+// GLU are patches (ASPP, GLUP) in CHARMM, and disulfides are patches of CYS.
+var charmmVariantNames = map[string]string{
+	"HID": "HSD", "HIE": "HSE", "HIP": "HSP", "LYN": "LSN", "CYX": "CYS", "ASH": "", "GLH": "",
+}
+
+// hbondAcceptorAtoms and hbondDonorAtoms are the atoms of standard residues that can only accept or
+// only donate a hydrogen bond to a histidine nitrogen
+var (
+	hbondAcceptorAtoms = map[string]bool{"ASP OD1": true, "ASP OD2": true, "GLU OE1": true, "GLU OE2": true, "ASN OD1": true, "GLN OE1": true}
+	hbondDonorAtoms    = map[string]bool{"LYS NZ": true, "ARG NE": true, "ARG NH1": true, "ARG NH2": true, "ASN ND2": true, "GLN NE2": true, "TRP NE1": true}
+)
+
+var setProtonationCmd = &cobra.Command{
+	Use:   "set-protonation [flags] [input_file...]",
+	Short: "Rename residues to force field protonation variants (HID/HIE/HIP, GLH, LYN, ...)",
+	Long: `Rename histidines, and residues given by rules, to the protonation variants of a force field, as expected by
+Amber (tleap) or CHARMM setup tools.
+
+The protonation of each histidine is taken from its hydrogens (HD1, HE2) when they are present, and is
+otherwise guessed from the environment of its ring nitrogens: a nitrogen within 3.2 Å of an atom that
+can only accept a hydrogen bond (a backbone O, a carboxylate oxygen, or the carbonyl O of ASN or GLN)
+is protonated, and one within 3.2 Å of a hydrogen bond donor (a backbone N, LYS, ARG, ASN, GLN or TRP
+nitrogens) or 2.6 Å of a metal is not. Histidines with both nitrogens protonated are HIP; otherwise
+the tautomer is HID when only ND1 is protonated and HIE (the most common) in all other cases.
+Cysteines whose SG atoms are within 2.5 Å of each other are renamed CYX. ASP, GLU and LYS stay charged
+unless a rule says otherwise.
+
+--set gives rules as RESIDUE=VARIANT (e.g. A:45=HIP,A:102=GLH), which override the guesses. Variants
+can be given by Amber or CHARMM names: HID, HIE, HIP, ASH, GLH, LYN, CYX, HSD, HSE, HSP or LSN, or the
+standard names to keep a residue charged. --forcefield charmm writes the CHARMM names HSD, HSE, HSP and
+LSN; CHARMM has no residue names for neutral ASP and GLU (they are the patches ASPP and GLUP), so those
+rules are refused with it. Residues that already have variant names keep their states unless a rule
+applies. Hydrogens that the new variant does not have are removed.
+
+Each renamed residue is reported on stderr with the reason.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Name histidines HID/HIE/HIP and disulfide cysteines CYX for tleap
+  pdbtk set-protonation 1a02.pdb --output 1a02_amber.pdb
+
+  # Set states explicitly and write CHARMM names
+  pdbtk set-protonation --forcefield charmm --set A:45=HSP,A:120=LSN 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runSetProtonation,
+}
+
+func init() {
+	setProtonationCmd.Flags().StringVarP(&protonationOutput, "output", "o", "", "Output file (default: stdout)")
+	setProtonationCmd.Flags().StringVar(&protonationForcefield, "forcefield", "amber", "Residue names to write: amber or charmm")
+	setProtonationCmd.Flags().StringSliceVar(&protonationRules, "set", nil, "Protonation rules as RESIDUE=VARIANT, e.g. A:45=HIP (comma-separated or repeated)")
+	addBatchFlags(setProtonationCmd, &protonationBatch, "{name}")
+}
+
+// protonationRule sets the protonation variant (by its Amber name) of a residue
+type protonationRule struct {
+	chainID  byte
+	position residuePosition
+	variant  string
+}
+
+func runSetProtonation(cmd *cobra.Command, args []string) error {
+	if protonationForcefield != "amber" && protonationForcefield != "charmm" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --forcefield: %s (must be amber or charmm)", protonationForcefield))
+	}
+	var rules []protonationRule
+	for _, spec := range protonationRules {
+		residue, name, ok := strings.Cut(spec, "=")
+		if !ok {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --set: %s (expected RESIDUE=VARIANT, e.g. A:45=HIP)", spec))
+		}
+		chainID, position, err := parseResidueSpec(residue)
+		if err != nil {
+			return err
+		}
+		variant, ok := amberVariant(strings.ToUpper(name))
+		if !ok {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --set: unknown protonation variant %s", name))
+		}
+		if protonationForcefield == "charmm" && charmmVariantName(variant) == "" {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --set: %s has no CHARMM residue name (it is the patch %sP)", variant, protonationVariants[variant]))
+		}
+		rules = append(rules, protonationRule{chainID, position, variant})
+	}
+
+	return runBatch(cmd.Context(), args, protonationOutput, protonationBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		firstModel := file.Models()[0]
+		used := make([]bool, len(rules))
+		renamed := 0
+		var atoms []*AtomRecord
+		for _, residue := range groupResidues(file.Atoms) {
+			key := residue[0].Residue()
+			current, ok := amberVariant(key.ResName)
+			if !ok || residue[0].Het {
+				atoms = append(atoms, residue...)
+				continue
+			}
+			variant, reason := current, ""
+			if key.ResName == "HIS" {
+				variant, reason = guessHistidine(residue, file.Atoms)
+			} else if key.ResName == "CYS" && disulfideBonded(residue, file.Atoms) {
+				variant, reason = "CYX", "disulfide"
+			}
+			for i, rule := range rules {
+				if rule.chainID == key.ChainID && rule.position == (residuePosition{ResSeq: key.ResSeq, ICode: key.ICode}) {
+					if protonationVariants[rule.variant] != protonationVariants[current] {
+						return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --set: %s is %s, not %s", residueLabel(key), key.ResName, protonationVariants[rule.variant]))
+					}
+					variant, reason, used[i] = rule.variant, "rule", true
+				}
+			}
+
+			name := variant
+			if protonationForcefield == "charmm" {
+				name = charmmVariantName(variant)
+				if name == "" {
+					name = protonationVariants[variant]
+					if err := warn("%s %s has no CHARMM residue name; apply the %sP patch", residueLabel(key), variant, name); err != nil {
+						return err
+					}
+				}
+			}
+			if name != key.ResName && key.Model == firstModel {
+				renamed++
+				if reason == "" {
+					reason = "existing " + key.ResName
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s %s -> %s (%s)\n", residueLabel(key), key.ResName, name, reason)
+			}
+			for _, atom := range residue {
+				if variantLacksHydrogen(variant, atom.Name) {
+					continue
+				}
+				copied := *atom
+				copied.ResName = name
+				atoms = append(atoms, &copied)
+			}
+		}
+		for i, rule := range rules {
+			if !used[i] {
+				if err := warn("--set residue %c:%d%s not found", rule.chainID, rule.position.ResSeq, strings.TrimSpace(string(rule.position.ICode))); err != nil {
+					return err
+				}
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Renamed %d residues\n", renamed)
+
+		return writePDBRecords(file.WithAtoms(atoms), writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// amberVariant returns the Amber name of a residue name of a protonation variant (Amber, CHARMM or
+// standard), and whether it is one
+func amberVariant(name string) (string, bool) {
+	if name == "HIS" {
+		return "HIE", true
+	}
+	if _, ok := protonationVariants[name]; ok {
+		return name, true
+	}
+	for amber, charmm := range charmmVariantNames {
+		if charmm == name && charmm != "CYS" {
+			return amber, true
+		}
+	}
+	return "", false
+}
+
+// charmmVariantName returns the CHARMM residue name of an Amber variant, or "" if it has none
+func charmmVariantName(variant string) string {
+	if name, ok := charmmVariantNames[variant]; ok {
+		return name
+	}
+	return variant
+}
+
+// variantLacksHydrogen reports whether the hydrogen name is one that the protonation variant does not
+// have
+func variantLacksHydrogen(variant, name string) bool {
+	switch variant {
+	case "HID":
+		return name == "HE2"
+	case "HIE":
+		return name == "HD1"
+	case "ASP":
+		return name == "HD2"
+	case "GLU":
+		return name == "HE2"
+	case "LYN":
+		return name == "HZ1"
+	case "CYX":
+		return name == "HG"
+	}
+	return false
+}
+
+// guessHistidine returns the protonation variant of a histidine and the reason for it: from its
+// hydrogens if it has any, otherwise from the hydrogen bond partners and metals near its ring
+// nitrogens
+func guessHistidine(residue []*AtomRecord, all []*AtomRecord) (string, string) {
+	names := make(map[string]*AtomRecord)
+	for _, atom := range residue {
+		if _, ok := names[atom.Name]; !ok {
+			names[atom.Name] = atom
+		}
+	}
+	switch {
+	case names["HD1"] != nil && names["HE2"] != nil:
+		return "HIP", "HD1 and HE2"
+	case names["HD1"] != nil:
+		return "HID", "HD1"
+	case names["HE2"] != nil:
+		return "HIE", "HE2"
+	}
+
+	// protonated and deprotonated give the evidence for each ring nitrogen
+	protonated := make(map[string]string)
+	deprotonated := make(map[string]string)
+	key := residue[0].Residue()
+	for _, name := range []string{"ND1", "NE2"} {
+		n := names[name]
+		if n == nil {
+			continue
+		}
+		for _, atom := range all {
+			if atom.Model != key.Model || atom.Residue() == key {
+				continue
+			}
+			d := distance(n.Coord(), atom.Coord())
+			element := atomElement(atom, "")
+			switch {
+			case metalElements[element] && atom.Het && d <= protonationMetalDistance:
+				deprotonated[name] = fmt.Sprintf("%s coordinates %s %s", name, residueLabel(atom.Residue()), atom.Name)
+			case d > protonationHbondDistance:
+			case (atom.Name == "O" || atom.Name == "OXT") && !atom.Het,
+				hbondAcceptorAtoms[atom.ResName+" "+atom.Name]:
+				protonated[name] = fmt.Sprintf("%s donates to %s %s", name, residueLabel(atom.Residue()), atom.Name)
+			case atom.Name == "N" && !atom.Het && atom.ResName != "PRO",
+				hbondDonorAtoms[atom.ResName+" "+atom.Name]:
+				if deprotonated[name] == "" {
+					deprotonated[name] = fmt.Sprintf("%s accepts from %s %s", name, residueLabel(atom.Residue()), atom.Name)
+				}
+			}
+		}
+		if deprotonated[name] != "" && strings.Contains(deprotonated[name], "coordinates") {
+			delete(protonated, name)
+		}
+	}
+	switch {
+	case protonated["ND1"] != "" && protonated["NE2"] != "":
+		return "HIP", protonated["ND1"] + ", " + protonated["NE2"]
+	case protonated["ND1"] != "" && deprotonated["ND1"] == "":
+		return "HID", protonated["ND1"]
+	case protonated["NE2"] != "":
+		return "HIE", protonated["NE2"]
+	case deprotonated["ND1"] != "":
+		return "HIE", deprotonated["ND1"]
+	case deprotonated["NE2"] != "":
+		return "HID", deprotonated["NE2"]
+	}
+	return "HIE", "default"
+}
+
+// disulfideBonded reports whether the SG atom of a cysteine is within disulfideDistance of the SG of
+// another cysteine of the same model
+func disulfideBonded(residue []*AtomRecord, all []*AtomRecord) bool {
+	key := residue[0].Residue()
+	for _, sg := range residue {
+		if sg.Name != "SG" {
+			continue
+		}
+		for _, atom := range all {
+			if atom.Model == key.Model && atom.Name == "SG" && atom.Residue() != key && distance(sg.Coord(), atom.Coord()) <= disulfideDistance {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package cmd
+
+import "math"
+
+// sidechainAtom gives the ideal internal coordinates of a side chain atom: it is bonded to c, with the
+// angle b-c-atom and the torsion a-b-c-atom
+type sidechainAtom struct {
+	name    string
+	a, b, c string
+	bond    float64
+	angle   float64
+	chi     int     // the side chain torsion (1 to 4) that sets the torsion, or 0 for a fixed torsion
+	torsion float64 // the fixed torsion, or the offset from the chi angle
+}
+
+// cbAtom places CB from the backbone of an L-amino acid
+var cbAtom = sidechainAtom{"CB", "N", "C", "CA", 1.52, 109.5, 0, 122.7}
+
+// sidechainAtoms lists the side chain atoms of the standard amino acids after CB, in PDB order, with
+// ideal bond lengths and angles (Engh and Huber). Atoms that branch off the same parent as the atom that
+// defines a chi angle are placed 120° or 180° from it.
+var sidechainAtoms = map[string][]sidechainAtom{
+	"ALA": {},
+	"GLY": {},
+	"SER": {{"OG", "N", "CA", "CB", 1.42, 110.8, 1, 0}},
+	"CYS": {{"SG", "N", "CA", "CB", 1.81, 113.8, 1, 0}},
+	"THR": {
+		{"OG1", "N", "CA", "CB", 1.43, 109.2, 1, 0},
+		{"CG2", "N", "CA", "CB", 1.52, 111.1, 1, -120},
+	},
+	"VAL": {
+		{"CG1", "N", "CA", "CB", 1.53, 110.7, 1, 0},
+		{"CG2", "N", "CA", "CB", 1.53, 110.4, 1, -120},
+	},
+	"ILE": {
+		{"CG1", "N", "CA", "CB", 1.53, 110.7, 1, 0},
+		{"CG2", "N", "CA", "CB", 1.53, 110.4, 1, -120},
+		{"CD1", "CA", "CB", "CG1", 1.52, 114.0, 2, 0},
+	},
+	"LEU": {
+		{"CG", "N", "CA", "CB", 1.53, 116.1, 1, 0},
+		{"CD1", "CA", "CB", "CG", 1.52, 110.3, 2, 0},
+		{"CD2", "CA", "CB", "CG", 1.52, 110.6, 2, -120},
+	},
+	"MET": {
+		{"CG", "N", "CA", "CB", 1.52, 114.0, 1, 0},
+		{"SD", "CA", "CB", "CG", 1.81, 112.7, 2, 0},
+		{"CE", "CB", "CG", "SD", 1.79, 100.6, 3, 0},
+	},
+	"PRO": {
+		{"CG", "N", "CA", "CB", 1.50, 103.5, 1, 0},
+		{"CD", "CA", "CB", "CG", 1.50, 104.5, 2, 0},
+	},
+	"PHE": {
+		{"CG", "N", "CA", "CB", 1.50, 113.9, 1, 0},
+		{"CD1", "CA", "CB", "CG", 1.39, 120.0, 2, 0},
+		{"CD2", "CA", "CB", "CG", 1.39, 120.0, 2, 180},
+		{"CE1", "CB", "CG", "CD1", 1.39, 120.0, 0, 180},
+		{"CE2", "CB", "CG", "CD2", 1.39, 120.0, 0, 180},
+		{"CZ", "CG", "CD1", "CE1", 1.39, 120.0, 0, 0},
+	},
+	"TYR": {
+		{"CG", "N", "CA", "CB", 1.51, 113.8, 1, 0},
+		{"CD1", "CA", "CB", "CG", 1.39, 120.8, 2, 0},
+		{"CD2", "CA", "CB", "CG", 1.39, 120.8, 2, 180},
+		{"CE1", "CB", "CG", "CD1", 1.39, 121.2, 0, 180},
+		{"CE2", "CB", "CG", "CD2", 1.39, 121.2, 0, 180},
+		{"CZ", "CG", "CD1", "CE1", 1.38, 119.6, 0, 0},
+		{"OH", "CD1", "CE1", "CZ", 1.38, 119.9, 0, 180},
+	},
+	"TRP": {
+		{"CG", "N", "CA", "CB", 1.50, 114.1, 1, 0},
+		{"CD1", "CA", "CB", "CG", 1.37, 127.1, 2, 0},
+		{"CD2", "CA", "CB", "CG", 1.43, 126.7, 2, 180},
+		{"NE1", "CB", "CG", "CD1", 1.38, 108.5, 0, 180},
+		{"CE2", "CB", "CG", "CD2", 1.41, 108.5, 0, 180},
+		{"CE3", "CB", "CG", "CD2", 1.40, 133.8, 0, 0},
+		{"CZ2", "CG", "CD2", "CE2", 1.40, 120.0, 0, 180},
+		{"CZ3", "CG", "CD2", "CE3", 1.39, 120.0, 0, 180},
+		{"CH2", "CD2", "CE2", "CZ2", 1.37, 120.0, 0, 0},
+	},
+	"HIS": {
+		{"CG", "N", "CA", "CB", 1.50, 113.7, 1, 0},
+		{"ND1", "CA", "CB", "CG", 1.38, 122.9, 2, 0},
+		{"CD2", "CA", "CB", "CG", 1.36, 130.6, 2, 180},
+		{"CE1", "CB", "CG", "ND1", 1.32, 108.5, 0, 180},
+		{"NE2", "CB", "CG", "CD2", 1.37, 107.2, 0, 180},
+	},
+	"ASP": {
+		{"CG", "N", "CA", "CB", 1.52, 113.1, 1, 0},
+		{"OD1", "CA", "CB", "CG", 1.25, 119.2, 2, 0},
+		{"OD2", "CA", "CB", "CG", 1.25, 118.2, 2, 180},
+	},
+	"ASN": {
+		{"CG", "N", "CA", "CB", 1.52, 112.6, 1, 0},
+		{"OD1", "CA", "CB", "CG", 1.23, 120.9, 2, 0},
+		{"ND2", "CA", "CB", "CG", 1.33, 116.5, 2, 180},
+	},
+	"GLU": {
+		{"CG", "N", "CA", "CB", 1.52, 113.8, 1, 0},
+		{"CD", "CA", "CB", "CG", 1.52, 113.3, 2, 0},
+		{"OE1", "CB", "CG", "CD", 1.25, 119.0, 3, 0},
+		{"OE2", "CB", "CG", "CD", 1.25, 118.1, 3, 180},
+	},
+	"GLN": {
+		{"CG", "N", "CA", "CB", 1.52, 113.8, 1, 0},
+		{"CD", "CA", "CB", "CG", 1.52, 112.8, 2, 0},
+		{"OE1", "CB", "CG", "CD", 1.24, 120.9, 3, 0},
+		{"NE2", "CB", "CG", "CD", 1.33, 116.5, 3, 180},
+	},
+	"LYS": {
+		{"CG", "N", "CA", "CB", 1.52, 113.8, 1, 0},
+		{"CD", "CA", "CB", "CG", 1.52, 111.8, 2, 0},
+		{"CE", "CB", "CG", "CD", 1.52, 111.7, 3, 0},
+		{"NZ", "CG", "CD", "CE", 1.49, 111.4, 4, 0},
+	},
+	"ARG": {
+		{"CG", "N", "CA", "CB", 1.52, 113.8, 1, 0},
+		{"CD", "CA", "CB", "CG", 1.52, 111.8, 2, 0},
+		{"NE", "CB", "CG", "CD", 1.46, 111.7, 3, 0},
+		{"CZ", "CG", "CD", "NE", 1.33, 124.8, 4, 0},
+		{"NH1", "CD", "NE", "CZ", 1.33, 120.6, 0, 0},
+		{"NH2", "CD", "NE", "CZ", 1.33, 119.6, 0, 180},
+	},
+}
+
+// rotamer is a common side chain conformation, with the chi angles in degrees and its frequency in
+// high-resolution structures
+type rotamer struct {
+	name      string
+	chi       []float64
+	frequency float64
+}
+
+// rotamers are the most common rotamers of each amino acid (Lovell et al., the penultimate rotamer
+// library), most frequent first
+var rotamers = map[string][]rotamer{
+	"SER": {{"p", []float64{64}, 48}, {"m", []float64{-65}, 29}, {"t", []float64{178}, 22}},
+	"CYS": {{"m", []float64{-65}, 55}, {"t", []float64{-177}, 34}, {"p", []float64{62}, 11}},
+	"THR": {{"p", []float64{62}, 49}, {"m", []float64{-65}, 43}, {"t", []float64{-175}, 7}},
+	"VAL": {{"t", []float64{175}, 73}, {"m", []float64{-60}, 20}, {"p", []float64{63}, 6}},
+	"ILE": {
+		{"mt", []float64{-65, 170}, 60}, {"mm", []float64{-57, -60}, 15}, {"pt", []float64{62, 170}, 13},
+		{"tt", []float64{-177, 170}, 8},
+	},
+	"LEU": {{"mt", []float64{-65, 175}, 59}, {"tp", []float64{-177, 65}, 29}, {"tt", []float64{-172, 145}, 2}, {"mp", []float64{-85, 65}, 2}},
+	"MET": {
+		{"mmm", []float64{-65, -65, -70}, 19}, {"mtp", []float64{-65, 180, 75}, 17}, {"mtm", []float64{-65, 180, -75}, 11},
+		{"ttp", []float64{-177, 180, 75}, 8}, {"tpp", []float64{-177, 65, 75}, 5}, {"ptm", []float64{62, 180, -75}, 5},
+	},
+	// The proline puckers have the chi angles that close the ring with an ideal CB
+	"PRO": {{"Cg endo", []float64{25, -21}, 50}, {"Cg exo", []float64{-25, 21}, 45}},
+	"PHE": {{"m-85", []float64{-65, -85}, 44}, {"t80", []float64{-177, 80}, 33}, {"p90", []float64{62, 90}, 13}, {"m-30", []float64{-65, -30}, 9}},
+	"TYR": {{"m-85", []float64{-65, -85}, 43}, {"t80", []float64{-177, 80}, 34}, {"p90", []float64{62, 90}, 13}, {"m-30", []float64{-65, -30}, 9}},
+	"TRP": {
+		{"m95", []float64{-65, 95}, 28}, {"t90", []float64{-177, 90}, 18}, {"m0", []float64{-65, -5}, 17},
+		{"t-105", []float64{-177, -105}, 16}, {"p-90", []float64{62, -90}, 9}, {"m-90", []float64{-65, -90}, 6}, {"p90", []float64{62, 90}, 5},
+	},
+	"HIS": {
+		{"m-70", []float64{-65, -70}, 29}, {"t60", []float64{-177, 60}, 16}, {"m80", []float64{-65, 80}, 13},
+		{"t-80", []float64{-177, -80}, 11}, {"p-80", []float64{62, -75}, 9}, {"m170", []float64{-65, 165}, 7},
+		{"t-160", []float64{-177, -165}, 5}, {"p80", []float64{62, 80}, 4},
+	},
+	"ASP": {{"m-20", []float64{-70, -15}, 51}, {"t0", []float64{-177, 0}, 21}, {"p-10", []float64{62, -10}, 10}, {"p30", []float64{62, 30}, 9}, {"t70", []float64{-177, 65}, 8}},
+	"ASN": {
+		{"m-20", []float64{-65, -20}, 27}, {"t30", []float64{-174, 30}, 15}, {"t-20", []float64{-177, -20}, 12},
+		{"p30", []float64{62, 30}, 9}, {"m-80", []float64{-65, -75}, 9}, {"p-10", []float64{62, -10}, 7}, {"m120", []float64{-65, 120}, 6},
+	},
+	"GLU": {
+		{"mt-10", []float64{-67, 180, -10}, 33}, {"tt0", []float64{-177, 180, 0}, 24}, {"mm-40", []float64{-65, -65, -40}, 13},
+		{"tp10", []float64{-177, 65, 10}, 10}, {"mp0", []float64{-65, 85, 0}, 6}, {"pt-20", []float64{62, 180, -20}, 5},
+	},
+	"GLN": {
+		{"mt-30", []float64{-67, 180, -25}, 38}, {"tt0", []float64{-177, 180, 0}, 16}, {"mm-40", []float64{-65, -65, -40}, 16},
+		{"tp60", []float64{-177, 65, 60}, 10}, {"mp0", []float64{-65, 85, 0}, 6}, {"pt20", []float64{64, 180, 20}, 4},
+	},
+	"LYS": {
+		{"mttt", []float64{-65, 180, 180, 180}, 36}, {"tttt", []float64{-177, 180, 180, 180}, 13}, {"mmtt", []float64{-62, -68, 180, 180}, 7},
+		{"mttm", []float64{-65, 180, 180, -65}, 5}, {"mtmt", []float64{-65, 180, -68, 180}, 4}, {"ptt", []float64{62, 180, 180, 180}, 4},
+	},
+	"ARG": {
+		{"mtt180", []float64{-67, 180, 180, 180}, 12}, {"mtt85", []float64{-67, 180, 180, 85}, 9}, {"ttt180", []float64{-177, 180, 180, 180}, 8},
+		{"mtp180", []float64{-67, 180, 65, 175}, 7}, {"mmt180", []float64{-62, -68, 180, 180}, 6}, {"ptt180", []float64{62, 180, 180, 180}, 4},
+	},
+}
+
+// buildSidechain places the side chain atoms of resName from the backbone atoms (N, CA, C and CB, which
+// is also placed when it is missing) with the given chi angles, and returns them in PDB order. Side chain
+// atoms that are already in backbone are kept where they are.
+func buildSidechain(resName string, backbone map[string]vec3, chi []float64) ([]string, []vec3) {
+	if resName == "GLY" {
+		return nil, nil
+	}
+	positions := make(map[string]vec3, len(backbone))
+	for name, p := range backbone {
+		positions[name] = p
+	}
+	if _, ok := positions["CB"]; !ok {
+		positions["CB"] = placeAtom(positions["N"], positions["C"], positions["CA"], cbAtom.bond, cbAtom.angle, cbAtom.torsion)
+	}
+	names, placed := []string{"CB"}, []vec3{positions["CB"]}
+	for _, atom := range sidechainAtoms[resName] {
+		if p, ok := positions[atom.name]; ok {
+			names, placed = append(names, atom.name), append(placed, p)
+			continue
+		}
+		torsion := atom.torsion
+		if atom.chi > 0 {
+			torsion += chi[atom.chi-1]
+		}
+		p := placeAtom(positions[atom.a], positions[atom.b], positions[atom.c], atom.bond, atom.angle, torsion)
+		positions[atom.name] = p
+		names, placed = append(names, atom.name), append(placed, p)
+	}
+	return names, placed
+}
+
+// measuredChi returns the chi angles of resName measured from the atoms in positions, with NaN for
+// those whose atoms are missing
+func measuredChi(resName string, positions map[string]vec3) []float64 {
+	chi := make([]float64, chiCount(resName))
+	for i := range chi {
+		chi[i] = math.NaN()
+	}
+	for _, atom := range sidechainAtoms[resName] {
+		if atom.chi == 0 || atom.torsion != 0 {
+			continue
+		}
+		a, okA := positions[atom.a]
+		b, okB := positions[atom.b]
+		c, okC := positions[atom.c]
+		d, okD := positions[atom.name]
+		if okA && okB && okC && okD {
+			chi[atom.chi-1] = dihedral(a, b, c, d)
+		}
+	}
+	return chi
+}
+
+// chiCount returns the number of chi angles that set the side chain of resName
+func chiCount(resName string) int {
+	n := 0
+	for _, atom := range sidechainAtoms[resName] {
+		n = max(n, atom.chi)
+	}
+	return n
+}
@@ -0,0 +1,330 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	siftsXML       string
+	siftsChain     string
+	siftsFormat    string
+	siftsStamp     string
+	siftsStructure string
+	siftsOutput    string
+)
+
+// siftsURLEnv overrides the base URL the SIFTS XML files are downloaded from
+const siftsURLEnv = "PDBTK_SIFTS_URL"
+
+// siftsURL returns the base URL of the SIFTS XML files
+func siftsURL() string {
+	if url := os.Getenv(siftsURLEnv); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://ftp.ebi.ac.uk/pub/databases/msd/sifts/xml"
+}
+
+var siftsCmd = &cobra.Command{
+	Use:   "sifts [flags] <pdb_code>",
+	Short: "Map residues to UniProt, Pfam, CATH and SCOP using SIFTS",
+	Long: `Download the SIFTS residue-level mapping of an entry and write a per-residue table linking the PDB
+chain and residue number to the UniProt accession and residue number and the Pfam, CATH and SCOP
+domains. Residues that are in the sequence but not modelled are reported with an empty residue
+number and observed set to false.
+
+The SIFTS XML file is downloaded from https://ftp.ebi.ac.uk/pub/databases/msd/sifts/xml/{pdb_code}.xml.gz;
+set PDBTK_SIFTS_URL to use a mirror, or use --xml to read a local (optionally gzipped) SIFTS file.
+The output is a tab-separated table, or JSON with --format json.
+
+With --stamp and --structure, an annotation is written into the B-factor column of a structure
+instead: the UniProt residue number for --stamp uniprot, or for pfam, cath and scop the number of the
+domain each residue belongs to (1 for the first domain in the mapping, 2 for the second, ...; 0 for
+residues outside any domain). The domain numbers are listed on stderr.
+
+Examples:
+  # Map the residues of an entry to UniProt and domain databases
+  pdbtk sifts 1a02
+
+  # Map a single chain from a local SIFTS file
+  pdbtk sifts --xml 1a02.xml.gz --chain A
+
+  # Colour a structure by CATH domain
+  pdbtk sifts --stamp cath --structure 1a02.pdb --output 1a02_cath.pdb 1a02`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSifts,
+}
+
+func init() {
+	siftsCmd.Flags().StringVar(&siftsXML, "xml", "", "Read the mapping from a local SIFTS XML file instead of downloading it")
+	siftsCmd.Flags().StringVarP(&siftsChain, "chain", "c", "", "Only report residues of this chain")
+	siftsCmd.Flags().StringVarP(&siftsFormat, "format", "f", "tsv", "Output format: tsv or json")
+	siftsCmd.Flags().StringVar(&siftsStamp, "stamp", "", "Annotation to write into the B-factors of --structure: uniprot, pfam, cath or scop")
+	siftsCmd.Flags().StringVar(&siftsStructure, "structure", "", "Structure file whose B-factors are set by --stamp")
+	siftsCmd.Flags().StringVarP(&siftsOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// siftsResidue is a residue of the SIFTS mapping
+type siftsResidue struct {
+	Chain          string `json:"chain"`
+	ResNum         string `json:"resnum"`
+	ResName        string `json:"resname"`
+	Observed       bool   `json:"observed"`
+	UniProt        string `json:"uniprot"`
+	UniProtResNum  string `json:"uniprot_resnum"`
+	UniProtResName string `json:"uniprot_resname"`
+	Pfam           string `json:"pfam"`
+	CATH           string `json:"cath"`
+	SCOP           string `json:"scop"`
+}
+
+// siftsEntry is the part of a SIFTS XML file used by the sifts command
+type siftsEntry struct {
+	Entities []struct {
+		Segments []struct {
+			Residues []struct {
+				ResName   string `xml:"dbResName,attr"`
+				CrossRefs []struct {
+					Source    string `xml:"dbSource,attr"`
+					Accession string `xml:"dbAccessionId,attr"`
+					ResNum    string `xml:"dbResNum,attr"`
+					ResName   string `xml:"dbResName,attr"`
+					Chain     string `xml:"dbChainId,attr"`
+				} `xml:"crossRefDb"`
+				Details []struct {
+					Property string `xml:"property,attr"`
+					Value    string `xml:",chardata"`
+				} `xml:"residueDetail"`
+			} `xml:"listResidue>residue"`
+		} `xml:"segment"`
+	} `xml:"entity"`
+}
+
+func runSifts(cmd *cobra.Command, args []string) error {
+	if siftsFormat != "tsv" && siftsFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", siftsFormat))
+	}
+	if (len(args) == 0) == (siftsXML == "") {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("specify either a PDB code or --xml"))
+	}
+	switch siftsStamp {
+	case "", "uniprot", "pfam", "cath", "scop":
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --stamp: %s (must be uniprot, pfam, cath or scop)", siftsStamp))
+	}
+	if (siftsStamp == "") != (siftsStructure == "") {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--stamp and --structure must be used together"))
+	}
+	if siftsStructure != "" {
+		if err := CheckFileExistsContext(cmd.Context(), siftsStructure); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	}
+
+	var residues []siftsResidue
+	var err error
+	if siftsXML != "" {
+		if err := CheckFileExistsContext(cmd.Context(), siftsXML); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+		residues, err = readSiftsFile(cmd.Context(), siftsXML)
+	} else {
+		residues, err = fetchSifts(cmd.Context(), args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if siftsChain != "" {
+		var filtered []siftsResidue
+		for _, residue := range residues {
+			if residue.Chain == siftsChain {
+				filtered = append(filtered, residue)
+			}
+		}
+		residues = filtered
+	}
+	if len(residues) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no residues in the SIFTS mapping"))
+	}
+
+	if siftsStructure != "" {
+		return stampSifts(cmd, residues)
+	}
+	return writeOutput(cmd.Context(), siftsOutput, func(w io.Writer) error {
+		if siftsFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(residues)
+		}
+		fmt.Fprintln(w, "chain\tresnum\tresname\tobserved\tuniprot\tuniprot_resnum\tuniprot_resname\tpfam\tcath\tscop")
+		for _, r := range residues {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Chain, r.ResNum, r.ResName, r.Observed,
+				r.UniProt, r.UniProtResNum, r.UniProtResName, r.Pfam, r.CATH, r.SCOP)
+		}
+		return nil
+	})
+}
+
+// fetchSifts downloads and parses the SIFTS mapping of an entry
+func fetchSifts(ctx context.Context, pdbCode string) ([]siftsResidue, error) {
+	pdbCode = strings.ToLower(pdbCode)
+	if len(pdbCode) != 4 {
+		return nil, withCode(ErrCodeInvalidArgument, fmt.Errorf("PDB code must be exactly 4 characters, got: %s", pdbCode))
+	}
+	body, err := fetchBody(ctx, siftsURL()+"/"+pdbCode+".xml.gz")
+	if errors.Is(err, errNotFound) {
+		return nil, withCode(ErrCodeNoMatch, fmt.Errorf("no SIFTS mapping found for %s", pdbCode))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return parseSifts(body)
+}
+
+// readSiftsFile parses a local SIFTS XML file
+func readSiftsFile(ctx context.Context, filename string) ([]siftsResidue, error) {
+	if isObjectURI(filename) {
+		content, err := readObject(ctx, filename)
+		if err != nil {
+			return nil, err
+		}
+		return parseSifts(bytes.NewReader(content))
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, withCode(ErrCodeIO, fmt.Errorf("failed to read input file: %v", err))
+	}
+	defer file.Close()
+	return parseSifts(file)
+}
+
+// parseSifts parses a SIFTS XML document, which may be gzipped
+func parseSifts(reader io.Reader) ([]siftsResidue, error) {
+	buffered := bufio.NewReader(reader)
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, withCode(ErrCodeParse, fmt.Errorf("failed to read SIFTS file: %v", err))
+		}
+		defer gz.Close()
+		reader = gz
+	} else {
+		reader = buffered
+	}
+
+	var entry siftsEntry
+	if err := xml.NewDecoder(reader).Decode(&entry); err != nil {
+		return nil, withCode(ErrCodeParse, fmt.Errorf("failed to parse SIFTS file: %v", err))
+	}
+
+	var residues []siftsResidue
+	for _, entity := range entry.Entities {
+		for _, segment := range entity.Segments {
+			for _, r := range segment.Residues {
+				residue := siftsResidue{ResName: r.ResName, Observed: true}
+				for _, ref := range r.CrossRefs {
+					switch ref.Source {
+					case "PDB":
+						residue.Chain, residue.ResName = ref.Chain, ref.ResName
+						if ref.ResNum != "null" {
+							residue.ResNum = ref.ResNum
+						}
+					case "UniProt":
+						if residue.UniProt == "" {
+							residue.UniProt, residue.UniProtResNum, residue.UniProtResName = ref.Accession, ref.ResNum, ref.ResName
+						}
+					case "Pfam":
+						residue.Pfam = firstNonEmpty(residue.Pfam, ref.Accession)
+					case "CATH":
+						residue.CATH = firstNonEmpty(residue.CATH, ref.Accession)
+					case "SCOP":
+						residue.SCOP = firstNonEmpty(residue.SCOP, ref.Accession)
+					}
+				}
+				for _, detail := range r.Details {
+					if detail.Property == "Annotation" && strings.TrimSpace(detail.Value) == "Not_Observed" {
+						residue.Observed = false
+					}
+				}
+				if residue.ResNum == "" {
+					residue.Observed = false
+				}
+				residues = append(residues, residue)
+			}
+		}
+	}
+	return residues, nil
+}
+
+// firstNonEmpty returns a if it is not empty, and b otherwise
+func firstNonEmpty(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
+
+// stampSifts writes --structure with the --stamp annotation of each residue in the B-factor column
+func stampSifts(cmd *cobra.Command, residues []siftsResidue) error {
+	file, err := readInputRecords(cmd.Context(), siftsStructure)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]float64)
+	var domains []string
+	domainNumbers := make(map[string]int)
+	for _, residue := range residues {
+		if !residue.Observed {
+			continue
+		}
+		key := residue.Chain + ":" + residue.ResNum
+		if siftsStamp == "uniprot" {
+			if n, err := strconv.Atoi(residue.UniProtResNum); err == nil {
+				values[key] = float64(n)
+			}
+			continue
+		}
+		domain := map[string]string{"pfam": residue.Pfam, "cath": residue.CATH, "scop": residue.SCOP}[siftsStamp]
+		if domain == "" {
+			continue
+		}
+		if _, ok := domainNumbers[domain]; !ok {
+			domains = append(domains, domain)
+			domainNumbers[domain] = len(domains)
+		}
+		values[key] = float64(domainNumbers[domain])
+	}
+
+	atoms := make([]*AtomRecord, len(file.Atoms))
+	stamped := make(map[ResidueKey]bool)
+	for i, atom := range file.Atoms {
+		atoms[i] = atom.Copy()
+		atoms[i].TempFactor = 0
+		key := fmt.Sprintf("%c:%d%s", atom.ChainID, atom.ResSeq, strings.TrimSpace(string(atom.ICode)))
+		if value, ok := values[key]; ok {
+			atoms[i].TempFactor = value
+			stamped[atom.Residue()] = true
+		}
+	}
+	for i, domain := range domains {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%d\t%s\n", i+1, domain)
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Stamped %s annotations on %d residues\n", siftsStamp, len(stamped))
+	return writeOutput(cmd.Context(), siftsOutput, func(w io.Writer) error {
+		return writePDBRecords(file.WithAtoms(atoms), w, recordCommandLine(cmd, nil, siftsStructure))
+	})
+}
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// spaceGroup is a space group of macromolecular crystals, given by the generators of its symmetry
+// operators (in the setting of International Tables) and its lattice centering
+type spaceGroup struct {
+	Symbol     string
+	Number     int
+	Centering  byte
+	Generators []string
+}
+
+// spaceGroups are the 65 space groups without inversion or mirror symmetry, the only ones possible
+// for chiral molecules such as proteins, in the settings used by CRYST1 records. Rhombohedral groups
+// are given on hexagonal (H) and rhombohedral (R) axes.
+var spaceGroups = []spaceGroup{
+	{"P 1", 1, 'P', nil},
+	{"P 1 2 1", 3, 'P', []string{"-x,y,-z"}},
+	{"P 1 21 1", 4, 'P', []string{"-x,y+1/2,-z"}},
+	{"C 1 2 1", 5, 'C', []string{"-x,y,-z"}},
+	{"I 1 2 1", 5, 'I', []string{"-x,y,-z"}},
+	{"P 2 2 2", 16, 'P', []string{"-x,-y,z", "-x,y,-z"}},
+	{"P 2 2 21", 17, 'P', []string{"-x,-y,z+1/2", "-x,y,-z+1/2"}},
+	{"P 21 21 2", 18, 'P', []string{"-x,-y,z", "-x+1/2,y+1/2,-z"}},
+	{"P 21 21 21", 19, 'P', []string{"-x+1/2,-y,z+1/2", "-x,y+1/2,-z+1/2"}},
+	{"C 2 2 21", 20, 'C', []string{"-x,-y,z+1/2", "-x,y,-z+1/2"}},
+	{"C 2 2 2", 21, 'C', []string{"-x,-y,z", "-x,y,-z"}},
+	{"F 2 2 2", 22, 'F', []string{"-x,-y,z", "-x,y,-z"}},
+	{"I 2 2 2", 23, 'I', []string{"-x,-y,z", "-x,y,-z"}},
+	{"I 21 21 21", 24, 'I', []string{"-x+1/2,-y,z+1/2", "-x,y+1/2,-z+1/2"}},
+	{"P 4", 75, 'P', []string{"-y,x,z"}},
+	{"P 41", 76, 'P', []string{"-y,x,z+1/4"}},
+	{"P 42", 77, 'P', []string{"-y,x,z+1/2"}},
+	{"P 43", 78, 'P', []string{"-y,x,z+3/4"}},
+	{"I 4", 79, 'I', []string{"-y,x,z"}},
+	{"I 41", 80, 'I', []string{"-y,x+1/2,z+1/4"}},
+	{"P 4 2 2", 89, 'P', []string{"-y,x,z", "-x,y,-z"}},
+	{"P 4 21 2", 90, 'P', []string{"-y+1/2,x+1/2,z", "-x+1/2,y+1/2,-z"}},
+	{"P 41 2 2", 91, 'P', []string{"-y,x,z+1/4", "-x,y,-z"}},
+	{"P 41 21 2", 92, 'P', []string{"-y+1/2,x+1/2,z+1/4", "-x+1/2,y+1/2,-z+1/4"}},
+	{"P 42 2 2", 93, 'P', []string{"-y,x,z+1/2", "-x,y,-z"}},
+	{"P 42 21 2", 94, 'P', []string{"-y+1/2,x+1/2,z+1/2", "-x+1/2,y+1/2,-z+1/2"}},
+	{"P 43 2 2", 95, 'P', []string{"-y,x,z+3/4", "-x,y,-z"}},
+	{"P 43 21 2", 96, 'P', []string{"-y+1/2,x+1/2,z+3/4", "-x+1/2,y+1/2,-z+3/4"}},
+	{"I 4 2 2", 97, 'I', []string{"-y,x,z", "-x,y,-z"}},
+	{"I 41 2 2", 98, 'I', []string{"-y,x+1/2,z+1/4", "-x+1/2,y,-z+3/4"}},
+	{"P 3", 143, 'P', []string{"-y,x-y,z"}},
+	{"P 31", 144, 'P', []string{"-y,x-y,z+1/3"}},
+	{"P 32", 145, 'P', []string{"-y,x-y,z+2/3"}},
+	{"H 3", 146, 'H', []string{"-y,x-y,z"}},
+	{"R 3", 146, 'P', []string{"z,x,y"}},
+	{"P 3 1 2", 149, 'P', []string{"-y,x-y,z", "-y,-x,-z"}},
+	{"P 3 2 1", 150, 'P', []string{"-y,x-y,z", "y,x,-z"}},
+	{"P 31 1 2", 151, 'P', []string{"-y,x-y,z+1/3", "-y,-x,-z+2/3"}},
+	{"P 31 2 1", 152, 'P', []string{"-y,x-y,z+1/3", "y,x,-z"}},
+	{"P 32 1 2", 153, 'P', []string{"-y,x-y,z+2/3", "-y,-x,-z+1/3"}},
+	{"P 32 2 1", 154, 'P', []string{"-y,x-y,z+2/3", "y,x,-z"}},
+	{"H 3 2", 155, 'H', []string{"-y,x-y,z", "y,x,-z"}},
+	{"R 3 2", 155, 'P', []string{"z,x,y", "-y,-x,-z"}},
+	{"P 6", 168, 'P', []string{"x-y,x,z"}},
+	{"P 61", 169, 'P', []string{"x-y,x,z+1/6"}},
+	{"P 65", 170, 'P', []string{"x-y,x,z+5/6"}},
+	{"P 62", 171, 'P', []string{"x-y,x,z+1/3"}},
+	{"P 64", 172, 'P', []string{"x-y,x,z+2/3"}},
+	{"P 63", 173, 'P', []string{"x-y,x,z+1/2"}},
+	{"P 6 2 2", 177, 'P', []string{"x-y,x,z", "y,x,-z"}},
+	{"P 61 2 2", 178, 'P', []string{"x-y,x,z+1/6", "y,x,-z+1/3"}},
+	{"P 65 2 2", 179, 'P', []string{"x-y,x,z+5/6", "y,x,-z+2/3"}},
+	{"P 62 2 2", 180, 'P', []string{"x-y,x,z+1/3", "y,x,-z+2/3"}},
+	{"P 64 2 2", 181, 'P', []string{"x-y,x,z+2/3", "y,x,-z+1/3"}},
+	{"P 63 2 2", 182, 'P', []string{"x-y,x,z+1/2", "y,x,-z"}},
+	{"P 2 3", 195, 'P', []string{"-x,-y,z", "-x,y,-z", "z,x,y"}},
+	{"F 2 3", 196, 'F', []string{"-x,-y,z", "-x,y,-z", "z,x,y"}},
+	{"I 2 3", 197, 'I', []string{"-x,-y,z", "-x,y,-z", "z,x,y"}},
+	{"P 21 3", 198, 'P', []string{"-x+1/2,-y,z+1/2", "-x,y+1/2,-z+1/2", "z,x,y"}},
+	{"I 21 3", 199, 'I', []string{"-x+1/2,-y,z+1/2", "-x,y+1/2,-z+1/2", "z,x,y"}},
+	{"P 4 3 2", 207, 'P', []string{"-x,-y,z", "-x,y,-z", "z,x,y", "y,x,-z"}},
+	{"P 42 3 2", 208, 'P', []string{"-x,-y,z", "-x,y,-z", "z,x,y", "y+1/2,x+1/2,-z+1/2"}},
+	{"F 4 3 2", 209, 'F', []string{"-x,-y,z", "-x,y,-z", "z,x,y", "y,x,-z"}},
+	{"F 41 3 2", 210, 'F', []string{"-x,-y+1/2,z+1/2", "-x+1/2,y+1/2,-z", "z,x,y", "y+3/4,x+1/4,-z+3/4"}},
+	{"I 4 3 2", 211, 'I', []string{"-x,-y,z", "-x,y,-z", "z,x,y", "y,x,-z"}},
+	{"P 43 3 2", 212, 'P', []string{"-x+1/2,-y,z+1/2", "-x,y+1/2,-z+1/2", "z,x,y", "y+1/4,x+3/4,-z+3/4"}},
+	{"P 41 3 2", 213, 'P', []string{"-x+1/2,-y,z+1/2", "-x,y+1/2,-z+1/2", "z,x,y", "y+3/4,x+1/4,-z+1/4"}},
+	{"I 41 3 2", 214, 'I', []string{"-x+1/2,-y,z+1/2", "-x,y+1/2,-z+1/2", "z,x,y", "y+3/4,x+1/4,-z+1/4"}},
+}
+
+// spaceGroupAliases are other names of the space groups found in CRYST1 records
+var spaceGroupAliases = map[string]string{
+	"P 2":   "P 1 2 1",
+	"P 21":  "P 1 21 1",
+	"C 2":   "C 1 2 1",
+	"I 2":   "I 1 2 1",
+	"R 3 H": "H 3",
+	"R 3 R": "R 3",
+	"H 3 R": "R 3",
+	"H 32":  "H 3 2",
+	"R 32":  "R 3 2",
+}
+
+// centeringVectors are the lattice translations of each lattice centering, besides the origin
+var centeringVectors = map[byte][][3]float64{
+	'P': nil,
+	'C': {{0.5, 0.5, 0}},
+	'I': {{0.5, 0.5, 0.5}},
+	'F': {{0, 0.5, 0.5}, {0.5, 0, 0.5}, {0.5, 0.5, 0}},
+	'H': {{2.0 / 3, 1.0 / 3, 1.0 / 3}, {1.0 / 3, 2.0 / 3, 2.0 / 3}},
+}
+
+// lookupSpaceGroup returns the space group with a Hermann-Mauguin symbol as written in CRYST1 records
+func lookupSpaceGroup(symbol string) (*spaceGroup, bool) {
+	symbol = strings.Join(strings.Fields(strings.ToUpper(symbol)), " ")
+	if alias, ok := spaceGroupAliases[symbol]; ok {
+		symbol = alias
+	}
+	for i := range spaceGroups {
+		if spaceGroups[i].Symbol == symbol {
+			return &spaceGroups[i], true
+		}
+	}
+	return nil, false
+}
+
+// operators returns the symmetry operators of the space group in fractional coordinates, starting
+// with the identity, with translations within the unit cell
+func (g *spaceGroup) operators() []symmetryOperator {
+	var generators []symmetryOperator
+	for _, text := range g.Generators {
+		op, err := parseXYZOperator(text)
+		if err != nil {
+			panic(fmt.Sprintf("space group %s: %v", g.Symbol, err))
+		}
+		generators = append(generators, op)
+	}
+
+	identity := symmetryOperator{Rotation: [3][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}}
+	ops := []symmetryOperator{identity}
+	seen := map[string]bool{operatorKey(identity): true}
+	for i := 0; i < len(ops); i++ {
+		for _, generator := range generators {
+			op := reduceTranslation(ops[i].then(generator))
+			if key := operatorKey(op); !seen[key] {
+				seen[key] = true
+				ops = append(ops, op)
+			}
+		}
+	}
+
+	primitive := len(ops)
+	for _, vector := range centeringVectors[g.Centering] {
+		for _, op := range ops[:primitive] {
+			for k := range vector {
+				op.Translation[k] += vector[k]
+			}
+			op = reduceTranslation(op)
+			if key := operatorKey(op); !seen[key] {
+				seen[key] = true
+				ops = append(ops, op)
+			}
+		}
+	}
+	return ops
+}
+
+// reduceTranslation moves the translation of a fractional operator into the unit cell
+func reduceTranslation(op symmetryOperator) symmetryOperator {
+	for k, t := range op.Translation {
+		// Translations are multiples of 1/12
+		n := int(math.Round(t*12)) % 12
+		if n < 0 {
+			n += 12
+		}
+		op.Translation[k] = float64(n) / 12
+	}
+	return op
+}
+
+// operatorKey identifies a fractional operator for comparison
+func operatorKey(op symmetryOperator) string {
+	return fmt.Sprintf("%v %v", op.Rotation, op.Translation)
+}
+
+// parseXYZOperator parses a symmetry operator written as in International Tables, e.g. -x+1/2,y,-z
+func parseXYZOperator(text string) (symmetryOperator, error) {
+	var op symmetryOperator
+	parts := strings.Split(strings.ReplaceAll(strings.ToLower(text), " ", ""), ",")
+	if len(parts) != 3 {
+		return op, fmt.Errorf("invalid symmetry operator: %q", text)
+	}
+	for i, part := range parts {
+		for len(part) > 0 {
+			sign := 1.0
+			if part[0] == '+' || part[0] == '-' {
+				if part[0] == '-' {
+					sign = -1
+				}
+				part = part[1:]
+			}
+			end := strings.IndexAny(part, "+-")
+			if end < 0 {
+				end = len(part)
+			}
+			term := part[:end]
+			part = part[end:]
+			switch term {
+			case "x", "y", "z":
+				op.Rotation[i][term[0]-'x'] += sign
+			default:
+				value, err := parseFraction(term)
+				if err != nil {
+					return op, fmt.Errorf("invalid symmetry operator: %q", text)
+				}
+				op.Translation[i] += sign * value
+			}
+		}
+	}
+	return op, nil
+}
+
+// parseFraction parses a number written as a fraction (1/2) or decimal (0.5)
+func parseFraction(text string) (float64, error) {
+	if numerator, denominator, ok := strings.Cut(text, "/"); ok {
+		n, err1 := strconv.ParseFloat(numerator, 64)
+		d, err2 := strconv.ParseFloat(denominator, 64)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, fmt.Errorf("invalid fraction: %s", text)
+		}
+		return n / d, nil
+	}
+	return strconv.ParseFloat(text, 64)
+}
+
+// formatXYZOperator writes a fractional operator as in International Tables, e.g. -x+1/2,y,-z
+func formatXYZOperator(op symmetryOperator) string {
+	parts := make([]string, 3)
+	for i := 0; i < 3; i++ {
+		var b strings.Builder
+		for j, axis := range "xyz" {
+			switch c := op.Rotation[i][j]; {
+			case c > 0.5:
+				if b.Len() > 0 {
+					b.WriteByte('+')
+				}
+				b.WriteRune(axis)
+			case c < -0.5:
+				b.WriteByte('-')
+				b.WriteRune(axis)
+			}
+		}
+		if t := math.Round(op.Translation[i] * 12); t != 0 {
+			sign := "+"
+			if t < 0 {
+				sign, t = "-", -t
+			}
+			numerator, denominator := int(t), 12
+			for _, d := range []int{2, 2, 3} {
+				if numerator%d == 0 {
+					numerator, denominator = numerator/d, denominator/d
+				}
+			}
+			if b.Len() == 0 && sign == "+" {
+				sign = ""
+			}
+			fmt.Fprintf(&b, "%s%d/%d", sign, numerator, denominator)
+		}
+		parts[i] = b.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// cartesian converts a fractional operator to Cartesian coordinates of the cell
+func (c *unitCell) cartesian(op symmetryOperator) symmetryOperator {
+	orth, frac := c.orthogonalization(), c.fractionalization()
+	var out symmetryOperator
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				for l := 0; l < 3; l++ {
+					out.Rotation[i][j] += orth[i][k] * op.Rotation[k][l] * frac[l][j]
+				}
+			}
+			out.Translation[i] += orth[i][j] * op.Translation[j]
+		}
+	}
+	return out
+}
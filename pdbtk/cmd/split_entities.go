@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	splitEntitiesOutdir   string
+	splitEntitiesTemplate string
+	splitEntitiesTypes    string
+)
+
+var splitEntitiesCmd = &cobra.Command{
+	Use:   "split-entities [flags] --outdir DIR [input_file]",
+	Short: "Write the chains of each mmCIF entity to a separate PDB file",
+	Long: `Split an mmCIF file by entity, writing the chains of each entity (each distinct protein, nucleic acid,
+ligand and the waters) to a separate PDB file in --outdir, using the _entity bookkeeping that is lost
+when working with PDB files.
+
+Entities are classified from _entity and _entity_poly as protein, dna, rna, ligand, water, branched
+(oligosaccharides) or other, and --types keeps only the entities of the listed types. Output files are
+named by --name-template, where {stem} is the input file name without its extension, {entity} the
+entity ID and {type} the entity type. All models are written. Author chain IDs of more than one
+character are replaced by unused single-character IDs. Each entity and its output file are listed on
+stderr.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Write each entity to its own file
+  pdbtk split-entities --outdir entities/ 1a02.cif
+
+  # Write only the protein and RNA entities
+  pdbtk split-entities --types protein,rna --outdir entities/ 1a02.cif`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSplitEntities,
+}
+
+func init() {
+	splitEntitiesCmd.Flags().StringVar(&splitEntitiesOutdir, "outdir", "", "Output directory (required)")
+	splitEntitiesCmd.Flags().StringVar(&splitEntitiesTemplate, "name-template", "{stem}_entity{entity}.pdb", "Output filename template ({stem}, {entity}, {type})")
+	splitEntitiesCmd.Flags().StringVar(&splitEntitiesTypes, "types", "", "Comma-separated entity types to write: protein, dna, rna, ligand, water, branched, other (default: all)")
+	splitEntitiesCmd.MarkFlagRequired("outdir")
+}
+
+// entityTypes are the entity types written by split-entities
+var entityTypes = []string{"protein", "dna", "rna", "ligand", "water", "branched", "other"}
+
+// cifEntity is an entity of an mmCIF file and the atoms of its chains
+type cifEntity struct {
+	ID          string
+	Type        string
+	Description string
+	Chains      []string
+	Atoms       []*AtomRecord
+	atomChains  []string // author chain ID of each atom
+}
+
+func runSplitEntities(cmd *cobra.Command, args []string) error {
+	var types []string
+	if splitEntitiesTypes != "" {
+		for _, t := range strings.Split(splitEntitiesTypes, ",") {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if !containsString(entityTypes, t) {
+				return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --types: %s (must be %s)", t, strings.Join(entityTypes, ", ")))
+			}
+			types = append(types, t)
+		}
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	block, err := readCIFInput(cmd.Context(), inputFile, "split-entities")
+	if err != nil {
+		return err
+	}
+	entities, err := cifEntities(block)
+	if err != nil {
+		return withCode(ErrCodeParse, err)
+	}
+	if len(types) > 0 {
+		var selected []*cifEntity
+		for _, entity := range entities {
+			if containsString(types, entity.Type) {
+				selected = append(selected, entity)
+			}
+		}
+		entities = selected
+	}
+	if len(entities) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no entities with atoms found"))
+	}
+
+	if err := makeOutputDir(splitEntitiesOutdir); err != nil {
+		return err
+	}
+	stem := block.Name
+	if inputFile != "" {
+		name := strings.TrimSuffix(inputBaseName(inputFile), ".gz")
+		stem = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	header := cifHeader(block)
+	for _, entity := range entities {
+		chainIDs := assignBundleChainIDs(entity.Chains)
+		if chainIDs == nil {
+			return fmt.Errorf("entity %s has %d chains, more than fit in a PDB file (%d)", entity.ID, len(entity.Chains), len(chainIDAlphabet))
+		}
+		for i, atom := range entity.Atoms {
+			atom.ChainID = chainIDs[entity.atomChains[i]]
+		}
+		name := strings.NewReplacer("{stem}", stem, "{entity}", entity.ID, "{type}", entity.Type).Replace(splitEntitiesTemplate)
+		outputFile := joinOutputPath(splitEntitiesOutdir, name)
+		file := &PDBFile{Header: header, Atoms: entity.Atoms}
+		err := writeOutput(cmd.Context(), outputFile, func(w io.Writer) error {
+			return writePDBRecords(file, w, recordCommandLine(cmd, nil, inputFile))
+		})
+		if err != nil {
+			return err
+		}
+		description := ""
+		if entity.Description != "" {
+			description = ", " + entity.Description
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Entity %s (%s%s): chains %s, %d atoms -> %s\n",
+			entity.ID, entity.Type, description, strings.Join(entity.Chains, ","), len(entity.Atoms), outputFile)
+	}
+	return nil
+}
+
+// cifEntities returns the entities of an mmCIF file that have atoms, in the order of _entity, with the
+// atoms of each
+func cifEntities(block *CIFBlock) ([]*cifEntity, error) {
+	polymerTypes := make(map[string]string)
+	for _, row := range block.Category("_entity_poly") {
+		polymerTypes[row["entity_id"]] = strings.ToLower(cifRowValue(row, "type"))
+	}
+
+	var entities []*cifEntity
+	byID := make(map[string]*cifEntity)
+	for _, row := range block.Category("_entity") {
+		entity := &cifEntity{ID: row["id"], Description: cifRowValue(row, "pdbx_description")}
+		switch strings.ToLower(cifRowValue(row, "type")) {
+		case "polymer":
+			polymerType := polymerTypes[entity.ID]
+			switch {
+			case strings.HasPrefix(polymerType, "polypeptide"):
+				entity.Type = "protein"
+			case polymerType == "polydeoxyribonucleotide":
+				entity.Type = "dna"
+			case polymerType == "polyribonucleotide":
+				entity.Type = "rna"
+			default:
+				entity.Type = "other"
+			}
+		case "non-polymer":
+			entity.Type = "ligand"
+		case "water":
+			entity.Type = "water"
+		case "branched":
+			entity.Type = "branched"
+		default:
+			entity.Type = "other"
+		}
+		entities = append(entities, entity)
+		byID[entity.ID] = entity
+	}
+
+	rows := block.Category("_atom_site")
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no _atom_site records found")
+	}
+	for _, row := range rows {
+		entity := byID[row["label_entity_id"]]
+		if entity == nil {
+			return nil, fmt.Errorf("atom %s belongs to entity %s, which is not in _entity", row["id"], row["label_entity_id"])
+		}
+		atom, err := cifAtomRecord(row)
+		if err != nil {
+			return nil, err
+		}
+		chain := cifRowValue(row, "auth_asym_id")
+		if chain == "" {
+			chain = row["label_asym_id"]
+		}
+		if !containsString(entity.Chains, chain) {
+			entity.Chains = append(entity.Chains, chain)
+		}
+		entity.Atoms = append(entity.Atoms, atom)
+		entity.atomChains = append(entity.atomChains, chain)
+	}
+
+	var withAtoms []*cifEntity
+	for _, entity := range entities {
+		if len(entity.Atoms) > 0 {
+			withAtoms = append(withAtoms, entity)
+		}
+	}
+	return withAtoms, nil
+}
@@ -0,0 +1,66 @@
+package cmd
+
+// modifiedResidueParents maps common modified amino acids to their standard parent residues
+var modifiedResidueParents = map[string]string{
+	"MSE": "MET", // selenomethionine
+	"SEP": "SER", // phosphoserine
+	"TPO": "THR", // phosphothreonine
+	"PTR": "TYR", // phosphotyrosine
+	"TYS": "TYR", // sulfotyrosine
+	"HYP": "PRO", // hydroxyproline
+	"MLY": "LYS", // dimethyllysine
+	"M3L": "LYS", // trimethyllysine
+	"ALY": "LYS", // acetyllysine
+	"KCX": "LYS", // carboxylysine
+	"LLP": "LYS", // pyridoxal phosphate lysine
+	"CSO": "CYS", // hydroxycysteine
+	"CSD": "CYS", // cysteine sulfinic acid
+	"CME": "CYS", // beta-mercaptoethanol cysteine adduct
+	"OCS": "CYS", // cysteine sulfonic acid
+	"SEC": "CYS", // selenocysteine
+	"CGU": "GLU", // carboxyglutamate
+	"HIC": "HIS", // methylhistidine
+	"NEP": "HIS", // phosphohistidine
+	"MEN": "ASN", // methylasparagine
+	"PHD": "ASP", // phosphoaspartate
+	"AGM": "ARG", // methylarginine
+	"DAL": "ALA", // D-alanine
+	"NLE": "LEU", // norleucine
+}
+
+// modifiedAtomRenames maps atom names of modified residues that differ from their parent's
+var modifiedAtomRenames = map[string]map[string]string{
+	"MSE": {"SE": "SD"},
+	"SEC": {"SE": "SG"},
+	"NLE": {"CD": "CD1"},
+}
+
+// standardizeResidues converts modified residues to their standard parents: atoms are renamed where
+// needed, atoms that the parent does not have (and hydrogens) are removed and HETATM records become ATOM
+// records. It returns the new atoms and the number of residues converted.
+func standardizeResidues(atoms []*AtomRecord) ([]*AtomRecord, int) {
+	converted := make(map[ResidueKey]bool)
+	var result []*AtomRecord
+	for _, atom := range atoms {
+		parent, ok := modifiedResidueParents[atom.ResName]
+		if !ok {
+			result = append(result, atom)
+			continue
+		}
+		converted[atom.Residue()] = true
+
+		newAtom := atom.Copy()
+		if newName, ok := modifiedAtomRenames[atom.ResName][atom.Name]; ok {
+			newAtom.Name = newName
+		}
+		if atomOrderIndex(parent, newAtom.Name) < 0 {
+			continue
+		}
+		newAtom.ResName = parent
+		newAtom.Het = false
+		newAtom.Element = ""
+		newAtom.Element = inferElement(newAtom)
+		result = append(result, newAtom)
+	}
+	return result, len(converted)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	statusFormat string
+	statusOutput string
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [flags] <pdb_code> [pdb_code...]",
+	Short: "Report whether PDB entries are current, obsolete or on hold",
+	Long: `Look up the release status of PDB entries through the RCSB Data API holdings service, for example
+to find the entries of a local mirror that have been obsoleted or revised.
+
+For each entry the status (CURRENT, OBSOLETE or UNRELEASED) and status code are reported, with the
+entries that superseded an obsolete entry and the date it was obsoleted, the deposition and hold
+dates of an unreleased entry, and the coordinate version history (version and revision date of
+each revision of the structure model) of a current entry.
+
+The output is a tab-separated table with the latest version of each entry, or JSON with
+--format json, which includes the full version history. Entries that are not found are reported
+as errors. The requests are sent to https://data.rcsb.org; set PDBTK_RCSB_DATA_URL to use a mirror.
+
+Examples:
+  # Check whether entries are still current
+  pdbtk status 1HHB 4HHB
+
+  # Report the version history of an entry as JSON
+  pdbtk status --format json 4HHB
+
+  # Check every entry of a local mirror
+  pdbtk status $(ls mirror/ | sed 's/\.pdb$//') --output status.tsv`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runStatus,
+}
+
+func init() {
+	statusCmd.Flags().StringVarP(&statusFormat, "format", "f", "tsv", "Output format: tsv or json")
+	statusCmd.Flags().StringVarP(&statusOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// entryStatus is the release status of a PDB entry
+type entryStatus struct {
+	Entry        string         `json:"entry"`
+	Status       string         `json:"status"`
+	StatusCode   string         `json:"status_code"`
+	ReplacedBy   []string       `json:"replaced_by,omitempty"`
+	ObsoleteDate string         `json:"obsolete_date,omitempty"`
+	DepositDate  string         `json:"deposit_date,omitempty"`
+	HoldDate     string         `json:"hold_date,omitempty"`
+	Versions     []entryVersion `json:"versions,omitempty"`
+}
+
+// entryVersion is a revision of the structure model of an entry
+type entryVersion struct {
+	Version string `json:"version"`
+	Date    string `json:"date"`
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	if statusFormat != "tsv" && statusFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be tsv or json)", statusFormat))
+	}
+	ids := make([]string, len(args))
+	for i, arg := range args {
+		ids[i] = strings.ToUpper(arg)
+		if len(ids[i]) != 4 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("PDB code must be exactly 4 characters, got: %s", ids[i]))
+		}
+	}
+
+	var failures batchFailures
+	statuses := make([]entryStatus, 0, len(ids))
+	for _, id := range ids {
+		status, err := fetchEntryStatus(cmd.Context(), id)
+		recordResult(id, statusOutput, err)
+		failures.add(id, err)
+		if err == nil {
+			statuses = append(statuses, status)
+		}
+	}
+
+	if len(statuses) > 0 {
+		err := writeOutput(cmd.Context(), statusOutput, func(w io.Writer) error {
+			if statusFormat == "json" {
+				encoder := json.NewEncoder(w)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(statuses)
+			}
+			fmt.Fprintln(w, "entry\tstatus\tstatus_code\treplaced_by\tobsolete_date\tversion\trevision_date")
+			for _, s := range statuses {
+				var version entryVersion
+				if len(s.Versions) > 0 {
+					version = s.Versions[len(s.Versions)-1]
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Entry, s.Status, s.StatusCode,
+					strings.Join(s.ReplacedBy, ","), s.ObsoleteDate, version.Version, version.Date)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return failures.err(len(ids))
+}
+
+// fetchEntryStatus looks up the status of a PDB entry and the details that depend on it
+func fetchEntryStatus(ctx context.Context, id string) (entryStatus, error) {
+	status := entryStatus{Entry: id}
+	var holdings struct {
+		Combined struct {
+			Status     string `json:"status"`
+			StatusCode string `json:"status_code"`
+			ReplacedBy string `json:"id_code_replaced_by_latest"`
+		} `json:"rcsb_repository_holdings_combined"`
+	}
+	err := fetchJSON(ctx, rcsbDataURL()+"/rest/v1/holdings/status/"+id, &holdings)
+	if errors.Is(err, errNotFound) {
+		return status, withCode(ErrCodeNoMatch, fmt.Errorf("entry not found: %s", id))
+	}
+	if err != nil {
+		return status, err
+	}
+	status.Status = holdings.Combined.Status
+	status.StatusCode = holdings.Combined.StatusCode
+
+	switch status.Status {
+	case "OBSOLETE":
+		var removed struct {
+			Removed struct {
+				ReplacedBy []string `json:"id_codes_replaced_by"`
+				RemoveDate string   `json:"remove_date"`
+			} `json:"rcsb_repository_holdings_removed"`
+		}
+		if err := fetchJSON(ctx, rcsbDataURL()+"/rest/v1/holdings/removed/"+id, &removed); err != nil && !errors.Is(err, errNotFound) {
+			return status, err
+		}
+		status.ReplacedBy = removed.Removed.ReplacedBy
+		status.ObsoleteDate = dateOnly(removed.Removed.RemoveDate)
+		if len(status.ReplacedBy) == 0 && holdings.Combined.ReplacedBy != "" {
+			status.ReplacedBy = []string{holdings.Combined.ReplacedBy}
+		}
+	case "UNRELEASED":
+		var unreleased struct {
+			Unreleased struct {
+				DepositDate string `json:"deposit_date"`
+				HoldDate    string `json:"hold_date"`
+			} `json:"rcsb_repository_holdings_unreleased"`
+		}
+		if err := fetchJSON(ctx, rcsbDataURL()+"/rest/v1/holdings/unreleased/"+id, &unreleased); err != nil && !errors.Is(err, errNotFound) {
+			return status, err
+		}
+		status.DepositDate = dateOnly(unreleased.Unreleased.DepositDate)
+		status.HoldDate = dateOnly(unreleased.Unreleased.HoldDate)
+	case "CURRENT":
+		var entry struct {
+			History []struct {
+				DataContentType string `json:"data_content_type"`
+				MajorRevision   int    `json:"major_revision"`
+				MinorRevision   int    `json:"minor_revision"`
+				RevisionDate    string `json:"revision_date"`
+			} `json:"pdbx_audit_revision_history"`
+		}
+		if err := fetchJSON(ctx, rcsbDataURL()+"/rest/v1/core/entry/"+id, &entry); err != nil && !errors.Is(err, errNotFound) {
+			return status, err
+		}
+		for _, revision := range entry.History {
+			if revision.DataContentType != "Structure model" {
+				continue
+			}
+			status.Versions = append(status.Versions, entryVersion{
+				Version: fmt.Sprintf("%d.%d", revision.MajorRevision, revision.MinorRevision),
+				Date:    dateOnly(revision.RevisionDate),
+			})
+		}
+	}
+	return status, nil
+}
+
+// dateOnly returns the date part (YYYY-MM-DD) of an RCSB timestamp
+func dateOnly(timestamp string) string {
+	if len(timestamp) > 10 {
+		return timestamp[:10]
+	}
+	return timestamp
+}
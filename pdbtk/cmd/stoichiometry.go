@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	stoichOutput   string
+	stoichFormat   string
+	stoichIdentity float64
+)
+
+var stoichiometryCmd = &cobra.Command{
+	Use:   "stoichiometry [flags] [input_file]",
+	Short: "Group identical chains and report the oligomeric state",
+	Long: `Group the polymer chains of a structure by identical (or near-identical) sequence and report the
+stoichiometry, e.g. A2B2 for a hetero 4-mer with two copies each of two different chains.
+
+Chain sequences are taken from the coordinate records of the first model, with modified residues
+written as their parent residue. Two chains belong to the same group when the sequence identity of
+their alignment, relative to the shorter sequence, is at least --identity, so copies with missing
+terminal residues are still grouped together. Use --identity 1 to group only identical sequences.
+Groups are lettered A, B, ... by decreasing number of copies.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Report the stoichiometry of a structure
+  pdbtk stoichiometry 4hhb.pdb
+
+  # Group only chains with identical sequences and report as JSON
+  pdbtk stoichiometry --identity 1 --format json 4hhb.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStoichiometry,
+}
+
+func init() {
+	stoichiometryCmd.Flags().StringVarP(&stoichOutput, "output", "o", "", "Output file (default: stdout)")
+	stoichiometryCmd.Flags().StringVarP(&stoichFormat, "format", "f", "text", "Output format: text or json")
+	stoichiometryCmd.Flags().Float64Var(&stoichIdentity, "identity", 0.95, "Minimum sequence identity (0-1) for chains to be grouped together")
+}
+
+// chainGroup is a set of chains with the same (or nearly the same) sequence
+type chainGroup struct {
+	Label       string   `json:"label"`
+	Chains      []string `json:"chains"`
+	Length      int      `json:"length"`
+	MinIdentity float64  `json:"min_identity"`
+	Sequence    string   `json:"sequence"`
+}
+
+// stoichiometryResult is the report written by stoichiometry
+type stoichiometryResult struct {
+	Stoichiometry   string        `json:"stoichiometry"`
+	OligomericState string        `json:"oligomeric_state"`
+	Groups          []*chainGroup `json:"groups"`
+}
+
+func runStoichiometry(cmd *cobra.Command, args []string) error {
+	if stoichFormat != "text" && stoichFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", stoichFormat))
+	}
+	if stoichIdentity < 0 || stoichIdentity > 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --identity: %g (must be between 0 and 1)", stoichIdentity))
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+	chainIDs, chainSeqs := observedChainSequences(file)
+	if len(chainIDs) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no polymer chains found"))
+	}
+
+	groups := groupChains(chainIDs, chainSeqs, stoichIdentity)
+	result := &stoichiometryResult{
+		Stoichiometry:   stoichiometryFormula(groups),
+		OligomericState: oligomericState(groups, len(chainIDs)),
+		Groups:          groups,
+	}
+
+	return writeOutput(cmd.Context(), stoichOutput, func(w io.Writer) error {
+		if stoichFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(result)
+		}
+		fmt.Fprintf(w, "Stoichiometry: %s (%s)\n", result.Stoichiometry, result.OligomericState)
+		for _, group := range groups {
+			fmt.Fprintf(w, "%s: chains %s, %d residues", group.Label, strings.Join(group.Chains, ","), group.Length)
+			if len(group.Chains) > 1 {
+				fmt.Fprintf(w, ", minimum identity %.1f%%", group.MinIdentity*100)
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	})
+}
+
+// observedChainSequences returns the polymer chains of the first model and their sequences without gaps,
+// with modified residues written as their parent residue
+func observedChainSequences(file *PDBFile) ([]byte, map[byte]string) {
+	models := file.Models()
+	if len(models) == 0 {
+		return nil, nil
+	}
+	sequences := chainSequences(file, models[0], parseModres(file.Header), "parent")
+
+	var chainIDs []byte
+	chainSeqs := make(map[byte]string)
+	for _, chainID := range file.ChainIDs() {
+		sequence := strings.ReplaceAll(positionsToString(sequences[chainID]), "-", "")
+		if sequence != "" {
+			chainIDs = append(chainIDs, chainID)
+			chainSeqs[chainID] = sequence
+		}
+	}
+	return chainIDs, chainSeqs
+}
+
+// sequenceIdentity returns the fraction of identical residues in the alignment of a and b, relative to
+// the length of the shorter sequence
+func sequenceIdentity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	shorter := min(len(a), len(b))
+	if shorter == 0 {
+		return 0
+	}
+	matches := 0
+	for _, pair := range alignSequences(a, b) {
+		if pair.A >= 0 && pair.B >= 0 && a[pair.A] == b[pair.B] {
+			matches++
+		}
+	}
+	return float64(matches) / float64(shorter)
+}
+
+// groupChains groups chains whose sequence identity to the first chain of a group is at least minIdentity,
+// and labels the groups by decreasing number of chains
+func groupChains(chainIDs []byte, sequences map[byte]string, minIdentity float64) []*chainGroup {
+	var groups []*chainGroup
+	for _, chainID := range chainIDs {
+		sequence := sequences[chainID]
+		var found *chainGroup
+		for _, group := range groups {
+			if identity := sequenceIdentity(group.Sequence, sequence); identity >= minIdentity {
+				found = group
+				found.MinIdentity = min(found.MinIdentity, identity)
+				break
+			}
+		}
+		if found == nil {
+			found = &chainGroup{Sequence: sequence, Length: len(sequence), MinIdentity: 1}
+			groups = append(groups, found)
+		}
+		found.Chains = append(found.Chains, string(chainID))
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].Chains) > len(groups[j].Chains)
+	})
+	for i, group := range groups {
+		group.Label = groupLabel(i)
+	}
+	return groups
+}
+
+// groupLabel returns the label of the i-th group: A to Z, then AA, AB, ...
+func groupLabel(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return groupLabel(i/26-1) + groupLabel(i%26)
+}
+
+// stoichiometryFormula formats groups as e.g. A2B2, leaving out counts of 1
+func stoichiometryFormula(groups []*chainGroup) string {
+	var formula strings.Builder
+	for _, group := range groups {
+		formula.WriteString(group.Label)
+		if len(group.Chains) > 1 {
+			fmt.Fprintf(&formula, "%d", len(group.Chains))
+		}
+	}
+	return formula.String()
+}
+
+// oligomericState describes the assembly as a monomer, homo n-mer or hetero n-mer
+func oligomericState(groups []*chainGroup, chains int) string {
+	switch {
+	case chains == 1:
+		return "monomer"
+	case len(groups) == 1:
+		return fmt.Sprintf("homo %d-mer", chains)
+	}
+	return fmt.Sprintf("hetero %d-mer", chains)
+}
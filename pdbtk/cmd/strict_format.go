@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// strictFormat makes the PDB writers check every record they emit against the fixed-column format
+// before writing it, set by the global --strict-format flag
+var strictFormat bool
+
+// maxReportedFormatViolations is the number of malformed lines listed in a --strict-format error
+const maxReportedFormatViolations = 5
+
+// formatCheckedWriter returns the writer a PDB writer emits its records to: writer itself, or with
+// --strict-format a buffer whose records are checked by finish, which passes them on to writer only if
+// they all conform. The PDB writers call finish once they have written everything.
+func formatCheckedWriter(writer io.Writer) (io.Writer, func() error) {
+	if !strictFormat {
+		return writer, func() error { return nil }
+	}
+	var buf bytes.Buffer
+	return &buf, func() error {
+		if err := checkPDBFormat(buf.Bytes()); err != nil {
+			return err
+		}
+		_, err := writer.Write(buf.Bytes())
+		return err
+	}
+}
+
+// checkPDBFormat checks every line of PDB output against the fixed-column format, reporting the first
+// problem of each malformed line
+func checkPDBFormat(content []byte) error {
+	var violations []string
+	for i, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		if problems := pdbRecordViolations(line); len(problems) > 0 {
+			violations = append(violations, fmt.Sprintf("line %d: %s", i+1, problems[0]))
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	message := strings.Join(violations[:min(len(violations), maxReportedFormatViolations)], "; ")
+	if len(violations) > maxReportedFormatViolations {
+		message += fmt.Sprintf("; and %d more malformed lines", len(violations)-maxReportedFormatViolations)
+	}
+	return withCode(ErrCodeValidation, fmt.Errorf("output would not be valid PDB format (--strict-format): %s", message))
+}
+
+// pdbRecordViolations returns the ways a record breaks the PDB fixed-column format. Values too large
+// for their columns push the following columns out of place, so they show up as misaligned columns.
+func pdbRecordViolations(line string) []string {
+	var problems []string
+	// The COMMAND remark of the pdbtk provenance records the whole command line, however long
+	if len(line) > pdbLineWidth && !strings.HasPrefix(line, "REMARK   1 COMMAND:") {
+		problems = append(problems, fmt.Sprintf("%d columns, more than %d", len(line), pdbLineWidth))
+	}
+	for _, c := range line {
+		if c < ' ' || c > '~' {
+			problems = append(problems, fmt.Sprintf("non-printable or non-ASCII character %q", c))
+			break
+		}
+	}
+
+	switch strings.TrimSpace(safeColumns(line, 0, 6)) {
+	case "ATOM", "HETATM":
+		problems = append(problems, atomRecordViolations(line)...)
+	case "TER":
+		if len(line) > 6 && !isIntegerField(line[6:min(len(line), 11)]) {
+			problems = append(problems, fmt.Sprintf("invalid TER serial number %q (columns 7-11)", safeColumns(line, 6, 11)))
+		}
+		if len(line) >= 27 {
+			if line[11] != ' ' || line[20] != ' ' {
+				problems = append(problems, "TER record columns are misaligned")
+			}
+			if !isIntegerField(line[22:26]) || !isInsertionCode(line[26]) {
+				problems = append(problems, fmt.Sprintf("invalid TER residue number %q (columns 23-27)", line[22:27]))
+			}
+		}
+	case "MODEL":
+		if !isIntegerField(safeColumns(line, 10, 14)) || strings.TrimSpace(safeColumns(line, 14, len(line))) != "" {
+			problems = append(problems, fmt.Sprintf("invalid MODEL serial number %q (columns 11-14)", strings.TrimSpace(safeColumns(line, 10, len(line)))))
+		}
+	case "CONECT":
+		fields := strings.TrimRight(safeColumns(line, 6, len(line)), " ")
+		if fields == "" || len(fields)%5 != 0 {
+			problems = append(problems, "CONECT serial numbers are not in 5-column fields")
+			break
+		}
+		for i := 0; i < len(fields); i += 5 {
+			if !isIntegerField(fields[i : i+5]) {
+				problems = append(problems, fmt.Sprintf("invalid CONECT serial number %q (columns %d-%d)", fields[i:i+5], i+7, i+11))
+			}
+		}
+	}
+	return problems
+}
+
+// atomRecordViolations returns the ways an ATOM/HETATM record breaks the fixed-column format: fields
+// that are not where the format puts them, then the errors validate reports
+func atomRecordViolations(line string) []string {
+	var problems []string
+	if len(line) >= 54 {
+		if !isInsertionCode(line[26]) {
+			problems = append(problems, fmt.Sprintf("residue number %q does not fit in columns 23-26", strings.TrimSpace(line[22:27])))
+		}
+		for i, axis := range []string{"x", "y", "z"} {
+			start := 30 + 8*i
+			if line[start+4] != '.' {
+				problems = append(problems, fmt.Sprintf("%s coordinate %q is not in columns %d-%d", axis, line[start:start+8], start+1, start+8))
+			}
+		}
+	}
+	if len(line) >= 66 {
+		for _, field := range []struct {
+			name  string
+			start int
+		}{{"occupancy", 54}, {"temperature factor", 60}} {
+			if line[field.start+3] != '.' {
+				problems = append(problems, fmt.Sprintf("%s %q is not in columns %d-%d", field.name, line[field.start:field.start+6], field.start+1, field.start+6))
+			}
+		}
+	}
+	if strings.TrimSpace(safeColumns(line, 66, 72)) != "" {
+		problems = append(problems, "columns 67-72 are not blank")
+	}
+	if charge := strings.TrimSpace(safeColumns(line, 78, 80)); charge != "" && !isChargeField(charge) {
+		problems = append(problems, fmt.Sprintf("invalid charge %q (columns 79-80)", charge))
+	}
+
+	result := &validationResult{}
+	validateAtomLine(result, 0, line)
+	for _, v := range result.Violations {
+		if v.Severity == SeverityError {
+			problems = append(problems, v.Message)
+		}
+	}
+	return problems
+}
+
+// isIntegerField reports whether a fixed-width field holds an integer, possibly padded with spaces
+func isIntegerField(field string) bool {
+	_, err := strconv.Atoi(strings.TrimSpace(field))
+	return err == nil
+}
+
+// isInsertionCode reports whether c can be an insertion code: blank or a letter
+func isInsertionCode(c byte) bool {
+	return c == ' ' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// isChargeField reports whether a charge is written as in the PDB format, e.g. 2+ or 1-
+func isChargeField(charge string) bool {
+	return len(charge) == 2 && charge[0] >= '0' && charge[0] <= '9' && (charge[1] == '+' || charge[1] == '-')
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import "math"
+
+// rigidTransform is a rotation about a center followed by a translation: p' = rotation·(p - from) + to
+type rigidTransform struct {
+	rotation [3][3]float64
+	from, to vec3
+}
+
+// apply transforms the point p
+func (t rigidTransform) apply(p vec3) vec3 {
+	d := p.sub(t.from)
+	var r vec3
+	for i := 0; i < 3; i++ {
+		r[i] = t.rotation[i][0]*d[0] + t.rotation[i][1]*d[1] + t.rotation[i][2]*d[2]
+	}
+	return r.add(t.to)
+}
+
+// centroid returns the mean of points
+func centroid(points []vec3) vec3 {
+	var sum vec3
+	for _, p := range points {
+		sum = sum.add(p)
+	}
+	if len(points) == 0 {
+		return sum
+	}
+	return sum.scale(1 / float64(len(points)))
+}
+
+// superposition returns the transform that minimises the RMSD of mobile onto target (paired point by
+// point), using the quaternion method of Horn (1987)
+func superposition(mobile, target []vec3) rigidTransform {
+	from, to := centroid(mobile), centroid(target)
+
+	// Correlation matrix of the centred coordinates
+	var s [3][3]float64
+	for i := range mobile {
+		p, q := mobile[i].sub(from), target[i].sub(to)
+		for a := 0; a < 3; a++ {
+			for b := 0; b < 3; b++ {
+				s[a][b] += p[a] * q[b]
+			}
+		}
+	}
+
+	n := [4][4]float64{
+		{s[0][0] + s[1][1] + s[2][2], s[1][2] - s[2][1], s[2][0] - s[0][2], s[0][1] - s[1][0]},
+		{s[1][2] - s[2][1], s[0][0] - s[1][1] - s[2][2], s[0][1] + s[1][0], s[2][0] + s[0][2]},
+		{s[2][0] - s[0][2], s[0][1] + s[1][0], -s[0][0] + s[1][1] - s[2][2], s[1][2] + s[2][1]},
+		{s[0][1] - s[1][0], s[2][0] + s[0][2], s[1][2] + s[2][1], -s[0][0] - s[1][1] + s[2][2]},
+	}
+	values, vectors := symmetricEigen4(n)
+	best := 0
+	for i := 1; i < 4; i++ {
+		if values[i] > values[best] {
+			best = i
+		}
+	}
+	q0, q1, q2, q3 := vectors[0][best], vectors[1][best], vectors[2][best], vectors[3][best]
+
+	rotation := [3][3]float64{
+		{q0*q0 + q1*q1 - q2*q2 - q3*q3, 2 * (q1*q2 - q0*q3), 2 * (q1*q3 + q0*q2)},
+		{2 * (q1*q2 + q0*q3), q0*q0 - q1*q1 + q2*q2 - q3*q3, 2 * (q2*q3 - q0*q1)},
+		{2 * (q1*q3 - q0*q2), 2 * (q2*q3 + q0*q1), q0*q0 - q1*q1 - q2*q2 + q3*q3},
+	}
+	return rigidTransform{rotation: rotation, from: from, to: to}
+}
+
+// symmetricEigen4 returns the eigenvalues and eigenvectors (as columns) of a symmetric 4x4 matrix,
+// using cyclic Jacobi rotations
+func symmetricEigen4(m [4][4]float64) ([4]float64, [4][4]float64) {
+	a := m
+	var v [4][4]float64
+	for i := 0; i < 4; i++ {
+		v[i][i] = 1
+	}
+
+	for sweep := 0; sweep < 50; sweep++ {
+		off := 0.0
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				off += a[p][q] * a[p][q]
+			}
+		}
+		if off < 1e-22 {
+			break
+		}
+		for p := 0; p < 4; p++ {
+			for q := p + 1; q < 4; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				theta := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := 1 / (math.Abs(theta) + math.Sqrt(theta*theta+1))
+				if theta < 0 {
+					t = -t
+				}
+				c := 1 / math.Sqrt(t*t+1)
+				s := t * c
+				for k := 0; k < 4; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p] = c*akp - s*akq
+					a[k][q] = s*akp + c*akq
+				}
+				for k := 0; k < 4; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k] = c*apk - s*aqk
+					a[q][k] = s*apk + c*aqk
+				}
+				for k := 0; k < 4; k++ {
+					vkp, vkq := v[k][p], v[k][q]
+					v[k][p] = c*vkp - s*vkq
+					v[k][q] = s*vkp + c*vkq
+				}
+			}
+		}
+	}
+
+	var values [4]float64
+	for i := 0; i < 4; i++ {
+		values[i] = a[i][i]
+	}
+	return values, v
+}
+
+// rmsd returns the root-mean-square deviation between paired points
+func rmsd(a, b []vec3) float64 {
+	if len(a) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for i := range a {
+		d := a[i].sub(b[i])
+		sum += d.dot(d)
+	}
+	return math.Sqrt(sum / float64(len(a)))
+}
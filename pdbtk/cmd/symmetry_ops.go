@@ -0,0 +1,321 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	symmetryOutput     string
+	symmetryFormat     string
+	symmetryFractional bool
+	symmetrySpaceGroup string
+)
+
+var symmetryOpsCmd = &cobra.Command{
+	Use:   "symmetry-ops [flags] [input_file]",
+	Short: "List the crystallographic and biological assembly symmetry operators",
+	Long: `List the symmetry operators of a structure as rotation matrices and translation vectors: the
+operators of the space group of its unit cell, and the operators that build its biological assemblies.
+
+The space group and cell are read from the CRYST1 record of a PDB file, or from the _cell and _symmetry
+categories of an mmCIF file. Its operators are written as in International Tables (e.g. -x+1/2,y,-z)
+and as matrices in Cartesian coordinates (Å), applied as R·x + t, like the SMTRY records of REMARK 290;
+use --fractional for matrices in fractional coordinates. The 65 space groups possible for chiral
+molecules are supported, with rhombohedral groups on hexagonal (H 3, H 3 2) or rhombohedral axes
+(R 3, R 3 2).
+
+The assembly operators are the BIOMT records of REMARK 350 of a PDB file, or the operators of
+_pdbx_struct_assembly_gen (from _pdbx_struct_oper_list, combined as in their expressions) of an mmCIF
+file, with the chains they apply to.
+
+--space-group lists the operators of a space group without reading a structure (in fractional
+coordinates, as there is no cell).
+If no input file is specified, reads from stdin.
+
+Examples:
+  # List the symmetry operators of a crystal structure
+  pdbtk symmetry-ops 1a02.pdb
+
+  # List the fractional operators of a space group
+  pdbtk symmetry-ops --space-group "P 21 21 21"
+
+  # Write the operators of an mmCIF file as JSON
+  pdbtk symmetry-ops --format json 1a02.cif`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSymmetryOps,
+}
+
+func init() {
+	symmetryOpsCmd.Flags().StringVarP(&symmetryOutput, "output", "o", "", "Output file (default: stdout)")
+	symmetryOpsCmd.Flags().StringVarP(&symmetryFormat, "format", "f", "text", "Output format: text or json")
+	symmetryOpsCmd.Flags().BoolVar(&symmetryFractional, "fractional", false, "Write the space group operators in fractional instead of Cartesian coordinates")
+	symmetryOpsCmd.Flags().StringVar(&symmetrySpaceGroup, "space-group", "", "List the operators of this space group instead of those of an input, e.g. \"P 21 21 21\"")
+}
+
+// operatorMatrix is a symmetry operator as written by symmetry-ops
+type operatorMatrix struct {
+	ID          string        `json:"id"`
+	XYZ         string        `json:"xyz,omitempty"`
+	Rotation    [3][3]float64 `json:"rotation"`
+	Translation [3]float64    `json:"translation"`
+}
+
+// assemblyOperators are the operators of a biological assembly applied to a set of chains
+type assemblyOperators struct {
+	Assembly  string           `json:"assembly"`
+	Chains    []string         `json:"chains"`
+	Operators []operatorMatrix `json:"operators"`
+}
+
+// symmetryReport is the output of symmetry-ops
+type symmetryReport struct {
+	SpaceGroup  string              `json:"space_group,omitempty"`
+	Number      int                 `json:"number,omitempty"`
+	Cell        *unitCell           `json:"cell,omitempty"`
+	Fractional  bool                `json:"fractional"`
+	Operators   []operatorMatrix    `json:"operators"`
+	Assemblies  []assemblyOperators `json:"assemblies,omitempty"`
+	Unsupported bool                `json:"-"`
+}
+
+func runSymmetryOps(cmd *cobra.Command, args []string) error {
+	if symmetryFormat != "text" && symmetryFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", symmetryFormat))
+	}
+
+	report := &symmetryReport{Fractional: symmetryFractional}
+	if symmetrySpaceGroup != "" {
+		if len(args) > 0 {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("--space-group cannot be combined with an input file"))
+		}
+		group, ok := lookupSpaceGroup(symmetrySpaceGroup)
+		if !ok {
+			return withCode(ErrCodeInvalidArgument, fmt.Errorf("unsupported space group: %s", symmetrySpaceGroup))
+		}
+		report.Fractional = true
+		report.addSpaceGroup(group, nil)
+	} else {
+		var inputFile string
+		if len(args) == 1 {
+			inputFile = args[0]
+			if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+				return withCode(ErrCodeInputNotFound, err)
+			}
+		} else if err := checkStdinAvailable(); err != nil {
+			return err
+		}
+		if err := readSymmetry(cmd.Context(), inputFile, report); err != nil {
+			return err
+		}
+	}
+
+	return writeOutput(cmd.Context(), symmetryOutput, func(w io.Writer) error {
+		if symmetryFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+		writeSymmetryReport(w, report)
+		return nil
+	})
+}
+
+// readSymmetry reads the cell, space group and assembly operators of a PDB or mmCIF input into report
+func readSymmetry(ctx context.Context, inputFile string, report *symmetryReport) error {
+	content, err := readInputContent(ctx, inputFile)
+	if err != nil {
+		return err
+	}
+	var cell *unitCell
+	if isCIFFilename(inputFile) || looksLikeCIF(content) {
+		blocks, err := ParseCIF(strings.NewReader(string(content)))
+		if err != nil {
+			return withCode(ErrCodeParse, fmt.Errorf("failed to parse mmCIF: %v", err))
+		}
+		cell = cifCell(blocks[0])
+		if report.Assemblies, err = cifAssemblyOperators(blocks[0]); err != nil {
+			return withCode(ErrCodeParse, err)
+		}
+	} else {
+		header := contentHeader(content)
+		if cell, err = headerCell(header); err != nil {
+			return withCode(ErrCodeParse, err)
+		}
+		if report.Assemblies, err = biomtOperators(header); err != nil {
+			return withCode(ErrCodeParse, err)
+		}
+	}
+
+	if cell != nil {
+		if !cell.valid() {
+			return withCode(ErrCodeParse, fmt.Errorf("invalid unit cell: %s", cell.cryst1()))
+		}
+		if group, ok := lookupSpaceGroup(cell.SpaceGroup); ok {
+			report.addSpaceGroup(group, cell)
+		} else {
+			report.SpaceGroup, report.Cell, report.Unsupported = cell.SpaceGroup, cell, true
+			if err := warn("unsupported space group: %s", cell.SpaceGroup); err != nil {
+				return err
+			}
+		}
+	}
+	if report.Operators == nil && len(report.Assemblies) == 0 {
+		if cell == nil {
+			return withCode(ErrCodeNoMatch, fmt.Errorf("no unit cell (CRYST1) or assembly operators found"))
+		}
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no supported symmetry operators found"))
+	}
+	return nil
+}
+
+// addSpaceGroup adds the operators of a space group to the report, in Cartesian coordinates of the
+// cell unless the report is fractional
+func (r *symmetryReport) addSpaceGroup(group *spaceGroup, cell *unitCell) {
+	r.SpaceGroup, r.Number, r.Cell = group.Symbol, group.Number, cell
+	for i, op := range group.operators() {
+		matrix := operatorMatrix{ID: strconv.Itoa(i + 1), XYZ: formatXYZOperator(op), Rotation: op.Rotation, Translation: op.Translation}
+		if !r.Fractional {
+			cartesian := cell.cartesian(op)
+			for i := 0; i < 3; i++ {
+				for j := 0; j < 3; j++ {
+					matrix.Rotation[i][j] = dropRoundingError(roundTo(cartesian.Rotation[i][j], 6), 1e-9)
+				}
+				matrix.Translation[i] = dropRoundingError(roundTo(cartesian.Translation[i], 5), 1e-9)
+			}
+		}
+		r.Operators = append(r.Operators, matrix)
+	}
+}
+
+// biomtOperators reads the assembly operators of the REMARK 350 BIOMT records of a PDB header
+func biomtOperators(header []string) ([]assemblyOperators, error) {
+	var assemblies []assemblyOperators
+	var assembly string
+	var current *assemblyOperators
+	for _, line := range header {
+		if n, ok := remarkClass(line); !ok || n != 350 {
+			continue
+		}
+		text := strings.TrimSpace(safeColumns(line, 10, len(line)))
+		switch {
+		case strings.HasPrefix(text, "BIOMOLECULE:"):
+			assembly = strings.TrimSpace(strings.TrimPrefix(text, "BIOMOLECULE:"))
+		case strings.HasPrefix(text, "APPLY THE FOLLOWING TO CHAINS:"):
+			assemblies = append(assemblies, assemblyOperators{Assembly: assembly})
+			current = &assemblies[len(assemblies)-1]
+			current.Chains = append(current.Chains, splitChainList(strings.TrimPrefix(text, "APPLY THE FOLLOWING TO CHAINS:"))...)
+		case strings.HasPrefix(text, "AND CHAINS:") && current != nil:
+			current.Chains = append(current.Chains, splitChainList(strings.TrimPrefix(text, "AND CHAINS:"))...)
+		case strings.HasPrefix(text, "BIOMT") && current != nil:
+			fields := strings.Fields(text)
+			if len(fields) != 6 || len(fields[0]) != 6 || fields[0][5] < '1' || fields[0][5] > '3' {
+				return nil, fmt.Errorf("invalid BIOMT record: %q", line)
+			}
+			row := int(fields[0][5] - '1')
+			var values [4]float64
+			for i := range values {
+				v, err := strconv.ParseFloat(fields[i+2], 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid BIOMT record: %q", line)
+				}
+				values[i] = v
+			}
+			if row == 0 {
+				current.Operators = append(current.Operators, operatorMatrix{ID: fields[1]})
+			}
+			if len(current.Operators) == 0 {
+				return nil, fmt.Errorf("invalid BIOMT record: %q", line)
+			}
+			op := &current.Operators[len(current.Operators)-1]
+			copy(op.Rotation[row][:], values[:3])
+			op.Translation[row] = values[3]
+		}
+	}
+	return assemblies, nil
+}
+
+// splitChainList splits a comma-separated list of chain IDs
+func splitChainList(list string) []string {
+	var chains []string
+	for _, id := range strings.Split(list, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			chains = append(chains, id)
+		}
+	}
+	return chains
+}
+
+// cifAssemblyOperators reads the assembly operators of the _pdbx_struct_assembly_gen rows of an mmCIF
+// block, combining the operators of each expression (e.g. (1-60)(61)) into one
+func cifAssemblyOperators(block *CIFBlock) ([]assemblyOperators, error) {
+	operators, err := parseOperatorList(block)
+	if err != nil {
+		return nil, err
+	}
+	var assemblies []assemblyOperators
+	for _, row := range block.Category("_pdbx_struct_assembly_gen") {
+		sequences, err := expandOperatorExpression(row["oper_expression"])
+		if err != nil {
+			return nil, err
+		}
+		assembly := assemblyOperators{Assembly: row["assembly_id"], Chains: splitChainList(row["asym_id_list"])}
+		for _, sequence := range sequences {
+			for _, id := range sequence {
+				if _, ok := operators[id]; !ok {
+					return nil, fmt.Errorf("operator %s of assembly %s is not in _pdbx_struct_oper_list", id, row["assembly_id"])
+				}
+			}
+			op := operators[sequence[len(sequence)-1]]
+			for i := len(sequence) - 2; i >= 0; i-- {
+				op = op.then(operators[sequence[i]])
+			}
+			assembly.Operators = append(assembly.Operators, operatorMatrix{ID: strings.Join(sequence, "x"), Rotation: op.Rotation, Translation: op.Translation})
+		}
+		assemblies = append(assemblies, assembly)
+	}
+	return assemblies, nil
+}
+
+// writeSymmetryReport writes the operators as text
+func writeSymmetryReport(w io.Writer, report *symmetryReport) {
+	if report.Cell != nil {
+		c := report.Cell
+		fmt.Fprintf(w, "Cell: %.3f %.3f %.3f %.2f %.2f %.2f\n", c.A, c.B, c.C, c.Alpha, c.Beta, c.Gamma)
+	}
+	if report.Unsupported {
+		fmt.Fprintf(w, "Space group: %s (not supported)\n", report.SpaceGroup)
+	} else if report.SpaceGroup != "" {
+		coordinates := "Cartesian"
+		if report.Fractional {
+			coordinates = "fractional"
+		}
+		fmt.Fprintf(w, "Space group: %s (No. %d), %d operators in %s coordinates\n", report.SpaceGroup, report.Number, len(report.Operators), coordinates)
+		for _, op := range report.Operators {
+			fmt.Fprintf(w, "\nOperator %s: %s\n", op.ID, op.XYZ)
+			writeOperatorMatrix(w, op)
+		}
+	}
+	for _, assembly := range report.Assemblies {
+		fmt.Fprintf(w, "\nAssembly %s, chains %s: %d operators\n", assembly.Assembly, strings.Join(assembly.Chains, ","), len(assembly.Operators))
+		for _, op := range assembly.Operators {
+			fmt.Fprintf(w, "\nOperator %s\n", op.ID)
+			writeOperatorMatrix(w, op)
+		}
+	}
+}
+
+// writeOperatorMatrix writes the rotation matrix of an operator with the translation as a fourth column
+func writeOperatorMatrix(w io.Writer, op operatorMatrix) {
+	for i := 0; i < 3; i++ {
+		r := op.Rotation[i]
+		fmt.Fprintf(w, "  %10.6f %10.6f %10.6f   %12.5f\n", dropRoundingError(r[0], 5e-7), dropRoundingError(r[1], 5e-7),
+			dropRoundingError(r[2], 5e-7), dropRoundingError(op.Translation[i], 5e-6))
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	tidyOutput              string
+	tidyStandardizeResidues bool
+	tidyBatch               batchOptions
+)
+
+// pdbLineWidth is the width of a PDB record
+const pdbLineWidth = 80
+
+var tidyCmd = &cobra.Command{
+	Use:   "tidy [flags] [input_file...]",
+	Short: "Fix common formatting problems in a PDB file",
+	Long: `Fix common formatting problems in a PDB file in one pass, producing spec-compliant output.
+
+tidy normalizes whitespace (tabs, expanded to 8-column stops, trailing spaces, blank lines and
+lower-case record names), pads every record to 80 columns, fills in missing element symbols
+(columns 77-78) from the atom names, removes duplicate MODEL records, renumbers atom serials and
+writes TER records after each polymer chain and a final END record.
+
+With --standardize-residues, common modified residues are converted to their standard parents
+(MSE to MET, SEP to SER, TPO to THR, PTR to TYR, ...) for simulation preparation: atoms are renamed
+where needed (e.g. SE to SD), atoms the parent residue does not have (such as phosphate groups and
+hydrogens) are removed and HETATM records become ATOM records.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Tidy a PDB file
+  pdbtk tidy messy.pdb --output clean.pdb
+
+  # Tidy every PDB file in a directory
+  pdbtk tidy --outdir clean/ structures/*.pdb
+
+  # Convert modified residues to standard residues for simulation
+  pdbtk tidy --standardize-residues 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runTidy,
+}
+
+func init() {
+	tidyCmd.Flags().StringVarP(&tidyOutput, "output", "o", "", "Output file (default: stdout)")
+	tidyCmd.Flags().BoolVar(&tidyStandardizeResidues, "standardize-residues", false, "Convert modified residues (MSE, SEP, TPO, ...) to their standard parent residues")
+	addBatchFlags(tidyCmd, &tidyBatch, "{name}")
+}
+
+func runTidy(cmd *cobra.Command, args []string) error {
+	return runBatch(cmd.Context(), args, tidyOutput, tidyBatch, func(inputFile string, writer io.Writer) error {
+		return tidyFile(cmd, inputFile, writer)
+	})
+}
+
+// tidyFile tidies a single input and writes the result to writer
+func tidyFile(cmd *cobra.Command, inputFile string, writer io.Writer) error {
+	content, err := readInputContent(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	file, err := ParsePDBRecordsContext(cmd.Context(), bytes.NewReader(normalizePDBLines(content)))
+	if err != nil {
+		return parseError(cmd.Context(), err)
+	}
+
+	if tidyStandardizeResidues {
+		atoms, converted := standardizeResidues(file.Atoms)
+		file = file.WithAtoms(atoms)
+		fmt.Fprintf(cmd.ErrOrStderr(), "Standardized %d modified residues\n", converted)
+	}
+
+	inferred := inferElements(file.Atoms)
+	for i, atom := range file.Atoms {
+		if atom.Element == "" || !isElementSymbol(atom.Element) {
+			atom.Element = inferred[i]
+		}
+		atom.Element = strings.ToUpper(atom.Element)
+	}
+
+	var buf bytes.Buffer
+	if err := writePDBRecords(file, &buf, recordCommandLine(cmd, nil, inputFile)); err != nil {
+		return err
+	}
+	return writePaddedLines(buf.Bytes(), writer)
+}
+
+// normalizePDBLines cleans up whitespace and record names and removes duplicate MODEL records, so the
+// content can be parsed as fixed-column records
+func normalizePDBLines(content []byte) []byte {
+	var out bytes.Buffer
+	inModel := false
+	usedModels := make(map[int]bool)
+	lastModel := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(expandTabs(scanner.Text()), " \r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if len(line) >= 6 {
+			line = strings.ToUpper(line[:6]) + line[6:]
+		} else {
+			line = strings.ToUpper(line)
+		}
+
+		switch strings.TrimSpace(safeColumns(line, 0, 6)) {
+		case "MODEL":
+			// A MODEL record inside an open model, or one reusing a number, is a duplicate
+			if inModel {
+				continue
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 6, len(line))))
+			if err != nil || usedModels[n] {
+				n = lastModel + 1
+			}
+			usedModels[n] = true
+			lastModel = n
+			inModel = true
+			line = fmt.Sprintf("MODEL     %4d", n)
+		case "ENDMDL":
+			inModel = false
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes()
+}
+
+// writePaddedLines copies content to writer, padding each line to the width of a PDB record
+func writePaddedLines(content []byte, writer io.Writer) error {
+	w := bufio.NewWriter(writer)
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		fmt.Fprintf(w, "%-*s\n", pdbLineWidth, line)
+	}
+	return w.Flush()
+}
+
+// expandTabs replaces the tabs of a line with spaces up to the next 8-column tab stop, as a text
+// editor shows them, so the fields after a tab stay in the columns they appear in
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	for _, r := range line {
+		if r == '\t' {
+			b.WriteString(strings.Repeat(" ", 8-b.Len()%8))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
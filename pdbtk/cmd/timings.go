@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+// Phases of a run reported by --timings. Filter is the time spent outside the other phases:
+// selecting, transforming and analysing the structures.
+const (
+	phaseDownload = "download"
+	phaseRead     = "read"
+	phaseParse    = "parse"
+	phaseFilter   = "filter"
+	phaseWrite    = "write"
+)
+
+// phaseOrder is the order phases are listed in, following the flow of a run
+var phaseOrder = []string{phaseDownload, phaseRead, phaseParse, phaseFilter, phaseWrite}
+
+// showTimings reports the time and memory spent in each phase at the end of a run, set by the
+// global --timings flag
+var showTimings bool
+
+// heapMetric is the runtime metric for the memory occupied by live and not yet collected heap objects
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// phaseStats accumulates the wall time of a phase. Calls that overlap, such as concurrent downloads or
+// a phase entered again while in progress, are counted once.
+type phaseStats struct {
+	active   int
+	since    time.Time
+	wall     time.Duration
+	peakHeap uint64
+}
+
+func (s *phaseStats) enter(now time.Time) {
+	if s.active == 0 {
+		s.since = now
+	}
+	s.active++
+}
+
+func (s *phaseStats) leave(now time.Time, heap uint64) {
+	s.active--
+	if s.active == 0 {
+		s.wall += now.Sub(s.since)
+	}
+	s.peakHeap = max(s.peakHeap, heap)
+}
+
+// runTimings records the phases of a run
+type runTimings struct {
+	mu      sync.Mutex
+	start   time.Time
+	phases  map[string]*phaseStats
+	anyPart phaseStats
+}
+
+var timings = &runTimings{start: time.Now(), phases: make(map[string]*phaseStats)}
+
+// beginPhase records that the run has entered phase, and returns the function that records leaving it.
+// It does nothing without --timings.
+func beginPhase(phase string) func() {
+	if !showTimings {
+		return func() {}
+	}
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+	now := time.Now()
+	stats := timings.phases[phase]
+	if stats == nil {
+		stats = &phaseStats{}
+		timings.phases[phase] = stats
+	}
+	stats.enter(now)
+	timings.anyPart.enter(now)
+	return func() {
+		heap := heapInUse()
+		timings.mu.Lock()
+		defer timings.mu.Unlock()
+		now := time.Now()
+		stats.leave(now, heap)
+		timings.anyPart.leave(now, heap)
+	}
+}
+
+// heapInUse returns the memory occupied by heap objects
+func heapInUse() uint64 {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// timedWriter counts the time spent in writes to w as the write phase
+type timedWriter struct {
+	w io.Writer
+}
+
+func (t timedWriter) Write(p []byte) (int, error) {
+	defer beginPhase(phaseWrite)()
+	return t.w.Write(p)
+}
+
+// timedOutput returns w, counting the time spent writing to it as the write phase with --timings
+func timedOutput(w io.Writer) io.Writer {
+	if !showTimings {
+		return w
+	}
+	return timedWriter{w}
+}
+
+// phaseTiming is the time and peak heap memory of a phase, in the run report
+type phaseTiming struct {
+	Phase         string  `json:"phase"`
+	Seconds       float64 `json:"seconds"`
+	PeakHeapBytes uint64  `json:"peak_heap_bytes,omitempty"`
+}
+
+// timingSummary is the --timings summary of a run, in the run report
+type timingSummary struct {
+	Seconds       float64       `json:"seconds"`
+	PeakHeapBytes uint64        `json:"peak_heap_bytes"`
+	Phases        []phaseTiming `json:"phases"`
+}
+
+// summarizeTimings returns the time and peak heap memory of each phase the run went through. Phases
+// can overlap, e.g. writing a downloaded file counts as both download and write.
+func summarizeTimings() *timingSummary {
+	heap := heapInUse()
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+	total := time.Since(timings.start)
+	summary := &timingSummary{Seconds: total.Seconds(), PeakHeapBytes: max(timings.anyPart.peakHeap, heap)}
+	for _, phase := range phaseOrder {
+		if phase == phaseFilter {
+			summary.Phases = append(summary.Phases, phaseTiming{Phase: phase, Seconds: (total - timings.anyPart.wall).Seconds()})
+			continue
+		}
+		if stats := timings.phases[phase]; stats != nil {
+			summary.Phases = append(summary.Phases, phaseTiming{phase, stats.wall.Seconds(), stats.peakHeap})
+		}
+	}
+	return summary
+}
+
+// printTimings writes the --timings summary of a run to stderr
+func printTimings(summary *timingSummary) {
+	fmt.Fprintln(os.Stderr, "Timings:")
+	fmt.Fprintf(os.Stderr, "  %-10s %10s %7s %11s\n", "phase", "time", "share", "peak heap")
+	for _, phase := range summary.Phases {
+		share := 0.0
+		if summary.Seconds > 0 {
+			share = 100 * phase.Seconds / summary.Seconds
+		}
+		heap := "-"
+		if phase.PeakHeapBytes > 0 {
+			heap = formatByteSize(int64(phase.PeakHeapBytes))
+		}
+		fmt.Fprintf(os.Stderr, "  %-10s %9.3fs %6.1f%% %11s\n", phase.Phase, phase.Seconds, share, heap)
+	}
+	fmt.Fprintf(os.Stderr, "  %-10s %9.3fs %7s %11s\n", "total", summary.Seconds, "", formatByteSize(int64(summary.PeakHeapBytes)))
+}
@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tolerantParsing makes the input readers repair malformed coordinate records instead of failing on
+// them, set by the global --tolerant flag
+var tolerantParsing bool
+
+// recordRepair is a change made to a line of the input by --tolerant
+type recordRepair struct {
+	line    int
+	message string
+}
+
+// repairPDBContent repairs the malformed ATOM, HETATM and MODEL records of PDB content read from
+// inputFile, so that the parsers accept it, and warns about every change with its line number
+func repairPDBContent(inputFile string, content []byte) ([]byte, error) {
+	repaired, repairs := repairPDBRecords(content)
+	for _, repair := range repairs {
+		if err := warnLine(inputFile, repair.line, "%s", repair.message); err != nil {
+			return nil, err
+		}
+	}
+	return repaired, nil
+}
+
+// repairPDBRecords returns content with its malformed ATOM, HETATM and MODEL records repaired, and the
+// changes made. Fields that cannot be read get a default, records in nonstandard columns are read as
+// whitespace-separated fields and rewritten in the fixed columns, and records that cannot be read at
+// all are dropped.
+func repairPDBRecords(content []byte) ([]byte, []recordRepair) {
+	var repairs []recordRepair
+	var previous *AtomRecord
+	lastModel := 0
+	lines := strings.Split(string(content), "\n")
+	kept := lines[:0]
+	for i, line := range lines {
+		lineNum := i + 1
+		record := strings.TrimRight(line, "\r")
+		switch {
+		case strings.HasPrefix(record, "ATOM") || strings.HasPrefix(record, "HETATM"):
+			repaired, atom, problems := repairAtomRecord(record, previous)
+			for _, problem := range problems {
+				repairs = append(repairs, recordRepair{lineNum, problem})
+			}
+			if atom == nil {
+				continue
+			}
+			line = repaired
+			previous = atom
+		case strings.HasPrefix(record, "MODEL"):
+			field := strings.TrimSpace(safeColumns(record, 10, 14))
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				// Some tools write the model number right after the record name
+				field = strings.TrimSpace(safeColumns(record, 5, len(record)))
+				if n, err = strconv.Atoi(field); err == nil {
+					repairs = append(repairs, recordRepair{lineNum, fmt.Sprintf("MODEL serial number %d is not in columns 11-14", n)})
+				} else {
+					n = lastModel + 1
+					repairs = append(repairs, recordRepair{lineNum, fmt.Sprintf("invalid MODEL serial number %q, numbered %d", field, n)})
+				}
+				line = fmt.Sprintf("MODEL     %4d", n)
+			}
+			lastModel = n
+		}
+		kept = append(kept, line)
+	}
+	return []byte(strings.Join(kept, "\n")), repairs
+}
+
+// repairAtomRecord returns an ATOM/HETATM record that the parsers accept, the atom it holds and the
+// problems that were repaired, or a nil atom if the record cannot be read
+func repairAtomRecord(line string, previous *AtomRecord) (string, *AtomRecord, []string) {
+	recordName := strings.TrimSpace(safeColumns(line, 0, 6))
+	var err error
+	if recordName == "ATOM" || recordName == "HETATM" {
+		var atom *AtomRecord
+		if atom, err = parseAtomRecord(line); err == nil {
+			return line, atom, nil
+		}
+		if repaired, problems, ok := repairAtomColumns(line, previous); ok {
+			if atom, err := parseAtomRecord(repaired); err == nil {
+				return repaired, atom, problems
+			}
+		}
+	} else {
+		// The parsers would skip the record, as its name is not alone in columns 1-6
+		err = fmt.Errorf("record name %q is not in columns 1-6", recordName)
+	}
+	if atom, problems, ok := parseAtomFields(line, previous); ok {
+		problems = append([]string{fmt.Sprintf("%v; read the record as whitespace-separated fields", err)}, problems...)
+		return formatAtomRecord(atom, atom.Serial), atom, problems
+	}
+	return "", nil, []string{fmt.Sprintf("skipped unreadable coordinate record: %v", err)}
+}
+
+// repairAtomColumns replaces the unreadable serial number, residue number, occupancy and temperature
+// factor of a fixed-column ATOM/HETATM record with defaults. It fails if the coordinates cannot be read.
+func repairAtomColumns(line string, previous *AtomRecord) (string, []string, bool) {
+	if len(line) < 54 {
+		return "", nil, false
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(line[30+8*i:38+8*i]), 64); err != nil {
+			return "", nil, false
+		}
+	}
+
+	var problems []string
+	patch := func(start, end int, value string, format string, args ...interface{}) {
+		if len(line) < end {
+			line += strings.Repeat(" ", end-len(line))
+		}
+		line = line[:start] + value + line[end:]
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	if field := strings.TrimSpace(line[6:11]); field != "" && !isIntegerField(field) {
+		serial := nextSerial(previous)
+		patch(6, 11, fmt.Sprintf("%5d", serial), "invalid atom serial number %q, numbered %d", field, serial)
+	}
+	if field := strings.TrimSpace(line[22:26]); !isIntegerField(field) {
+		resSeq := 1
+		if previous != nil {
+			resSeq = previous.ResSeq
+		}
+		patch(22, 26, fmt.Sprintf("%4d", resSeq), "invalid residue number %q, using %d", field, resSeq)
+	}
+	if field := strings.TrimSpace(safeColumns(line, 54, 60)); field != "" && !isFloatField(field) {
+		patch(54, 60, "  1.00", "invalid occupancy %q, using 1.00", field)
+	}
+	if field := strings.TrimSpace(safeColumns(line, 60, 66)); field != "" && !isFloatField(field) {
+		patch(60, 66, "  0.00", "invalid temperature factor %q, using 0.00", field)
+	}
+	return line, problems, true
+}
+
+// parseAtomFields reads an ATOM/HETATM record written as whitespace-separated fields rather than in
+// fixed columns: record name, serial number, atom name, residue name, chain ID (optional), residue
+// number, coordinates, occupancy and temperature factor (optional), and element symbol (optional).
+// A serial or residue number too large for its columns is wrapped, as molecular dynamics tools do.
+func parseAtomFields(line string, previous *AtomRecord) (*AtomRecord, []string, bool) {
+	fields := strings.Fields(line)
+	atom := &AtomRecord{Het: strings.HasPrefix(fields[0], "HETATM"), AltLoc: ' ', ChainID: ' ', ICode: ' ', Occupancy: 1.0}
+
+	// The serial number runs into the record name when it is too large for its columns
+	var identifiers []string
+	if serial := strings.TrimLeft(fields[0], "ATOMHET"); serial != "" {
+		identifiers = append(identifiers, serial)
+	}
+	coords := -1
+	for i := 1; i+2 < len(fields); i++ {
+		if isDecimalField(fields[i]) && isDecimalField(fields[i+1]) && isDecimalField(fields[i+2]) {
+			coords = i
+			break
+		}
+	}
+	if coords < 0 {
+		return nil, nil, false
+	}
+	identifiers = append(identifiers, fields[1:coords]...)
+
+	// The identifiers are read from the residue number back, as the chain ID and serial number are optional
+	if len(identifiers) < 3 {
+		return nil, nil, false
+	}
+	resSeq := identifiers[len(identifiers)-1]
+	identifiers = identifiers[:len(identifiers)-1]
+	if n := len(resSeq); n > 1 && unicode.IsLetter(rune(resSeq[n-1])) {
+		atom.ICode = resSeq[n-1]
+		resSeq = resSeq[:n-1]
+	}
+	if len(resSeq) > 1 && unicode.IsLetter(rune(resSeq[0])) {
+		atom.ChainID = resSeq[0]
+		resSeq = resSeq[1:]
+	} else if len(identifiers) >= 3 && len(identifiers[len(identifiers)-1]) == 1 {
+		atom.ChainID = identifiers[len(identifiers)-1][0]
+		identifiers = identifiers[:len(identifiers)-1]
+	}
+	var err error
+	if atom.ResSeq, err = strconv.Atoi(resSeq); err != nil {
+		return nil, nil, false
+	}
+	atom.ResName = identifiers[len(identifiers)-1]
+	atom.Name = identifiers[len(identifiers)-2]
+	identifiers = identifiers[:len(identifiers)-2]
+	if len(atom.ResName) > 3 || len(atom.Name) > 4 {
+		return nil, nil, false
+	}
+	switch len(identifiers) {
+	case 0:
+		atom.Serial = nextSerial(previous)
+	case 1:
+		if atom.Serial, err = strconv.Atoi(identifiers[0]); err != nil {
+			return nil, nil, false
+		}
+	default:
+		return nil, nil, false
+	}
+
+	atom.X, _ = strconv.ParseFloat(fields[coords], 64)
+	atom.Y, _ = strconv.ParseFloat(fields[coords+1], 64)
+	atom.Z, _ = strconv.ParseFloat(fields[coords+2], 64)
+	rest := fields[coords+3:]
+	if len(rest) > 0 && isDecimalField(rest[0]) {
+		atom.Occupancy, _ = strconv.ParseFloat(rest[0], 64)
+		rest = rest[1:]
+		if len(rest) > 0 && isDecimalField(rest[0]) {
+			atom.TempFactor, _ = strconv.ParseFloat(rest[0], 64)
+			rest = rest[1:]
+		}
+	}
+	if len(rest) > 0 && isElementSymbol(strings.ToUpper(rest[len(rest)-1])) {
+		atom.Element = strings.ToUpper(rest[len(rest)-1])
+	}
+
+	var problems []string
+	if atom.Serial > 99999 || atom.Serial < 0 {
+		wrapped := ((atom.Serial % 100000) + 100000) % 100000
+		problems = append(problems, fmt.Sprintf("atom serial number %d does not fit in columns 7-11, wrapped to %d", atom.Serial, wrapped))
+		atom.Serial = wrapped
+	}
+	if atom.ResSeq > 9999 || atom.ResSeq < -999 {
+		wrapped := atom.ResSeq % 10000
+		if wrapped < -999 {
+			wrapped += 10000
+		}
+		problems = append(problems, fmt.Sprintf("residue number %d does not fit in columns 23-26, wrapped to %d", atom.ResSeq, wrapped))
+		atom.ResSeq = wrapped
+	}
+	return atom, problems, true
+}
+
+// nextSerial returns the serial number of the atom after previous, which may be nil
+func nextSerial(previous *AtomRecord) int {
+	if previous == nil {
+		return 1
+	}
+	return previous.Serial + 1
+}
+
+// isFloatField reports whether a field holds a number
+func isFloatField(field string) bool {
+	_, err := strconv.ParseFloat(field, 64)
+	return err == nil
+}
+
+// isDecimalField reports whether a field holds a number written with a decimal point, as coordinates,
+// occupancies and temperature factors are
+func isDecimalField(field string) bool {
+	return strings.Contains(field, ".") && isFloatField(field)
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	trimOutput string
+	trimTo     string
+	trimChains string
+	trimBatch  batchOptions
+)
+
+var trimSidechainsCmd = &cobra.Command{
+	Use:   "trim-sidechains [flags] [input_file...]",
+	Short: "Truncate amino acids to a poly-Ala or poly-Gly backbone model",
+	Long: `Strip the side chains of amino acid residues and rename them, producing backbone models for molecular
+replacement search models and design scaffolds.
+
+--to ala (the default) keeps CB and renames the residues to ALA (a poly-Ala model); --to gly also
+removes CB and renames them to GLY (a backbone-only poly-Gly model). Glycines are left unchanged by
+--to ala. N, CA, C, O and OXT are kept, with the hydrogens on N; other hydrogens of trimmed residues
+are removed. Modified amino acids (e.g. MSE) are trimmed and written as ATOM records; nucleotides,
+ligands and waters are not changed. LINK and SSBOND records of removed atoms are dropped.
+--chains restricts trimming to chains, optionally with residue ranges (A:10-120).
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Make a poly-Ala search model for molecular replacement
+  pdbtk trim-sidechains 1a02.pdb --output 1a02_polyala.pdb
+
+  # Make a poly-Gly backbone model of chain A
+  pdbtk trim-sidechains --to gly --chains A 1a02.pdb`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runTrimSidechains,
+}
+
+func init() {
+	trimSidechainsCmd.Flags().StringVarP(&trimOutput, "output", "o", "", "Output file (default: stdout)")
+	trimSidechainsCmd.Flags().StringVar(&trimTo, "to", "ala", "Residue to truncate to: ala (keep CB) or gly (backbone only)")
+	trimSidechainsCmd.Flags().StringVarP(&trimChains, "chains", "c", "", "Comma-separated list of chain IDs to trim, optionally with residue ranges (default: all chains)")
+	addBatchFlags(trimSidechainsCmd, &trimBatch, "{name}")
+}
+
+func runTrimSidechains(cmd *cobra.Command, args []string) error {
+	var target string
+	switch trimTo {
+	case "ala":
+		target = "ALA"
+	case "gly":
+		target = "GLY"
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --to: %s (must be ala or gly)", trimTo))
+	}
+	var selections chainSelections
+	if trimChains != "" {
+		var err error
+		if selections, err = parseChainSelections(trimChains); err != nil {
+			return err
+		}
+	}
+
+	return runBatch(cmd.Context(), args, trimOutput, trimBatch, func(inputFile string, writer io.Writer) error {
+		file, err := readInputRecords(cmd.Context(), inputFile)
+		if err != nil {
+			return err
+		}
+		type residueAt struct {
+			chainID  byte
+			position residuePosition
+		}
+		trimmed := make(map[residueAt]bool)
+		firstModel := file.Models()[0]
+		var atoms []*AtomRecord
+		for _, residue := range groupResidues(file.Atoms) {
+			key := residue[0].Residue()
+			if !isAminoAcid(residue) || (selections != nil && !selections.Contains(key.ChainID, key.ResSeq, key.ICode)) ||
+				(key.ResName == "GLY" && target == "ALA") {
+				atoms = append(atoms, residue...)
+				continue
+			}
+			if key.Model == firstModel {
+				trimmed[residueAt{key.ChainID, residuePosition{ResSeq: key.ResSeq, ICode: key.ICode}}] = true
+			}
+			atoms = append(atoms, trimResidue(residue, target)...)
+		}
+		if len(trimmed) == 0 {
+			if err := warn("no amino acid residues to trim"); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(cmd.ErrOrStderr(), "Trimmed %d residues to %s\n", len(trimmed), target)
+
+		header := dropResidueLinks(file.Header, func(chainID byte, position residuePosition, name string) bool {
+			return trimmed[residueAt{chainID, position}] && !keptAtom(name, target)
+		})
+		return writePDBRecords(&PDBFile{Header: header, Atoms: atoms, Conect: file.Conect}, writer, recordCommandLine(cmd, nil, inputFile))
+	})
+}
+
+// isAminoAcid reports whether a residue is a standard amino acid, or a modified amino acid with an
+// amino acid backbone (N, CA and C)
+func isAminoAcid(residue []*AtomRecord) bool {
+	if _, ok := sidechainAtoms[residue[0].ResName]; ok {
+		return true
+	}
+	names := make(map[string]bool)
+	for _, atom := range residue {
+		names[atom.Name] = true
+	}
+	return names["N"] && names["CA"] && names["C"]
+}
+
+// trimResidue returns the atoms of a residue that are kept when it is truncated to resName (ALA or GLY),
+// renamed
+func trimResidue(residue []*AtomRecord, resName string) []*AtomRecord {
+	var kept []*AtomRecord
+	for _, atom := range residue {
+		switch atom.Name {
+		case "H", "H1", "H2", "H3":
+		default:
+			if !keptAtom(atom.Name, resName) {
+				continue
+			}
+		}
+		copied := *atom
+		copied.ResName, copied.Het = resName, false
+		kept = append(kept, &copied)
+	}
+	return kept
+}
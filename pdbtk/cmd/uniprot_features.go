@@ -0,0 +1,333 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	featuresChain  string
+	featuresPDB    string
+	featuresXML    string
+	featuresTypes  []string
+	featuresScript string
+	featuresOutput string
+)
+
+// uniprotURLEnv overrides the base URL of the UniProt REST API, e.g. for a mirror
+const uniprotURLEnv = "PDBTK_UNIPROT_URL"
+
+// uniprotURL returns the base URL of the UniProt REST API
+func uniprotURL() string {
+	if url := os.Getenv(uniprotURLEnv); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://rest.uniprot.org"
+}
+
+// featureColors are the colours of the features in the generated scripts, known to both PyMOL and ChimeraX
+var featureColors = []string{"red", "blue", "green", "orange", "magenta", "cyan", "yellow", "purple", "salmon", "pink"}
+
+var uniprotFeaturesCmd = &cobra.Command{
+	Use:   "uniprot-features [flags] [input_file]",
+	Short: "Map UniProt features onto a structure through SIFTS",
+	Long: `Fetch the sequence features (domains, active sites, binding sites, natural variants, ...) of the
+UniProt entries of a structure, map them onto its residues with the SIFTS residue-level mapping and
+encode them in the B-factor column: residues covered by the first feature get 1, by the second 2, and
+so on (the first feature wins where features overlap), all other residues 0. The numbered features
+are listed on stderr.
+
+With --script pymol or --script chimerax, a PyMOL (.pml) or ChimeraX (.cxc) script that loads the
+structure and colours each feature is written instead.
+
+--feature selects the UniProt feature types to map, e.g. Domain (the default), "Active site",
+"Binding site", "Natural variant", Region or Motif; types are case-insensitive and may use
+underscores instead of spaces. The SIFTS mapping is downloaded for the ID code in the HEADER record
+of the structure (or --pdb); use --xml to read a local SIFTS file. Features are fetched from
+https://rest.uniprot.org; set PDBTK_UNIPROT_URL to use a mirror.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Encode the UniProt domains of chain A into the B-factors
+  pdbtk uniprot-features --chain A --output 1a02_domains.pdb 1a02.pdb
+
+  # Colour the active and binding sites in PyMOL
+  pdbtk uniprot-features --feature active_site,binding_site --script pymol --output sites.pml 1a02.pdb
+
+  # Show natural variants in ChimeraX, with a local SIFTS file
+  pdbtk uniprot-features --xml 1a02.xml.gz --feature natural_variant --script chimerax --output variants.cxc 1a02.pdb`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runUniprotFeatures,
+}
+
+func init() {
+	uniprotFeaturesCmd.Flags().StringVarP(&featuresChain, "chain", "c", "", "Only map features onto this chain (default: all chains)")
+	uniprotFeaturesCmd.Flags().StringVar(&featuresPDB, "pdb", "", "PDB code of the SIFTS mapping (default: ID code of the structure)")
+	uniprotFeaturesCmd.Flags().StringVar(&featuresXML, "xml", "", "Read the mapping from a local SIFTS XML file instead of downloading it")
+	uniprotFeaturesCmd.Flags().StringSliceVar(&featuresTypes, "feature", []string{"Domain"}, "UniProt feature types to map (comma-separated)")
+	uniprotFeaturesCmd.Flags().StringVar(&featuresScript, "script", "", "Write a visualization script instead of the structure: pymol or chimerax")
+	uniprotFeaturesCmd.Flags().StringVarP(&featuresOutput, "output", "o", "", "Output file (default: stdout)")
+}
+
+// uniprotFeature is a sequence feature of a UniProt entry
+type uniprotFeature struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Location    struct {
+		Start struct {
+			Value int `json:"value"`
+		} `json:"start"`
+		End struct {
+			Value int `json:"value"`
+		} `json:"end"`
+	} `json:"location"`
+}
+
+// mappedFeature is a UniProt feature and the structure residues it covers
+type mappedFeature struct {
+	Accession string
+	Feature   uniprotFeature
+	Residues  []ResidueKey
+}
+
+// uniprotPosition is a residue of a UniProt sequence
+type uniprotPosition struct {
+	Accession string
+	ResNum    int
+}
+
+func runUniprotFeatures(cmd *cobra.Command, args []string) error {
+	if featuresChain != "" && len(featuresChain) != 1 {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("chain ID must be a single character, got: %s", featuresChain))
+	}
+	if featuresScript != "" && featuresScript != "pymol" && featuresScript != "chimerax" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --script: %s (must be pymol or chimerax)", featuresScript))
+	}
+	if featuresPDB != "" && featuresXML != "" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("--pdb cannot be combined with --xml"))
+	}
+	types := make(map[string]bool)
+	for _, t := range featuresTypes {
+		types[normalizeFeatureType(t)] = true
+	}
+
+	var inputFile string
+	if len(args) == 1 {
+		inputFile = args[0]
+		if err := CheckFileExistsContext(cmd.Context(), inputFile); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+	} else if err := checkStdinAvailable(); err != nil {
+		return err
+	}
+	file, err := readInputRecords(cmd.Context(), inputFile)
+	if err != nil {
+		return err
+	}
+
+	var residues []siftsResidue
+	switch {
+	case featuresXML != "":
+		if err := CheckFileExistsContext(cmd.Context(), featuresXML); err != nil {
+			return withCode(ErrCodeInputNotFound, err)
+		}
+		residues, err = readSiftsFile(cmd.Context(), featuresXML)
+	case featuresPDB != "":
+		residues, err = fetchSifts(cmd.Context(), featuresPDB)
+	case file.IdCode() != "":
+		residues, err = fetchSifts(cmd.Context(), file.IdCode())
+	default:
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("the structure has no ID code, specify --pdb or --xml"))
+	}
+	if err != nil {
+		return err
+	}
+
+	// UniProt residue of each observed structure residue
+	positions := make(map[string]uniprotPosition)
+	var accessions []string
+	for _, residue := range residues {
+		n, err := strconv.Atoi(residue.UniProtResNum)
+		if !residue.Observed || residue.UniProt == "" || err != nil || (featuresChain != "" && residue.Chain != featuresChain) {
+			continue
+		}
+		positions[residue.Chain+":"+residue.ResNum] = uniprotPosition{residue.UniProt, n}
+		if !containsString(accessions, residue.UniProt) {
+			accessions = append(accessions, residue.UniProt)
+		}
+	}
+	if len(accessions) == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no residues mapped to UniProt in the SIFTS mapping"))
+	}
+
+	var features []*mappedFeature
+	for _, accession := range accessions {
+		var entry struct {
+			Features []uniprotFeature `json:"features"`
+		}
+		err := fetchJSON(cmd.Context(), uniprotURL()+"/uniprotkb/"+accession+".json", &entry)
+		if errors.Is(err, errNotFound) {
+			if err := warn("UniProt entry not found: %s", accession); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, feature := range entry.Features {
+			if types[normalizeFeatureType(feature.Type)] && feature.Location.Start.Value > 0 && feature.Location.End.Value > 0 {
+				features = append(features, &mappedFeature{Accession: accession, Feature: feature})
+			}
+		}
+	}
+
+	// Assign every residue to the first feature covering it
+	firstModel := file.Models()[0]
+	atoms := make([]*AtomRecord, len(file.Atoms))
+	assigned := make(map[ResidueKey]int)
+	for i, atom := range file.Atoms {
+		atoms[i] = atom.Copy()
+		atoms[i].TempFactor = 0
+		key := atom.Residue()
+		number, seen := assigned[key]
+		if !seen {
+			position, ok := positions[fmt.Sprintf("%c:%d%s", atom.ChainID, atom.ResSeq, strings.TrimSpace(string(atom.ICode)))]
+			for j, f := range features {
+				if ok && f.Accession == position.Accession && position.ResNum >= f.Feature.Location.Start.Value && position.ResNum <= f.Feature.Location.End.Value {
+					number = j + 1
+					if key.Model == firstModel {
+						f.Residues = append(f.Residues, key)
+					}
+					break
+				}
+			}
+			assigned[key] = number
+		}
+		atoms[i].TempFactor = float64(number)
+	}
+
+	mapped := 0
+	for i, f := range features {
+		location := fmt.Sprintf("%d-%d", f.Feature.Location.Start.Value, f.Feature.Location.End.Value)
+		fmt.Fprintf(cmd.ErrOrStderr(), "%d\t%s\t%s:%s\t%s\t%d residues\n", i+1, f.Feature.Type, f.Accession, location, f.Feature.Description, len(f.Residues))
+		if len(f.Residues) > 0 {
+			mapped++
+		}
+	}
+	if mapped == 0 {
+		return withCode(ErrCodeNoMatch, fmt.Errorf("no %s features map onto the structure", strings.Join(featuresTypes, ", ")))
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "Mapped %d of %d features onto the structure\n", mapped, len(features))
+
+	return writeOutput(cmd.Context(), featuresOutput, func(w io.Writer) error {
+		switch featuresScript {
+		case "pymol":
+			writePymolFeatureScript(w, recordCommandLine(cmd, nil, inputFile), inputFile, features)
+		case "chimerax":
+			writeChimeraXFeatureScript(w, recordCommandLine(cmd, nil, inputFile), inputFile, features)
+		default:
+			return writePDBRecords(file.WithAtoms(atoms), w, recordCommandLine(cmd, nil, inputFile))
+		}
+		return nil
+	})
+}
+
+// normalizeFeatureType lower-cases a feature type and replaces underscores and hyphens with spaces
+func normalizeFeatureType(t string) string {
+	return strings.NewReplacer("_", " ", "-", " ").Replace(strings.ToLower(strings.TrimSpace(t)))
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// residueSelections returns the residues of each chain as ranges of residue numbers, in the order the
+// chains first appear: consecutive residue numbers without insertion codes are joined with rangeSep and
+// the ranges of a chain are separated by listSep
+func residueSelections(residues []ResidueKey, rangeSep, listSep string) ([]byte, map[byte]string) {
+	var chains []byte
+	byChain := make(map[byte][]string)
+	for i := 0; i < len(residues); i++ {
+		r := residues[i]
+		if _, ok := byChain[r.ChainID]; !ok {
+			chains = append(chains, r.ChainID)
+		}
+		label := strconv.Itoa(r.ResSeq) + strings.TrimSpace(string(r.ICode))
+		if r.ICode == ' ' {
+			end := i
+			for end+1 < len(residues) && residues[end+1].ChainID == r.ChainID && residues[end+1].ICode == ' ' && residues[end+1].ResSeq == residues[end].ResSeq+1 {
+				end++
+			}
+			if end > i {
+				label += rangeSep + strconv.Itoa(residues[end].ResSeq)
+				i = end
+			}
+		}
+		byChain[r.ChainID] = append(byChain[r.ChainID], label)
+	}
+	selections := make(map[byte]string)
+	for _, chain := range chains {
+		selections[chain] = strings.Join(byChain[chain], listSep)
+	}
+	return chains, selections
+}
+
+// writePymolFeatureScript writes a PyMOL script selecting and colouring each mapped feature
+func writePymolFeatureScript(w io.Writer, commandLine, inputFile string, features []*mappedFeature) {
+	fmt.Fprintf(w, "# Generated by pdbtk: %s\n", commandLine)
+	if inputFile != "" {
+		fmt.Fprintf(w, "load %s\n", filepath.Base(inputFile))
+	}
+	fmt.Fprintln(w, "color grey80")
+	for i, f := range features {
+		if len(f.Residues) == 0 {
+			continue
+		}
+		chains, selections := residueSelections(f.Residues, "-", "+")
+		var parts []string
+		for _, chain := range chains {
+			parts = append(parts, fmt.Sprintf("(chain %c and resi %s)", chain, selections[chain]))
+		}
+		name := fmt.Sprintf("feature_%d", i+1)
+		fmt.Fprintf(w, "# %d: %s %s %d-%d %s\n", i+1, f.Feature.Type, f.Accession, f.Feature.Location.Start.Value, f.Feature.Location.End.Value, f.Feature.Description)
+		fmt.Fprintf(w, "select %s, %s\n", name, strings.Join(parts, " or "))
+		fmt.Fprintf(w, "color %s, %s\n", featureColors[i%len(featureColors)], name)
+	}
+	fmt.Fprintln(w, "deselect")
+}
+
+// writeChimeraXFeatureScript writes a ChimeraX command script colouring and naming each mapped feature
+func writeChimeraXFeatureScript(w io.Writer, commandLine, inputFile string, features []*mappedFeature) {
+	fmt.Fprintf(w, "# Generated by pdbtk: %s\n", commandLine)
+	if inputFile != "" {
+		fmt.Fprintf(w, "open %s\n", filepath.Base(inputFile))
+	}
+	fmt.Fprintln(w, "color lightgray")
+	for i, f := range features {
+		if len(f.Residues) == 0 {
+			continue
+		}
+		chains, selections := residueSelections(f.Residues, "-", ",")
+		var spec strings.Builder
+		for _, chain := range chains {
+			fmt.Fprintf(&spec, "/%c:%s", chain, selections[chain])
+		}
+		fmt.Fprintf(w, "# %d: %s %s %d-%d %s\n", i+1, f.Feature.Type, f.Accession, f.Feature.Location.Start.Value, f.Feature.Location.End.Value, f.Feature.Description)
+		fmt.Fprintf(w, "name feature_%d %s\n", i+1, spec.String())
+		fmt.Fprintf(w, "color feature_%d %s\n", i+1, featureColors[i%len(featureColors)])
+	}
+}
@@ -0,0 +1,588 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateOutput string
+	validateFormat string
+)
+
+// ErrCodeValidation is reported when validate finds errors in a file
+const ErrCodeValidation = "validation_failed"
+
+// Severities of validation violations
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [flags] [input_file...]",
+	Short: "Check PDB or mmCIF files for format violations",
+	Long: `Check PDB or mmCIF files for format violations and print a list of problems with their severities.
+
+For PDB files this checks fixed-column alignment of coordinate records, record ordering
+(HEADER, CRYST1, MODEL/ENDMDL, CONECT, END), atom serial number continuity, chain/TER consistency,
+chains split into separate blocks, residue numbers used by more than one residue, element symbols
+and that ALTLOC occupancies of each atom sum to 1.
+For mmCIF files (.cif, .mmcif or content starting with data_) this checks the syntax, the presence
+of mandatory _atom_site items, numeric values, unique atom IDs and ALTLOC occupancies.
+
+The command fails if any errors are found (or any warnings with --strict).
+The input is checked as it is: --tolerant does not repair it first.
+If no input file is specified, reads from stdin.
+
+Examples:
+  # Validate a PDB file
+  pdbtk validate 1a02.pdb
+
+  # Validate all structures in a directory and report as JSON
+  pdbtk validate --format json structures/*.pdb structures/*.cif`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runValidate,
+}
+
+func init() {
+	validateCmd.Flags().StringVarP(&validateOutput, "output", "o", "", "Output file (default: stdout)")
+	validateCmd.Flags().StringVarP(&validateFormat, "format", "f", "text", "Output format: text or json")
+}
+
+// violation is a single problem found by validate
+type violation struct {
+	Line     int    `json:"line,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// validationResult holds the violations found in one file
+type validationResult struct {
+	File       string      `json:"file"`
+	Format     string      `json:"format"`
+	Errors     int         `json:"errors"`
+	Warnings   int         `json:"warnings"`
+	Violations []violation `json:"violations"`
+}
+
+func (r *validationResult) add(line int, severity string, format string, args ...interface{}) {
+	r.Violations = append(r.Violations, violation{Line: line, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	if severity == SeverityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	if validateFormat != "text" && validateFormat != "json" {
+		return withCode(ErrCodeInvalidArgument, fmt.Errorf("invalid --format: %s (must be text or json)", validateFormat))
+	}
+
+	var inputs []string
+	if len(args) == 0 {
+		if err := checkStdinAvailable(); err != nil {
+			return err
+		}
+		inputs = []string{""}
+	} else {
+		var err error
+		if inputs, err = expandInputs(cmd.Context(), args); err != nil {
+			return err
+		}
+	}
+
+	var results []*validationResult
+	for _, inputFile := range inputs {
+		content, err := readRawInputContent(cmd.Context(), inputFile)
+		if err != nil {
+			recordResult(inputFile, "", err)
+			return err
+		}
+		var result *validationResult
+		if isCIFFilename(inputFile) || (inputFile == "" && looksLikeCIF(content)) {
+			result = validateCIF(content)
+		} else {
+			result = validatePDB(content)
+		}
+		result.File = inputFile
+		if inputFile == "" {
+			result.File = "stdin"
+		}
+		results = append(results, result)
+	}
+
+	err := writeOutput(cmd.Context(), validateOutput, func(w io.Writer) error {
+		if validateFormat == "json" {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(results)
+		}
+		return writeValidationText(results, w)
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, result := range results {
+		var resultErr error
+		if result.Errors > 0 {
+			resultErr = withCode(ErrCodeValidation, fmt.Errorf("%d errors found", result.Errors))
+		} else if strictMode && result.Warnings > 0 {
+			resultErr = withCode(ErrCodeStrict, fmt.Errorf("%d warnings found (treated as errors in --strict mode)", result.Warnings))
+		}
+		recordResult(result.File, validateOutput, resultErr)
+		if resultErr != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		code := ErrCodeValidation
+		if strictMode {
+			code = ErrCodeStrict
+		}
+		return withCode(code, fmt.Errorf("validation failed for %d of %d files", failed, len(results)))
+	}
+	return nil
+}
+
+func writeValidationText(results []*validationResult, w io.Writer) error {
+	for _, result := range results {
+		for _, v := range result.Violations {
+			if v.Line > 0 {
+				fmt.Fprintf(w, "%s:%d: %s: %s\n", result.File, v.Line, v.Severity, v.Message)
+			} else {
+				fmt.Fprintf(w, "%s: %s: %s\n", result.File, v.Severity, v.Message)
+			}
+		}
+		fmt.Fprintf(w, "%s: %s format, %d errors, %d warnings\n", result.File, result.Format, result.Errors, result.Warnings)
+	}
+	return nil
+}
+
+// altLocKey identifies an atom position that may have alternate conformations
+type altLocKey struct {
+	model   int
+	chainID string
+	resSeq  string
+	iCode   string
+	name    string
+}
+
+type altLocSum struct {
+	line      int
+	occupancy float64
+	count     int
+}
+
+// checkAltLocOccupancies reports atoms whose alternate conformations have occupancies not summing to 1
+func checkAltLocOccupancies(result *validationResult, sums map[altLocKey]*altLocSum, order []altLocKey) {
+	for _, key := range order {
+		sum := sums[key]
+		if sum.count > 1 && math.Abs(sum.occupancy-1.0) > 0.02 {
+			result.add(sum.line, SeverityWarning, "occupancies of alternate locations of atom %s in residue %s:%s%s sum to %.2f",
+				key.name, key.chainID, key.resSeq, strings.TrimSpace(key.iCode), sum.occupancy)
+		}
+	}
+}
+
+// validatePDB checks the records of a PDB format file
+func validatePDB(content []byte) *validationResult {
+	result := &validationResult{Format: "PDB", Violations: make([]violation, 0)}
+
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	inModel := false
+	usesModels := false
+	seenCoords := false
+	seenEnd := false
+	lastSerial := 0
+	model := 1
+	modelNumbers := make(map[int]bool)
+	var lastAtomChain byte
+	lastWasAtom := false
+	terminatedChains := make(map[byte]bool)
+	altLocSums := make(map[altLocKey]*altLocSum)
+	var altLocOrder []altLocKey
+
+	for i, line := range lines {
+		lineNum := i + 1
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		if len(line) > 80 {
+			result.add(lineNum, SeverityWarning, "line is longer than 80 columns (%d)", len(line))
+		}
+		recordName := strings.TrimSpace(safeColumns(line, 0, 6))
+
+		if seenEnd {
+			result.add(lineNum, SeverityError, "%s record after END", recordName)
+			seenEnd = false
+		}
+
+		switch recordName {
+		case "HEADER":
+			if i != 0 {
+				result.add(lineNum, SeverityWarning, "HEADER is not the first record")
+			}
+		case "CRYST1", "ORIGX1", "ORIGX2", "ORIGX3", "SCALE1", "SCALE2", "SCALE3", "SEQRES", "HELIX", "SHEET":
+			if seenCoords {
+				result.add(lineNum, SeverityError, "%s record after coordinate records", recordName)
+			}
+		case "MODEL":
+			if inModel {
+				result.add(lineNum, SeverityError, "MODEL record without ENDMDL for the previous model")
+			}
+			if seenCoords && !usesModels {
+				result.add(lineNum, SeverityError, "MODEL record after coordinates outside of any model")
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 10, 14)))
+			if err != nil {
+				result.add(lineNum, SeverityError, "invalid MODEL serial number (columns 11-14)")
+			} else if modelNumbers[n] {
+				result.add(lineNum, SeverityError, "duplicate MODEL number %d", n)
+			} else {
+				modelNumbers[n] = true
+				model = n
+			}
+			inModel = true
+			usesModels = true
+			lastSerial = 0
+			lastWasAtom = false
+			terminatedChains = make(map[byte]bool)
+		case "ENDMDL":
+			if !inModel {
+				result.add(lineNum, SeverityError, "ENDMDL record without MODEL")
+			}
+			inModel = false
+		case "ATOM", "HETATM":
+			if usesModels && !inModel {
+				result.add(lineNum, SeverityError, "coordinate record outside of MODEL/ENDMDL")
+			}
+			seenCoords = true
+			serial, chainID, ok := validateAtomLine(result, lineNum, line)
+			if !ok {
+				continue
+			}
+			if lastSerial != 0 && serial != lastSerial+1 {
+				result.add(lineNum, SeverityWarning, "atom serial number %d does not follow %d", serial, lastSerial)
+			}
+			lastSerial = serial
+
+			if recordName == "ATOM" {
+				if terminatedChains[chainID] {
+					result.add(lineNum, SeverityError, "chain %c continues after its TER record", chainID)
+					delete(terminatedChains, chainID)
+				}
+				if lastWasAtom && chainID != lastAtomChain && !terminatedChains[lastAtomChain] {
+					result.add(lineNum, SeverityWarning, "missing TER record at the end of chain %c", lastAtomChain)
+				}
+				lastAtomChain = chainID
+				lastWasAtom = true
+			} else {
+				lastWasAtom = false
+			}
+
+			if altLoc := safeColumns(line, 16, 17); altLoc != " " && len(line) >= 60 {
+				key := altLocKey{model, string(chainID), strings.TrimSpace(line[22:26]), line[26:27], strings.TrimSpace(line[12:16])}
+				occupancy, err := strconv.ParseFloat(strings.TrimSpace(line[54:60]), 64)
+				if err == nil {
+					if _, ok := altLocSums[key]; !ok {
+						altLocSums[key] = &altLocSum{line: lineNum}
+						altLocOrder = append(altLocOrder, key)
+					}
+					altLocSums[key].occupancy += occupancy
+					altLocSums[key].count++
+				}
+			}
+		case "TER":
+			if !lastWasAtom {
+				result.add(lineNum, SeverityWarning, "TER record does not follow an ATOM record")
+			} else {
+				terminatedChains[lastAtomChain] = true
+			}
+			lastWasAtom = false
+			if serial, err := strconv.Atoi(strings.TrimSpace(safeColumns(line, 6, 11))); err == nil {
+				lastSerial = serial
+			}
+		case "CONECT":
+			if !seenCoords {
+				result.add(lineNum, SeverityError, "CONECT record before coordinate records")
+			}
+		case "END":
+			seenEnd = true
+		}
+	}
+
+	if inModel {
+		result.add(0, SeverityError, "missing ENDMDL record for the last model")
+	}
+	if !seenCoords {
+		result.add(0, SeverityError, "no ATOM or HETATM records found")
+	}
+	if len(lines) == 0 || strings.TrimSpace(lines[len(lines)-1]) != "END" {
+		result.add(0, SeverityWarning, "file does not end with an END record")
+	}
+	checkAltLocOccupancies(result, altLocSums, altLocOrder)
+	if file, err := ParsePDBRecords(strings.NewReader(string(content))); err == nil {
+		for _, collision := range findIdentifierCollisions(file.Atoms) {
+			result.add(0, collision.Severity, "%s", collision.Message)
+		}
+	}
+	return result
+}
+
+// validateAtomLine checks the fixed columns of an ATOM/HETATM record. It returns the serial number and
+// chain ID, and false if the record is too malformed for further checks.
+func validateAtomLine(result *validationResult, lineNum int, line string) (int, byte, bool) {
+	if len(line) < 54 {
+		result.add(lineNum, SeverityError, "coordinate record too short (%d columns, need at least 54)", len(line))
+		return 0, 0, false
+	}
+
+	ok := true
+	serial, err := strconv.Atoi(strings.TrimSpace(line[6:11]))
+	if err != nil {
+		result.add(lineNum, SeverityError, "invalid atom serial number %q (columns 7-11)", line[6:11])
+		ok = false
+	}
+	for _, col := range []int{11, 20} {
+		if line[col] != ' ' {
+			result.add(lineNum, SeverityError, "column %d should be blank, columns are misaligned", col+1)
+			ok = false
+		}
+	}
+	if strings.TrimSpace(line[12:16]) == "" {
+		result.add(lineNum, SeverityError, "missing atom name (columns 13-16)")
+	}
+	if strings.TrimSpace(line[17:20]) == "" {
+		result.add(lineNum, SeverityError, "missing residue name (columns 18-20)")
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(line[22:26])); err != nil {
+		result.add(lineNum, SeverityError, "invalid residue sequence number %q (columns 23-26)", line[22:26])
+		ok = false
+	}
+	if strings.TrimSpace(line[27:30]) != "" {
+		result.add(lineNum, SeverityError, "columns 28-30 should be blank, columns are misaligned")
+		ok = false
+	}
+	for i, axis := range []string{"x", "y", "z"} {
+		start := 30 + 8*i
+		field := line[start : start+8]
+		if _, err := strconv.ParseFloat(strings.TrimSpace(field), 64); err != nil {
+			result.add(lineNum, SeverityError, "invalid %s coordinate %q (columns %d-%d)", axis, field, start+1, start+8)
+			ok = false
+		} else if field[4] != '.' {
+			result.add(lineNum, SeverityWarning, "%s coordinate %q is not aligned to 3 decimal places", axis, field)
+		}
+	}
+	if len(line) < 66 {
+		result.add(lineNum, SeverityWarning, "missing occupancy and/or temperature factor (columns 55-66)")
+	} else {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(line[54:60]), 64); err != nil {
+			result.add(lineNum, SeverityError, "invalid occupancy %q (columns 55-60)", line[54:60])
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSpace(line[60:66]), 64); err != nil {
+			result.add(lineNum, SeverityError, "invalid temperature factor %q (columns 61-66)", line[60:66])
+		}
+	}
+	element := strings.TrimSpace(safeColumns(line, 76, 78))
+	if element == "" {
+		result.add(lineNum, SeverityWarning, "missing element symbol (columns 77-78)")
+	} else if !isElementSymbol(element) {
+		result.add(lineNum, SeverityError, "unknown element symbol %q (columns 77-78)", element)
+	} else if line[76] == ' ' && len(element) == 2 {
+		result.add(lineNum, SeverityWarning, "element symbol %q is not right-justified", element)
+	}
+	return serial, line[21], ok
+}
+
+// requiredAtomSiteItems are the _atom_site items needed to reconstruct coordinates from an mmCIF file
+var requiredAtomSiteItems = []string{
+	"group_PDB", "id", "type_symbol", "label_atom_id", "label_comp_id", "label_asym_id",
+	"label_entity_id", "label_seq_id", "Cartn_x", "Cartn_y", "Cartn_z", "occupancy", "B_iso_or_equiv",
+}
+
+// recommendedAtomSiteItems are commonly relied upon but not strictly required
+var recommendedAtomSiteItems = []string{
+	"label_alt_id", "auth_seq_id", "auth_asym_id", "pdbx_PDB_ins_code", "pdbx_PDB_model_num",
+}
+
+// validateCIF checks the syntax and mandatory items of an mmCIF file
+func validateCIF(content []byte) *validationResult {
+	result := &validationResult{Format: "mmCIF", Violations: make([]violation, 0)}
+
+	blocks, err := ParseCIF(strings.NewReader(string(content)))
+	if err != nil {
+		result.add(0, SeverityError, "syntax error: %v", err)
+		return result
+	}
+	if len(blocks) > 1 {
+		result.add(0, SeverityWarning, "file contains %d data blocks, only the first is checked", len(blocks))
+	}
+	block := blocks[0]
+
+	if _, ok := block.Items["_entry.id"]; !ok && !block.HasCategory("_entry") {
+		result.add(0, SeverityWarning, "missing _entry.id")
+	}
+
+	var atomSite *CIFLoop
+	for _, loop := range block.Loops {
+		if len(loop.Tags) > 0 && strings.HasPrefix(strings.ToLower(loop.Tags[0]), "_atom_site.") {
+			atomSite = loop
+			break
+		}
+	}
+	if atomSite == nil {
+		result.add(0, SeverityError, "missing _atom_site loop")
+		return result
+	}
+
+	for _, item := range requiredAtomSiteItems {
+		if atomSite.Column("_atom_site."+item) < 0 {
+			result.add(0, SeverityError, "missing mandatory item _atom_site.%s", item)
+		}
+	}
+	for _, item := range recommendedAtomSiteItems {
+		if atomSite.Column("_atom_site."+item) < 0 {
+			result.add(0, SeverityWarning, "missing item _atom_site.%s", item)
+		}
+	}
+
+	numeric := []string{"Cartn_x", "Cartn_y", "Cartn_z", "occupancy", "B_iso_or_equiv"}
+	idCol := atomSite.Column("_atom_site.id")
+	altCol := atomSite.Column("_atom_site.label_alt_id")
+	occCol := atomSite.Column("_atom_site.occupancy")
+	symbolCol := atomSite.Column("_atom_site.type_symbol")
+	ids := make(map[string]bool)
+	altLocSums := make(map[altLocKey]*altLocSum)
+	var altLocOrder []altLocKey
+
+	for rowNum, row := range atomSite.Rows {
+		for _, item := range numeric {
+			col := atomSite.Column("_atom_site." + item)
+			if col >= 0 && row[col] != "?" && row[col] != "." {
+				if _, err := strconv.ParseFloat(row[col], 64); err != nil {
+					result.add(0, SeverityError, "atom_site row %d: invalid %s %q", rowNum+1, item, row[col])
+				}
+			}
+		}
+		if idCol >= 0 {
+			if ids[row[idCol]] {
+				result.add(0, SeverityError, "atom_site row %d: duplicate atom id %s", rowNum+1, row[idCol])
+			}
+			ids[row[idCol]] = true
+		}
+		if symbolCol >= 0 && !isElementSymbol(row[symbolCol]) {
+			result.add(0, SeverityError, "atom_site row %d: unknown element symbol %q", rowNum+1, row[symbolCol])
+		}
+		if altCol >= 0 && occCol >= 0 && row[altCol] != "." && row[altCol] != "?" {
+			key := altLocKey{
+				model:   0,
+				chainID: cifValue(atomSite, row, "_atom_site.label_asym_id"),
+				resSeq:  cifValue(atomSite, row, "_atom_site.label_seq_id"),
+				iCode:   cifValue(atomSite, row, "_atom_site.pdbx_PDB_ins_code"),
+				name:    cifValue(atomSite, row, "_atom_site.label_atom_id"),
+			}
+			if modelNum, err := strconv.Atoi(cifValue(atomSite, row, "_atom_site.pdbx_PDB_model_num")); err == nil {
+				key.model = modelNum
+			}
+			if occupancy, err := strconv.ParseFloat(row[occCol], 64); err == nil {
+				if _, ok := altLocSums[key]; !ok {
+					altLocSums[key] = &altLocSum{}
+					altLocOrder = append(altLocOrder, key)
+				}
+				altLocSums[key].occupancy += occupancy
+				altLocSums[key].count++
+			}
+		}
+	}
+	checkAltLocOccupancies(result, altLocSums, altLocOrder)
+	atoms, chainNames := cifCollisionAtoms(block)
+	for _, collision := range findNamedIdentifierCollisions(atoms, chainNames) {
+		result.add(0, collision.Severity, "%s", collision.Message)
+	}
+	return result
+}
+
+// cifCollisionAtoms returns the atoms of _atom_site with the author identifiers that commands select and
+// renumber by: chain, residue number and insertion code, with the residue name and model. Author chain
+// IDs longer than one character are given unused one-character IDs, returned with the names they stand for.
+func cifCollisionAtoms(block *CIFBlock) ([]*AtomRecord, map[byte]string) {
+	rows := block.Category("_atom_site")
+	used := make(map[byte]bool)
+	for _, row := range rows {
+		if chain := cifRowValue(row, "auth_asym_id"); len(chain) == 1 {
+			used[chain[0]] = true
+		}
+	}
+	chainIDs := make(map[string]byte)
+	chainNames := make(map[byte]string)
+	next := 0
+	var atoms []*AtomRecord
+	for _, row := range rows {
+		chain := cifRowValue(row, "auth_asym_id")
+		if chain == "" {
+			chain = cifRowValue(row, "label_asym_id")
+		}
+		chainID, ok := chainIDs[chain]
+		if !ok {
+			if len(chain) == 1 {
+				chainID = chain[0]
+			} else {
+				// Any byte not used by another chain will do; it is only shown through chainNames
+				for next < 256 && used[byte(next)] {
+					next++
+				}
+				if next == 256 {
+					break
+				}
+				chainID = byte(next)
+				used[chainID] = true
+				chainNames[chainID] = chain
+			}
+			chainIDs[chain] = chainID
+		}
+		resSeq, err := strconv.Atoi(cifRowValue(row, "auth_seq_id"))
+		if err != nil {
+			continue
+		}
+		atom := &AtomRecord{
+			Het:     strings.EqualFold(row["group_PDB"], "HETATM"),
+			AltLoc:  ' ',
+			ResName: cifRowValue(row, "label_comp_id"),
+			ChainID: chainID,
+			ResSeq:  resSeq,
+			ICode:   ' ',
+			Model:   1,
+		}
+		if model, err := strconv.Atoi(cifRowValue(row, "pdbx_PDB_model_num")); err == nil {
+			atom.Model = model
+		}
+		if altLoc := cifRowValue(row, "label_alt_id"); altLoc != "" {
+			atom.AltLoc = altLoc[0]
+		}
+		if iCode := cifRowValue(row, "pdbx_PDB_ins_code"); iCode != "" {
+			atom.ICode = iCode[0]
+		}
+		atoms = append(atoms, atom)
+	}
+	return atoms, chainNames
+}
+
+// cifValue returns the value of tag in a loop row, or "" if the loop has no such column
+func cifValue(loop *CIFLoop, row []string, tag string) string {
+	col := loop.Column(tag)
+	if col < 0 {
+		return ""
+	}
+	return row[col]
+}
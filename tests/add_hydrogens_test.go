@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestAddHydrogensCommand(t *testing.T) {
+	testPDB := `ATOM      1  N   ALA A   1      -0.677  -1.230  -0.491  1.00 20.00           N
+ATOM      2  CA  ALA A   1      -0.001   0.064  -0.491  1.00 20.00           C
+ATOM      3  C   ALA A   1       1.499  -0.110  -0.491  1.00 20.00           C
+ATOM      4  O   ALA A   1       2.030  -1.227  -0.502  1.00 20.00           O
+ATOM      5  CB  ALA A   1      -0.509   0.856   0.727  1.00 20.00           C
+ATOM      6  N   SER A   2       2.218   1.007  -0.479  1.00 20.00           N
+ATOM      7  CA  SER A   2       3.666   0.981  -0.478  1.00 20.00           C
+ATOM      8  C   SER A   2       4.216   2.400  -0.465  1.00 20.00           C
+ATOM      9  O   SER A   2       3.451   3.363  -0.455  1.00 20.00           O
+ATOM     10  CB  SER A   2       4.183   0.220   0.741  1.00 20.00           C
+ATOM     11  OG  SER A   2       5.602   0.197   0.745  1.00 20.00           O
+HETATM   12  O   HOH A 101      10.000  10.000  10.000  1.00 20.00           O
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "add-hydrogens")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("add-hydrogens command failed: %v", err)
+	}
+
+	coords := make(map[string][3]float64)
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "ATOM") && !strings.HasPrefix(line, "HETATM") {
+			continue
+		}
+		var xyz [3]float64
+		for i := 0; i < 3; i++ {
+			xyz[i], _ = strconv.ParseFloat(strings.TrimSpace(line[30+8*i:38+8*i]), 64)
+		}
+		coords[strings.TrimSpace(line[12:16])+" "+strings.TrimSpace(line[17:20])] = xyz
+	}
+
+	// Expected hydrogens with the heavy atom they are bonded to and the ideal bond length
+	bonds := []struct {
+		hydrogen, parent string
+		length           float64
+	}{
+		{"HA ALA", "CA ALA", 1.09},
+		{"HB1 ALA", "CB ALA", 1.09},
+		{"HB2 ALA", "CB ALA", 1.09},
+		{"HB3 ALA", "CB ALA", 1.09},
+		{"H1 ALA", "N ALA", 1.01},
+		{"H SER", "N SER", 1.01},
+		{"HB2 SER", "CB SER", 1.09},
+		{"HB3 SER", "CB SER", 1.09},
+		{"HG SER", "OG SER", 0.96},
+	}
+	for _, bond := range bonds {
+		h, ok := coords[bond.hydrogen]
+		if !ok {
+			t.Errorf("Expected hydrogen %s to be added", bond.hydrogen)
+			continue
+		}
+		p := coords[bond.parent]
+		d := math.Sqrt((h[0]-p[0])*(h[0]-p[0]) + (h[1]-p[1])*(h[1]-p[1]) + (h[2]-p[2])*(h[2]-p[2]))
+		if math.Abs(d-bond.length) > 0.01 {
+			t.Errorf("Expected %s-%s bond length %.2f, got %.3f", bond.hydrogen, bond.parent, bond.length, d)
+		}
+	}
+	if strings.Contains(string(output), "HOH A 101") && strings.Count(string(output), "HOH") != 1 {
+		t.Error("Expected waters to be left unchanged")
+	}
+}
@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"math"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestAddMissingAtoms(t *testing.T) {
+	mutate := exec.Command("../bin/pdbtk", "mutate", "A:4", "LYS")
+	mutate.Stdin = strings.NewReader(mutateTestPDB)
+	complete, err := mutate.Output()
+	if err != nil {
+		t.Fatalf("mutate failed: %v", err)
+	}
+
+	// Truncate the lysine side chain and remove the O of residue 2
+	var truncated []string
+	for _, line := range strings.Split(string(complete), "\n") {
+		if strings.HasPrefix(line, "ATOM") && (line[12:16] == " CE " || line[12:16] == " NZ " ||
+			(line[12:16] == " O  " && strings.TrimSpace(line[22:26]) == "2")) {
+			continue
+		}
+		truncated = append(truncated, line)
+	}
+	cmd := exec.Command("../bin/pdbtk", "add-missing-atoms")
+	cmd.Stdin = strings.NewReader(strings.Join(truncated, "\n"))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("add-missing-atoms failed: %v\n%s", err, stderr.String())
+	}
+	for _, expected := range []string{"A:4 LYS: added CE, NZ", "A:2 ALA: added O", "Added 3 atoms to 2 residues"} {
+		if !strings.Contains(stderr.String(), expected) {
+			t.Errorf("Expected %q in stderr, got:\n%s", expected, stderr.String())
+		}
+	}
+
+	// The measured chi angles are kept, so the atoms are rebuilt where they were
+	for _, resSeq := range []int{2, 4} {
+		wantNames, want, _ := residueAtoms(string(complete), resSeq)
+		names, got, _ := residueAtoms(string(output), resSeq)
+		if strings.Join(names, " ") != strings.Join(wantNames, " ") {
+			t.Errorf("Residue %d atoms = %v, want %v", resSeq, names, wantNames)
+		}
+		for name, p := range want {
+			q := got[name]
+			if d := math.Sqrt((p[0]-q[0])*(p[0]-q[0]) + (p[1]-q[1])*(p[1]-q[1]) + (p[2]-q[2])*(p[2]-q[2])); d > 0.01 {
+				t.Errorf("Residue %d %s is %.3f Å from its original position", resSeq, name, d)
+			}
+		}
+	}
+
+	// A residue without CA is skipped with a warning, which fails under --strict
+	var noCA []string
+	for _, line := range truncated {
+		if !strings.HasPrefix(line, "ATOM") || line[12:16] != " CA " || strings.TrimSpace(line[22:26]) != "4" {
+			noCA = append(noCA, line)
+		}
+	}
+	cmd = exec.Command("../bin/pdbtk", "--strict", "add-missing-atoms")
+	cmd.Stdin = strings.NewReader(strings.Join(noCA, "\n"))
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected --strict to fail for a residue without CA")
+	}
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestAltLocSummary(t *testing.T) {
+	testPDB := `ATOM      1  N   SER A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA ASER A   1       1.000   0.000   0.000  0.60 20.00           C
+ATOM      3  CA BSER A   1       1.100   0.000   0.000  0.40 20.00           C
+ATOM      4  CA AGLY A   2       4.000   0.000   0.000  0.50 20.00           C
+ATOM      5  CA BALA A   2       4.100   0.000   0.000  0.30 20.00           C
+ATOM      6  CA  GLY B   1      10.000   0.000   0.000  1.00 20.00           C
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "altloc-summary", "--format", "json")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("altloc-summary failed: %v", err)
+	}
+	var summary struct {
+		Residues       int `json:"residues"`
+		AltLocResidues int `json:"altloc_residues"`
+		AltLocAtoms    int `json:"altloc_atoms"`
+		Identifiers    []struct {
+			ID       string `json:"id"`
+			Residues int    `json:"residues"`
+		} `json:"identifiers"`
+		Occupancy []struct {
+			Atoms int `json:"atoms"`
+		} `json:"occupancy"`
+		Chains []struct {
+			Chain          string `json:"chain"`
+			AltLocResidues int    `json:"altloc_residues"`
+		} `json:"chains"`
+		OccupancyIssues []string `json:"occupancy_not_one"`
+		ResidueList     []struct {
+			Residue string   `json:"residue"`
+			AltLocs []string `json:"altlocs"`
+		} `json:"altloc_residue_list"`
+	}
+	if err := json.Unmarshal(output, &summary); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if summary.Residues != 3 || summary.AltLocResidues != 2 || summary.AltLocAtoms != 4 {
+		t.Errorf("Unexpected counts: %+v", summary)
+	}
+	if len(summary.Identifiers) != 2 || summary.Identifiers[1].ID != "B" || summary.Identifiers[1].Residues != 2 {
+		t.Errorf("Unexpected identifiers: %+v", summary.Identifiers)
+	}
+	var bins []int
+	for _, bin := range summary.Occupancy {
+		bins = append(bins, bin.Atoms)
+	}
+	if len(bins) != 5 || bins[1] != 1 || bins[2] != 2 || bins[3] != 1 {
+		t.Errorf("Unexpected occupancy distribution: %v", bins)
+	}
+	if len(summary.Chains) != 2 || summary.Chains[0].AltLocResidues != 2 || summary.Chains[1].AltLocResidues != 0 {
+		t.Errorf("Unexpected chains: %+v", summary.Chains)
+	}
+	// The alternate locations of residue 2 are a GLY and an ALA with occupancies adding up to 0.8
+	if len(summary.OccupancyIssues) != 1 || summary.OccupancyIssues[0] != "A:2 GLY/ALA" {
+		t.Errorf("Unexpected occupancy issues: %v", summary.OccupancyIssues)
+	}
+	if len(summary.ResidueList) != 2 || strings.Join(summary.ResidueList[0].AltLocs, ",") != "A,B" {
+		t.Errorf("Unexpected residue list: %+v", summary.ResidueList)
+	}
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// archiveMembers are the files written to the test archives
+var archiveMembers = []struct{ name, content string }{
+	{"T1104/model1.pdb", "ATOM      1  CA  ALA A   1      11.000  10.000  10.000  1.00 10.00           C\nEND\n"},
+	{"T1104/model2.pdb", "ATOM      1  CA  GLY B   1      12.000  10.000  10.000  1.00 10.00           C\nEND\n"},
+	{"T1104/README.txt", "not a structure\n"},
+	{"T1106/model1.pdb", "ATOM      1  CA  SER A   1      13.000  10.000  10.000  1.00 10.00           C\nEND\n"},
+}
+
+func writeTestTarGz(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, member := range archiveMembers {
+		header := &tar.Header{Name: member.name, Mode: 0644, Size: int64(len(member.content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+		tw.Write([]byte(member.content))
+	}
+	tw.Close()
+	gz.Close()
+}
+
+func writeTestZip(t *testing.T, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, member := range archiveMembers {
+		w, err := zw.Create(member.name)
+		if err != nil {
+			t.Fatalf("Failed to write archive: %v", err)
+		}
+		w.Write([]byte(member.content))
+	}
+	zw.Close()
+}
+
+func TestArchiveInput(t *testing.T) {
+	dir := t.TempDir()
+	tarGz := filepath.Join(dir, "casp.tar.gz")
+	zipFile := filepath.Join(dir, "casp.zip")
+	writeTestTarGz(t, tarGz)
+	writeTestZip(t, zipFile)
+
+	for _, archive := range []string{tarGz, zipFile} {
+		// Every PDB file of an archive is an input
+		output, err := exec.Command("../bin/pdbtk", "canonicalize", "--checksum", archive).Output()
+		if err != nil {
+			t.Fatalf("canonicalize %s failed: %v", archive, err)
+		}
+		var names []string
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			names = append(names, strings.Fields(line)[1])
+		}
+		expected := archive + ":T1104/model1.pdb " + archive + ":T1104/model2.pdb " + archive + ":T1106/model1.pdb"
+		if strings.Join(names, " ") != expected {
+			t.Errorf("Expected the PDB files of %s, got %s", archive, strings.Join(names, " "))
+		}
+
+		// A single member
+		output, err = exec.Command("../bin/pdbtk", "extract-seq", archive+":T1104/model2.pdb").Output()
+		if err != nil {
+			t.Fatalf("extract-seq of an archive member failed: %v", err)
+		}
+		if strings.TrimSpace(string(output)) != ">model2_B\nG" {
+			t.Errorf("Expected the sequence of model2, got:\n%s", output)
+		}
+	}
+
+	// Outputs are named after the members, in the directories of the members, as members in
+	// different directories share names
+	for _, args := range [][]string{{"tidy"}, {"renumber-residues", "--start", "1"}, {"renumber-residues", "--start", "1", "-r"}} {
+		outdir := filepath.Join(dir, "out_"+strings.Join(args, "_"))
+		if out, err := exec.Command("../bin/pdbtk", append(args, "--outdir", outdir, tarGz)...).CombinedOutput(); err != nil {
+			t.Fatalf("%s --outdir of an archive failed: %v\n%s", args[0], err, out)
+		}
+		for _, name := range []string{"T1104/model1.pdb", "T1106/model1.pdb"} {
+			content, err := os.ReadFile(filepath.Join(outdir, filepath.FromSlash(name)))
+			if err != nil {
+				t.Errorf("%s: expected %s to be written: %v", args[0], name, err)
+				continue
+			}
+			resName := map[string]string{"T1104/model1.pdb": "ALA", "T1106/model1.pdb": "SER"}[name]
+			if !strings.Contains(string(content), " CA  "+resName+" A") {
+				t.Errorf("%s: expected %s to hold the %s of its member, got:\n%s", args[0], name, resName, content)
+			}
+		}
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract-seq", zipFile+":T1104/model3.pdb")); code != 1 {
+		t.Errorf("Expected exit code 1 for a missing archive member, got %d", code)
+	}
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// ensemblePDB writes the given models of a CA trace as a multi-model PDB file
+func ensemblePDB(models [][][3]float64) string {
+	residues := []string{"ALA", "GLY", "SER", "LEU", "VAL"}
+	var b strings.Builder
+	for m, coords := range models {
+		fmt.Fprintf(&b, "MODEL     %4d\n", m+1)
+		for i, c := range coords {
+			fmt.Fprintf(&b, "ATOM  %5d  CA  %3s A%4d    %8.3f%8.3f%8.3f  1.00 20.00           C\n",
+				i+1, residues[i], i+1, c[0], c[1], c[2])
+		}
+		b.WriteString("ENDMDL\n")
+	}
+	b.WriteString("END\n")
+	return b.String()
+}
+
+func TestAverage(t *testing.T) {
+	base := [][3]float64{{0, 0, 0}, {3.8, 0, 0}, {5.0, 3.6, 0}, {8.5, 4.0, 1.0}, {10.0, 7.5, 2.0}}
+	// The same coordinates translated, and rotated by 90 degrees about z
+	var translated, rotated [][3]float64
+	for _, c := range base {
+		translated = append(translated, [3]float64{c[0] + 10, c[1] - 5, c[2] + 2})
+		rotated = append(rotated, [3]float64{-c[1], c[0], c[2]})
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "average", "--rmsf-bfactor")
+	cmd.Stdin = strings.NewReader(ensemblePDB([][][3]float64{base, translated, rotated}))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("average failed: %v", err)
+	}
+	if strings.Contains(string(output), "MODEL") {
+		t.Error("Expected a single-model output")
+	}
+
+	var atoms int
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "ATOM") {
+			continue
+		}
+		c := base[atoms]
+		for axis, columns := range [][2]int{{30, 38}, {38, 46}, {46, 54}} {
+			value, _ := strconv.ParseFloat(strings.TrimSpace(line[columns[0]:columns[1]]), 64)
+			if math.Abs(value-c[axis]) > 0.002 {
+				t.Errorf("Expected the average to match the superposed models, got %s", line)
+			}
+		}
+		if bfactor := strings.TrimSpace(line[60:66]); bfactor != "0.00" {
+			t.Errorf("Expected zero RMSF for identical models, got %s", bfactor)
+		}
+		atoms++
+	}
+	if atoms != len(base) {
+		t.Errorf("Expected %d atoms, got %d", len(base), atoms)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "average")
+	cmd.Stdin = strings.NewReader(ensemblePDB([][][3]float64{base}))
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error for a single-model file")
+	}
+}
+
+func TestEnsembleStats(t *testing.T) {
+	base := [][3]float64{{0, 0, 0}, {3.8, 0, 0}, {5.0, 3.6, 0}, {8.5, 4.0, 1.0}, {10.0, 7.5, 2.0}}
+	// Model 2 moves the last residue by 1 Å, model 3 by 2 Å; model 2 is the medoid
+	var shifted1, shifted2 [][3]float64
+	for i, c := range base {
+		if i == len(base)-1 {
+			shifted1 = append(shifted1, [3]float64{c[0], c[1], c[2] + 1})
+			shifted2 = append(shifted2, [3]float64{c[0], c[1], c[2] + 2})
+			continue
+		}
+		shifted1 = append(shifted1, c)
+		shifted2 = append(shifted2, c)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "ensemble-stats", "--format", "json")
+	cmd.Stdin = strings.NewReader(ensemblePDB([][][3]float64{base, shifted1, shifted2}))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("ensemble-stats failed: %v", err)
+	}
+	var stats struct {
+		Models   []int       `json:"models"`
+		RMSD     [][]float64 `json:"rmsd"`
+		Medoid   int         `json:"medoid"`
+		Residues []struct {
+			Residue string  `json:"residue"`
+			RMSF    float64 `json:"rmsf"`
+		} `json:"residues"`
+	}
+	if err := json.Unmarshal(output, &stats); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if len(stats.Models) != 3 || len(stats.RMSD) != 3 || stats.RMSD[0][0] != 0 || stats.RMSD[0][1] != stats.RMSD[1][0] {
+		t.Errorf("Unexpected RMSD matrix: %v", stats.RMSD)
+	}
+	if stats.Medoid != 2 {
+		t.Errorf("Expected model 2 to be the medoid, got %d", stats.Medoid)
+	}
+	last := stats.Residues[len(stats.Residues)-1]
+	if last.Residue != "A:5 VAL" || last.RMSF <= stats.Residues[0].RMSF {
+		t.Errorf("Expected the last residue to be the most variable: %+v", stats.Residues)
+	}
+}
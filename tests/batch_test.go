@@ -0,0 +1,348 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const batchTestPDB = `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           N
+ATOM      2  CA  ALA A   1      19.030  16.206  23.362  1.00 10.53           C
+ATOM      3  N   VAL B   1      30.154  26.967  33.862  1.00 11.18           N
+ATOM      4  CA  VAL B   1      29.030  26.206  33.362  1.00 10.53           C
+END`
+
+func TestExtractBatchOutdir(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pdbtk_batch_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"one.pdb", "two.pdb"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(batchTestPDB), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	outdir := filepath.Join(dir, "out")
+	cmd := exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--outdir", outdir,
+		"--name-template", "{stem}_chainA.pdb", filepath.Join(dir, "*.pdb"))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Batch extract failed: %v\n%s", err, output)
+	}
+
+	for _, stem := range []string{"one", "two"} {
+		content, err := os.ReadFile(filepath.Join(outdir, stem+"_chainA.pdb"))
+		if err != nil {
+			t.Fatalf("Expected output file for %s: %v", stem, err)
+		}
+		if !strings.Contains(string(content), "ALA A   1") {
+			t.Errorf("Output for %s should contain chain A atoms", stem)
+		}
+		if strings.Contains(string(content), "VAL B   1") {
+			t.Errorf("Output for %s should not contain chain B atoms", stem)
+		}
+	}
+}
+
+func TestBatchRequiresOutdir(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pdbtk_batch_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	one := filepath.Join(dir, "one.pdb")
+	two := filepath.Join(dir, "two.pdb")
+	for _, name := range []string{one, two} {
+		if err := os.WriteFile(name, []byte(batchTestPDB), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "renumber-residues", "--start", "10", one, two)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected error when passing multiple inputs without --outdir")
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "extract", "--chains", "A", filepath.Join(dir, "missing_*.pdb"))
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected error when a glob matches no files")
+	}
+}
+
+func TestFailedRunLeavesNoOutput(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pdbtk_atomic_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "broken.pdb")
+	if err := os.WriteFile(input, []byte("NOT A PDB FILE\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	outputFile := filepath.Join(dir, "out.pdb")
+	cmd := exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--output", outputFile, input)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Expected extract to fail on an invalid PDB file")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() != "broken.pdb" {
+			t.Errorf("Failed run should not leave output files behind, found %s", entry.Name())
+		}
+	}
+}
+
+func TestOutputPermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes and umask are Unix")
+	}
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.pdb")
+	if err := os.WriteFile(input, []byte(batchTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// New outputs get the permissions of the umask, as files created by the shell do
+	output := filepath.Join(dir, "out.pdb")
+	cmd := exec.Command("sh", "-c", `umask 027 && exec ../bin/pdbtk tidy --output "$1" "$2"`, "sh", output, input)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("tidy failed: %v\n%s", err, out)
+	}
+	if info, err := os.Stat(output); err != nil {
+		t.Errorf("Expected an output: %v", err)
+	} else if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected a new output with mode 0640, got %v", info.Mode().Perm())
+	}
+
+	// Replaced outputs keep their permissions
+	if err := os.Chmod(output, 0600); err != nil {
+		t.Fatalf("Failed to change output permissions: %v", err)
+	}
+	if out, err := exec.Command("../bin/pdbtk", "tidy", "--output", output, input).CombinedOutput(); err != nil {
+		t.Fatalf("tidy failed: %v\n%s", err, out)
+	}
+	if info, err := os.Stat(output); err != nil {
+		t.Errorf("Expected an output: %v", err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the replaced output to keep mode 0600, got %v", info.Mode().Perm())
+	}
+}
+
+func TestBatchProgress(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pdbtk_progress_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "one.pdb")
+	if err := os.WriteFile(input, []byte(batchTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// stderr is not a terminal under go test, so progress is off unless forced
+	for _, mode := range []string{"auto", "always"} {
+		cmd := exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--progress", mode,
+			"--outdir", filepath.Join(dir, "out_"+mode), input)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			t.Fatalf("Batch extract with --progress %s failed: %v", mode, err)
+		}
+
+		hasProgress := strings.Contains(stderr.String(), "Processing")
+		if mode == "auto" && hasProgress {
+			t.Error("Progress should be disabled when stderr is not a terminal")
+		}
+		if mode == "always" && !strings.Contains(stderr.String(), "1/1") {
+			t.Errorf("Expected progress line with --progress always, got %q", stderr.String())
+		}
+	}
+}
+
+func TestExtractSeqCombinedFASTA(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pdbtk_batch_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, sub := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, sub, "one.pdb"), []byte(batchTestPDB), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	bad := filepath.Join(dir, "bad.pdb")
+	if err := os.WriteFile(bad, []byte("not a pdb file\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "extract-seq", "--chains", "A", filepath.Join(dir, "a", "one.pdb"), filepath.Join(dir, "b", "one.pdb"), bad)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err == nil {
+		t.Error("Expected extract-seq to fail when one of the inputs cannot be read")
+	}
+
+	outputStr := string(output)
+	if !strings.Contains(outputStr, ">one_A\n") || !strings.Contains(outputStr, ">one_A_2\n") {
+		t.Errorf("Expected unique IDs one_A and one_A_2 in combined FASTA, got:\n%s", outputStr)
+	}
+	if !strings.Contains(stderr.String(), "bad.pdb") || !strings.Contains(stderr.String(), "1 of 3 inputs failed") {
+		t.Errorf("Expected the failed input to be reported, got: %s", stderr.String())
+	}
+}
+
+func TestBatchRecursive(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "mirror")
+	for _, name := range []string{"top.pdb", "ab/1abc.pdb", "ab/x/2abc.pdb", "cd/3cde.pdb", "cd/notes.txt"} {
+		path := filepath.Join(input, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(batchTestPDB), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	outdir := filepath.Join(dir, "out")
+	cmd := exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--recursive", "--outdir", outdir,
+		"--name-template", "{stem}_A.pdb", input)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Recursive extract failed: %v\n%s", err, output)
+	}
+	var written []string
+	filepath.WalkDir(outdir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			rel, _ := filepath.Rel(outdir, path)
+			written = append(written, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	expected := "ab/1abc_A.pdb ab/x/2abc_A.pdb cd/3cde_A.pdb top_A.pdb"
+	if strings.Join(written, " ") != expected {
+		t.Errorf("Expected the output tree %s, got %s", expected, strings.Join(written, " "))
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--outdir", outdir, input)); code != 1 {
+		t.Errorf("Expected exit code 1 for a directory without --recursive, got %d", code)
+	}
+}
+
+func TestBatchOutputClash(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"dir1/x.pdb", "dir2/x.pdb"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(batchTestPDB), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	outdir := filepath.Join(dir, "out")
+	cmd := exec.Command("../bin/pdbtk", "--json-errors", "tidy", "--outdir", outdir,
+		filepath.Join(dir, "dir1", "x.pdb"), filepath.Join(dir, "dir2", "x.pdb"))
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 for inputs written to the same file, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `"code":"invalid_argument"`) {
+		t.Errorf("Expected an invalid_argument error, got: %s", stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "x.pdb")); err == nil {
+		t.Error("Expected no output to be written")
+	}
+
+	// A recursive run over their parent keeps them apart in subdirectories
+	outdir = t.TempDir()
+	cmd = exec.Command("../bin/pdbtk", "tidy", "--recursive", "--outdir", outdir, dir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("Recursive tidy failed: %v\n%s", err, output)
+	}
+	for _, name := range []string{"dir1/x.pdb", "dir2/x.pdb"} {
+		if _, err := os.Stat(filepath.Join(outdir, name)); err != nil {
+			t.Errorf("Expected output %s: %v", name, err)
+		}
+	}
+}
+
+func TestBatchResume(t *testing.T) {
+	dir := t.TempDir()
+	var inputs []string
+	for _, name := range []string{"one.pdb", "two.pdb"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(batchTestPDB), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		inputs = append(inputs, path)
+	}
+	outdir := filepath.Join(dir, "out")
+	manifest := filepath.Join(dir, "manifest.jsonl")
+	run := func(extra ...string) string {
+		args := append([]string{"tidy", "--outdir", outdir}, extra...)
+		output, err := exec.Command("../bin/pdbtk", append(args, inputs...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("tidy %v failed: %v\n%s", extra, err, output)
+		}
+		return string(output)
+	}
+
+	run("--manifest", manifest)
+	if output := run("--resume"); !strings.Contains(output, "Skipped 2 of 2 inputs") {
+		t.Errorf("Expected both inputs to be skipped, got: %s", output)
+	}
+
+	// An output older than its input is written again
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(outdir, "two.pdb"), old, old); err != nil {
+		t.Fatalf("Failed to change output time: %v", err)
+	}
+	if output := run("--resume"); !strings.Contains(output, "Skipped 1 of 2 inputs") {
+		t.Errorf("Expected only one.pdb to be skipped, got: %s", output)
+	}
+
+	// Inputs recorded as successful in the manifest are written again when their outputs are missing
+	if err := os.Remove(filepath.Join(outdir, "two.pdb")); err != nil {
+		t.Fatalf("Failed to remove output: %v", err)
+	}
+	if output := run("--resume", "--manifest", manifest); !strings.Contains(output, "Skipped 1 of 2 inputs") {
+		t.Errorf("Expected only one.pdb to be skipped, got: %s", output)
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "two.pdb")); err != nil {
+		t.Errorf("Expected two.pdb to be written again: %v", err)
+	}
+	content, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatalf("Expected a manifest: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[2], `"status":"skipped"`) || !strings.Contains(lines[3], `"status":"ok"`) {
+		t.Errorf("Expected the skipped and written inputs to be appended to the manifest, got:\n%s", content)
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "tidy", "--resume", inputs[0])); code != 1 {
+		t.Errorf("Expected exit code 1 for --resume without --outdir, got %d", code)
+	}
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	pdbA := `HEADER    TEST                                    01-JAN-00   1ABC
+REMARK   2 RESOLUTION. 2.00 ANGSTROMS.
+CRYST1   50.000   50.000   50.000  90.00  90.00  90.00 P 1           1
+ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  N   ALA A   1      11.000  11.000  11.000  1.00 20.00           N
+HETATM    3  C1  LIG A 101      12.000  12.000  12.000  1.00 20.00           C
+HETATM    4  O1  LIG A 101      13.000  13.000  13.000  1.00 20.00           O
+CONECT    3    4
+END
+`
+	// The same structure with other serials, atom order, whitespace and header records
+	pdbB := "CRYST1   50.000   50.000   50.000  90.00  90.00  90.00 P 1           1\n" +
+		"ATOM     11  N   ALA A   1      11.000  11.000  11.000  1.00 20.00\n" +
+		"ATOM     12  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00   \t\n" +
+		"HETATM   14  O1  LIG A 101      13.000  13.000  13.000  1.00 20.00           O\n" +
+		"HETATM   13  C1  LIG A 101      12.000  12.000  12.000  1.00 20.00           C\n" +
+		"CONECT   13   14\n" +
+		"END\n"
+	// A different structure
+	pdbC := strings.Replace(pdbA, "13.000  13.000  13.000", "13.500  13.000  13.000", 1)
+
+	files := map[string]string{"test_canonical_a.pdb": pdbA, "test_canonical_b.pdb": pdbB, "test_canonical_c.pdb": pdbC}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		defer os.Remove(name)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "canonicalize", "test_canonical_a.pdb")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("canonicalize failed: %v", err)
+	}
+	if strings.Contains(string(output), "REMARK") || strings.Contains(string(output), "HEADER") {
+		t.Errorf("Expected header records to be removed:\n%s", string(output))
+	}
+	if !strings.HasPrefix(string(output), "CRYST1") {
+		t.Errorf("Expected CRYST1 to be kept:\n%s", string(output))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "canonicalize", "--checksum", "test_canonical_a.pdb", "test_canonical_b.pdb", "test_canonical_c.pdb")
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("canonicalize --checksum failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 checksum lines, got:\n%s", string(output))
+	}
+	sums := make([]string, len(lines))
+	for i, line := range lines {
+		sums[i] = strings.Fields(line)[0]
+		if len(sums[i]) != 64 {
+			t.Errorf("Expected a SHA-256 hash, got %q", line)
+		}
+	}
+	if sums[0] != sums[1] {
+		t.Errorf("Expected equivalent files to have the same checksum:\n%s", string(output))
+	}
+	if sums[0] == sums[2] {
+		t.Errorf("Expected different structures to have different checksums:\n%s", string(output))
+	}
+}
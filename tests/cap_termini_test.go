@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"math"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCapTermini(t *testing.T) {
+	run := func(input string, args ...string) (string, string) {
+		cmd := exec.Command("../bin/pdbtk", append([]string{"cap-termini"}, args...)...)
+		cmd.Stdin = strings.NewReader(input)
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("cap-termini %v failed: %v\n%s", args, err, stderr.String())
+		}
+		return string(output), stderr.String()
+	}
+	// residues lists the residue names and numbers of the ATOM records, once each
+	residues := func(output string) []string {
+		var result []string
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "ATOM") {
+				residue := line[17:20] + " " + strings.TrimSpace(line[22:26])
+				if len(result) == 0 || result[len(result)-1] != residue {
+					result = append(result, residue)
+				}
+			}
+		}
+		return result
+	}
+
+	output, stderr := run(mutateTestPDB)
+	if !strings.Contains(stderr, "Added 1 ACE and 1 NME caps") {
+		t.Errorf("Unexpected report: %s", stderr)
+	}
+	got := residues(output)
+	if got[0] != "ACE 0" || got[len(got)-1] != "NME 9" || len(got) != 10 {
+		t.Errorf("Unexpected residues: %v", got)
+	}
+	names, coords, _ := residueAtoms(output, 0)
+	if strings.Join(names, " ") != "C O CH3" {
+		t.Errorf("ACE atoms = %v", names)
+	}
+	_, first, _ := residueAtoms(output, 1)
+	if d := math.Sqrt(sq(coords["C"][0]-first["N"][0]) + sq(coords["C"][1]-first["N"][1]) + sq(coords["C"][2]-first["N"][2])); math.Abs(d-1.33) > 0.01 {
+		t.Errorf("ACE C-N bond = %.3f Å, want 1.33", d)
+	}
+	if names, _, _ := residueAtoms(output, 9); strings.Join(names, " ") != "N C" {
+		t.Errorf("NME atoms = %v", names)
+	}
+	if !strings.Contains(output, "\nTER      46      NME A   9") {
+		t.Errorf("Expected TER after NME:\n%s", output)
+	}
+
+	// Capping again leaves the capped termini unchanged
+	if _, stderr := run(output); !strings.Contains(stderr, "Added 0 ACE and 0 NME caps") {
+		t.Errorf("Unexpected report for capped input: %s", stderr)
+	}
+
+	// --breaks caps both sides of a chain break, and --naming amber names the NME carbon CH3
+	var broken []string
+	for _, line := range strings.Split(mutateTestPDB, "\n") {
+		if len(line) < 26 || (line[22:26] != "   4" && line[22:26] != "   5") {
+			broken = append(broken, line)
+		}
+	}
+	output, _ = run(strings.Join(broken, "\n"), "--breaks", "--naming", "amber")
+	expected := []string{"ACE 0", "ALA 1", "ALA 2", "ALA 3", "NME 4", "ACE 5", "ALA 6", "ALA 7", "ALA 8", "NME 9"}
+	if got := residues(output); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Residues with --breaks = %v, want %v", got, expected)
+	}
+	if names, _, _ := residueAtoms(output, 4); strings.Join(names, " ") != "N CH3" {
+		t.Errorf("Amber NME atoms = %v", names)
+	}
+}
+
+func sq(x float64) float64 {
+	return x * x
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCenter(t *testing.T) {
+	testPDB := `ATOM      1  N   ALA A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA  ALA A   1       2.000   0.000   0.000  1.00 20.00           C
+ATOM      3  CA  GLY B   1      10.000  10.000  10.000  1.00 20.00           C
+HETATM    4  O   HOH A 101      50.000   0.000   0.000  1.00 20.00           O
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "center", "--chains", "A", "--format", "json")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("center failed: %v", err)
+	}
+	var centers []struct {
+		Atoms           int        `json:"atoms"`
+		CenterOfMass    [3]float64 `json:"center_of_mass"`
+		GeometricCenter [3]float64 `json:"geometric_center"`
+	}
+	if err := json.Unmarshal(output, &centers); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	// The centre of mass is pulled towards the heavier nitrogen: 2*mC/(mN+mC) = 0.923
+	if len(centers) != 1 || centers[0].Atoms != 2 || centers[0].CenterOfMass != [3]float64{0.923, 0, 0} || centers[0].GeometricCenter != [3]float64{1, 0, 0} {
+		t.Errorf("Unexpected centres: %+v", centers)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "center", "--atoms", "ca", "--format", "xyz")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("center --format xyz failed: %v", err)
+	}
+	if string(output) != "6.000 5.000 5.000\n" {
+		t.Errorf("Unexpected xyz output: %q", string(output))
+	}
+}
@@ -0,0 +1,89 @@
+package tests
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const chargesTestPDB = `HETATM    1  C1  BNZ A   1       1.390   0.000   0.000  1.00 20.00           C
+HETATM    2  C2  BNZ A   1       0.695   1.204   0.000  1.00 20.00           C
+HETATM    3  C3  BNZ A   1      -0.695   1.204   0.000  1.00 20.00           C
+HETATM    4  C4  BNZ A   1      -1.390   0.000   0.000  1.00 20.00           C
+HETATM    5  C5  BNZ A   1      -0.695  -1.204   0.000  1.00 20.00           C
+HETATM    6  C6  BNZ A   1       0.695  -1.204   0.000  1.00 20.00           C
+HETATM    7  C   MOH A   2      10.000   0.000   0.000  1.00 20.00           C
+HETATM    8  O   MOH A   2      11.430   0.000   0.000  1.00 20.00           O
+HETATM    9  HO  MOH A   2      11.750   0.910   0.000  1.00 20.00           H
+HETATM   10 ZN    ZN A   3      20.000   0.000   0.000  1.00 20.00          ZN2+
+END
+`
+
+func TestCharges(t *testing.T) {
+	cmd := exec.Command("../bin/pdbtk", "charges", "--format", "json")
+	cmd.Stdin = strings.NewReader(chargesTestPDB)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("charges failed: %v\n%s", err, stderr.String())
+	}
+	var charges []struct {
+		Atom   string  `json:"atom"`
+		Type   string  `json:"type"`
+		Charge float64 `json:"charge"`
+	}
+	if err := json.Unmarshal(output, &charges); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, string(output))
+	}
+	if len(charges) != 10 {
+		t.Fatalf("Expected 10 atoms, got %d", len(charges))
+	}
+
+	total := 0.0
+	for _, c := range charges[:6] {
+		if c.Type != "C.ar" || c.Charge != 0 {
+			t.Errorf("Expected an uncharged aromatic carbon, got %+v", c)
+		}
+	}
+	carbon, oxygen, hydrogen := charges[6], charges[7], charges[8]
+	if carbon.Type != "C.3" || oxygen.Type != "O.3" || hydrogen.Type != "H" {
+		t.Errorf("Unexpected methanol atom types: %+v", charges[6:9])
+	}
+	if !(oxygen.Charge < -0.3 && hydrogen.Charge > 0.15 && carbon.Charge > 0) {
+		t.Errorf("Expected a polarized hydroxyl group, got %+v", charges[6:9])
+	}
+	for _, c := range charges {
+		total += c.Charge
+	}
+	// The zinc ion keeps its formal charge
+	if charges[9].Charge != 2 || total < 1.999 || total > 2.001 {
+		t.Errorf("Expected the formal charge of the zinc ion to be kept, got %+v (total %.4f)", charges[9], total)
+	}
+	if !strings.Contains(stderr.String(), "no Gasteiger parameters for ZN") {
+		t.Errorf("Expected a warning about the zinc ion, got %q", stderr.String())
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "charges", "--format", "pdbqt")
+	cmd.Stdin = strings.NewReader(chargesTestPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("charges --format pdbqt failed: %v", err)
+	}
+	lines := strings.Split(string(output), "\n")
+	for i, adType := range map[int]string{0: "A ", 6: "C ", 7: "OA", 8: "HD", 9: "Zn"} {
+		if len(lines[i]) != 79 || lines[i][77:79] != adType {
+			t.Errorf("Expected AutoDock type %q in %q", adType, lines[i])
+		}
+	}
+	if !strings.HasPrefix(lines[9], "HETATM   10 ZN    ZN A   3      20.000   0.000   0.000  1.00 20.00     2.000") {
+		t.Errorf("Unexpected PDBQT record: %q", lines[9])
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "--strict", "charges")
+	cmd.Stdin = strings.NewReader(chargesTestPDB)
+	if code := exitCodeOf(t, cmd); code == 0 {
+		t.Error("Expected --strict to fail for atoms without parameters")
+	}
+}
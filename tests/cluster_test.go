@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	alpha := []string{"VAL", "LEU", "SER", "PRO", "ALA", "ASP", "LYS", "THR", "ASN", "VAL"}
+	alphaShort := []string{"VAL", "LEU", "SER", "PRO", "ALA", "ASP", "LYS", "THR", "ASN"}
+	beta := []string{"VAL", "HIS", "LEU", "THR", "PRO", "GLU", "GLU", "LYS"}
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"one.pdb": chainPDB('A', alpha, 1) + chainPDB('B', beta, 11) + "END\n",
+		"two.pdb": chainPDB('A', alphaShort, 1) + "END\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	outdir := filepath.Join(dir, "nr")
+
+	cmd := exec.Command("../bin/pdbtk", "cluster", "--outdir", outdir, filepath.Join(dir, "*.pdb"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("cluster failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	expected := []string{
+		"cluster\tfile\tchain\tlength\trepresentative\tidentity",
+		"1\t" + filepath.Join(dir, "one.pdb") + "\tA\t10\ttrue\t1.000",
+		"1\t" + filepath.Join(dir, "two.pdb") + "\tA\t9\tfalse\t1.000",
+		"2\t" + filepath.Join(dir, "one.pdb") + "\tB\t8\ttrue\t1.000",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected cluster table:\n%s", string(output))
+	}
+
+	for _, name := range []string{"one_A.pdb", "one_B.pdb"} {
+		if _, err := os.Stat(filepath.Join(outdir, name)); err != nil {
+			t.Errorf("Expected representative %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "two_A.pdb")); err == nil {
+		t.Error("Expected no file for a non-representative chain")
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "cluster", "--min-length", "9", filepath.Join(dir, "*.pdb"))
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("cluster --min-length failed: %v", err)
+	}
+	if strings.Contains(string(output), "\tB\t") {
+		t.Errorf("Expected chain B to be left out:\n%s", string(output))
+	}
+}
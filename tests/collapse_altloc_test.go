@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCollapseAltLocCommand(t *testing.T) {
+	testPDB := `ATOM      1  N   SER A   1      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CA  SER A   1      11.000  10.000  10.000  1.00 20.00           C
+ATOM      3  CB ASER A   1      12.000  10.000  10.000  0.30 20.00           C
+ATOM      4  CB BSER A   1      12.000  11.000  10.000  0.70 20.00           C
+ATOM      5  OG ASER A   1      13.000  10.000  10.000  0.30 20.00           O
+ATOM      6  OG BSER A   1      13.000  11.000  10.000  0.70 20.00           O
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "collapse-altloc")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("collapse-altloc command failed: %v", err)
+	}
+
+	outputStr := string(output)
+	expected := []string{
+		"ATOM      1  N   SER A   1      10.000  10.000  10.000  1.00 20.00           N",
+		"ATOM      2  CA  SER A   1      11.000  10.000  10.000  1.00 20.00           C",
+		"ATOM      3  CB  SER A   1      12.000  11.000  10.000  1.00 20.00           C",
+		"ATOM      4  OG  SER A   1      13.000  11.000  10.000  1.00 20.00           O",
+	}
+	for _, e := range expected {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+	if strings.Count(outputStr, "SER A") != 5 {
+		t.Errorf("Expected 4 atoms and a TER record, got:\n%s", outputStr)
+	}
+}
+
+func TestCollapseAltLocAverage(t *testing.T) {
+	testPDB := `ATOM      1  N   SER A   1      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CB ASER A   1      12.000  10.000  10.000  0.30 10.00           C
+ATOM      3  CB BSER A   1      12.000  11.000  10.000  0.70 20.00           C
+ATOM      4  OG ASER A   1      13.000  10.000  10.000  0.00 20.00           O
+ATOM      5  OG BSER A   1      13.000  11.000  12.000  0.00 20.00           O
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "collapse-altloc", "--mode", "average")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("collapse-altloc --mode average failed: %v", err)
+	}
+
+	outputStr := string(output)
+	expected := []string{
+		"ATOM      1  N   SER A   1      10.000  10.000  10.000  1.00 20.00           N",
+		"ATOM      2  CB  SER A   1      12.000  10.700  10.000  1.00 17.00           C",
+		// Without occupancies the locations are weighted equally
+		"ATOM      3  OG  SER A   1      13.000  10.500  11.000  1.00 20.00           O",
+	}
+	for _, e := range expected {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+	if strings.Count(outputStr, "SER A") != 4 {
+		t.Errorf("Expected 3 atoms and a TER record, got:\n%s", outputStr)
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "collapse-altloc", "--mode", "lowest")); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid --mode, got %d", code)
+	}
+}
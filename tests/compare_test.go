@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	residues := []string{"MET", "LYS", "ALA", "GLY", "SER", "TRP", "LEU", "ILE", "THR", "GLU", "ASP", "VAL"}
+	var a, b strings.Builder
+	for i, resName := range residues {
+		// An alpha helix CA trace
+		angle := float64(i) * 100 * math.Pi / 180
+		x, y, z := 2.3*math.Cos(angle), 2.3*math.Sin(angle), float64(i)*1.5
+		fmt.Fprintf(&a, "ATOM  %5d  CA  %3s A%4d    %8.3f%8.3f%8.3f  1.00 20.00           C\n", i+1, resName, i+1, x, y, z)
+		// Structure b: chain X, numbered from 101, translated, with residue 3 mutated and residue 8 moved
+		if i == 2 {
+			resName = "VAL"
+		}
+		if i == len(residues)-1 {
+			x += 5
+		}
+		fmt.Fprintf(&b, "ATOM  %5d  CA  %3s X%4d    %8.3f%8.3f%8.3f  1.00 20.00           C\n", i+1, resName, i+101, x+10, y, z+5)
+	}
+	files := map[string]string{"test_compare_a.pdb": a.String() + "END\n", "test_compare_b.pdb": b.String() + "END\n"}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		defer os.Remove(name)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "compare", "--format", "json", "test_compare_a.pdb", "test_compare_b.pdb")
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("compare failed: %v", err)
+	}
+	var report struct {
+		Chains []struct {
+			ChainA   string  `json:"chain_a"`
+			ChainB   string  `json:"chain_b"`
+			Identity float64 `json:"identity"`
+			Paired   int     `json:"paired_residues"`
+		} `json:"chains"`
+		SequenceDifferences []struct {
+			ResidueA string `json:"residue_a"`
+			ResidueB string `json:"residue_b"`
+		} `json:"sequence_differences"`
+		ConformationDifferences []struct {
+			ResidueA string `json:"residue_a"`
+		} `json:"conformation_differences"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if len(report.Chains) != 1 || report.Chains[0].ChainA != "A" || report.Chains[0].ChainB != "X" || report.Chains[0].Paired != 12 {
+		t.Errorf("Unexpected chain mapping: %+v", report.Chains)
+	}
+	if report.Chains[0].Identity != 0.917 {
+		t.Errorf("Expected identity 0.917, got %g", report.Chains[0].Identity)
+	}
+	if len(report.SequenceDifferences) != 1 || report.SequenceDifferences[0].ResidueA != "A:3 ALA" || report.SequenceDifferences[0].ResidueB != "X:103 VAL" {
+		t.Errorf("Unexpected sequence differences: %+v", report.SequenceDifferences)
+	}
+	if len(report.ConformationDifferences) != 1 || report.ConformationDifferences[0].ResidueA != "A:12 VAL" {
+		t.Errorf("Unexpected conformation differences: %+v", report.ConformationDifferences)
+	}
+}
+
+func TestCompareStructuralAlignment(t *testing.T) {
+	residuesA := []string{"MET", "LYS", "ALA", "GLY", "SER", "TRP", "LEU", "ILE", "THR", "GLU", "ASP", "VAL"}
+	residuesB := []string{"PHE", "TYR", "ASN", "GLN", "HIS", "ARG", "PRO", "MET", "LYS", "ALA", "GLY", "SER"}
+	var a, b strings.Builder
+	serial := 1
+	for i := range residuesA {
+		angle := float64(i) * 100 * math.Pi / 180
+		x, y, z := 2.3*math.Cos(angle), 2.3*math.Sin(angle), float64(i)*1.5
+		fmt.Fprintf(&a, "ATOM  %5d  CA  %3s A%4d    %8.3f%8.3f%8.3f  1.00 20.00           C\n", i+1, residuesA[i], i+1, x, y, z)
+		fmt.Fprintf(&b, "ATOM  %5d  CA  %3s B%4d    %8.3f%8.3f%8.3f  1.00 20.00           C\n", serial, residuesB[i], serial, x+10, y, z)
+		serial++
+		// Structure b has a loop residue inserted after the sixth residue, away from the helix
+		if i == 5 {
+			fmt.Fprintf(&b, "ATOM  %5d  CA  CYS B%4d    %8.3f%8.3f%8.3f  1.00 20.00           C\n", serial, serial, 10+x*4, y*4, z+0.75)
+			serial++
+		}
+	}
+	dir := t.TempDir()
+	fileA, fileB := dir+"/homolog_a.pdb", dir+"/homolog_b.pdb"
+	os.WriteFile(fileA, []byte(a.String()+"END\n"), 0644)
+	os.WriteFile(fileB, []byte(b.String()+"END\n"), 0644)
+
+	alignment := dir + "/aln.fasta"
+	if err := exec.Command("../bin/pdbtk", "compare", "--min-identity", "0", "--alignment", alignment, fileA, fileB).Run(); err != nil {
+		t.Fatalf("compare --alignment failed: %v", err)
+	}
+	content, err := os.ReadFile(alignment)
+	if err != nil {
+		t.Fatalf("Expected an alignment file: %v", err)
+	}
+	expected := ">homolog_a_A\nMKAGSW-LITEDV\n>homolog_b_B\nFYNQHRCPMKAGS\n"
+	if string(content) != expected {
+		t.Errorf("Expected alignment:\n%s\ngot:\n%s", expected, string(content))
+	}
+
+	stockholm := dir + "/aln.sto"
+	if err := exec.Command("../bin/pdbtk", "compare", "--min-identity", "0", "--alignment", stockholm, "--alignment-format", "stockholm", fileA, fileB).Run(); err != nil {
+		t.Fatalf("compare --alignment-format stockholm failed: %v", err)
+	}
+	content, _ = os.ReadFile(stockholm)
+	if !strings.HasPrefix(string(content), "# STOCKHOLM 1.0\n#=GF DE Structure-based alignment of homolog_a_A and homolog_b_B: 12 residues") ||
+		!strings.HasSuffix(string(content), "homolog_a_A  MKAGSW-LITEDV\nhomolog_b_B  FYNQHRCPMKAGS\n//\n") {
+		t.Errorf("Unexpected Stockholm alignment:\n%s", string(content))
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "compare", "--alignment", alignment, "--alignment-format", "clustal", fileA, fileB)); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid --alignment-format, got %d", code)
+	}
+}
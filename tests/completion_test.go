@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		output, err := exec.Command("../bin/pdbtk", "completion", shell).Output()
+		if err != nil || !strings.Contains(string(output), "pdbtk") {
+			t.Errorf("completion %s failed: %v", shell, err)
+		}
+	}
+
+	input := filepath.Join(t.TempDir(), "input.pdb")
+	if err := os.WriteFile(input, []byte(consolidateTestPDB), 0644); err != nil {
+		t.Fatal(err)
+	}
+	complete := func(args ...string) []string {
+		output, err := exec.Command("../bin/pdbtk", append([]string{"__complete"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("__complete %v failed: %v", args, err)
+		}
+		var values []string
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			if !strings.HasPrefix(line, ":") {
+				values = append(values, line)
+			}
+		}
+		return values
+	}
+
+	tests := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"extract", input, "--chains", ""}, []string{"A\t3 residues", "B\t1 residue"}},
+		{[]string{"extract", input, "--chains", "A,"}, []string{"A,B\t1 residue"}},
+		{[]string{"renumber-residues", input, "--chain", "B"}, []string{"B\t1 residue"}},
+		{[]string{"extract-ligand", input, "--het", ""}, []string{"ZN\t1 copy"}},
+		{[]string{"extract", "--chains", ""}, nil},
+	}
+	for _, tt := range tests {
+		if got := complete(tt.args...); strings.Join(got, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("__complete %v = %q, want %q", tt.args, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const consolidateTestPDB = `LINK        ZN    ZN A 200                 O   HOH A   1     1555   1555  2.10  
+ATOM      1  CA  ALA A   1       0.000   0.000   0.000  1.00 20.00           C
+ATOM      2  CA  ALA A   2       3.800   0.000   0.000  1.00 20.00           C
+ATOM      3  CA  ALA B   1      30.000   0.000   0.000  1.00 20.00           C
+HETATM    4 ZN    ZN A 200       1.000   2.000   0.000  1.00 20.00          ZN
+HETATM    5  O   HOH A   1       1.000   4.000   0.000  1.00 20.00           O
+HETATM    6  O   HOH A   1      31.000   2.000   0.000  1.00 20.00           O
+HETATM    7  O   HOH B 300       2.000   5.000   0.000  1.00 20.00           O
+HETATM    8  H1  HOH B 300       2.500   5.500   0.000  1.00 20.00           H
+END`
+
+func TestConsolidateWaters(t *testing.T) {
+	run := func(args ...string) string {
+		cmd := exec.Command("../bin/pdbtk", append([]string{"consolidate-waters"}, args...)...)
+		cmd.Stdin = strings.NewReader(consolidateTestPDB)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("consolidate-waters %v failed: %v", args, err)
+		}
+		return string(output)
+	}
+	// waters lists the atom name, chain and residue number of the water records
+	waters := func(output string) []string {
+		var result []string
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "HETATM") && line[17:20] == "HOH" {
+				result = append(result, strings.TrimSpace(line[12:16])+" "+line[21:22]+strings.TrimSpace(line[22:26]))
+			}
+		}
+		return result
+	}
+
+	output := run()
+	expected := []string{"O W1", "O W2", "O W3", "H1 W3"}
+	if got := waters(output); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Waters = %v, want %v", got, expected)
+	}
+	if !strings.Contains(output, "O   HOH W   1     1555") {
+		t.Errorf("Expected the LINK record to be renumbered:\n%s", output)
+	}
+
+	output = run("--per-chain")
+	expected = []string{"O A201", "O A202", "H1 A202", "O B2"}
+	if got := waters(output); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Waters with --per-chain = %v, want %v", got, expected)
+	}
+
+	output = run("--chain", "S", "--start", "1001")
+	expected = []string{"O S1001", "O S1002", "O S1003", "H1 S1003"}
+	if got := waters(output); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Waters with --start = %v, want %v", got, expected)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "consolidate-waters", "--per-chain", "--chain", "S")
+	cmd.Stdin = strings.NewReader(consolidateTestPDB)
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 for --per-chain with --chain, got %d", code)
+	}
+}
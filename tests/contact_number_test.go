@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestContactNumber(t *testing.T) {
+	testPDB := `ATOM      1  CA  ALA A   1       0.000   0.000   0.000  1.00 20.00           C
+ATOM      2  CB  ALA A   1       1.000   0.000   0.000  1.00 20.00           C
+ATOM      3  CA  GLY A   2       5.000   0.000   0.000  1.00 20.00           C
+ATOM      4  CA  SER A   3       8.000   0.000   0.000  1.00 20.00           C
+ATOM      5  CB  SER A   3       9.000   0.000   0.000  1.00 20.00           C
+ATOM      6  CA  LEU B   1      30.000   0.000   0.000  1.00 20.00           C
+ATOM      7  CB  LEU B   1      31.000   0.000   0.000  1.00 20.00           C
+HETATM    8  O   HOH A 101       2.000   0.000   0.000  1.00 20.00           O
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "contact-number", "--cutoff", "6")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("contact-number failed: %v", err)
+	}
+	// The CB of A1 is 4 Å from the CA of G2 and 8 Å from the CB of S3, G2 is 4 Å from S3
+	expected := "chain,resnum,resname,contacts\nA,1,ALA,1\nA,2,GLY,2\nA,3,SER,1\nB,1,LEU,0\n"
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, string(output))
+	}
+
+	defer os.Remove("test_contact_number.pdb")
+	cmd = exec.Command("../bin/pdbtk", "contact-number", "--cutoff", "6", "--chains", "A", "--format", "tsv", "--bfactor-output", "test_contact_number.pdb")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("contact-number --bfactor-output failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); len(lines) != 4 || lines[2] != "A\t2\tGLY\t2" {
+		t.Errorf("Unexpected table:\n%s", string(output))
+	}
+	stamped, err := os.ReadFile("test_contact_number.pdb")
+	if err != nil {
+		t.Fatalf("Failed to read B-factor output: %v", err)
+	}
+	var bfactors []string
+	for _, line := range strings.Split(string(stamped), "\n") {
+		if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+			bfactors = append(bfactors, strings.TrimSpace(line[60:66]))
+		}
+	}
+	if strings.Join(bfactors, " ") != "1.00 1.00 2.00 1.00 1.00 0.00 0.00 0.00" {
+		t.Errorf("Unexpected B-factors: %v", bfactors)
+	}
+}
@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDedupe(t *testing.T) {
+	structure := `ATOM      1  N   ALA A   1      11.000  10.000  10.000  1.00 10.00           N
+ATOM      2  CA  ALA A   1      12.000  10.000  10.000  1.00 10.00           C
+END
+`
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.pdb": structure,
+		// The same atoms in a different order with a header and small rounding differences
+		"b.pdb": `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+ATOM      1  CA  ALA A   1      12.004  10.000  10.000  1.00 10.00           C
+ATOM      2  N   ALA A   1      11.000  10.000   9.998  1.00 10.00           N
+END
+`,
+		// The same atoms in a different position
+		"c.pdb": strings.ReplaceAll(structure, "11.000", "11.500"),
+		// Not a PDB file
+		"notes.txt": "not a structure\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	output, err := exec.Command("../bin/pdbtk", "dedupe", dir).Output()
+	if err != nil {
+		t.Fatalf("dedupe failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected a header and 3 rows, got:\n%s", output)
+	}
+	fields := strings.Split(lines[2], "\t")
+	if fields[0] != "1" || fields[1] != filepath.Join(dir, "b.pdb") || fields[3] != filepath.Join(dir, "a.pdb") {
+		t.Errorf("Expected b.pdb to be a duplicate of a.pdb, got: %s", lines[2])
+	}
+	if fields := strings.Split(lines[3], "\t"); fields[0] != "2" || fields[3] != "-" {
+		t.Errorf("Expected c.pdb to be unique, got: %s", lines[3])
+	}
+
+	// A tighter tolerance keeps the files with rounding differences apart
+	output, err = exec.Command("../bin/pdbtk", "dedupe", "--tolerance", "0.001", dir).Output()
+	if err != nil {
+		t.Fatalf("dedupe --tolerance failed: %v", err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n")[1:] {
+		if fields := strings.Split(line, "\t"); fields[3] != "-" {
+			t.Errorf("Expected no duplicates with --tolerance 0.001, got: %s", line)
+		}
+	}
+
+	if err := exec.Command("../bin/pdbtk", "dedupe", "--remove", dir).Run(); err != nil {
+		t.Fatalf("dedupe --remove failed: %v", err)
+	}
+	for name, kept := range map[string]bool{"a.pdb": true, "b.pdb": false, "c.pdb": true, "notes.txt": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != kept {
+			t.Errorf("Expected %s to be kept: %t", name, kept)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDensityMap(t *testing.T) {
+	testPDB := `ATOM      1  CA  GLY A   1       0.000   0.000   0.000  1.00 20.00           C
+HETATM    2  O   HOH A 101       9.000   9.000   9.000  1.00 20.00           O
+END
+`
+	defer os.Remove("test_density.mrc")
+	cmd := exec.Command("../bin/pdbtk", "density-map", "--mode", "occupancy", "--radius", "1", "--padding", "2", "--output", "test_density.mrc")
+	cmd.Stdin = strings.NewReader(testPDB)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("density-map failed: %v\n%s", err, string(output))
+	}
+	data, err := os.ReadFile("test_density.mrc")
+	if err != nil {
+		t.Fatalf("Failed to read map: %v", err)
+	}
+	word := func(i int) int32 { return int32(binary.LittleEndian.Uint32(data[4*i:])) }
+	// The water is left out: a 5x5x5 grid from -2 to 2 around the atom
+	if len(data) != 1024+4*125 {
+		t.Fatalf("Expected a 5x5x5 map, got %d bytes", len(data))
+	}
+	if word(0) != 5 || word(1) != 5 || word(2) != 5 || word(3) != 2 || word(4) != -2 || string(data[208:212]) != "MAP " {
+		t.Errorf("Unexpected header: size %d %d %d, mode %d, start %d", word(0), word(1), word(2), word(3), word(4))
+	}
+	ones := 0
+	for i := 0; i < 125; i++ {
+		v := math.Float32frombits(binary.LittleEndian.Uint32(data[1024+4*i:]))
+		if v == 1 {
+			ones++
+		}
+	}
+	// The atom's voxel and its 6 face neighbours are within 1 Å
+	if ones != 7 {
+		t.Errorf("Expected 7 masked voxels, got %d", ones)
+	}
+	if centre := math.Float32frombits(binary.LittleEndian.Uint32(data[1024+4*62:])); centre != 1 {
+		t.Errorf("Expected the centre voxel to be masked, got %g", centre)
+	}
+}
@@ -0,0 +1,45 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDetectLinks(t *testing.T) {
+	testPDB := `HEADER    TEST
+LINK         ND2 ASN A  30                 C1  NAG A 401     1555   1555  1.45
+CRYST1   50.000   50.000   50.000  90.00  90.00  90.00 P 1           1
+ATOM      1  C   GLY A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  N   CYS A   2      10.000  11.330  10.000  1.00 20.00           N
+ATOM      3  SG  CYS A   2      15.000  10.000  10.000  1.00 20.00           S
+ATOM      4  SG  CYS A  20      15.000  12.040  10.000  1.00 20.00           S
+ATOM      5  ND2 ASN A  30      30.000  30.000  30.000  1.00 20.00           N
+HETATM    6  C1  NAG A 401      31.450  30.000  30.000  1.00 20.00           C
+HETATM    7  C12 LIG A 301      13.200  10.000  10.000  1.00 20.00           C
+HETATM    8  O   HOH A 501      15.000   8.500  10.000  1.00 20.00           O
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "detect-links")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("detect-links failed: %v", err)
+	}
+
+	var records []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "LINK") || strings.HasPrefix(line, "SSBOND") || strings.HasPrefix(line, "CRYST1") {
+			records = append(records, line)
+		}
+	}
+	expected := []string{
+		"SSBOND   1 CYS A    2    CYS A   20                          1555   1555  2.04",
+		"LINK         ND2 ASN A  30                 C1  NAG A 401     1555   1555  1.45",
+		"LINK         SG  CYS A   2                 C12 LIG A 301     1555   1555  1.80",
+		"CRYST1   50.000   50.000   50.000  90.00  90.00  90.00 P 1           1",
+	}
+	if strings.Join(records, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected records:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(records, "\n"))
+	}
+}
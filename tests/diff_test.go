@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	pdbA := `ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  GLY A   2      11.000  11.000  11.000  1.00 20.00           C
+ATOM      3  CA  SER A   3      12.000  12.000  12.000  1.00 20.00           C
+ATOM      4  CB  SER A   3      12.500  12.000  12.000  1.00 20.00           C
+ATOM      5  CA  HIS A   4      13.000  13.000  13.000  1.00 20.00           C
+END
+`
+	pdbB := `ATOM      1  CA  ALA A   1      10.500  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  GLY A   2      11.000  11.000  11.000  0.50 30.00           C
+ATOM      3  CA  SER A   3      12.000  12.000  12.000  1.00 20.00           C
+ATOM      4  CA  TRP A   5      14.000  14.000  14.000  1.00 20.00           C
+END
+`
+	fileA, fileB := "test_diff_a.pdb", "test_diff_b.pdb"
+	for name, content := range map[string]string{fileA: pdbA, fileB: pdbB} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		defer os.Remove(name)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "diff", fileA, fileB)
+	output, err := cmd.Output()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 1 {
+		t.Fatalf("Expected exit code 1 for differing files, got %v", err)
+	}
+	for _, expected := range []string{
+		"atom_moved: A:1 ALA CA: 0.500 Å",
+		"bfactor_changed: A:2 GLY CA: 20.00 -> 30.00",
+		"occupancy_changed: A:2 GLY CA: 1.00 -> 0.50",
+		"atom_removed: A:3 SER CB",
+		"residue_removed: A:4 HIS",
+		"residue_added: A:5 TRP",
+	} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, string(output))
+		}
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "diff", "--tolerance", "1", "--value-tolerance", "100", "--format", "json", fileA, fileB)
+	output, _ = cmd.Output()
+	var result struct {
+		Counts       map[string]int `json:"counts"`
+		MatchedAtoms int            `json:"matched_atoms"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if result.Counts["atom_moved"] != 0 || result.Counts["bfactor_changed"] != 0 || result.Counts["residue_added"] != 1 {
+		t.Errorf("Unexpected counts: %v", result.Counts)
+	}
+	if result.MatchedAtoms != 3 {
+		t.Errorf("Expected 3 matched atoms, got %d", result.MatchedAtoms)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "diff", fileA, fileA)
+	if err := cmd.Run(); err != nil {
+		t.Errorf("Expected identical files to exit 0, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestEnsemble(t *testing.T) {
+	files := map[string]string{
+		"test_ensemble_1.pdb": `ATOM      1  N   ALA A   1      11.000  10.000  10.000  1.00 10.00           N
+ATOM      2  CA  ALA A   1      12.000  10.000  10.000  1.00 10.00           C
+END
+`,
+		// The same atoms in a different order
+		"test_ensemble_2.pdb": `ATOM      1  CA  ALA A   1      12.500  10.000  10.000  1.00 10.00           C
+ATOM      2  N   ALA A   1      11.500  10.000  10.000  1.00 10.00           N
+END
+`,
+		// A different atom
+		"test_ensemble_3.pdb": `ATOM      1  N   ALA A   1      11.500  10.000  10.000  1.00 10.00           N
+ATOM      2  CB  ALA A   1      12.500  10.000  10.000  1.00 10.00           C
+END
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		defer os.Remove(name)
+	}
+
+	output, err := exec.Command("../bin/pdbtk", "ensemble", "--reorder", "test_ensemble_1.pdb", "test_ensemble_2.pdb").Output()
+	if err != nil {
+		t.Fatalf("ensemble --reorder failed: %v", err)
+	}
+	var records []string
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, "MODEL"):
+			records = append(records, strings.Join(strings.Fields(line), " "))
+		case strings.HasPrefix(line, "ATOM"):
+			records = append(records, strings.TrimSpace(line[12:16])+" "+strings.TrimSpace(line[30:38]))
+		}
+	}
+	expected := "MODEL 1|N 11.000|CA 12.000|MODEL 2|N 11.500|CA 12.500"
+	if strings.Join(records, "|") != expected {
+		t.Errorf("Expected %q, got %q", expected, strings.Join(records, "|"))
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "ensemble", "test_ensemble_1.pdb", "test_ensemble_2.pdb")); code != 1 {
+		t.Errorf("Expected exit code 1 for atoms in a different order without --reorder, got %d", code)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "ensemble", "--reorder", "test_ensemble_1.pdb", "test_ensemble_3.pdb")
+	output, _ = cmd.CombinedOutput()
+	if !strings.Contains(string(output), "1 atoms of the first input are missing (A:1 ALA CA)") {
+		t.Errorf("Expected the missing atoms to be reported, got: %s", output)
+	}
+}
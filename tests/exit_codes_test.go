@@ -0,0 +1,53 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func exitCodeOf(t *testing.T, cmd *exec.Cmd) int {
+	err := cmd.Run()
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("Failed to run command: %v", err)
+	}
+	return exitErr.ExitCode()
+}
+
+func TestExitCodes(t *testing.T) {
+	if err := os.WriteFile("test_exit_codes.pdb", []byte(batchTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_exit_codes.pdb")
+
+	if err := os.WriteFile("test_exit_codes_broken.pdb", []byte("NOT A PDB FILE\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_exit_codes_broken.pdb")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"success", []string{"extract", "--chains", "A", "test_exit_codes.pdb"}, 0},
+		{"no matching chains", []string{"extract", "--chains", "Z", "test_exit_codes.pdb"}, 2},
+		{"rename missing chain", []string{"rename-chain", "Z", "--to", "Y", "test_exit_codes.pdb"}, 2},
+		{"parse error", []string{"extract-seq", "test_exit_codes_broken.pdb"}, 3},
+		{"partial match", []string{"extract", "--chains", "A,Z", "test_exit_codes.pdb"}, 0},
+		{"partial match strict", []string{"extract", "--strict", "--chains", "A,Z", "test_exit_codes.pdb"}, 5},
+		{"invalid argument", []string{"extract", "--chains", "AB", "test_exit_codes.pdb"}, 1},
+	}
+
+	for _, test := range tests {
+		code := exitCodeOf(t, exec.Command("../bin/pdbtk", test.args...))
+		if code != test.expected {
+			t.Errorf("%s: expected exit code %d, got %d", test.name, test.expected, code)
+		}
+	}
+}
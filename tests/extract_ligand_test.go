@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExtractLigand(t *testing.T) {
+	testPDB := `ATOM      1  CA  HIS A  10      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  NE2 HIS A  10      12.000  10.000  10.000  1.00 20.00           N
+ATOM      3  CA  GLY A  11      20.000  20.000  20.000  1.00 20.00           C
+HETATM    4 FE   HEM A 142      14.000  10.000  10.000  1.00 20.00          FE
+HETATM    5  NA  HEM A 142      15.000  10.000  10.000  1.00 20.00           N
+HETATM    6 FE   HEM B 143      40.000  40.000  40.000  1.00 20.00          FE
+HETATM    7  O   HOH A 201      14.500  11.000  10.000  1.00 20.00           O
+CONECT    2    4
+CONECT    4    2    5
+CONECT    5    4
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "extract-ligand", "--het", "HEM")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-ligand failed: %v", err)
+	}
+	if strings.Count(string(output), "HETATM") != 3 || strings.Contains(string(output), "HIS") {
+		t.Errorf("Expected only the HEM atoms:\n%s", string(output))
+	}
+	if !strings.Contains(string(output), "CONECT    1    2") {
+		t.Errorf("Expected the CONECT records of the component:\n%s", string(output))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "extract-ligand", "--het", "HEM", "--chain", "A", "--coordinating")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-ligand --coordinating failed: %v", err)
+	}
+	out := string(output)
+	if !strings.Contains(out, "HIS A  10") || strings.Contains(out, "GLY") || strings.Contains(out, "HOH") || strings.Contains(out, "HEM B") {
+		t.Errorf("Expected HEM A 142 with HIS A 10:\n%s", out)
+	}
+	if !strings.Contains(out, "CONECT    2    4") {
+		t.Errorf("Expected the CONECT record between HIS NE2 and FE:\n%s", out)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "extract-ligand", "--het", "HEM", "--chain", "B", "--resnum", "142")
+	cmd.Stdin = strings.NewReader(testPDB)
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 2 {
+		t.Errorf("Expected exit code 2 when no instance matches, got %v", err)
+	}
+}
@@ -162,3 +162,205 @@ END`
 		t.Errorf("Expected sequence 'VNT', got: %s", outputStr)
 	}
 }
+
+func TestExtractSeqWrap(t *testing.T) {
+	testPDB := `ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  GLY A   2      11.000  11.000  11.000  1.00 20.00           C
+ATOM      3  CA  CYS A   3      12.000  12.000  12.000  1.00 20.00           C
+ATOM      4  CA  ASP A   4      13.000  13.000  13.000  1.00 20.00           C
+ATOM      5  CA  GLU A   5      14.000  14.000  14.000  1.00 20.00           C
+END`
+
+	tests := []struct {
+		wrap     string
+		expected string
+	}{
+		{"2", ">stdin_A\nAG\nCD\nE\n"},
+		{"0", ">stdin_A\nAGCDE\n"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("../bin/pdbtk", "extract-seq", "--wrap", tt.wrap)
+		cmd.Stdin = strings.NewReader(testPDB)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("extract-seq --wrap %s failed: %v", tt.wrap, err)
+		}
+		if !strings.HasPrefix(string(output), tt.expected) {
+			t.Errorf("--wrap %s: expected %q, got %q", tt.wrap, tt.expected, string(output))
+		}
+	}
+}
+
+func TestExtractSeqIDTemplate(t *testing.T) {
+	testPDB := `HEADER    HYDROLASE                               01-JAN-01   1ABC
+COMPND    MOL_ID: 1;
+COMPND   2 MOLECULE: LYSOZYME;
+COMPND   3 CHAIN: A;
+SOURCE    MOL_ID: 1;
+SOURCE   2 ORGANISM_SCIENTIFIC: GALLUS GALLUS;
+SOURCE   3 ORGANISM_TAXID: 9031;
+ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  GLY A   2      11.000  11.000  11.000  1.00 20.00           C
+END`
+
+	cmd := exec.Command("../bin/pdbtk", "extract-seq", "--id-template", "{pdbid}_{chain}|{entity}|{organism}|{file}")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-seq --id-template failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), ">1ABC_A|1|GALLUS_GALLUS|stdin\nAG\n") {
+		t.Errorf("Unexpected FASTA output: %q", string(output))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "extract-seq", "--id-template", "{unknown}")
+	cmd.Stdin = strings.NewReader(testPDB)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error for an unknown placeholder")
+	}
+}
+
+func TestExtractSeqNonStandard(t *testing.T) {
+	testPDB := `ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+HETATM    2  N   MSE A   2      11.000  11.000  11.000  1.00 20.00           N
+HETATM    3  CA  MSE A   2      11.500  11.000  11.000  1.00 20.00           C
+HETATM    4  C   MSE A   2      12.000  11.000  11.000  1.00 20.00           C
+ATOM      5  CA  GLY A   3      12.000  12.000  12.000  1.00 20.00           C
+TER       6      GLY A   3
+HETATM    7  C1  NAG A 101      20.000  20.000  20.000  1.00 20.00           C
+HETATM    8  O   HOH A 201      30.000  30.000  30.000  1.00 20.00           O
+END`
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{"parent", "AMG"},
+		{"x", "AXG"},
+		{"skip", "AG"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("../bin/pdbtk", "extract-seq", "--nonstandard", tt.mode)
+		cmd.Stdin = strings.NewReader(testPDB)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("extract-seq --nonstandard %s failed: %v", tt.mode, err)
+		}
+		expected := ">stdin_A\n" + tt.expected + "\n"
+		if !strings.HasPrefix(string(output), expected) {
+			t.Errorf("--nonstandard %s: expected %q, got %q", tt.mode, expected, string(output))
+		}
+	}
+}
+
+func TestExtractSeqPerModel(t *testing.T) {
+	testPDB := `MODEL        1
+ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  GLY A   2      11.000  11.000  11.000  1.00 20.00           C
+ATOM      3  CA  GLY B   1      12.000  12.000  12.000  1.00 20.00           C
+ENDMDL
+MODEL        2
+ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  SER A   2      11.000  11.000  11.000  1.00 20.00           C
+ATOM      3  CA  GLY B   1      12.000  12.000  12.000  1.00 20.00           C
+ENDMDL
+END`
+
+	cmd := exec.Command("../bin/pdbtk", "extract-seq")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-seq failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), ">stdin_A\nAG\n>stdin_B\nG\n") {
+		t.Errorf("Expected first-model sequences, got %q", string(output))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "extract-seq", "--per-model")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-seq --per-model failed: %v", err)
+	}
+	expected := ">stdin_A_model1\nAG\n>stdin_A_model2\nAS\n>stdin_B\nG\n"
+	if !strings.HasPrefix(string(output), expected) {
+		t.Errorf("Expected %q, got %q", expected, string(output))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "extract-seq", "--per-model", "--id-template", "{chain}/{model}")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-seq --per-model --id-template failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), ">A/1\nAG\n>A/2\nAS\n") {
+		t.Errorf("Unexpected FASTA output: %q", string(output))
+	}
+}
+
+func TestExtractSeqMapOutput(t *testing.T) {
+	testPDB := `ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  GLY A   3      11.000  11.000  11.000  1.00 20.00           C
+ATOM      3  CA  SER A   3A     12.000  12.000  12.000  1.00 20.00           C
+END`
+
+	mapFile := "test_extract_seq_map.tsv"
+	defer os.Remove(mapFile)
+
+	cmd := exec.Command("../bin/pdbtk", "extract-seq", "--map-output", mapFile)
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-seq --map-output failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), ">stdin_A\nA-GS\n") {
+		t.Errorf("Unexpected FASTA output: %q", string(output))
+	}
+
+	mapping, err := os.ReadFile(mapFile)
+	if err != nil {
+		t.Fatalf("Failed to read map file: %v", err)
+	}
+	expected := "sequence_id\tposition\tchain\tresnum\ticode\tresname\n" +
+		"stdin_A\t1\tA\t1\t\tALA\n" +
+		"stdin_A\t3\tA\t3\t\tGLY\n" +
+		"stdin_A\t4\tA\t3\tA\tSER\n"
+	if string(mapping) != expected {
+		t.Errorf("Expected map:\n%q\ngot:\n%q", expected, string(mapping))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "extract-seq", "--seqres", "--map-output", mapFile)
+	cmd.Stdin = strings.NewReader(testPDB)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error for --map-output with --seqres")
+	}
+}
+
+func TestExtractSeqAnnotate(t *testing.T) {
+	testPDB := `HELIX    1   1 ALA A    2  LEU A    3  1                                   2
+ATOM      1  CA  MET A   1      10.000  10.000  10.000  1.00 95.00           C
+ATOM      2  CA  ALA A   2      11.000  11.000  11.000  1.00 87.00           C
+ATOM      3  CB  ALA A   2      11.500  11.000  11.000  1.00 83.00           C
+ATOM      4  CA  LEU A   3      12.000  12.000  12.000  1.00 42.00           C
+ATOM      5  CA  GLY A   5      13.000  13.000  13.000  1.00  5.00           C
+END`
+
+	cmd := exec.Command("../bin/pdbtk", "extract-seq", "--annotate", "ss,bfactor")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract-seq --annotate failed: %v", err)
+	}
+	expected := ">stdin_A\nMAL-G\n>stdin_A_ss\nCHH-C\n>stdin_A_bfactor\n984-0\n\n"
+	if string(output) != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, string(output))
+	}
+
+	for _, args := range [][]string{{"--annotate", "dssp"}, {"--annotate", "ss", "--seqres"}} {
+		cmd = exec.Command("../bin/pdbtk", append([]string{"extract-seq"}, args...)...)
+		cmd.Stdin = strings.NewReader(testPDB)
+		if err := cmd.Run(); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
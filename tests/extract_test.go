@@ -1,8 +1,10 @@
 package tests
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -134,3 +136,421 @@ END`
 		t.Error("Chain B extraction should contain chain B atoms")
 	}
 }
+
+func TestExtractResidueRanges(t *testing.T) {
+	testPDB := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+ATOM      1  CA  ALA A   1      10.000  10.000  10.000  1.00 10.00           C
+ATOM      2  CA  GLY A   2      13.800  10.000  10.000  1.00 10.00           C
+ATOM      3  CA  SER A   3      17.600  10.000  10.000  1.00 10.00           C
+ATOM      4  CA  THR A   5      25.200  10.000  10.000  1.00 10.00           C
+ATOM      5  CA  VAL A   6      29.000  10.000  10.000  1.00 10.00           C
+ATOM      6  CA  LEU B   3      10.000  20.000  10.000  1.00 10.00           C
+ATOM      7  CA  ILE B   3A     13.800  20.000  10.000  1.00 10.00           C
+ATOM      8  CA  MET B   4      17.600  20.000  10.000  1.00 10.00           C
+END`
+	if err := os.WriteFile("test_extract_ranges.pdb", []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_extract_ranges.pdb")
+
+	output, err := exec.Command("../bin/pdbtk", "extract", "--chains", "A:2-3,5,B:3-3A", "test_extract_ranges.pdb").Output()
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	var residues []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			residues = append(residues, line[21:22]+":"+strings.TrimSpace(line[22:27]))
+		}
+	}
+	if strings.Join(residues, " ") != "A:2 A:3 A:5 B:3 B:3A" {
+		t.Errorf("Expected residues A:2 A:3 A:5 B:3 B:3A, got %v", residues)
+	}
+
+	sequences := map[string]string{"A:2-5": "GS-T", "A:2,5": "GT", "A,B:4": "AGS-TV"}
+	for selection, expected := range sequences {
+		output, err := exec.Command("../bin/pdbtk", "extract-seq", "--wrap", "0", "--chains", selection, "test_extract_ranges.pdb").Output()
+		if err != nil {
+			t.Fatalf("extract-seq --chains %s failed: %v", selection, err)
+		}
+		if lines := strings.Split(string(output), "\n"); len(lines) < 2 || lines[1] != expected {
+			t.Errorf("extract-seq --chains %s: expected sequence %s, got:\n%s", selection, expected, string(output))
+		}
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--chains", "A:100-200", "test_extract_ranges.pdb")); code != 2 {
+		t.Errorf("Expected exit code 2 for a range without residues, got %d", code)
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--chains", "A:20-10", "test_extract_ranges.pdb")); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid range, got %d", code)
+	}
+}
+
+func TestExtractLigands(t *testing.T) {
+	testPDB := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+ATOM      1  N   ALA A   1      11.104   6.134  -6.504  1.00 10.00           N
+ATOM      2  CA  ALA A   1      11.639   6.071  -5.147  1.00 10.00           C
+ATOM      3  N   ALA B   1      21.104   6.134  -6.504  1.00 10.00           N
+ATOM      4  CA  ALA B   1      21.639   6.071  -5.147  1.00 10.00           C
+HETATM    5 FE   HEM A 201      12.000   7.000  -4.000  1.00 15.00          FE
+HETATM    6 FE   HEM B 201      22.000   7.000  -4.000  1.00 15.00          FE
+HETATM    7  PA  NAD A 202      14.000   8.000  -3.000  1.00 30.00           P
+HETATM    8  O   HOH A 301      13.000   8.000  -3.000  1.00 30.00           O
+END`
+	if err := os.WriteFile("test_extract_ligands.pdb", []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_extract_ligands.pdb")
+
+	residues := func(args ...string) string {
+		output, err := exec.Command("../bin/pdbtk", append([]string{"extract"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("extract %v failed: %v", args, err)
+		}
+		var residues []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+				residue := strings.TrimSpace(line[17:20]) + ":" + line[21:22]
+				if len(residues) == 0 || residues[len(residues)-1] != residue {
+					residues = append(residues, residue)
+				}
+			}
+		}
+		return strings.Join(residues, " ")
+	}
+
+	if got := residues("--chains", "A", "--ligand", "hem,NAD", "test_extract_ligands.pdb"); got != "ALA:A HEM:A NAD:A" {
+		t.Errorf("Expected chain A with its HEM and NAD, got %s", got)
+	}
+	if got := residues("--ligand", "HEM", "test_extract_ligands.pdb"); got != "HEM:A HEM:B" {
+		t.Errorf("Expected the HEM groups of both chains, got %s", got)
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--ligand", "XYZ", "test_extract_ligands.pdb")); code != 2 {
+		t.Errorf("Expected exit code 2 for a missing ligand, got %d", code)
+	}
+}
+
+func TestExtractBox(t *testing.T) {
+	testPDB := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+ATOM      1  N   ALA A   1       1.000   1.000   1.000  1.00 10.00           N
+ATOM      2  CA  ALA A   1       2.000   1.000   1.000  1.00 10.00           C
+ATOM      3  C   ALA A   1       3.000   1.000   1.000  1.00 10.00           C
+ATOM      4  N   GLY A   2       8.000   1.000   1.000  1.00 10.00           N
+ATOM      5  CA  GLY A   2       9.000   1.000   1.000  1.00 10.00           C
+HETATM    6  O   HOH A 101       2.500   2.000   1.000  1.00 30.00           O
+END`
+	if err := os.WriteFile("test_extract_box.pdb", []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_extract_box.pdb")
+
+	atomNames := func(args ...string) string {
+		output, err := exec.Command("../bin/pdbtk", append([]string{"extract"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("extract %v failed: %v", args, err)
+		}
+		var names []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+				names = append(names, strings.TrimSpace(line[17:20])+"."+strings.TrimSpace(line[12:16]))
+			}
+		}
+		return strings.Join(names, " ")
+	}
+
+	if got := atomNames("--box", "1.5:8.5,0:3,0:2", "test_extract_box.pdb"); got != "ALA.CA ALA.C GLY.N HOH.O" {
+		t.Errorf("Expected the atoms inside the box, got %s", got)
+	}
+	if got := atomNames("--chains", "A", "--box", "2.5:8.5,0:1.5,0:2", "--box-residues", "test_extract_box.pdb"); got != "ALA.N ALA.CA ALA.C GLY.N GLY.CA" {
+		t.Errorf("Expected whole residues reaching into the box, got %s", got)
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--box", "50:60,50:60,50:60", "test_extract_box.pdb")); code != 2 {
+		t.Errorf("Expected exit code 2 for an empty box, got %d", code)
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--box", "0:1,0:1", "test_extract_box.pdb")); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid box, got %d", code)
+	}
+}
+
+func TestExtractSecondaryStructure(t *testing.T) {
+	testPDB := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+HELIX    1  H1 GLY A    2  SER A    3  1                                   2
+HELIX    2  H2 LEU A    6  ILE A    7  1                                   2
+SHEET    1   S 1 VAL A   9  THR A  10  0
+ATOM      1  CA  ALA A   1       1.000   0.000   0.000  1.00 10.00           C
+ATOM      2  CA  GLY A   2       2.000   0.000   0.000  1.00 10.00           C
+ATOM      3  CA  SER A   3       3.000   0.000   0.000  1.00 10.00           C
+ATOM      4  CA  ASP A   4       4.000   0.000   0.000  1.00 10.00           C
+ATOM      5  CA  LYS A   5       5.000   0.000   0.000  1.00 10.00           C
+ATOM      6  CA  LEU A   6       6.000   0.000   0.000  1.00 10.00           C
+ATOM      7  CA  ILE A   7       7.000   0.000   0.000  1.00 10.00           C
+ATOM      8  CA  PRO A   8       8.000   0.000   0.000  1.00 10.00           C
+ATOM      9  CA  VAL A   9       9.000   0.000   0.000  1.00 10.00           C
+ATOM     10  CA  THR A  10      10.000   0.000   0.000  1.00 10.00           C
+HETATM   11  O   HOH A 101      11.000   0.000   0.000  1.00 30.00           O
+END`
+	if err := os.WriteFile("test_extract_ss.pdb", []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_extract_ss.pdb")
+
+	residueNumbers := func(args ...string) string {
+		output, err := exec.Command("../bin/pdbtk", append([]string{"extract"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("extract %v failed: %v", args, err)
+		}
+		var numbers []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+				numbers = append(numbers, strings.TrimSpace(line[22:26]))
+			}
+		}
+		return strings.Join(numbers, " ")
+	}
+
+	cases := map[string][]string{
+		"2 3 6 7":      {"--ss", "H"},
+		"1 4 5 8":      {"--chains", "A", "--ss", "C"},
+		"6 7 9 10":     {"--ss-element", "A:H2,A:E1"},
+		"2 3 6 7 9 10": {"--ss", "H,E"},
+	}
+	for expected, args := range cases {
+		if got := residueNumbers(append(args, "test_extract_ss.pdb")...); got != expected {
+			t.Errorf("extract %v: expected residues %s, got %s", args, expected, got)
+		}
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--ss", "X", "test_extract_ss.pdb")); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid --ss, got %d", code)
+	}
+}
+
+func TestExtractPolymer(t *testing.T) {
+	testPDB := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+ATOM      1  N   GLY A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA  GLY A   1       1.500   0.000   0.000  1.00 20.00           C
+ATOM      3  C   GLY A   1       2.000   1.400   0.000  1.00 20.00           C
+HETATM    4  N   MSE A   2       3.000   2.000   0.000  1.00 20.00           N
+HETATM    5  CA  MSE A   2       4.000   2.500   0.000  1.00 20.00           C
+HETATM    6  C   MSE A   2       5.000   3.000   0.000  1.00 20.00           C
+ATOM      7  P    DA B   1      10.000   0.000   0.000  1.00 20.00           P
+ATOM      8  C1'  DA B   1      11.000   0.000   0.000  1.00 20.00           C
+ATOM      9  P     U C   1      20.000   0.000   0.000  1.00 20.00           P
+ATOM     10  O2'   U C   1      21.000   0.000   0.000  1.00 20.00           O
+HETATM   11 FE   HEM A 101      30.000   0.000   0.000  1.00 20.00          FE
+HETATM   12  O   HOH A 201      40.000   0.000   0.000  1.00 20.00           O
+END`
+	if err := os.WriteFile("test_extract_polymer.pdb", []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_extract_polymer.pdb")
+
+	residues := func(args ...string) string {
+		output, err := exec.Command("../bin/pdbtk", append([]string{"extract"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("extract %v failed: %v", args, err)
+		}
+		var residues []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+				residue := strings.TrimSpace(line[17:20]) + ":" + line[21:22]
+				if len(residues) == 0 || residues[len(residues)-1] != residue {
+					residues = append(residues, residue)
+				}
+			}
+		}
+		return strings.Join(residues, " ")
+	}
+
+	// The modified residue MSE follows its protein chain
+	if got := residues("--polymer", "protein", "test_extract_polymer.pdb"); got != "GLY:A MSE:A" {
+		t.Errorf("Expected the protein residues, got %s", got)
+	}
+	if got := residues("--polymer", "dna,rna,water", "test_extract_polymer.pdb"); got != "DA:B U:C HOH:A" {
+		t.Errorf("Expected the nucleic acids and water, got %s", got)
+	}
+	if got := residues("--chains", "A", "--polymer", "ligand", "test_extract_polymer.pdb"); got != "HEM:A" {
+		t.Errorf("Expected the ligand of chain A, got %s", got)
+	}
+
+	output, err := exec.Command("../bin/pdbtk", "extract-seq", "--polymer", "protein", "test_extract_polymer.pdb").Output()
+	if err != nil {
+		t.Fatalf("extract-seq --polymer failed: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != ">test_extract_polymer_A\nGM" {
+		t.Errorf("Expected only the protein sequence, got:\n%s", string(output))
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--polymer", "lipid", "test_extract_polymer.pdb")); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid --polymer, got %d", code)
+	}
+}
+
+func TestExtractAssembly(t *testing.T) {
+	testCIF := `data_1ABC
+_entry.id 1ABC
+loop_
+_pdbx_struct_assembly_gen.assembly_id
+_pdbx_struct_assembly_gen.oper_expression
+_pdbx_struct_assembly_gen.asym_id_list
+1 1,2 A,C
+2 1 B
+loop_
+_pdbx_struct_oper_list.id
+_pdbx_struct_oper_list.type
+_pdbx_struct_oper_list.matrix[1][1]
+_pdbx_struct_oper_list.matrix[1][2]
+_pdbx_struct_oper_list.matrix[1][3]
+_pdbx_struct_oper_list.vector[1]
+_pdbx_struct_oper_list.matrix[2][1]
+_pdbx_struct_oper_list.matrix[2][2]
+_pdbx_struct_oper_list.matrix[2][3]
+_pdbx_struct_oper_list.vector[2]
+_pdbx_struct_oper_list.matrix[3][1]
+_pdbx_struct_oper_list.matrix[3][2]
+_pdbx_struct_oper_list.matrix[3][3]
+_pdbx_struct_oper_list.vector[3]
+1 'identity operation' 1 0 0 0 0 1 0 0 0 0 1 0
+2 'crystal symmetry operation' -1 0 0 10 0 -1 0 0 0 0 1 5
+loop_
+_atom_site.group_PDB
+_atom_site.id
+_atom_site.type_symbol
+_atom_site.label_atom_id
+_atom_site.label_alt_id
+_atom_site.label_comp_id
+_atom_site.label_asym_id
+_atom_site.label_entity_id
+_atom_site.label_seq_id
+_atom_site.pdbx_PDB_ins_code
+_atom_site.Cartn_x
+_atom_site.Cartn_y
+_atom_site.Cartn_z
+_atom_site.occupancy
+_atom_site.B_iso_or_equiv
+_atom_site.auth_seq_id
+_atom_site.auth_comp_id
+_atom_site.auth_asym_id
+_atom_site.auth_atom_id
+_atom_site.pdbx_PDB_model_num
+ATOM 1 N N . ALA A 1 1 ? 1.000 2.000 3.000 1.00 10.00 5 ALA A N 1
+ATOM 2 C CA . ALA A 1 1 ? 2.000 2.000 3.000 1.00 11.00 5 ALA A CA 1
+ATOM 3 N N . GLY B 1 1 ? 5.000 5.000 5.000 1.00 12.00 1 GLY B N 1
+HETATM 4 ZN ZN . ZN C 2 . ? 3.000 3.000 3.000 1.00 20.00 101 ZN A ZN 1
+`
+	inputFile := "test_extract_assembly.cif"
+	if err := os.WriteFile(inputFile, []byte(testCIF), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(inputFile)
+
+	output, err := exec.Command("../bin/pdbtk", "extract", "--assembly", "1", inputFile).Output()
+	if err != nil {
+		t.Fatalf("extract --assembly failed: %v", err)
+	}
+	var atoms []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+			atoms = append(atoms, line[12:54])
+		}
+	}
+	expected := []string{
+		" N   ALA A   5       1.000   2.000   3.000",
+		" CA  ALA A   5       2.000   2.000   3.000",
+		"ZN    ZN A 101       3.000   3.000   3.000",
+		" N   ALA B   5       9.000  -2.000   8.000",
+		" CA  ALA B   5       8.000  -2.000   8.000",
+		"ZN    ZN B 101       7.000  -3.000   8.000",
+	}
+	if strings.Join(atoms, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected assembly atoms:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(atoms, "\n"))
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--assembly", "3", inputFile)); code != 2 {
+		t.Errorf("Expected exit code 2 for a missing assembly, got %d", code)
+	}
+}
+
+func TestExtractModels(t *testing.T) {
+	var testPDB strings.Builder
+	for model := 1; model <= 5; model++ {
+		fmt.Fprintf(&testPDB, "MODEL     %4d\n", model)
+		fmt.Fprintf(&testPDB, "ATOM      1  CA  ALA A   1      %6.3f  10.000  10.000  1.00 10.00           C\n", float64(10+model))
+		testPDB.WriteString("ENDMDL\n")
+	}
+	testPDB.WriteString("END\n")
+	if err := os.WriteFile("test_extract_models.pdb", []byte(testPDB.String()), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_extract_models.pdb")
+
+	models := func(args ...string) string {
+		output, err := exec.Command("../bin/pdbtk", append([]string{"extract"}, args...)...).Output()
+		if err != nil {
+			t.Fatalf("extract %v failed: %v", args, err)
+		}
+		var models []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(line, "MODEL") {
+				models = append(models, strings.TrimSpace(line[10:14]))
+			}
+		}
+		return strings.Join(models, ",")
+	}
+
+	if got := models("--models", "1,3-4", "test_extract_models.pdb"); got != "1,3,4" {
+		t.Errorf("Expected models 1,3,4, got %s", got)
+	}
+	if got := models("--drop-models", "2,4-5", "test_extract_models.pdb"); got != "1,3" {
+		t.Errorf("Expected models 1,3, got %s", got)
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--models", "7-9", "test_extract_models.pdb")); code != 2 {
+		t.Errorf("Expected exit code 2 for missing models, got %d", code)
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--models", "1", "--drop-models", "2", "test_extract_models.pdb")); code != 1 {
+		t.Errorf("Expected exit code 1 for --models with --drop-models, got %d", code)
+	}
+}
+
+func TestExtractKeepRemarks(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "remarks.pdb")
+	content := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+REMARK   2 RESOLUTION.    1.80 ANGSTROMS.
+REMARK 200 EXPERIMENT TYPE                : X-RAY DIFFRACTION
+REMARK 350 BIOMOLECULE: 1
+REMARK 350 APPLY THE FOLLOWING TO CHAINS: A, B
+REMARK 350   BIOMT1   1  1.000000  0.000000  0.000000        0.00000
+REMARK 350 BIOMOLECULE: 2
+REMARK 350 APPLY THE FOLLOWING TO CHAINS: B
+REMARK 350   BIOMT1   1  1.000000  0.000000  0.000000        0.00000
+REMARK 465   M RES C SSSEQI
+REMARK 465     MET A     0
+REMARK 465     GLY B     0
+` + strings.SplitN(batchTestPDB, "\n", 2)[1]
+	if err := os.WriteFile(input, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	// The chain-only and record-level extraction paths
+	for _, args := range [][]string{{"--chains", "A"}, {"--chains", "A", "--polymer", "protein"}} {
+		args = append([]string{"extract", "--keep-remarks", "2,350,465"}, append(args, input)...)
+		output, err := exec.Command("../bin/pdbtk", args...).Output()
+		if err != nil {
+			t.Fatalf("extract %v failed: %v", args, err)
+		}
+		out := string(output)
+		for _, expected := range []string{"RESOLUTION.    1.80", "BIOMOLECULE: 1", "APPLY THE FOLLOWING TO CHAINS: A\n", "MET A     0"} {
+			if !strings.Contains(out, expected) {
+				t.Errorf("extract %v: expected %q in output:\n%s", args, expected, out)
+			}
+		}
+		for _, unexpected := range []string{"REMARK 200", "BIOMOLECULE: 2", "GLY B     0"} {
+			if strings.Contains(out, unexpected) {
+				t.Errorf("extract %v: expected no %q in output:\n%s", args, unexpected, out)
+			}
+		}
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--keep-remarks", "x", input)); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid --keep-remarks, got %d", code)
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFilesFrom(t *testing.T) {
+	testPDB := `ATOM      1  CA  ALA A   1      11.000  10.000  10.000  1.00 10.00           C
+END
+`
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"one.pdb", "two.pdb"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(testPDB), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		paths = append(paths, path)
+	}
+
+	checksumFiles := func(stdin string, args ...string) string {
+		cmd := exec.Command("../bin/pdbtk", args...)
+		cmd.Stdin = strings.NewReader(stdin)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("canonicalize %v failed: %v", args, err)
+		}
+		var files []string
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			files = append(files, filepath.Base(strings.Fields(line)[1]))
+		}
+		return strings.Join(files, ",")
+	}
+
+	// One path per line, with comments and blank lines
+	list := "# models\n" + paths[0] + "\n\n" + paths[1] + "\n"
+	if got := checksumFiles(list, "canonicalize", "--checksum", "-l", "-"); got != "one.pdb,two.pdb" {
+		t.Errorf("Expected both files from stdin, got %s", got)
+	}
+	// NUL-separated paths, as written by find -print0
+	if got := checksumFiles(paths[1]+"\x00"+paths[0]+"\x00", "--files-from=-", "canonicalize", "--checksum"); got != "two.pdb,one.pdb" {
+		t.Errorf("Expected both files from NUL-separated stdin, got %s", got)
+	}
+
+	// Paths from a file are added after the positional arguments
+	listFile := filepath.Join(dir, "files.txt")
+	if err := os.WriteFile(listFile, []byte(paths[1]+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to create file list: %v", err)
+	}
+	if got := checksumFiles("", "canonicalize", "--checksum", paths[0], "--files-from", listFile); got != "one.pdb,two.pdb" {
+		t.Errorf("Expected the positional and listed files, got %s", got)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "canonicalize", "--checksum", "-l", "-")
+	cmd.Stdin = strings.NewReader("\n")
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 for an empty file list, got %d", code)
+	}
+}
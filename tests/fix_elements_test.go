@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestFixElementsCommand(t *testing.T) {
+	testPDB := `ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           C
+ATOM      2  CA  ALA A   1      19.030  16.206  23.362  1.00 10.53
+HETATM    3 CA    CA A 101      10.000  10.000  10.000  1.00 20.00           C
+HETATM    4 SE   MSE A 102      11.000  10.000  10.000  1.00 20.00
+HETATM    5  CA  MSE A 102      11.000  10.000  10.000  1.00 20.00
+HETATM    6 CL1  LIG A 103      11.000  10.000  10.000  1.00 20.00           C
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "fix-elements")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("fix-elements command failed: %v", err)
+	}
+
+	outputStr := string(output)
+	expected := []string{
+		"ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           N",
+		"ATOM      2  CA  ALA A   1      19.030  16.206  23.362  1.00 10.53           C",
+		"HETATM    4 CA    CA A 101      10.000  10.000  10.000  1.00 20.00          CA",
+		"HETATM    5 SE   MSE A 102      11.000  10.000  10.000  1.00 20.00          SE",
+		"HETATM    6  CA  MSE A 102      11.000  10.000  10.000  1.00 20.00           C",
+		"HETATM    7 CL1  LIG A 103      11.000  10.000  10.000  1.00 20.00          CL",
+	}
+	for _, e := range expected {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package tests
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetUniprot(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/uniprot/summary/P69905.json":
+			fmt.Fprintf(w, `{"uniprot_entry": {"ac": "P69905"}, "structures": [
+				{"summary": {"model_identifier": "1a00", "model_category": "EXPERIMENTALLY DETERMINED", "provider": "PDBe",
+					"model_url": "%[1]s/files/1a00.cif", "model_format": "MMCIF", "coverage": 0.99}},
+				{"summary": {"model_identifier": "AF-P69905-F1", "model_category": "AB-INITIO", "provider": "AlphaFold DB",
+					"model_url": "%[1]s/files/AF-P69905-F1.pdb", "model_format": "PDB", "created": "2022-06-01"}}]}`, server.URL)
+		case "/files/1a00.cif":
+			w.Write([]byte("data_1A00\n"))
+		case "/files/AF-P69905-F1.pdb":
+			w.Write([]byte("HEADER    AF-P69905-F1\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir, err := os.MkdirTemp("", "pdbtk_get_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.Command("../bin/pdbtk", "get", "--uniprot", "--outdir", dir, "p69905")
+	cmd.Env = append(cmd.Environ(), "PDBTK_3DBEACONS_URL="+server.URL)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("get --uniprot failed: %v\n%s", err, string(output))
+	}
+
+	for file, content := range map[string]string{
+		"P69905_PDBe_1a00.cif":                 "data_1A00\n",
+		"P69905_AlphaFold_DB_AF-P69905-F1.pdb": "HEADER    AF-P69905-F1\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil || string(got) != content {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", file, content, string(got), err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "P69905_models.json"))
+	if err != nil {
+		t.Fatalf("Expected the metadata file to be written: %v", err)
+	}
+	var manifest []struct {
+		File    string `json:"file"`
+		Summary struct {
+			Provider string `json:"provider"`
+			Created  string `json:"created"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatalf("Invalid metadata file: %v\n%s", err, string(data))
+	}
+	if len(manifest) != 2 || manifest[1].File != "P69905_AlphaFold_DB_AF-P69905-F1.pdb" || manifest[1].Summary.Created != "2022-06-01" {
+		t.Errorf("Unexpected metadata: %+v", manifest)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "get", "--uniprot", "--outdir", dir, "Q99999")
+	cmd.Env = append(cmd.Environ(), "PDBTK_3DBEACONS_URL="+server.URL)
+	if err := cmd.Run(); err == nil || cmd.ProcessState.ExitCode() != 2 {
+		t.Errorf("Expected exit code 2 for an accession without models, got %v", err)
+	}
+}
+
+func TestGetRateLimitAndUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	var agents []string
+	var times []time.Time
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents = append(agents, r.UserAgent())
+		times = append(times, time.Now())
+		mu.Unlock()
+		if r.URL.Path == "/uniprot/summary/P69905.json" {
+			fmt.Fprintf(w, `{"structures": [
+				{"summary": {"model_identifier": "m1", "provider": "P", "model_url": "%[1]s/files/m1.pdb"}},
+				{"summary": {"model_identifier": "m2", "provider": "P", "model_url": "%[1]s/files/m2.pdb"}},
+				{"summary": {"model_identifier": "m3", "provider": "P", "model_url": "%[1]s/files/m3.pdb"}}]}`, server.URL)
+			return
+		}
+		w.Write([]byte("HEADER\n"))
+	}))
+	defer server.Close()
+
+	cmd := exec.Command("../bin/pdbtk", "get", "--uniprot", "--outdir", t.TempDir(), "--max-concurrent", "3",
+		"--rate-limit", "10", "--user-agent", "test-pipeline (me@example.org)", "P69905")
+	cmd.Env = append(cmd.Environ(), "PDBTK_3DBEACONS_URL="+server.URL)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("get --rate-limit failed: %v\n%s", err, string(output))
+	}
+	if len(times) != 4 {
+		t.Fatalf("Expected 4 requests, got %d", len(times))
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < len(times); i++ {
+		// 10 requests per second, with some allowance for timer precision
+		if gap := times[i].Sub(times[i-1]); gap < 80*time.Millisecond {
+			t.Errorf("Expected requests at least 100ms apart, got %v", gap)
+		}
+	}
+	for _, agent := range agents {
+		if agent != "test-pipeline (me@example.org)" {
+			t.Errorf("Expected the --user-agent, got %q", agent)
+		}
+	}
+
+	agents = nil
+	cmd = exec.Command("../bin/pdbtk", "get", "--uniprot", "--outdir", t.TempDir(), "P69905")
+	cmd.Env = append(cmd.Environ(), "PDBTK_3DBEACONS_URL="+server.URL, "PDBTK_USER_AGENT=env-agent")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("get failed: %v\n%s", err, string(output))
+	}
+	if len(agents) == 0 || agents[0] != "env-agent" {
+		t.Errorf("Expected the User-Agent from PDBTK_USER_AGENT, got %v", agents)
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "get", "--max-concurrent", "0", "1A02")); code != 1 {
+		t.Errorf("Expected exit code 1 for --max-concurrent 0, got %d", code)
+	}
+}
+
+func TestGetProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(batchTestPDB + "\n"))
+	}))
+	defer server.Close()
+
+	for _, concurrent := range []string{"1", "3"} {
+		cmd := exec.Command("../bin/pdbtk", "get", "--progress", "always", "--max-concurrent", concurrent,
+			"--outdir", t.TempDir(), "1ABC", "2ABC", "3ABC")
+		cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_FILES_URL="+server.URL)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("get --max-concurrent %s failed: %v\n%s", concurrent, err, output)
+		}
+		if !strings.Contains(string(output), "Downloading [") || !strings.Contains(string(output), "100% 3/3") {
+			t.Errorf("Expected the number of entries downloaded with --max-concurrent %s, got:\n%s", concurrent, output)
+		}
+	}
+}
+
+func TestGetVerifiesDownloads(t *testing.T) {
+	content := []byte("HEADER    VERIFIED\n")
+	sum := md5.Sum(content)
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		attempt := requests[r.URL.Path]
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/uniprot/summary/P69905.json":
+			fmt.Fprintf(w, `{"structures": [{"summary": {"model_identifier": "m1", "provider": "P", "model_url": "%s/files/m1.pdb"}}]}`, server.URL)
+		case "/uniprot/summary/P68871.json":
+			fmt.Fprintf(w, `{"structures": [{"summary": {"model_identifier": "m2", "provider": "P", "model_url": "%s/files/m2.pdb.gz"}}]}`, server.URL)
+		case "/files/m1.pdb":
+			// The first transfer is corrupted
+			w.Header().Set("Content-MD5", base64.StdEncoding.EncodeToString(sum[:]))
+			if attempt == 1 {
+				w.Write([]byte("HEADER    CORRUPTED\n"))
+				return
+			}
+			w.Write(content)
+		case "/files/m2.pdb.gz":
+			w.Write([]byte("not gzip data"))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cmd := exec.Command("../bin/pdbtk", "get", "--uniprot", "--outdir", dir, "P69905")
+	cmd.Env = append(cmd.Environ(), "PDBTK_3DBEACONS_URL="+server.URL)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("get failed: %v\n%s", err, string(output))
+	}
+	if !strings.Contains(string(output), "Retrying") || requests["/files/m1.pdb"] != 2 {
+		t.Errorf("Expected the corrupted download to be retried once, got %d requests\n%s", requests["/files/m1.pdb"], string(output))
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "P69905_P_m1.pdb")); err != nil || string(got) != string(content) {
+		t.Errorf("Expected the verified download, got %q (%v)", string(got), err)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "get", "--uniprot", "--outdir", dir, "--retries", "1", "P68871")
+	cmd.Env = append(cmd.Environ(), "PDBTK_3DBEACONS_URL="+server.URL)
+	if code := exitCodeOf(t, cmd); code == 0 {
+		t.Errorf("Expected a corrupt gzip download to fail")
+	}
+	if requests["/files/m2.pdb.gz"] != 2 {
+		t.Errorf("Expected 2 attempts with --retries 1, got %d", requests["/files/m2.pdb.gz"])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "P68871_P_m2.pdb.gz")); !os.IsNotExist(err) {
+		t.Errorf("Expected no file to be left for a corrupt download, got %v", err)
+	}
+}
+
+func TestGetWithMetadata(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/download/1ABC.pdb", "/download/2ABC.pdb", "/files/m1.pdb":
+			w.Write([]byte("HEADER\n"))
+		case "/rest/v1/core/entry/1ABC":
+			w.Write([]byte(`{"struct": {"title": "A TEST STRUCTURE"}, "exptl": [{"method": "X-RAY DIFFRACTION"}],
+				"rcsb_entry_info": {"resolution_combined": [1.8]}}`))
+		case "/uniprot/summary/P69905.json":
+			fmt.Fprintf(w, `{"structures": [{"summary": {"model_identifier": "m1", "provider": "P", "model_format": "PDB",
+				"model_url": "%s/files/m1.pdb", "experimental_method": "X-RAY DIFFRACTION", "resolution": 2.1}}]}`, server.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	type sidecar struct {
+		Entry      string   `json:"entry"`
+		File       string   `json:"file"`
+		SourceURL  string   `json:"source_url"`
+		Retrieved  string   `json:"retrieved"`
+		Format     string   `json:"format"`
+		Provider   string   `json:"provider"`
+		Title      string   `json:"title"`
+		Method     string   `json:"experimental_method"`
+		Resolution *float64 `json:"resolution"`
+	}
+	readSidecar := func(file string) sidecar {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Expected a metadata sidecar: %v", err)
+		}
+		var s sidecar
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("Invalid metadata sidecar: %v\n%s", err, string(data))
+		}
+		return s
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command("../bin/pdbtk", "get", "--with-metadata", "--outdir", dir, "1ABC", "2ABC")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_FILES_URL="+server.URL, "PDBTK_RCSB_DATA_URL="+server.URL)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("get --with-metadata failed: %v\n%s", err, string(output))
+	}
+	s := readSidecar(filepath.Join(dir, "1ABC.pdb.json"))
+	if s.Entry != "1ABC" || s.File != "1ABC.pdb" || s.SourceURL != server.URL+"/download/1ABC.pdb" || s.Format != "pdb" ||
+		s.Title != "A TEST STRUCTURE" || s.Method != "X-RAY DIFFRACTION" || s.Resolution == nil || *s.Resolution != 1.8 {
+		t.Errorf("Unexpected metadata: %+v", s)
+	}
+	if _, err := time.Parse(time.RFC3339, s.Retrieved); err != nil {
+		t.Errorf("Expected an RFC 3339 retrieval time, got %q", s.Retrieved)
+	}
+	// Entries without metadata still get a sidecar, with a warning
+	if s := readSidecar(filepath.Join(dir, "2ABC.pdb.json")); s.Entry != "2ABC" || s.Title != "" {
+		t.Errorf("Unexpected metadata: %+v", s)
+	}
+	if !strings.Contains(string(output), "no metadata found for 2ABC") {
+		t.Errorf("Expected a warning for the missing metadata, got:\n%s", string(output))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "get", "--uniprot", "--with-metadata", "--outdir", dir, "P69905")
+	cmd.Env = append(cmd.Environ(), "PDBTK_3DBEACONS_URL="+server.URL)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("get --uniprot --with-metadata failed: %v\n%s", err, string(output))
+	}
+	s = readSidecar(filepath.Join(dir, "P69905_P_m1.pdb.json"))
+	if s.Entry != "m1" || s.Provider != "P" || s.Format != "pdb" || s.Resolution == nil || *s.Resolution != 2.1 {
+		t.Errorf("Unexpected metadata: %+v", s)
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "get", "--with-metadata", "--output", "-", "1ABC")); code != 1 {
+		t.Errorf("Expected exit code 1 for --with-metadata to stdout, got %d", code)
+	}
+}
+
+func TestGetTimeoutAndInterrupt(t *testing.T) {
+	// The server never answers, until the client gives up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	cmd := exec.Command("../bin/pdbtk", "get", "--timeout", "300ms", "--outdir", dir, "1ABC")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_FILES_URL="+server.URL)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	start := time.Now()
+	if code := exitCodeOf(t, cmd); code != 6 {
+		t.Errorf("get --timeout exit code = %d, want 6\n%s", code, stderr.String())
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("get --timeout took %s", elapsed)
+	}
+	if !strings.Contains(stderr.String(), "timed out after 300ms") {
+		t.Errorf("Unexpected error: %s", stderr.String())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected no files after a timeout, got %d", len(entries))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "get", "--outdir", dir, "1ABC")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_FILES_URL="+server.URL)
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start get: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	cmd.Process.Signal(os.Interrupt)
+	err := cmd.Wait()
+	if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 6 || !strings.Contains(stderr.String(), "interrupted") {
+		t.Errorf("Interrupted get: %v\n%s", err, stderr.String())
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "version", "--timeout", "-1s")); code != 1 {
+		t.Errorf("Negative --timeout exit code = %d, want 1", code)
+	}
+}
+
+func TestGetFailuresKeepErrorCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	report := filepath.Join(dir, "report.json")
+	cmd := exec.Command("../bin/pdbtk", "get", "--json-errors", "--report", report, "--outdir", dir, "1ABC", "2ABC")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_FILES_URL="+server.URL)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if code := exitCodeOf(t, cmd); code != 4 {
+		t.Errorf("get exit code = %d, want 4 when every download fails with a network error\n%s", code, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	var runError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &runError); err != nil {
+		t.Fatalf("Failed to parse JSON error: %v\n%s", err, stderr.String())
+	}
+	if runError.Code != "network_error" || runError.Message != "2 of 2 downloads failed" {
+		t.Errorf("Unexpected run error: %+v", runError)
+	}
+
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("Expected report file to be written: %v", err)
+	}
+	var runReport struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(content, &runReport); err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
+	if runReport.Error.Code != "network_error" {
+		t.Errorf("Report error code = %q, want network_error", runReport.Error.Code)
+	}
+}
@@ -0,0 +1,129 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const importSSTestPDB = `HELIX    1   1 MET A    1  ALA A    2  1                                   2
+ATOM      1  CA  MET A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  ALA A   2      11.000  11.000  11.000  1.00 20.00           C
+ATOM      3  CA  LEU A   3      12.000  12.000  12.000  1.00 20.00           C
+ATOM      4  CA  LYS A   4      13.000  13.000  13.000  1.00 20.00           C
+ATOM      5  CA  GLU A   5      14.000  14.000  14.000  1.00 20.00           C
+ATOM      6  CA  VAL A   6      15.000  15.000  15.000  1.00 20.00           C
+ATOM      7  CA  THR A   7      16.000  16.000  16.000  1.00 20.00           C
+ATOM      8  CA  ILE A   8      17.000  17.000  17.000  1.00 20.00           C
+ATOM      9  CA  SER A   9      18.000  18.000  18.000  1.00 20.00           C
+ATOM     10  CA  GLY A  10      19.000  19.000  19.000  1.00 20.00           C
+END`
+
+const importSSTestDSSP = `==== Secondary Structure Definition by the program DSSP, CMBI version 2.2.1 ==== DATE=2026-10-18        .
+  #  RESIDUE AA STRUCTURE BP1 BP2  ACC     N-H-->O    O-->H-N    N-H-->O    O-->H-N    TCO  KAPPA ALPHA  PHI   PSI    X-CA   Y-CA   Z-CA
+    1    1 A M              0   0  170      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   10.0   10.0   10.0
+    2    2 A A  H  >         0   0   50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   11.0   11.0   11.0
+    3    3 A L  H  >         0   0   50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   12.0   12.0   12.0
+    4    4 A K  H  >         0   0   50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   13.0   13.0   13.0
+    5    5 A E  E     -A    9   0A  50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   14.0   14.0   14.0
+    6    6 A V  E     -A    8   0A  50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   15.0   15.0   15.0
+    7    7 A T  T  3  S+     0   0   50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   16.0   16.0   16.0
+    8    8 A I  E     -A    6   0A  50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   17.0   17.0   17.0
+    9    9 A S  E     -A    5   0A  50      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   18.0   18.0   18.0
+   10   10 A G              0   0  170      0, 0.0     2,-0.3     0, 0.0     0, 0.0   0.000 360.0 360.0 360.0 143.2   19.0   19.0   19.0`
+
+const importSSTestCIF = `data_test
+loop_
+_struct_conf.conf_type_id
+_struct_conf.id
+_struct_conf.beg_label_comp_id
+_struct_conf.beg_label_asym_id
+_struct_conf.beg_label_seq_id
+_struct_conf.pdbx_beg_PDB_ins_code
+_struct_conf.end_label_asym_id
+_struct_conf.end_label_seq_id
+_struct_conf.pdbx_end_PDB_ins_code
+_struct_conf.beg_auth_asym_id
+_struct_conf.beg_auth_seq_id
+_struct_conf.end_auth_asym_id
+_struct_conf.end_auth_seq_id
+HELX_RH_3T_P HELX_RH_3T_P1 ALA A 2 ? A 4 ? A 2 A 4
+TURN_TY1_P TURN_TY1_P1 THR A 7 ? A 7 ? A 7 A 7
+#
+loop_
+_struct_sheet_range.sheet_id
+_struct_sheet_range.id
+_struct_sheet_range.beg_auth_asym_id
+_struct_sheet_range.beg_auth_seq_id
+_struct_sheet_range.end_auth_asym_id
+_struct_sheet_range.end_auth_seq_id
+A 1 A 5 A 6
+A 2 A 8 A 9
+#
+_struct_sheet_order.sheet_id A
+_struct_sheet_order.range_id_1 1
+_struct_sheet_order.range_id_2 2
+_struct_sheet_order.sense anti-parallel`
+
+func TestImportSS(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.pdb")
+	files := map[string]string{input: importSSTestPDB, filepath.Join(dir, "ss.dssp"): importSSTestDSSP, filepath.Join(dir, "ss.cif"): importSSTestCIF}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content+"\n"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		file       string
+		helixClass string
+	}{
+		{"ss.dssp", "1"},
+		{"ss.cif", "5"},
+	}
+	for _, tt := range tests {
+		output, err := exec.Command("../bin/pdbtk", "import-ss", "--dssp", filepath.Join(dir, tt.file), input).Output()
+		if err != nil {
+			t.Fatalf("import-ss --dssp %s failed: %v", tt.file, err)
+		}
+		var records []string
+		for _, line := range strings.Split(string(output), "\n") {
+			if strings.HasPrefix(line, "HELIX") || strings.HasPrefix(line, "SHEET") {
+				records = append(records, line)
+			}
+		}
+		expected := []string{
+			"HELIX    1   1 ALA A    2  LYS A    4  " + tt.helixClass + "                                   3",
+			"SHEET    1   A 2 GLU A   5  VAL A   6  0",
+			"SHEET    2   A 2 ILE A   8  SER A   9 -1",
+		}
+		if strings.Join(records, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: expected records:\n%s\ngot:\n%s", tt.file, strings.Join(expected, "\n"), strings.Join(records, "\n"))
+		}
+	}
+
+	// The imported strands are used by extract --ss
+	imported, err := exec.Command("../bin/pdbtk", "import-ss", "--dssp", filepath.Join(dir, "ss.dssp"), input).Output()
+	if err != nil {
+		t.Fatalf("import-ss failed: %v", err)
+	}
+	cmd := exec.Command("../bin/pdbtk", "extract", "--ss", "E")
+	cmd.Stdin = strings.NewReader(string(imported))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract --ss E failed: %v", err)
+	}
+	if n := strings.Count(string(output), "ATOM  "); n != 4 {
+		t.Errorf("Expected 4 strand residues, got %d:\n%s", n, string(output))
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "import-ss", "--dssp", input, input)); code != 3 {
+		t.Errorf("Expected exit code 3 for a file that is not DSSP output, got %d", code)
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "import-ss", input)); code != 1 {
+		t.Errorf("Expected exit code 1 without --dssp, got %d", code)
+	}
+}
@@ -0,0 +1,44 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestIsoelectricPoint(t *testing.T) {
+	testPDB := `SEQRES   1 A    3  GLY GLY LYS
+SEQRES   1 B    2  GLY ASP
+SEQRES   1 C    2   DA  DT
+ATOM      1  CA  GLY A   1       0.000   0.000   0.000  1.00 20.00           C
+ATOM      2  CA  GLY A   2       3.800   0.000   0.000  1.00 20.00           C
+ATOM      3  CA  GLY B   1       0.000   9.000   0.000  1.00 20.00           C
+ATOM      4  CA  ASP B   2       3.800   9.000   0.000  1.00 20.00           C
+ATOM      5  P    DA C   1       0.000  20.000   0.000  1.00 20.00           P
+ATOM      6  P    DT C   2       5.000  20.000   0.000  1.00 20.00           P
+END
+`
+	// Only the termini of chain A are charged, so its pI is the mean of their pKa values; the DNA chain
+	// is left out
+	cmd := exec.Command("../bin/pdbtk", "isoelectric-point")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("isoelectric-point failed: %v", err)
+	}
+	expected := "chain\tlength\tpi\tph\tcharge\nA\t2\t6.10\t7.00\t-0.02\nB\t2\t3.75\t7.00\t-1.02\n"
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, string(output))
+	}
+
+	// The SEQRES sequence of chain A includes the unmodelled lysine
+	cmd = exec.Command("../bin/pdbtk", "isoelectric-point", "--seqres", "--chains", "A", "--ph", "5")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("isoelectric-point --seqres failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(output)), "\n"); len(lines) != 2 || lines[1] != "A\t3\t9.70\t5.00\t1.04" {
+		t.Errorf("Unexpected output:\n%s", string(output))
+	}
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLigandContacts(t *testing.T) {
+	testPDB := `ATOM      1  NZ  LYS A  10      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  OG  SER A  11      10.000  14.000  10.000  1.00 20.00           O
+ATOM      3  CD1 LEU A  12      16.000  10.000  10.000  1.00 20.00           C
+ATOM      4  CA  GLY A  13      30.000  30.000  30.000  1.00 20.00           C
+HETATM    5  O1  LIG A 101      10.000  12.800  10.000  1.00 20.00           O
+HETATM    6  C1  LIG A 101      12.500  10.000  10.000  1.00 20.00           C
+HETATM    7  C2  LIG A 101      13.800  10.000  10.000  1.00 20.00           C
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "ligand-contacts", "--het", "LIG", "--format", "json")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("ligand-contacts failed: %v", err)
+	}
+	var contacts []struct {
+		LigandAtom  string  `json:"ligand_atom"`
+		ResNum      string  `json:"resnum"`
+		Atom        string  `json:"atom"`
+		Distance    float64 `json:"distance"`
+		Interaction string  `json:"interaction"`
+	}
+	if err := json.Unmarshal(output, &contacts); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+
+	var got []string
+	for _, c := range contacts {
+		got = append(got, c.ResNum+" "+c.Atom+" "+c.LigandAtom+" "+c.Interaction)
+	}
+	expected := []string{
+		"10 NZ O1 ionic",
+		"10 NZ C1 contact",
+		"10 NZ C2 contact",
+		"11 OG O1 hbond",
+		"12 CD1 C1 hydrophobic",
+		"12 CD1 C2 hydrophobic",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected contacts:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "ligand-contacts", "--het", "XYZ")
+	cmd.Stdin = strings.NewReader(testPDB)
+	if err := cmd.Run(); err == nil || cmd.ProcessState.ExitCode() != 2 {
+		t.Errorf("Expected exit code 2 for a missing ligand, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestLigandInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/core/chemcomp/HEM" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"chem_comp": {"name": "PROTOPORPHYRIN IX CONTAINING FE", "formula": "C34 H32 Fe N4 O4"},
+			"rcsb_chem_comp_descriptor": {"SMILES": "CC1=C(CCC(O)=O)...", "InChI": "InChI=1S/C34H34N4O4.Fe/...", "InChIKey": "KABFMIBPWCXCRK-RGGAHWMASA-L"}}`))
+	}))
+	defer server.Close()
+
+	testPDB := `ATOM      1  CA  HIS A  10      10.000  10.000  10.000  1.00 20.00           C
+HETATM    2 FE   HEM A 142      14.000  10.000  10.000  1.00 20.00          FE
+HETATM    3 FE   HEM B 143      40.000  40.000  40.000  1.00 20.00          FE
+HETATM    4  C1  XYZ A 301      30.000  30.000  30.000  1.00 20.00           C
+HETATM    5  O   HOH A 401      14.500  11.000  10.000  1.00 20.00           O
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "ligand-info", "--format", "json")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("ligand-info failed: %v", err)
+	}
+
+	var components []struct {
+		ID        string `json:"id"`
+		Instances int    `json:"instances"`
+		Name      string `json:"name"`
+		InChIKey  string `json:"inchikey"`
+	}
+	if err := json.Unmarshal(output, &components); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if len(components) != 2 {
+		t.Fatalf("Expected HEM and XYZ (waters left out), got %+v", components)
+	}
+	if components[0].ID != "HEM" || components[0].Instances != 2 || components[0].InChIKey != "KABFMIBPWCXCRK-RGGAHWMASA-L" {
+		t.Errorf("Unexpected HEM entry: %+v", components[0])
+	}
+	if components[1].ID != "XYZ" || components[1].Name != "" {
+		t.Errorf("Expected an empty entry for the unknown component: %+v", components[1])
+	}
+
+	// An unknown component is an error in --strict mode
+	cmd = exec.Command("../bin/pdbtk", "--strict", "ligand-info")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	cmd.Stdin = strings.NewReader(testPDB)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error for an unknown component with --strict")
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"one.pdb": batchTestPDB,
+		// Only has chain B, so --chains A,B raises a warning
+		"two.pdb": `ATOM      1  N   VAL B   1      30.154  26.967  33.862  1.00 11.18           N
+END`,
+		"broken.pdb": "not a PDB file\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	manifestFile := filepath.Join(dir, "manifest.jsonl")
+	cmd := exec.Command("../bin/pdbtk", "extract", "--chains", "A,B", "--outdir", filepath.Join(dir, "out"),
+		"--manifest", manifestFile, filepath.Join(dir, "one.pdb"), filepath.Join(dir, "two.pdb"), filepath.Join(dir, "broken.pdb"))
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Expected the run to fail for broken.pdb")
+	}
+
+	content, err := os.ReadFile(manifestFile)
+	if err != nil {
+		t.Fatalf("Expected a manifest: %v", err)
+	}
+	type entry struct {
+		Input    string   `json:"input"`
+		Output   string   `json:"output"`
+		Status   string   `json:"status"`
+		Chains   []string `json:"chains"`
+		Atoms    int      `json:"atoms"`
+		Warnings []string `json:"warnings"`
+		Seconds  *float64 `json:"seconds"`
+		Error    *struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	var entries []entry
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		var e entry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Invalid manifest line %q: %v", line, err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 manifest entries, got %d", len(entries))
+	}
+
+	one, two, broken := entries[0], entries[1], entries[2]
+	if one.Status != "ok" || one.Output != filepath.Join(dir, "out", "one.pdb") || strings.Join(one.Chains, ",") != "A,B" || one.Atoms != 4 {
+		t.Errorf("Unexpected entry for one.pdb: %+v", one)
+	}
+	if one.Seconds == nil || len(one.Warnings) != 0 {
+		t.Errorf("Expected a timing and no warnings for one.pdb: %+v", one)
+	}
+	if two.Status != "ok" || strings.Join(two.Chains, ",") != "B" || two.Atoms != 1 {
+		t.Errorf("Unexpected entry for two.pdb: %+v", two)
+	}
+	if len(two.Warnings) != 1 || !strings.Contains(two.Warnings[0], "A") {
+		t.Errorf("Expected the missing chain warning for two.pdb, got %v", two.Warnings)
+	}
+	if broken.Status != "error" || broken.Error == nil || broken.Error.Code != "parse_error" {
+		t.Errorf("Expected a parse error for broken.pdb: %+v", broken)
+	}
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMapSeq(t *testing.T) {
+	testPDB := `ATOM      1  CA  ALA A   3      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  GLY A   4      11.000  11.000  11.000  1.00 20.00           C
+ATOM      3  CA  SER A   5      12.000  12.000  12.000  1.00 20.00           C
+ATOM      4  CA  LEU A   8      13.000  13.000  13.000  1.00 20.00           C
+ATOM      5  CA  ALA A   9      14.000  14.000  14.000  1.00 20.00           C
+ATOM      6  CA  HIS A  10      15.000  15.000  15.000  1.00 20.00           C
+ATOM      7  CA  GLY B   1      16.000  16.000  16.000  1.00 20.00           C
+END`
+	fastaFile := "test_map_seq.fasta"
+	if err := os.WriteFile(fastaFile, []byte(">construct test\nMKAGSWL\nLE\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(fastaFile)
+
+	cmd := exec.Command("../bin/pdbtk", "map-seq", "--fasta", fastaFile, "--chain", "A")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("map-seq failed: %v", err)
+	}
+
+	expected := []string{
+		"seq_position\tseq_residue\tchain\tresnum\ticode\tresname\tstatus",
+		"1\tM\tA\t\t\t\tunmodelled",
+		"2\tK\tA\t\t\t\tunmodelled",
+		"3\tA\tA\t3\t\tALA\tmatch",
+		"4\tG\tA\t4\t\tGLY\tmatch",
+		"5\tS\tA\t5\t\tSER\tmatch",
+		"6\tW\tA\t\t\t\tunmodelled",
+		"7\tL\tA\t\t\t\tunmodelled",
+		"8\tL\tA\t8\t\tLEU\tmatch",
+		"9\tE\tA\t9\t\tALA\tmismatch",
+		"\t\tA\t10\t\tHIS\tnot_in_sequence",
+	}
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected mapping:\n%s", string(output))
+	}
+
+	// Without --chain the structure's two chains are ambiguous
+	cmd = exec.Command("../bin/pdbtk", "map-seq", "--fasta", fastaFile)
+	cmd.Stdin = strings.NewReader(testPDB)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error when --chain is needed")
+	}
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	receptor := `HEADER    RECEPTOR                                01-JAN-01   1ABC
+ATOM      1  CA  ALA A   1      11.000  10.000  10.000  1.00 10.00           C
+ATOM      2  CA  ALA B   1      12.000  10.000  10.000  1.00 10.00           C
+HETATM    3 ZN    ZN A 101      13.000  10.000  10.000  1.00 10.00          ZN
+CONECT    3    1
+END
+`
+	ligand := `ATOM      1  CA  GLY A   1      21.000  10.000  10.000  1.00 10.00           C
+HETATM    2  C1  LIG L   1      22.000  10.000  10.000  1.00 10.00           C
+HETATM    3  C2  LIG L   1      23.000  10.000  10.000  1.00 10.00           C
+CONECT    2    3
+END
+`
+	files := map[string]string{"test_merge_receptor.pdb": receptor, "test_merge_ligand.pdb": ligand}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+		defer os.Remove(name)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "merge", "test_merge_receptor.pdb", "test_merge_ligand.pdb")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("merge failed: %v\n%s", err, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "test_merge_ligand.pdb: chain A renamed to C") {
+		t.Errorf("Expected the renamed chain to be reported, got: %s", stderr.String())
+	}
+
+	var atoms, conect []string
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, "ATOM"), strings.HasPrefix(line, "HETATM"):
+			atoms = append(atoms, strings.TrimSpace(line[6:11])+" "+line[17:26])
+		case strings.HasPrefix(line, "CONECT"):
+			conect = append(conect, strings.Join(strings.Fields(line), " "))
+		}
+	}
+	expected := "1 ALA A   1|3 ALA B   1|5  ZN A 101|6 GLY C   1|8 LIG L   1|9 LIG L   1"
+	if strings.Join(atoms, "|") != expected {
+		t.Errorf("Expected atoms %q, got %q", expected, strings.Join(atoms, "|"))
+	}
+	if strings.Join(conect, "|") != "CONECT 5 1|CONECT 8 9" {
+		t.Errorf("Expected renumbered CONECT records, got %q", strings.Join(conect, "|"))
+	}
+	if !strings.HasPrefix(string(output), "HEADER    RECEPTOR") {
+		t.Error("Expected the HEADER of the first input")
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "merge", "--no-rename", "test_merge_receptor.pdb", "test_merge_ligand.pdb")
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 for a chain ID collision with --no-rename, got %d", code)
+	}
+}
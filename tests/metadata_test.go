@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Query     string `json:"query"`
+			Variables struct {
+				IDs []string `json:"ids"`
+			} `json:"variables"`
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" || json.NewDecoder(r.Body).Decode(&request) != nil {
+			http.NotFound(w, r)
+			return
+		}
+		entries := []interface{}{}
+		for _, id := range request.Variables.IDs {
+			if id == "4HHB" {
+				entries = append(entries, map[string]interface{}{
+					"rcsb_id":         "4HHB",
+					"struct":          map[string]string{"title": "THE CRYSTAL STRUCTURE OF HUMAN DEOXYHAEMOGLOBIN"},
+					"rcsb_entry_info": map[string]interface{}{"resolution_combined": []float64{1.74}},
+				})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"entries": entries}})
+	}))
+	defer server.Close()
+
+	cmd := exec.Command("../bin/pdbtk", "metadata", "4hhb")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("metadata failed: %v", err)
+	}
+	var entry struct {
+		RcsbID string `json:"rcsb_id"`
+		Struct struct {
+			Title string `json:"title"`
+		} `json:"struct"`
+		Info struct {
+			Resolution []float64 `json:"resolution_combined"`
+		} `json:"rcsb_entry_info"`
+	}
+	if err := json.Unmarshal(output, &entry); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if entry.RcsbID != "4HHB" || entry.Struct.Title == "" || len(entry.Info.Resolution) != 1 {
+		t.Errorf("Unexpected metadata: %+v", entry)
+	}
+
+	// Several entries are written as an array, and missing entries are an error in --strict mode
+	cmd = exec.Command("../bin/pdbtk", "metadata", "4HHB", "9ZZZ")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("metadata with a missing entry failed: %v", err)
+	}
+	var entries []json.RawMessage
+	if err := json.Unmarshal(output, &entries); err != nil || len(entries) != 1 {
+		t.Errorf("Expected an array with one entry, got %s", string(output))
+	}
+	cmd = exec.Command("../bin/pdbtk", "--strict", "metadata", "4HHB", "9ZZZ")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected a missing entry to fail in --strict mode")
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "metadata", "9ZZZ")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	if err := cmd.Run(); err == nil || cmd.ProcessState.ExitCode() != 2 {
+		t.Errorf("Expected exit code 2 for a missing entry, got %v", err)
+	}
+}
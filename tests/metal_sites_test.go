@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMetalSites(t *testing.T) {
+	var b strings.Builder
+	// A zinc ion with four cysteine SG atoms at tetrahedral positions 2.3 Å away
+	zn := [3]float64{10, 10, 10}
+	for i, d := range [][3]float64{{1, 1, 1}, {1, -1, -1}, {-1, 1, -1}, {-1, -1, 1}} {
+		s := 2.3 / 1.7320508
+		fmt.Fprintf(&b, "ATOM  %5d  SG  CYS A%4d    %8.3f%8.3f%8.3f  1.00 20.00           S\n",
+			i+1, 10+i, zn[0]+d[0]*s, zn[1]+d[1]*s, zn[2]+d[2]*s)
+	}
+	fmt.Fprintf(&b, "HETATM    5 ZN    ZN A 301    %8.3f%8.3f%8.3f  1.00 20.00          ZN\n", zn[0], zn[1], zn[2])
+	// A magnesium ion with six waters at octahedral positions 2.1 Å away
+	mg := [3]float64{30, 30, 30}
+	fmt.Fprintf(&b, "HETATM    6 MG    MG A 302    %8.3f%8.3f%8.3f  1.00 20.00          MG\n", mg[0], mg[1], mg[2])
+	for i, d := range [][3]float64{{1, 0, 0}, {-1, 0, 0}, {0, 1, 0}, {0, -1, 0}, {0, 0, 1}, {0, 0, -1}} {
+		fmt.Fprintf(&b, "HETATM%5d  O   HOH A%4d    %8.3f%8.3f%8.3f  1.00 20.00           O\n",
+			7+i, 401+i, mg[0]+d[0]*2.1, mg[1]+d[1]*2.1, mg[2]+d[2]*2.1)
+	}
+	b.WriteString("END\n")
+
+	cmd := exec.Command("../bin/pdbtk", "metal-sites", "--format", "json")
+	cmd.Stdin = strings.NewReader(b.String())
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("metal-sites failed: %v", err)
+	}
+	var sites []struct {
+		Residue         string `json:"residue"`
+		CoordinationNum int    `json:"coordination_number"`
+		Geometry        string `json:"geometry"`
+		Ligands         []struct {
+			Residue  string  `json:"residue"`
+			Distance float64 `json:"distance"`
+		} `json:"ligands"`
+	}
+	if err := json.Unmarshal(output, &sites); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if len(sites) != 2 {
+		t.Fatalf("Expected 2 metal sites, got %d", len(sites))
+	}
+	if sites[0].Residue != "A:301 ZN" || sites[0].CoordinationNum != 4 || sites[0].Geometry != "tetrahedral" {
+		t.Errorf("Unexpected zinc site: %+v", sites[0])
+	}
+	if sites[0].Ligands[0].Distance != 2.3 {
+		t.Errorf("Expected a 2.30 Å Zn-S distance, got %g", sites[0].Ligands[0].Distance)
+	}
+	if sites[1].CoordinationNum != 6 || sites[1].Geometry != "octahedral" {
+		t.Errorf("Unexpected magnesium site: %+v", sites[1])
+	}
+}
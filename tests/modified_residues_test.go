@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestModifiedResidues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/v1/core/chemcomp/XYZ" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"chem_comp": {"name": "TEST TYROSINE", "mon_nstd_parent_comp_id": ["TYR"], "one_letter_code": "Y"}}`))
+	}))
+	defer server.Close()
+
+	testPDB := `MODRES 1ABC ABA A    3  ALA  ALPHA-AMINOBUTYRIC ACID
+ATOM      1  CA  GLY A   1      10.000  10.000  10.000  1.00 20.00           C
+HETATM    2  SE  MSE A   2      11.000  10.000  10.000  1.00 20.00          SE
+HETATM    3  CA  ABA A   3      12.000  10.000  10.000  1.00 20.00           C
+HETATM    4  N   XYZ A   4      13.000  10.000  10.000  1.00 20.00           N
+HETATM    5  CA  XYZ A   4      14.000  10.000  10.000  1.00 20.00           C
+HETATM    6  C   XYZ A   4      15.000  10.000  10.000  1.00 20.00           C
+HETATM    7 FE   HEM A 101      30.000  30.000  30.000  1.00 20.00          FE
+END
+`
+	expected := []string{
+		"chain\tresnum\tresname\tparent\tone_letter_code\tsource\tstandardize",
+		"A\t2\tMSE\tMET\tM\tbuiltin\ttrue",
+		"A\t3\tABA\tALA\tA\tmodres\tfalse",
+	}
+	tests := []struct {
+		name string
+		args []string
+		last string
+	}{
+		{"Offline", nil, "A\t4\tXYZ\t\tX\tunknown\tfalse"},
+		{"CCD", []string{"--ccd"}, "A\t4\tXYZ\tTYR\tY\tccd\tfalse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("../bin/pdbtk", append([]string{"modified-residues"}, tt.args...)...)
+			cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+			cmd.Stdin = strings.NewReader(testPDB)
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("modified-residues failed: %v", err)
+			}
+			want := strings.Join(append(expected, tt.last), "\n") + "\n"
+			if string(output) != want {
+				t.Errorf("Expected:\n%s\ngot:\n%s", want, string(output))
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestMolecularWeight(t *testing.T) {
+	testPDB := `ATOM      1  N   GLY A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA  GLY A   1       1.500   0.000   0.000  1.00 20.00           C
+ATOM      3  C   GLY A   1       2.000   1.400   0.000  1.00 20.00           C
+ATOM      4  O   GLY A   1       1.200   2.300   0.000  1.00 20.00           O
+HETATM    5 FE   HEM A 101      10.000   0.000   0.000  1.00 20.00          FE
+HETATM    6  O   HOH A 201      20.000   0.000   0.000  1.00 20.00           O
+ATOM      7  CA  GLY B   1      30.000   0.000   0.000  1.00 20.00           C
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "molecular-weight")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("molecular-weight failed: %v", err)
+	}
+	expected := `chain	part	atoms	mass	formula
+A	polymer	4	54.03	C2NO
+A	ligands	1	55.85	Fe
+A	waters	1	16.00	O
+A	all	6	125.87	C2FeNO2
+B	polymer	1	12.01	C
+B	all	1	12.01	C
+all	polymer	5	66.04	C3NO
+all	ligands	1	55.85	Fe
+all	waters	1	16.00	O
+all	all	7	137.88	C3FeNO2
+`
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, string(output))
+	}
+}
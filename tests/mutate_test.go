@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// mutateTestPDB is an ideal alpha helix of eight alanines
+const mutateTestPDB = `ATOM      1  N   ALA A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA  ALA A   1       1.458   0.000   0.000  1.00 20.00           C
+ATOM      3  C   ALA A   1       2.009   0.711  -1.231  1.00 20.00           C
+ATOM      4  O   ALA A   1       2.910   1.543  -1.121  1.00 20.00           O
+ATOM      5  CB  ALA A   1       1.996  -1.421   0.049  1.00 20.00           C
+ATOM      6  N   ALA A   2       1.463   0.376  -2.396  1.00 20.00           N
+ATOM      7  CA  ALA A   2       1.899   0.981  -3.649  1.00 20.00           C
+ATOM      8  C   ALA A   2       1.768   2.500  -3.602  1.00 20.00           C
+ATOM      9  O   ALA A   2       2.693   3.219  -3.981  1.00 20.00           O
+ATOM     10  CB  ALA A   2       1.094   0.438  -4.818  1.00 20.00           C
+ATOM     11  N   ALA A   3       0.618   2.976  -3.137  1.00 20.00           N
+ATOM     12  CA  ALA A   3       0.364   4.408  -3.041  1.00 20.00           C
+ATOM     13  C   ALA A   3       1.421   5.099  -2.187  1.00 20.00           C
+ATOM     14  O   ALA A   3       1.958   6.137  -2.575  1.00 20.00           O
+ATOM     15  CB  ALA A   3      -1.014   4.673  -2.456  1.00 20.00           C
+ATOM     16  N   ALA A   4       1.711   4.517  -1.028  1.00 20.00           N
+ATOM     17  CA  ALA A   4       2.704   5.075  -0.117  1.00 20.00           C
+ATOM     18  C   ALA A   4       4.057   5.228  -0.803  1.00 20.00           C
+ATOM     19  O   ALA A   4       4.696   6.275  -0.699  1.00 20.00           O
+ATOM     20  CB  ALA A   4       2.856   4.200   1.116  1.00 20.00           C
+ATOM     21  N   ALA A   5       4.484   4.179  -1.499  1.00 20.00           N
+ATOM     22  CA  ALA A   5       5.761   4.194  -2.202  1.00 20.00           C
+ATOM     23  C   ALA A   5       5.830   5.349  -3.196  1.00 20.00           C
+ATOM     24  O   ALA A   5       6.823   6.075  -3.243  1.00 20.00           O
+ATOM     25  CB  ALA A   5       5.988   2.882  -2.934  1.00 20.00           C
+ATOM     26  N   ALA A   6       4.771   5.510  -3.983  1.00 20.00           N
+ATOM     27  CA  ALA A   6       4.709   6.576  -4.976  1.00 20.00           C
+ATOM     28  C   ALA A   6       4.899   7.944  -4.329  1.00 20.00           C
+ATOM     29  O   ALA A   6       5.676   8.764  -4.818  1.00 20.00           O
+ATOM     30  CB  ALA A   6       3.382   6.546  -5.716  1.00 20.00           C
+ATOM     31  N   ALA A   7       4.187   8.178  -3.231  1.00 20.00           N
+ATOM     32  CA  ALA A   7       4.276   9.446  -2.516  1.00 20.00           C
+ATOM     33  C   ALA A   7       5.712   9.742  -2.095  1.00 20.00           C
+ATOM     34  O   ALA A   7       6.204  10.853  -2.290  1.00 20.00           O
+ATOM     35  CB  ALA A   7       3.379   9.435  -1.289  1.00 20.00           C
+ATOM     36  N   ALA A   8       6.372   8.742  -1.519  1.00 20.00           N
+ATOM     37  CA  ALA A   8       7.751   8.893  -1.070  1.00 20.00           C
+ATOM     38  C   ALA A   8       8.660   9.325  -2.215  1.00 20.00           C
+ATOM     39  O   ALA A   8       9.462  10.247  -2.063  1.00 20.00           O
+ATOM     40  CB  ALA A   8       8.268   7.595  -0.473  1.00 20.00           C
+END`
+
+// residueAtoms returns the atom names and coordinates of a residue of a PDB file
+func residueAtoms(pdb string, resSeq int) ([]string, map[string][3]float64, string) {
+	var names []string
+	coords := make(map[string][3]float64)
+	resName := ""
+	for _, line := range strings.Split(pdb, "\n") {
+		if !strings.HasPrefix(line, "ATOM") {
+			continue
+		}
+		if n, _ := strconv.Atoi(strings.TrimSpace(line[22:26])); n != resSeq {
+			continue
+		}
+		name := strings.TrimSpace(line[12:16])
+		var p [3]float64
+		for i := range p {
+			p[i], _ = strconv.ParseFloat(strings.TrimSpace(line[30+8*i:38+8*i]), 64)
+		}
+		names = append(names, name)
+		coords[name] = p
+		resName = line[17:20]
+	}
+	return names, coords, resName
+}
+
+func TestMutate(t *testing.T) {
+	cmd := exec.Command("../bin/pdbtk", "mutate", "A:4", "TYR")
+	cmd.Stdin = strings.NewReader(mutateTestPDB)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("mutate failed: %v\n%s", err, stderr.String())
+	}
+	if !strings.Contains(string(output), "REMARK   1 COMMAND: pdbtk mutate A:4 TYR\n") {
+		t.Errorf("Expected the mutation in the provenance record:\n%s", output)
+	}
+	names, coords, resName := residueAtoms(string(output), 4)
+	expected := "N CA C O CB CG CD1 CD2 CE1 CE2 CZ OH"
+	if strings.Join(names, " ") != expected || resName != "TYR" {
+		t.Errorf("Expected TYR atoms %s, got %s %v", expected, resName, names)
+	}
+	_, original, _ := residueAtoms(mutateTestPDB, 4)
+	if coords["CB"] != original["CB"] {
+		t.Errorf("Expected CB to be kept at %v, got %v", original["CB"], coords["CB"])
+	}
+	if d := math.Sqrt(math.Pow(coords["CB"][0]-coords["CG"][0], 2) + math.Pow(coords["CB"][1]-coords["CG"][1], 2) +
+		math.Pow(coords["CB"][2]-coords["CG"][2], 2)); math.Abs(d-1.51) > 0.01 {
+		t.Errorf("Expected a CB-CG bond of 1.51 Å, got %.3f", d)
+	}
+	if !strings.Contains(stderr.String(), "Mutated A:4 ALA to TYR (rotamer m-85)") || strings.Contains(stderr.String(), "clashes") {
+		t.Errorf("Unexpected report: %s", stderr.String())
+	}
+
+	// chi1 near +60 clashes with the preceding turn of the helix
+	cmd = exec.Command("../bin/pdbtk", "mutate", "A:4", "F", "--chi", "62,90")
+	cmd.Stdin = strings.NewReader(mutateTestPDB)
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("mutate --chi failed: %v", err)
+	}
+	if !strings.Contains(stderr.String(), "PHE clashes: CG with A:1 ALA O") {
+		t.Errorf("Expected clashes to be reported, got: %s", stderr.String())
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "mutate", "A:4", "GLY")
+	cmd.Stdin = strings.NewReader(mutateTestPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("mutate to GLY failed: %v", err)
+	}
+	if names, _, _ := residueAtoms(string(output), 4); strings.Join(names, " ") != "N CA C O" {
+		t.Errorf("Expected the backbone only for GLY, got %v", names)
+	}
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"A:99", "TYR"}, 2},
+		{[]string{"A:4", "XYZ"}, 1},
+		{[]string{"4", "TYR"}, 1},
+		{[]string{"A:4", "LEU", "--chi", "60"}, 1},
+	} {
+		cmd := exec.Command("../bin/pdbtk", append([]string{"mutate"}, tt.args...)...)
+		cmd.Stdin = strings.NewReader(mutateTestPDB)
+		if code := exitCodeOf(t, cmd); code != tt.code {
+			t.Errorf("mutate %v: expected exit code %d, got %d", tt.args, tt.code, code)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package tests
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory S3 endpoint that stores objects by path and checks that requests are signed
+func fakeS3(t *testing.T) (*httptest.Server, map[string]string) {
+	var mu sync.Mutex
+	objects := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=TESTKEY/") {
+			t.Errorf("%s %s: request is not signed: %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodGet, http.MethodHead:
+			content, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, content)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, objects
+}
+
+func TestObjectStoreS3(t *testing.T) {
+	server, objects := fakeS3(t)
+	objects["/bucket/inputs/test_s3.pdb"] = batchTestPDB
+
+	env := append(os.Environ(), "AWS_ENDPOINT_URL="+server.URL, "AWS_ACCESS_KEY_ID=TESTKEY",
+		"AWS_SECRET_ACCESS_KEY=TESTSECRET", "AWS_REGION=eu-west-1")
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command("../bin/pdbtk", args...)
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		return string(output), err
+	}
+
+	t.Run("read input and write output", func(t *testing.T) {
+		output, err := run("extract", "--chains", "A", "--output", "s3://bucket/outputs/chain_a.pdb", "s3://bucket/inputs/test_s3.pdb")
+		if err != nil {
+			t.Fatalf("extract failed: %v\n%s", err, output)
+		}
+		result, ok := objects["/bucket/outputs/chain_a.pdb"]
+		if !ok {
+			t.Fatalf("output object not written, objects: %v", objects)
+		}
+		if !strings.Contains(result, " A ") || strings.Contains(result, "ATOM      3") {
+			t.Errorf("unexpected output:\n%s", result)
+		}
+	})
+
+	t.Run("read input to stdout", func(t *testing.T) {
+		output, err := run("extract-seq", "s3://bucket/inputs/test_s3.pdb")
+		if err != nil {
+			t.Fatalf("extract-seq failed: %v\n%s", err, output)
+		}
+		if !strings.HasPrefix(output, ">") {
+			t.Errorf("expected FASTA output, got:\n%s", output)
+		}
+	})
+
+	t.Run("batch output directory", func(t *testing.T) {
+		output, err := run("sort", "--outdir", "s3://bucket/sorted/", "--name-template", "{stem}_sorted.pdb", "s3://bucket/inputs/test_s3.pdb")
+		if err != nil {
+			t.Fatalf("sort failed: %v\n%s", err, output)
+		}
+		if _, ok := objects["/bucket/sorted/test_s3_sorted.pdb"]; !ok {
+			t.Errorf("batch output object not written, objects: %v", objects)
+		}
+	})
+
+	t.Run("missing object", func(t *testing.T) {
+		cmd := exec.Command("../bin/pdbtk", "extract", "s3://bucket/inputs/missing.pdb")
+		cmd.Env = env
+		if code := exitCodeOf(t, cmd); code == 0 {
+			t.Errorf("expected failure for a missing object")
+		}
+	})
+}
+
+// credentialEnv returns an environment without the cloud credentials of the test machine, with a
+// temporary home directory and the given variables
+func credentialEnv(home string, vars ...string) []string {
+	var env []string
+	for _, v := range os.Environ() {
+		if !strings.HasPrefix(v, "AWS_") && !strings.HasPrefix(v, "GOOGLE_") && !strings.HasPrefix(v, "CLOUDSDK_") && !strings.HasPrefix(v, "HOME=") {
+			env = append(env, v)
+		}
+	}
+	return append(append(env, "HOME="+home, "AWS_REGION=eu-west-1"), vars...)
+}
+
+func TestObjectStoreAWSAmbientCredentials(t *testing.T) {
+	server, objects := fakeS3(t)
+	objects["/bucket/inputs/test_s3.pdb"] = batchTestPDB
+	credentials := `{"AccessKeyId": "TESTKEY", "SecretAccessKey": "TESTSECRET", "Token": "TESTSESSION"}`
+
+	run := func(t *testing.T, env []string) {
+		t.Helper()
+		cmd := exec.Command("../bin/pdbtk", "extract-seq", "s3://bucket/inputs/test_s3.pdb")
+		cmd.Env = append(env, "AWS_ENDPOINT_URL="+server.URL)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("extract-seq failed: %v\n%s", err, output)
+		}
+		if !strings.HasPrefix(string(output), ">") {
+			t.Errorf("expected FASTA output, got:\n%s", output)
+		}
+	}
+
+	t.Run("EC2 instance profile", func(t *testing.T) {
+		imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/latest/api/token" {
+				if r.Method != http.MethodPut || r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds") == "" {
+					http.Error(w, "bad token request", http.StatusBadRequest)
+					return
+				}
+				io.WriteString(w, "IMDSTOKEN")
+				return
+			}
+			if r.Header.Get("X-aws-ec2-metadata-token") != "IMDSTOKEN" {
+				http.Error(w, "no token", http.StatusUnauthorized)
+				return
+			}
+			switch r.URL.Path {
+			case "/latest/meta-data/iam/security-credentials/":
+				io.WriteString(w, "test-role")
+			case "/latest/meta-data/iam/security-credentials/test-role":
+				io.WriteString(w, credentials)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer imds.Close()
+		run(t, credentialEnv(t.TempDir(), "AWS_EC2_METADATA_SERVICE_ENDPOINT="+imds.URL))
+	})
+
+	t.Run("container credentials full URI", func(t *testing.T) {
+		endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "TESTAUTH" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, credentials)
+		}))
+		defer endpoint.Close()
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		if err := os.WriteFile(tokenFile, []byte("TESTAUTH\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run(t, credentialEnv(t.TempDir(), "AWS_EC2_METADATA_DISABLED=true",
+			"AWS_CONTAINER_CREDENTIALS_FULL_URI="+endpoint.URL+"/creds", "AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE="+tokenFile))
+	})
+
+	t.Run("credential_process", func(t *testing.T) {
+		dir := t.TempDir()
+		script := filepath.Join(dir, "credentials.sh")
+		if err := os.WriteFile(script, []byte("#!/bin/sh\necho '{\"Version\": 1, \"AccessKeyId\": \"TESTKEY\", \"SecretAccessKey\": \"TESTSECRET\"}'\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		config := filepath.Join(dir, "config")
+		if err := os.WriteFile(config, []byte("[profile ci]\ncredential_process = \""+script+"\" --role ci\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run(t, credentialEnv(dir, "AWS_EC2_METADATA_DISABLED=true", "AWS_CONFIG_FILE="+config, "AWS_PROFILE=ci"))
+	})
+
+	t.Run("SSO login", func(t *testing.T) {
+		portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/federation/credentials" || r.URL.Query().Get("account_id") != "123456789012" ||
+				r.URL.Query().Get("role_name") != "Reader" || r.Header.Get("x-amz-sso_bearer_token") != "SSOTOKEN" {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, `{"roleCredentials": {"accessKeyId": "TESTKEY", "secretAccessKey": "TESTSECRET", "sessionToken": "TESTSESSION"}}`)
+		}))
+		defer portal.Close()
+
+		home := t.TempDir()
+		sum := sha1.Sum([]byte("lab"))
+		cache := filepath.Join(home, ".aws", "sso", "cache")
+		if err := os.MkdirAll(cache, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		token := fmt.Sprintf(`{"accessToken": "SSOTOKEN", "expiresAt": %q}`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+		if err := os.WriteFile(filepath.Join(cache, hex.EncodeToString(sum[:])+".json"), []byte(token), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		config := "[profile reader]\nsso_session = lab\nsso_account_id = 123456789012\nsso_role_name = Reader\n\n[sso-session lab]\nsso_region = eu-west-1\nsso_start_url = https://lab.awsapps.com/start\n"
+		if err := os.WriteFile(filepath.Join(home, ".aws", "config"), []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		run(t, credentialEnv(home, "AWS_EC2_METADATA_DISABLED=true", "AWS_PROFILE=reader", "AWS_ENDPOINT_URL_SSO="+portal.URL))
+	})
+}
+
+func TestObjectStoreGCSCredentialFiles(t *testing.T) {
+	gcs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer TESTTOKEN" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/storage/v1/b/bucket/o/inputs/test_gcs.pdb" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, batchTestPDB)
+	}))
+	defer gcs.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("grant_type") {
+		case "refresh_token":
+			if r.Form.Get("refresh_token") != "TESTREFRESH" || r.Form.Get("client_id") != "TESTCLIENT" {
+				http.Error(w, "bad refresh token", http.StatusBadRequest)
+				return
+			}
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			parts := strings.Split(r.Form.Get("assertion"), ".")
+			if len(parts) != 3 {
+				http.Error(w, "bad assertion", http.StatusBadRequest)
+				return
+			}
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+				http.Error(w, "bad signature", http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "bad grant type", http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"access_token": "TESTTOKEN", "expires_in": 3600, "token_type": "Bearer"}`)
+	}))
+	defer token.Close()
+
+	run := func(t *testing.T, env []string) {
+		t.Helper()
+		cmd := exec.Command("../bin/pdbtk", "extract-seq", "gs://bucket/inputs/test_gcs.pdb")
+		cmd.Env = append(env, "STORAGE_EMULATOR_HOST="+gcs.URL)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("extract-seq failed: %v\n%s", err, output)
+		}
+		if !strings.HasPrefix(string(output), ">") {
+			t.Errorf("expected FASTA output, got:\n%s", output)
+		}
+	}
+	writeJSON := func(t *testing.T, filename string, v interface{}) {
+		t.Helper()
+		content, _ := json.Marshal(v)
+		if err := os.WriteFile(filename, content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("service account key", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "key.json")
+		writeJSON(t, filename, map[string]string{
+			"type":         "service_account",
+			"client_email": "pipeline@project.iam.gserviceaccount.com",
+			"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+			"token_uri":    token.URL + "/token",
+		})
+		run(t, credentialEnv(t.TempDir(), "GOOGLE_APPLICATION_CREDENTIALS="+filename))
+	})
+
+	t.Run("gcloud application default credentials", func(t *testing.T) {
+		home := t.TempDir()
+		dir := filepath.Join(home, ".config", "gcloud")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeJSON(t, filepath.Join(dir, "application_default_credentials.json"), map[string]string{
+			"type":          "authorized_user",
+			"client_id":     "TESTCLIENT",
+			"client_secret": "TESTSECRET",
+			"refresh_token": "TESTREFRESH",
+			"token_uri":     token.URL + "/token",
+		})
+		run(t, credentialEnv(home))
+	})
+
+	t.Run("unsupported credentials type", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "external.json")
+		writeJSON(t, filename, map[string]string{"type": "external_account"})
+		cmd := exec.Command("../bin/pdbtk", "extract-seq", "gs://bucket/inputs/test_gcs.pdb")
+		cmd.Env = append(credentialEnv(t.TempDir(), "GOOGLE_APPLICATION_CREDENTIALS="+filename), "STORAGE_EMULATOR_HOST="+gcs.URL)
+		output, _ := cmd.CombinedOutput()
+		if !strings.Contains(string(output), "unsupported Google Cloud credentials type") {
+			t.Errorf("expected an unsupported credentials error, got:\n%s", output)
+		}
+	})
+}
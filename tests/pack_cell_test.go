@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackCell(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "crystal.pdb")
+	content := strings.Replace(batchTestPDB, "\n", "\nCRYST1   40.000   60.000   80.000  90.00  90.00  90.00 P 21 21 21    4\n", 1)
+	if err := os.WriteFile(input, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	output, err := exec.Command("../bin/pdbtk", "pack-cell", input).Output()
+	if err != nil {
+		t.Fatalf("pack-cell failed: %v", err)
+	}
+	var atoms, chains []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			atoms = append(atoms, line)
+			if chain := line[21:22]; !strings.Contains(strings.Join(chains, ""), chain) {
+				chains = append(chains, chain)
+			}
+		}
+	}
+	if len(atoms) != 16 || strings.Join(chains, "") != "ABCDEFGH" {
+		t.Fatalf("Expected 4 copies of chains A and B with distinct chain IDs, got %d atoms in chains %v:\n%s", len(atoms), chains, string(output))
+	}
+	// The first copy is the input; the second (-x+1/2,-y,z+1/2) is moved into the cell by +a and +b
+	if !strings.HasPrefix(atoms[0][30:], "  20.154  16.967  23.862") || strings.TrimSpace(atoms[0][72:76]) != "1" {
+		t.Errorf("Unexpected first atom: %s", atoms[0])
+	}
+	if atoms[4][21] != 'C' || !strings.HasPrefix(atoms[4][30:], "  39.846  43.033  63.862") || strings.TrimSpace(atoms[4][72:76]) != "2" {
+		t.Errorf("Unexpected first atom of the second copy: %s", atoms[4])
+	}
+	if !strings.Contains(string(output), "CRYST1   40.000   60.000   80.000  90.00  90.00  90.00 P 1           4\n") {
+		t.Errorf("Expected the packed cell in P 1:\n%s", string(output))
+	}
+
+	output, err = exec.Command("../bin/pdbtk", "pack-cell", "--supercell", "2,1,3", input).Output()
+	if err != nil {
+		t.Fatalf("pack-cell --supercell failed: %v", err)
+	}
+	if n := strings.Count(string(output), "\nATOM"); n != 96 {
+		t.Errorf("Expected 24 copies (96 atoms) in a 2x1x3 supercell, got %d atoms", n)
+	}
+	if !strings.Contains(string(output), "CRYST1   80.000   60.000  240.000  90.00  90.00  90.00 P 1          24\n") {
+		t.Errorf("Expected the supercell in P 1:\n%s", string(output))
+	}
+
+	// 48 copies of two chains need more chain IDs than there are
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "--strict", "pack-cell", "--supercell", "3,2,2", input)); code == 0 {
+		t.Error("Expected --strict to fail when the chain IDs run out")
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "pack-cell", "--supercell", "0,1,1", input)); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid --supercell, got %d", code)
+	}
+	plain := filepath.Join(dir, "plain.pdb")
+	os.WriteFile(plain, []byte(batchTestPDB), 0644)
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "pack-cell", plain)); code != 2 {
+		t.Errorf("Expected exit code 2 without a CRYST1 record, got %d", code)
+	}
+}
+
+func TestPackCellBatch(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Replace(batchTestPDB, "\n", "\nCRYST1   40.000   60.000   80.000  90.00  90.00  90.00 P 21 21 21    4\n", 1)
+	for _, name := range []string{"a.pdb", "b.pdb"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write input: %v", err)
+		}
+	}
+
+	outdir := filepath.Join(dir, "out")
+	cmd := exec.Command("../bin/pdbtk", "pack-cell", "--outdir", outdir, filepath.Join(dir, "*.pdb"))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("pack-cell --outdir failed: %v\n%s", err, output)
+	}
+	entries, err := os.ReadDir(outdir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, " ") != "a_cell.pdb b_cell.pdb" {
+		t.Errorf("Expected a_cell.pdb and b_cell.pdb, got %v", names)
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const bundleMapping = `    New chain ID            Original chain ID
+
+    1abc-pdb-bundle1.pdb:
+           A                   AA
+           B                    B
+
+    1abc-pdb-bundle2.pdb:
+           A                   BA
+`
+
+const bundle1PDB = `HEADER    RIBOSOME                                01-JAN-01   1ABC
+ATOM      1  N   ALA A   1      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CA  ALA A   1      11.458  10.000  10.000  1.00 20.00           C
+TER       3      ALA A   1
+ATOM      4  N   GLY B   1      20.000  10.000  10.000  1.00 20.00           N
+ATOM      5  CA  GLY B   1      21.458  10.000  10.000  1.00 20.00           C
+END
+`
+
+const bundle2PDB = `ATOM      1  N   SER A   1      30.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CA  SER A   1      31.458  10.000  10.000  1.00 20.00           C
+END
+`
+
+// writeBundle writes a gzipped tar archive of the given files
+func writeBundle(t *testing.T, filename string, files [][2]string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for _, file := range files {
+		archive.WriteHeader(&tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1]))})
+		archive.Write([]byte(file[1]))
+	}
+	archive.Close()
+	gz.Close()
+	if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+}
+
+func TestPDBBundle(t *testing.T) {
+	writeBundle(t, "1abc-pdb-bundle.tar.gz", [][2]string{
+		{"1abc-pdb-bundle2.pdb", bundle2PDB},
+		{"1abc-pdb-bundle1.pdb", bundle1PDB},
+		{"1abc-chain-id-mapping.txt", bundleMapping},
+	})
+	defer os.Remove("1abc-pdb-bundle.tar.gz")
+
+	// Chains get unique chain IDs (single-character original IDs are kept) and keep their original ID as segment ID
+	output, err := exec.Command("../bin/pdbtk", "sort", "1abc-pdb-bundle.tar.gz").Output()
+	if err != nil {
+		t.Fatalf("sort failed: %v", err)
+	}
+	chains := make(map[string]string)
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			chains[line[21:22]] = line[17:20] + " " + strings.TrimSpace(line[72:76])
+		}
+	}
+	expected := map[string]string{"A": "ALA AA", "B": "GLY B", "C": "SER BA"}
+	for chain, residue := range expected {
+		if chains[chain] != residue {
+			t.Errorf("Expected chain %s to be %s, got %q:\n%s", chain, residue, chains[chain], string(output))
+		}
+	}
+
+	output, err = exec.Command("../bin/pdbtk", "extract", "--chains", "C", "1abc-pdb-bundle.tar.gz").Output()
+	if err != nil || !strings.Contains(string(output), "SER C   1") {
+		t.Errorf("extract of chain C failed: %v\n%s", err, string(output))
+	}
+
+	output, err = exec.Command("../bin/pdbtk", "extract-seq", "1abc-pdb-bundle.tar.gz").Output()
+	if err != nil {
+		t.Fatalf("extract-seq failed: %v", err)
+	}
+	for _, chain := range []string{"_A\nA", "_B\nG", "_C\nS"} {
+		if !strings.Contains(string(output), chain) {
+			t.Errorf("Expected chain %q in sequences:\n%s", chain, string(output))
+		}
+	}
+
+	// Batch outputs of bundles are named as PDB files
+	dir := t.TempDir()
+	if output, err := exec.Command("../bin/pdbtk", "sort", "--outdir", dir, "1abc-pdb-bundle.tar.gz").CombinedOutput(); err != nil {
+		t.Fatalf("sort failed: %v\n%s", err, output)
+	}
+	if _, err := os.Stat(dir + "/1abc-pdb-bundle.pdb"); err != nil {
+		t.Errorf("Expected output 1abc-pdb-bundle.pdb: %v", err)
+	}
+}
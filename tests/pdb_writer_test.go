@@ -393,3 +393,42 @@ END`
 		t.Errorf("Expected 1 atom without ALTLOC, got %d", noAltlocCount)
 	}
 }
+
+func TestAltLocFilteringKeepsAtomOrder(t *testing.T) {
+	testPDB := `ATOM      1  N   SER A   1      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CA ASER A   1      11.458  10.000  10.000  0.60 20.00           C
+ATOM      3  CA BSER A   1      11.460  10.010  10.000  0.40 20.00           C
+ATOM      4  C   SER A   1      12.009  11.420  10.000  1.00 20.00           C
+ATOM      5  O   SER A   1      11.300  12.400  10.000  1.00 20.00           O
+ATOM      6  CB ASER A   1      11.900   9.200  11.200  0.60 20.00           C
+ATOM      7  CB BSER A   1      11.950   9.250  11.150  0.40 20.00           C
+ATOM      8  OG ASER A   1      13.300   9.100  11.300  0.60 20.00           O
+ATOM      9  OG BSER A   1      11.500   7.900  11.000  0.40 20.00           O
+END`
+
+	for _, flag := range []string{"A", "first"} {
+		var first string
+		for run := 0; run < 5; run++ {
+			cmd := exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--altloc", flag)
+			cmd.Stdin = strings.NewReader(testPDB)
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("extract --altloc %s failed: %v", flag, err)
+			}
+			if run == 0 {
+				first = string(output)
+				var names []string
+				for _, line := range strings.Split(first, "\n") {
+					if strings.HasPrefix(line, "ATOM") {
+						names = append(names, strings.TrimSpace(line[12:16]))
+					}
+				}
+				if got := strings.Join(names, " "); got != "N CA C O CB OG" {
+					t.Errorf("--altloc %s: atoms written as %s, want the input order", flag, got)
+				}
+			} else if string(output) != first {
+				t.Fatalf("--altloc %s: output differs between runs:\n%s\n---\n%s", flag, first, string(output))
+			}
+		}
+	}
+}
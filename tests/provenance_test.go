@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProvenanceRemarks(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.pdb")
+	if err := os.WriteFile(input, []byte(batchTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	extracted := filepath.Join(dir, "extracted.pdb")
+	if output, err := exec.Command("../bin/pdbtk", "extract", "--chain", "A", "--output", extracted, input).CombinedOutput(); err != nil {
+		t.Fatalf("extract failed: %v\n%s", err, string(output))
+	}
+	output, err := exec.Command("../bin/pdbtk", "renumber-residues", "--start", "10", extracted).Output()
+	if err != nil {
+		t.Fatalf("renumber-residues failed: %v", err)
+	}
+
+	// One block per operation, oldest first
+	var remarks []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "REMARK   1") {
+			remarks = append(remarks, line)
+		}
+	}
+	expected := []string{
+		"REMARK   1 GENERATED BY PDBTK",
+		"REMARK   1 COMMAND: pdbtk extract --chain A --output " + extracted + " " + input,
+		"REMARK   1",
+		"REMARK   1 GENERATED BY PDBTK",
+		"REMARK   1 COMMAND: pdbtk renumber-residues --start 10 " + extracted,
+		"REMARK   1",
+	}
+	if len(remarks) != len(expected) {
+		t.Fatalf("Expected %d REMARK 1 records, got:\n%s", len(expected), strings.Join(remarks, "\n"))
+	}
+	for i := range expected {
+		if !strings.HasPrefix(remarks[i], expected[i]) {
+			t.Errorf("Expected REMARK %q, got %q", expected[i], remarks[i])
+		}
+	}
+	if !strings.HasPrefix(remarks[0], "REMARK   1 GENERATED BY PDBTK 0.") {
+		t.Errorf("Expected the pdbtk version in the REMARK, got %q", remarks[0])
+	}
+
+	output, err = exec.Command("../bin/pdbtk", "collapse-altloc", "--no-provenance", extracted).Output()
+	if err != nil {
+		t.Fatalf("collapse-altloc --no-provenance failed: %v", err)
+	}
+	if strings.Contains(string(output), "PDBTK") {
+		t.Errorf("Expected no pdbtk REMARKs with --no-provenance, got:\n%s", string(output))
+	}
+}
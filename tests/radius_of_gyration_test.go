@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRadiusOfGyration(t *testing.T) {
+	testPDB := `MODEL        1
+ATOM      1  CA  ALA A   1      -1.000   0.000   0.000  1.00 20.00           C
+ATOM      2  CA  ALA A   2       1.000   0.000   0.000  1.00 20.00           C
+HETATM    3  O   HOH A 101      50.000   0.000   0.000  1.00 20.00           O
+ENDMDL
+MODEL        2
+ATOM      1  CA  ALA A   1      -2.000   0.000   0.000  1.00 20.00           C
+ATOM      2  CA  ALA A   2       2.000   0.000   0.000  1.00 20.00           C
+HETATM    3  O   HOH A 101      50.000   0.000   0.000  1.00 20.00           O
+ENDMDL
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "radius-of-gyration")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("radius-of-gyration failed: %v", err)
+	}
+	expected := "model\tatoms\trg\n1\t2\t1.000\n2\t2\t2.000\n"
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, string(output))
+	}
+
+	// A nitrogen and a carbon 2 Å apart: Rg is 1 Å unweighted, 2*sqrt(mN*mC)/(mN+mC) mass-weighted
+	massPDB := `ATOM      1  N   ALA A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA  ALA A   1       2.000   0.000   0.000  1.00 20.00           C
+END
+`
+	cmd = exec.Command("../bin/pdbtk", "radius-of-gyration", "--mass-weighted", "--format", "json")
+	cmd.Stdin = strings.NewReader(massPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("radius-of-gyration --mass-weighted failed: %v", err)
+	}
+	var results []struct {
+		Model int     `json:"model"`
+		Atoms int     `json:"atoms"`
+		Rg    float64 `json:"rg"`
+	}
+	if err := json.Unmarshal(output, &results); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if len(results) != 1 || results[0].Atoms != 2 || results[0].Rg != 0.997 {
+		t.Errorf("Unexpected result: %+v", results)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "radius-of-gyration", "--chains", "B")
+	cmd.Stdin = strings.NewReader(massPDB)
+	if code := exitCodeOf(t, cmd); code != 2 {
+		t.Errorf("Expected exit code 2 for an empty selection, got %d", code)
+	}
+}
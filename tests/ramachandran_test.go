@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"encoding/json"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ramachandranTestPDB is the backbone of an ideal alpha helix (phi -57, psi -47) with a chain break
+// before residue 7
+const ramachandranTestPDB = `ATOM      1  N   ALA A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA  ALA A   1       1.458   0.000   0.000  1.00 20.00           C
+ATOM      3  C   ALA A   1       2.009   0.711  -1.231  1.00 20.00           C
+ATOM      4  N   GLY A   2       1.463   0.376  -2.396  1.00 20.00           N
+ATOM      5  CA  GLY A   2       1.899   0.981  -3.649  1.00 20.00           C
+ATOM      6  C   GLY A   2       1.768   2.500  -3.602  1.00 20.00           C
+ATOM      7  N   ALA A   3       0.618   2.976  -3.137  1.00 20.00           N
+ATOM      8  CA  ALA A   3       0.364   4.408  -3.041  1.00 20.00           C
+ATOM      9  C   ALA A   3       1.421   5.099  -2.187  1.00 20.00           C
+ATOM     10  N   PRO A   4       1.711   4.517  -1.028  1.00 20.00           N
+ATOM     11  CA  PRO A   4       2.704   5.075  -0.117  1.00 20.00           C
+ATOM     12  C   PRO A   4       4.057   5.228  -0.803  1.00 20.00           C
+ATOM     13  N   ALA A   5       4.484   4.179  -1.499  1.00 20.00           N
+ATOM     14  CA  ALA A   5       5.761   4.194  -2.202  1.00 20.00           C
+ATOM     15  C   ALA A   5       5.830   5.349  -3.196  1.00 20.00           C
+ATOM     16  N   ALA A   6       4.771   5.510  -3.983  1.00 20.00           N
+ATOM     17  CA  ALA A   6       4.709   6.576  -4.976  1.00 20.00           C
+ATOM     18  C   ALA A   6       4.899   7.944  -4.329  1.00 20.00           C
+ATOM     19  N   ALA A   7      30.000  30.000  30.000  1.00 20.00           N
+ATOM     20  CA  ALA A   7      31.458  30.000  30.000  1.00 20.00           C
+ATOM     21  C   ALA A   7      32.000  31.400  30.000  1.00 20.00           C
+END
+`
+
+func TestRamachandran(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "helix.pdb")
+	if err := os.WriteFile(input, []byte(ramachandranTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	output, err := exec.Command("../bin/pdbtk", "ramachandran", "--format", "json", input, "--plot", filepath.Join(dir, "rama.svg")).Output()
+	if err != nil {
+		t.Fatalf("ramachandran failed: %v", err)
+	}
+	var torsions []struct {
+		ResNum string   `json:"resnum"`
+		Class  string   `json:"class"`
+		Phi    *float64 `json:"phi"`
+		Psi    *float64 `json:"psi"`
+	}
+	if err := json.Unmarshal(output, &torsions); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, string(output))
+	}
+	if len(torsions) != 7 {
+		t.Fatalf("Expected 7 residues, got %d", len(torsions))
+	}
+	classes := []string{"general", "glycine", "pre-proline", "proline", "general", "general", "general"}
+	for i, r := range torsions {
+		if r.Class != classes[i] {
+			t.Errorf("Expected residue %s to be %s, got %s", r.ResNum, classes[i], r.Class)
+		}
+		// Phi is undefined at the start of the chain and after the break, psi at the end and before it
+		if (r.Phi == nil) != (i == 0 || i == 6) || (r.Psi == nil) != (i >= 5) {
+			t.Errorf("Unexpected undefined angles for residue %s: %v %v", r.ResNum, r.Phi, r.Psi)
+			continue
+		}
+		if r.Phi != nil && (*r.Phi < -57.5 || *r.Phi > -56.5) || r.Psi != nil && (*r.Psi < -47.5 || *r.Psi > -46.5) {
+			t.Errorf("Expected helical angles for residue %s, got %v %v", r.ResNum, *r.Phi, *r.Psi)
+		}
+	}
+
+	svg, err := os.ReadFile(filepath.Join(dir, "rama.svg"))
+	if err != nil {
+		t.Fatalf("Expected an SVG plot: %v", err)
+	}
+	// 4 residues with both angles and the 4 legend markers
+	if n := strings.Count(string(svg), "<circle"); n != 8 || !strings.Contains(string(svg), "<title>A:3 ALA (-57.0, -47.0)</title>") {
+		t.Errorf("Unexpected SVG plot (%d circles):\n%s", n, string(svg))
+	}
+
+	output, err = exec.Command("../bin/pdbtk", "ramachandran", input, "--plot", filepath.Join(dir, "rama_{chain}.png")).Output()
+	if err != nil {
+		t.Fatalf("ramachandran --plot png failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), "chain\tresnum\tresname\tclass\tphi\tpsi\nA\t1\tALA\tgeneral\t\t-47.0\n") {
+		t.Errorf("Unexpected table:\n%s", string(output))
+	}
+	file, err := os.Open(filepath.Join(dir, "rama_A.png"))
+	if err != nil {
+		t.Fatalf("Expected a PNG plot: %v", err)
+	}
+	defer file.Close()
+	if img, err := png.Decode(file); err != nil || img.Bounds().Dx() != 500 {
+		t.Errorf("Invalid PNG plot: %v", err)
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "ramachandran", input, "--plot", filepath.Join(dir, "rama.pdf"))); code != 1 {
+		t.Errorf("Expected exit code 1 for an unsupported plot format, got %d", code)
+	}
+}
@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRemoveHydrogensCommand(t *testing.T) {
+	testPDB := `ATOM      1  N   ALA A   1      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  H   ALA A   1      11.000  10.000  10.000  1.00 20.00           H
+ATOM      3  CA  ALA A   1      12.000  10.000  10.000  1.00 20.00           C
+ATOM      4  HA  ALA A   1      13.000  10.000  10.000  1.00 20.00
+ATOM      5  D   ALA A   1      14.000  10.000  10.000  1.00 20.00           D
+HETATM    6 HG    HG A 101      15.000  10.000  10.000  1.00 20.00
+CONECT    1    2
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "remove-hydrogens")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("remove-hydrogens command failed: %v", err)
+	}
+
+	outputStr := string(output)
+	if strings.Contains(outputStr, " H   ALA") || strings.Contains(outputStr, " HA  ALA") || strings.Contains(outputStr, " D   ALA") {
+		t.Errorf("Expected hydrogen and deuterium atoms to be removed, got:\n%s", outputStr)
+	}
+	if !strings.Contains(outputStr, "HG A 101") {
+		t.Errorf("Expected mercury ion to be kept, got:\n%s", outputStr)
+	}
+	if strings.Contains(outputStr, "CONECT") {
+		t.Errorf("Expected CONECT record to a removed atom to be dropped, got:\n%s", outputStr)
+	}
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRemoveWaters(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		waters string
+	}{
+		{"All", nil, ""},
+		{"KeepWatersWithin", []string{"--keep-waters-within", "3.5"}, "203"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("../bin/pdbtk", append([]string{"remove-waters"}, tt.args...)...)
+			cmd.Stdin = strings.NewReader(solvatedPDB)
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("remove-waters failed: %v", err)
+			}
+			var waters []string
+			for _, line := range strings.Split(string(output), "\n") {
+				if strings.HasPrefix(line, "HETATM") && line[17:20] == "HOH" {
+					waters = append(waters, strings.TrimSpace(line[22:26]))
+				}
+			}
+			if strings.Join(waters, ",") != tt.waters {
+				t.Errorf("Expected waters %q, got %v", tt.waters, waters)
+			}
+			if !strings.Contains(string(output), "ZN A 101") {
+				t.Errorf("Expected the zinc ion to be kept, got:\n%s", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// modelOrder returns the MODEL numbers of a PDB file with the x coordinate of the first atom of each
+func modelOrder(output []byte) string {
+	var models []string
+	model := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		switch {
+		case strings.HasPrefix(line, "MODEL"):
+			model = strings.TrimSpace(line[10:14])
+		case strings.HasPrefix(line, "ATOM") && model != "":
+			models = append(models, model+":"+strings.TrimSpace(line[30:38]))
+			model = ""
+		}
+	}
+	return strings.Join(models, " ")
+}
+
+func TestRenumberModels(t *testing.T) {
+	// Concatenated ensemble with a duplicate model number
+	testPDB := `MODEL        1
+ATOM      1  CA  ALA A   1      11.000  10.000  10.000  1.00 10.00           C
+ENDMDL
+MODEL        1
+ATOM      1  CA  ALA A   1      12.000  10.000  10.000  1.00 10.00           C
+ENDMDL
+MODEL        7
+ATOM      1  CA  ALA A   1      13.000  10.000  10.000  1.00 10.00           C
+ENDMDL
+END
+`
+	inputFile := "test_renumber_models.pdb"
+	if err := os.WriteFile(inputFile, []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(inputFile)
+
+	output, err := exec.Command("../bin/pdbtk", "renumber-models", inputFile).Output()
+	if err != nil {
+		t.Fatalf("renumber-models failed: %v", err)
+	}
+	if got := modelOrder(output); got != "1:11.000 2:12.000 3:13.000" {
+		t.Errorf("Expected sequential models, got %s", got)
+	}
+
+	// With duplicate model numbers, scores refer to the position of each model
+	scoreFile := "test_renumber_models_scores.csv"
+	if err := os.WriteFile(scoreFile, []byte("model,energy\n1,-5.0\n2,-9.5\n3,-7.0\n"), 0644); err != nil {
+		t.Fatalf("Failed to create score file: %v", err)
+	}
+	defer os.Remove(scoreFile)
+
+	output, err = exec.Command("../bin/pdbtk", "renumber-models", "--order-by", scoreFile, "--start", "0", inputFile).Output()
+	if err != nil {
+		t.Fatalf("renumber-models --order-by failed: %v", err)
+	}
+	if got := modelOrder(output); got != "0:12.000 1:13.000 2:11.000" {
+		t.Errorf("Expected models sorted by energy, got %s", got)
+	}
+
+	output, err = exec.Command("../bin/pdbtk", "renumber-models", "--order-by", scoreFile, "--descending", inputFile).Output()
+	if err != nil {
+		t.Fatalf("renumber-models --descending failed: %v", err)
+	}
+	if got := modelOrder(output); got != "1:11.000 2:13.000 3:12.000" {
+		t.Errorf("Expected models sorted by descending energy, got %s", got)
+	}
+}
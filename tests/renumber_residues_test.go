@@ -147,3 +147,102 @@ END`
 		t.Error("Expected renumbering with stdin input")
 	}
 }
+
+func TestRenumberResiduesReference(t *testing.T) {
+	reference := `ATOM      1  CA  MET A  10      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  LYS A  11      11.000  10.000  10.000  1.00 20.00           C
+ATOM      3  CA  ALA A  12      12.000  10.000  10.000  1.00 20.00           C
+ATOM      4  CA  GLY A  15      13.000  10.000  10.000  1.00 20.00           C
+ATOM      5  CA  SER A  16      14.000  10.000  10.000  1.00 20.00           C
+ATOM      6  CA  TRP A  17      15.000  10.000  10.000  1.00 20.00           C
+END
+`
+	model := `ATOM      1  CA  PRO B   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  MET B   2      11.000  10.000  10.000  1.00 20.00           C
+ATOM      3  CA  LYS B   3      12.000  10.000  10.000  1.00 20.00           C
+ATOM      4  CA  ALA B   4      13.000  10.000  10.000  1.00 20.00           C
+ATOM      5  CA  HIS B   5      14.000  10.000  10.000  1.00 20.00           C
+ATOM      6  CA  GLY B   6      15.000  10.000  10.000  1.00 20.00           C
+ATOM      7  CA  SER B   7      16.000  10.000  10.000  1.00 20.00           C
+ATOM      8  CA  TRP B   8      17.000  10.000  10.000  1.00 20.00           C
+END
+`
+	referenceFile := "test_renumber_reference.pdb"
+	if err := os.WriteFile(referenceFile, []byte(reference), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(referenceFile)
+
+	cmd := exec.Command("../bin/pdbtk", "renumber-residues", "--reference", referenceFile, "--reference-chain", "A")
+	cmd.Stdin = strings.NewReader(model)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("renumber-residues --reference failed: %v", err)
+	}
+
+	var numbers []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			numbers = append(numbers, strings.TrimSpace(line[17:20])+strings.TrimSpace(line[22:27]))
+		}
+	}
+	expected := "PRO9 MET10 LYS11 ALA12 HIS13 GLY15 SER16 TRP17"
+	if strings.Join(numbers, " ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(numbers, " "))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "renumber-residues", "--reference", referenceFile, "--start", "5")
+	cmd.Stdin = strings.NewReader(model)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error for --start with --reference")
+	}
+}
+
+func TestRenumberResiduesRemoveInsertionCodes(t *testing.T) {
+	testPDB := `ATOM      1  CA  SER H  52      11.000  10.000  10.000  1.00 10.00           C
+ATOM      2  CA  GLY H  52A     12.000  10.000  10.000  1.00 10.00           C
+ATOM      3  CA  TYR H  52B     13.000  10.000  10.000  1.00 10.00           C
+ATOM      4  CA  ASN H  53      14.000  10.000  10.000  1.00 10.00           C
+ATOM      5  CA  ALA H  60      15.000  10.000  10.000  1.00 10.00           C
+ATOM      6  CA  ALA L   1      16.000  10.000  10.000  1.00 10.00           C
+END
+`
+	auditFile := "test_renumber_audit.tsv"
+	defer os.Remove(auditFile)
+
+	cmd := exec.Command("../bin/pdbtk", "renumber-residues", "--remove-icodes", "--audit", auditFile)
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("renumber-residues --remove-icodes failed: %v", err)
+	}
+
+	var numbers []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			numbers = append(numbers, line[21:27])
+		}
+	}
+	expected := "H  52 |H  53 |H  54 |H  55 |H  62 |L   1 "
+	if strings.Join(numbers, "|") != expected {
+		t.Errorf("Expected %q, got %q", expected, strings.Join(numbers, "|"))
+	}
+	if !strings.Contains(string(output), "REMARK 999     H GLY          52A     53") {
+		t.Errorf("Expected a REMARK 999 record with the original numbering, got:\n%s", output)
+	}
+
+	audit, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatalf("Failed to read the audit table: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(audit)), "\n")
+	if len(lines) != 7 || lines[0] != "chain\tresidue\toriginal\tnew" || lines[3] != "H\tTYR\t52B\t54" {
+		t.Errorf("Unexpected audit table:\n%s", audit)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "renumber-residues", "--audit", auditFile)
+	cmd.Stdin = strings.NewReader(testPDB)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected an error for --audit without --remove-icodes")
+	}
+}
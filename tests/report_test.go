@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONErrors(t *testing.T) {
+	cmd := exec.Command("../bin/pdbtk", "extract", "--json-errors", "--chains", "A", "nonexistent.pdb")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err == nil {
+		t.Fatal("Expected extract to fail for a missing input file")
+	}
+
+	var reported struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stderr.String())), &reported); err != nil {
+		t.Fatalf("Expected stderr to be a JSON object, got %q: %v", stderr.String(), err)
+	}
+	if reported.Code != "input_not_found" {
+		t.Errorf("Expected error code input_not_found, got %s", reported.Code)
+	}
+}
+
+func TestReportFile(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pdbtk_report_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	good := filepath.Join(dir, "good.pdb")
+	bad := filepath.Join(dir, "bad.pdb")
+	if err := os.WriteFile(good, []byte(batchTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(bad, []byte("NOT A PDB FILE\n"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	reportPath := filepath.Join(dir, "report.json")
+	cmd := exec.Command("../bin/pdbtk", "extract", "--chains", "A", "--outdir", filepath.Join(dir, "out"),
+		"--report", reportPath, good, bad)
+	if err := cmd.Run(); err == nil {
+		t.Error("Expected batch run with a broken input to fail")
+	}
+
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("Expected report file to be written: %v", err)
+	}
+
+	var report struct {
+		Status  string `json:"status"`
+		Results []struct {
+			Input  string `json:"input"`
+			Status string `json:"status"`
+			Error  *struct {
+				Code string `json:"code"`
+			} `json:"error"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(content, &report); err != nil {
+		t.Fatalf("Failed to parse report: %v", err)
+	}
+
+	if report.Status != "error" {
+		t.Errorf("Expected overall status error, got %s", report.Status)
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(report.Results))
+	}
+	if report.Results[0].Status != "ok" {
+		t.Errorf("Expected %s to succeed, got %s", report.Results[0].Input, report.Results[0].Status)
+	}
+	if report.Results[1].Status != "error" || report.Results[1].Error == nil || report.Results[1].Error.Code != "parse_error" {
+		t.Errorf("Expected %s to fail with parse_error", report.Results[1].Input)
+	}
+}
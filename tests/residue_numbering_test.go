@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestResidueNumbering(t *testing.T) {
+	testCIF := `data_TEST
+_entry.id TEST
+loop_
+_pdbx_poly_seq_scheme.asym_id
+_pdbx_poly_seq_scheme.entity_id
+_pdbx_poly_seq_scheme.seq_id
+_pdbx_poly_seq_scheme.mon_id
+_pdbx_poly_seq_scheme.pdb_seq_num
+_pdbx_poly_seq_scheme.auth_seq_num
+_pdbx_poly_seq_scheme.pdb_mon_id
+_pdbx_poly_seq_scheme.pdb_strand_id
+_pdbx_poly_seq_scheme.pdb_ins_code
+A 1 1 MET 23 ? ? H .
+A 1 2 SER 24 24 SER H .
+A 1 3 GLY 24 24 GLY H A
+A 1 4 TYR 25 25 TYR H .
+B 2 1 ALA 1 1 ALA L .
+`
+	cmd := exec.Command("../bin/pdbtk", "residue-numbering")
+	cmd.Stdin = strings.NewReader(testCIF)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("residue-numbering failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	expected := []string{
+		"entity\tlabel_asym_id\tlabel_seq_id\tauth_asym_id\tauth_seq_id\tins_code\tresidue\tobserved\toffset",
+		"1\tA\t1\tH\t\t\tMET\tfalse\t",
+		"1\tA\t2\tH\t24\t\tSER\ttrue\t22",
+		"1\tA\t3\tH\t24\tA\tGLY\ttrue\t21",
+		"1\tA\t4\tH\t25\t\tTYR\ttrue\t21",
+		"2\tB\t1\tL\t1\t\tALA\ttrue\t0",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected output:\n%s", output)
+	}
+
+	// Fall back to _atom_site, selecting a chain by its label ID
+	atomSiteCIF := `data_TEST
+loop_
+_atom_site.group_PDB
+_atom_site.id
+_atom_site.label_atom_id
+_atom_site.label_comp_id
+_atom_site.label_asym_id
+_atom_site.label_entity_id
+_atom_site.label_seq_id
+_atom_site.auth_seq_id
+_atom_site.auth_asym_id
+_atom_site.pdbx_PDB_model_num
+ATOM 1 N ALA A 1 1 5 X 1
+ATOM 2 CA ALA A 1 1 5 X 1
+ATOM 3 N GLY A 1 2 6 X 1
+HETATM 4 O HOH B 2 . 101 X 1
+`
+	cmd = exec.Command("../bin/pdbtk", "residue-numbering", "--chains", "A", "--format", "json")
+	cmd.Stdin = strings.NewReader(atomSiteCIF)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("residue-numbering --format json failed: %v", err)
+	}
+	var residues []struct {
+		AuthAsym string `json:"auth_asym_id"`
+		LabelSeq int    `json:"label_seq_id"`
+		AuthSeq  int    `json:"auth_seq_id"`
+		Offset   int    `json:"offset"`
+	}
+	if err := json.Unmarshal(output, &residues); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if len(residues) != 2 || residues[1].AuthAsym != "X" || residues[1].LabelSeq != 2 || residues[1].AuthSeq != 6 || residues[1].Offset != 4 {
+		t.Errorf("Unexpected residues: %+v", residues)
+	}
+
+	// PDB files have no label numbering
+	cmd = exec.Command("../bin/pdbtk", "residue-numbering")
+	cmd.Stdin = strings.NewReader("ATOM      1  CA  ALA A   1      11.000  10.000  10.000  1.00 10.00           C\nEND\n")
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 for a PDB file, got %d", code)
+	}
+}
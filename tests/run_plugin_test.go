@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunPlugin(t *testing.T) {
+	dir := t.TempDir()
+	contextFile := filepath.Join(dir, "context.json")
+	// The plugin records its context and renames the waters
+	script := "#!/bin/sh\nprintf '%s' \"$PDBTK_CONTEXT\" > " + contextFile + "\nsed 's/HOH/WAT/'\n"
+	if err := os.WriteFile(filepath.Join(dir, "pdbtk-rename-waters"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pdbtk-fail"), []byte("#!/bin/sh\necho broken >&2\nexit 3\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	env := append(os.Environ(), "PDBTK_PLUGIN_PATH="+dir)
+
+	cmd := exec.Command("../bin/pdbtk", "run-plugin", "rename-waters", "--arg", "--mode", "--arg", "fast", "--list=false")
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(consolidateTestPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("run-plugin failed: %v", err)
+	}
+	if !strings.Contains(string(output), "O   WAT A   1") || strings.Contains(string(output), "HOH") {
+		t.Errorf("Expected the plugin output:\n%s", output)
+	}
+	if !strings.Contains(string(output), "REMARK   1 COMMAND: pdbtk run-plugin --arg --mode --arg fast --list=false rename-waters") {
+		t.Errorf("Expected a provenance record:\n%s", output)
+	}
+	var context struct {
+		Step  string   `json:"step"`
+		Input string   `json:"input"`
+		Args  []string `json:"args"`
+	}
+	data, err := os.ReadFile(contextFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &context); err != nil {
+		t.Fatalf("Invalid context %s: %v", data, err)
+	}
+	if context.Step != "rename-waters" || context.Input != "" || strings.Join(context.Args, " ") != "--mode fast" {
+		t.Errorf("Unexpected context: %s", data)
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "run-plugin", "--list")
+	cmd.Env = env
+	output, err = cmd.Output()
+	if err != nil || !strings.Contains(string(output), "rename-waters\t"+filepath.Join(dir, "pdbtk-rename-waters")) {
+		t.Errorf("Unexpected plugin list (%v):\n%s", err, output)
+	}
+
+	for _, name := range []string{"fail", "missing"} {
+		cmd = exec.Command("../bin/pdbtk", "run-plugin", name)
+		cmd.Env = env
+		cmd.Stdin = strings.NewReader(consolidateTestPDB)
+		if code := exitCodeOf(t, cmd); code != 1 {
+			t.Errorf("run-plugin %s exit code = %d, want 1", name, code)
+		}
+	}
+}
+
+func TestRunPluginTimeout(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pdbtk-slow"), []byte("#!/bin/sh\nsleep 5\ncat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	cmd := exec.Command("../bin/pdbtk", "--timeout", "1s", "run-plugin", "slow")
+	cmd.Env = append(os.Environ(), "PDBTK_PLUGIN_PATH="+dir)
+	cmd.Stdin = strings.NewReader(consolidateTestPDB)
+	if code := exitCodeOf(t, cmd); code != 6 {
+		t.Errorf("Expected exit code 6 for a plugin that runs past --timeout, got %d", code)
+	}
+	if elapsed := time.Since(start); elapsed > 4*time.Second {
+		t.Errorf("Expected the plugin to be stopped at the timeout, took %v", elapsed)
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSearchSeq(t *testing.T) {
+	var query struct {
+		Query struct {
+			Parameters struct {
+				Identity     float64 `json:"identity_cutoff"`
+				SequenceType string  `json:"sequence_type"`
+				Value        string  `json:"value"`
+			} `json:"parameters"`
+		} `json:"query"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/rcsbsearch/v2/query" || json.NewDecoder(r.Body).Decode(&query) != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if query.Query.Parameters.Identity > 0.95 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"total_count": 2, "result_set": [
+			{"identifier": "4HHB.A", "score": 1.0, "services": [{"service_type": "sequence", "nodes": [{"match_context": [
+				{"sequence_identity": 1.0, "evalue": 1e-80, "bitscore": 280, "alignment_length": 3,
+				 "query_beg": 1, "query_end": 3, "subject_beg": 10, "subject_end": 12}]}]}]},
+			{"identifier": "1A00.C", "score": 0.9, "services": [{"service_type": "sequence", "nodes": [{"match_context": [
+				{"sequence_identity": 0.92, "evalue": 1e-60, "bitscore": 250, "alignment_length": 3,
+				 "query_beg": 1, "query_end": 3, "subject_beg": 1, "subject_end": 3}]}]}]}]}`))
+	}))
+	defer server.Close()
+
+	testPDB := `ATOM      1  CA  MET A   1      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  DA  B   1      20.000  10.000  10.000  1.00 20.00           C
+ATOM      3  CA  VAL C   1      30.000  10.000  10.000  1.00 20.00           C
+ATOM      4  CA  LEU C   2      33.800  10.000  10.000  1.00 20.00           C
+ATOM      5  CA  SER C   3      37.600  10.000  10.000  1.00 20.00           C
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "search-seq", "--chain", "C")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_SEARCH_URL="+server.URL)
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("search-seq failed: %v", err)
+	}
+	if query.Query.Parameters.Value != "VLS" || query.Query.Parameters.SequenceType != "protein" {
+		t.Errorf("Unexpected query: %+v", query.Query.Parameters)
+	}
+	expected := "identifier\tentry\tchain\tidentity\tevalue\tbitscore\talignment_length\tquery_start\tquery_end\tsubject_start\tsubject_end\n" +
+		"4HHB.A\t4HHB\tA\t1.000\t1e-80\t280\t3\t1\t3\t10\t12\n" +
+		"1A00.C\t1A00\tC\t0.920\t1e-60\t250\t3\t1\t3\t1\t3\n"
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, string(output))
+	}
+
+	// A search without results writes an empty table
+	cmd = exec.Command("../bin/pdbtk", "search-seq", "--chain", "B", "--identity", "1", "--format", "json")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_SEARCH_URL="+server.URL)
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("search-seq without results failed: %v", err)
+	}
+	if strings.TrimSpace(string(output)) != "[]" || query.Query.Parameters.SequenceType != "dna" {
+		t.Errorf("Expected an empty DNA search result, got %s (%+v)", string(output), query.Query.Parameters)
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+const serveTestPDB = `ATOM      1  N   ALA A   1      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CA  ALA A   1      11.458  10.000  10.000  1.00 20.00           C
+ATOM      3  N   GLY B   1      20.000  10.000  10.000  1.00 20.00           N
+ATOM      4  CA  GLY B   1      21.458  10.000  10.000  1.00 20.00           C
+END
+`
+
+// startServer runs pdbtk serve on a free port, with any extra flags, and returns its base URL
+func startServer(t *testing.T, flags ...string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	cmd := exec.Command("../bin/pdbtk", append([]string{"serve", "--addr", addr}, flags...)...)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	url := "http://" + addr
+	for i := 0; i < 50; i++ {
+		if resp, err := http.Get(url + "/health"); err == nil {
+			resp.Body.Close()
+			return url
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("Server did not start on %s", addr)
+	return ""
+}
+
+func TestServe(t *testing.T) {
+	url := startServer(t)
+
+	post := func(path, contentType string, body io.Reader) (int, string) {
+		resp, err := http.Post(url+path, contentType, body)
+		if err != nil {
+			t.Fatalf("Request to %s failed: %v", path, err)
+		}
+		defer resp.Body.Close()
+		content, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(content)
+	}
+
+	status, body := post("/v1/extract?chains=B", "chemical/x-pdb", strings.NewReader(serveTestPDB))
+	if status != http.StatusOK || !strings.Contains(body, "GLY B") || strings.Contains(body, "ALA A") {
+		t.Errorf("Unexpected extract response %d:\n%s", status, body)
+	}
+
+	// Flags from an earlier request do not leak into the next one
+	var upload bytes.Buffer
+	form := multipart.NewWriter(&upload)
+	part, _ := form.CreateFormFile("file", "test.pdb")
+	part.Write([]byte(serveTestPDB))
+	form.Close()
+	status, body = post("/v1/extract-seq", form.FormDataContentType(), &upload)
+	if status != http.StatusOK || !strings.Contains(body, ">test_A\nA\n") || !strings.Contains(body, ">test_B\nG\n") {
+		t.Errorf("Unexpected extract-seq response %d:\n%s", status, body)
+	}
+
+	for _, tt := range []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/v1/extract?chains=Z", http.StatusNotFound, "no_match"},
+		{"/v1/extract?output=/tmp/x.pdb", http.StatusBadRequest, "invalid_argument"},
+		{"/v1/extract?bogus=1", http.StatusBadRequest, "invalid_argument"},
+	} {
+		status, body = post(tt.path, "chemical/x-pdb", strings.NewReader(serveTestPDB))
+		var reported struct {
+			Code string `json:"code"`
+		}
+		json.Unmarshal([]byte(body), &reported)
+		if status != tt.status || reported.Code != tt.code {
+			t.Errorf("%s: expected %d %s, got %d %s", tt.path, tt.status, tt.code, status, body)
+		}
+	}
+
+	status, body = post("/v1/extract", "chemical/x-pdb", strings.NewReader(""))
+	if status != http.StatusBadRequest {
+		t.Errorf("Expected an empty request to fail with 400, got %d %s", status, body)
+	}
+}
+
+func TestServeRequestTimeout(t *testing.T) {
+	// Downloads from this server never finish, until the request is abandoned
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer files.Close()
+	t.Setenv("PDBTK_RCSB_FILES_URL", files.URL)
+	url := startServer(t, "--timeout", "300ms")
+
+	// The timeout applies to each request, not to the server
+	time.Sleep(500 * time.Millisecond)
+	resp, err := http.Post(url+"/v1/extract?pdb=1ABC", "text/plain", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(body), `"canceled"`) {
+		t.Errorf("Expected a 503 canceled error, got %d: %s", resp.StatusCode, string(body))
+	}
+
+	resp, err = http.Post(url+"/v1/extract?chains=A", "text/plain", strings.NewReader(serveTestPDB))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the next request to succeed, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetCell(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.pdb")
+	if err := os.WriteFile(input, []byte(batchTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	cell := filepath.Join(dir, "cell.pdb")
+	cmd := exec.Command("../bin/pdbtk", "set-cell", "--cell", "40.1,75.3,80.2,90,105.5,90", "--output", cell, input)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("set-cell failed: %v\n%s", err, string(output))
+	}
+	content, _ := os.ReadFile(cell)
+	for _, expected := range []string{
+		"CRYST1   40.100   75.300   80.200  90.00 105.50  90.00 P 1           1\n",
+		"ORIGX1      1.000000  0.000000  0.000000        0.00000\n",
+		"SCALE1      0.024938  0.000000  0.006916        0.00000\n",
+		"SCALE2      0.000000  0.013280  0.000000        0.00000\n",
+		"SCALE3      0.000000  0.000000  0.012939        0.00000\n",
+	} {
+		if !strings.Contains(string(content), expected) {
+			t.Errorf("Expected %q in output:\n%s", expected, string(content))
+		}
+	}
+
+	// Editing the space group keeps the cell
+	output, err := exec.Command("../bin/pdbtk", "set-cell", "--space-group", "P 1 21 1", "--z", "2", cell).Output()
+	if err != nil {
+		t.Fatalf("set-cell --space-group failed: %v", err)
+	}
+	if !strings.Contains(string(output), "CRYST1   40.100   75.300   80.200  90.00 105.50  90.00 P 1 21 1      2\n") {
+		t.Errorf("Expected the edited CRYST1 record, got:\n%s", string(output))
+	}
+
+	// The crystal records are kept by other commands
+	for _, args := range [][]string{
+		{"extract", "--chains", "A", cell},
+		{"rename-chain", "A", "--to", "C", cell},
+		{"renumber-residues", "--start", "5", cell},
+		{"collapse-altloc", cell},
+	} {
+		output, err := exec.Command("../bin/pdbtk", args...).Output()
+		if err != nil {
+			t.Fatalf("%v failed: %v", args, err)
+		}
+		if !strings.Contains(string(output), "CRYST1   40.100") || !strings.Contains(string(output), "SCALE3") {
+			t.Errorf("%v: expected the crystal records to be kept, got:\n%s", args, string(output))
+		}
+	}
+
+	output, err = exec.Command("../bin/pdbtk", "set-cell", "--remove", cell).Output()
+	if err != nil {
+		t.Fatalf("set-cell --remove failed: %v", err)
+	}
+	if strings.Contains(string(output), "CRYST1") || strings.Contains(string(output), "SCALE") {
+		t.Errorf("Expected no crystal records, got:\n%s", string(output))
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "set-cell", "--space-group", "P 1", input)); code != 1 {
+		t.Errorf("Expected exit code 1 for editing a missing CRYST1, got %d", code)
+	}
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "set-cell", "--cell", "10,10,10,90,90,200", input)); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid cell, got %d", code)
+	}
+}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSetProtonation(t *testing.T) {
+	mutate := exec.Command("../bin/pdbtk", "mutate", "A:4", "HIS")
+	mutate.Stdin = strings.NewReader(mutateTestPDB)
+	histidine, err := mutate.Output()
+	if err != nil {
+		t.Fatalf("mutate failed: %v", err)
+	}
+	_, ring, _ := residueAtoms(string(histidine), 4)
+
+	// partner returns a HETATM or ATOM record 2.8 Å (or 2.1 Å for a metal) out from a ring nitrogen
+	partner := func(record, name, resName string, chainID byte, nitrogen, element string) string {
+		var center [3]float64
+		for _, atom := range []string{"CG", "ND1", "CD2", "CE1", "NE2"} {
+			for i := range center {
+				center[i] += ring[atom][i] / 5
+			}
+		}
+		d := 2.8
+		if element == "ZN" {
+			d = 2.1
+		}
+		n := ring[nitrogen]
+		length := math.Sqrt(sq(n[0]-center[0]) + sq(n[1]-center[1]) + sq(n[2]-center[2]))
+		var p [3]float64
+		for i := range p {
+			p[i] = n[i] + (n[i]-center[i])/length*d
+		}
+		return fmt.Sprintf("%-6s%5d %-4s %3s %c%4d    %8.3f%8.3f%8.3f  1.00 20.00          %2s", record, 99, name, resName, chainID, 1, p[0], p[1], p[2], element)
+	}
+	run := func(extra []string, args ...string) (string, string) {
+		var lines []string
+		for _, line := range strings.Split(string(histidine), "\n") {
+			if line != "END" && line != "" {
+				lines = append(lines, line)
+			}
+		}
+		lines = append(append(lines, extra...), "END")
+		cmd := exec.Command("../bin/pdbtk", append([]string{"set-protonation"}, args...)...)
+		cmd.Stdin = strings.NewReader(strings.Join(lines, "\n"))
+		var stderr strings.Builder
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("set-protonation %v failed: %v\n%s", args, err, stderr.String())
+		}
+		return string(output), stderr.String()
+	}
+	resName := func(output string) string {
+		_, _, name := residueAtoms(output, 4)
+		return name
+	}
+
+	tests := []struct {
+		name     string
+		extra    []string
+		args     []string
+		expected string
+	}{
+		{"default", nil, nil, "HIE"},
+		{"ND1 donates", []string{partner("ATOM", " OD1", "ASP", 'B', "ND1", "O")}, nil, "HID"},
+		{"both donate", []string{partner("ATOM", " OD1", "ASP", 'B', "ND1", "O"), partner("ATOM", " OE1", "GLU", 'C', "NE2", "O")}, nil, "HIP"},
+		{"NE2 coordinates zinc", []string{partner("HETATM", "ZN", "ZN", 'D', "NE2", "ZN")}, nil, "HID"},
+		{"rule", nil, []string{"--set", "A:4=HIP"}, "HIP"},
+		{"charmm", []string{partner("ATOM", " OD1", "ASP", 'B', "ND1", "O")}, []string{"--forcefield", "charmm"}, "HSD"},
+	}
+	for _, tt := range tests {
+		output, stderr := run(tt.extra, tt.args...)
+		if got := resName(output); got != tt.expected {
+			t.Errorf("%s: residue 4 is %s, want %s (%s)", tt.name, got, tt.expected, stderr)
+		}
+	}
+
+	if _, stderr := run(nil, "--set", "A:4=HIP"); !strings.Contains(stderr, "A:4 HIS -> HIP (rule)") {
+		t.Errorf("Unexpected report: %s", stderr)
+	}
+
+	// Rules are checked against the residue type and the force field
+	for _, args := range [][]string{{"--set", "A:4=GLH"}, {"--set", "A:3=XYZ"}, {"--forcefield", "charmm", "--set", "A:4=GLH"}} {
+		cmd := exec.Command("../bin/pdbtk", append([]string{"set-protonation"}, args...)...)
+		cmd.Stdin = strings.NewReader(string(histidine))
+		if code := exitCodeOf(t, cmd); code != 1 {
+			t.Errorf("set-protonation %v exit code = %d, want 1", args, code)
+		}
+	}
+}
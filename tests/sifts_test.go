@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const siftsTestXML = `<?xml version="1.0" encoding="UTF-8"?>
+<entry xmlns="http://www.ebi.ac.uk/pdbe/docs/sifts/eFamily.xsd" dbSource="PDBe" dbAccessionId="1abc">
+  <entity type="protein" entityId="A">
+    <segment segId="1abc_A_1_3" start="1" end="3">
+      <listResidue>
+        <residue dbSource="PDBe" dbCoordSys="PDBe" dbResNum="1" dbResName="MET">
+          <crossRefDb dbSource="PDB" dbCoordSys="PDBresnum" dbAccessionId="1abc" dbResNum="null" dbResName="MET" dbChainId="A"/>
+          <crossRefDb dbSource="UniProt" dbCoordSys="UniProt" dbAccessionId="P12345" dbResNum="1" dbResName="M"/>
+          <residueDetail dbSource="PDBe" property="Annotation">Not_Observed</residueDetail>
+        </residue>
+        <residue dbSource="PDBe" dbCoordSys="PDBe" dbResNum="2" dbResName="VAL">
+          <crossRefDb dbSource="PDB" dbCoordSys="PDBresnum" dbAccessionId="1abc" dbResNum="10" dbResName="VAL" dbChainId="A"/>
+          <crossRefDb dbSource="UniProt" dbCoordSys="UniProt" dbAccessionId="P12345" dbResNum="2" dbResName="V"/>
+          <crossRefDb dbSource="Pfam" dbCoordSys="UniProt" dbAccessionId="PF00042" dbResNum="2" dbResName="V"/>
+          <crossRefDb dbSource="CATH" dbCoordSys="PDBresnum" dbAccessionId="1.10.490.10" dbResNum="10" dbResName="VAL" dbChainId="A"/>
+        </residue>
+        <residue dbSource="PDBe" dbCoordSys="PDBe" dbResNum="3" dbResName="LEU">
+          <crossRefDb dbSource="PDB" dbCoordSys="PDBresnum" dbAccessionId="1abc" dbResNum="10A" dbResName="LEU" dbChainId="A"/>
+          <crossRefDb dbSource="UniProt" dbCoordSys="UniProt" dbAccessionId="P12345" dbResNum="3" dbResName="L"/>
+          <crossRefDb dbSource="SCOP" dbCoordSys="PDBresnum" dbAccessionId="46486" dbResNum="10A" dbResName="LEU" dbChainId="A"/>
+        </residue>
+      </listResidue>
+    </segment>
+  </entity>
+</entry>
+`
+
+func TestSifts(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte(siftsTestXML))
+	gz.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1abc.xml.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	cmd := exec.Command("../bin/pdbtk", "sifts", "1ABC")
+	cmd.Env = append(cmd.Environ(), "PDBTK_SIFTS_URL="+server.URL)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sifts failed: %v", err)
+	}
+	expected := "chain\tresnum\tresname\tobserved\tuniprot\tuniprot_resnum\tuniprot_resname\tpfam\tcath\tscop\n" +
+		"A\t\tMET\tfalse\tP12345\t1\tM\t\t\t\n" +
+		"A\t10\tVAL\ttrue\tP12345\t2\tV\tPF00042\t1.10.490.10\t\n" +
+		"A\t10A\tLEU\ttrue\tP12345\t3\tL\t\t\t46486\n"
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, string(output))
+	}
+}
+
+func TestSiftsStamp(t *testing.T) {
+	dir := t.TempDir()
+	xmlFile := dir + "/1abc.xml"
+	structure := dir + "/1abc.pdb"
+	if err := os.WriteFile(xmlFile, []byte(siftsTestXML), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	structurePDB := `ATOM      1  CA  VAL A  10      10.000  10.000  10.000  1.00 20.00           C
+ATOM      2  CA  LEU A  10A     13.800  10.000  10.000  1.00 20.00           C
+ATOM      3  CA  GLY A  11      17.600  10.000  10.000  1.00 20.00           C
+END
+`
+	if err := os.WriteFile(structure, []byte(structurePDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "sifts", "--xml", xmlFile, "--stamp", "uniprot", "--structure", structure)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sifts --stamp failed: %v", err)
+	}
+	var bfactors []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			bfactors = append(bfactors, strings.TrimSpace(line[60:66]))
+		}
+	}
+	if strings.Join(bfactors, ",") != "2.00,3.00,0.00" {
+		t.Errorf("Expected UniProt residue numbers as B-factors, got %v", bfactors)
+	}
+}
@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const solvatedPDB = `ATOM      1  N   ALA A   1       0.000   0.000   0.000  1.00 20.00           N
+ATOM      2  CA  ALA A   1       1.458   0.000   0.000  1.00 20.00           C
+ATOM      3  C   ALA A   1       2.009   1.420   0.000  1.00 20.00           C
+ATOM      4  O   ALA A   1       1.251   2.390   0.000  1.00 20.00           O
+TER       5      ALA A   1
+HETATM    6 ZN    ZN A 101      20.000   0.000   0.000  1.00 20.00          ZN
+HETATM    7  O   HOH A 201       1.251   5.190   0.000  1.00 20.00           O
+HETATM    8  O   HOH A 202      10.000  10.000  10.000  1.00 20.00           O
+HETATM    9  O   HOH A 203      22.000   0.000   0.000  1.00 20.00           O
+END
+`
+
+func TestSolventShell(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		waters []string
+	}{
+		{"Polymer", nil, []string{"201"}},
+		{"Distance", []string{"--distance", "16"}, []string{"201", "202"}},
+		{"Het", []string{"--het", "ZN"}, []string{"203"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command("../bin/pdbtk", append([]string{"solvent-shell"}, tt.args...)...)
+			cmd.Stdin = strings.NewReader(solvatedPDB)
+			output, err := cmd.Output()
+			if err != nil {
+				t.Fatalf("solvent-shell failed: %v", err)
+			}
+			var waters []string
+			atoms := 0
+			for _, line := range strings.Split(string(output), "\n") {
+				if strings.HasPrefix(line, "HETATM") && line[17:20] == "HOH" {
+					waters = append(waters, strings.TrimSpace(line[22:26]))
+				}
+				if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+					atoms++
+				}
+			}
+			if strings.Join(waters, ",") != strings.Join(tt.waters, ",") {
+				t.Errorf("Expected waters %v, got %v", tt.waters, waters)
+			}
+			if atoms != 5+len(tt.waters) {
+				t.Errorf("Expected all non-water atoms to be kept, got %d atoms", atoms)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestSortCommand(t *testing.T) {
+	testPDB := `ATOM      1  CB  ALA B   2      20.154  16.967  23.862  1.00 11.18           C
+ATOM      2  N   ALA B   2      19.030  16.206  23.362  1.00 10.53           N
+ATOM      3  CA AALA A   1      10.000  10.000  10.000  0.50 20.00           C
+ATOM      4  H   ALA A   1      10.000  10.000  10.000  1.00 20.00           H
+ATOM      5  CA BALA A   1      10.000  10.000  10.000  0.50 20.00           C
+ATOM      6  N   ALA A   1      10.000  10.000  10.000  1.00 20.00           N
+CONECT    1    2
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "sort")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("sort command failed: %v", err)
+	}
+
+	var atoms []string
+	var conect []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			atoms = append(atoms, strings.TrimSpace(line[6:27]))
+		}
+		if strings.HasPrefix(line, "CONECT") {
+			conect = append(conect, line)
+		}
+	}
+	expected := []string{
+		"1  N   ALA A   1",
+		"2  CA AALA A   1",
+		"3  CA BALA A   1",
+		"4  H   ALA A   1",
+		"6  N   ALA B   2",
+		"7  CB  ALA B   2",
+	}
+	if strings.Join(atoms, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected atom order:\n%s\nexpected:\n%s", strings.Join(atoms, "\n"), strings.Join(expected, "\n"))
+	}
+	if len(conect) != 1 || conect[0] != "CONECT    7    6" {
+		t.Errorf("Expected CONECT record to be renumbered to 'CONECT    7    6', got %v", conect)
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitEntities(t *testing.T) {
+	testCIF := `data_1ABC
+loop_
+_entity.id
+_entity.type
+_entity.pdbx_description
+1 polymer 'Protein kinase'
+2 polymer 'tRNA'
+3 non-polymer 'ZINC ION'
+4 water water
+loop_
+_entity_poly.entity_id
+_entity_poly.type
+1 'polypeptide(L)'
+2 polyribonucleotide
+loop_
+_atom_site.group_PDB
+_atom_site.id
+_atom_site.type_symbol
+_atom_site.label_atom_id
+_atom_site.label_comp_id
+_atom_site.label_asym_id
+_atom_site.label_entity_id
+_atom_site.label_seq_id
+_atom_site.Cartn_x
+_atom_site.Cartn_y
+_atom_site.Cartn_z
+_atom_site.auth_seq_id
+_atom_site.auth_asym_id
+_atom_site.pdbx_PDB_model_num
+ATOM 1 C CA ALA A 1 1 1.0 2.0 3.0 1 A 1
+ATOM 2 C CA ALA B 1 1 4.0 2.0 3.0 1 BB 1
+ATOM 3 P P G C 2 1 5.0 5.0 5.0 1 R 1
+HETATM 4 ZN ZN ZN D 3 . 3.0 3.0 3.0 101 A 1
+HETATM 5 O O HOH E 4 . 9.0 9.0 9.0 201 A 1
+`
+	inputFile := "test_split_entities.cif"
+	if err := os.WriteFile(inputFile, []byte(testCIF), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove(inputFile)
+	outdir := t.TempDir()
+
+	cmd := exec.Command("../bin/pdbtk", "split-entities", "--types", "protein,ligand", "--name-template", "{stem}_{entity}_{type}.pdb", "--outdir", outdir, inputFile)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("split-entities failed: %v\n%s", err, output)
+	}
+
+	entries, err := os.ReadDir(outdir)
+	if err != nil {
+		t.Fatalf("Failed to read output directory: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, " ") != "test_split_entities_1_protein.pdb test_split_entities_3_ligand.pdb" {
+		t.Fatalf("Unexpected output files: %v", names)
+	}
+
+	protein, err := os.ReadFile(filepath.Join(outdir, "test_split_entities_1_protein.pdb"))
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	var chains []string
+	for _, line := range strings.Split(string(protein), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			chains = append(chains, line[21:22])
+		}
+		if strings.HasPrefix(line, "HETATM") {
+			t.Errorf("Expected no het groups in the protein entity, got %s", line)
+		}
+	}
+	// The two-character chain BB is given an unused chain ID
+	if strings.Join(chains, ",") != "A,B" {
+		t.Errorf("Expected chains A,B, got %s", strings.Join(chains, ","))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "split-entities", "--types", "dna", "--outdir", outdir, inputFile)
+	if code := exitCodeOf(t, cmd); code != 2 {
+		t.Errorf("Expected exit code 2 without DNA entities, got %d", code)
+	}
+}
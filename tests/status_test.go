@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	responses := map[string]string{
+		"/rest/v1/holdings/status/4HHB": `{"rcsb_id": "4HHB", "rcsb_repository_holdings_combined": {"status": "CURRENT", "status_code": "REL"}}`,
+		"/rest/v1/core/entry/4HHB": `{"pdbx_audit_revision_history": [
+			{"data_content_type": "Structure model", "major_revision": 1, "minor_revision": 0, "revision_date": "1984-07-17T00:00:00+0000"},
+			{"data_content_type": "Structure factors", "major_revision": 1, "minor_revision": 0, "revision_date": "1984-07-17T00:00:00+0000"},
+			{"data_content_type": "Structure model", "major_revision": 1, "minor_revision": 1, "revision_date": "2008-03-03T00:00:00+0000"}]}`,
+		"/rest/v1/holdings/status/1HHB":  `{"rcsb_id": "1HHB", "rcsb_repository_holdings_combined": {"status": "OBSOLETE", "status_code": "OBS", "id_code_replaced_by_latest": "2HHB"}}`,
+		"/rest/v1/holdings/removed/1HHB": `{"rcsb_repository_holdings_removed": {"id_codes_replaced_by": ["2HHB"], "remove_date": "1984-07-17T00:00:00+0000"}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	cmd := exec.Command("../bin/pdbtk", "status", "--format", "json", "4hhb", "1HHB")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	var statuses []struct {
+		Entry        string   `json:"entry"`
+		Status       string   `json:"status"`
+		ReplacedBy   []string `json:"replaced_by"`
+		ObsoleteDate string   `json:"obsolete_date"`
+		Versions     []struct {
+			Version string `json:"version"`
+			Date    string `json:"date"`
+		} `json:"versions"`
+	}
+	if err := json.Unmarshal(output, &statuses); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(statuses))
+	}
+	current, obsolete := statuses[0], statuses[1]
+	if current.Status != "CURRENT" || len(current.Versions) != 2 || current.Versions[1].Version != "1.1" || current.Versions[1].Date != "2008-03-03" {
+		t.Errorf("Unexpected status of 4HHB: %+v", current)
+	}
+	if obsolete.Status != "OBSOLETE" || len(obsolete.ReplacedBy) != 1 || obsolete.ReplacedBy[0] != "2HHB" || obsolete.ObsoleteDate != "1984-07-17" {
+		t.Errorf("Unexpected status of 1HHB: %+v", obsolete)
+	}
+
+	// The table reports the latest version, and unknown entries fail with the no-match exit code
+	cmd = exec.Command("../bin/pdbtk", "status", "4HHB", "9ZZZ")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_DATA_URL="+server.URL)
+	output, err = cmd.Output()
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 2 {
+		t.Fatalf("Expected exit code 2 for an unknown entry, got: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 2 || lines[1] != "4HHB\tCURRENT\tREL\t\t\t1.1\t2008-03-03" {
+		t.Errorf("Unexpected table:\n%s", string(output))
+	}
+}
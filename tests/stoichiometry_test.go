@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// chainPDB returns CA-only ATOM records for a chain with the given residue names
+func chainPDB(chainID byte, residues []string, serial int) string {
+	var b strings.Builder
+	for i, resName := range residues {
+		fmt.Fprintf(&b, "ATOM  %5d  CA  %3s %c%4d      %6.3f  10.000  10.000  1.00 20.00           C\n",
+			serial+i, resName, chainID, i+1, float64(i)*3.8)
+	}
+	return b.String()
+}
+
+func TestStoichiometry(t *testing.T) {
+	alpha := []string{"VAL", "LEU", "SER", "PRO", "ALA", "ASP", "LYS", "THR", "ASN", "VAL"}
+	alphaMutant := []string{"VAL", "LEU", "SER", "PRO", "ALA", "ASP", "LYS", "THR", "ASN", "ILE"}
+	beta := []string{"VAL", "HIS", "LEU", "THR", "PRO", "GLU", "GLU", "LYS"}
+	testPDB := chainPDB('A', alpha, 1) + chainPDB('B', beta, 11) + chainPDB('C', alphaMutant, 21) +
+		chainPDB('D', beta, 31) + "END\n"
+
+	cmd := exec.Command("../bin/pdbtk", "stoichiometry", "--identity", "0.9")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("stoichiometry failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), "Stoichiometry: A2B2 (hetero 4-mer)\n") {
+		t.Errorf("Unexpected output:\n%s", string(output))
+	}
+	if !strings.Contains(string(output), "A: chains A,C, 10 residues, minimum identity 90.0%") {
+		t.Errorf("Expected chains A and C to be grouped:\n%s", string(output))
+	}
+
+	cmd = exec.Command("../bin/pdbtk", "stoichiometry", "--identity", "1", "--format", "json")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err = cmd.Output()
+	if err != nil {
+		t.Fatalf("stoichiometry --format json failed: %v", err)
+	}
+	var result struct {
+		Stoichiometry string `json:"stoichiometry"`
+		Groups        []struct {
+			Chains []string `json:"chains"`
+		} `json:"groups"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		t.Fatalf("Invalid JSON output: %v\n%s", err, string(output))
+	}
+	if result.Stoichiometry != "A2BC" || len(result.Groups) != 3 {
+		t.Errorf("Expected A2BC with identical sequences only, got %+v", result)
+	}
+}
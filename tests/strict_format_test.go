@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const strictFormatTestPDB = `ATOM      1  N   GLY A   1      11.104   6.134  -6.504  1.00 11.18           N
+ATOM      2  CA  GLY A   1      11.639   6.071  -5.147  1.00 10.53           C
+ATOM      3  N   ALA A   2      12.950   6.180  -4.980  1.00 11.18           N
+ATOM      4  CA  ALA A   2      13.800   6.071  -3.790  1.00 10.53           C
+END
+`
+
+func TestStrictFormat(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pdbtk_strict_format_")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "input.pdb")
+	if err := os.WriteFile(input, []byte(strictFormatTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Residue 10000 does not fit in columns 23-26, which is written without --strict-format
+	out, err := exec.Command("../bin/pdbtk", "renumber-residues", "--start", "9999", input).CombinedOutput()
+	if err != nil {
+		t.Fatalf("renumber-residues failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "ALA A10000") {
+		t.Errorf("Expected the overflowing residue number in the output, got:\n%s", out)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "--strict-format", "renumber-residues", "--start", "9999", input)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	var stdout strings.Builder
+	cmd.Stdout = &stdout
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 with --strict-format, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `residue number "10000" does not fit in columns 23-26`) {
+		t.Errorf("Expected the format violation in the error, got: %s", stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected no output with --strict-format, got:\n%s", stdout.String())
+	}
+
+	// With --outdir the malformed file is not written
+	outdir := filepath.Join(dir, "out")
+	cmd = exec.Command("../bin/pdbtk", "--strict-format", "renumber-residues", "--start", "9999", "--outdir", outdir, input)
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 with --strict-format and --outdir, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(outdir, "input.pdb")); err == nil {
+		t.Error("Expected no output file for the malformed output")
+	}
+
+	// Well-formed output is written unchanged
+	for _, args := range [][]string{
+		{"renumber-residues", "--start", "9998", input},
+		{"extract", "--chains", "A", input},
+		{"tidy", input},
+		{"canonicalize", input},
+	} {
+		plain, err := exec.Command("../bin/pdbtk", append([]string{"--no-provenance"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s failed: %v\n%s", args[0], err, plain)
+		}
+		checked, err := exec.Command("../bin/pdbtk", append([]string{"--no-provenance", "--strict-format"}, args...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%s --strict-format failed: %v\n%s", args[0], err, checked)
+		}
+		if string(plain) != string(checked) {
+			t.Errorf("%s: expected --strict-format not to change the output, got:\n%s\nwant:\n%s", args[0], checked, plain)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type symmetryOpsReport struct {
+	SpaceGroup string `json:"space_group"`
+	Number     int    `json:"number"`
+	Operators  []struct {
+		ID          string        `json:"id"`
+		XYZ         string        `json:"xyz"`
+		Rotation    [3][3]float64 `json:"rotation"`
+		Translation [3]float64    `json:"translation"`
+	} `json:"operators"`
+	Assemblies []struct {
+		Assembly  string   `json:"assembly"`
+		Chains    []string `json:"chains"`
+		Operators []struct {
+			Translation [3]float64 `json:"translation"`
+		} `json:"operators"`
+	} `json:"assemblies"`
+}
+
+func runSymmetryOps(t *testing.T, args ...string) symmetryOpsReport {
+	t.Helper()
+	output, err := exec.Command("../bin/pdbtk", append([]string{"symmetry-ops", "--format", "json"}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("symmetry-ops %v failed: %v", args, err)
+	}
+	var report symmetryOpsReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, string(output))
+	}
+	return report
+}
+
+func TestSymmetryOps(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "crystal.pdb")
+	content := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+REMARK 350 BIOMOLECULE: 1
+REMARK 350 APPLY THE FOLLOWING TO CHAINS: A, B
+REMARK 350   BIOMT1   1  1.000000  0.000000  0.000000        0.00000
+REMARK 350   BIOMT2   1  0.000000  1.000000  0.000000        0.00000
+REMARK 350   BIOMT3   1  0.000000  0.000000  1.000000        0.00000
+REMARK 350   BIOMT1   2 -1.000000  0.000000  0.000000       20.00000
+REMARK 350   BIOMT2   2  0.000000  1.000000  0.000000        0.00000
+REMARK 350   BIOMT3   2  0.000000  0.000000 -1.000000       40.00000
+CRYST1   40.000   60.000   80.000  90.00  90.00  90.00 P 21 21 21    8
+` + strings.SplitN(batchTestPDB, "\n", 2)[1]
+	if err := os.WriteFile(input, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write input: %v", err)
+	}
+
+	report := runSymmetryOps(t, input)
+	if report.SpaceGroup != "P 21 21 21" || report.Number != 19 || len(report.Operators) != 4 {
+		t.Fatalf("Expected the 4 operators of P 21 21 21, got %+v", report)
+	}
+	// -x+1/2,-y,z+1/2 translates by a/2 and c/2
+	op := report.Operators[1]
+	if op.XYZ != "-x+1/2,-y,z+1/2" || op.Rotation[0][0] != -1 || op.Rotation[2][2] != 1 || op.Translation != [3]float64{20, 0, 40} {
+		t.Errorf("Unexpected operator 2: %+v", op)
+	}
+	if len(report.Assemblies) != 1 || strings.Join(report.Assemblies[0].Chains, ",") != "A,B" || len(report.Assemblies[0].Operators) != 2 ||
+		report.Assemblies[0].Operators[1].Translation != [3]float64{20, 0, 40} {
+		t.Errorf("Unexpected assembly operators: %+v", report.Assemblies)
+	}
+
+	output, err := exec.Command("../bin/pdbtk", "symmetry-ops", input).Output()
+	if err != nil || !strings.Contains(string(output), "Operator 2: -x+1/2,-y,z+1/2\n   -1.000000   0.000000   0.000000       20.00000\n") {
+		t.Errorf("Unexpected text output (%v):\n%s", err, string(output))
+	}
+
+	for group, count := range map[string]int{"P 1": 1, "P 43 21 2": 8, "H 3 2": 18, "P 61 2 2": 12, "I 41 3 2": 48, "C 2": 4} {
+		if report := runSymmetryOps(t, "--space-group", group); len(report.Operators) != count {
+			t.Errorf("Expected %d operators for %s, got %d", count, group, len(report.Operators))
+		}
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "symmetry-ops", "--space-group", "P 21/c")); code != 1 {
+		t.Errorf("Expected exit code 1 for an unsupported space group, got %d", code)
+	}
+	plain := filepath.Join(t.TempDir(), "plain.pdb")
+	os.WriteFile(plain, []byte(batchTestPDB), 0644)
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "symmetry-ops", plain)); code != 2 {
+		t.Errorf("Expected exit code 2 without CRYST1 or BIOMT records, got %d", code)
+	}
+}
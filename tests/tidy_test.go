@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTidyCommand(t *testing.T) {
+	messyPDB := "header    MESSY\n" +
+		"MODEL        1\n" +
+		"MODEL        1\n" +
+		"atom      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18\t\n" +
+		"\n" +
+		"ATOM      2  CA  ALA A   1      19.030  16.206  23.362  1.00 10.53   \n" +
+		"HETATM    3 ZN    ZN A 101      10.000  10.000  10.000  1.00 20.00\n" +
+		"ENDMDL\n"
+
+	cmd := exec.Command("../bin/pdbtk", "tidy")
+	cmd.Stdin = strings.NewReader(messyPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("tidy command failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	for _, line := range lines {
+		if len(line) != 80 {
+			t.Errorf("Expected every line to be 80 columns, got %d: %q", len(line), line)
+		}
+	}
+
+	outputStr := string(output)
+	if !strings.HasPrefix(outputStr, "HEADER    MESSY") {
+		t.Errorf("Expected normalized HEADER record, got: %s", lines[0])
+	}
+	if strings.Count(outputStr, "MODEL ") != 0 {
+		t.Errorf("Expected duplicate MODEL records to be collapsed into a single model, got:\n%s", outputStr)
+	}
+	expected := []string{
+		"ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           N",
+		"ATOM      2  CA  ALA A   1      19.030  16.206  23.362  1.00 10.53           C",
+		"TER       3      ALA A   1",
+		"HETATM    4 ZN    ZN A 101      10.000  10.000  10.000  1.00 20.00          ZN",
+		"END",
+	}
+	for _, e := range expected {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+}
+
+func TestTidyTabs(t *testing.T) {
+	// Tabs stop at every 8 columns, which puts these fields in their fixed columns
+	tabbedPDB := "ATOM\t  1  N   ALA A   1\t20.154  16.967  23.862  1.00 11.18           N\n"
+
+	cmd := exec.Command("../bin/pdbtk", "--no-provenance", "tidy")
+	cmd.Stdin = strings.NewReader(tabbedPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("tidy command failed: %v", err)
+	}
+	if !strings.HasPrefix(string(output), "ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           N") {
+		t.Errorf("Expected the tabs to be expanded to their columns, without a HEADER record, got:\n%s", output)
+	}
+}
+
+func TestTidyStandardizeResidues(t *testing.T) {
+	testPDB := `ATOM      1  N   GLY A   1      10.000  10.000  10.000  1.00 20.00           N
+HETATM    2  N   MSE A   2      11.000  10.000  10.000  1.00 20.00           N
+HETATM    3 SE   MSE A   2      12.000  10.000  10.000  1.00 20.00          SE
+HETATM    4  OG  SEP A   3      13.000  10.000  10.000  1.00 20.00           O
+HETATM    5  P   SEP A   3      14.000  10.000  10.000  1.00 20.00           P
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "tidy", "--standardize-residues")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("tidy --standardize-residues failed: %v", err)
+	}
+
+	outputStr := string(output)
+	expected := []string{
+		"ATOM      2  N   MET A   2      11.000  10.000  10.000  1.00 20.00           N",
+		"ATOM      3  SD  MET A   2      12.000  10.000  10.000  1.00 20.00           S",
+		"ATOM      4  OG  SER A   3      13.000  10.000  10.000  1.00 20.00           O",
+	}
+	for _, e := range expected {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+	if strings.Contains(outputStr, "HETATM") || strings.Contains(outputStr, " P   ") {
+		t.Errorf("Expected HETATM records and phosphate atoms to be removed, got:\n%s", outputStr)
+	}
+}
+
+func TestTidyStandardizeNorleucine(t *testing.T) {
+	testPDB := `HETATM    1  N   NLE A   1       8.755  10.872  10.000  1.00 20.00           N
+HETATM    2  CA  NLE A   1      10.000  10.000  10.000  1.00 20.00           C
+HETATM    3  CB  NLE A   1      11.245  10.872  10.000  1.00 20.00           C
+HETATM    4  CG  NLE A   1      12.490  10.000  10.000  1.00 20.00           C
+HETATM    5  CD  NLE A   1      13.735  10.872  10.000  1.00 20.00           C
+HETATM    6  CE  NLE A   1      14.980  10.000  10.000  1.00 20.00           C
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "tidy", "--standardize-residues")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("tidy --standardize-residues failed: %v", err)
+	}
+
+	coords := make(map[string][3]float64)
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "ATOM") {
+			continue
+		}
+		if resName := strings.TrimSpace(line[17:20]); resName != "LEU" {
+			t.Errorf("Expected NLE to become LEU, got %s", resName)
+		}
+		var xyz [3]float64
+		for i := range xyz {
+			xyz[i], _ = strconv.ParseFloat(strings.TrimSpace(line[30+8*i:38+8*i]), 64)
+		}
+		coords[strings.TrimSpace(line[12:16])] = xyz
+	}
+	if _, ok := coords["CE"]; ok {
+		t.Errorf("Expected CE to be removed, got:\n%s", output)
+	}
+	cg, okCG := coords["CG"]
+	cd1, okCD1 := coords["CD1"]
+	if !okCG || !okCD1 {
+		t.Fatalf("Expected CG and CD1 atoms, got:\n%s", output)
+	}
+	distance := math.Sqrt(math.Pow(cg[0]-cd1[0], 2) + math.Pow(cg[1]-cd1[1], 2) + math.Pow(cg[2]-cd1[2], 2))
+	if math.Abs(distance-1.52) > 0.05 {
+		t.Errorf("Expected a CG-CD1 bond of about 1.52 A, got %.2f A", distance)
+	}
+}
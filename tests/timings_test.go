@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// timingPhases returns the phases listed in the --timings summary on stderr
+func timingPhases(t *testing.T, stderr string) []string {
+	_, summary, ok := strings.Cut(stderr, "Timings:\n")
+	if !ok {
+		t.Fatalf("Expected a timings summary, got:\n%s", stderr)
+	}
+	var phases []string
+	for _, line := range strings.Split(strings.TrimSpace(summary), "\n")[1:] {
+		phases = append(phases, strings.Fields(line)[0])
+	}
+	return phases
+}
+
+func TestTimings(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.pdb")
+	if err := os.WriteFile(input, []byte(batchTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	plain, err := exec.Command("../bin/pdbtk", "--no-provenance", "extract", "--chains", "A", input).Output()
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	report := filepath.Join(dir, "report.json")
+	cmd := exec.Command("../bin/pdbtk", "--no-provenance", "--timings", "--report", report, "extract", "--chains", "A", input)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	timed, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract --timings failed: %v\n%s", err, stderr.String())
+	}
+	if string(timed) != string(plain) {
+		t.Errorf("Expected --timings not to change the output, got:\n%s", timed)
+	}
+	if phases := strings.Join(timingPhases(t, stderr.String()), " "); phases != "read parse filter write total" {
+		t.Errorf("Unexpected phases: %s", phases)
+	}
+
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	var parsed struct {
+		Timings struct {
+			Seconds       float64 `json:"seconds"`
+			PeakHeapBytes uint64  `json:"peak_heap_bytes"`
+			Phases        []struct {
+				Phase   string  `json:"phase"`
+				Seconds float64 `json:"seconds"`
+			} `json:"phases"`
+		} `json:"timings"`
+	}
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		t.Fatalf("Invalid report JSON: %v", err)
+	}
+	if len(parsed.Timings.Phases) != 4 || parsed.Timings.Seconds <= 0 || parsed.Timings.PeakHeapBytes == 0 {
+		t.Errorf("Unexpected timings in the report: %s", content)
+	}
+	sum := 0.0
+	for _, phase := range parsed.Timings.Phases {
+		sum += phase.Seconds
+	}
+	if sum > parsed.Timings.Seconds*1.01 {
+		t.Errorf("Phases take %fs, more than the run (%fs)", sum, parsed.Timings.Seconds)
+	}
+
+	// Without --timings there is no summary and no timings in the report
+	cmd = exec.Command("../bin/pdbtk", "--report", report, "extract", "--chains", "A", "-o", filepath.Join(dir, "out.pdb"), input)
+	if out, err := cmd.CombinedOutput(); err != nil || strings.Contains(string(out), "Timings:") {
+		t.Errorf("Unexpected output without --timings: %v\n%s", err, out)
+	}
+	if content, _ := os.ReadFile(report); strings.Contains(string(content), "timings") {
+		t.Errorf("Expected no timings in the report without --timings: %s", content)
+	}
+}
+
+func TestTimingsDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(batchTestPDB + "\n"))
+	}))
+	defer server.Close()
+
+	cmd := exec.Command("../bin/pdbtk", "--timings", "get", "--outdir", t.TempDir(), "1ABC", "2ABC")
+	cmd.Env = append(cmd.Environ(), "PDBTK_RCSB_FILES_URL="+server.URL)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("get --timings failed: %v\n%s", err, out)
+	}
+	if phases := strings.Join(timingPhases(t, string(out)), " "); phases != "download filter write total" {
+		t.Errorf("Unexpected phases: %s", phases)
+	}
+}
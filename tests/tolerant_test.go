@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// malformedTestPDB has a bad serial number and occupancy (line 3), a bad residue number (line 4),
+// whitespace-separated fields (line 5), a record too short to read (line 6) and a serial and residue
+// number too large for their columns (line 7)
+const malformedTestPDB = `MODEL 1
+ATOM      1  N   GLY A   1      11.104   6.134  -6.504  1.00 11.18           N
+ATOM     xx  CA  GLY A   1      11.639   6.071  -5.147   abc 10.53           C
+ATOM      3  C   GLY A   ?      12.950   6.180  -4.980  1.00 11.18           C
+ATOM 4 N ALA A 2 13.800 6.071 -3.790 1.00 10.53 N
+ATOM      5  CA  ALA A   2
+ATOM100000  CA  ALA A10000     14.100   6.500  -2.900  1.00 10.53           C
+ENDMDL
+END
+`
+
+func TestTolerantParsing(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "malformed.pdb")
+	if err := os.WriteFile(input, []byte(malformedTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "extract", "--chains", "A", input)); code != 3 {
+		t.Errorf("Expected exit code 3 without --tolerant, got %d", code)
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "--tolerant", "extract", "--chains", "A", input)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("extract --tolerant failed: %v\n%s", err, stderr.String())
+	}
+	var atoms []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			atoms = append(atoms, strings.TrimSpace(line[12:27]))
+		}
+	}
+	if got := strings.Join(atoms, ","); got != "N   GLY A   1,CA  GLY A   1,C   GLY A   1,N   ALA A   2,CA  ALA A   0" {
+		t.Errorf("Unexpected atoms: %s", got)
+	}
+	for _, warning := range []string{
+		input + `:1: MODEL serial number 1 is not in columns 11-14`,
+		input + `:3: invalid atom serial number "xx", numbered 2`,
+		input + `:3: invalid occupancy "abc", using 1.00`,
+		input + `:4: invalid residue number "?", using 1`,
+		input + `:5: record name "ATOM 4" is not in columns 1-6; read the record as whitespace-separated fields`,
+		input + `:6: skipped unreadable coordinate record`,
+		input + `:7: residue number 10000 does not fit in columns 23-26, wrapped to 0`,
+	} {
+		if !strings.Contains(stderr.String(), "Warning: "+warning) {
+			t.Errorf("Expected warning %q, got:\n%s", warning, stderr.String())
+		}
+	}
+
+	// Warnings are structured, with the input and line number, in --json-errors output and the report
+	report := filepath.Join(dir, "report.json")
+	cmd = exec.Command("../bin/pdbtk", "--tolerant", "--json-errors", "--report", report, "tidy", "-o", filepath.Join(dir, "tidy.pdb"), input)
+	stderr.Reset()
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("tidy --tolerant failed: %v\n%s", err, stderr.String())
+	}
+	var first struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Input   string `json:"input"`
+		Line    int    `json:"line"`
+	}
+	if err := json.Unmarshal([]byte(strings.SplitN(stderr.String(), "\n", 2)[0]), &first); err != nil {
+		t.Fatalf("Invalid JSON warning: %v\n%s", err, stderr.String())
+	}
+	if first.Code != "warning" || first.Input != input || first.Line != 1 {
+		t.Errorf("Unexpected warning: %+v", first)
+	}
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("Failed to read report: %v", err)
+	}
+	if !strings.Contains(string(content), `"line": 7`) {
+		t.Errorf("Expected line numbers in the report warnings: %s", content)
+	}
+
+	// --strict turns the first repair into an error
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "--tolerant", "--strict", "extract", "--chains", "A", input)); code != 5 {
+		t.Errorf("Expected exit code 5 with --tolerant --strict, got %d", code)
+	}
+
+	// validate checks the input as it is
+	if code := exitCodeOf(t, exec.Command("../bin/pdbtk", "--tolerant", "validate", input)); code == 0 {
+		t.Error("Expected validate --tolerant to report the malformed records")
+	}
+}
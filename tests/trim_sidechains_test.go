@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const trimTestPDB = `SSBOND   1 CYS A    2    CYS A    4                          1555   1555  2.04  
+ATOM      1  N   GLY A   1      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CA  GLY A   1      11.400  10.000  10.000  1.00 20.00           C
+ATOM      3  C   GLY A   1      12.000  11.400  10.000  1.00 20.00           C
+ATOM      4  O   GLY A   1      11.300  12.400  10.000  1.00 20.00           O
+ATOM      5  N   CYS A   2      13.300  11.500  10.000  1.00 20.00           N
+ATOM      6  CA  CYS A   2      14.000  12.800  10.000  1.00 20.00           C
+ATOM      7  C   CYS A   2      15.500  12.600  10.000  1.00 20.00           C
+ATOM      8  O   CYS A   2      16.000  11.500  10.000  1.00 20.00           O
+ATOM      9  CB  CYS A   2      13.600  13.600  11.200  1.00 20.00           C
+ATOM     10  SG  CYS A   2      14.200  15.300  11.200  1.00 20.00           S
+ATOM     11  HA  CYS A   2      13.700  13.300   9.100  1.00 20.00           H
+HETATM   12  N   MSE A   3      16.200  13.700  10.000  1.00 20.00           N
+HETATM   13  CA  MSE A   3      17.600  13.700  10.000  1.00 20.00           C
+HETATM   14  C   MSE A   3      18.200  15.100  10.000  1.00 20.00           C
+HETATM   15  O   MSE A   3      17.500  16.100  10.000  1.00 20.00           O
+HETATM   16  CB  MSE A   3      18.100  12.900  11.200  1.00 20.00           C
+HETATM   17  CG  MSE A   3      19.600  12.700  11.200  1.00 20.00           C
+HETATM   18 SE   MSE A   3      20.300  11.800  12.800  1.00 20.00          SE
+ATOM     19  N   CYS A   4      19.500  15.200  10.000  1.00 20.00           N
+ATOM     20  CA  CYS A   4      20.100  16.500  10.000  1.00 20.00           C
+ATOM     21  C   CYS A   4      21.600  16.400  10.000  1.00 20.00           C
+ATOM     22  O   CYS A   4      22.200  15.300  10.000  1.00 20.00           O
+ATOM     23  CB  CYS A   4      19.600  17.300  11.200  1.00 20.00           C
+ATOM     24  SG  CYS A   4      15.900  15.800  12.300  1.00 20.00           S
+ATOM     25  OXT CYS A   4      22.300  17.400  10.000  1.00 20.00           O
+HETATM   26  O   HOH A 101      25.000  25.000  25.000  1.00 30.00           O
+END`
+
+func TestTrimSidechains(t *testing.T) {
+	run := func(args ...string) string {
+		cmd := exec.Command("../bin/pdbtk", append([]string{"trim-sidechains"}, args...)...)
+		cmd.Stdin = strings.NewReader(trimTestPDB)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("trim-sidechains %v failed: %v", args, err)
+		}
+		return string(output)
+	}
+	// atoms lists the record type, atom and residue names of the coordinate records
+	atoms := func(output string) []string {
+		var result []string
+		for _, line := range strings.Split(output, "\n") {
+			if strings.HasPrefix(line, "ATOM") || strings.HasPrefix(line, "HETATM") {
+				result = append(result, strings.TrimSpace(line[0:6])+" "+strings.TrimSpace(line[12:16])+" "+line[17:20]+" "+strings.TrimSpace(line[22:26]))
+			}
+		}
+		return result
+	}
+
+	polyAla := run()
+	expected := []string{
+		"ATOM N GLY 1", "ATOM CA GLY 1", "ATOM C GLY 1", "ATOM O GLY 1",
+		"ATOM N ALA 2", "ATOM CA ALA 2", "ATOM C ALA 2", "ATOM O ALA 2", "ATOM CB ALA 2",
+		"ATOM N ALA 3", "ATOM CA ALA 3", "ATOM C ALA 3", "ATOM O ALA 3", "ATOM CB ALA 3",
+		"ATOM N ALA 4", "ATOM CA ALA 4", "ATOM C ALA 4", "ATOM O ALA 4", "ATOM CB ALA 4", "ATOM OXT ALA 4",
+		"HETATM O HOH 101",
+	}
+	if got := atoms(polyAla); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected poly-Ala atoms:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if strings.Contains(polyAla, "SSBOND") {
+		t.Error("Expected the SSBOND record to be dropped")
+	}
+
+	polyGly := run("--to", "gly", "--chains", "A:3-4")
+	got := atoms(polyGly)
+	for _, atom := range []string{"ATOM SG CYS 2", "ATOM N GLY 3", "ATOM OXT GLY 4"} {
+		if !containsLine(got, atom) {
+			t.Errorf("Expected %q in poly-Gly output:\n%s", atom, strings.Join(got, "\n"))
+		}
+	}
+	for _, atom := range []string{"ATOM CB GLY 3", "ATOM CB GLY 4"} {
+		if containsLine(got, atom) {
+			t.Errorf("Expected no %q in poly-Gly output", atom)
+		}
+	}
+
+	cmd := exec.Command("../bin/pdbtk", "trim-sidechains", "--to", "ser")
+	cmd.Stdin = strings.NewReader(trimTestPDB)
+	if code := exitCodeOf(t, cmd); code != 1 {
+		t.Errorf("Expected exit code 1 for an invalid --to, got %d", code)
+	}
+}
+
+// containsLine reports whether lines contains s
+func containsLine(lines []string, s string) bool {
+	for _, line := range lines {
+		if line == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+const featuresTestPDB = `HEADER    TEST PROTEIN                            01-JAN-01   1ABC
+ATOM      1  N   VAL A  10      10.000  10.000  10.000  1.00 20.00           N
+ATOM      2  CA  VAL A  10      11.458  10.000  10.000  1.00 20.00           C
+ATOM      3  N   LEU A  10A     13.000  10.000  10.000  1.00 20.00           N
+ATOM      4  CA  LEU A  10A     14.458  10.000  10.000  1.00 20.00           C
+ATOM      5  N   GLY A  11      16.000  10.000  10.000  1.00 20.00           N
+ATOM      6  CA  GLY A  11      17.458  10.000  10.000  1.00 20.00           C
+END
+`
+
+const featuresTestUniProt = `{"primaryAccession": "P12345", "features": [
+  {"type": "Domain", "description": "First domain", "location": {"start": {"value": 2}, "end": {"value": 2}}},
+  {"type": "Domain", "description": "Second domain", "location": {"start": {"value": 3}, "end": {"value": 5}}},
+  {"type": "Active site", "description": "Nucleophile", "location": {"start": {"value": 3}, "end": {"value": 3}}}
+]}`
+
+func TestUniprotFeatures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sifts/1abc.xml.gz":
+			w.Write([]byte(siftsTestXML))
+		case "/uniprot/uniprotkb/P12345.json":
+			w.Write([]byte(featuresTestUniProt))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	if err := os.WriteFile("test_features.pdb", []byte(featuresTestPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_features.pdb")
+
+	run := func(args ...string) string {
+		cmd := exec.Command("../bin/pdbtk", append([]string{"uniprot-features"}, args...)...)
+		cmd.Env = append(cmd.Environ(), "PDBTK_SIFTS_URL="+server.URL+"/sifts", "PDBTK_UNIPROT_URL="+server.URL+"/uniprot")
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("uniprot-features %v failed: %v", args, err)
+		}
+		return string(output)
+	}
+
+	// Domains are numbered in the B-factor column, unmapped residues get 0
+	bfactors := make(map[string]string)
+	for _, line := range strings.Split(run("test_features.pdb"), "\n") {
+		if strings.HasPrefix(line, "ATOM") {
+			bfactors[strings.TrimSpace(line[22:27])] = strings.TrimSpace(line[60:66])
+		}
+	}
+	expected := map[string]string{"10": "1.00", "10A": "2.00", "11": "0.00"}
+	for residue, bfactor := range expected {
+		if bfactors[residue] != bfactor {
+			t.Errorf("Expected B-factor %s for residue %s, got %q", bfactor, residue, bfactors[residue])
+		}
+	}
+
+	script := run("--feature", "active_site", "--script", "pymol", "test_features.pdb")
+	for _, line := range []string{"load test_features.pdb", "select feature_1, (chain A and resi 10A)", "color red, feature_1"} {
+		if !strings.Contains(script, line+"\n") {
+			t.Errorf("Expected %q in PyMOL script:\n%s", line, script)
+		}
+	}
+
+	script = run("--script", "chimerax", "test_features.pdb")
+	for _, line := range []string{"open test_features.pdb", "name feature_1 /A:10", "name feature_2 /A:10A", "color feature_2 blue"} {
+		if !strings.Contains(script, line+"\n") {
+			t.Errorf("Expected %q in ChimeraX script:\n%s", line, script)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestValidateValidPDB(t *testing.T) {
+	testPDB := `HEADER    TEST STRUCTURE                                   01-JAN-01   TEST
+ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           N
+ATOM      2  CA  ALA A   1      19.030  16.206  23.362  1.00 10.53           C
+TER       3      ALA A   1
+ATOM      4  N   VAL B   1      30.154  26.967  33.862  1.00 11.18           N
+TER       5      VAL B   1
+END
+`
+	if err := os.WriteFile("test_validate_ok.pdb", []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_validate_ok.pdb")
+
+	output, err := exec.Command("../bin/pdbtk", "validate", "test_validate_ok.pdb").Output()
+	if err != nil {
+		t.Fatalf("validate failed on a valid file: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "0 errors, 0 warnings") {
+		t.Errorf("Expected no violations, got: %s", output)
+	}
+}
+
+func TestValidateInvalidPDB(t *testing.T) {
+	testPDB := `ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           N
+ATOM      2  CA AALA A   1      19.030  16.206  23.362  0.60 10.53           C
+ATOM      3  CA BALA A   1      19.030  16.206  23.362  0.30 10.53           C
+TER       4      ALA A   1
+ATOM      6  N   ALA A   2     20.154  16.967  23.862  1.00 11.18           N
+END
+`
+	if err := os.WriteFile("test_validate_bad.pdb", []byte(testPDB), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	defer os.Remove("test_validate_bad.pdb")
+
+	cmd := exec.Command("../bin/pdbtk", "validate", "test_validate_bad.pdb")
+	output, _ := cmd.Output()
+	if cmd.ProcessState.ExitCode() == 0 {
+		t.Error("Expected validate to fail for a file with errors")
+	}
+
+	outputStr := string(output)
+	expected := []string{
+		"test_validate_bad.pdb:5: warning: x coordinate \" 20.154 \" is not aligned to 3 decimal places",
+		"test_validate_bad.pdb:5: error: chain A continues after its TER record",
+		"test_validate_bad.pdb:5: warning: atom serial number 6 does not follow 4",
+		"test_validate_bad.pdb:2: warning: occupancies of alternate locations of atom CA in residue A:1 sum to 0.90",
+	}
+	for _, e := range expected {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+}
+
+func TestValidateCIF(t *testing.T) {
+	testCIF := `data_TEST
+_entry.id TEST
+loop_
+_atom_site.group_PDB
+_atom_site.id
+_atom_site.label_atom_id
+_atom_site.Cartn_x
+_atom_site.Cartn_y
+_atom_site.Cartn_z
+ATOM 1 N 20.154 16.967 23.862
+ATOM 1 CA 19.030 'bad' 23.362
+`
+	cmd := exec.Command("../bin/pdbtk", "validate")
+	cmd.Stdin = strings.NewReader(testCIF)
+	output, _ := cmd.Output()
+	if cmd.ProcessState.ExitCode() == 0 {
+		t.Error("Expected validate to fail for an invalid mmCIF file")
+	}
+
+	outputStr := string(output)
+	for _, e := range []string{"mmCIF format", "missing mandatory item _atom_site.type_symbol", "duplicate atom id 1", "invalid Cartn_y"} {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+}
+
+func TestValidateIdentifierCollisions(t *testing.T) {
+	testPDB := `ATOM      1  N   ALA A   1      20.154  16.967  23.862  1.00 11.18           N
+ATOM      2  N   GLY A   2      21.154  16.967  23.862  1.00 11.18           N
+TER       3      GLY A   2
+ATOM      4  N   VAL B   1      30.154  26.967  33.862  1.00 11.18           N
+TER       5      VAL B   1
+ATOM      6  N   SER A   2      22.154  16.967  23.862  1.00 11.18           N
+TER       7      SER A   2
+END
+`
+	cmd := exec.Command("../bin/pdbtk", "validate")
+	cmd.Stdin = strings.NewReader(testPDB)
+	output, _ := cmd.Output()
+	if cmd.ProcessState.ExitCode() == 0 {
+		t.Error("Expected validate to fail for a file with residue number collisions")
+	}
+
+	outputStr := string(output)
+	for _, e := range []string{
+		"warning: chain A appears in 2 separate blocks in model 1",
+		"error: residue number A:2 is used by more than one residue",
+	} {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+
+	// The same check warns in commands that select residues
+	cmd = exec.Command("../bin/pdbtk", "extract", "--chain", "A")
+	cmd.Stdin = strings.NewReader(testPDB)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	cmd.Run()
+	if !strings.Contains(stderr.String(), "residue number A:2 is used by more than one residue") {
+		t.Errorf("Expected extract to warn about the residue number collision, got: %s", stderr.String())
+	}
+}
+
+func TestValidateCIFIdentifierCollisions(t *testing.T) {
+	testCIF := `data_TEST
+_entry.id TEST
+loop_
+_atom_site.group_PDB
+_atom_site.id
+_atom_site.type_symbol
+_atom_site.label_atom_id
+_atom_site.label_comp_id
+_atom_site.label_asym_id
+_atom_site.label_seq_id
+_atom_site.Cartn_x
+_atom_site.Cartn_y
+_atom_site.Cartn_z
+_atom_site.auth_seq_id
+_atom_site.auth_asym_id
+_atom_site.pdbx_PDB_ins_code
+_atom_site.pdbx_PDB_model_num
+ATOM 1 N N ALA A 1 20.154 16.967 23.862 1 A ? 1
+ATOM 2 N N GLY A 2 21.154 16.967 23.862 2 A ? 1
+ATOM 3 N N VAL B 1 30.154 26.967 33.862 1 BB ? 1
+ATOM 4 N N SER C 1 22.154 16.967 23.862 2 A ? 1
+ATOM 5 N N THR D 1 31.154 26.967 33.862 7 BB ? 1
+ATOM 6 N N LEU D 2 32.154 26.967 33.862 7 BB ? 1
+`
+	cmd := exec.Command("../bin/pdbtk", "validate")
+	cmd.Stdin = strings.NewReader(testCIF)
+	output, _ := cmd.Output()
+	if cmd.ProcessState.ExitCode() == 0 {
+		t.Error("Expected validate to fail for an mmCIF file with residue number collisions")
+	}
+
+	outputStr := string(output)
+	for _, e := range []string{
+		"warning: chain A appears in 2 separate blocks in model 1",
+		"error: residue number A:2 is used by more than one residue",
+		"error: residue number BB:7 is used by more than one residue",
+	} {
+		if !strings.Contains(outputStr, e) {
+			t.Errorf("Expected output to contain %q, got:\n%s", e, outputStr)
+		}
+	}
+}